@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// runBackfillCommand implements the "backfill" subcommand, which records every broadcast of a
+// schedule entry in a past date range that is still in the timeshift window.
+func runBackfillCommand(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	scheduleFilePath := fs.String("file", defaultSchedulePath(currentProfile), "Path to the schedule file (JSON, YAML or TOML).")
+	name := fs.String("entry", "", "program_name of the schedule entry to record.")
+	fromFlag := fs.String("from", "", "First day of the range, as YYYY-MM-DD in the schedule's time zone.")
+	toFlag := fs.String("to", "", "Last day of the range, as YYYY-MM-DD. Defaults to today.")
+	quiet := fs.Bool("quiet", false, "Suppress progress output and print one summary line per job.")
+	noSpinner := fs.Bool("no-spinner", false, "Disable the progress bar; log progress periodically instead.")
+	overwrite := fs.Bool("overwrite", false, "Record broadcasts again even if they were recorded before, replacing the recordings.")
+	fs.Parse(args)
+
+	if *name == "" || *fromFlag == "" {
+		return fmt.Errorf("usage: %s backfill -entry NAME -from YYYY-MM-DD [-to YYYY-MM-DD]", os.Args[0])
+	}
+	config := loadConfig(currentProfile)
+	from, err := internal.ParseScheduleDate(*fromFlag, config.ScheduleLocation())
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	to := time.Now()
+	if *toFlag != "" {
+		day, err := internal.ParseScheduleDate(*toFlag, config.ScheduleLocation())
+		if err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+		to = day.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return fmt.Errorf("the range from %s to %s is empty", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+
+	schedule, _ := resolveSchedule(currentProfile, config, *scheduleFilePath, flagWasSet(fs, "file"))
+	var entries []internal.ScheduleEntry
+	for _, entry := range schedule {
+		if entry.ProgramName == *name {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no schedule entry named '%s'", *name)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := newOptions(currentProfile, config, schedule, *quiet, *noSpinner)
+	opts.Overwrite = *overwrite
+	summary := &internal.RunSummary{}
+	opts.Summary = summary
+	var errs []error
+	for _, entry := range entries {
+		if err := internal.Backfill(ctx, opts, entry, from, to); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	printRunSummary(os.Stdout, summary, *quiet, opts.Output)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"radikoRecScheduler/internal"
+)
+
+// runCacheCommand implements the "cache" subcommand for inspecting and clearing the caches.
+func runCacheCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s cache <stats|clear> [name]", os.Args[0])
+	}
+
+	caches, err := openCaches(currentProfile, loadConfig(currentProfile))
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "stats":
+		return cacheStats(os.Stdout, caches)
+	case "clear":
+		if len(args) > 1 && args[1] == "tokens" {
+			return clearTokenCache()
+		}
+		targets := caches.All()
+		if len(args) > 1 {
+			cache := caches.Find(args[1])
+			if cache == nil {
+				return fmt.Errorf("unknown cache: %s", args[1])
+			}
+			targets = []*internal.Cache{cache}
+		}
+		for _, cache := range targets {
+			if err := cache.Clear(); err != nil {
+				return err
+			}
+			fmt.Printf("Cleared %s cache.\n", cache.Name())
+		}
+		if len(args) == 1 {
+			return clearTokenCache()
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown cache command: %s", args[0])
+	}
+}
+
+// openCaches opens the caches in the XDG cache directory of profile with the caps from config.
+func openCaches(profile internal.Profile, config *internal.Config) (*internal.Caches, error) {
+	cacheDir, err := profile.CacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	return internal.OpenCaches(cacheDir, config.Cache), nil
+}
+
+// openTokenCache returns the auth token cache, persisted in the XDG cache directory of profile if
+// config asks for it, or nil for the default in-memory cache.
+func openTokenCache(profile internal.Profile, config *internal.Config) *internal.TokenCache {
+	if !config.Cache.PersistTokens {
+		return nil
+	}
+	cacheDir, err := profile.CacheDir()
+	if err != nil {
+		log.Printf("WARNING: Auth tokens will not be kept across runs: failed to get cache directory: %v", err)
+		return nil
+	}
+	return internal.NewTokenCache(filepath.Join(cacheDir, internal.TokenCacheFile), internal.SystemClock)
+}
+
+// clearTokenCache removes the persisted auth tokens, if any.
+func clearTokenCache() error {
+	cacheDir, err := currentProfile.CacheDir()
+	if err != nil {
+		return fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	if err := os.Remove(filepath.Join(cacheDir, internal.TokenCacheFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear auth tokens: %w", err)
+	}
+	fmt.Println("Cleared auth tokens.")
+	return nil
+}
+
+func cacheStats(w io.Writer, caches *internal.Caches) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CACHE\tENTRIES\tSIZE\tLIMIT\tDIRECTORY")
+	for _, cache := range caches.All() {
+		stats, err := cache.Stats()
+		if err != nil {
+			return err
+		}
+		limit := "disabled"
+		if stats.MaxBytes > 0 {
+			limit = formatMB(stats.MaxBytes)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", stats.Name, stats.Entries, formatMB(stats.Bytes), limit, stats.Dir)
+	}
+	return tw.Flush()
+}
+
+func formatMB(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"radikoRecScheduler/internal"
+)
+
+// runConfigCommand implements the "config" subcommand.
+func runConfigCommand(args []string) error {
+	usage := fmt.Errorf("usage: %s config migrate | show [-show-secrets]", os.Args[0])
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "migrate":
+		return runConfigMigrate()
+	case "show":
+		return runConfigShow(args[1:])
+	default:
+		return usage
+	}
+}
+
+// runConfigMigrate moves a separate schedule.json into config.json.
+func runConfigMigrate() error {
+	configPath, err := currentProfile.ConfigPath()
+	if err != nil {
+		return fmt.Errorf("failed to get default config path: %w", err)
+	}
+	schedulePath := defaultSchedulePath(currentProfile)
+
+	count, err := internal.MigrateLegacySchedule(currentProfile, configPath, schedulePath)
+	if err != nil {
+		return fmt.Errorf("failed to migrate schedule: %w", err)
+	}
+	fmt.Printf("Moved %d schedule entries from %s into %s (old file kept as %s.bak).\n", count, schedulePath, configPath, schedulePath)
+	return nil
+}
+
+// runConfigShow prints the effective config.json, with secrets masked unless -show-secrets is given.
+func runConfigShow(args []string) error {
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	showSecrets := fs.Bool("show-secrets", false, "Print passwords, keys and webhook URLs instead of masking them.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config := loadConfig(currentProfile)
+	if !*showSecrets {
+		config = config.Redacted()
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"radikoRecScheduler/internal"
+)
+
+// runGuideCommand implements the "guide" subcommand for searching the program guide.
+func runGuideCommand(args []string) error {
+	if len(args) == 0 || args[0] != "search" {
+		return fmt.Errorf("usage: %s guide search [-station ID,...] [-add N] <keyword>", os.Args[0])
+	}
+
+	fs := flag.NewFlagSet("guide search", flag.ExitOnError)
+	stations := fs.String("station", "", "Comma-separated station IDs to search. Defaults to all stations.")
+	add := fs.Int("add", 0, "Add result number N to the schedule.")
+	fs.Parse(args[1:])
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: %s guide search [-station ID,...] [-add N] <keyword>", os.Args[0])
+	}
+	keyword := strings.Join(fs.Args(), " ")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	config := loadConfig(currentProfile)
+	var stationIDs []string
+	if *stations != "" {
+		stationIDs = strings.Split(*stations, ",")
+	} else {
+		ids, err := internal.GetAllStationIDs(ctx)
+		if err != nil {
+			return err
+		}
+		stationIDs = ids
+	}
+
+	fetch := internal.GetProgramGuide
+	if caches, err := openCaches(currentProfile, config); err == nil {
+		fetch = internal.CachedGuideFetcher(caches.Guide, internal.SystemClock, internal.GetProgramGuide)
+	}
+	matches, errs := internal.SearchGuides(ctx, stationIDs, keyword, fetch)
+	for _, err := range errs {
+		log.Printf("WARNING: Failed to search guide: %v", err)
+	}
+
+	if *add == 0 {
+		return printGuideMatches(os.Stdout, matches)
+	}
+	if *add < 1 || *add > len(matches) {
+		return fmt.Errorf("no result number %d (found %d)", *add, len(matches))
+	}
+	return addGuideMatch(config, matches[*add-1])
+}
+
+func printGuideMatches(w io.Writer, matches []internal.GuideMatch) error {
+	if len(matches) == 0 {
+		fmt.Fprintln(w, "No matching broadcasts found.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tBROADCAST\tSTATION\tTITLE\tPERFORMER")
+	for i, m := range matches {
+		when := m.Prog.Ft
+		if start, err := m.Start(); err == nil {
+			when = start.Format("2006-01-02 (Mon) 15:04")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", i+1, when, m.StationID, m.Prog.Title, m.Prog.Pfm)
+	}
+	return tw.Flush()
+}
+
+// addGuideMatch adds the weekly slot of match to the schedule in use and reports the outcome.
+func addGuideMatch(config *internal.Config, match internal.GuideMatch) error {
+	message, err := scheduleGuideMatch(config, match)
+	if err != nil {
+		return err
+	}
+	fmt.Println(message)
+	return nil
+}
+
+// scheduleGuideMatch adds the weekly slot of match to the schedule in use and returns a
+// message describing the outcome.
+func scheduleGuideMatch(config *internal.Config, match internal.GuideMatch) (string, error) {
+	entry, err := match.ScheduleEntry(config.ScheduleLocation())
+	if err != nil {
+		return "", err
+	}
+	return addScheduleEntry(config, entry)
+}
+
+// addScheduleEntry adds entry to the schedule in use: the file named by RADIREC_SCHEDULE, config.json,
+// or a separate schedule file if that is where the schedule still lives. It returns a message describing
+// the outcome.
+func addScheduleEntry(config *internal.Config, entry internal.ScheduleEntry) (string, error) {
+	var err error
+	path := defaultSchedulePath(currentProfile)
+	isConfig := len(config.Schedule) > 0
+	if env := os.Getenv(internal.SchedulePathEnv); env != "" {
+		path, isConfig = env, false
+	} else if _, statErr := os.Stat(path); isConfig || os.IsNotExist(statErr) {
+		if path, err = currentProfile.ConfigPath(); err != nil {
+			return "", fmt.Errorf("failed to get default config path: %w", err)
+		}
+		isConfig = true
+	}
+
+	added, err := internal.AddScheduleEntry(currentProfile, path, isConfig, entry)
+	if err != nil {
+		return "", err
+	}
+	if !added {
+		return fmt.Sprintf("%s %s %s on %s is already scheduled.", entry.DayOfWeek, entry.StartTime, entry.StationID, path), nil
+	}
+	return fmt.Sprintf("Added %s (%s %s, %s) to %s.", entry.ProgramName, entry.DayOfWeek, entry.StartTime, entry.StationID, path), nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"radikoRecScheduler/internal"
+)
+
+// runHistoryCommand implements the "history" subcommand for exporting the recording history.
+func runHistoryCommand(args []string) error {
+	if len(args) == 0 || args[0] != "export" {
+		return fmt.Errorf("usage: %s history export [-format csv|json] [-o FILE]", os.Args[0])
+	}
+	fs := flag.NewFlagSet("history export", flag.ExitOnError)
+	format := fs.String("format", internal.ExportCSV, "Output format: csv or json.")
+	out := fs.String("o", "", "Write the export to this file instead of standard output.")
+	fs.Parse(args[1:])
+
+	source, err := openJobSource()
+	if err != nil {
+		return err
+	}
+	records, err := source.Jobs(context.Background(), 0)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := internal.ExportHistory(&buf, records, *format); err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write export '%s': %w", *out, err)
+	}
+	return nil
+}
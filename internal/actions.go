@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Actions recorded in the action log.
+const (
+	ActionJobStarted       = "job_started"       // A recording attempt began; its history record has JobID.
+	ActionChunksDownloaded = "chunks_downloaded" // The audio of a job was downloaded.
+	ActionFileWritten      = "file_written"      // A recording was saved.
+	ActionFileDeleted      = "file_deleted"      // A file was removed; Detail says why.
+	ActionJobFinished      = "job_finished"      // A recording attempt ended; Error is set when it failed.
+	ActionPassRequested    = "pass_requested"    // A client of the daemon started a pass.
+	ActionJobCancelled     = "job_cancelled"     // A client of the daemon cancelled a running job.
+)
+
+// ActionRecord is one line of the action log, an append-only account of what the scheduler did,
+// so what an unattended daemon did weeks ago can be reconstructed. Fields that do not apply to
+// the action are left out.
+type ActionRecord struct {
+	Time        time.Time `json:"time"`
+	Action      string    `json:"action"`
+	JobID       string    `json:"job_id,omitempty"` // ID of the job's HistoryRecord.
+	ProgramName string    `json:"program_name,omitempty"`
+	StationID   string    `json:"station_id,omitempty"`
+	Start       time.Time `json:"start,omitzero"` // Broadcast start time.
+	Path        string    `json:"path,omitempty"`
+	Chunks      int       `json:"chunks,omitempty"`
+	Missing     int       `json:"missing,omitempty"` // Chunks left out of the recording.
+	Bytes       int64     `json:"bytes,omitempty"`
+	Detail      string    `json:"detail,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// ActionLogPath returns where the action log is stored, next to the history file.
+func (h *History) ActionLogPath() string {
+	return filepath.Join(filepath.Dir(h.path), "actions.jsonl")
+}
+
+// LogAction appends rec to the action log, stamped with the current time unless it has one.
+func (h *History) LogAction(rec ActionRecord) error {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	path := h.ActionLogPath()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create action log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open action log '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode action: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write action log '%s': %w", path, err)
+	}
+	return file.Close()
+}
+
+// Actions returns the records of the action log in the order they were written.
+// A missing action log yields no records.
+func (h *History) Actions() ([]ActionRecord, error) {
+	path := h.ActionLogPath()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open action log '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	var records []ActionRecord
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec ActionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("error parsing action log '%s' line %d: %w", path, lineNo, err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read action log '%s': %w", path, err)
+	}
+	return records, nil
+}
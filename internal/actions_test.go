@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExecuteJobLogsActions(t *testing.T) {
+	history := OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	entry := ScheduleEntry{ProgramName: "Test Program", StationID: "ST1"}
+
+	opts := JobOptions{OutputDir: t.TempDir(), History: history}
+	if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+	// An upload that removes the local copy is logged as a deletion.
+	opts.OutputDir, opts.PostStore, opts.DeleteLocal = t.TempDir(), &recordingPostStore{}, true
+	if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+	failing := &MockRadikoClient{AuthenticateFn: func(ctx context.Context) error { return fmt.Errorf("auth failed") }}
+	// The upload is in the history, so only --overwrite records the broadcast again.
+	opts.OutputDir, opts.PostStore, opts.Overwrite = t.TempDir(), nil, true
+	if _, err := ExecuteJob(context.Background(), failing, entry, pastTime, opts); err == nil {
+		t.Fatal("expected ExecuteJob to fail")
+	}
+
+	records, err := history.Records()
+	if err != nil || len(records) != 3 {
+		t.Fatalf("Records = (%d records, %v), want 3", len(records), err)
+	}
+	actions, err := history.Actions()
+	if err != nil {
+		t.Fatalf("Actions failed: %v", err)
+	}
+	var got []string
+	for _, action := range actions {
+		got = append(got, action.Action)
+		if action.Time.IsZero() || action.ProgramName != "Test Program" || action.StationID != "ST1" || !action.Start.Equal(pastTime) {
+			t.Errorf("action without its job: %+v", action)
+		}
+	}
+	want := []string{
+		ActionJobStarted, ActionChunksDownloaded, ActionFileWritten, ActionJobFinished,
+		ActionJobStarted, ActionChunksDownloaded, ActionFileWritten, ActionFileDeleted, ActionJobFinished,
+		ActionJobStarted, ActionJobFinished,
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("actions = %v, want %v", got, want)
+	}
+	if actions[0].JobID != records[0].ID || actions[1].Chunks != 2 || actions[2].Path != records[0].OutputPath || actions[2].Bytes != records[0].Size {
+		t.Errorf("unexpected actions of the first job: %+v", actions[:4])
+	}
+	if actions[7].Path != records[1].OutputPath || actions[7].Detail != "uploaded to the post-store" {
+		t.Errorf("unexpected deletion: %+v", actions[7])
+	}
+	if last := actions[len(actions)-1]; last.JobID != records[2].ID || last.Detail != StatusFailed || last.Error != records[2].Error {
+		t.Errorf("unexpected end of the failed job: %+v", last)
+	}
+}
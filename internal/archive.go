@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Archive formats of ScheduleEntry.Archive.
+const (
+	ArchiveOpus = "opus" // Re-encoded to Opus at archiveOpusBitrate, about a third smaller than radiko's AAC.
+	ArchiveFLAC = "flac" // Decoded to FLAC, so later edits add no further lossy generation.
+)
+
+// archiveOpusBitrate keeps speech and music of radio quality; radiko streams at 48 kbps.
+const archiveOpusBitrate = "32k"
+
+// archiveFormat is how recordings of an archive format are encoded and named.
+type archiveFormat struct {
+	ext  string   // File extension of the recordings.
+	args []string // ffmpeg output arguments.
+}
+
+var archiveFormats = map[string]archiveFormat{
+	ArchiveOpus: {ext: ".opus", args: []string{"-c:a", "libopus", "-b:a", archiveOpusBitrate, "-f", "opus"}},
+	ArchiveFLAC: {ext: ".flac", args: []string{"-c:a", "flac", "-f", "flac"}},
+}
+
+// lookupArchiveFormat returns the archive format named name.
+func lookupArchiveFormat(name string) (archiveFormat, error) {
+	format, ok := archiveFormats[name]
+	if !ok {
+		return archiveFormat{}, fmt.Errorf("unknown archive format '%s': use %s or %s", name, ArchiveOpus, ArchiveFLAC)
+	}
+	return format, nil
+}
+
+// transcodeRecording replaces the AAC file at path with a copy in format by ffmpeg, tagged
+// with metadata ("key=value"). On failure path is left as it was.
+func transcodeRecording(ctx context.Context, ffmpeg, path string, format archiveFormat, metadata ...string) error {
+	if ffmpeg == "" {
+		ffmpeg = DefaultFFmpeg
+	}
+	tmp := path + ".archive"
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin", "-y", "-i", path, "-vn"}
+	for _, tag := range metadata {
+		args = append(args, "-metadata", tag)
+	}
+	args = append(append(args, format.args...), tmp)
+	cmd := exec.CommandContext(ctx, ffmpeg, args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmp)
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("ffmpeg failed: %w: %s", err, msg)
+		}
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace '%s' with the transcoded recording: %w", path, err)
+	}
+	return nil
+}
+
+// seriesFormat is the format of recordings in LayoutSeries: radiko's AAC copied as is into an
+// MP4 container, which media servers read durations and seek positions from.
+var seriesFormat = archiveFormat{ext: ".m4a", args: []string{"-c:a", "copy", "-bsf:a", "aac_adtstoasc", "-f", "ipod"}}
+
+// recordingFormat returns the format the recordings of entry are converted to when saved in
+// layout, or false when they are kept as downloaded. An archive format takes precedence over
+// the format of the layout.
+func recordingFormat(entry ScheduleEntry, layout string) (archiveFormat, bool, error) {
+	if entry.Archive != "" {
+		format, err := lookupArchiveFormat(entry.Archive)
+		return format, err == nil, err
+	}
+	if layout == LayoutSeries {
+		return seriesFormat, true, nil
+	}
+	return archiveFormat{}, false, nil
+}
+
+// recordingTags returns the metadata of the broadcast of entry at start saved as title: the
+// album is the entry's program name whatever the title.
+func recordingTags(entry ScheduleEntry, title string, start time.Time) []string {
+	return []string{
+		"title=" + title,
+		"album=" + entry.ProgramName,
+		"artist=" + entry.StationID,
+		"date=" + start.In(JST).Format("2006-01-02"),
+	}
+}
+
+// convert converts the recording at path to format, as returned by recordingFormat for entry,
+// tagged with recordingTags.
+func (o JobOptions) convert(ctx context.Context, logger *log.Logger, path string, entry ScheduleEntry, format archiveFormat, title string, start time.Time) error {
+	if entry.Archive != "" {
+		logger.Printf("INFO: Transcoding to %s for the archive...", entry.Archive)
+	} else {
+		logger.Println("INFO: Copying the audio into an M4A file...")
+	}
+	return transcodeRecording(ctx, o.FFmpeg, path, format, recordingTags(entry, title, start)...)
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranscodeRecording(t *testing.T) {
+	tests := []struct {
+		format   string
+		fail     bool
+		wantArgs string
+		expected string
+	}{
+		{format: ArchiveOpus, wantArgs: "-c:a libopus -b:a " + archiveOpusBitrate + " -f opus", expected: "normalized"},
+		{format: ArchiveFLAC, wantArgs: "-c:a flac -f flac", expected: "normalized"},
+		{format: ArchiveOpus, fail: true, expected: "original"},
+	}
+	for _, tt := range tests {
+		ffmpeg, argsFile := fakeFFmpeg(t, tt.fail)
+		path := filepath.Join(t.TempDir(), "Show.aac")
+		if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		err := transcodeRecording(context.Background(), ffmpeg, path, archiveFormats[tt.format])
+		if (err != nil) != tt.fail {
+			t.Errorf("%s: transcodeRecording = %v, want error %v", tt.format, err, tt.fail)
+		}
+		if !tt.fail {
+			if args, _ := os.ReadFile(argsFile); !strings.Contains(string(args), tt.wantArgs) {
+				t.Errorf("%s: expected ffmpeg arguments %q, got %s", tt.format, tt.wantArgs, args)
+			}
+		}
+		if data, _ := os.ReadFile(path); string(data) != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.format, tt.expected, data)
+		}
+		if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+			t.Errorf("%s: expected no temporary files, got %v", tt.format, entries)
+		}
+	}
+}
+
+func TestExecuteJobArchive(t *testing.T) {
+	ffmpeg, _ := fakeFFmpeg(t, false)
+	outputDir := t.TempDir()
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		Logger:     log.New(io.Discard, "", 0),
+		FFmpeg:     ffmpeg,
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+		DisableProgressBar: true,
+	}
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+
+	entry := ScheduleEntry{ProgramName: "Show", StationID: "ST1", Archive: ArchiveOpus}
+	result, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts)
+	if err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+	want := filepath.Join(outputDir, "20260112100000-ST1-Show.opus")
+	if result.OutputPath != want {
+		t.Errorf("saved to %s, want %s", result.OutputPath, want)
+	}
+	if data, _ := os.ReadFile(want); string(data) != "normalized" {
+		t.Errorf("expected the transcoded recording, got %q", data)
+	}
+	// The archived recording is found by the next run.
+	if result, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err != nil || !result.Skipped {
+		t.Errorf("expected the second run to skip the recording, got (%+v, %v)", result, err)
+	}
+
+	entry.Archive = "mp3"
+	if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err == nil || !strings.Contains(err.Error(), "unknown archive format") {
+		t.Errorf("expected an unknown format error, got %v", err)
+	}
+}
+
+func TestExecuteJobGuideTitle(t *testing.T) {
+	tests := []struct {
+		name           string
+		scheduleTitles bool
+		title          string
+	}{
+		{"Guide title", false, "アフター６ジャンクション"},
+		{"Schedule title", true, "After 6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ffmpeg, argsFile := fakeFFmpeg(t, false)
+			outputDir := t.TempDir()
+			history := OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+			opts := JobOptions{
+				OutputDir:      outputDir,
+				Logger:         log.New(io.Discard, "", 0),
+				FFmpeg:         ffmpeg,
+				History:        history,
+				ScheduleTitles: tt.scheduleTitles,
+				FetchGuide:     func(context.Context, string) ([]byte, error) { return []byte(chaptersGuide), nil },
+
+				DisableProgressBar: true,
+			}
+			entry := ScheduleEntry{ProgramName: "After 6", StationID: "TBS", Archive: ArchiveOpus}
+			pastTime := time.Date(2026, time.January, 12, 18, 0, 0, 0, JST)
+
+			result, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts)
+			if err != nil {
+				t.Fatalf("ExecuteJob failed: %v", err)
+			}
+			if want := filepath.Join(outputDir, "20260112180000-TBS-"+tt.title+".opus"); result.OutputPath != want {
+				t.Errorf("saved to %s, want %s", result.OutputPath, want)
+			}
+			args, _ := os.ReadFile(argsFile)
+			for _, tag := range []string{"-metadata title=" + tt.title + " ", "-metadata album=After 6 ", "-metadata date=2026-01-12 "} {
+				if !strings.Contains(string(args), tag) {
+					t.Errorf("expected %q in ffmpeg arguments %s", tag, args)
+				}
+			}
+			records, err := history.Records()
+			if err != nil || len(records) != 1 {
+				t.Fatalf("expected one history record, got %v, %v", records, err)
+			}
+			rec := records[0]
+			if rec.ProgramName != "After 6" || rec.Title != tt.title || rec.Guide == nil || rec.Guide.Title != "アフター６ジャンクション" {
+				t.Errorf("unexpected history record: program_name %q, title %q, guide %+v", rec.ProgramName, rec.Title, rec.Guide)
+			}
+		})
+	}
+}
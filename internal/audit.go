@@ -0,0 +1,467 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Kinds of problems found by AuditLibrary.
+const (
+	AuditMissing   = "missing"   // The file no longer exists.
+	AuditTruncated = "truncated" // The file is shorter than when it was recorded.
+	AuditCorrupt   = "corrupt"   // The file's size or contents differ from when it was recorded.
+)
+
+// Repair states of an AuditIssue.
+const (
+	RepairQueued  = "queued"   // Can still be re-recorded from the timeshift window; see RepairRecordings.
+	RepairExpired = "expired"  // The broadcast has left the timeshift window; restore it from a backup.
+	RepairDone    = "repaired" // Re-recorded by RepairRecordings.
+	RepairFailed  = "failed"   // Re-recording failed; it is retried by the next RepairRecordings.
+)
+
+// AuditConfig controls the periodic library audit in daemon mode.
+type AuditConfig struct {
+	IntervalDays int  `json:"interval_days"` // Days between audits. 0 disables the periodic audit.
+	AutoRepair   bool `json:"auto_repair"`   // Re-record damaged recordings still in the timeshift window after each audit.
+}
+
+// AuditIssue is a damaged or missing recording found by AuditLibrary.
+type AuditIssue struct {
+	RecordID    string    `json:"record_id"`
+	Path        string    `json:"path"`
+	Kind        string    `json:"kind"`
+	Detail      string    `json:"detail"`
+	StartTime   time.Time `json:"start_time"` // Broadcast start time, to tell whether it can still be re-recorded.
+	Repair      string    `json:"repair"`
+	RepairError string    `json:"repair_error,omitempty"`
+}
+
+// AuditState is the progress and result of a library audit. It is saved as the audit runs,
+// so an interrupted audit of a large archive resumes where it stopped instead of starting over.
+type AuditState struct {
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"` // Zero while the audit is in progress.
+	Total      int          `json:"total"`       // Recordings to verify.
+	Checked    []string     `json:"checked"`     // IDs of the history records verified so far.
+	Bytes      int64        `json:"bytes"`       // Bytes hashed so far.
+	Unhashed   int          `json:"unhashed"`    // Recordings from before hashes were stored; only their presence is checked.
+	Issues     []AuditIssue `json:"issues,omitempty"`
+}
+
+// Finished reports whether the audit ran to completion.
+func (s *AuditState) Finished() bool {
+	return !s.FinishedAt.IsZero()
+}
+
+// AuditStatePath returns where the state of the library audit is stored, next to the history file.
+func (h *History) AuditStatePath() string {
+	return filepath.Join(filepath.Dir(h.path), "audit.json")
+}
+
+// LoadAuditState reads the audit state at path. A missing file yields nil.
+func LoadAuditState(path string) (*AuditState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read audit state '%s': %w", path, err)
+	}
+	var state AuditState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error parsing audit state '%s': %w", path, err)
+	}
+	return &state, nil
+}
+
+// save writes the state to path atomically.
+func (s *AuditState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode audit state: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write audit state '%s': %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write audit state '%s': %w", path, err)
+	}
+	return nil
+}
+
+// AuditOptions configures AuditLibrary. Only History is required.
+type AuditOptions struct {
+	History          *History
+	Logger           *log.Logger   // Defaults to the standard logger.
+	Clock            Clock         // Defaults to SystemClock.
+	ProgressInterval time.Duration // How often progress is logged and saved. Defaults to 30 seconds.
+	Restart          bool          // Discard an unfinished audit instead of resuming it.
+}
+
+// auditTargets returns the latest successful recording of each broadcast, leaving out
+// recordings whose local file was removed after an upload. A later recording (e.g. a repair)
+// supersedes earlier ones of the same broadcast.
+func auditTargets(records []HistoryRecord) []HistoryRecord {
+	latest := make(map[string]int)
+	var targets []HistoryRecord
+	for _, rec := range records {
+		if rec.Status != StatusSuccess || rec.OutputPath == "" {
+			continue
+		}
+		key := broadcastKey(rec.StationID, rec.StartTime)
+		if rec.Rerun {
+			key += "|rerun"
+		}
+		if i, ok := latest[key]; ok {
+			targets[i] = rec
+			continue
+		}
+		latest[key] = len(targets)
+		targets = append(targets, rec)
+	}
+
+	kept := targets[:0]
+	for _, rec := range targets {
+		if !rec.LocalRemoved {
+			kept = append(kept, rec)
+		}
+	}
+	return kept
+}
+
+// AuditLibrary verifies every recording in the history against the size and SHA-256 hash stored
+// when it was recorded, detecting missing, truncated and bit-rotten files. Damaged recordings that
+// are still in the timeshift window are queued for RepairRecordings.
+// Progress is logged and saved periodically; an unfinished audit is resumed unless Restart is set.
+// When ctx is cancelled the progress is saved and ctx.Err() is returned along with the partial state.
+func AuditLibrary(ctx context.Context, opts AuditOptions) (*AuditState, error) {
+	if opts.History == nil {
+		return nil, fmt.Errorf("no history to audit")
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	clock := opts.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	interval := opts.ProgressInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	statePath := opts.History.AuditStatePath()
+
+	records, err := opts.History.Records()
+	if err != nil {
+		return nil, err
+	}
+	targets := auditTargets(records)
+
+	state, err := LoadAuditState(statePath)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil || state.Finished() || opts.Restart {
+		state = &AuditState{StartedAt: clock.Now()}
+	} else {
+		logger.Printf("INFO: Resuming the library audit started at %s (%d recordings already verified).", state.StartedAt.Format("2006-01-02 15:04:05"), len(state.Checked))
+	}
+	state.Total = len(targets)
+
+	checked := make(map[string]bool, len(state.Checked))
+	for _, id := range state.Checked {
+		checked[id] = true
+	}
+
+	lastProgress := clock.Now()
+	for _, rec := range targets {
+		if checked[rec.ID] {
+			continue
+		}
+		issue, hashed, err := verifyRecording(ctx, rec)
+		if err != nil {
+			if ctx.Err() != nil {
+				if saveErr := state.save(statePath); saveErr != nil {
+					logger.Printf("WARNING: %v", saveErr)
+				}
+				return state, ctx.Err()
+			}
+			return state, err
+		}
+		state.Bytes += hashed
+		if rec.SHA256 == "" {
+			state.Unhashed++
+		}
+		if issue != nil {
+			issue.Repair = RepairExpired
+			if clock.Now().Sub(rec.StartTime) < TimeshiftWindow {
+				issue.Repair = RepairQueued
+			}
+			logger.Printf("WARNING: %s recording %s: %s", issue.Kind, issue.Path, issue.Detail)
+			state.Issues = append(state.Issues, *issue)
+		}
+		state.Checked = append(state.Checked, rec.ID)
+		checked[rec.ID] = true
+
+		if now := clock.Now(); now.Sub(lastProgress) >= interval {
+			lastProgress = now
+			logger.Printf("INFO: Audited %d/%d recordings (%s hashed).", len(state.Checked), state.Total, formatBytes(state.Bytes))
+			if err := state.save(statePath); err != nil {
+				logger.Printf("WARNING: %v", err)
+			}
+		}
+	}
+
+	state.FinishedAt = clock.Now()
+	if err := state.save(statePath); err != nil {
+		return state, err
+	}
+	logger.Printf("INFO: Library audit finished: %d recordings, %d issue(s).", state.Total, len(state.Issues))
+	return state, nil
+}
+
+// verifyRecording checks the file of rec against its recorded size and hash.
+// It returns the problem found, if any, and the number of bytes hashed.
+// Recordings without a stored hash are only checked for presence.
+func verifyRecording(ctx context.Context, rec HistoryRecord) (*AuditIssue, int64, error) {
+	issue := func(kind, detail string) *AuditIssue {
+		return &AuditIssue{RecordID: rec.ID, Path: rec.OutputPath, Kind: kind, Detail: detail, StartTime: rec.StartTime}
+	}
+
+	files, err := recordingFiles(rec.OutputPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return issue(AuditMissing, "file not found"), 0, nil
+		}
+		return issue(AuditCorrupt, err.Error()), 0, nil
+	}
+	var size int64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				detail := "file not found"
+				if file != rec.OutputPath {
+					detail = fmt.Sprintf("part %s not found", filepath.Base(file))
+				}
+				return issue(AuditMissing, detail), 0, nil
+			}
+			return issue(AuditCorrupt, err.Error()), 0, nil
+		}
+		size += info.Size()
+	}
+	if rec.SHA256 == "" {
+		return nil, 0, nil
+	}
+	if size < rec.Size {
+		return issue(AuditTruncated, fmt.Sprintf("%s of %s", formatBytes(size), formatBytes(rec.Size))), 0, nil
+	}
+	if size != rec.Size {
+		return issue(AuditCorrupt, fmt.Sprintf("size %s, expected %s", formatBytes(size), formatBytes(rec.Size))), 0, nil
+	}
+
+	sum, n, err := hashFileContext(ctx, files...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, n, err
+		}
+		return issue(AuditCorrupt, err.Error()), n, nil
+	}
+	want, err := hex.DecodeString(rec.SHA256)
+	if err != nil || !bytes.Equal(sum, want) {
+		return issue(AuditCorrupt, "SHA-256 mismatch"), n, nil
+	}
+	return nil, n, nil
+}
+
+// hashFileContext returns the SHA-256 hash of the files at paths read one after another,
+// stopping early when ctx is cancelled.
+func hashFileContext(ctx context.Context, paths ...string) ([]byte, int64, error) {
+	h := sha256.New()
+	var total int64
+	for _, path := range paths {
+		n, err := hashInto(ctx, h, path)
+		total += n
+		if err != nil {
+			return nil, total, err
+		}
+	}
+	return h.Sum(nil), total, nil
+}
+
+// hashInto writes the contents of the file at path to h.
+func hashInto(ctx context.Context, h io.Writer, path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	n, err := io.Copy(h, ctxReader{ctx: ctx, r: file})
+	if err != nil {
+		return n, fmt.Errorf("failed to hash '%s': %w", path, err)
+	}
+	return n, nil
+}
+
+// ctxReader fails reads once ctx is cancelled.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// auditDue reports whether the periodic audit should run: when none has finished within
+// the configured interval, or an earlier one was interrupted.
+func auditDue(cfg AuditConfig, history *History, now time.Time) (bool, error) {
+	if cfg.IntervalDays <= 0 || history == nil {
+		return false, nil
+	}
+	state, err := LoadAuditState(history.AuditStatePath())
+	if err != nil {
+		return false, err
+	}
+	if state == nil || !state.Finished() {
+		return true, nil
+	}
+	return now.Sub(state.FinishedAt) >= time.Duration(cfg.IntervalDays)*24*time.Hour, nil
+}
+
+// RepairRecordings re-records the recordings queued by the last audit (including earlier
+// failed repairs) that are still in the timeshift window. The damaged file is kept with a
+// ".damaged" suffix until the new recording succeeds. It returns the number of recordings repaired.
+func RepairRecordings(ctx context.Context, opts Options) (int, error) {
+	opts = opts.withDefaults()
+	if opts.History == nil {
+		return 0, fmt.Errorf("no history configured")
+	}
+	statePath := opts.History.AuditStatePath()
+	state, err := LoadAuditState(statePath)
+	if err != nil || state == nil {
+		return 0, err
+	}
+
+	now := opts.Clock.Now()
+	jobOpts := opts.jobOptions()
+	repaired := 0
+	var errs []error
+	for i := range state.Issues {
+		issue := &state.Issues[i]
+		if issue.Repair != RepairQueued && issue.Repair != RepairFailed {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if now.Sub(issue.StartTime) >= TimeshiftWindow {
+			issue.Repair = RepairExpired
+			continue
+		}
+
+		if err := repairRecording(ctx, opts, jobOpts, *issue); err != nil {
+			issue.Repair, issue.RepairError = RepairFailed, err.Error()
+			opts.Logger.Printf("WARNING: Failed to repair %s: %v", issue.Path, err)
+			errs = append(errs, fmt.Errorf("%s: %w", issue.Path, err))
+		} else {
+			issue.Repair, issue.RepairError = RepairDone, ""
+			opts.Logger.Printf("INFO: Repaired %s", issue.Path)
+			repaired++
+		}
+		if err := state.save(statePath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := state.save(statePath); err != nil {
+		errs = append(errs, err)
+	}
+	return repaired, errors.Join(errs...)
+}
+
+// repairRecording re-records the broadcast of issue's history record.
+func repairRecording(ctx context.Context, opts Options, jobOpts JobOptions, issue AuditIssue) error {
+	rec, err := opts.History.Find(issue.RecordID)
+	if err != nil {
+		return err
+	}
+
+	damaged := rec.OutputPath + ".damaged"
+	if err := os.Rename(rec.OutputPath, damaged); err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to move damaged recording aside: %w", err)
+		}
+		damaged = ""
+	}
+
+	start := rec.StartTime.In(JST)
+	entry := ScheduleEntry{
+		ProgramName: rec.ProgramName,
+		DayOfWeek:   japaneseDayOfWeek(start.Weekday()),
+		StartTime:   start.Format("150405"),
+		StationID:   rec.StationID,
+		Provider:    rec.Provider,
+	}
+	client, err := opts.newClient(ctx, entry)
+	if err == nil {
+		jobOpts.Rerun = rec.Rerun
+		_, err = ExecuteJob(ctx, client, entry, start, jobOpts)
+	} else {
+		err = fmt.Errorf("failed to create client: %w", err)
+	}
+
+	if damaged == "" {
+		return err
+	}
+	if err != nil {
+		if _, statErr := os.Stat(rec.OutputPath); os.IsNotExist(statErr) {
+			os.Rename(damaged, rec.OutputPath)
+		}
+		return err
+	}
+	if err := os.Remove(damaged); err != nil {
+		opts.Logger.Printf("WARNING: Failed to remove damaged recording: %v", err)
+	}
+	return nil
+}
+
+// FormatAuditReport renders the result of an audit as a notification subject and body.
+func FormatAuditReport(state *AuditState) (string, string) {
+	subject := fmt.Sprintf("Library audit: %d recordings, %d issue(s)", state.Total, len(state.Issues))
+	if !state.Finished() {
+		subject = fmt.Sprintf("Library audit (in progress, %d/%d): %d issue(s)", len(state.Checked), state.Total, len(state.Issues))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Started %s, %s hashed.\n", state.StartedAt.Format("2006-01-02 15:04"), formatBytes(state.Bytes))
+	if state.Unhashed > 0 {
+		fmt.Fprintf(&b, "%d recording(s) predate stored hashes and were only checked for presence.\n", state.Unhashed)
+	}
+	for _, issue := range state.Issues {
+		fmt.Fprintf(&b, "%s: %s (%s) — %s", issue.Kind, issue.Path, issue.Detail, issue.Repair)
+		if issue.RepairError != "" {
+			fmt.Fprintf(&b, ": %s", issue.RepairError)
+		}
+		b.WriteString("\n")
+	}
+	return subject, strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAuditFixture writes content to dir/name and returns a successful history record for it.
+func writeAuditFixture(t *testing.T, dir, id, name, content string, start time.Time) HistoryRecord {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	return HistoryRecord{
+		ID: id, ProgramName: "Program", StationID: id, StartTime: start, Status: StatusSuccess,
+		OutputPath: path, Size: int64(len(content)), SHA256: hex.EncodeToString(sum[:]),
+	}
+}
+
+func TestAuditLibrary(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)
+	recent := now.Add(-24 * time.Hour)
+	old := now.Add(-30 * 24 * time.Hour)
+	history := OpenHistory(filepath.Join(dir, "history.jsonl"))
+
+	good := writeAuditFixture(t, dir, "good", "good.aac", "intact recording", recent)
+	missing := writeAuditFixture(t, dir, "missing", "missing.aac", "gone", recent)
+	truncated := writeAuditFixture(t, dir, "trunc", "truncated.aac", "full length recording", old)
+	rotten := writeAuditFixture(t, dir, "rot", "rotten.aac", "original contents", recent)
+	unhashed := writeAuditFixture(t, dir, "unhashed", "unhashed.aac", "legacy", old)
+	unhashed.Size, unhashed.SHA256 = 0, ""
+	uploaded := writeAuditFixture(t, dir, "uploaded", "uploaded.aac", "uploaded", recent)
+	uploaded.LocalRemoved = true
+	failed := HistoryRecord{ID: "failed", Status: StatusFailed, OutputPath: filepath.Join(dir, "failed.aac")}
+
+	os.Remove(missing.OutputPath)
+	os.Remove(uploaded.OutputPath)
+	os.WriteFile(truncated.OutputPath, []byte("full length"), 0644)
+	os.WriteFile(rotten.OutputPath, []byte("originaL contents"), 0644)
+
+	for _, rec := range []HistoryRecord{good, missing, truncated, rotten, unhashed, uploaded, failed} {
+		if err := history.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state, err := AuditLibrary(context.Background(), AuditOptions{History: history, Logger: log.New(&bytes.Buffer{}, "", 0), Clock: &fakeClock{now: now}})
+	if err != nil {
+		t.Fatalf("AuditLibrary: %v", err)
+	}
+	if !state.Finished() || state.Total != 5 || state.Unhashed != 1 {
+		t.Errorf("unexpected state: %+v", state)
+	}
+
+	want := map[string][2]string{
+		"missing": {AuditMissing, RepairQueued},
+		"trunc":   {AuditTruncated, RepairExpired},
+		"rot":     {AuditCorrupt, RepairQueued},
+	}
+	if len(state.Issues) != len(want) {
+		t.Fatalf("expected %d issues, got %+v", len(want), state.Issues)
+	}
+	for _, issue := range state.Issues {
+		if w := want[issue.RecordID]; issue.Kind != w[0] || issue.Repair != w[1] {
+			t.Errorf("%s: expected %s/%s, got %s/%s", issue.RecordID, w[0], w[1], issue.Kind, issue.Repair)
+		}
+	}
+
+	saved, err := LoadAuditState(history.AuditStatePath())
+	if err != nil || saved == nil || len(saved.Issues) != 3 {
+		t.Errorf("expected the result to be saved, got %+v (%v)", saved, err)
+	}
+}
+
+func TestAuditLibraryResumes(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)
+	history := OpenHistory(filepath.Join(dir, "history.jsonl"))
+
+	first := writeAuditFixture(t, dir, "first", "first.aac", "first", now)
+	second := writeAuditFixture(t, dir, "second", "second.aac", "second", now)
+	os.Remove(first.OutputPath) // Would be reported if it were checked again.
+	for _, rec := range []HistoryRecord{first, second} {
+		if err := history.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	interrupted := &AuditState{StartedAt: now.Add(-time.Hour), Checked: []string{"first"}, Bytes: 5}
+	if err := interrupted.save(history.AuditStatePath()); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := AuditOptions{History: history, Logger: log.New(&bytes.Buffer{}, "", 0), Clock: &fakeClock{now: now}}
+	state, err := AuditLibrary(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.StartedAt.Equal(interrupted.StartedAt) || len(state.Checked) != 2 || len(state.Issues) != 0 || state.Bytes != 11 {
+		t.Errorf("expected the interrupted audit to be resumed, got %+v", state)
+	}
+
+	opts.Restart = true
+	if state, err = AuditLibrary(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Issues) != 1 {
+		t.Errorf("expected a restarted audit to check every recording, got %+v", state)
+	}
+}
+
+func TestAuditLibraryCancelled(t *testing.T) {
+	dir := t.TempDir()
+	history := OpenHistory(filepath.Join(dir, "history.jsonl"))
+	rec := writeAuditFixture(t, dir, "a", "a.aac", "contents", time.Now())
+	if err := history.Append(rec); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	state, err := AuditLibrary(ctx, AuditOptions{History: history, Logger: log.New(&bytes.Buffer{}, "", 0)})
+	if err != context.Canceled || state == nil || state.Finished() {
+		t.Fatalf("expected an unfinished state and context.Canceled, got %+v, %v", state, err)
+	}
+	if saved, _ := LoadAuditState(history.AuditStatePath()); saved == nil || saved.Finished() {
+		t.Errorf("expected the progress to be saved for resuming, got %+v", saved)
+	}
+}
+
+func TestAuditDue(t *testing.T) {
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)
+	tests := []struct {
+		name  string
+		cfg   AuditConfig
+		state *AuditState
+		want  bool
+	}{
+		{"disabled", AuditConfig{}, nil, false},
+		{"never audited", AuditConfig{IntervalDays: 7}, nil, true},
+		{"interrupted", AuditConfig{IntervalDays: 7}, &AuditState{StartedAt: now}, true},
+		{"recent", AuditConfig{IntervalDays: 7}, &AuditState{FinishedAt: now.AddDate(0, 0, -6)}, false},
+		{"stale", AuditConfig{IntervalDays: 7}, &AuditState{FinishedAt: now.AddDate(0, 0, -7)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+			if tt.state != nil {
+				if err := tt.state.save(history.AuditStatePath()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			due, err := auditDue(tt.cfg, history, now)
+			if err != nil || due != tt.want {
+				t.Errorf("auditDue = %v, %v; want %v", due, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepairRecordings(t *testing.T) {
+	dir := t.TempDir()
+	outputDir := filepath.Join(dir, "output")
+	os.MkdirAll(outputDir, 0755)
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)
+	start := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	history := OpenHistory(filepath.Join(dir, "history.jsonl"))
+
+	rec := writeAuditFixture(t, outputDir, "rot", "20260112100000-rot-Program.aac", "bit-rotten", start)
+	rec.SHA256 = strings.Repeat("0", 64)
+	if err := history.Append(rec); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{
+		OutputDir:   outputDir,
+		History:     history,
+		Logger:      log.New(&bytes.Buffer{}, "", 0),
+		Clock:       &fakeClock{now: now},
+		NewProvider: func(ctx context.Context) (Provider, error) { return &MockRadikoClient{}, nil },
+		FetchGuide:  func(ctx context.Context, stationID string) ([]byte, error) { return nil, os.ErrNotExist },
+	}
+	state, err := AuditLibrary(context.Background(), AuditOptions{History: history, Logger: opts.Logger, Clock: opts.Clock})
+	if err != nil || len(state.Issues) != 1 || state.Issues[0].Repair != RepairQueued {
+		t.Fatalf("expected one queued repair, got %+v (%v)", state, err)
+	}
+
+	repaired, err := RepairRecordings(context.Background(), opts)
+	if err != nil || repaired != 1 {
+		t.Fatalf("RepairRecordings = %d, %v", repaired, err)
+	}
+	data, err := os.ReadFile(rec.OutputPath)
+	if err != nil || string(data) != dummyAACChunk+dummyAACChunk {
+		t.Errorf("expected the recording to be replaced, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(rec.OutputPath + ".damaged"); !os.IsNotExist(err) {
+		t.Errorf("expected the damaged file to be removed, got %v", err)
+	}
+	saved, _ := LoadAuditState(history.AuditStatePath())
+	if saved == nil || saved.Issues[0].Repair != RepairDone {
+		t.Errorf("expected the issue to be marked repaired, got %+v", saved)
+	}
+
+	// The repaired recording passes the next audit.
+	if state, err = AuditLibrary(context.Background(), AuditOptions{History: history, Logger: opts.Logger, Clock: opts.Clock}); err != nil || len(state.Issues) != 0 {
+		t.Errorf("expected a clean audit after the repair, got %+v (%v)", state, err)
+	}
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ParseScheduleDate returns the start of the day s, a YYYY-MM-DD date, in loc, the time zone of
+// the schedule (nil is Japan time).
+func ParseScheduleDate(s string, loc *time.Location) (time.Time, error) {
+	day, err := time.ParseInLocation("2006-01-02", s, scheduleLocation(loc))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date '%s': %w", s, err)
+	}
+	return day, nil
+}
+
+// BroadcastsBetween returns the broadcasts of entry starting at or after from and before to,
+// oldest first, leaving out those the entry skips (SkipDates, SkipHolidays). The entry is read in loc.
+func BroadcastsBetween(entry ScheduleEntry, from, to time.Time, loc *time.Location) ([]time.Time, error) {
+	var starts []time.Time
+	start, err := CalculateRecentPastRunTime(entry, to.Add(-time.Second), loc)
+	for err == nil && !start.Before(from) {
+		if _, skip := skipReason(entry, start, loc); !skip {
+			starts = append([]time.Time{start}, starts...)
+		}
+		if entry.OneShot() {
+			break
+		}
+		start, err = CalculateRecentPastRunTime(entry, start.Add(-time.Second), loc)
+	}
+	if err != nil && !errors.Is(err, ErrNotAired) {
+		return nil, err
+	}
+	return starts, nil
+}
+
+// Backfill records every broadcast of entry from from to to that has aired and is still in the
+// timeshift window, oldest first, as a run would record the most recent one. Broadcasts recorded
+// before are skipped unless opts.Overwrite is set. The outcome of each is added to opts.Summary.
+func Backfill(ctx context.Context, opts Options, entry ScheduleEntry, from, to time.Time) error {
+	opts = opts.withDefaults()
+	now := opts.Clock.Now().In(JST)
+	if to.After(now) {
+		to = now
+	}
+	starts, err := BroadcastsBetween(entry, from, to, opts.Location)
+	if err != nil {
+		return fmt.Errorf("%s: %w", entry.ProgramName, err)
+	}
+	if len(starts) == 0 {
+		opts.Logger.Printf("INFO: '%s' has no broadcasts from %s to %s.", entry.ProgramName, from.Format("2006-01-02 15:04"), to.Format("2006-01-02 15:04"))
+		return nil
+	}
+	opts.Logger.Printf("INFO: Backfilling %d broadcasts of '%s'.", len(starts), entry.ProgramName)
+
+	jobOpts := opts.jobOptions()
+	session := newSession(opts, jobOpts)
+	jobOpts.Authenticated = true
+	var errs []error
+	for _, start := range starts {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		result := JobResult{ProgramName: entry.ProgramName, StationID: entry.StationID, Start: start}
+		if _, err := checkTimeshift(start, now); err != nil {
+			opts.Logger.Printf("WARNING: Skipping the broadcast of '%s' at %s: %v.", entry.ProgramName, start.Format("2006-01-02 15:04"), err)
+			result.Outcome, result.Err = OutcomeSkipped, err
+			opts.Summary.add(result)
+			continue
+		}
+		client, err := session.client(ctx, entry)
+		var rec RecordingResult
+		if err == nil {
+			rec, err = executeJobWithRerun(ctx, client, entry, start, now, jobOpts)
+		}
+		switch {
+		case err != nil:
+			opts.Logger.Printf(opts.Output.Msg("Error executing job for '%s' at %s: %v"), entry.ProgramName, start.Format("2006-01-02 15:04"), err)
+			errs = append(errs, fmt.Errorf("%s at %s: %w", entry.ProgramName, start.Format("2006-01-02 15:04"), err))
+			result.Outcome, result.Err = OutcomeFailed, err
+		case rec.Skipped:
+			result.Outcome, result.OutputPath = OutcomeSkipped, rec.OutputPath
+		default:
+			result.Outcome, result.OutputPath, result.Size = OutcomeSucceeded, rec.OutputPath, rec.Size
+		}
+		opts.Summary.add(result)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBroadcastsBetween(t *testing.T) {
+	day := func(d, hour int) time.Time { return time.Date(2026, time.January, d, hour, 0, 0, 0, JST) }
+	tests := []struct {
+		name     string
+		entry    ScheduleEntry
+		from, to time.Time
+		want     []time.Time
+	}{
+		{
+			name:  "weekly",
+			entry: ScheduleEntry{DayOfWeek: "月", StartTime: "100000"},
+			from:  day(1, 0), to: day(20, 0),
+			want: []time.Time{day(5, 10), day(12, 10), day(19, 10)},
+		},
+		{
+			name:  "range ends at a broadcast",
+			entry: ScheduleEntry{DayOfWeek: "月", StartTime: "100000"},
+			from:  day(5, 10), to: day(19, 10),
+			want: []time.Time{day(5, 10), day(12, 10)},
+		},
+		{
+			name:  "skipped dates",
+			entry: ScheduleEntry{DayOfWeek: "月", StartTime: "100000", SkipDates: []string{"2026-01-12"}},
+			from:  day(1, 0), to: day(20, 0),
+			want: []time.Time{day(5, 10), day(19, 10)},
+		},
+		{
+			name:  "every two weeks",
+			entry: ScheduleEntry{DayOfWeek: "月", StartTime: "100000", Date: "2026-01-12", EveryWeeks: 2},
+			from:  day(1, 0), to: day(31, 0),
+			want: []time.Time{day(12, 10), day(26, 10)},
+		},
+		{
+			name:  "one-shot",
+			entry: ScheduleEntry{StartTime: "100000", Date: "2026-01-14"},
+			from:  day(1, 0), to: day(31, 0),
+			want: []time.Time{day(14, 10)},
+		},
+		{
+			name:  "one-shot outside the range",
+			entry: ScheduleEntry{StartTime: "100000", Date: "2026-01-14"},
+			from:  day(15, 0), to: day(31, 0),
+		},
+		{
+			name:  "none",
+			entry: ScheduleEntry{DayOfWeek: "月", StartTime: "100000"},
+			from:  day(13, 0), to: day(18, 0),
+		},
+	}
+	for _, tt := range tests {
+		got, err := BroadcastsBetween(tt.entry, tt.from, tt.to, nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !slices.EqualFunc(got, tt.want, time.Time.Equal) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	now := time.Date(2026, time.January, 18, 12, 0, 0, 0, JST) // Sunday
+	summary := &RunSummary{}
+	opts := Options{
+		OutputDir:   t.TempDir(),
+		Logger:      log.New(&bytes.Buffer{}, "", 0),
+		Clock:       &fakeClock{now: now},
+		Summary:     summary,
+		NewProvider: func(ctx context.Context) (Provider, error) { return &MockRadikoClient{}, nil },
+	}
+	entry := ScheduleEntry{ProgramName: "Weekly", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}
+
+	// The broadcast of the 5th has left the timeshift window; those of the 19th and 26th have not aired.
+	if err := Backfill(context.Background(), opts, entry, time.Date(2026, time.January, 1, 0, 0, 0, 0, JST), time.Date(2026, time.February, 1, 0, 0, 0, 0, JST)); err != nil {
+		t.Fatalf("Backfill failed: %v", err)
+	}
+	var got []string
+	for _, r := range summary.Results() {
+		got = append(got, r.Start.Format("01-02")+" "+r.Outcome)
+	}
+	want := []string{"01-05 " + OutcomeSkipped, "01-12 " + OutcomeSucceeded}
+	if !slices.Equal(got, want) {
+		t.Errorf("results = %v, want %v", got, want)
+	}
+}
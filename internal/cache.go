@@ -0,0 +1,257 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CacheConfig sets the size cap of each cache in megabytes. A cap of 0 disables that cache.
+type CacheConfig struct {
+	GuideMB   int `json:"guide_mb"`
+	ChunkMB   int `json:"chunk_mb"`
+	ArtworkMB int `json:"artwork_mb"`
+
+	// PersistTokens keeps auth tokens in TokenCacheFile, so they are reused across runs
+	// rather than only by the jobs of one run.
+	PersistTokens bool `json:"persist_tokens"`
+}
+
+// TokenCacheFile is the name of the auth token cache in the cache directory.
+const TokenCacheFile = "tokens.json"
+
+// DefaultCacheConfig returns the cache caps used when config.json does not set them.
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{GuideMB: 8, ChunkMB: 256, ArtworkMB: 32}
+}
+
+// Cache is a directory of cached blobs with a total size cap.
+// When a Put pushes the cache over its cap, the least recently used entries are evicted first.
+// A nil *Cache is valid and behaves as a disabled cache.
+type Cache struct {
+	name     string
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex
+}
+
+// CacheStats describes the current contents of a cache.
+type CacheStats struct {
+	Name     string
+	Dir      string
+	Entries  int
+	Bytes    int64
+	MaxBytes int64
+}
+
+// NewCache returns a cache stored in dir and capped at maxBytes. The directory is created on first Put.
+func NewCache(name, dir string, maxBytes int64) *Cache {
+	return &Cache{name: name, dir: dir, maxBytes: maxBytes}
+}
+
+// Name returns the name of the cache.
+func (c *Cache) Name() string { return c.name }
+
+// enabled reports whether the cache stores anything at all.
+func (c *Cache) enabled() bool { return c != nil && c.maxBytes > 0 }
+
+// path maps a key to its file. Keys are hashed so any string (such as a URL) can be used.
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get returns the cached data for key and marks the entry as recently used.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	if !c.enabled() {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now) // The modification time is the LRU timestamp.
+	return data, true
+}
+
+// Put stores data under key, then evicts least recently used entries until the cache fits its cap.
+func (c *Cache) Put(key string, data []byte) error {
+	if !c.enabled() {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s cache directory '%s': %w", c.name, c.dir, err)
+	}
+	path := c.path(key)
+	tmp, err := os.CreateTemp(c.dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to write %s cache entry: %w", c.name, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s cache entry: %w", c.name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s cache entry: %w", c.name, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s cache entry: %w", c.name, err)
+	}
+	return c.evict()
+}
+
+// cacheEntry is a file in the cache directory.
+type cacheEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// entries lists the files in the cache directory. A missing directory is an empty cache.
+func (c *Cache) entries() ([]cacheEntry, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s cache directory '%s': %w", c.name, c.dir, err)
+	}
+	entries := make([]cacheEntry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		if de.IsDir() {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue // Removed concurrently.
+		}
+		entries = append(entries, cacheEntry{path: filepath.Join(c.dir, de.Name()), size: info.Size(), modTime: info.ModTime()})
+	}
+	return entries, nil
+}
+
+// evict removes the least recently used entries until the cache fits its cap. Callers hold c.mu.
+func (c *Cache) evict() error {
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+	if total <= c.maxBytes {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+	for _, e := range entries {
+		if total <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to evict %s cache entry: %w", c.name, err)
+		}
+		total -= e.size
+	}
+	return nil
+}
+
+// Stats reports the number and total size of the cached entries.
+func (c *Cache) Stats() (CacheStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{Name: c.name, Dir: c.dir, MaxBytes: c.maxBytes}
+	entries, err := c.entries()
+	if err != nil {
+		return stats, err
+	}
+	for _, e := range entries {
+		stats.Entries++
+		stats.Bytes += e.size
+	}
+	return stats, nil
+}
+
+// Clear removes every entry from the cache.
+func (c *Cache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear %s cache: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+// Caches groups the application's caches, each kept in its own subdirectory with its own cap.
+type Caches struct {
+	Guide   *Cache // Program guide XML, one entry per station and day.
+	Chunk   *Cache // Verified AAC chunks, keyed by URL, so re-running a failed job skips finished chunks.
+	Artwork *Cache // Program artwork.
+}
+
+// OpenCaches returns the caches stored under dir with the caps from cfg.
+func OpenCaches(dir string, cfg CacheConfig) *Caches {
+	const mb = 1 << 20
+	return &Caches{
+		Guide:   NewCache("guide", filepath.Join(dir, "guide"), int64(cfg.GuideMB)*mb),
+		Chunk:   NewCache("chunk", filepath.Join(dir, "chunk"), int64(cfg.ChunkMB)*mb),
+		Artwork: NewCache("artwork", filepath.Join(dir, "artwork"), int64(cfg.ArtworkMB)*mb),
+	}
+}
+
+// All returns every cache in a fixed order.
+func (c *Caches) All() []*Cache {
+	return []*Cache{c.Guide, c.Chunk, c.Artwork}
+}
+
+// Find returns the cache with the given name, or nil.
+func (c *Caches) Find(name string) *Cache {
+	for _, cache := range c.All() {
+		if cache.name == name {
+			return cache
+		}
+	}
+	return nil
+}
+
+// CachedGuideFetcher wraps fetch so each station's guide is downloaded at most once per JST day.
+func CachedGuideFetcher(cache *Cache, clock Clock, fetch func(ctx context.Context, stationID string) ([]byte, error)) func(ctx context.Context, stationID string) ([]byte, error) {
+	return func(ctx context.Context, stationID string) ([]byte, error) {
+		key := stationID + "/" + clock.Now().In(JST).Format("20060102")
+		if data, ok := cache.Get(key); ok {
+			return data, nil
+		}
+		data, err := fetch(ctx, stationID)
+		if err != nil {
+			return nil, err
+		}
+		_ = cache.Put(key, data) // A failed cache write only costs a later refetch.
+		return data, nil
+	}
+}
@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewCache("test", t.TempDir(), 25)
+
+	// Give each entry a distinct, increasing LRU timestamp.
+	base := time.Now().Add(-time.Hour)
+	put := func(key string, age int) {
+		t.Helper()
+		if err := cache.Put(key, []byte("0123456789")); err != nil {
+			t.Fatalf("Put(%s) failed: %v", key, err)
+		}
+		ts := base.Add(time.Duration(age) * time.Minute)
+		if err := os.Chtimes(cache.path(key), ts, ts); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+	}
+	put("a", 1)
+	put("b", 2)
+
+	// Reading "a" makes it the most recently used, so "b" is evicted by the next Put.
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	put("c", 100)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("expected %s to be kept", key)
+		}
+	}
+
+	stats, err := cache.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Entries != 2 || stats.Bytes != 20 || stats.MaxBytes != 25 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if stats, _ := cache.Stats(); stats.Entries != 0 {
+		t.Errorf("expected empty cache after Clear, got %+v", stats)
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "disabled")
+	for _, cache := range []*Cache{nil, NewCache("disabled", dir, 0)} {
+		if err := cache.Put("k", []byte("v")); err != nil {
+			t.Errorf("Put on disabled cache failed: %v", err)
+		}
+		if _, ok := cache.Get("k"); ok {
+			t.Error("disabled cache returned an entry")
+		}
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("disabled cache created its directory: %v", err)
+	}
+}
+
+func TestCachedGuideFetcher(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)}
+	caches := OpenCaches(t.TempDir(), DefaultCacheConfig())
+
+	fetches := 0
+	fetch := CachedGuideFetcher(caches.Guide, clock, func(ctx context.Context, stationID string) ([]byte, error) {
+		fetches++
+		return []byte(fmt.Sprintf("%s-%d", stationID, fetches)), nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if data, err := fetch(context.Background(), "LFR"); err != nil || string(data) != "LFR-1" {
+			t.Errorf("fetch = (%q, %v), want LFR-1", data, err)
+		}
+	}
+
+	// The guide is refreshed on the next day.
+	clock.now = clock.now.Add(24 * time.Hour)
+	if data, _ := fetch(context.Background(), "LFR"); string(data) != "LFR-2" {
+		t.Errorf("fetch on next day = %q, want LFR-2", data)
+	}
+	if fetches != 2 {
+		t.Errorf("expected 2 fetches, got %d", fetches)
+	}
+}
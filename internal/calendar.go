@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"sort"
+	"time"
+)
+
+// CalendarSlot is one broadcast in a ScheduleCalendar.
+type CalendarSlot struct {
+	Entry ScheduleEntry
+	Title string // Title from the guide, or the program name when the slot was not found.
+	Start time.Time
+	End   time.Time // From the guide; an hour after Start when the slot was not found.
+}
+
+// CalendarOverlap is a time two slots of a ScheduleCalendar air at once.
+type CalendarOverlap struct {
+	A, B       int // Indices of the slots in ScheduleCalendar.Slots, A < B.
+	Start, End time.Time
+}
+
+// ScheduleCalendar is a week of scheduled broadcasts, see BuildScheduleCalendar.
+type ScheduleCalendar struct {
+	Start    time.Time // Midnight JST of the first day.
+	Slots    []CalendarSlot
+	Overlaps []CalendarOverlap
+}
+
+// BuildScheduleCalendar lays out the resolved entries of BuildWeeklyPreview over the seven days
+// starting at the midnight before now, and finds the broadcasts that overlap. Entries that could
+// not be placed are left out.
+func BuildScheduleCalendar(items []PreviewItem, now time.Time) ScheduleCalendar {
+	now = now.In(JST)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, JST)
+	cal := ScheduleCalendar{Start: start}
+	weekEnd := start.AddDate(0, 0, 7)
+	for _, item := range items {
+		if item.At.IsZero() || !item.At.Before(weekEnd) {
+			continue
+		}
+		slot := CalendarSlot{Entry: item.Entry, Title: item.Title, Start: item.At.In(JST), End: item.End.In(JST)}
+		if slot.Title == "" {
+			slot.Title = item.Entry.ProgramName
+		}
+		if !slot.End.After(slot.Start) {
+			slot.End = slot.Start.Add(icsDefaultDuration)
+		}
+		cal.Slots = append(cal.Slots, slot)
+	}
+	sort.SliceStable(cal.Slots, func(i, j int) bool { return cal.Slots[i].Start.Before(cal.Slots[j].Start) })
+
+	for i, a := range cal.Slots {
+		for j := i + 1; j < len(cal.Slots) && cal.Slots[j].Start.Before(a.End); j++ {
+			b := cal.Slots[j]
+			end := a.End
+			if b.End.Before(end) {
+				end = b.End
+			}
+			cal.Overlaps = append(cal.Overlaps, CalendarOverlap{A: i, B: j, Start: b.Start, End: end})
+		}
+	}
+	return cal
+}
+
+// Cell returns how many slots air during the given hour (0-23) of the given day (0-6), and
+// whether any of them overlap there.
+func (c ScheduleCalendar) Cell(day, hour int) (count int, overlap bool) {
+	from := c.Start.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour)
+	to := from.Add(time.Hour)
+	for _, slot := range c.Slots {
+		if slot.Start.Before(to) && slot.End.After(from) {
+			count++
+		}
+	}
+	for _, o := range c.Overlaps {
+		if o.Start.Before(to) && o.End.After(from) {
+			overlap = true
+			break
+		}
+	}
+	return count, overlap
+}
+
+// DayTotal returns how many slots start on the given day (0-6) and their broadcast time.
+func (c ScheduleCalendar) DayTotal(day int) (count int, duration time.Duration) {
+	from := c.Start.AddDate(0, 0, day)
+	to := from.AddDate(0, 0, 1)
+	for _, slot := range c.Slots {
+		if !slot.Start.Before(from) && slot.Start.Before(to) {
+			count++
+			duration += slot.End.Sub(slot.Start)
+		}
+	}
+	return count, duration
+}
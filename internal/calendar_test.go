@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildScheduleCalendar(t *testing.T) {
+	now := time.Date(2026, time.March, 2, 8, 0, 0, 0, JST) // Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.March, 2+day, hour, minute, 0, 0, JST)
+	}
+	items := []PreviewItem{
+		{Entry: ScheduleEntry{ProgramName: "Talk"}, At: at(0, 10, 30), End: at(0, 11, 30), Title: "Talk Show"},
+		{Entry: ScheduleEntry{ProgramName: "Morning"}, At: at(0, 10, 0), End: at(0, 11, 0)},
+		// Not in the guide: an hour long.
+		{Entry: ScheduleEntry{ProgramName: "Late"}, At: at(2, 23, 30)},
+		{Entry: ScheduleEntry{ProgramName: "Unresolved"}, Err: ErrNotAired},
+		{Entry: ScheduleEntry{ProgramName: "Next week"}, At: at(7, 10, 0), End: at(7, 11, 0)},
+	}
+
+	cal := BuildScheduleCalendar(items, now)
+	if !cal.Start.Equal(at(0, 0, 0)) {
+		t.Errorf("Start = %v", cal.Start)
+	}
+	var names []string
+	for _, slot := range cal.Slots {
+		names = append(names, slot.Title)
+	}
+	if len(names) != 3 || names[0] != "Morning" || names[1] != "Talk Show" || names[2] != "Late" {
+		t.Fatalf("slots = %v", names)
+	}
+	if !cal.Slots[2].End.Equal(at(3, 0, 30)) {
+		t.Errorf("unguided slot ends at %v", cal.Slots[2].End)
+	}
+	if len(cal.Overlaps) != 1 || cal.Overlaps[0] != (CalendarOverlap{A: 0, B: 1, Start: at(0, 10, 30), End: at(0, 11, 0)}) {
+		t.Errorf("overlaps = %+v", cal.Overlaps)
+	}
+
+	cells := []struct {
+		day, hour int
+		count     int
+		overlap   bool
+	}{
+		{0, 9, 0, false},
+		{0, 10, 2, true},
+		{0, 11, 1, false},
+		{2, 23, 1, false},
+		{3, 0, 1, false},
+	}
+	for _, c := range cells {
+		if count, overlap := cal.Cell(c.day, c.hour); count != c.count || overlap != c.overlap {
+			t.Errorf("Cell(%d, %d) = %d, %v, want %d, %v", c.day, c.hour, count, overlap, c.count, c.overlap)
+		}
+	}
+	if count, duration := cal.DayTotal(0); count != 2 || duration != 2*time.Hour {
+		t.Errorf("DayTotal(0) = %d, %v", count, duration)
+	}
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chaptersExt is the extension of the chapter file written next to a recording (JobOptions.Chapters).
+// It is in ffmpeg's FFMETADATA format, so a post_command can mux the chapters into an M4A:
+//
+//	ffmpeg -i "$RADIKO_FILE" -i "$RADIKO_CHAPTERS" -map_metadata 1 -c copy out.m4a
+const chaptersExt = ".ffmeta"
+
+// Chapter is a segment of a recording, as an offset from its start.
+type Chapter struct {
+	Start time.Duration
+	End   time.Duration
+	Title string
+}
+
+// chapterMark is where a chapter starts in the broadcast, found in the guide.
+type chapterMark struct {
+	at    time.Time
+	title string
+}
+
+// cornerPattern matches a line of a program description announcing a corner by its time,
+// e.g. "15:10〜 リスナーのお便り" or "・5:30頃 交通情報". Hours past midnight may be written
+// as 24-29, as radiko does.
+var cornerPattern = regexp.MustCompile(`^[\s・●○■□◆◇▼▽★☆※-]*(\d{1,2})[:：](\d{2})\s*(?:頃|ごろ)?\s*[〜～~ー\-]?\s*(\S.*)$`)
+
+// htmlTagPattern matches the markup in guide descriptions.
+var htmlTagPattern = regexp.MustCompile(`(?i)<br\s*/?>|</p>|<[^>]*>`)
+
+// guideChapters returns the chapters of a recording of stationID from start to end: one per
+// program of the guide airing in the window, split further at the corners the programs' descriptions
+// announce with a time. It returns nil when the guide yields fewer than two chapters.
+func guideChapters(programData []byte, stationID string, start, end time.Time) ([]Chapter, error) {
+	var radiko Radiko
+	if err := xml.Unmarshal(programData, &radiko); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal program guide: %w", err)
+	}
+
+	var marks []chapterMark
+	for _, station := range radiko.Stations.Station {
+		if station.ID != "" && station.ID != stationID {
+			continue
+		}
+		for _, prog := range station.Progs.Prog {
+			ft, err := time.ParseInLocation("20060102150405", prog.Ft, JST)
+			if err != nil {
+				continue
+			}
+			to, err := time.ParseInLocation("20060102150405", prog.To, JST)
+			if err != nil || !to.After(start) || !ft.Before(end) {
+				continue
+			}
+			title := prog.Title
+			if prog.SubTitle != "" {
+				title += " " + prog.SubTitle
+			}
+			at := ft
+			if at.Before(start) {
+				at = start
+			}
+			marks = append(marks, chapterMark{at, title})
+			for _, c := range programCorners(prog, ft, to) {
+				if !c.at.Before(start) && c.at.Before(end) {
+					marks = append(marks, c)
+				}
+			}
+		}
+	}
+	sort.SliceStable(marks, func(i, j int) bool { return marks[i].at.Before(marks[j].at) })
+
+	var chapters []Chapter
+	for i, m := range marks {
+		if i > 0 && m.at.Equal(marks[i-1].at) {
+			continue // A corner starting with its program.
+		}
+		offset := m.at.Sub(start)
+		if n := len(chapters); n > 0 {
+			chapters[n-1].End = offset
+		}
+		chapters = append(chapters, Chapter{Start: offset, Title: m.title})
+	}
+	if len(chapters) < 2 {
+		return nil, nil
+	}
+	chapters[len(chapters)-1].End = end.Sub(start)
+	return chapters, nil
+}
+
+// programCorners returns the corners announced with a time in the description of prog,
+// which airs from ft to to.
+func programCorners(prog Prog, ft, to time.Time) []chapterMark {
+	var corners []chapterMark
+	text := htmlTagPattern.ReplaceAllString(prog.Info+"\n"+prog.Desc, "\n")
+	for _, line := range strings.Split(html.UnescapeString(text), "\n") {
+		m := cornerPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		hour, _ := strconv.Atoi(m[1])
+		minute, _ := strconv.Atoi(m[2])
+		if hour > 29 || minute > 59 {
+			continue
+		}
+		hour %= 24
+		// The time of day on the broadcast's date, or the next one for programs past midnight.
+		day := time.Date(ft.Year(), ft.Month(), ft.Day(), 0, 0, 0, 0, JST)
+		at := day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+		if at.Before(ft) {
+			at = at.Add(24 * time.Hour)
+		}
+		if at.Before(to) {
+			corners = append(corners, chapterMark{at, strings.TrimSpace(m[3])})
+		}
+	}
+	return corners
+}
+
+// writeChapters writes the chapters of the broadcast of stationID from start to end next to
+// the recording at path. It returns the path of the chapter file, or "" if the guide has no chapters.
+func writeChapters(programData []byte, path, stationID, title string, start, end time.Time) (string, error) {
+	chapters, err := guideChapters(programData, stationID, start, end)
+	if err != nil || chapters == nil {
+		return "", err
+	}
+	chaptersPath := strings.TrimSuffix(path, filepath.Ext(path)) + chaptersExt
+	if err := writeFileAtomic(chaptersPath, formatFFMetadata(title, chapters), 0644); err != nil {
+		return "", fmt.Errorf("failed to write chapters '%s': %w", chaptersPath, err)
+	}
+	return chaptersPath, nil
+}
+
+// formatFFMetadata renders chapters in ffmpeg's FFMETADATA format, with the recording's title.
+func formatFFMetadata(title string, chapters []Chapter) []byte {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	fmt.Fprintf(&b, "title=%s\n", escapeFFMetadata(title))
+	for _, c := range chapters {
+		fmt.Fprintf(&b, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			c.Start.Milliseconds(), c.End.Milliseconds(), escapeFFMetadata(c.Title))
+	}
+	return []byte(b.String())
+}
+
+// ffMetadataEscaper escapes the characters that are special in FFMETADATA values.
+var ffMetadataEscaper = strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
+
+func escapeFFMetadata(s string) string {
+	return ffMetadataEscaper.Replace(s)
+}
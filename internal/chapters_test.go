@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+const chaptersGuide = `<?xml version="1.0" encoding="UTF-8"?>
+<radiko>
+  <stations>
+    <station id="TBS">
+      <name>TBSラジオ</name>
+      <progs>
+        <prog ft="20260112180000" to="20260112210000" ftl="1800" tol="2100" dur="10800">
+          <title>アフター６ジャンクション</title>
+          <info>&lt;p&gt;18:00〜 オープニング&lt;br /&gt;・19:30頃 特集&lt;br&gt;20:45～ リスナーのお便り&lt;/p&gt;</info>
+          <desc>22:00 never aired</desc>
+        </prog>
+        <prog ft="20260112210000" to="20260112220000" ftl="2100" tol="2200" dur="3600">
+          <title>ニュース</title>
+          <sub_title>夜の部</sub_title>
+        </prog>
+        <prog ft="20260113010000" to="20260113030000" ftl="2500" tol="2700" dur="7200">
+          <title>JUNK</title>
+          <info>25:30〜 ふつおた</info>
+        </prog>
+      </progs>
+    </station>
+  </stations>
+</radiko>`
+
+func TestGuideChapters(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.January, day, hour, minute, 0, 0, JST)
+	}
+	tests := []struct {
+		name       string
+		start, end time.Time
+		expected   []Chapter
+	}{
+		{
+			name:  "corners",
+			start: at(12, 18, 0), end: at(12, 21, 0),
+			expected: []Chapter{
+				{0, 90 * time.Minute, "アフター６ジャンクション"},
+				{90 * time.Minute, 165 * time.Minute, "特集"},
+				{165 * time.Minute, 180 * time.Minute, "リスナーのお便り"},
+			},
+		},
+		{
+			name:  "window across programs",
+			start: at(12, 20, 0), end: at(12, 21, 30),
+			expected: []Chapter{
+				{0, 45 * time.Minute, "アフター６ジャンクション"},
+				{45 * time.Minute, 60 * time.Minute, "リスナーのお便り"},
+				{60 * time.Minute, 90 * time.Minute, "ニュース 夜の部"},
+			},
+		},
+		{
+			name:  "past midnight",
+			start: at(13, 1, 0), end: at(13, 3, 0),
+			expected: []Chapter{
+				{0, 30 * time.Minute, "JUNK"},
+				{30 * time.Minute, 120 * time.Minute, "ふつおた"},
+			},
+		},
+		{
+			name:  "single program",
+			start: at(12, 21, 0), end: at(12, 22, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chapters, err := guideChapters([]byte(chaptersGuide), "TBS", tt.start, tt.end)
+			if err != nil {
+				t.Fatalf("guideChapters failed: %v", err)
+			}
+			if !reflect.DeepEqual(chapters, tt.expected) {
+				t.Errorf("guideChapters = %+v, want %+v", chapters, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatFFMetadata(t *testing.T) {
+	got := string(formatFFMetadata("A=B; #1", []Chapter{{0, 90 * time.Second, "Intro"}, {90 * time.Second, time.Hour, `C:\D`}}))
+	want := ";FFMETADATA1\ntitle=A\\=B\\; \\#1\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=90000\ntitle=Intro\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=90000\nEND=3600000\ntitle=C:\\\\D\n"
+	if got != want {
+		t.Errorf("formatFFMetadata =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExecuteJobChapters(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX sh syntax")
+	}
+	outputDir := t.TempDir()
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		Logger:     log.New(io.Discard, "", 0),
+		Chapters:   true,
+		FetchGuide: func(context.Context, string) ([]byte, error) { return []byte(chaptersGuide), nil },
+
+		DisableProgressBar: true,
+	}
+	entry := ScheduleEntry{ProgramName: "After 6", DayOfWeek: "月", StartTime: "180000", StationID: "TBS"}
+	pastTime := time.Date(2026, time.January, 12, 18, 0, 0, 0, JST)
+
+	envFile := filepath.Join(t.TempDir(), "env")
+	opts.PostCommand = fmt.Sprintf(`printf %%s "$RADIKO_CHAPTERS" > %q`, envFile)
+	if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+
+	path := filepath.Join(outputDir, "20260112180000-TBS-アフター６ジャンクション"+chaptersExt)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected a chapter file: %v", err)
+	}
+	if want := string(formatFFMetadata("アフター６ジャンクション", []Chapter{
+		{0, 90 * time.Minute, "アフター６ジャンクション"},
+		{90 * time.Minute, 165 * time.Minute, "特集"},
+		{165 * time.Minute, 180 * time.Minute, "リスナーのお便り"},
+	})); string(data) != want {
+		t.Errorf("unexpected chapters:\n%s", data)
+	}
+	if env, _ := os.ReadFile(envFile); string(env) != path {
+		t.Errorf("expected RADIKO_CHAPTERS=%s, got %q", path, env)
+	}
+}
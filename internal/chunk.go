@@ -0,0 +1,256 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxChunkAttempts is how many times a chunk is downloaded before it is reported as unrecoverable.
+const maxChunkAttempts = 3
+
+// ChunkFailure describes a chunk that could not be recovered.
+type ChunkFailure struct {
+	Index int
+	URL   string
+	Err   error
+}
+
+// ChunkDownloadError reports a chunk whose download failed after all attempts. It is an ErrChunkDownload.
+type ChunkDownloadError struct {
+	Index int
+	URL   string
+	Err   error
+}
+
+func (e *ChunkDownloadError) Error() string {
+	return fmt.Sprintf("failed to download chunk %d (%s): %v", e.Index, e.URL, e.Err)
+}
+
+func (e *ChunkDownloadError) Unwrap() error { return e.Err }
+
+func (e *ChunkDownloadError) Is(target error) bool { return target == ErrChunkDownload }
+
+// ChunkIntegrityError reports every chunk that still failed verification after all attempts.
+// It is an ErrChunkDownload.
+type ChunkIntegrityError struct {
+	Failures []ChunkFailure
+}
+
+func (e *ChunkIntegrityError) Is(target error) bool { return target == ErrChunkDownload }
+
+// Error lists the failures by chunk index. It sorts a copy, so e is never modified and the
+// error can be formatted from several goroutines.
+func (e *ChunkIntegrityError) Error() string {
+	failures := slices.Clone(e.Failures)
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Index < failures[j].Index })
+	parts := make([]string, len(failures))
+	for i, f := range failures {
+		parts[i] = fmt.Sprintf("chunk %d (%s): %v", f.Index, f.URL, f.Err)
+	}
+	return fmt.Sprintf("%d chunk(s) unrecoverable after %d attempts: %s", len(e.Failures), maxChunkAttempts, strings.Join(parts, "; "))
+}
+
+// verifyChunkFile checks the downloaded chunk at filePath, see verifyChunk.
+func verifyChunkFile(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open chunk: %w", err)
+	}
+	defer file.Close()
+	return verifyChunk(file)
+}
+
+// verifyChunk checks that a downloaded chunk is non-empty and starts with an ADTS frame.
+// HLS packed audio segments from radiko begin with an ID3v2 timestamp tag, which is skipped.
+func verifyChunk(file io.ReadSeeker) error {
+	header := make([]byte, 10)
+	n, err := io.ReadFull(file, header)
+	if n == 0 {
+		return fmt.Errorf("chunk is empty")
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("failed to read chunk: %w", err)
+	}
+
+	if n >= 10 && string(header[:3]) == "ID3" {
+		if _, err := file.Seek(id3TagSize(header), io.SeekStart); err != nil {
+			return fmt.Errorf("failed to skip ID3 tag: %w", err)
+		}
+		n, err = io.ReadFull(file, header[:2])
+		if err != nil {
+			return fmt.Errorf("no audio data after ID3 tag")
+		}
+	} else if n < 2 {
+		return fmt.Errorf("chunk too short (%d bytes)", n)
+	}
+
+	if !isADTSSync(header[:2]) {
+		return fmt.Errorf("missing ADTS sync word (got % x)", header[:2])
+	}
+	return nil
+}
+
+// id3TagSize returns the length of the ID3v2 tag whose 10-byte header starts header. Its size is
+// a 28-bit "syncsafe" integer; a footer adds another 10 bytes.
+func id3TagSize(header []byte) int64 {
+	size := 10 + (int64(header[6]&0x7f)<<21 | int64(header[7]&0x7f)<<14 | int64(header[8]&0x7f)<<7 | int64(header[9]&0x7f))
+	if header[5]&0x10 != 0 {
+		size += 10
+	}
+	return size
+}
+
+// isADTSSync reports whether b starts with the 12-bit ADTS sync word followed by layer 00.
+func isADTSSync(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0xFF && b[1]&0xF6 == 0xF0
+}
+
+// chunkSpool is where streamDownload keeps each chunk from its download until it is delivered:
+// a file in Dir, or memory when Dir is empty.
+type chunkSpool struct {
+	Dir      string
+	MaxBytes int64  // Largest chunk kept in memory.
+	Pattern  string // File names of the chunks, see chunkName. Defaults to DefaultChunkNamePattern.
+	Keep     bool   // Keep the files of delivered chunks, see JobOptions.KeepChunks.
+
+	Logger *log.Logger // Logger of the job, for resumed downloads. Defaults to log.Default().
+}
+
+func (s chunkSpool) logger() *log.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return log.Default()
+}
+
+// downloadedChunk is a chunk held by a chunkSpool, in the file at Path or, in memory, in Data.
+type downloadedChunk struct {
+	Chunk
+	Path string
+	Data []byte
+	keep bool // Leave the file in place when the chunk is discarded.
+}
+
+// DefaultChunkNamePattern is the file name of downloaded chunks, see chunkName.
+const DefaultChunkNamePattern = "chunk_{index}.aac"
+
+// chunkName returns the file name of the i-th chunk of a playlist by pattern, in which {index}
+// is replaced by the chunk's number, zero-padded to four digits, and {name} by the last element
+// of its URL, e.g. "20260113180000_abc.aac".
+func chunkName(pattern string, i int, chunk Chunk) string {
+	if pattern == "" {
+		pattern = DefaultChunkNamePattern
+	}
+	name := "chunk"
+	if u, err := url.Parse(chunk.URL); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+		name = path.Base(u.Path)
+	}
+	return sanitizeFileName(strings.NewReplacer("{index}", fmt.Sprintf("%04d", i), "{name}", name).Replace(pattern))
+}
+
+// checkChunkNamePattern validates the chunk_name_pattern setting. {index} is required, as
+// the URLs of different chunks may end in the same name.
+func checkChunkNamePattern(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	if !strings.Contains(pattern, "{index}") {
+		return fmt.Errorf("pattern '%s' must contain {index}", pattern)
+	}
+	if strings.ContainsAny(pattern, `/\`) {
+		return fmt.Errorf("pattern '%s' must be a file name, not a path", pattern)
+	}
+	return nil
+}
+
+// store keeps data as the i-th chunk.
+func (s chunkSpool) store(i int, chunk Chunk, data []byte) (downloadedChunk, error) {
+	c := downloadedChunk{Chunk: chunk}
+	if s.Dir == "" {
+		c.Data = data
+		return c, nil
+	}
+	c.Path, c.keep = filepath.Join(s.Dir, chunkName(s.Pattern, i, chunk)), s.Keep
+	return c, os.WriteFile(c.Path, data, 0644)
+}
+
+// download fetches chunk as the i-th chunk, replacing any previous attempt.
+func (s chunkSpool) download(ctx context.Context, provider Provider, i int, chunk Chunk, timeout time.Duration) (downloadedChunk, error) {
+	c := downloadedChunk{Chunk: chunk}
+	var err error
+	if s.Dir == "" {
+		c.Data, err = downloadChunkData(ctx, s.logger(), provider, chunk.URL, s.MaxBytes, timeout)
+		return c, err
+	}
+	c.Path, c.keep = filepath.Join(s.Dir, chunkName(s.Pattern, i, chunk)), s.Keep
+	_, err = downloadChunk(ctx, s.logger(), provider, chunk.URL, c.Path, timeout)
+	return c, err
+}
+
+// verify checks the chunk, see verifyChunk.
+func (c downloadedChunk) verify() error {
+	if c.Path == "" {
+		return verifyChunk(bytes.NewReader(c.Data))
+	}
+	return verifyChunkFile(c.Path)
+}
+
+// size returns the number of bytes of the chunk, or 0 if it cannot be read.
+func (c downloadedChunk) size() int64 {
+	if c.Path == "" {
+		return int64(len(c.Data))
+	}
+	info, err := os.Stat(c.Path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// bytes returns the contents of the chunk.
+func (c downloadedChunk) bytes() ([]byte, error) {
+	if c.Path == "" {
+		return c.Data, nil
+	}
+	return os.ReadFile(c.Path)
+}
+
+// discard removes the file of the chunk, if any, unless the spool keeps it.
+func (c downloadedChunk) discard() {
+	if c.Path != "" && !c.keep {
+		os.Remove(c.Path)
+	}
+}
+
+// chunkIndexFile lists the chunks kept in a temporary directory, see writeChunkIndex.
+const chunkIndexFile = "index.tsv"
+
+// writeChunkIndex writes chunkIndexFile into the spool's directory, with a line for each chunk
+// of the playlist: its number, file name, state (ok, corrupt or missing), duration in
+// seconds and URL, for comparing what the CDN served with the playlist.
+func (s chunkSpool) writeChunkIndex(chunks []Chunk) error {
+	var b strings.Builder
+	b.WriteString("index\tfile\tstate\tduration\turl\n")
+	for i, chunk := range chunks {
+		name := chunkName(s.Pattern, i, chunk)
+		state := "ok"
+		if _, err := os.Stat(filepath.Join(s.Dir, name)); err != nil {
+			state = "missing"
+		} else if err := verifyChunkFile(filepath.Join(s.Dir, name)); err != nil {
+			state = "corrupt: " + strings.ReplaceAll(err.Error(), "\t", " ")
+		}
+		fmt.Fprintf(&b, "%d\t%s\t%s\t%.3f\t%s\n", i, name, state, chunk.Duration.Seconds(), chunk.URL)
+	}
+	return os.WriteFile(filepath.Join(s.Dir, chunkIndexFile), []byte(b.String()), 0644)
+}
@@ -0,0 +1,424 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// id3Tag returns a minimal ID3v2.4 tag with a payload of the given size.
+func id3Tag(size int) []byte {
+	tag := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, byte(size >> 7), byte(size & 0x7f)}
+	return append(tag, make([]byte, size)...)
+}
+
+func TestVerifyChunkFile(t *testing.T) {
+	adts := []byte(dummyAACChunk)
+
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr string
+	}{
+		{name: "Plain ADTS", content: adts},
+		{name: "ID3 tag followed by ADTS", content: append(id3Tag(200), adts...)},
+		{name: "Empty chunk", content: nil, wantErr: "chunk is empty"},
+		{name: "HTML error page", content: []byte("<html>503</html>"), wantErr: "missing ADTS sync word"},
+		{name: "ID3 tag without audio", content: id3Tag(20), wantErr: "no audio data after ID3 tag"},
+		{name: "Single byte", content: []byte{0xFF}, wantErr: "chunk too short"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "chunk.aac")
+			if err := os.WriteFile(path, tt.content, 0644); err != nil {
+				t.Fatalf("Failed to write chunk: %v", err)
+			}
+
+			err := verifyChunkFile(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected valid chunk, got %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestBulkDownloadRetriesCorruptChunks(t *testing.T) {
+	requests := map[string]int{}
+	client := &MockRadikoClient{
+		DoFn: func(req *http.Request) (*http.Response, error) {
+			url := req.URL.String()
+			requests[url]++
+			body := dummyAACChunk
+			switch {
+			case strings.HasSuffix(url, "flaky.aac") && requests[url] == 1:
+				body = "" // Truncated on the first attempt only
+			case strings.HasSuffix(url, "broken.aac"):
+				body = "<html>error</html>"
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		},
+	}
+
+	progress := newLogProgress(log.New(io.Discard, "", 0), time.Minute, Output{})
+	urls := []string{"http://mock.chunk/ok.aac", "http://mock.chunk/flaky.aac", "http://mock.chunk/broken.aac"}
+
+	_, err := bulkDownload(context.Background(), client, urlChunks(urls), t.TempDir(), nil, nil, time.Minute, progress)
+
+	var integrityErr *ChunkIntegrityError
+	if !errors.As(err, &integrityErr) {
+		t.Fatalf("expected *ChunkIntegrityError, got %v", err)
+	}
+	if len(integrityErr.Failures) != 1 || integrityErr.Failures[0].Index != 2 {
+		t.Errorf("expected only chunk 2 to be unrecoverable, got %+v", integrityErr.Failures)
+	}
+	if requests["http://mock.chunk/flaky.aac"] != 2 {
+		t.Errorf("expected flaky chunk to be downloaded twice, got %d", requests["http://mock.chunk/flaky.aac"])
+	}
+	if requests["http://mock.chunk/broken.aac"] != maxChunkAttempts {
+		t.Errorf("expected broken chunk to be tried %d times, got %d", maxChunkAttempts, requests["http://mock.chunk/broken.aac"])
+	}
+}
+
+func TestChunkIntegrityErrorDoesNotReorderFailures(t *testing.T) {
+	failures := []ChunkFailure{
+		{Index: 7, URL: "http://mock.chunk/7.aac", Err: errors.New("chunk is empty")},
+		{Index: 2, URL: "http://mock.chunk/2.aac", Err: errors.New("chunk is empty")},
+	}
+	err := &ChunkIntegrityError{Failures: failures}
+
+	done := make(chan string)
+	for range 2 {
+		go func() { done <- err.Error() }()
+	}
+	for range 2 {
+		if msg := <-done; !strings.Contains(msg, "chunk 2 (http://mock.chunk/2.aac): chunk is empty; chunk 7") {
+			t.Errorf("expected the failures in index order, got %q", msg)
+		}
+	}
+	if err.Failures[0].Index != 7 || err.Failures[1].Index != 2 {
+		t.Errorf("Error() reordered the failures: %+v", err.Failures)
+	}
+}
+
+func TestStreamDownloadMissingChunks(t *testing.T) {
+	requests := map[string]int{}
+	client := &MockRadikoClient{
+		DoFn: func(req *http.Request) (*http.Response, error) {
+			url := req.URL.String()
+			requests[url]++
+			switch {
+			case strings.HasSuffix(url, "gone.aac"):
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+			case strings.HasSuffix(url, "broken.aac"):
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("<html>error</html>"))}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(dummyAACChunk))}, nil
+		},
+	}
+	progress := newLogProgress(log.New(io.Discard, "", 0), time.Minute, Output{})
+	urls := []string{"http://mock.chunk/1.aac", "http://mock.chunk/gone.aac", "http://mock.chunk/2.aac", "http://mock.chunk/broken.aac", "http://mock.chunk/3.aac"}
+
+	tests := []struct {
+		name          string
+		maxMissing    int
+		wantDelivered []string
+		wantMissing   []int
+		wantErr       string
+	}{
+		{name: "none tolerated", wantDelivered: urls[:1], wantErr: "failed to download chunk 1"},
+		{name: "too many missing", maxMissing: 1, wantDelivered: []string{urls[0], urls[2]}, wantErr: "2 chunk(s) unrecoverable"},
+		{name: "tolerated", maxMissing: 2, wantDelivered: []string{urls[0], urls[2], urls[4]}, wantMissing: []int{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clear(requests)
+			var delivered []string
+			deliver := func(c downloadedChunk) error {
+				delivered = append(delivered, c.URL)
+				return nil
+			}
+			missing, err := streamDownload(context.Background(), client, urlChunks(urls), chunkSpool{MaxBytes: 1 << 10}, nil, nil, time.Minute, tt.maxMissing, progress, deliver)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("streamDownload failed: %v", err)
+			}
+			if !reflect.DeepEqual(delivered, tt.wantDelivered) {
+				t.Errorf("delivered %v, want %v", delivered, tt.wantDelivered)
+			}
+			var indexes []int
+			for _, f := range missing {
+				indexes = append(indexes, f.Index)
+			}
+			if !reflect.DeepEqual(indexes, tt.wantMissing) {
+				t.Errorf("missing chunks %v, want %v", indexes, tt.wantMissing)
+			}
+			if tt.maxMissing > 0 && requests["http://mock.chunk/gone.aac"] != maxChunkAttempts {
+				t.Errorf("expected the missing chunk to be tried %d times, got %d", maxChunkAttempts, requests["http://mock.chunk/gone.aac"])
+			}
+		})
+	}
+}
+
+func TestBulkDownloadRetriesStalledChunks(t *testing.T) {
+	attempts := 0
+	client := &MockRadikoClient{
+		DoFn: func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts == 1 {
+				<-req.Context().Done() // Stalled until the request timeout.
+				return nil, req.Context().Err()
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(dummyAACChunk))}, nil
+		},
+	}
+
+	progress := newLogProgress(log.New(io.Discard, "", 0), time.Minute, Output{})
+	files, err := bulkDownload(context.Background(), client, urlChunks([]string{"http://mock.chunk/1.aac"}), t.TempDir(), nil, nil, 20*time.Millisecond, progress)
+	if err != nil {
+		t.Fatalf("bulkDownload failed: %v", err)
+	}
+	if len(files) != 1 || attempts != 2 {
+		t.Errorf("expected the stalled chunk to be downloaded again, got %d files after %d attempts", len(files), attempts)
+	}
+}
+
+func TestBulkDownloadUsesChunkCache(t *testing.T) {
+	requests := 0
+	client := &MockRadikoClient{
+		DoFn: func(req *http.Request) (*http.Response, error) {
+			requests++
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(dummyAACChunk))}, nil
+		},
+	}
+	cache := NewCache("chunk", t.TempDir(), 1<<20)
+	progress := newLogProgress(log.New(io.Discard, "", 0), time.Minute, Output{})
+	urls := []string{"http://mock.chunk/1.aac", "http://mock.chunk/2.aac"}
+
+	for i := 0; i < 2; i++ {
+		files, err := bulkDownload(context.Background(), client, urlChunks(urls), t.TempDir(), cache, nil, time.Minute, progress)
+		if err != nil {
+			t.Fatalf("bulkDownload failed: %v", err)
+		}
+		if len(files) != len(urls) {
+			t.Fatalf("expected %d files, got %d", len(urls), len(files))
+		}
+	}
+	if requests != len(urls) {
+		t.Errorf("expected the second run to be served from cache, got %d requests", requests)
+	}
+}
+
+func TestStreamDownloadInMemory(t *testing.T) {
+	client := &MockRadikoClient{
+		DoFn: func(req *http.Request) (*http.Response, error) {
+			body := dummyAACChunk
+			if strings.HasSuffix(req.URL.Path, "big.aac") {
+				body += strings.Repeat("x", 100)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		},
+	}
+	progress := newLogProgress(log.New(io.Discard, "", 0), time.Minute, Output{})
+	spool := chunkSpool{MaxBytes: 64}
+
+	var delivered []downloadedChunk
+	deliver := func(c downloadedChunk) error {
+		delivered = append(delivered, c)
+		return nil
+	}
+	urls := []string{"http://mock.chunk/1.aac", "http://mock.chunk/2.aac"}
+	if _, err := streamDownload(context.Background(), client, urlChunks(urls), spool, nil, nil, time.Minute, 0, progress, deliver); err != nil {
+		t.Fatalf("streamDownload failed: %v", err)
+	}
+	for i, c := range delivered {
+		if c.Path != "" || string(c.Data) != dummyAACChunk || c.URL != urls[i] {
+			t.Errorf("unexpected chunk %d: %+v", i, c)
+		}
+	}
+
+	_, err := streamDownload(context.Background(), client, urlChunks([]string{"http://mock.chunk/big.aac"}), spool, nil, nil, time.Minute, 0, progress, deliver)
+	if err == nil || !strings.Contains(err.Error(), "exceeds the in-memory limit of 64 B") {
+		t.Errorf("expected the chunk to exceed the limit, got %v", err)
+	}
+}
+
+// resumeMockClient is a MockRadikoClient that also resumes chunk downloads with Range requests.
+type resumeMockClient struct {
+	*MockRadikoClient
+}
+
+func (m *resumeMockClient) DownloadFrom(ctx context.Context, chunkURL string, offset int64) (io.ReadCloser, bool, error) {
+	return httpGetFrom(ctx, doerFunc(m.do), chunkURL, offset)
+}
+
+// brokenReader returns the bytes of r, then fails as a dropped connection would.
+type brokenReader struct{ r io.Reader }
+
+func (b brokenReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func TestDownloadChunkResumes(t *testing.T) {
+	const chunk = "0123456789abcdefghij"
+	tests := []struct {
+		name       string
+		resumable  bool
+		honorRange bool
+		breaks     int // Responses that break off after 5 bytes.
+		expectErr  bool
+		ranges     []string
+	}{
+		{name: "Resumed at the first missing byte", resumable: true, honorRange: true, breaks: 2, ranges: []string{"", "bytes=5-", "bytes=10-"}},
+		{name: "Range ignored by the server", resumable: true, breaks: 1, ranges: []string{"", "bytes=5-"}},
+		{name: "Provider without ranges", breaks: 1, expectErr: true, ranges: []string{""}},
+		{name: "Too many interruptions", resumable: true, honorRange: true, breaks: maxChunkResumes + 1, expectErr: true, ranges: []string{"", "bytes=5-", "bytes=10-", "bytes=15-"}},
+	}
+	for _, tt := range tests {
+		for _, inMemory := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/memory=%v", tt.name, inMemory), func(t *testing.T) {
+				var ranges []string
+				var logged strings.Builder
+				logger := log.New(&logged, "", 0)
+				mock := &MockRadikoClient{DoFn: func(req *http.Request) (*http.Response, error) {
+					rangeHeader := req.Header.Get("Range")
+					ranges = append(ranges, rangeHeader)
+					status, body := http.StatusOK, chunk
+					var offset int
+					if tt.honorRange && rangeHeader != "" {
+						fmt.Sscanf(rangeHeader, "bytes=%d-", &offset)
+						status, body = http.StatusPartialContent, chunk[offset:]
+					}
+					var r io.Reader = strings.NewReader(body)
+					if len(ranges) <= tt.breaks {
+						r = brokenReader{strings.NewReader(body[:min(5, len(body))])}
+					}
+					return &http.Response{StatusCode: status, Body: io.NopCloser(r)}, nil
+				}}
+				var provider Provider = mock
+				if tt.resumable {
+					provider = &resumeMockClient{mock}
+				}
+
+				var got string
+				var err error
+				if inMemory {
+					var data []byte
+					data, err = downloadChunkData(context.Background(), logger, provider, "http://mock.chunk/1.aac", 64, time.Minute)
+					got = string(data)
+				} else {
+					path := filepath.Join(t.TempDir(), "chunk.aac")
+					if _, err = downloadChunk(context.Background(), logger, provider, "http://mock.chunk/1.aac", path, time.Minute); err == nil {
+						data, _ := os.ReadFile(path)
+						got = string(data)
+					}
+				}
+				if (err != nil) != tt.expectErr {
+					t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+				}
+				if !tt.expectErr && got != chunk {
+					t.Errorf("downloaded %q, want %q", got, chunk)
+				}
+				if !reflect.DeepEqual(ranges, tt.ranges) {
+					t.Errorf("requested ranges %q, want %q", ranges, tt.ranges)
+				}
+				if resumed := strings.Contains(logged.String(), "INFO: Resuming chunk"); resumed != (tt.resumable && len(tt.ranges) > 1) {
+					t.Errorf("job log %q, want resumption logged %v", logged.String(), !resumed)
+				}
+			})
+		}
+	}
+}
+
+// urlChunks returns chunks of unknown duration at urls.
+func urlChunks(urls []string) []Chunk {
+	chunks := make([]Chunk, len(urls))
+	for i, u := range urls {
+		chunks[i] = Chunk{URL: u}
+	}
+	return chunks
+}
+
+func TestChunkName(t *testing.T) {
+	chunk := Chunk{URL: "https://cdn.example/ts/20260113180000_abc.aac?token=x"}
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"", "chunk_0007.aac"},
+		{"{index}-{name}", "0007-20260113180000_abc.aac"},
+		{"seg{index}.ts", "seg0007.ts"},
+	}
+	for _, tt := range tests {
+		if got := chunkName(tt.pattern, 7, chunk); got != tt.expected {
+			t.Errorf("chunkName(%q) = %q, want %q", tt.pattern, got, tt.expected)
+		}
+	}
+	for _, pattern := range []string{"{name}", "dir/{index}.aac"} {
+		if err := checkChunkNamePattern(pattern); err == nil {
+			t.Errorf("checkChunkNamePattern(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestExecuteJobKeepChunks(t *testing.T) {
+	tempDir := t.TempDir()
+	opts := JobOptions{
+		OutputDir:        t.TempDir(),
+		TempDir:          tempDir,
+		Logger:           log.New(io.Discard, "", 0),
+		FetchGuide:       func(context.Context, string) ([]byte, error) { return nil, errors.New("offline") },
+		KeepChunks:       true,
+		ChunkBufferMB:    1, // Ignored: kept chunks need a directory.
+		ChunkNamePattern: "{index}-{name}",
+
+		DisableProgressBar: true,
+	}
+	entry := ScheduleEntry{ProgramName: "Show", StationID: "ST1"}
+	if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, time.Date(2026, time.January, 12, 10, 0, 0, 0, JST), opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+
+	dirs, _ := filepath.Glob(filepath.Join(tempDir, "radikoRecScheduler-chunks-*"))
+	if len(dirs) != 1 {
+		t.Fatalf("expected the temporary directory to be kept, found %v", dirs)
+	}
+	for _, name := range []string{"0000-chunk1.aac", "0001-chunk2.aac"} {
+		if _, err := os.Stat(filepath.Join(dirs[0], name)); err != nil {
+			t.Errorf("chunk %s not kept: %v", name, err)
+		}
+	}
+	index, err := os.ReadFile(filepath.Join(dirs[0], chunkIndexFile))
+	if err != nil {
+		t.Fatalf("Failed to read the chunk index: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(index)), "\n")
+	want := []string{
+		"index\tfile\tstate\tduration\turl",
+		"0\t0000-chunk1.aac\tok\t0.000\thttp://mock.chunk/chunk1.aac",
+		"1\t0001-chunk2.aac\tok\t0.000\thttp://mock.chunk/chunk2.aac",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("index = %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errKernelCopyUnsupported is returned by copyFileKernel when the platform or
+// filesystem cannot copy between the two files inside the kernel.
+var errKernelCopyUnsupported = errors.New("in-kernel file copy not supported")
+
+// chunkCopier appends the remaining contents of src to dst.
+type chunkCopier func(dst, src *os.File) (int64, error)
+
+// appendChunk appends src to dst, preferring an in-kernel copy (copy_file_range or
+// sendfile on Linux) so chunk data does not pass through userspace buffers.
+// It falls back to a buffered copy where that is not available.
+func appendChunk(dst, src *os.File) (int64, error) {
+	n, err := copyFileKernel(dst, src)
+	if errors.Is(err, errKernelCopyUnsupported) {
+		return userspaceCopy(dst, src)
+	}
+	return n, err
+}
+
+// userspaceCopy appends src to dst through a userspace buffer.
+// The files are wrapped so io.Copy cannot pick a kernel fast path on its own.
+func userspaceCopy(dst, src *os.File) (int64, error) {
+	return io.Copy(struct{ io.Writer }{dst}, struct{ io.Reader }{src})
+}
+
+// concatFiles concatenates inputFiles into outputFile using copyChunk for each input.
+// The output is flushed to disk before it is closed, so it can be renamed into place safely.
+func concatFiles(inputFiles []string, outputFile string, copyChunk chunkCopier) (err error) {
+	outFile, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create output file '%s': %w", outputFile, err)
+	}
+	defer func() {
+		if closeErr := outFile.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to close output file '%s': %w", outputFile, closeErr)
+		}
+	}()
+
+	for _, inFile := range inputFiles {
+		if err := appendFile(outFile, inFile, copyChunk); err != nil {
+			return err
+		}
+	}
+	if err := outFile.Sync(); err != nil {
+		return fmt.Errorf("failed to flush output file '%s': %w", outputFile, err)
+	}
+	return nil
+}
+
+// appendFile appends the file at path to dst using copyChunk, closing it before returning
+// so that long chunk lists never hold more than one input open.
+func appendFile(dst *os.File, path string, copyChunk chunkCopier) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open input file '%s': %w", path, err)
+	}
+	defer src.Close()
+
+	if _, err := copyChunk(dst, src); err != nil {
+		return fmt.Errorf("failed to concatenate file '%s': %w", path, err)
+	}
+	return nil
+}
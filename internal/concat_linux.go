@@ -0,0 +1,60 @@
+//go:build linux
+
+package internal
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxKernelCopy bounds a single copy_file_range/sendfile call.
+const maxKernelCopy = 1 << 30
+
+// copyFileKernel appends src to dst with copy_file_range, or sendfile when the
+// filesystem does not support it (e.g. across mounts on older kernels).
+// Both advance the file offsets, so a buffered copy can take over if neither works.
+func copyFileKernel(dst, src *os.File) (int64, error) {
+	srcFd, dstFd := int(src.Fd()), int(dst.Fd())
+
+	written, err := kernelCopyLoop(func() (int, error) {
+		return unix.CopyFileRange(srcFd, nil, dstFd, nil, maxKernelCopy, 0)
+	})
+	if err == nil || !kernelCopyUnsupported(err) {
+		return written, err
+	}
+
+	n, err := kernelCopyLoop(func() (int, error) {
+		return unix.Sendfile(dstFd, srcFd, nil, maxKernelCopy)
+	})
+	written += n
+	if err != nil && kernelCopyUnsupported(err) {
+		return written, errKernelCopyUnsupported
+	}
+	return written, err
+}
+
+// kernelCopyLoop calls copyOnce until it reports end of file.
+func kernelCopyLoop(copyOnce func() (int, error)) (int64, error) {
+	var written int64
+	for {
+		n, err := copyOnce()
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+		if n == 0 {
+			return written, nil
+		}
+		written += int64(n)
+	}
+}
+
+// kernelCopyUnsupported reports whether err means the kernel cannot copy between these files.
+func kernelCopyUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EXDEV) || errors.Is(err, unix.EINVAL) ||
+		errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EPERM)
+}
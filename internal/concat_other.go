@@ -0,0 +1,10 @@
+//go:build !linux
+
+package internal
+
+import "os"
+
+// copyFileKernel is only implemented on Linux; elsewhere appendChunk uses a buffered copy.
+func copyFileKernel(dst, src *os.File) (int64, error) {
+	return 0, errKernelCopyUnsupported
+}
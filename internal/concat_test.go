@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestChunks writes count chunk files of size bytes each and returns their paths and joined contents.
+func writeTestChunks(tb testing.TB, dir string, count, size int) ([]string, []byte) {
+	tb.Helper()
+	var all []byte
+	paths := make([]string, count)
+	for i := range paths {
+		data := bytes.Repeat([]byte{byte('a' + i%26)}, size)
+		paths[i] = filepath.Join(dir, fmt.Sprintf("chunk_%04d.aac", i))
+		if err := os.WriteFile(paths[i], data, 0644); err != nil {
+			tb.Fatalf("Failed to write chunk: %v", err)
+		}
+		all = append(all, data...)
+	}
+	return paths, all
+}
+
+func TestConcatFilesCopiers(t *testing.T) {
+	dir := t.TempDir()
+	inputs, want := writeTestChunks(t, dir, 5, 64*1024+7)
+
+	tests := []struct {
+		name   string
+		copier chunkCopier
+	}{
+		{"kernel", appendChunk},
+		{"userspace", userspaceCopy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(dir, tt.name+".aac")
+			if err := concatFiles(inputs, out, tt.copier); err != nil {
+				t.Fatalf("concatFiles failed: %v", err)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("output mismatch: got %d bytes, want %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+// openFiles returns the number of file descriptors open in the process, skipping the test
+// where that is not available.
+func openFiles(tb testing.TB) int {
+	tb.Helper()
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		tb.Skipf("open files not available: %v", err)
+	}
+	return len(fds)
+}
+
+func TestConcatFilesManyChunks(t *testing.T) {
+	dir := t.TempDir()
+	inputs, want := writeTestChunks(t, dir, 3000, 16)
+
+	before := openFiles(t)
+	peak := 0
+	counting := func(dst, src *os.File) (int64, error) {
+		peak = max(peak, openFiles(t))
+		return appendChunk(dst, src)
+	}
+	out := filepath.Join(dir, "out.aac")
+	if err := concatFiles(inputs, out, counting); err != nil {
+		t.Fatalf("concatFiles failed: %v", err)
+	}
+	// The output, the current input and the descriptor reading /proc/self/fd.
+	if peak-before > 3 {
+		t.Errorf("expected inputs to be closed as they are appended, %d files were open at once", peak-before)
+	}
+	if got, err := os.ReadFile(out); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("output mismatch: got %d bytes (%v), want %d", len(got), err, len(want))
+	}
+	if after := openFiles(t); after != before {
+		t.Errorf("expected %d open files after concatFiles, got %d", before, after)
+	}
+}
+
+// BenchmarkConcatFiles compares the in-kernel copy path with the buffered fallback
+// on a recording-sized input (360 chunks of ~160 KB, roughly one hour).
+func BenchmarkConcatFiles(b *testing.B) {
+	dir := b.TempDir()
+	inputs, all := writeTestChunks(b, dir, 360, 160*1024)
+
+	for _, bm := range []struct {
+		name   string
+		copier chunkCopier
+	}{
+		{"kernel", appendChunk},
+		{"userspace", userspaceCopy},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.SetBytes(int64(len(all)))
+			out := filepath.Join(dir, bm.name+".aac")
+			for b.Loop() {
+				if err := concatFiles(inputs, out, bm.copier); err != nil {
+					b.Fatalf("concatFiles failed: %v", err)
+				}
+			}
+		})
+	}
+}
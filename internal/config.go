@@ -1,33 +1,392 @@
 package internal
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"time"
 )
 
-// GetScheduleConfigPath returns the XDG compliant path for schedule.json.
+// Config holds global application settings and the schedule, loaded from config.json.
+type Config struct {
+	OutputDir          string            `json:"output_dir"`
+	OutputLayout       string            `json:"output_layout"`                 // LayoutTimestamp (default), LayoutTitle or LayoutSeries.
+	TitleCollision     string            `json:"title_collision"`               // CollisionDateSubtitle (default), CollisionDate or CollisionSubtitle.
+	Concurrency        int               `json:"concurrency"`                   // Number of jobs recorded in parallel. Defaults to 1.
+	ChunkRate          float64           `json:"chunk_rate"`                    // Chunk requests per second across all jobs; 0 is unlimited.
+	JobChunkRate       float64           `json:"job_chunk_rate,omitempty"`      // Chunk requests per second of each job; 0 is unlimited.
+	StationConcurrency int               `json:"station_concurrency,omitempty"` // Jobs of one station recorded in parallel; 0 is unlimited.
+	PanicHours         float64           `json:"panic_hours,omitempty"`         // Jobs this close to leaving the timeshift window ignore the three limits above; 0 disables.
+	JobTimeout         int               `json:"job_timeout_minutes,omitempty"` // Limit of each recording job; 0 is unlimited.
+	Radiko             RadikoCredentials `json:"radiko"`
+	PostStore          PostStoreConfig   `json:"post_store"`
+	PostCommand        string            `json:"post_command"`              // Shell command run after each successful recording.
+	Chapters           bool              `json:"chapters"`                  // Write chapters from the program guide next to recordings.
+	ShowNotes          bool              `json:"show_notes"`                // Write the guide's description of each broadcast next to recordings.
+	FFmpegPath         string            `json:"ffmpeg_path,omitempty"`     // ffmpeg used for loudness normalization and archive transcoding. Defaults to "ffmpeg" in PATH.
+	TempDir            string            `json:"temp_dir,omitempty"`        // Where chunks are downloaded. Defaults to the OS temporary directory.
+	ChunkBufferMB      int               `json:"chunk_buffer_mb,omitempty"` // Keep chunks in memory, up to this size each, instead of in temp_dir.
+	Timezone           string            `json:"timezone,omitempty"`        // Time zone the schedule is written in. Defaults to Asia/Tokyo.
+
+	// PreferGuideTitle names and tags recordings after the guide's title of the broadcast,
+	// correcting a misspelt program_name; program_name is kept in the history and metadata.
+	// Defaults to true; false keeps program_name. See GuideTitles.
+	PreferGuideTitle *bool `json:"prefer_guide_title,omitempty"`
+
+	// Quality selects the variant stream recorded when a master playlist offers several:
+	// QualityHighest, QualityLowest or a bitrate in kbps. Empty takes the first one listed.
+	Quality string `json:"quality,omitempty"`
+
+	// Lang is the language of console messages: LangEnglish (default) or LangJapanese.
+	Lang string `json:"lang,omitempty"`
+	// ProgressCharset draws the progress bar with CharsetASCII (default) or CharsetUnicode block characters.
+	ProgressCharset string `json:"progress_charset,omitempty"`
+
+	// KeepChunks leaves each job's chunks and an index of them in temp_dir, for debugging downloads.
+	KeepChunks bool `json:"keep_chunks,omitempty"`
+	// ChunkNamePattern names the chunk files in temp_dir, with {index} and {name} (the URL's file name).
+	ChunkNamePattern string `json:"chunk_name_pattern,omitempty"`
+
+	// TolerateMissingChunks is the percentage of chunks that may be missing from a recording
+	// when they cannot be downloaded, instead of failing it. 0 tolerates none.
+	TolerateMissingChunks float64 `json:"tolerate_missing_chunks,omitempty"`
+
+	Notifications NotificationConfig  `json:"notifications"`
+	Cache         CacheConfig         `json:"cache"`
+	Audit         AuditConfig         `json:"audit"`
+	Network       NetworkConfig       `json:"network"`
+	Rotation      RotationConfig      `json:"rotation"`
+	DurationCheck DurationCheckConfig `json:"duration_check"`
+	Retry         RetryConfig         `json:"retry"`
+	GRPC          GRPCConfig          `json:"grpc"`
+	Transcription TranscriptionConfig `json:"transcription"`
+	FileNames     FileNameConfig      `json:"file_names"`
+
+	// Schedule holds the programs to record. Older setups keep it in a separate
+	// schedule.json instead; see MigrateLegacySchedule.
+	Schedule []ScheduleEntry `json:"schedule,omitempty"`
+	// Subscriptions record every broadcast matching a keyword, in addition to the schedule.
+	Subscriptions []Subscription `json:"subscriptions,omitempty"`
+}
+
+// RadikoCredentials are the radiko premium account used for area-free recording.
+type RadikoCredentials struct {
+	Mail     string `json:"mail"`
+	Password string `json:"password"`
+	// AppKeyFile is the auth key of the radiko smartphone app (aSmartPhone7a), for
+	// authorizing tokens that play the timeshift of stations outside the area.
+	AppKeyFile string `json:"app_key_file,omitempty"`
+}
+
+// GuideTitles reports whether recordings are named after the guide's title, see PreferGuideTitle.
+func (c *Config) GuideTitles() bool {
+	return c.PreferGuideTitle == nil || *c.PreferGuideTitle
+}
+
+// ScheduleLocation returns the time zone of Timezone, in which the schedule is read. A time zone
+// that does not load, which LoadConfig rejects, is Japan time.
+func (c *Config) ScheduleLocation() *time.Location {
+	loc, err := LoadScheduleLocation(c.Timezone)
+	if err != nil {
+		return JST
+	}
+	return loc
+}
+
+// Output returns how console output is written, from Lang and ProgressCharset.
+func (c *Config) Output() Output {
+	return Output{Lang: c.Lang, Charset: c.ProgressCharset}
+}
+
+// DefaultConfig returns the settings of the default profile used when no config.json exists.
+func DefaultConfig() *Config {
+	return Profile("").DefaultConfig()
+}
+
+// DefaultConfig returns the settings of the profile used when its config.json does not exist.
+func (p Profile) DefaultConfig() *Config {
+	outputDir := "output"
+	if p != "" {
+		outputDir = filepath.Join(outputDir, string(p))
+	}
+	return &Config{
+		OutputDir:   outputDir,
+		Concurrency: 1,
+		Cache:       DefaultCacheConfig(),
+	}
+}
+
+// appName is the directory name used below the per-user config, data and cache directories.
+const appName = "radikoRecScheduler"
+
+// appDirSpec describes where one kind of per-user directory lives on each platform.
+type appDirSpec struct {
+	kind       string   // "config", "data" or "cache", for error messages.
+	xdgVar     string   // XDG base directory variable, honoured on every platform.
+	home       []string // Fallback below the home directory.
+	windowsVar string   // Base directory variable on Windows, used instead of the home fallback.
+	windowsSub string   // Subdirectory of the application directory on Windows, if any.
+}
+
+var (
+	configDirSpec = appDirSpec{kind: "config", xdgVar: "XDG_CONFIG_HOME", home: []string{".config"}, windowsVar: "APPDATA"}
+	dataDirSpec   = appDirSpec{kind: "data", xdgVar: "XDG_DATA_HOME", home: []string{".local", "share"}, windowsVar: "LOCALAPPDATA"}
+	cacheDirSpec  = appDirSpec{kind: "cache", xdgVar: "XDG_CACHE_HOME", home: []string{".cache"}, windowsVar: "LOCALAPPDATA", windowsSub: "cache"}
+)
+
+// resolve returns the application directory of spec on goos: below the XDG variable if set,
+// else below %APPDATA% or %LOCALAPPDATA% on Windows, else below the home directory.
+func (spec appDirSpec) resolve(goos string, getenv func(string) string, homeDir func() (string, error)) (string, error) {
+	if base := getenv(spec.xdgVar); base != "" {
+		return filepath.Join(base, appName), nil
+	}
+	if goos == "windows" {
+		if base := getenv(spec.windowsVar); base != "" {
+			return filepath.Join(base, appName, spec.windowsSub), nil
+		}
+	}
+	home, err := homeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(append(append([]string{home}, spec.home...), appName)...), nil
+}
+
+// appDir returns the application directory of spec on this platform, or its profiles/<name>
+// subdirectory for a profile other than the default one, creating it if it doesn't exist.
+func (p Profile) appDir(spec appDirSpec) (string, error) {
+	dir, err := spec.resolve(runtime.GOOS, os.Getenv, os.UserHomeDir)
+	if err != nil {
+		return "", err
+	}
+	if p != "" {
+		dir = filepath.Join(dir, profilesDir, string(p))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create application %s directory '%s': %w", spec.kind, dir, err)
+	}
+	return dir, nil
+}
+
+// configDir returns the XDG compliant application config directory of the profile (%APPDATA% on Windows).
 // It creates the necessary directory structure if it doesn't exist.
-func GetScheduleConfigPath() (string, error) {
-	var configHome string
-
-	// 1. Check XDG_CONFIG_HOME environment variable
-	xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
-	if xdgConfigHome != "" {
-		configHome = xdgConfigHome
-	} else {
-		// 2. Fallback to ~/.config
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("failed to get user home directory: %w", err)
+func (p Profile) configDir() (string, error) {
+	return p.appDir(configDirSpec)
+}
+
+// DataDir returns the XDG compliant application data directory of the profile used for history and other state
+// (%LOCALAPPDATA% on Windows). It creates the necessary directory structure if it doesn't exist.
+func (p Profile) DataDir() (string, error) {
+	return p.appDir(dataDirSpec)
+}
+
+// CacheDir returns the XDG compliant application cache directory of the profile (%LOCALAPPDATA%\radikoRecScheduler\cache
+// on Windows). It creates the necessary directory structure if it doesn't exist.
+func (p Profile) CacheDir() (string, error) {
+	return p.appDir(cacheDirSpec)
+}
+
+// ScheduleConfigPath returns the XDG compliant path of the schedule file of the profile: schedule.json,
+// or schedule.yaml/.yml/.toml if only one of those exists.
+// It creates the necessary directory structure if it doesn't exist.
+func (p Profile) ScheduleConfigPath() (string, error) {
+	appConfigDir, err := p.configDir()
+	if err != nil {
+		return "", err
+	}
+	return FindScheduleFile(appConfigDir), nil
+}
+
+// configPathOverride is the config.json given on the command line, see SetConfigPath.
+var configPathOverride string
+
+// SetConfigPath makes GetConfigPath return path, e.g. from a -config flag, in preference to ConfigPathEnv.
+func SetConfigPath(path string) {
+	configPathOverride = path
+}
+
+// ConfigPath returns the path of config.json: the one set with SetConfigPath or ConfigPathEnv,
+// or the XDG compliant one of the profile, whose directory structure is created if it doesn't exist.
+func (p Profile) ConfigPath() (string, error) {
+	if configPathOverride != "" {
+		return configPathOverride, nil
+	}
+	if path := os.Getenv(ConfigPathEnv); path != "" {
+		return path, nil
+	}
+	appConfigDir, err := p.configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appConfigDir, "config.json"), nil
+}
+
+// LoadConfig reads and parses the config file of the default profile from the given path.
+// A missing file is not an error; the default configuration is returned instead.
+func LoadConfig(filePath string) (*Config, error) {
+	return Profile("").LoadConfig(filePath)
+}
+
+// LoadConfig is like the package-level LoadConfig, with the defaults of the profile.
+func (p Profile) LoadConfig(filePath string) (*Config, error) {
+	return p.loadConfig(filePath, nil)
+}
+
+// LoadEffectiveConfig is LoadConfig with settings overridden by the environment variables found
+// by lookup (see Config.ApplyEnv), the configuration a run uses. Files written back, such as by
+// MigrateLegacySchedule, are based on LoadConfig instead, so they do not pick up the environment.
+func (p Profile) LoadEffectiveConfig(filePath string, lookup func(string) (string, bool)) (*Config, error) {
+	return p.loadConfig(filePath, lookup)
+}
+
+func (p Profile) loadConfig(filePath string, lookup func(string) (string, bool)) (*Config, error) {
+	cfg := p.DefaultConfig()
+
+	file, err := os.ReadFile(filePath)
+	switch {
+	case os.IsNotExist(err) && lookup == nil:
+		return cfg, nil
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("error reading config file '%s': %w", filePath, err)
+	default:
+		if err := decodeStrictJSON(file, cfg, "config"); err != nil {
+			return nil, fmt.Errorf("error parsing JSON from '%s': %w", filePath, err)
+		}
+	}
+	if lookup != nil {
+		if err := cfg.ApplyEnv(lookup); err != nil {
+			return nil, err
 		}
-		configHome = filepath.Join(homeDir, ".config")
 	}
 
-	appConfigDir := filepath.Join(configHome, "radikoRecScheduler")
-	if err := os.MkdirAll(appConfigDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create application config directory '%s': %w", appConfigDir, err)
+	if cfg.OutputDir == "" {
+		cfg.OutputDir = p.DefaultConfig().OutputDir
+	}
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	if _, err := NewTransport(cfg.Network); err != nil {
+		return nil, fmt.Errorf("invalid network settings in '%s': %w", filePath, err)
+	}
+	if _, err := ParseProviderHeaders(cfg.Network.Headers); err != nil {
+		return nil, fmt.Errorf("invalid network.headers in '%s': %w", filePath, err)
+	}
+	if cfg.JobTimeout < 0 || cfg.Network.RequestTimeoutSeconds < 0 {
+		return nil, fmt.Errorf("invalid timeout in '%s': must not be negative", filePath)
+	}
+	if cfg.Rotation.MaxMB < 0 || cfg.Rotation.MaxMinutes < 0 {
+		return nil, fmt.Errorf("invalid rotation in '%s': limits must not be negative", filePath)
+	}
+	if err := cfg.Notifications.Email.validate(); err != nil {
+		return nil, fmt.Errorf("invalid notifications.email in '%s': %w", filePath, err)
+	}
+	if cfg.ChunkRate < 0 {
+		return nil, fmt.Errorf("invalid chunk_rate %g in '%s': must not be negative", cfg.ChunkRate, filePath)
+	}
+	if cfg.JobChunkRate < 0 {
+		return nil, fmt.Errorf("invalid job_chunk_rate %g in '%s': must not be negative", cfg.JobChunkRate, filePath)
+	}
+	if cfg.TolerateMissingChunks < 0 || cfg.TolerateMissingChunks >= 100 {
+		return nil, fmt.Errorf("invalid tolerate_missing_chunks %g in '%s': must be a percentage from 0 to below 100", cfg.TolerateMissingChunks, filePath)
+	}
+	if cfg.Retry.DelayMinutes < 0 || cfg.Retry.MaxDelayMinutes < 0 || cfg.Retry.MaxAttempts < 0 {
+		return nil, fmt.Errorf("invalid retry in '%s': delay_minutes, max_delay_minutes and max_attempts must not be negative", filePath)
+	}
+	if cfg.ChunkBufferMB < 0 {
+		return nil, fmt.Errorf("invalid chunk_buffer_mb %d in '%s': must not be negative", cfg.ChunkBufferMB, filePath)
+	}
+	if err := checkChunkNamePattern(cfg.ChunkNamePattern); err != nil {
+		return nil, fmt.Errorf("invalid chunk_name_pattern in '%s': %w", filePath, err)
+	}
+	if cfg.PanicHours < 0 {
+		return nil, fmt.Errorf("invalid panic_hours %g in '%s': must not be negative", cfg.PanicHours, filePath)
 	}
+	if cfg.StationConcurrency < 0 {
+		return nil, fmt.Errorf("invalid station_concurrency %d in '%s': must not be negative", cfg.StationConcurrency, filePath)
+	}
+	if cfg.Radiko.AppKeyFile != "" && cfg.Radiko.Mail == "" {
+		return nil, fmt.Errorf("invalid radiko.app_key_file in '%s': area-free tokens need a premium account (radiko.mail)", filePath)
+	}
+	if _, err := LoadScheduleLocation(cfg.Timezone); err != nil {
+		return nil, fmt.Errorf("invalid timezone in '%s': %w", filePath, err)
+	}
+	if err := cfg.GRPC.validate(); err != nil {
+		return nil, fmt.Errorf("invalid grpc in '%s': %w", filePath, err)
+	}
+	if err := checkQuality(cfg.Quality); err != nil {
+		return nil, fmt.Errorf("invalid quality in '%s': %w", filePath, err)
+	}
+	if err := checkLanguage(cfg.Lang); err != nil {
+		return nil, fmt.Errorf("invalid lang in '%s': %w", filePath, err)
+	}
+	if err := checkProgressCharset(cfg.ProgressCharset); err != nil {
+		return nil, fmt.Errorf("invalid progress_charset in '%s': %w", filePath, err)
+	}
+	if err := cfg.FileNames.validate(); err != nil {
+		return nil, fmt.Errorf("invalid file_names in '%s': %w", filePath, err)
+	}
+	switch cfg.OutputLayout {
+	case "", LayoutTimestamp, LayoutTitle, LayoutSeries:
+	default:
+		return nil, fmt.Errorf("invalid output_layout '%s' in '%s'", cfg.OutputLayout, filePath)
+	}
+	switch cfg.TitleCollision {
+	case "", CollisionDateSubtitle, CollisionDate, CollisionSubtitle:
+	default:
+		return nil, fmt.Errorf("invalid title_collision '%s' in '%s'", cfg.TitleCollision, filePath)
+	}
+	for _, sub := range cfg.Subscriptions {
+		if _, err := sub.matcher(); err != nil {
+			return nil, fmt.Errorf("%w in '%s'", err, filePath)
+		}
+	}
+
+	return cfg, nil
+}
 
-	return filepath.Join(appConfigDir, "schedule.json"), nil
+// SaveConfig writes cfg to filePath as indented JSON, readable by the owner only
+// since it may hold credentials.
+func SaveConfig(filePath string, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.WriteFile(filePath, append(data, '\n'), configFileMode); err != nil {
+		return fmt.Errorf("failed to write config file '%s': %w", filePath, err)
+	}
+	// WriteFile keeps the permissions of an existing file.
+	if err := os.Chmod(filePath, configFileMode); err != nil {
+		return fmt.Errorf("failed to restrict permissions of '%s': %w", filePath, err)
+	}
+	return nil
+}
+
+// MigrateLegacySchedule moves the entries of a separate schedule.json into the
+// schedule array of config.json of profile, then renames schedule.json to schedule.json.bak.
+// It returns the number of entries migrated.
+func MigrateLegacySchedule(profile Profile, configPath, schedulePath string) (int, error) {
+	cfg, err := profile.LoadConfig(configPath)
+	if err != nil {
+		return 0, err
+	}
+	if len(cfg.Schedule) > 0 {
+		return 0, fmt.Errorf("'%s' already contains a schedule; refusing to overwrite it", configPath)
+	}
+
+	entries, err := LoadSchedule(schedulePath)
+	if err != nil {
+		return 0, err
+	}
+
+	cfg.Schedule = entries
+	if err := SaveConfig(configPath, cfg); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(schedulePath, schedulePath+".bak"); err != nil {
+		return 0, fmt.Errorf("migrated schedule but failed to rename '%s': %w", schedulePath, err)
+	}
+	return len(entries), nil
 }
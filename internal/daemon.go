@@ -0,0 +1,324 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DaemonState is the live state of RunDaemon, shared with the daemon API.
+type DaemonState struct {
+	mu          sync.Mutex
+	startedAt   time.Time
+	schedule    []ScheduleEntry
+	nextPass    time.Time
+	passRunning bool
+	jobs        *jobTracker
+	passes      chan struct{} // Passes requested by clients, see RequestPass.
+}
+
+// NewDaemonState returns an empty DaemonState.
+func NewDaemonState() *DaemonState {
+	return &DaemonState{startedAt: time.Now(), jobs: newJobTracker(), passes: make(chan struct{}, 1)}
+}
+
+// DaemonStatus is a snapshot of DaemonState, as served by the daemon API.
+type DaemonStatus struct {
+	PID         int             `json:"pid"`
+	StartedAt   time.Time       `json:"started_at"`
+	Schedule    []ScheduleEntry `json:"schedule"`
+	NextPass    time.Time       `json:"next_pass,omitempty"`
+	PassRunning bool            `json:"pass_running"`
+	RunningJobs []string        `json:"running_jobs"` // Program names of the jobs being recorded.
+}
+
+func (s *DaemonState) setSchedule(entries []ScheduleEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schedule = entries
+}
+
+func (s *DaemonState) setPass(running bool, next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.passRunning = running
+	s.nextPass = next
+}
+
+// Status returns a snapshot of the state.
+func (s *DaemonState) Status() DaemonStatus {
+	s.mu.Lock()
+	status := DaemonStatus{
+		PID:         os.Getpid(),
+		StartedAt:   s.startedAt,
+		Schedule:    s.schedule,
+		NextPass:    s.nextPass,
+		PassRunning: s.passRunning,
+	}
+	s.mu.Unlock()
+	status.RunningJobs = s.jobs.programNames()
+	return status
+}
+
+// RunningJobs returns the recordings in progress.
+func (s *DaemonState) RunningJobs() []RunningJob {
+	return s.jobs.list()
+}
+
+// WatchJobs returns a channel of the progress of running jobs, starting with the jobs running
+// now, and a function to stop watching. Events a slow reader has no room for are dropped.
+func (s *DaemonState) WatchJobs() (<-chan JobEvent, func()) {
+	return s.jobs.watch()
+}
+
+// CancelJob cancels the running job with the given RunningJob.ID and reports whether there was one.
+func (s *DaemonState) CancelJob(id string) bool {
+	return s.jobs.cancelJob(id)
+}
+
+// RequestPass asks RunDaemon to start a pass right away, or right after the running one.
+func (s *DaemonState) RequestPass() {
+	select {
+	case s.passes <- struct{}{}:
+	default: // A pass is already requested.
+	}
+}
+
+// daemonTokenHeader carries the token from the discovery file; requests without it are rejected.
+const daemonTokenHeader = "X-Radirec-Token"
+
+// DaemonInfo is the content of the discovery file written by a running daemon.
+type DaemonInfo struct {
+	PID       int       `json:"pid"`
+	Network   string    `json:"network"` // "unix" or "tcp"
+	Address   string    `json:"address"`
+	Token     string    `json:"token"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// DaemonInfoPath returns the location of the discovery file in dataDir.
+func DaemonInfoPath(dataDir string) string {
+	return filepath.Join(dataDir, "daemon.json")
+}
+
+// listenDaemon listens on a unix socket in dataDir, or on a random localhost port
+// where unix sockets are unavailable (older Windows, or a socket path that is too long).
+func listenDaemon(dataDir string) (net.Listener, error) {
+	socketPath := filepath.Join(dataDir, "daemon.sock")
+	os.Remove(socketPath) // A leftover from a daemon that did not shut down cleanly.
+	if l, err := net.Listen("unix", socketPath); err == nil {
+		return l, nil
+	}
+	return net.Listen("tcp", "127.0.0.1:0")
+}
+
+// ServeDaemonAPI makes the daemon discoverable by CLI commands: it serves the daemon API
+// and writes the discovery file into dataDir. It fails if another daemon is already running.
+// The returned stop function shuts the API down and removes the discovery file.
+func ServeDaemonAPI(ctx context.Context, dataDir string, state *DaemonState, history *History, logger *log.Logger) (stop func(), err error) {
+	infoPath := DaemonInfoPath(dataDir)
+	if client, err := DiscoverDaemon(ctx, dataDir); err != nil {
+		return nil, err
+	} else if client != nil {
+		return nil, fmt.Errorf("another daemon is already running (pid %d)", client.Info.PID)
+	}
+
+	listener, err := listenDaemon(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for daemon API: %w", err)
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to generate daemon token: %w", err)
+	}
+	info := DaemonInfo{
+		PID:       os.Getpid(),
+		Network:   listener.Addr().Network(),
+		Address:   listener.Addr().String(),
+		Token:     hex.EncodeToString(token),
+		StartedAt: state.startedAt,
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to encode discovery file: %w", err)
+	}
+	// The token grants access to the daemon, so the file is readable by the owner only.
+	if err := os.WriteFile(infoPath, data, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to write discovery file '%s': %w", infoPath, err)
+	}
+
+	server := &http.Server{Handler: daemonHandler(info.Token, state, history)}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Printf("WARNING: Daemon API stopped: %v", err)
+		}
+	}()
+	return func() {
+		server.Close()
+		os.Remove(infoPath)
+		if info.Network == "unix" {
+			os.Remove(info.Address)
+		}
+	}, nil
+}
+
+// daemonHandler serves the daemon API:
+//
+//	GET /status       DaemonStatus
+//	GET /schedule     the schedule currently in use
+//	GET /jobs?n=N     the N most recent HistoryRecords (all when N is 0)
+//	GET /jobs/{id}    a single HistoryRecord
+//	GET /events       Server-Sent Events of the running jobs, see serveJobEvents
+//
+// As browsers' EventSource cannot send headers, /events also takes the token as ?token=.
+func daemonHandler(token string, state *DaemonState, history *History) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, state.Status())
+	})
+	mux.HandleFunc("GET /schedule", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, state.Status().Schedule)
+	})
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		records, err := history.Records()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if limit > 0 && len(records) > limit {
+			records = records[len(records)-limit:]
+		}
+		writeJSON(w, records)
+	})
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		rec, err := history.Find(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, rec)
+	})
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		serveJobEvents(w, r, state)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get(daemonTokenHeader)
+		if got == "" && r.URL.Path == "/events" {
+			got = r.URL.Query().Get("token")
+		}
+		if got != token {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// sseKeepAlive is how often a comment is sent on an idle event stream, so proxies and
+// clients do not time it out.
+const sseKeepAlive = 30 * time.Second
+
+// JobProgressEvent is the data of the events of GET /events: the state of a running job.
+type JobProgressEvent struct {
+	ID                string    `json:"id"` // Identifies the job while it runs: its station and broadcast start.
+	ProgramName       string    `json:"program_name"`
+	StationID         string    `json:"station_id"`
+	Start             time.Time `json:"start"` // Start of the broadcast.
+	ChunksDone        int       `json:"chunks_done"`
+	ChunksTotal       int       `json:"chunks_total"`
+	BytesDone         int64     `json:"bytes_done"`
+	AudioDoneSeconds  float64   `json:"audio_done_seconds,omitempty"`  // Broadcast time downloaded, when the playlist has durations.
+	AudioTotalSeconds float64   `json:"audio_total_seconds,omitempty"` // Broadcast time of the recording, when the playlist has durations.
+}
+
+// serveJobEvents streams the jobs of the daemon as Server-Sent Events until the client goes
+// away: a "started" event when a job starts, "progress" after each chunk (and once for each job
+// already running when the stream opens), and "finished" when it stops, successfully or not.
+// Events a slow client has no room for are dropped; the next progress event catches up.
+func serveJobEvents(w http.ResponseWriter, r *http.Request, state *DaemonState) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	events, stop := state.WatchJobs()
+	defer stop()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case ev := <-events:
+			name := "progress"
+			switch {
+			case ev.Started:
+				name = "started"
+			case ev.Finished:
+				name = "finished"
+			}
+			job := ev.Job
+			data, err := json.Marshal(JobProgressEvent{
+				ID:                job.ID,
+				ProgramName:       job.ProgramName,
+				StationID:         job.StationID,
+				Start:             job.Start,
+				ChunksDone:        job.Progress.ChunksDone,
+				ChunksTotal:       job.Progress.ChunksTotal,
+				BytesDone:         job.Progress.BytesDone,
+				AudioDoneSeconds:  job.Progress.AudioDone.Seconds(),
+				AudioTotalSeconds: job.Progress.AudioTotal.Seconds(),
+			})
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+		}
+		flusher.Flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// programNames returns the sorted program names of the running jobs.
+func (t *jobTracker) programNames() []string {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	names := make([]string, 0, len(t.running))
+	for job := range t.running {
+		names = append(names, job.entry.ProgramName)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemonDiscovery(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+
+	if client, err := DiscoverDaemon(ctx, dataDir); err != nil || client != nil {
+		t.Fatalf("DiscoverDaemon without daemon = (%v, %v), want (nil, nil)", client, err)
+	}
+
+	history := OpenHistory(filepath.Join(dataDir, "history.jsonl"))
+	if err := history.Append(HistoryRecord{ID: "abcd1234", ProgramName: "P", Status: StatusSuccess}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	state := NewDaemonState()
+	state.setSchedule([]ScheduleEntry{{ProgramName: "P", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}})
+	_, _, done := state.jobs.start(ctx, ScheduleEntry{ProgramName: "P"}, time.Time{}, false)
+	defer done()
+
+	stop, err := ServeDaemonAPI(ctx, dataDir, state, history, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("ServeDaemonAPI failed: %v", err)
+	}
+
+	client, err := DiscoverDaemon(ctx, dataDir)
+	if err != nil || client == nil {
+		t.Fatalf("DiscoverDaemon = (%v, %v), want a client", client, err)
+	}
+	if client.Info.PID != os.Getpid() {
+		t.Errorf("discovered pid %d, want %d", client.Info.PID, os.Getpid())
+	}
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(status.Schedule) != 1 || len(status.RunningJobs) != 1 || status.RunningJobs[0] != "P" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if records, err := client.Jobs(ctx, 10); err != nil || len(records) != 1 {
+		t.Errorf("Jobs = (%+v, %v), want one record", records, err)
+	}
+	if rec, err := client.Job(ctx, "abcd1234"); err != nil || rec.ProgramName != "P" {
+		t.Errorf("Job = (%+v, %v)", rec, err)
+	}
+	if _, err := client.Job(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected 404 for unknown job, got %v", err)
+	}
+
+	// Requests without the token from the discovery file are rejected.
+	client.Info.Token = "wrong"
+	if _, err := client.Status(ctx); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected 401 with a wrong token, got %v", err)
+	}
+
+	if _, err := ServeDaemonAPI(ctx, dataDir, state, history, log.New(&bytes.Buffer{}, "", 0)); err == nil {
+		t.Error("expected a second daemon to be refused")
+	}
+
+	stop()
+	if _, err := os.Stat(DaemonInfoPath(dataDir)); !os.IsNotExist(err) {
+		t.Errorf("expected discovery file to be removed on stop: %v", err)
+	}
+}
+
+func TestDiscoverDaemonRemovesStaleFile(t *testing.T) {
+	dataDir := t.TempDir()
+	stale := `{"pid": 1, "network": "tcp", "address": "127.0.0.1:1", "token": "x", "started_at": "` + time.Now().Format(time.RFC3339) + `"}`
+	if err := os.WriteFile(DaemonInfoPath(dataDir), []byte(stale), 0600); err != nil {
+		t.Fatalf("Failed to write discovery file: %v", err)
+	}
+
+	client, err := DiscoverDaemon(context.Background(), dataDir)
+	if err != nil || client != nil {
+		t.Fatalf("DiscoverDaemon = (%v, %v), want (nil, nil)", client, err)
+	}
+	if _, err := os.Stat(DaemonInfoPath(dataDir)); !os.IsNotExist(err) {
+		t.Errorf("expected stale discovery file to be removed: %v", err)
+	}
+}
+
+func TestDaemonJobEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	state := NewDaemonState()
+	server := httptest.NewServer(daemonHandler("secret", state, OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"))))
+	defer server.Close()
+
+	if resp, err := http.Get(server.URL + "/events"); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a stream without the token to be rejected, got (%v, %v)", resp, err)
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events?token=secret", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	// The response headers are flushed once the handler watches the jobs.
+	start := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	_, progress, done := state.jobs.start(ctx, ScheduleEntry{ProgramName: "P", StationID: "ST1"}, start, false)
+	progress(ProgressUpdate{ChunksDone: 3, ChunksTotal: 10, BytesDone: 300, AudioDone: 15 * time.Second, AudioTotal: 50 * time.Second})
+	done()
+
+	reader := bufio.NewReader(resp.Body)
+	var names []string
+	var last JobProgressEvent
+	for len(names) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the stream failed after %v: %v", names, err)
+		}
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			names = append(names, strings.TrimSpace(name))
+		} else if data, ok := strings.CutPrefix(line, "data: "); ok && len(names) == 2 {
+			if err := json.Unmarshal([]byte(data), &last); err != nil {
+				t.Fatalf("invalid event data %q: %v", data, err)
+			}
+		}
+	}
+	if want := []string{"started", "progress", "finished"}; !slices.Equal(names, want) {
+		t.Errorf("events = %v, want %v", names, want)
+	}
+	want := JobProgressEvent{ID: broadcastKey("ST1", start), ProgramName: "P", StationID: "ST1", Start: start,
+		ChunksDone: 3, ChunksTotal: 10, BytesDone: 300, AudioDoneSeconds: 15, AudioTotalSeconds: 50}
+	if !last.Start.Equal(want.Start) {
+		t.Errorf("start = %v, want %v", last.Start, want.Start)
+	}
+	last.Start = want.Start
+	if last != want {
+		t.Errorf("progress event = %+v, want %+v", last, want)
+	}
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DaemonClient talks to a running daemon through the address in its discovery file.
+type DaemonClient struct {
+	Info   DaemonInfo
+	client *http.Client
+}
+
+// DiscoverDaemon returns a client for the daemon running for dataDir, or nil if none is running.
+// A discovery file left behind by a daemon that is no longer reachable is removed.
+func DiscoverDaemon(ctx context.Context, dataDir string) (*DaemonClient, error) {
+	infoPath := DaemonInfoPath(dataDir)
+	data, err := os.ReadFile(infoPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read discovery file '%s': %w", infoPath, err)
+	}
+	var info DaemonInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		os.Remove(infoPath)
+		return nil, nil
+	}
+
+	dialer := &net.Dialer{Timeout: 2 * time.Second}
+	c := &DaemonClient{
+		Info: info,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, info.Network, info.Address)
+				},
+			},
+		},
+	}
+	if _, err := c.Status(ctx); err != nil {
+		os.Remove(infoPath) // Stale: the daemon exited without cleaning up.
+		return nil, nil
+	}
+	return c, nil
+}
+
+// get fetches path from the daemon API and decodes the JSON response into v.
+func (c *DaemonClient) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://daemon"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(daemonTokenHeader, c.Info.Token)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach daemon: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var msg [512]byte
+		n, _ := resp.Body.Read(msg[:])
+		return fmt.Errorf("daemon returned status %d: %s", resp.StatusCode, string(msg[:n]))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Status returns the daemon's current state.
+func (c *DaemonClient) Status(ctx context.Context) (DaemonStatus, error) {
+	var status DaemonStatus
+	err := c.get(ctx, "/status", &status)
+	return status, err
+}
+
+// Schedule returns the schedule the daemon is currently using.
+func (c *DaemonClient) Schedule(ctx context.Context) ([]ScheduleEntry, error) {
+	var entries []ScheduleEntry
+	err := c.get(ctx, "/schedule", &entries)
+	return entries, err
+}
+
+// Jobs returns the limit most recent history records (all when limit is 0).
+func (c *DaemonClient) Jobs(ctx context.Context, limit int) ([]HistoryRecord, error) {
+	var records []HistoryRecord
+	err := c.get(ctx, "/jobs?n="+strconv.Itoa(limit), &records)
+	return records, err
+}
+
+// Job returns the history record with the given ID.
+func (c *DaemonClient) Job(ctx context.Context, id string) (HistoryRecord, error) {
+	var rec HistoryRecord
+	err := c.get(ctx, "/jobs/"+url.PathEscape(id), &rec)
+	return rec, err
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// recordingBytesPerSecond is the size of downloaded audio assumed when checking free space:
+// radiko and NHK stream AAC at 48 kbps, rounded up to 64 kbps for headroom.
+const recordingBytesPerSecond = 64_000 / 8
+
+// assumedChunkDuration stands in for chunks of playlists without durations.
+const assumedChunkDuration = 5 * time.Second
+
+// audioBytesPerSecond is the size of the 48 kbps AAC streams of radiko and NHK, for the
+// estimate logged before downloading.
+const audioBytesPerSecond = 48_000 / 8
+
+// overlongSlack is how much longer than the broadcast the audio of a playlist may be, beyond half
+// the broadcast, before logDownloadEstimate warns about it.
+const overlongSlack = 10 * time.Minute
+
+var errDiskFreeUnsupported = errors.New("free space not available on this platform")
+
+// recordingSpaceNeeded estimates the size of the recording of chunks.
+func recordingSpaceNeeded(chunks []Chunk) uint64 {
+	audio := chunksDuration(chunks)
+	if audio <= 0 {
+		audio = time.Duration(len(chunks)) * assumedChunkDuration
+	}
+	return uint64(audio.Seconds() * recordingBytesPerSecond)
+}
+
+// logDownloadEstimate logs what downloading the chunks of the recording of name amounts to, the number of chunks, the length
+// of their audio and its approximate size, next to the length of the broadcast expected (0 if
+// unknown), for users to sanity-check. Audio much longer than the broadcast, as a wrong start time
+// or time zone would give, is logged as a warning.
+func logDownloadEstimate(logger *log.Logger, name string, chunks []Chunk, expected time.Duration) {
+	audio := chunksDuration(chunks)
+	estimated := audio
+	if estimated <= 0 {
+		estimated = time.Duration(len(chunks)) * assumedChunkDuration
+	}
+	msg := fmt.Sprintf("Found %d audio chunks", len(chunks))
+	if audio > 0 {
+		msg += fmt.Sprintf(" (%s)", formatClock(audio))
+	}
+	msg += fmt.Sprintf(", about %s to download", formatBytes(int64(estimated.Seconds()*audioBytesPerSecond)))
+	if expected > 0 {
+		msg += fmt.Sprintf(", for a broadcast of %s", formatClock(expected))
+	}
+	logger.Printf("INFO: %s.", msg)
+	if expected > 0 && estimated > expected+expected/2+overlongSlack {
+		logger.Printf("WARNING: The playlist holds %s of audio, much more than the %s broadcast; check the start time and time zone of '%s'.", formatClock(estimated), formatClock(expected), name)
+	}
+}
+
+// checkFreeSpace returns an error when dir has less than need bytes available.
+// Platforms that cannot tell the free space pass.
+func checkFreeSpace(dir string, need uint64) error {
+	free, err := diskFree(dir)
+	if errors.Is(err, errDiskFreeUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check free space in '%s': %w", dir, err)
+	}
+	if free < need {
+		return fmt.Errorf("not enough space in '%s' for the recording: about %s needed, %s available",
+			dir, formatBytes(int64(need)), formatBytes(int64(free)))
+	}
+	return nil
+}
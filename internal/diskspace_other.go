@@ -0,0 +1,8 @@
+//go:build !linux
+
+package internal
+
+// diskFree is only implemented on Linux; elsewhere the free space check is skipped.
+func diskFree(dir string) (uint64, error) {
+	return 0, errDiskFreeUnsupported
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordingSpaceNeeded(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []Chunk
+		want   uint64
+	}{
+		{"durations", []Chunk{{Duration: 3 * time.Hour}}, 3 * 60 * 60 * recordingBytesPerSecond},
+		{"no durations", make([]Chunk, 12), 60 * recordingBytesPerSecond},
+		{"empty", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := recordingSpaceNeeded(tt.chunks); got != tt.want {
+				t.Errorf("recordingSpaceNeeded = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogDownloadEstimate(t *testing.T) {
+	halfHour := make([]Chunk, 360)
+	for i := range halfHour {
+		halfHour[i].Duration = 5 * time.Second
+	}
+	tests := []struct {
+		name     string
+		chunks   []Chunk
+		expected time.Duration
+		want     string
+	}{
+		{"as the broadcast", halfHour, 30 * time.Minute, "INFO: Found 360 audio chunks (0:30:00), about 10.3 MB to download, for a broadcast of 0:30:00.\n"},
+		{"no durations", make([]Chunk, 12), 0, "INFO: Found 12 audio chunks, about 351.6 KB to download.\n"},
+		{"much longer than the broadcast", []Chunk{{Duration: 4 * time.Hour}}, 30 * time.Minute, "INFO: Found 1 audio chunks (4:00:00), about 82.4 MB to download, for a broadcast of 0:30:00.\n" +
+			"WARNING: The playlist holds 4:00:00 of audio, much more than the 0:30:00 broadcast; check the start time and time zone of 'Show'.\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logDownloadEstimate(log.New(&logs, "", 0), "Show", tt.chunks, tt.expected)
+			if logs.String() != tt.want {
+				t.Errorf("logged %q, want %q", logs.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkFreeSpace(dir, 1); err != nil {
+		t.Errorf("expected room for a byte: %v", err)
+	}
+	if _, err := diskFree(dir); err != nil {
+		t.Skipf("free space not available: %v", err)
+	}
+	if err := checkFreeSpace(dir, 1<<62); err == nil || !strings.Contains(err.Error(), "not enough space") {
+		t.Errorf("expected a shortage, got %v", err)
+	}
+}
+
+func TestExecuteJobTempDir(t *testing.T) {
+	tempDir := filepath.Join(t.TempDir(), "spool")
+	var logs bytes.Buffer
+	opts := JobOptions{
+		OutputDir: t.TempDir(),
+		TempDir:   tempDir,
+		Logger:    log.New(&logs, "", 0),
+
+		DisableProgressBar: true,
+	}
+	entry := ScheduleEntry{ProgramName: "Show", StationID: "ST1"}
+	if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, time.Date(2026, time.January, 12, 10, 0, 0, 0, JST), opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+
+	if !strings.Contains(logs.String(), "Created temporary directory: "+filepath.Join(tempDir, "radikoRecScheduler-chunks-")) {
+		t.Errorf("expected the chunks under %s, got:\n%s", tempDir, logs.String())
+	}
+	if left, err := os.ReadDir(tempDir); err != nil || len(left) != 0 {
+		t.Errorf("expected an empty temporary directory, got %v (%v)", left, err)
+	}
+}
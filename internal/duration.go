@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultDurationTolerance is how much shorter than the broadcast a recording may be when
+// DurationCheckConfig.ToleranceSeconds is 0.
+const DefaultDurationTolerance = time.Minute
+
+// DurationCheckConfig compares the length of each recording, counted from its AAC frames, with
+// the length of the broadcast in the guide (config "duration_check"), to catch recordings
+// truncated by chunks missing from the playlist.
+type DurationCheckConfig struct {
+	ToleranceSeconds int  `json:"tolerance_seconds"` // 0 means DefaultDurationTolerance; negative disables the check.
+	Fail             bool `json:"fail"`              // Fail the job, to be retried, instead of only logging a warning.
+}
+
+// tolerance returns how much shorter than the broadcast a recording may be, or a negative
+// duration if the check is disabled.
+func (c DurationCheckConfig) tolerance() time.Duration {
+	if c.ToleranceSeconds == 0 {
+		return DefaultDurationTolerance
+	}
+	return time.Duration(c.ToleranceSeconds) * time.Second
+}
+
+// verify measures the parts of a recording of a broadcast expected to last expected.
+// A recording short by more than the tolerance is an error with Fail set, and a warning
+// otherwise. A recording that cannot be measured is logged and passes.
+func (c DurationCheckConfig) verify(logger *log.Logger, parts []recordingPart, expected time.Duration) error {
+	tolerance := c.tolerance()
+	if tolerance < 0 || expected <= 0 {
+		return nil
+	}
+	var got time.Duration
+	for _, part := range parts {
+		d, err := fileDuration(part.Path)
+		if err != nil {
+			logger.Printf("WARNING: Failed to measure the length of the recording: %v", err)
+			return nil
+		}
+		got += d
+	}
+	if got >= expected-tolerance {
+		logger.Printf("INFO: The recording is %s long, as the broadcast in the guide (%s).", formatClock(got), formatClock(expected))
+		return nil
+	}
+	short := fmt.Sprintf("%s long, %s shorter than the broadcast in the guide (%s)", formatClock(got), formatClock(expected-got), formatClock(expected))
+	if c.Fail {
+		return fmt.Errorf("the recording is %s", short)
+	}
+	logger.Printf("WARNING: The recording is %s; chunks may be missing from the playlist.", short)
+	return nil
+}
+
+// expectedDuration returns the length of the recording of the broadcast at start: up to end
+// for a fixed window, else the length of prog in the guide, or 0 if unknown.
+func expectedDuration(prog *Prog, start, end time.Time) time.Duration {
+	if !end.IsZero() {
+		return end.Sub(start)
+	}
+	if prog == nil {
+		return 0
+	}
+	seconds, err := strconv.Atoi(prog.Dur)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// fileDuration returns the length of the ADTS stream in the file at path.
+func fileDuration(path string) (time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	d, err := adtsDuration(f)
+	if err != nil {
+		return 0, fmt.Errorf("'%s': %w", path, err)
+	}
+	return d, nil
+}
+
+// adtsSampleRates are the sampling frequencies by the index in an ADTS header.
+var adtsSampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// errNoADTS is returned by adtsDuration for data without any AAC frames.
+var errNoADTS = errors.New("no ADTS frames found")
+
+// adtsDuration returns the length of the ADTS stream read from r by counting its frames of
+// 1024 samples each. ID3 tags, which radiko puts at the start of every chunk and so are found
+// throughout a recording, are skipped, as are bytes that do not start a frame.
+func adtsDuration(r io.Reader) (time.Duration, error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	samples := make(map[int]int64) // By sampling frequency.
+scan:
+	for {
+		head, err := br.Peek(10)
+		if len(head) < 7 {
+			if err != nil && !errors.Is(err, io.EOF) {
+				return 0, err
+			}
+			break scan
+		}
+		switch {
+		case len(head) == 10 && string(head[:3]) == "ID3":
+			if _, err := br.Discard(int(id3TagSize(head))); err != nil {
+				break scan
+			}
+			continue
+		case isADTSSync(head):
+			length := int(head[3]&0x03)<<11 | int(head[4])<<3 | int(head[5])>>5
+			rate := int(head[2]>>2) & 0x0f
+			if length >= 7 && rate < len(adtsSampleRates) {
+				if n, _ := br.Discard(length); n < length {
+					break scan // A frame cut off at the end.
+				}
+				samples[adtsSampleRates[rate]] += 1024 * (int64(head[6]&0x03) + 1)
+				continue
+			}
+		}
+		br.Discard(1)
+	}
+	if len(samples) == 0 {
+		return 0, errNoADTS
+	}
+	var d time.Duration
+	for rate, n := range samples {
+		d += time.Duration(n) * time.Second / time.Duration(rate)
+	}
+	return d, nil
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// adtsFrames returns n ADTS frames of 48 kHz AAC with a payload of 10 bytes each.
+func adtsFrames(n int) []byte {
+	const length = 7 + 10
+	frame := []byte{0xff, 0xf1, 0x4c, 0x80, byte(length >> 3), byte(length&0x07)<<5 | 0x1f, 0xfc}
+	frame = append(frame, make([]byte, 10)...)
+	return bytes.Repeat(frame, n)
+}
+
+func TestADTSDuration(t *testing.T) {
+	eight := adtsFrames(375) // 375 frames of 1024 samples at 48 kHz last 8 seconds.
+	tests := []struct {
+		name    string
+		data    []byte
+		want    time.Duration
+		wantErr error
+	}{
+		{name: "frames", data: eight, want: 8 * time.Second},
+		{name: "ID3 tags between chunks", data: bytes.Join([][]byte{id3Tag(200), eight, id3Tag(63), eight}, nil), want: 16 * time.Second},
+		{name: "garbage and a cut-off frame", data: bytes.Join([][]byte{[]byte("junk"), eight, adtsFrames(1)[:9]}, nil), want: 8 * time.Second},
+		{name: "no frames", data: []byte("<html>503</html>"), wantErr: errNoADTS},
+		{name: "empty", wantErr: errNoADTS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := adtsDuration(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("adtsDuration error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("adtsDuration = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationCheckVerify(t *testing.T) {
+	dir := t.TempDir()
+	var parts []recordingPart
+	for i := range 2 {
+		path := filepath.Join(dir, "part"+string(rune('1'+i))+".aac")
+		if err := os.WriteFile(path, adtsFrames(375*8), 0644); err != nil { // 64 seconds each.
+			t.Fatal(err)
+		}
+		parts = append(parts, recordingPart{Path: path})
+	}
+
+	tests := []struct {
+		name     string
+		config   DurationCheckConfig
+		expected time.Duration
+		wantErr  bool
+		wantLog  string
+	}{
+		{name: "complete", expected: 2*time.Minute + 8*time.Second, wantLog: "INFO: The recording is 0:02:08 long"},
+		{name: "within the default tolerance", expected: 3 * time.Minute, wantLog: "INFO: The recording is 0:02:08 long"},
+		{name: "short", expected: 5 * time.Minute, wantLog: "WARNING: The recording is 0:02:08 long, 0:02:52 shorter than the broadcast in the guide (0:05:00)"},
+		{name: "short with fail", config: DurationCheckConfig{Fail: true}, expected: 5 * time.Minute, wantErr: true},
+		{name: "custom tolerance", config: DurationCheckConfig{ToleranceSeconds: 10, Fail: true}, expected: 2*time.Minute + 30*time.Second, wantErr: true},
+		{name: "disabled", config: DurationCheckConfig{ToleranceSeconds: -1, Fail: true}, expected: time.Hour},
+		{name: "unknown length", config: DurationCheckConfig{Fail: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			err := tt.config.verify(log.New(&logs, "", 0), parts, tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verify error = %v, want error %v", err, tt.wantErr)
+			}
+			if !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("expected the log to contain %q, got:\n%s", tt.wantLog, logs.String())
+			}
+		})
+	}
+}
+
+func TestExecuteJobShortRecording(t *testing.T) {
+	const guide = `<radiko><stations><station id="ST1"><progs>
+<prog ft="20260112100000" to="20260112110000" ftl="1000" tol="1100" dur="3600"><title>Test Program</title></prog>
+</progs></station></stations></radiko>`
+	client := &MockRadikoClient{DoFn: func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(adtsFrames(375)))}, nil
+	}}
+	outputDir := t.TempDir()
+	opts := JobOptions{
+		OutputDir:     outputDir,
+		Logger:        log.New(io.Discard, "", 0),
+		FetchGuide:    func(context.Context, string) ([]byte, error) { return []byte(guide), nil },
+		DurationCheck: DurationCheckConfig{Fail: true},
+
+		DisableProgressBar: true,
+	}
+	entry := ScheduleEntry{ProgramName: "Test Program", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+
+	_, err := ExecuteJob(context.Background(), client, entry, pastTime, opts)
+	if err == nil || !strings.Contains(err.Error(), "incomplete recording of Test Program: the recording is 0:00:16 long") {
+		t.Fatalf("expected the short recording to fail, got %v", err)
+	}
+	if files, _ := os.ReadDir(outputDir); len(files) != 0 {
+		t.Errorf("expected no recording to be left, found %v", files)
+	}
+}
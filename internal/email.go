@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmailConfig configures delivery of notifications by email (config "notifications.email").
+type EmailConfig struct {
+	Host     string   `json:"smtp_host"`
+	Port     int      `json:"smtp_port"` // Defaults to 587 (STARTTLS); 465 uses implicit TLS.
+	Username string   `json:"username"`  // Optional; enables SMTP authentication.
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// defaultSMTPPort is the submission port, on which STARTTLS is used when offered.
+const defaultSMTPPort = 587
+
+// implicitTLSPort is the submission port that expects TLS from the start.
+const implicitTLSPort = 465
+
+// validate checks that a configured email notifier can address its messages.
+func (c EmailConfig) validate() error {
+	if c.Host == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("invalid from address '%s': %w", c.From, err)
+	}
+	if len(c.To) == 0 {
+		return fmt.Errorf("no recipients in to")
+	}
+	for _, to := range c.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid to address '%s': %w", to, err)
+		}
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid smtp_port %d", c.Port)
+	}
+	return nil
+}
+
+// EmailNotifier sends notifications as plain-text email through an SMTP server.
+type EmailNotifier struct {
+	Config EmailConfig
+	Now    func() time.Time // Date of the messages. Defaults to time.Now.
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, subject, body string) error {
+	now := time.Now
+	if n.Now != nil {
+		now = n.Now
+	}
+	msg, err := emailMessage(n.Config, subject, body, now())
+	if err != nil {
+		return fmt.Errorf("failed to build email: %w", err)
+	}
+	if err := sendMail(ctx, n.Config, msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// emailMessage builds an RFC 5322 message with a UTF-8, quoted-printable body,
+// as program titles are usually Japanese.
+func emailMessage(cfg EmailConfig, subject, body string, date time.Time) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// sendMail delivers msg like smtp.SendMail, but honours ctx and supports implicit TLS.
+// STARTTLS is used whenever the server offers it.
+func sendMail(ctx context.Context, cfg EmailConfig, msg []byte) error {
+	port := cfg.Port
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host}
+
+	var conn net.Conn
+	var err error
+	if port == implicitTLSPort {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	// Abort the conversation when ctx is cancelled; smtp.Client itself has no deadlines.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && port != implicitTLSPort {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return err
+		}
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return err
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range cfg.To {
+		rcpt, err := mail.ParseAddress(to)
+		if err != nil {
+			return err
+		}
+		if err := client.Rcpt(rcpt.Address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer accepts one message without TLS or authentication and returns
+// the envelope and data it received.
+type fakeSMTPServer struct {
+	addr     string
+	received chan smtpMessage
+}
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &fakeSMTPServer{addr: ln.Addr().String(), received: make(chan smtpMessage, 1)}
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		var msg smtpMessage
+		tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch verb {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "MAIL":
+				msg.from = strings.TrimPrefix(line, "MAIL FROM:")
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				msg.to = append(msg.to, strings.TrimPrefix(line, "RCPT TO:"))
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 Go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				msg.data = string(data)
+				tp.PrintfLine("250 OK")
+				s.received <- msg
+			case "QUIT":
+				tp.PrintfLine("221 Bye")
+				return
+			default:
+				tp.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+	return s
+}
+
+func TestEmailNotifier(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(server.addr)
+	portNum, _ := strconv.Atoi(port)
+
+	notifier := &EmailNotifier{
+		Config: EmailConfig{Host: host, Port: portNum, From: "Recorder <rec@example.com>", To: []string{"me@example.com", "you@example.com"}},
+		Now:    func() time.Time { return time.Date(2026, time.January, 12, 12, 0, 0, 0, JST) },
+	}
+	if err := notifier.Notify(context.Background(), "録音レポート", "オールナイトニッポン: ok\nsecond line"); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	var msg smtpMessage
+	select {
+	case msg = <-server.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	if msg.from != "<rec@example.com>" || strings.Join(msg.to, ",") != "<me@example.com>,<you@example.com>" {
+		t.Errorf("unexpected envelope: %q -> %q", msg.from, msg.to)
+	}
+
+	header, body, _ := strings.Cut(msg.data, "\n\n")
+	for _, want := range []string{
+		"From: Recorder <rec@example.com>",
+		"To: me@example.com, you@example.com",
+		"Subject: =?utf-8?q?",
+		"Date: Mon, 12 Jan 2026 12:00:00 +0900",
+		"Content-Type: text/plain; charset=UTF-8",
+	} {
+		if !strings.Contains(header, want) {
+			t.Errorf("header missing %q:\n%s", want, header)
+		}
+	}
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	if err != nil || string(decoded) != "オールナイトニッポン: ok\nsecond line\n" {
+		t.Errorf("unexpected body %q (%v)", decoded, err)
+	}
+}
+
+func TestEmailConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     EmailConfig
+		wantErr bool
+	}{
+		{"disabled", EmailConfig{}, false},
+		{"valid", EmailConfig{Host: "smtp.example.com", From: "rec@example.com", To: []string{"me@example.com"}}, false},
+		{"no recipients", EmailConfig{Host: "smtp.example.com", From: "rec@example.com"}, true},
+		{"bad from", EmailConfig{Host: "smtp.example.com", From: "recorder", To: []string{"me@example.com"}}, true},
+		{"bad port", EmailConfig{Host: "smtp.example.com", Port: 70000, From: "rec@example.com", To: []string{"me@example.com"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts the names of the environment variables that override settings of config.json.
+const EnvPrefix = "RADIREC_"
+
+const (
+	// ConfigPathEnv overrides the location of config.json.
+	ConfigPathEnv = EnvPrefix + "CONFIG"
+	// SchedulePathEnv names a schedule file used instead of the schedule of config.json.
+	SchedulePathEnv = EnvPrefix + "SCHEDULE"
+)
+
+// ApplyEnv overrides the settings of c with the environment variables found by lookup, such as
+// os.LookupEnv. Each setting is named after its JSON key path, upper-cased and joined by
+// underscores after EnvPrefix: RADIREC_OUTPUT_DIR for output_dir, RADIREC_RADIKO_MAIL for
+// radiko.mail. Strings, numbers and booleans are given as is, lists of strings comma-separated,
+// and other lists and objects as JSON. The schedule itself cannot be set this way; see
+// SchedulePathEnv instead.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	return applyEnv(reflect.ValueOf(c).Elem(), strings.TrimSuffix(EnvPrefix, "_"), lookup)
+}
+
+// applyEnv sets the fields of the struct v from the variables named prefix_KEY.
+func applyEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || key == "" || key == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(key)
+		if name == SchedulePathEnv {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(v.Field(i), name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setEnvValue parses value into the field v.
+func setEnvValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			v.Set(reflect.ValueOf(items).Convert(v.Type()))
+			return nil
+		}
+		return json.Unmarshal([]byte(value), v.Addr().Interface())
+	}
+	return nil
+}
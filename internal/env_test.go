@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"RADIREC_OUTPUT_DIR":                      "/srv/radio",
+		"RADIREC_CONCURRENCY":                     "3",
+		"RADIREC_CHUNK_RATE":                      "2.5",
+		"RADIREC_CHAPTERS":                        "true",
+		"RADIREC_RADIKO_MAIL":                     "user@example.com",
+		"RADIREC_NOTIFICATIONS_EMAIL_TO":          "a@example.com, b@example.com",
+		"RADIREC_POST_STORE_S3_BUCKET":            "recordings",
+		"RADIREC_SUBSCRIPTIONS":                   `[{"keyword": "オードリー"}]`,
+		"RADIREC_SCHEDULE":                        "/etc/radirec/schedule.yaml", // A path, not the schedule.
+		"RADIREC_NETWORK_REQUEST_TIMEOUT_SECONDS": "30",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	cfg := DefaultConfig()
+	cfg.PostCommand = "kept"
+	if err := cfg.ApplyEnv(lookup); err != nil {
+		t.Fatalf("ApplyEnv failed: %v", err)
+	}
+	if cfg.OutputDir != "/srv/radio" || cfg.Concurrency != 3 || cfg.ChunkRate != 2.5 || !cfg.Chapters || cfg.PostCommand != "kept" {
+		t.Errorf("unexpected top-level settings: %+v", cfg)
+	}
+	if cfg.Radiko.Mail != "user@example.com" || cfg.PostStore.S3.Bucket != "recordings" || cfg.Network.RequestTimeoutSeconds != 30 {
+		t.Errorf("unexpected nested settings: %+v", cfg)
+	}
+	if want := []string{"a@example.com", "b@example.com"}; !reflect.DeepEqual(cfg.Notifications.Email.To, want) {
+		t.Errorf("email.to = %v, want %v", cfg.Notifications.Email.To, want)
+	}
+	if len(cfg.Subscriptions) != 1 || cfg.Subscriptions[0].Keyword != "オードリー" || len(cfg.Schedule) != 0 {
+		t.Errorf("unexpected subscriptions or schedule: %+v %+v", cfg.Subscriptions, cfg.Schedule)
+	}
+
+	env = map[string]string{"RADIREC_CHAPTERS": "maybe"}
+	if err := DefaultConfig().ApplyEnv(lookup); err == nil || !strings.Contains(err.Error(), "RADIREC_CHAPTERS") {
+		t.Errorf("expected an error naming the variable, got %v", err)
+	}
+}
+
+func TestLoadEffectiveConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"output_dir": "/from/file", "chapters": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"RADIREC_OUTPUT_DIR": "/from/env"}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	cfg, err := Profile("").LoadEffectiveConfig(path, lookup)
+	if err != nil || cfg.OutputDir != "/from/env" || !cfg.Chapters {
+		t.Errorf("expected the environment over the file, got %+v (%v)", cfg, err)
+	}
+	if cfg, err := LoadConfig(path); err != nil || cfg.OutputDir != "/from/file" {
+		t.Errorf("expected LoadConfig to ignore the environment, got %+v (%v)", cfg, err)
+	}
+	if cfg, err := Profile("").LoadEffectiveConfig(filepath.Join(t.TempDir(), "missing.json"), lookup); err != nil || cfg.OutputDir != "/from/env" {
+		t.Errorf("expected the environment without a config file, got %+v (%v)", cfg, err)
+	}
+
+	env["RADIREC_OUTPUT_LAYOUT"] = "bogus"
+	if _, err := Profile("").LoadEffectiveConfig(path, lookup); err == nil || !strings.Contains(err.Error(), "output_layout") {
+		t.Errorf("expected settings from the environment to be validated, got %v", err)
+	}
+}
+
+func TestConfigPathOverrides(t *testing.T) {
+	t.Setenv(ConfigPathEnv, "/etc/radirec/config.json")
+	if path, err := Profile("").ConfigPath(); err != nil || path != "/etc/radirec/config.json" {
+		t.Errorf("expected the path from the environment, got %q (%v)", path, err)
+	}
+	SetConfigPath("/run/config.json")
+	t.Cleanup(func() { SetConfigPath("") })
+	if path, err := Profile("").ConfigPath(); err != nil || path != "/run/config.json" {
+		t.Errorf("expected the flag to take precedence, got %q (%v)", path, err)
+	}
+}
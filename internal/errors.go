@@ -0,0 +1,36 @@
+package internal
+
+import "errors"
+
+// Failure classes of a recording job. The errors of ExecuteJob, RunOnce and the other job
+// functions wrap the class of their cause, so callers can branch with errors.Is instead of
+// matching messages. ErrOutsideTimeshift and ErrNotAired are classes too.
+var (
+	// ErrAuthFailed is a failed authentication with the provider, e.g. a radiko account
+	// that cannot log in or a client outside radiko's area.
+	ErrAuthFailed = errors.New("authentication failed")
+	// ErrStationNotFound is a station ID the provider does not know, or does not serve in
+	// the client's area.
+	ErrStationNotFound = errors.New("station not found")
+	// ErrChunkDownload is an audio chunk that could not be downloaded or verified, see
+	// ChunkDownloadError and ChunkIntegrityError for the chunks concerned.
+	ErrChunkDownload = errors.New("chunk download failed")
+)
+
+// classifiedError adds a failure class to err without changing its message.
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string   { return e.err.Error() }
+func (e *classifiedError) Unwrap() []error { return []error{e.class, e.err} }
+
+// classify returns err marked with class for errors.Is, or err itself if it is nil or already
+// of that class.
+func classify(class, err error) error {
+	if err == nil || errors.Is(err, class) {
+		return err
+	}
+	return &classifiedError{class: class, err: err}
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// fallbackEntry returns the schedule entry recording the slot of entry on station instead.
+func fallbackEntry(entry ScheduleEntry, station string) ScheduleEntry {
+	fallback := entry
+	fallback.StationID = station
+	fallback.FallbackStations = nil
+	return fallback
+}
+
+// executeJobWithFallbacks records entry at pastTime and, if that fails, the same slot on each of
+// its FallbackStations in turn until one succeeds. The error of the entry's own station is
+// returned, with those of the fallbacks, when none could be recorded.
+func executeJobWithFallbacks(ctx context.Context, client RadikoClient, entry ScheduleEntry, pastTime time.Time, opts JobOptions) (RecordingResult, error) {
+	result, err := ExecuteJob(ctx, client, entry, pastTime, opts)
+	if err == nil {
+		return result, nil
+	}
+	logger := opts.logger()
+	var failed []string
+	for _, station := range entry.FallbackStations {
+		if ctx.Err() != nil {
+			break
+		}
+		logger.Printf("INFO: Recording '%s' on %s failed, recording the same slot on %s instead.", entry.ProgramName, entry.StationID, station)
+		fallbackResult, fallbackErr := ExecuteJob(ctx, client, fallbackEntry(entry, station), pastTime, opts)
+		if fallbackErr == nil {
+			return fallbackResult, nil
+		}
+		failed = append(failed, fmt.Sprintf("%s: %v", station, fallbackErr))
+	}
+	if len(failed) > 0 {
+		return result, fmt.Errorf("%w (fallback stations also failed: %s)", err, strings.Join(failed, "; "))
+	}
+	return result, err
+}
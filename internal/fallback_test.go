@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteJobWithFallbacks(t *testing.T) {
+	pastTime := time.Date(2026, time.January, 10, 1, 0, 0, 0, JST)
+	entry := ScheduleEntry{
+		ProgramName:      "Test Program",
+		DayOfWeek:        "土",
+		StartTime:        "010000",
+		StationID:        "ST1",
+		FallbackStations: []string{"ST2", "ST3"},
+	}
+	tests := []struct {
+		name          string
+		available     map[string]bool
+		wantRequested []string
+		wantOutput    string
+		wantErr       string
+	}{
+		{name: "primary", available: map[string]bool{"ST1": true, "ST2": true}, wantRequested: []string{"ST1"}, wantOutput: "20260110010000-ST1-Test Program.aac"},
+		{name: "first fallback", available: map[string]bool{"ST2": true, "ST3": true}, wantRequested: []string{"ST1", "ST2"}, wantOutput: "20260110010000-ST2-Test Program.aac"},
+		{name: "second fallback", available: map[string]bool{"ST3": true}, wantRequested: []string{"ST1", "ST2", "ST3"}, wantOutput: "20260110010000-ST3-Test Program.aac"},
+		{name: "all fail", wantRequested: []string{"ST1", "ST2", "ST3"}, wantErr: "ST1 unavailable (fallback stations also failed: ST2: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []string
+			client := &MockRadikoClient{
+				ResolvePlaylistFn: func(ctx context.Context, stationID string, at time.Time) (string, error) {
+					requested = append(requested, stationID)
+					if !at.Equal(pastTime) || !tt.available[stationID] {
+						return "", fmt.Errorf("%s unavailable", stationID)
+					}
+					return "http://mock.m3u8/playlist.m3u8", nil
+				},
+			}
+			outputDir := t.TempDir()
+			opts := JobOptions{
+				OutputDir:  outputDir,
+				Logger:     log.New(io.Discard, "", 0),
+				FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+				DisableProgressBar: true,
+			}
+
+			_, err := executeJobWithFallbacks(context.Background(), client, entry, pastTime, opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("executeJobWithFallbacks failed: %v", err)
+			}
+			if !reflect.DeepEqual(requested, tt.wantRequested) {
+				t.Errorf("requested stations %v, want %v", requested, tt.wantRequested)
+			}
+			if tt.wantOutput != "" {
+				if _, err := os.Stat(filepath.Join(outputDir, tt.wantOutput)); err != nil {
+					t.Errorf("expected output %s: %v", tt.wantOutput, err)
+				}
+			}
+		})
+	}
+}
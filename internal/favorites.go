@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// radikoFavoritesURL is the member API listing the programs favorited ("お気に入り") by the
+// logged-in premium account.
+const radikoFavoritesURL = radikoBaseURL + "/ap/member/webapi/member/favorite/program/list"
+
+// FavoriteProgram is a program in the favorites of a radiko account.
+type FavoriteProgram struct {
+	StationID string `json:"station_id"`
+	Title     string `json:"title"`
+}
+
+// parseFavorites parses the response of the favorites API.
+func parseFavorites(data []byte) ([]FavoriteProgram, error) {
+	var resp struct {
+		Programs []FavoriteProgram `json:"programs"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse favorites: %w", err)
+	}
+	favorites := resp.Programs[:0]
+	for _, fav := range resp.Programs {
+		if fav.StationID != "" && strings.TrimSpace(fav.Title) != "" {
+			favorites = append(favorites, fav)
+		}
+	}
+	return favorites, nil
+}
+
+// fetchFavorites gets the favorites from url with client, which carries the session of a premium login.
+func fetchFavorites(ctx context.Context, client httpDoer, url string) ([]FavoriteProgram, error) {
+	body, err := httpGet(ctx, client, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favorites: %w", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read favorites: %w", err)
+	}
+	return parseFavorites(data)
+}
+
+// FetchRadikoFavorites logs in with the premium account of creds and returns its favorite programs.
+func FetchRadikoFavorites(ctx context.Context, creds RadikoCredentials) ([]FavoriteProgram, error) {
+	if creds.Mail == "" {
+		return nil, fmt.Errorf("favorites need a radiko premium account; set radiko.mail and radiko.password in config.json")
+	}
+	provider, err := NewLoggedInGoradikoClient(ctx, RadikoCredentials{Mail: creds.Mail, Password: creds.Password})
+	if err != nil {
+		return nil, err
+	}
+	return fetchFavorites(ctx, provider.(*goradikoClient).current(), radikoFavoritesURL)
+}
+
+// FavoriteScheduleEntries returns schedule entries recording every weekly slot in which the
+// favorites air, found in the station guides fetched with fetch. Favorites that are not in
+// this week's guide are returned in missing; stations whose guide fails are reported in errs
+// and their favorites skipped. The slots are given in loc, the time zone of the schedule.
+func FavoriteScheduleEntries(ctx context.Context, favorites []FavoriteProgram, loc *time.Location, fetch func(ctx context.Context, stationID string) ([]byte, error)) (entries []ScheduleEntry, missing []FavoriteProgram, errs []error) {
+	guides := make(map[string][]GuideMatch)
+	failed := make(map[string]bool)
+	for _, fav := range favorites {
+		progs, ok := guides[fav.StationID]
+		if !ok && !failed[fav.StationID] {
+			data, err := fetch(ctx, fav.StationID)
+			if err == nil {
+				progs, err = guideProgs(data)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", fav.StationID, err))
+				failed[fav.StationID] = true
+				continue
+			}
+			guides[fav.StationID] = progs
+		}
+		if failed[fav.StationID] {
+			continue
+		}
+
+		found := false
+		for _, m := range progs {
+			if !strings.EqualFold(strings.TrimSpace(m.Prog.Title), strings.TrimSpace(fav.Title)) {
+				continue
+			}
+			entry, err := m.ScheduleEntry(loc)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", fav.StationID, err))
+				continue
+			}
+			found = true
+			if !scheduleHasSlot(entries, entry) {
+				entries = append(entries, entry)
+			}
+		}
+		if !found {
+			missing = append(missing, fav)
+		}
+	}
+	return entries, missing, errs
+}
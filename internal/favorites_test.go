@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestFetchFavorites(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"programs": [
+			{"station_id": "LFR", "title": "オードリーのオールナイトニッポン"},
+			{"station_id": "", "title": "No station"},
+			{"station_id": "TBS", "title": " "}
+		]}`)
+	}))
+	defer srv.Close()
+
+	got, err := fetchFavorites(context.Background(), srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatalf("fetchFavorites failed: %v", err)
+	}
+	want := []FavoriteProgram{{StationID: "LFR", Title: "オードリーのオールナイトニッポン"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := parseFavorites([]byte("<html>login</html>")); err == nil {
+		t.Error("expected an error for a response that is not JSON")
+	}
+}
+
+func TestFavoriteScheduleEntries(t *testing.T) {
+	favorites := []FavoriteProgram{
+		{StationID: "LFR", Title: "morning show"},
+		{StationID: "LFR", Title: "オードリーのオールナイトニッポン"},
+		{StationID: "LFR", Title: "Gone"},
+		{StationID: "TBS", Title: "Unreachable"},
+	}
+	fetched := 0
+	entries, missing, errs := FavoriteScheduleEntries(context.Background(), favorites, nil, func(ctx context.Context, stationID string) ([]byte, error) {
+		fetched++
+		if stationID == "TBS" {
+			return nil, fmt.Errorf("guide unavailable")
+		}
+		return []byte(searchGuideXML), nil
+	})
+
+	want := []ScheduleEntry{
+		{ProgramName: "Morning Show", DayOfWeek: "月", StartTime: "060000", StationID: "LFR"},
+		{ProgramName: "オードリーのオールナイトニッポン", DayOfWeek: "土", StartTime: "010000", StationID: "LFR"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got entries %+v, want %+v", entries, want)
+	}
+	if len(missing) != 1 || missing[0].Title != "Gone" {
+		t.Errorf("expected only Gone to be missing, got %+v", missing)
+	}
+	if len(errs) != 1 || fetched != 2 {
+		t.Errorf("expected each guide to be fetched once and TBS to fail, got %d fetches and %v", fetched, errs)
+	}
+}
@@ -0,0 +1,275 @@
+package internal
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"radikoRecScheduler/pkg/radirec/daemonpb"
+)
+
+// GRPCConfig serves the daemon's gRPC control interface (config "grpc"), defined in
+// pkg/radirec/daemonpb/daemon.proto, for GUI and mobile clients.
+type GRPCConfig struct {
+	Listen string `json:"listen,omitempty"` // Address to listen on, e.g. "127.0.0.1:50051". Empty disables the interface.
+	// Token, when set, must be sent by every call in the "authorization" metadata as "Bearer <token>".
+	// It is required unless Listen is a loopback address.
+	Token string `json:"token,omitempty"`
+}
+
+// validate checks that an interface reachable from other hosts is protected by a token.
+func (c GRPCConfig) validate() error {
+	if c.Listen == "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(c.Listen)
+	if err != nil {
+		return fmt.Errorf("invalid listen address '%s': %w", c.Listen, err)
+	}
+	if c.Token == "" && !isLoopbackHost(host) {
+		return fmt.Errorf("listen address '%s' is reachable from other hosts; set a token", c.Listen)
+	}
+	return nil
+}
+
+// isLoopbackHost reports whether host only accepts connections from this machine.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ServeGRPC serves the gRPC control interface of the daemon on cfg.Listen, backed by state and
+// history, until the returned stop function is called.
+func ServeGRPC(cfg GRPCConfig, state *DaemonState, history *History, logger *log.Logger) (stop func(), err error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the gRPC interface: %w", err)
+	}
+	server := newGRPCServer(cfg.Token, state, history)
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			logger.Printf("WARNING: gRPC interface stopped: %v", err)
+		}
+	}()
+	logger.Printf("INFO: Serving the gRPC interface on %s.", listener.Addr())
+	return server.Stop, nil
+}
+
+// newGRPCServer returns a server of ScheduleService and RecordingService that, with a token,
+// rejects calls that do not carry it.
+func newGRPCServer(token string, state *DaemonState, history *History) *grpc.Server {
+	var opts []grpc.ServerOption
+	if token != "" {
+		opts = append(opts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if err := checkGRPCToken(ctx, token); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := checkGRPCToken(ss.Context(), token); err != nil {
+					return err
+				}
+				return handler(srv, ss)
+			}),
+		)
+	}
+	server := grpc.NewServer(opts...)
+	daemonpb.RegisterScheduleServiceServer(server, scheduleService{state: state, history: history})
+	daemonpb.RegisterRecordingServiceServer(server, recordingService{state: state, history: history})
+	return server
+}
+
+// checkGRPCToken fails unless the metadata of ctx authorizes the call with token.
+func checkGRPCToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if got, ok := strings.CutPrefix(value, "Bearer "); ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid token")
+}
+
+// logGRPCAction adds an action taken for a gRPC client to the action log of history.
+func logGRPCAction(ctx context.Context, history *History, action ActionRecord) {
+	action.Detail = "gRPC"
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		action.Detail = "gRPC client " + p.Addr.String()
+	}
+	if err := history.LogAction(action); err != nil {
+		log.Printf("WARNING: Failed to write the action log: %v", err)
+	}
+}
+
+// scheduleService implements daemonpb.ScheduleServiceServer.
+type scheduleService struct {
+	daemonpb.UnimplementedScheduleServiceServer
+	state   *DaemonState
+	history *History
+}
+
+func (s scheduleService) GetStatus(context.Context, *daemonpb.GetStatusRequest) (*daemonpb.DaemonStatus, error) {
+	st := s.state.Status()
+	resp := &daemonpb.DaemonStatus{
+		Pid:         int32(os.Getpid()),
+		StartedAt:   timestamppb.New(st.StartedAt),
+		PassRunning: st.PassRunning,
+	}
+	if !st.NextPass.IsZero() {
+		resp.NextPass = timestamppb.New(st.NextPass)
+	}
+	for _, job := range s.state.RunningJobs() {
+		resp.Running = append(resp.Running, recordingProto(job))
+	}
+	return resp, nil
+}
+
+func (s scheduleService) ListEntries(context.Context, *daemonpb.ListEntriesRequest) (*daemonpb.ListEntriesResponse, error) {
+	resp := &daemonpb.ListEntriesResponse{}
+	for _, entry := range s.state.Status().Schedule {
+		resp.Entries = append(resp.Entries, &daemonpb.ScheduleEntry{
+			ProgramName: entry.ProgramName,
+			DayOfWeek:   entry.DayOfWeek,
+			StartTime:   entry.StartTime,
+			StationId:   entry.StationID,
+			Provider:    entry.Provider,
+			Date:        entry.Date,
+			Enabled:     entry.IsEnabled(),
+			Note:        entry.Note,
+		})
+	}
+	return resp, nil
+}
+
+func (s scheduleService) RunPass(ctx context.Context, _ *daemonpb.RunPassRequest) (*daemonpb.RunPassResponse, error) {
+	logGRPCAction(ctx, s.history, ActionRecord{Action: ActionPassRequested})
+	s.state.RequestPass()
+	return &daemonpb.RunPassResponse{}, nil
+}
+
+// recordingService implements daemonpb.RecordingServiceServer.
+type recordingService struct {
+	daemonpb.UnimplementedRecordingServiceServer
+	state   *DaemonState
+	history *History
+}
+
+func (s recordingService) ListRunning(context.Context, *daemonpb.ListRunningRequest) (*daemonpb.ListRunningResponse, error) {
+	resp := &daemonpb.ListRunningResponse{}
+	for _, job := range s.state.RunningJobs() {
+		resp.Recordings = append(resp.Recordings, recordingProto(job))
+	}
+	return resp, nil
+}
+
+func (s recordingService) WatchProgress(_ *daemonpb.WatchProgressRequest, stream grpc.ServerStreamingServer[daemonpb.ProgressEvent]) error {
+	events, stop := s.state.WatchJobs()
+	defer stop()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev := <-events:
+			if err := stream.Send(&daemonpb.ProgressEvent{Recording: recordingProto(ev.Job), Finished: ev.Finished}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s recordingService) CancelRecording(ctx context.Context, req *daemonpb.CancelRecordingRequest) (*daemonpb.CancelRecordingResponse, error) {
+	var job RunningJob
+	for _, running := range s.state.RunningJobs() {
+		if running.ID == req.GetId() {
+			job = running
+		}
+	}
+	if !s.state.CancelJob(req.GetId()) {
+		return nil, status.Errorf(codes.NotFound, "no recording in progress with id %s", req.GetId())
+	}
+	logGRPCAction(ctx, s.history, ActionRecord{Action: ActionJobCancelled, ProgramName: job.ProgramName, StationID: job.StationID, Start: job.Start})
+	return &daemonpb.CancelRecordingResponse{}, nil
+}
+
+func (s recordingService) ListJobs(_ context.Context, req *daemonpb.ListJobsRequest) (*daemonpb.ListJobsResponse, error) {
+	records, err := s.history.Records()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if limit := int(req.GetLimit()); limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	resp := &daemonpb.ListJobsResponse{}
+	for _, rec := range records {
+		resp.Jobs = append(resp.Jobs, jobProto(rec))
+	}
+	return resp, nil
+}
+
+func (s recordingService) GetJob(_ context.Context, req *daemonpb.GetJobRequest) (*daemonpb.Job, error) {
+	rec, err := s.history.Find(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return jobProto(rec), nil
+}
+
+// recordingProto converts a running job to its message.
+func recordingProto(job RunningJob) *daemonpb.Recording {
+	progress := &daemonpb.Progress{
+		ChunksDone:  int32(job.Progress.ChunksDone),
+		ChunksTotal: int32(job.Progress.ChunksTotal),
+		BytesDone:   job.Progress.BytesDone,
+	}
+	if job.Progress.AudioTotal > 0 {
+		progress.AudioDone = durationpb.New(job.Progress.AudioDone)
+		progress.AudioTotal = durationpb.New(job.Progress.AudioTotal)
+	}
+	return &daemonpb.Recording{
+		Id:          job.ID,
+		ProgramName: job.ProgramName,
+		StationId:   job.StationID,
+		Start:       timestamppb.New(job.Start),
+		Progress:    progress,
+	}
+}
+
+// jobProto converts a history record to its message.
+func jobProto(rec HistoryRecord) *daemonpb.Job {
+	return &daemonpb.Job{
+		Id:          rec.ID,
+		ProgramName: rec.ProgramName,
+		Title:       rec.Title,
+		StationId:   rec.StationID,
+		Provider:    rec.Provider,
+		StartTime:   timestamppb.New(rec.StartTime),
+		StartedAt:   timestamppb.New(rec.StartedAt),
+		FinishedAt:  timestamppb.New(rec.FinishedAt),
+		Status:      rec.Status,
+		OutputPath:  rec.OutputPath,
+		Error:       rec.Error,
+		Rerun:       rec.Rerun,
+		Size:        rec.Size,
+	}
+}
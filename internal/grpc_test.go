@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"radikoRecScheduler/pkg/radirec/daemonpb"
+)
+
+func TestGRPCConfigValidate(t *testing.T) {
+	tests := []struct {
+		config  GRPCConfig
+		wantErr bool
+	}{
+		{config: GRPCConfig{}},
+		{config: GRPCConfig{Listen: "127.0.0.1:50051"}},
+		{config: GRPCConfig{Listen: "localhost:50051"}},
+		{config: GRPCConfig{Listen: "[::1]:50051"}},
+		{config: GRPCConfig{Listen: ":50051"}, wantErr: true},
+		{config: GRPCConfig{Listen: "0.0.0.0:50051"}, wantErr: true},
+		{config: GRPCConfig{Listen: "0.0.0.0:50051", Token: "secret"}},
+		{config: GRPCConfig{Listen: "50051"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate(%+v) = %v, want error %v", tt.config, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGRPCServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	history := OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err := history.Append(HistoryRecord{ID: "abcd1234", ProgramName: "P", Status: StatusSuccess, Size: 42}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	state := NewDaemonState()
+	state.setSchedule([]ScheduleEntry{{ProgramName: "P", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}})
+
+	listener := bufconn.Listen(1 << 20)
+	server := newGRPCServer("secret", state, history)
+	go server.Serve(listener)
+	defer server.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	schedule := daemonpb.NewScheduleServiceClient(conn)
+	recordings := daemonpb.NewRecordingServiceClient(conn)
+
+	if _, err := schedule.GetStatus(ctx, &daemonpb.GetStatusRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected a call without the token to be rejected, got %v", err)
+	}
+	authed := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+
+	entries, err := schedule.ListEntries(authed, &daemonpb.ListEntriesRequest{})
+	if err != nil || len(entries.GetEntries()) != 1 || entries.GetEntries()[0].GetStationId() != "ST1" || !entries.GetEntries()[0].GetEnabled() {
+		t.Errorf("ListEntries = (%v, %v)", entries, err)
+	}
+	if _, err := schedule.RunPass(authed, &daemonpb.RunPassRequest{}); err != nil {
+		t.Errorf("RunPass failed: %v", err)
+	}
+	select {
+	case <-state.passes:
+	default:
+		t.Error("expected RunPass to request a pass")
+	}
+
+	stream, err := recordings.WatchProgress(authed, &daemonpb.WatchProgressRequest{})
+	if err != nil {
+		t.Fatalf("WatchProgress failed: %v", err)
+	}
+	// The server watches the jobs once it handles the call, so wait for that before starting one.
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		state.jobs.mu.Lock()
+		n := len(state.jobs.watchers)
+		state.jobs.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("WatchProgress did not start watching")
+		}
+	}
+	start := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	jobCtx, progress, done := state.jobs.start(ctx, ScheduleEntry{ProgramName: "P", StationID: "ST1"}, start, false)
+	progress(ProgressUpdate{ChunksDone: 3, ChunksTotal: 10, BytesDone: 300, AudioDone: 15 * time.Second, AudioTotal: 50 * time.Second})
+
+	var events []*daemonpb.ProgressEvent
+	for len(events) < 2 {
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		events = append(events, ev)
+	}
+	id := events[0].GetRecording().GetId()
+	if got := events[1].GetRecording().GetProgress(); got.GetChunksDone() != 3 || got.GetAudioTotal().AsDuration() != 50*time.Second {
+		t.Errorf("unexpected progress event: %v", events[1])
+	}
+
+	running, err := recordings.ListRunning(authed, &daemonpb.ListRunningRequest{})
+	if err != nil || len(running.GetRecordings()) != 1 || running.GetRecordings()[0].GetId() != id || !running.GetRecordings()[0].GetStart().AsTime().Equal(start) {
+		t.Errorf("ListRunning = (%v, %v)", running, err)
+	}
+	if _, err := recordings.CancelRecording(authed, &daemonpb.CancelRecordingRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for an unknown recording, got %v", err)
+	}
+	if _, err := recordings.CancelRecording(authed, &daemonpb.CancelRecordingRequest{Id: id}); err != nil {
+		t.Errorf("CancelRecording failed: %v", err)
+	}
+	if jobCtx.Err() == nil {
+		t.Error("expected the recording to be cancelled")
+	}
+	done()
+	if ev, err := stream.Recv(); err != nil || !ev.GetFinished() {
+		t.Errorf("expected a finished event, got (%v, %v)", ev, err)
+	}
+
+	jobs, err := recordings.ListJobs(authed, &daemonpb.ListJobsRequest{Limit: 10})
+	if err != nil || len(jobs.GetJobs()) != 1 || jobs.GetJobs()[0].GetSize() != 42 {
+		t.Errorf("ListJobs = (%v, %v)", jobs, err)
+	}
+	if job, err := recordings.GetJob(authed, &daemonpb.GetJobRequest{Id: "abcd1234"}); err != nil || job.GetProgramName() != "P" {
+		t.Errorf("GetJob = (%v, %v)", job, err)
+	}
+	if _, err := recordings.GetJob(authed, &daemonpb.GetJobRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for an unknown job, got %v", err)
+	}
+
+	actions, err := history.Actions()
+	if err != nil || len(actions) != 2 || actions[0].Action != ActionPassRequested || actions[1].Action != ActionJobCancelled || actions[1].StationID != "ST1" {
+		t.Errorf("expected the pass and the cancellation in the action log, got (%+v, %v)", actions, err)
+	}
+}
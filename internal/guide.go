@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// stationRegionURL lists every radiko station, grouped by region.
+const stationRegionURL = "https://radiko.jp/v3/station/region/full.xml"
+
+// GetAllStationIDs returns the IDs of every radiko station, fetched with http.DefaultClient.
+func GetAllStationIDs(ctx context.Context) ([]string, error) {
+	return FetchStationIDs(ctx, http.DefaultClient)
+}
+
+// FetchStationIDs returns the IDs of every radiko station, fetched with client.
+func FetchStationIDs(ctx context.Context, client *http.Client) ([]string, error) {
+	body, err := fetchGuideXML(ctx, client, stationRegionURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get station list: %w", err)
+	}
+	return parseStationRegions(body)
+}
+
+// parseStationRegions extracts the station IDs from the region station list XML, without duplicates.
+func parseStationRegions(data []byte) ([]string, error) {
+	var region struct {
+		Stations []struct {
+			Station []struct {
+				ID string `xml:"id"`
+			} `xml:"station"`
+		} `xml:"stations"`
+	}
+	if err := xml.Unmarshal(data, &region); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal station list: %w", err)
+	}
+	seen := make(map[string]bool)
+	var ids []string
+	for _, group := range region.Stations {
+		for _, station := range group.Station {
+			if station.ID != "" && !seen[station.ID] {
+				seen[station.ID] = true
+				ids = append(ids, station.ID)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// GuideMatch is a broadcast found by SearchGuide.
+type GuideMatch struct {
+	StationID string
+	Prog      Prog
+}
+
+// Start returns the broadcast start time.
+func (m GuideMatch) Start() (time.Time, error) {
+	return time.ParseInLocation("20060102150405", m.Prog.Ft, JST)
+}
+
+// ScheduleEntry returns a weekly schedule entry recording the slot of this broadcast, with its
+// day and time in loc, the time zone of the schedule (nil is Japan time).
+func (m GuideMatch) ScheduleEntry(loc *time.Location) (ScheduleEntry, error) {
+	start, err := m.Start()
+	if err != nil {
+		return ScheduleEntry{}, fmt.Errorf("invalid start time '%s': %w", m.Prog.Ft, err)
+	}
+	start = start.In(scheduleLocation(loc))
+	return ScheduleEntry{
+		ProgramName: m.Prog.Title,
+		DayOfWeek:   japaneseDayOfWeek(start.Weekday()),
+		StartTime:   start.Format("150405"),
+		StationID:   m.StationID,
+	}, nil
+}
+
+// SearchGuide returns the programs in the guide XML whose title, performer or
+// description contains keyword (case-insensitively), in guide order.
+func SearchGuide(programData []byte, keyword string) ([]GuideMatch, error) {
+	progs, err := guideProgs(programData)
+	if err != nil {
+		return nil, err
+	}
+	keyword = strings.ToLower(keyword)
+
+	var matches []GuideMatch
+	for _, m := range progs {
+		for _, field := range []string{m.Prog.Title, m.Prog.Pfm, m.Prog.Desc, m.Prog.Info} {
+			if strings.Contains(strings.ToLower(field), keyword) {
+				matches = append(matches, m)
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+// guideProgs returns every program in the guide XML, in guide order.
+func guideProgs(programData []byte) ([]GuideMatch, error) {
+	var radiko Radiko
+	if err := xml.Unmarshal(programData, &radiko); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal program guide: %w", err)
+	}
+	var progs []GuideMatch
+	for _, station := range radiko.Stations.Station {
+		for _, prog := range station.Progs.Prog {
+			progs = append(progs, GuideMatch{StationID: station.ID, Prog: prog})
+		}
+	}
+	return progs, nil
+}
+
+// SearchGuides runs SearchGuide over the guides of stationIDs fetched with fetch.
+// Stations whose guide cannot be fetched or parsed are reported in errs and skipped.
+func SearchGuides(ctx context.Context, stationIDs []string, keyword string, fetch func(ctx context.Context, stationID string) ([]byte, error)) (matches []GuideMatch, errs []error) {
+	for _, id := range stationIDs {
+		data, err := fetch(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+			continue
+		}
+		found, err := SearchGuide(data, keyword)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+			continue
+		}
+		matches = append(matches, found...)
+	}
+	return matches, errs
+}
+
+// AddScheduleEntry appends entry to the JSON schedule at path: either the schedule array
+// of config.json of profile (when isConfig is set) or a separate schedule.json file.
+// YAML and TOML schedules are not rewritten, since that would drop their comments.
+// It returns false without changing anything if the same slot is already scheduled.
+func AddScheduleEntry(profile Profile, path string, isConfig bool, entry ScheduleEntry) (bool, error) {
+	if isConfig {
+		cfg, err := profile.LoadConfig(path)
+		if err != nil {
+			return false, err
+		}
+		if scheduleHasSlot(cfg.Schedule, entry) {
+			return false, nil
+		}
+		cfg.Schedule = append(cfg.Schedule, entry)
+		return true, SaveConfig(path, cfg)
+	}
+
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		return false, fmt.Errorf("'%s' is not a JSON schedule; add the entry by hand", path)
+	}
+	entries, err := LoadSchedule(path)
+	if err != nil {
+		return false, err
+	}
+	if scheduleHasSlot(entries, entry) {
+		return false, nil
+	}
+	data, err := json.MarshalIndent(append(entries, entry), "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("failed to encode schedule: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return false, fmt.Errorf("failed to write schedule file '%s': %w", path, err)
+	}
+	return true, nil
+}
+
+// scheduleHasSlot reports whether entries already record the station, day (or date) and time of entry.
+func scheduleHasSlot(entries []ScheduleEntry, entry ScheduleEntry) bool {
+	for _, e := range entries {
+		if e.StationID == entry.StationID && e.DayOfWeek == entry.DayOfWeek && e.StartTime == entry.StartTime && e.Date == entry.Date {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const searchGuideXML = `<?xml version="1.0" encoding="UTF-8"?>
+<radiko>
+  <stations>
+    <station id="LFR">
+      <progs>
+        <prog ft="20260117010000" to="20260117030000">
+          <title>オードリーのオールナイトニッポン</title>
+          <pfm>オードリー</pfm>
+        </prog>
+        <prog ft="20260113220000" to="20260113230000">
+          <title>Music Hour</title>
+          <desc>Guest: AUDREY</desc>
+        </prog>
+        <prog ft="20260112060000" to="20260112080000">
+          <title>Morning Show</title>
+        </prog>
+      </progs>
+    </station>
+  </stations>
+</radiko>`
+
+func TestSearchGuide(t *testing.T) {
+	tests := []struct {
+		keyword string
+		want    []string
+	}{
+		{"オードリー", []string{"オードリーのオールナイトニッポン"}},
+		{"audrey", []string{"Music Hour"}}, // Description, case-insensitive
+		{"show", []string{"Morning Show"}},
+		{"nothing", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyword, func(t *testing.T) {
+			matches, err := SearchGuide([]byte(searchGuideXML), tt.keyword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(matches) != len(tt.want) {
+				t.Fatalf("expected %d matches, got %+v", len(tt.want), matches)
+			}
+			for i, m := range matches {
+				if m.Prog.Title != tt.want[i] || m.StationID != "LFR" {
+					t.Errorf("match %d: expected %s on LFR, got %s on %s", i, tt.want[i], m.Prog.Title, m.StationID)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchGuides_SkipsFailedStations(t *testing.T) {
+	matches, errs := SearchGuides(context.Background(), []string{"LFR", "TBS"}, "show", func(ctx context.Context, stationID string) ([]byte, error) {
+		if stationID == "TBS" {
+			return nil, fmt.Errorf("guide unavailable")
+		}
+		return []byte(searchGuideXML), nil
+	})
+	if len(matches) != 1 || len(errs) != 1 {
+		t.Errorf("expected 1 match and 1 error, got %d and %v", len(matches), errs)
+	}
+}
+
+func TestParseStationRegions(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<region>
+  <stations region_id="kanto">
+    <station><id>TBS</id><name>TBSラジオ</name></station>
+    <station><id>LFR</id><name>ニッポン放送</name></station>
+  </stations>
+  <stations region_id="zenkoku">
+    <station><id>RN1</id><name>ラジオNIKKEI第1</name></station>
+    <station><id>LFR</id><name>ニッポン放送</name></station>
+  </stations>
+</region>`
+	ids, err := parseStationRegions([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[TBS LFR RN1]" {
+		t.Errorf("unexpected station IDs: %v", ids)
+	}
+}
+
+func TestGuideMatchScheduleEntry(t *testing.T) {
+	m := GuideMatch{StationID: "LFR", Prog: Prog{Ft: "20260117010000", Title: "オードリーのオールナイトニッポン"}}
+	entry, err := m.ScheduleEntry(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ScheduleEntry{ProgramName: "オードリーのオールナイトニッポン", DayOfWeek: "土", StartTime: "010000", StationID: "LFR"}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("expected %+v, got %+v", want, entry)
+	}
+
+	if _, err := (GuideMatch{Prog: Prog{Ft: "bad"}}).ScheduleEntry(nil); err == nil {
+		t.Error("expected an error for an invalid start time")
+	}
+}
+
+func TestAddScheduleEntry(t *testing.T) {
+	entry := ScheduleEntry{ProgramName: "New", DayOfWeek: "月", StartTime: "220000", StationID: "TBS"}
+
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		isConfig bool
+		added    bool
+		wantErr  bool
+		wantLen  int
+	}{
+		{"config", "config.json", `{"output_dir": "out", "schedule": []}`, true, true, false, 1},
+		{"missing config", "config.json", "", true, true, false, 1},
+		{"schedule file", "schedule.json", `[{"program_name": "Old", "day_of_week": "火", "start_time": "010000", "station_id": "LFR"}]`, false, true, false, 2},
+		{"duplicate slot", "schedule.json", `[{"program_name": "Renamed", "day_of_week": "月", "start_time": "220000", "station_id": "TBS"}]`, false, false, false, 1},
+		{"yaml", "schedule.yaml", "- program_name: Old\n", false, false, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			added, err := AddScheduleEntry("", path, tt.isConfig, entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if added != tt.added {
+				t.Errorf("expected added=%v, got %v", tt.added, added)
+			}
+
+			var entries []ScheduleEntry
+			if tt.isConfig {
+				cfg, err := LoadConfig(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				entries = cfg.Schedule
+				if tt.content != "" && cfg.OutputDir != "out" {
+					t.Errorf("expected other settings to be kept, got output_dir %q", cfg.OutputDir)
+				}
+			} else if entries, err = LoadSchedule(path); err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != tt.wantLen {
+				t.Errorf("expected %d entries, got %+v", tt.wantLen, entries)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Recording statuses stored in the history.
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// HistoryRecord is one recording attempt stored in the history file.
+type HistoryRecord struct {
+	ID          string    `json:"id"`
+	ProgramName string    `json:"program_name"` // program_name from the schedule entry
+	Title       string    `json:"title"`        // Title the recording was saved under
+	StationID   string    `json:"station_id"`
+	Provider    string    `json:"provider,omitempty"` // Schedule entry's provider; empty for radiko
+	StartTime   time.Time `json:"start_time"`         // Broadcast start time
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	Status      string    `json:"status"`
+	OutputPath  string    `json:"output_path,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	LogPath     string    `json:"log_path,omitempty"` // Log lines emitted during this job
+	Rerun       bool      `json:"rerun,omitempty"`    // Recorded from the entry's rerun slot
+
+	// Size and SHA256 describe the saved recording, so later audits can detect bit-rot and truncation.
+	Size         int64  `json:"size,omitempty"`
+	SHA256       string `json:"sha256,omitempty"`
+	LocalRemoved bool   `json:"local_removed,omitempty"` // The local file was deleted after a post-store upload.
+	// PendingUploads lists the files of a saved recording that were not uploaded to the post-store
+	// because an upload failed, in upload order. The next attempt at the broadcast uploads only these.
+	PendingUploads []string `json:"pending_uploads,omitempty"`
+
+	// Guide is a snapshot of the program guide entry used to resolve this recording,
+	// kept so later questions about what actually aired can be answered from the history.
+	Guide *Prog `json:"guide,omitempty"`
+}
+
+// History is an append-only JSON Lines file of HistoryRecords.
+type History struct {
+	path string
+	mu   sync.Mutex
+}
+
+// OpenHistory returns a History stored at path. The file is created on first append.
+func OpenHistory(path string) *History {
+	return &History{path: path}
+}
+
+// HistoryPath returns the default location of the history file of the profile in the XDG data directory.
+func (p Profile) HistoryPath() (string, error) {
+	dataDir, err := p.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "history.jsonl"), nil
+}
+
+// Path returns the location of the history file.
+func (h *History) Path() string {
+	return h.path
+}
+
+// JobLogPath returns where the log of the job with the given ID is stored.
+// Job logs live in a "jobs" directory next to the history file.
+func (h *History) JobLogPath(id string) string {
+	return filepath.Join(filepath.Dir(h.path), "jobs", id+".log")
+}
+
+// Append writes rec to the end of the history file.
+func (h *History) Append(rec HistoryRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file '%s': %w", h.path, err)
+	}
+	defer file.Close()
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode history record: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write history file '%s': %w", h.path, err)
+	}
+	return file.Close()
+}
+
+// Records returns all records in the order they were written.
+// A missing history file yields no records.
+func (h *History) Records() ([]HistoryRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	file, err := os.Open(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open history file '%s': %w", h.path, err)
+	}
+	defer file.Close()
+
+	var records []HistoryRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("error parsing history file '%s' line %d: %w", h.path, lineNo, err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file '%s': %w", h.path, err)
+	}
+	return records, nil
+}
+
+// Find returns the record with the given ID.
+func (h *History) Find(id string) (HistoryRecord, error) {
+	records, err := h.Records()
+	if err != nil {
+		return HistoryRecord{}, err
+	}
+	for _, rec := range records {
+		if rec.ID == id {
+			return rec, nil
+		}
+	}
+	return HistoryRecord{}, fmt.Errorf("no job with id %s", id)
+}
+
+// FindRecording returns the most recent successful recording of the broadcast
+// starting at start on stationID, tagged as a rerun or not.
+func (h *History) FindRecording(stationID string, start time.Time, rerun bool) (HistoryRecord, bool, error) {
+	records, err := h.Records()
+	if err != nil {
+		return HistoryRecord{}, false, err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := records[i]
+		if rec.Status == StatusSuccess && rec.StationID == stationID && rec.StartTime.Equal(start) && rec.Rerun == rerun {
+			return rec, true, nil
+		}
+	}
+	return HistoryRecord{}, false, nil
+}
+
+// FindPendingUpload returns the last attempt at the broadcast starting at start on stationID,
+// tagged as a rerun or not, if it saved the recording but left files to upload (see PendingUploads).
+func (h *History) FindPendingUpload(stationID string, start time.Time, rerun bool) (HistoryRecord, bool, error) {
+	records, err := h.Records()
+	if err != nil {
+		return HistoryRecord{}, false, err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := records[i]
+		if rec.StationID == stationID && rec.StartTime.Equal(start) && rec.Rerun == rerun {
+			return rec, len(rec.PendingUploads) > 0, nil
+		}
+	}
+	return HistoryRecord{}, false, nil
+}
+
+// newJobID returns a short random identifier for a recording attempt.
+func newJobID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(b)
+}
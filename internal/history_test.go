@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryAppendAndFind(t *testing.T) {
+	history := OpenHistory(filepath.Join(t.TempDir(), "state", "history.jsonl"))
+
+	records, err := history.Records()
+	if err != nil || records != nil {
+		t.Fatalf("expected no records for missing file, got %v, %v", records, err)
+	}
+
+	guide := &Prog{Ft: "20260112100000", To: "20260112110000", Title: "Guide Title", Desc: "概要"}
+	first := HistoryRecord{ID: "aaaa1111", ProgramName: "P1", Status: StatusSuccess, Guide: guide}
+	second := HistoryRecord{ID: "bbbb2222", ProgramName: "P2", Status: StatusFailed, Error: "boom"}
+	for _, rec := range []HistoryRecord{first, second} {
+		if err := history.Append(rec); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	records, err = history.Records()
+	if err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	got, err := history.Find("aaaa1111")
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if got.Guide == nil || *got.Guide != *guide {
+		t.Errorf("guide snapshot not preserved: %+v", got.Guide)
+	}
+
+	if _, err := history.Find("missing"); err == nil {
+		t.Error("expected error for unknown job id")
+	}
+}
+
+func TestExecuteJobWritesHistory(t *testing.T) {
+	history := OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	entry := ScheduleEntry{ProgramName: "Test Program", StationID: "ST1"}
+
+	opts := JobOptions{OutputDir: t.TempDir(), History: history}
+	if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+
+	failing := &MockRadikoClient{AuthenticateFn: func(ctx context.Context) error { return fmt.Errorf("auth failed") }}
+	opts.OutputDir = t.TempDir()
+	if _, err := ExecuteJob(context.Background(), failing, entry, pastTime, opts); err == nil {
+		t.Fatal("expected ExecuteJob to fail")
+	}
+
+	records, err := history.Records()
+	if err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 history records, got %d", len(records))
+	}
+	if records[0].Status != StatusSuccess || !records[0].StartTime.Equal(pastTime) || records[0].ID == "" {
+		t.Errorf("unexpected success record: %+v", records[0])
+	}
+	if sum, err := fileSHA256(records[0].OutputPath); err != nil || records[0].SHA256 != hex.EncodeToString(sum) || records[0].Size != int64(2*len(dummyAACChunk)) {
+		t.Errorf("expected size and hash of the recording in the record, got %d %q (%v)", records[0].Size, records[0].SHA256, err)
+	}
+	if records[1].Status != StatusFailed || !strings.Contains(records[1].Error, "auth failed") {
+		t.Errorf("unexpected failure record: %+v", records[1])
+	}
+
+	if records[1].LogPath != history.JobLogPath(records[1].ID) {
+		t.Fatalf("unexpected job log path: %s", records[1].LogPath)
+	}
+	jobLog, err := os.ReadFile(records[1].LogPath)
+	if err != nil {
+		t.Fatalf("Failed to read job log: %v", err)
+	}
+	for _, want := range []string{"Starting recording for: Test Program", "ERROR: failed to authenticate"} {
+		if !strings.Contains(string(jobLog), want) {
+			t.Errorf("job log missing %q:\n%s", want, jobLog)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Formats of ExportHistory.
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+)
+
+// historyCSVHeader names the columns of a CSV export, one per HistoryRecord field except the log
+// path and the guide, of which only the broadcast length is kept.
+var historyCSVHeader = []string{
+	"id", "program_name", "title", "station_id", "provider", "start_time", "duration_seconds",
+	"started_at", "finished_at", "status", "rerun", "output_path", "size", "sha256", "local_removed", "error",
+}
+
+// ExportHistory writes records to w as CSV with a header row, or as a JSON array of
+// HistoryRecords with every field. Times are RFC 3339; broadcast start times are in JST.
+func ExportHistory(w io.Writer, records []HistoryRecord, format string) error {
+	switch format {
+	case ExportCSV:
+		return exportHistoryCSV(w, records)
+	case ExportJSON:
+		if records == nil {
+			records = []HistoryRecord{} // An empty history is [], not null.
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+	return fmt.Errorf("unknown export format '%s': must be %s or %s", format, ExportCSV, ExportJSON)
+}
+
+func exportHistoryCSV(w io.Writer, records []HistoryRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(historyCSVHeader); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		duration := ""
+		if d := guideDuration(rec.Guide); d > 0 {
+			duration = strconv.Itoa(int(d.Seconds()))
+		}
+		size := ""
+		if rec.Size > 0 {
+			size = strconv.FormatInt(rec.Size, 10)
+		}
+		row := []string{
+			rec.ID, rec.ProgramName, rec.Title, rec.StationID, rec.Provider,
+			formatExportTime(rec.StartTime.In(JST)), duration,
+			formatExportTime(rec.StartedAt), formatExportTime(rec.FinishedAt),
+			rec.Status, strconv.FormatBool(rec.Rerun), rec.OutputPath, size, rec.SHA256,
+			strconv.FormatBool(rec.LocalRemoved), rec.Error,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatExportTime formats t as RFC 3339, or as an empty cell when it is unset.
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportHistory(t *testing.T) {
+	records := []HistoryRecord{
+		{
+			ID: "1a2b3c4d", ProgramName: "Morning", Title: "Morning, \"live\"", StationID: "TBS",
+			StartTime:  time.Date(2026, time.March, 2, 1, 0, 0, 0, time.UTC),
+			StartedAt:  time.Date(2026, time.March, 2, 2, 0, 0, 0, time.UTC),
+			FinishedAt: time.Date(2026, time.March, 2, 2, 5, 0, 0, time.UTC),
+			Status:     StatusSuccess, OutputPath: "/srv/radio/morning.aac", Size: 1024, SHA256: "abc",
+			Guide: &Prog{Ft: "20260302100000", To: "20260302113000"},
+		},
+		{ID: "5e6f7a8b", ProgramName: "Night", StationID: "QRR", Status: StatusFailed, Error: "auth failed"},
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		records  []HistoryRecord
+		expected string
+	}{
+		{
+			name:    "csv",
+			format:  ExportCSV,
+			records: records,
+			expected: "id,program_name,title,station_id,provider,start_time,duration_seconds,started_at,finished_at,status,rerun,output_path,size,sha256,local_removed,error\n" +
+				"1a2b3c4d,Morning,\"Morning, \"\"live\"\"\",TBS,,2026-03-02T10:00:00+09:00,5400,2026-03-02T02:00:00Z,2026-03-02T02:05:00Z,success,false,/srv/radio/morning.aac,1024,abc,false,\n" +
+				"5e6f7a8b,Night,,QRR,,,,,,failed,false,,,,false,auth failed\n",
+		},
+		{name: "empty json", format: ExportJSON, expected: "[]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := ExportHistory(&buf, tt.records, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("ExportHistory =\n%s\nwant\n%s", buf.String(), tt.expected)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := ExportHistory(&buf, records, ExportJSON); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []HistoryRecord
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[0].Guide == nil || decoded[1].Error != "auth failed" {
+		t.Errorf("JSON export does not round-trip: %v, %+v", err, decoded)
+	}
+
+	if err := ExportHistory(&buf, records, "xml"); err == nil || !strings.Contains(err.Error(), "unknown export format") {
+		t.Errorf("expected unknown format error, got %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Playlist is an HLS playlist parsed by ParsePlaylist. A master playlist lists Variants;
+// a media playlist lists Segments.
+type Playlist struct {
+	Variants      []string          // URIs of the variant streams, in order.
+	Streams       []PlaylistVariant // The variant streams with their attributes, in the order of Variants.
+	Segments      []PlaylistSegment
+	MediaSequence uint64 // Sequence number of the first segment (#EXT-X-MEDIA-SEQUENCE).
+	Closed        bool   // No segments will be added (#EXT-X-ENDLIST), as for timeshift playlists.
+}
+
+// Master reports whether p is a master playlist.
+func (p *Playlist) Master() bool {
+	return len(p.Variants) > 0
+}
+
+// PlaylistVariant is one variant stream of a master playlist (#EXT-X-STREAM-INF).
+type PlaylistVariant struct {
+	URI       string
+	Bandwidth int    // Peak bits per second (BANDWIDTH); 0 when not given.
+	Codecs    string // e.g. "mp4a.40.5".
+}
+
+// PlaylistSegment is one media segment of a Playlist.
+type PlaylistSegment struct {
+	URI      string
+	Duration time.Duration // From #EXTINF.
+	Key      *PlaylistKey  // Encryption in effect for the segment; nil when it is clear.
+}
+
+// PlaylistKey is the #EXT-X-KEY tag a segment is encrypted with.
+type PlaylistKey struct {
+	Method string // e.g. "AES-128".
+	URI    string
+	IV     string // "0x..." hex, or empty for the media sequence number.
+}
+
+// ParsePlaylist parses an HLS master or media playlist (RFC 8216). Only what recording
+// needs is kept: the variants of a master playlist, and the segments of a media playlist
+// with their durations and encryption. Other tags are ignored.
+func ParsePlaylist(r io.Reader) (*Playlist, error) {
+	p := &Playlist{}
+	var (
+		header   bool
+		variant  *PlaylistVariant // The next URI is a variant (#EXT-X-STREAM-INF).
+		duration time.Duration    // Of the next segment; set by #EXTINF.
+		timed    bool             // #EXTINF seen for the next segment.
+		key      *PlaylistKey
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if !header {
+			if strings.TrimPrefix(line, "\ufeff") != "#EXTM3U" {
+				return nil, fmt.Errorf("missing #EXTM3U header")
+			}
+			header = true
+			continue
+		}
+		tag, value, _ := strings.Cut(line, ":")
+		switch {
+		case line == "":
+		case tag == "#EXT-X-STREAM-INF":
+			attrs := playlistAttributes(value)
+			bandwidth, _ := strconv.Atoi(attrs["BANDWIDTH"])
+			variant = &PlaylistVariant{Bandwidth: bandwidth, Codecs: attrs["CODECS"]}
+		case tag == "#EXTINF":
+			seconds, _, _ := strings.Cut(value, ",")
+			d, err := strconv.ParseFloat(strings.TrimSpace(seconds), 64)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("line %d: invalid #EXTINF duration %q", n, seconds)
+			}
+			duration, timed = time.Duration(d*float64(time.Second)), true
+		case tag == "#EXT-X-MEDIA-SEQUENCE":
+			seq, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid #EXT-X-MEDIA-SEQUENCE %q", n, value)
+			}
+			p.MediaSequence = seq
+		case tag == "#EXT-X-ENDLIST":
+			p.Closed = true
+		case tag == "#EXT-X-KEY":
+			attrs := playlistAttributes(value)
+			if method := attrs["METHOD"]; method == "" || strings.EqualFold(method, "NONE") {
+				key = nil
+			} else {
+				key = &PlaylistKey{Method: method, URI: attrs["URI"], IV: attrs["IV"]}
+			}
+		case strings.HasPrefix(line, "#"): // Other tags and comments.
+		case variant != nil:
+			variant.URI = line
+			p.Variants = append(p.Variants, line)
+			p.Streams = append(p.Streams, *variant)
+			variant = nil
+		default:
+			if !timed {
+				return nil, fmt.Errorf("line %d: segment %s has no #EXTINF", n, line)
+			}
+			p.Segments = append(p.Segments, PlaylistSegment{URI: line, Duration: duration, Key: key})
+			timed = false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read playlist: %w", err)
+	}
+	if !header {
+		return nil, fmt.Errorf("missing #EXTM3U header")
+	}
+	if len(p.Variants) > 0 && len(p.Segments) > 0 {
+		return nil, fmt.Errorf("playlist lists both variants and segments")
+	}
+	return p, nil
+}
+
+// playlistAttributes parses an attribute list such as `METHOD=AES-128,URI="https://k/1",IV=0x01`.
+// Quoted values may contain commas; their quotes are removed.
+func playlistAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for s != "" {
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.ToUpper(strings.TrimSpace(name))] = value
+		s = strings.TrimSpace(rest)
+	}
+	return attrs
+}
+
+// Stream qualities of StreamConfig.Quality, besides a bitrate in kbps.
+const (
+	QualityHighest = "highest" // The variant with the highest bandwidth.
+	QualityLowest  = "lowest"  // The variant with the lowest bandwidth.
+)
+
+// checkQuality validates the quality setting.
+func checkQuality(quality string) error {
+	switch quality {
+	case "", QualityHighest, QualityLowest:
+		return nil
+	}
+	if kbps, err := strconv.Atoi(strings.TrimSuffix(quality, "k")); err != nil || kbps <= 0 {
+		return fmt.Errorf("unknown quality '%s': must be %s, %s or a bitrate in kbps", quality, QualityHighest, QualityLowest)
+	}
+	return nil
+}
+
+// selectVariant returns the variant of the master playlist p that quality selects. Variants
+// without a BANDWIDTH count as 0 bits per second; ties go to the one listed first.
+func selectVariant(p *Playlist, quality string) PlaylistVariant {
+	best := p.Streams[0]
+	switch quality {
+	case QualityHighest:
+		for _, v := range p.Streams[1:] {
+			if v.Bandwidth > best.Bandwidth {
+				best = v
+			}
+		}
+	case QualityLowest:
+		for _, v := range p.Streams[1:] {
+			if v.Bandwidth < best.Bandwidth {
+				best = v
+			}
+		}
+	case "":
+	default:
+		kbps, _ := strconv.Atoi(strings.TrimSuffix(quality, "k"))
+		distance := func(v PlaylistVariant) int { return max(v.Bandwidth-kbps*1000, kbps*1000-v.Bandwidth) }
+		for _, v := range p.Streams[1:] {
+			if distance(v) < distance(best) {
+				best = v
+			}
+		}
+	}
+	return best
+}
+
+// fetchMediaPlaylist downloads and parses the HLS playlist at uri with client, following a master
+// playlist to the variant quality selects. It returns the media playlist with its URL, for resolving relative URIs.
+func fetchMediaPlaylist(ctx context.Context, client httpDoer, uri, quality string) (*Playlist, *url.URL, error) {
+	playlist, base, err := fetchPlaylist(ctx, client, uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	if playlist.Master() {
+		variant, err := base.Parse(selectVariant(playlist, quality).URI)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid variant URI: %w", err)
+		}
+		if playlist, base, err = fetchPlaylist(ctx, client, variant.String()); err != nil {
+			return nil, nil, err
+		}
+		if playlist.Master() {
+			return nil, nil, fmt.Errorf("%s is not a media playlist", variant)
+		}
+	}
+	return playlist, base, nil
+}
+
+// fetchPlaylist downloads and parses the playlist at uri, returning it with its URL for resolving relative URIs.
+func fetchPlaylist(ctx context.Context, client httpDoer, uri string) (*Playlist, *url.URL, error) {
+	base, err := url.Parse(uri)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid playlist URI: %w", err)
+	}
+	body, err := httpGet(ctx, client, uri)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get playlist: %w", err)
+	}
+	defer body.Close()
+	playlist, err := ParsePlaylist(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse playlist: %w", err)
+	}
+	return playlist, base, nil
+}
+
+// mediaChunks returns the segments of media as chunks, with absolute URLs and their EXTINF durations.
+func mediaChunks(media *Playlist, base *url.URL) ([]Chunk, error) {
+	chunks := make([]Chunk, 0, len(media.Segments))
+	for _, segment := range media.Segments {
+		segmentURL, err := base.Parse(segment.URI)
+		if err != nil {
+			return nil, fmt.Errorf("invalid segment URI: %w", err)
+		}
+		chunks = append(chunks, Chunk{URL: segmentURL.String(), Duration: segment.Duration})
+	}
+	return chunks, nil
+}
@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePlaylist(t *testing.T) {
+	key := &PlaylistKey{Method: "AES-128", URI: "https://keys.example/k?a=1,b=2", IV: "0x0F"}
+	tests := []struct {
+		name    string
+		input   string
+		want    *Playlist
+		wantErr string
+	}{
+		{
+			name:  "master",
+			input: "\ufeff#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=52973,CODECS=\"mp4a.40.5\"\nhttps://example.com/media.m3u8\n",
+			want: &Playlist{
+				Variants: []string{"https://example.com/media.m3u8"},
+				Streams:  []PlaylistVariant{{URI: "https://example.com/media.m3u8", Bandwidth: 52973, Codecs: "mp4a.40.5"}},
+			},
+		},
+		{
+			name: "media",
+			input: "#EXTM3U\r\n#EXT-X-TARGETDURATION:10\r\n#EXT-X-MEDIA-SEQUENCE:42\r\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"https://keys.example/k?a=1,b=2\",IV=0x0F\r\n" +
+				"#EXTINF:10.000,\r\na.aac\r\n# comment\r\n#EXTINF:5,title\r\nb.aac\r\n" +
+				"#EXT-X-KEY:METHOD=NONE\r\n\r\n#EXTINF:2.5,\r\nc.aac\r\n#EXT-X-ENDLIST\r\n",
+			want: &Playlist{
+				Segments: []PlaylistSegment{
+					{URI: "a.aac", Duration: 10 * time.Second, Key: key},
+					{URI: "b.aac", Duration: 5 * time.Second, Key: key},
+					{URI: "c.aac", Duration: 2500 * time.Millisecond},
+				},
+				MediaSequence: 42,
+				Closed:        true,
+			},
+		},
+		{name: "empty", input: "", wantErr: "missing #EXTM3U header"},
+		{name: "no header", input: "#EXTINF:10,\na.aac\n", wantErr: "missing #EXTM3U header"},
+		{name: "bad duration", input: "#EXTM3U\n#EXTINF:ten,\na.aac\n", wantErr: "line 2: invalid #EXTINF duration"},
+		{name: "bad sequence", input: "#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:-1\n", wantErr: "line 2: invalid #EXT-X-MEDIA-SEQUENCE"},
+		{name: "untimed segment", input: "#EXTM3U\n#EXTINF:10,\na.aac\nb.aac\n", wantErr: "line 4: segment b.aac has no #EXTINF"},
+		{name: "mixed", input: "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nv.m3u8\n#EXTINF:10,\na.aac\n", wantErr: "both variants and segments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePlaylist(strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePlaylist failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if got.Master() != (len(tt.want.Variants) > 0) {
+				t.Errorf("Master() = %v", got.Master())
+			}
+		})
+	}
+}
+
+func TestSelectVariant(t *testing.T) {
+	master, err := ParsePlaylist(strings.NewReader("#EXTM3U\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=96000,CODECS=\"mp4a.40.2\"\nmid.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=48000,CODECS=\"mp4a.40.5\"\nlow.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=192000,CODECS=\"mp4a.40.2\"\nhigh.m3u8\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		quality string
+		want    string
+	}{
+		{quality: "", want: "mid.m3u8"},
+		{quality: QualityHighest, want: "high.m3u8"},
+		{quality: QualityLowest, want: "low.m3u8"},
+		{quality: "48", want: "low.m3u8"},
+		{quality: "64k", want: "low.m3u8"},
+		{quality: "128", want: "mid.m3u8"},
+		{quality: "320", want: "high.m3u8"},
+	}
+	for _, tt := range tests {
+		if err := checkQuality(tt.quality); err != nil {
+			t.Errorf("checkQuality(%q) failed: %v", tt.quality, err)
+		}
+		if got := selectVariant(master, tt.quality).URI; got != tt.want {
+			t.Errorf("selectVariant(%q) = %s, want %s", tt.quality, got, tt.want)
+		}
+	}
+	for _, quality := range []string{"best", "0", "-48", "48kbps"} {
+		if err := checkQuality(quality); err == nil {
+			t.Errorf("checkQuality(%q) succeeded, want an error", quality)
+		}
+	}
+}
+
+func TestListTimedChunks(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=52973,CODECS=\"mp4a.40.5\"\nmedia/chunklist.m3u8\n")
+	})
+	mux.HandleFunc("/media/chunklist.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n#EXT-X-MEDIA-SEQUENCE:0\n#EXTINF:10,\na.aac\n#EXTINF:2.5,\nhttp://cdn.example/b.aac\n#EXT-X-ENDLIST\n")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := newRadiruClient(srv.Client(), srv.URL)
+	chunks, err := listChunks(context.Background(), client, srv.URL+"/master.m3u8")
+	if err != nil {
+		t.Fatalf("listChunks failed: %v", err)
+	}
+	want := []Chunk{
+		{URL: srv.URL + "/media/a.aac", Duration: 10 * time.Second},
+		{URL: "http://cdn.example/b.aac", Duration: 2500 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("got %+v, want %+v", chunks, want)
+	}
+	if got := chunksDuration(chunks); got != 12500*time.Millisecond {
+		t.Errorf("chunksDuration = %v", got)
+	}
+
+	// Providers without durations report chunks of unknown length.
+	plain, err := listChunks(context.Background(), &MockRadikoClient{}, "http://mock.m3u8/playlist.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain) != 2 || plain[0].Duration != 0 || chunksDuration(plain) != 0 {
+		t.Errorf("unexpected chunks %+v", plain)
+	}
+}
+
+func TestNewClientStreamQuality(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=48000\nlow.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=96000\nhigh.m3u8\n")
+	})
+	for _, variant := range []string{"low", "high"} {
+		mux.HandleFunc("/"+variant+".m3u8", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "#EXTM3U\n#EXTINF:5,\n%s.aac\n#EXT-X-ENDLIST\n", variant)
+		})
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// Options of different profiles record different variants from the same kind of provider.
+	tests := []struct {
+		quality string
+		want    string
+	}{
+		{quality: "", want: srv.URL + "/low.aac"},
+		{quality: QualityHighest, want: srv.URL + "/high.aac"},
+		{quality: "48", want: srv.URL + "/low.aac"},
+	}
+	for _, tt := range tests {
+		opts := Options{Quality: tt.quality, Providers: map[string]ProviderFactory{
+			ProviderRadiru: func(ctx context.Context) (Provider, error) { return newRadiruClient(srv.Client(), srv.URL), nil },
+		}}
+		client, err := opts.newClient(context.Background(), ScheduleEntry{Provider: ProviderRadiru})
+		if err != nil {
+			t.Fatalf("newClient(%q) failed: %v", tt.quality, err)
+		}
+		chunks, err := client.ListChunks(context.Background(), srv.URL+"/master.m3u8")
+		if err != nil {
+			t.Fatalf("ListChunks(%q) failed: %v", tt.quality, err)
+		}
+		if !reflect.DeepEqual(chunks, []string{tt.want}) {
+			t.Errorf("quality %q recorded %v, want %s", tt.quality, chunks, tt.want)
+		}
+	}
+
+	if _, err := (Options{Quality: "best"}).newClient(context.Background(), ScheduleEntry{}); err == nil {
+		t.Error("expected an unknown quality to fail")
+	}
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// japaneseHoliday returns the name of the Japanese public holiday (国民の祝日) on the day of t in JST,
+// following the Act on National Holidays as in force since 2020, including substitute holidays
+// (振替休日) and citizens' holidays (国民の休日). The equinox days use the astronomical approximation
+// the Cabinet Office announces them by, valid until 2099.
+func japaneseHoliday(t time.Time) (string, bool) {
+	t = t.In(JST)
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, JST)
+	if name, ok := namedHoliday(date); ok {
+		return name, true
+	}
+
+	// A Sunday holiday moves to the next day that is not a holiday itself.
+	for day := date.AddDate(0, 0, -1); ; day = day.AddDate(0, 0, -1) {
+		if _, ok := namedHoliday(day); !ok {
+			break
+		}
+		if day.Weekday() == time.Sunday {
+			return "振替休日", true
+		}
+	}
+
+	// A day between two holidays is a holiday as well.
+	_, before := namedHoliday(date.AddDate(0, 0, -1))
+	_, after := namedHoliday(date.AddDate(0, 0, 1))
+	if before && after && date.Weekday() != time.Sunday {
+		return "国民の休日", true
+	}
+	return "", false
+}
+
+// namedHoliday returns the holiday named by the act on date, leaving out substitute and citizens' holidays.
+func namedHoliday(date time.Time) (string, bool) {
+	year, month, day := date.Date()
+	nthMonday := func(n int) bool {
+		return date.Weekday() == time.Monday && (day-1)/7+1 == n
+	}
+	switch {
+	case month == time.January && day == 1:
+		return "元日", true
+	case month == time.January && nthMonday(2):
+		return "成人の日", true
+	case month == time.February && day == 11:
+		return "建国記念の日", true
+	case month == time.February && day == 23:
+		return "天皇誕生日", true
+	case month == time.March && day == equinoxDay(year, 20.8431):
+		return "春分の日", true
+	case month == time.April && day == 29:
+		return "昭和の日", true
+	case month == time.May && day == 3:
+		return "憲法記念日", true
+	case month == time.May && day == 4:
+		return "みどりの日", true
+	case month == time.May && day == 5:
+		return "こどもの日", true
+	case month == time.July && nthMonday(3):
+		return "海の日", true
+	case month == time.August && day == 11:
+		return "山の日", true
+	case month == time.September && nthMonday(3):
+		return "敬老の日", true
+	case month == time.September && day == equinoxDay(year, 23.2488):
+		return "秋分の日", true
+	case month == time.October && nthMonday(2):
+		return "スポーツの日", true
+	case month == time.November && day == 3:
+		return "文化の日", true
+	case month == time.November && day == 23:
+		return "勤労感謝の日", true
+	}
+	return "", false
+}
+
+// equinoxDay approximates the day of the vernal (base 20.8431) or autumnal (base 23.2488) equinox in year.
+func equinoxDay(year int, base float64) int {
+	return int(base+0.242194*float64(year-1980)) - (year-1980)/4
+}
+
+// skipReason returns why the broadcast of entry at start is not recorded, if it falls on one
+// of its SkipDates, a calendar day in loc, the schedule's time zone, or, with SkipHolidays, on a
+// public holiday in Japan.
+func skipReason(entry ScheduleEntry, start time.Time, loc *time.Location) (string, bool) {
+	day := start.In(scheduleLocation(loc)).Format("2006-01-02")
+	if slices.Contains(entry.SkipDates, day) {
+		return fmt.Sprintf("%s is in skip_dates", day), true
+	}
+	if entry.SkipHolidays {
+		if name, ok := japaneseHoliday(start); ok {
+			return fmt.Sprintf("%s is a public holiday (%s)", start.In(JST).Format("2006-01-02"), name), true
+		}
+	}
+	return "", false
+}
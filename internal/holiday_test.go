@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJapaneseHoliday(t *testing.T) {
+	tests := []struct {
+		date string
+		want string // "" for a working day
+	}{
+		{"2026-01-01", "元日"},
+		{"2026-01-12", "成人の日"},
+		{"2026-01-13", ""},
+		{"2025-02-24", "振替休日"}, // 天皇誕生日 on a Sunday
+		{"2026-03-20", "春分の日"},
+		{"2026-05-06", "振替休日"}, // 憲法記念日 on a Sunday, followed by two holidays
+		{"2026-07-20", "海の日"},
+		{"2026-09-22", "国民の休日"}, // Between 敬老の日 and 秋分の日
+		{"2026-09-23", "秋分の日"},
+		{"2026-10-12", "スポーツの日"},
+		{"2026-10-13", ""},
+	}
+	for _, tt := range tests {
+		day, err := time.ParseInLocation("2006-01-02", tt.date, JST)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Late at night still counts as the calendar day.
+		name, ok := japaneseHoliday(day.Add(23 * time.Hour))
+		if name != tt.want || ok != (tt.want != "") {
+			t.Errorf("%s: got %q (%v), want %q", tt.date, name, ok, tt.want)
+		}
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	entry := ScheduleEntry{DayOfWeek: "月", StartTime: "100000", SkipDates: []string{"2026-01-19"}}
+	monday := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST) // 成人の日
+
+	if _, skip := skipReason(entry, monday, nil); skip {
+		t.Error("expected holidays to be recorded without skip_holidays")
+	}
+	if reason, skip := skipReason(entry, monday.AddDate(0, 0, 7), nil); !skip || !strings.Contains(reason, "skip_dates") {
+		t.Errorf("expected the date in skip_dates to be skipped, got %q", reason)
+	}
+	entry.SkipHolidays = true
+	if reason, skip := skipReason(entry, monday, nil); !skip || !strings.Contains(reason, "成人の日") {
+		t.Errorf("expected the holiday to be skipped, got %q", reason)
+	}
+
+	// The next run passes over both the holiday and the skipped date.
+	next, err := CalculateNextRunTime(entry, monday.Add(-time.Hour), nil)
+	if err != nil || !next.Equal(monday.AddDate(0, 0, 14)) {
+		t.Errorf("expected the next run on 2026-01-26, got %s (%v)", next, err)
+	}
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icsDefaultDuration is the length of events whose broadcast is not in the guide.
+const icsDefaultDuration = time.Hour
+
+// icsTimezone defines the Asia/Tokyo zone the events are in. Japan has no daylight saving time.
+const icsTimezone = "BEGIN:VTIMEZONE\r\nTZID:Asia/Tokyo\r\nBEGIN:STANDARD\r\nDTSTART:19700101T000000\r\n" +
+	"TZOFFSETFROM:+0900\r\nTZOFFSETTO:+0900\r\nTZNAME:JST\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n"
+
+// FormatScheduleICS renders the resolved entries of BuildUpcoming as an iCalendar (RFC 5545)
+// calendar with a recurring event per entry, starting at its next broadcast, and a single
+// event per one-shot entry. Events are named after the guide title and keep the same UID across
+// exports, so calendar apps that subscribe to the file update them instead of adding duplicates.
+func FormatScheduleICS(items []PreviewItem, now time.Time) []byte {
+	var b strings.Builder
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//radikoRecScheduler//Schedule//EN")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "X-WR-CALNAME:radikoRecScheduler")
+	writeICSLine(&b, "X-WR-TIMEZONE:Asia/Tokyo")
+	b.WriteString(icsTimezone)
+	for _, item := range items {
+		if item.At.IsZero() {
+			continue
+		}
+		title, end := item.Title, item.End
+		if title == "" {
+			title = item.Entry.ProgramName
+		}
+		if !end.After(item.At) {
+			end = item.At.Add(icsDefaultDuration)
+		}
+		description := "Recorded by radikoRecScheduler."
+		if title != item.Entry.ProgramName {
+			description += " Schedule: " + item.Entry.ProgramName
+		}
+		if item.Entry.Note != "" {
+			description += "\n" + item.Entry.Note
+		}
+
+		writeICSLine(&b, "BEGIN:VEVENT")
+		writeICSLine(&b, "UID:"+icsUID(item.Entry))
+		writeICSLine(&b, "DTSTAMP:"+now.UTC().Format("20060102T150405Z"))
+		writeICSLine(&b, "DTSTART;TZID=Asia/Tokyo:"+item.At.In(JST).Format("20060102T150405"))
+		writeICSLine(&b, "DTEND;TZID=Asia/Tokyo:"+end.In(JST).Format("20060102T150405"))
+		if rule := icsRule(item.Entry, item.At); rule != "" {
+			writeICSLine(&b, "RRULE:"+rule)
+			for _, date := range item.Entry.SkipDates {
+				if day, err := time.ParseInLocation("2006-01-02", date, JST); err == nil {
+					writeICSLine(&b, "EXDATE;TZID=Asia/Tokyo:"+day.Format("20060102")+item.At.In(JST).Format("T150405"))
+				}
+			}
+		}
+		writeICSLine(&b, "SUMMARY:"+icsText(title))
+		writeICSLine(&b, "LOCATION:"+icsText(item.Entry.StationID))
+		writeICSLine(&b, "DESCRIPTION:"+icsText(description))
+		writeICSLine(&b, "END:VEVENT")
+	}
+	writeICSLine(&b, "END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// icsUID identifies the event of entry by its slot, which is what makes a schedule entry unique.
+func icsUID(entry ScheduleEntry) string {
+	sum := sha1.Sum([]byte(entry.StationID + "|" + entry.DayOfWeek + "|" + entry.StartTime + "|" + entry.Date))
+	return hex.EncodeToString(sum[:8]) + "@radikoRecScheduler"
+}
+
+// icsRule returns the recurrence rule of the events of entry, first broadcast at at,
+// or "" for a one-shot.
+func icsRule(entry ScheduleEntry, at time.Time) string {
+	switch {
+	case entry.OneShot():
+		return ""
+	case entry.EveryWeeks > 0:
+		return fmt.Sprintf("FREQ=WEEKLY;INTERVAL=%d", entry.EveryWeeks)
+	case len(entry.WeeksOfMonth) > 0:
+		day := strings.ToUpper(at.In(JST).Weekday().String()[:2])
+		days := make([]string, len(entry.WeeksOfMonth))
+		for i, week := range entry.WeeksOfMonth {
+			days[i] = strconv.Itoa(week) + day
+		}
+		return "FREQ=MONTHLY;BYDAY=" + strings.Join(days, ",")
+	}
+	return "FREQ=WEEKLY"
+}
+
+// icsText escapes s for a TEXT property value.
+func icsText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeICSLine writes a content line, folded so that no line exceeds 75 octets
+// without splitting a UTF-8 character.
+func writeICSLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // The leading space of a continuation line counts.
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
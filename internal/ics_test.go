@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatScheduleICS(t *testing.T) {
+	now := time.Date(2026, time.January, 11, 18, 0, 0, 0, JST)
+	entries := []ScheduleEntry{
+		{ProgramName: "櫻坂46 こちら有楽町星空放送局", DayOfWeek: "日", StartTime: "230000", StationID: "LFR", Note: "Guest; see notes, later"},
+		{ProgramName: "Gone", DayOfWeek: "水", StartTime: "120000", StationID: "TBS"},
+		{ProgramName: "Invalid", DayOfWeek: "X", StartTime: "120000", StationID: "TBS"},
+	}
+	items := BuildWeeklyPreview(context.Background(), entries, now, nil, func(ctx context.Context, stationID string) ([]byte, error) {
+		if stationID == "TBS" {
+			return nil, fmt.Errorf("guide unavailable")
+		}
+		return []byte(previewGuideXML), nil
+	})
+	data := string(FormatScheduleICS(items, now))
+
+	if !strings.HasPrefix(data, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(data, "END:VCALENDAR\r\n") {
+		t.Fatalf("not a calendar:\n%s", data)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(data, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+	if got := strings.Count(data, "BEGIN:VEVENT"); got != 2 {
+		t.Errorf("expected 2 events (the invalid entry skipped), got %d", got)
+	}
+
+	unfolded := strings.ReplaceAll(data, "\r\n ", "")
+	for _, want := range []string{
+		"DTSTART;TZID=Asia/Tokyo:20260111T230000\r\nDTEND;TZID=Asia/Tokyo:20260111T233000\r\nRRULE:FREQ=WEEKLY\r\nSUMMARY:別の番組\r\nLOCATION:LFR\r\n",
+		`DESCRIPTION:Recorded by radikoRecScheduler. Schedule: 櫻坂46 こちら有楽町星空放送局\nGuest\; see notes\, later`,
+		// Not in the guide: named after the entry and an hour long.
+		"DTSTART;TZID=Asia/Tokyo:20260114T120000\r\nDTEND;TZID=Asia/Tokyo:20260114T130000\r\nRRULE:FREQ=WEEKLY\r\nSUMMARY:Gone\r\n",
+		"UID:" + icsUID(entries[1]) + "\r\n",
+		"DTSTAMP:20260111T090000Z\r\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("calendar missing %q:\n%s", want, unfolded)
+		}
+	}
+
+	renamed := entries[1]
+	renamed.ProgramName = "Renamed"
+	if icsUID(renamed) != icsUID(entries[1]) || icsUID(entries[0]) == icsUID(entries[1]) {
+		t.Error("expected the UID to follow the slot of the entry")
+	}
+}
+
+func TestICSRule(t *testing.T) {
+	at := time.Date(2026, time.January, 6, 3, 0, 0, 0, JST) // Tuesday
+	tests := []struct {
+		entry ScheduleEntry
+		want  string
+	}{
+		{ScheduleEntry{DayOfWeek: "火"}, "FREQ=WEEKLY"},
+		{ScheduleEntry{Date: "2026-01-06"}, ""},
+		{ScheduleEntry{Date: "2026-01-06", EveryWeeks: 2}, "FREQ=WEEKLY;INTERVAL=2"},
+		{ScheduleEntry{DayOfWeek: "火", WeeksOfMonth: []int{1, 3, -1}}, "FREQ=MONTHLY;BYDAY=1TU,3TU,-1TU"},
+	}
+	for _, tt := range tests {
+		if got := icsRule(tt.entry, at); got != tt.want {
+			t.Errorf("icsRule(%+v) = %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// manifestExt is the extension of the manifest written next to every recording.
+const manifestExt = ".json"
+
+// manifestVersion is the layout version of Manifest, raised on incompatible changes.
+const manifestVersion = 1
+
+// Manifest describes a recording for library tools, so listings can be built without
+// parsing file names. It is written next to the recording as <name>.json.
+type Manifest struct {
+	Version     int       `json:"version"`
+	Title       string    `json:"title"`        // Title the recording was saved under
+	ProgramName string    `json:"program_name"` // program_name from the schedule entry
+	StationID   string    `json:"station_id"`
+	Provider    string    `json:"provider,omitempty"` // Schedule entry's provider; empty for radiko
+	Start       time.Time `json:"start"`              // Broadcast start time
+	End         time.Time `json:"end,omitzero"`       // Broadcast end time, when known from the guide or the window
+	Rerun       bool      `json:"rerun,omitempty"`    // Recorded from the entry's rerun slot
+	RecordedAt  time.Time `json:"recorded_at"`
+
+	// File is the recording, or the playlist of its Parts, relative to the manifest.
+	File string `json:"file"`
+	// Size and SHA256 describe the audio; the parts of a rotated recording are hashed as if concatenated.
+	Size      int64          `json:"size"`
+	SHA256    string         `json:"sha256"`
+	Parts     []ManifestPart `json:"parts,omitempty"`
+	Chunks    int            `json:"chunks"`                     // Chunks the recording was assembled from
+	Duration  float64        `json:"duration_seconds,omitempty"` // Audio length, when the playlist reports it
+	Chapters  string         `json:"chapters,omitempty"`         // Chapter file, relative to the manifest
+	ShowNotes string         `json:"show_notes,omitempty"`       // Show notes file, relative to the manifest
+
+	// Transcript is the transcript of File, relative to the manifest; rotated recordings
+	// have one per part instead.
+	Transcript string `json:"transcript,omitempty"`
+
+	// Guide is the program guide entry of the broadcast, with its description.
+	Guide *Prog `json:"guide,omitempty"`
+
+	// Warnings describe known defects of the recording, such as chunks left out of it.
+	Warnings []string `json:"warnings,omitempty"`
+	// Missing lists the chunks left out because they could not be downloaded (see
+	// JobOptions.TolerateMissingChunks); the audio skips from one side of each gap to the other.
+	Missing []ManifestGap `json:"missing_chunks,omitempty"`
+}
+
+// ManifestGap is a chunk left out of a recording.
+type ManifestGap struct {
+	Chunk    int     `json:"chunk"`                      // Index of the chunk in the playlist
+	Offset   float64 `json:"offset_seconds,omitempty"`   // Where in the broadcast it starts, when the playlist reports durations
+	Duration float64 `json:"duration_seconds,omitempty"` // Its length, when the playlist reports it
+	Error    string  `json:"error"`
+}
+
+// manifestGaps describes the chunks of failures, left out of a recording of chunks.
+func manifestGaps(chunks []Chunk, failures []ChunkFailure) []ManifestGap {
+	var gaps []ManifestGap
+	for _, f := range failures {
+		gaps = append(gaps, ManifestGap{
+			Chunk:    f.Index,
+			Offset:   chunksDuration(chunks[:f.Index]).Seconds(),
+			Duration: chunks[f.Index].Duration.Seconds(),
+			Error:    f.Err.Error(),
+		})
+	}
+	return gaps
+}
+
+// missingDuration returns the length of the chunks of failures, as far as the playlist reports it.
+func missingDuration(chunks []Chunk, failures []ChunkFailure) time.Duration {
+	var total time.Duration
+	for _, f := range failures {
+		total += chunks[f.Index].Duration
+	}
+	return total
+}
+
+// missingChunksWarning summarizes the chunks of failures left out of a recording of chunks.
+func missingChunksWarning(chunks []Chunk, failures []ChunkFailure) string {
+	indexes := make([]string, len(failures))
+	for i, f := range failures {
+		indexes[i] = strconv.Itoa(f.Index)
+	}
+	audio := missingDuration(chunks, failures)
+	warning := fmt.Sprintf("%d of %d chunks could not be downloaded and are missing from the recording (chunks %s)", len(failures), len(chunks), strings.Join(indexes, ", "))
+	if audio > 0 {
+		warning = fmt.Sprintf("%s, %s of audio", warning, formatClock(audio))
+	}
+	return warning + "."
+}
+
+// ManifestPart is one file of a rotated recording.
+type ManifestPart struct {
+	File       string `json:"file"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+	Transcript string `json:"transcript,omitempty"`
+}
+
+// manifestPath returns where the manifest of the recording at path is written.
+func manifestPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + manifestExt
+}
+
+// writeManifest completes m, whose Size and SHA256 are already set, with the files of the
+// recording at path and their transcripts (by audio file) and writes it next to the recording.
+// It returns the path of the manifest.
+func writeManifest(ctx context.Context, path string, m Manifest, transcripts map[string]string) (string, error) {
+	m.Version = manifestVersion
+	m.File = filepath.Base(path)
+	if m.Chapters != "" {
+		m.Chapters = filepath.Base(m.Chapters)
+	}
+	if m.ShowNotes != "" {
+		m.ShowNotes = filepath.Base(m.ShowNotes)
+	}
+	if transcript, ok := transcripts[path]; ok {
+		m.Transcript = filepath.Base(transcript)
+	}
+	if isPlaylist(path) {
+		files, err := recordingFiles(path)
+		if err != nil {
+			return "", err
+		}
+		for _, file := range files {
+			sum, n, err := hashFileContext(ctx, file)
+			if err != nil {
+				return "", fmt.Errorf("failed to hash '%s': %w", file, err)
+			}
+			part := ManifestPart{File: filepath.Base(file), Size: n, SHA256: hex.EncodeToString(sum)}
+			if transcript, ok := transcripts[file]; ok {
+				part.Transcript = filepath.Base(transcript)
+			}
+			m.Parts = append(m.Parts, part)
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	manifest := manifestPath(path)
+	if err := writeFileAtomic(manifest, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write manifest '%s': %w", manifest, err)
+	}
+	return manifest, nil
+}
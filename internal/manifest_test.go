@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteJobManifest(t *testing.T) {
+	client := &timedMockClient{}
+	for i := 0; i < 6; i++ {
+		client.chunks = append(client.chunks, Chunk{URL: fmt.Sprintf("http://mock.chunk/chunk%d.aac", i), Duration: 30 * time.Minute})
+	}
+	pastTime := time.Date(2026, time.January, 12, 18, 0, 0, 0, JST)
+	entry := ScheduleEntry{ProgramName: "After 6", DayOfWeek: "月", StartTime: "180000", StationID: "TBS"}
+	name := "20260112180000-TBS-アフター６ジャンクション"
+
+	tests := []struct {
+		name     string
+		rotation RotationConfig
+		file     string
+		parts    int
+	}{
+		{"single file", RotationConfig{}, name + ".aac", 0},
+		{"rotated", RotationConfig{MaxMinutes: 60}, name + ".m3u", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			opts := JobOptions{
+				OutputDir:  outputDir,
+				Quiet:      true,
+				Chapters:   true,
+				Rotation:   tt.rotation,
+				FetchGuide: func(context.Context, string) ([]byte, error) { return []byte(chaptersGuide), nil },
+			}
+			if _, err := ExecuteJob(context.Background(), client, entry, pastTime, opts); err != nil {
+				t.Fatalf("ExecuteJob failed: %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(outputDir, name+manifestExt))
+			if err != nil {
+				t.Fatalf("expected a manifest: %v", err)
+			}
+			var m Manifest
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Fatalf("invalid manifest: %v", err)
+			}
+
+			if m.Version != manifestVersion || m.File != tt.file || m.Title != "アフター６ジャンクション" || m.ProgramName != "After 6" || m.StationID != "TBS" {
+				t.Errorf("unexpected manifest: %+v", m)
+			}
+			if !m.Start.Equal(pastTime) || !m.End.Equal(pastTime.Add(3*time.Hour)) || m.Chunks != 6 || m.Duration != 3*60*60 {
+				t.Errorf("unexpected broadcast in manifest: start %v, end %v, %d chunks, %gs", m.Start, m.End, m.Chunks, m.Duration)
+			}
+			if m.Guide == nil || m.Guide.Info == "" || m.Chapters != name+chaptersExt {
+				t.Errorf("expected the guide entry and chapters, got %+v", m)
+			}
+			if len(m.Parts) != tt.parts {
+				t.Errorf("expected %d parts, got %+v", tt.parts, m.Parts)
+			}
+
+			files := []string{filepath.Join(outputDir, tt.file)}
+			if tt.parts > 0 {
+				if files, err = recordingFiles(files[0]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			h := sha256.New()
+			var size int64
+			for i, file := range files {
+				audio, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				h.Write(audio)
+				size += int64(len(audio))
+				if tt.parts > 0 {
+					sum := sha256.Sum256(audio)
+					if part := m.Parts[i]; part.File != filepath.Base(file) || part.Size != int64(len(audio)) || part.SHA256 != hex.EncodeToString(sum[:]) {
+						t.Errorf("unexpected part %d: %+v", i, part)
+					}
+				}
+			}
+			if m.Size != size || m.SHA256 != hex.EncodeToString(h.Sum(nil)) {
+				t.Errorf("manifest hash %s (%d bytes) does not match the audio", m.SHA256, m.Size)
+			}
+		})
+	}
+}
+
+func TestExecuteJobMissingChunks(t *testing.T) {
+	client := &timedMockClient{MockRadikoClient: MockRadikoClient{DoFn: func(req *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(req.URL.Path, "chunk3.aac") {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(dummyAACChunk))}, nil
+	}}}
+	for i := 0; i < 10; i++ {
+		client.chunks = append(client.chunks, Chunk{URL: fmt.Sprintf("http://mock.chunk/chunk%d.aac", i), Duration: 5 * time.Minute})
+	}
+	pastTime := time.Date(2026, time.January, 12, 18, 0, 0, 0, JST)
+	entry := ScheduleEntry{ProgramName: "After 6", DayOfWeek: "月", StartTime: "180000", StationID: "TBS"}
+	outputDir := t.TempDir()
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		Quiet:      true,
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+	}
+
+	if _, err := ExecuteJob(context.Background(), client, entry, pastTime, opts); err == nil {
+		t.Fatal("expected the job to fail on a missing chunk by default")
+	}
+
+	opts.TolerateMissingChunks = 10
+	result, err := ExecuteJob(context.Background(), client, entry, pastTime, opts)
+	if err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+	if result.Size != int64(9*len(dummyAACChunk)) {
+		t.Errorf("expected the recording to hold the other 9 chunks, got %d bytes", result.Size)
+	}
+	data, err := os.ReadFile(manifestPath(result.OutputPath))
+	if err != nil {
+		t.Fatalf("expected a manifest: %v", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	wantGaps := []ManifestGap{{Chunk: 3, Offset: 900, Duration: 300, Error: "HTTP status 404"}}
+	if !reflect.DeepEqual(m.Missing, wantGaps) || m.Duration != 2700 {
+		t.Errorf("unexpected gaps in manifest: %+v, %gs", m.Missing, m.Duration)
+	}
+	if want := []string{"1 of 10 chunks could not be downloaded and are missing from the recording (chunks 3), 0:05:00 of audio."}; !reflect.DeepEqual(m.Warnings, want) {
+		t.Errorf("manifest warnings = %q, want %q", m.Warnings, want)
+	}
+}
@@ -0,0 +1,95 @@
+package internal
+
+import "fmt"
+
+// Languages of console messages (Config.Lang).
+const (
+	LangEnglish  = "en" // Default.
+	LangJapanese = "ja"
+)
+
+// Output sets how console output is written. The zero value writes English messages and
+// ASCII progress bars.
+type Output struct {
+	// Lang is the language of console messages, including the errors of jobs and of the command
+	// line: LangEnglish, LangJapanese, or "" for English. Log prefixes (INFO:, WARNING:), program
+	// names and the causes wrapped by errors, e.g. network and file errors, are left as they are.
+	Lang string
+	// Charset is the charset of progress bars: CharsetASCII, CharsetUnicode, or "" for ASCII.
+	Charset string
+}
+
+// japaneseMessages translates console messages, keyed by their English format string.
+// The translations take the same verbs in the same order.
+var japaneseMessages = map[string]string{
+	// Progress.
+	"%d/%d chunks":                           "%d/%d チャンク",
+	"[%s] %s  %s  %s/s  ETA %s":              "[%s] %s  %s  %s/秒  残り %s",
+	"INFO: Downloaded %s (%s, %s/s, ETA %s)": "INFO: ダウンロード済み %s (%s, %s/秒, 残り %s)",
+
+	// Jobs.
+	"INFO: Starting recording for: %s (%s) for past broadcast at %s": "INFO: 録音を開始します: %s (%s) 放送日時 %s",
+	"INFO: Successfully found program name: %s":                      "INFO: 番組表から番組名を取得しました: %s",
+	"INFO: File already exists, skipping: %s":                        "INFO: 録音済みのためスキップします: %s",
+	"INFO: Successfully downloaded %d AAC chunks.":                   "INFO: %d 個の AAC チャンクをダウンロードしました。",
+	"INFO: Successfully recorded and saved to: %s":                   "INFO: 録音を保存しました: %s",
+	"Error executing job for '%s': %v":                               "「%s」の録音に失敗しました: %v",
+	"Error executing job for '%s' at %s: %v":                         "「%s」(%s) の録音に失敗しました: %v",
+
+	// Errors of jobs; the causes they wrap are left as they are.
+	"failed to read the recording to overwrite: %w":        "上書きする録音を読み込めませんでした: %w",
+	"failed to authenticate: %w":                           "認証に失敗しました: %w",
+	"failed to get timeshift M3U8 playlist URI for %s: %w": "「%s」のタイムフリー M3U8 プレイリストを取得できませんでした: %w",
+	"failed to get chunklist from M3U8 for %s: %w":         "「%s」のチャンクリストを M3U8 から取得できませんでした: %w",
+	"failed to create temporary directory: %w":             "一時ディレクトリを作成できませんでした: %w",
+	"failed to create output directory '%s': %w":           "出力ディレクトリ「%s」を作成できませんでした: %w",
+	"failed to bulk download AAC chunks for %s: %w":        "「%s」の AAC チャンクをダウンロードできませんでした: %w",
+	"incomplete recording of %s: %w":                       "「%s」の録音が不完全です: %w",
+	"failed to save recording for %s: %w":                  "「%s」の録音を保存できませんでした: %w",
+	"failed to transcode recording for %s: %w":             "「%s」の録音を変換できませんでした: %w",
+	"failed to upload recording for %s: %w":                "「%s」の録音をアップロードできませんでした: %w",
+	"unknown provider '%s'":                                "不明なプロバイダ「%s」",
+	"no notifier configured":                               "通知先が設定されていません",
+
+	// Errors of the command line.
+	"-overwrite cannot be used with -daemon":                          "-overwrite は -daemon と併用できません",
+	"Failed to get data directory: %v":                                "データディレクトリを取得できませんでした: %v",
+	"Failed to start daemon: %v":                                      "デーモンを起動できませんでした: %v",
+	"Failed to get job queue path: %v":                                "ジョブキューのパスを取得できませんでした: %v",
+	"Failed to set up post-store: %v":                                 "アップロード先を設定できませんでした: %v",
+	"Failed to get history path: %v":                                  "履歴のパスを取得できませんでした: %v",
+	"Failed to set up transcription: %v":                              "文字起こしを設定できませんでした: %v",
+	"Failed to load schedule from XDG path and current directory: %v": "XDG パスからもカレントディレクトリからもスケジュールを読み込めませんでした: %v",
+	"Failed to load schedule: %v":                                     "スケジュールを読み込めませんでした: %v",
+
+	// Summaries.
+	"FAIL %s: %v":               "失敗 %s: %v",
+	"SKIP %s: already recorded": "スキップ %s: 録音済み",
+	"OK   %s -> %s":             "成功 %s -> %s",
+	"OK   %s -> %s (%s)":        "成功 %s -> %s (%s)",
+	"RESULT\tPROGRAM\tSTATION\tBROADCAST\tDETAIL": "結果\t番組\t放送局\t放送日時\t詳細",
+	"SUCCEEDED":                              "成功",
+	"FAILED":                                 "失敗",
+	"SKIPPED":                                "スキップ",
+	"%d succeeded, %d failed, %d skipped.\n": "成功 %d 件、失敗 %d 件、スキップ %d 件。\n",
+}
+
+// checkLanguage validates the lang setting.
+func checkLanguage(lang string) error {
+	switch lang {
+	case "", LangEnglish, LangJapanese:
+		return nil
+	}
+	return fmt.Errorf("unknown language '%s': must be %s or %s", lang, LangEnglish, LangJapanese)
+}
+
+// Msg returns the console message format in the language of o.
+// Messages without a translation are returned unchanged.
+func (o Output) Msg(format string) string {
+	if o.Lang == LangJapanese {
+		if translated, ok := japaneseMessages[format]; ok {
+			return translated
+		}
+	}
+	return format
+}
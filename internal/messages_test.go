@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJapaneseMessagesKeepVerbs(t *testing.T) {
+	verb := regexp.MustCompile(`%[a-z]`)
+	for format, translated := range japaneseMessages {
+		if got, want := verb.FindAllString(translated, -1), verb.FindAllString(format, -1); !slices.Equal(got, want) {
+			t.Errorf("translation of %q has verbs %v, want %v", format, got, want)
+		}
+	}
+}
+
+func TestMsg(t *testing.T) {
+	tests := []struct {
+		lang, format, expected string
+	}{
+		{"", "FAIL %s: %v", "FAIL %s: %v"},
+		{LangEnglish, "FAIL %s: %v", "FAIL %s: %v"},
+		{LangJapanese, "FAIL %s: %v", "失敗 %s: %v"},
+		{LangJapanese, "WARNING: untranslated", "WARNING: untranslated"},
+	}
+	for _, tt := range tests {
+		if got := (Output{Lang: tt.lang}).Msg(tt.format); got != tt.expected {
+			t.Errorf("Msg(%q) in %q = %q, want %q", tt.format, tt.lang, got, tt.expected)
+		}
+	}
+}
+
+func TestTranslatedErrors(t *testing.T) {
+	opts := JobOptions{
+		Output:     Output{Lang: LangJapanese},
+		OutputDir:  t.TempDir(),
+		Logger:     log.New(io.Discard, "", 0),
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+		DisableProgressBar: true,
+	}
+	entry := ScheduleEntry{ProgramName: "Show", StationID: "ST1"}
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	tests := []struct {
+		name     string
+		client   *MockRadikoClient
+		expected string
+		is       error
+	}{
+		{
+			name:     "Authentication",
+			client:   &MockRadikoClient{AuthenticateFn: func(context.Context) error { return errors.New("403 Forbidden") }},
+			expected: "認証に失敗しました: ",
+			is:       ErrAuthFailed,
+		},
+		{
+			name: "Playlist",
+			client: &MockRadikoClient{ResolvePlaylistFn: func(context.Context, string, time.Time) (string, error) {
+				return "", ErrNotAired
+			}},
+			expected: "「Show」のタイムフリー M3U8 プレイリストを取得できませんでした: ",
+			is:       ErrNotAired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExecuteJob(context.Background(), tt.client, entry, pastTime, opts)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.expected) {
+				t.Errorf("ExecuteJob error = %v, want %q", err, tt.expected)
+			}
+			if !errors.Is(err, tt.is) {
+				t.Errorf("expected the translated error to wrap %v, got %v", tt.is, err)
+			}
+		})
+	}
+
+	if _, err := (Options{Output: Output{Lang: LangJapanese}}).newClient(context.Background(), ScheduleEntry{Provider: "ftp"}); err == nil || err.Error() != "不明なプロバイダ「ftp」" {
+		t.Errorf("newClient error = %v", err)
+	}
+}
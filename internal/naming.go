@@ -0,0 +1,328 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// Output layouts, selecting how recordings are named (Config.OutputLayout).
+const (
+	LayoutTimestamp = "timestamp" // <start>-<station>-<title>.aac (default)
+	LayoutTitle     = "title"     // <title>.aac, for libraries organized by episode title
+	LayoutSeries    = "series"    // <program>/Season <year>/<program> - <date>.m4a with NFO sidecars, for media servers
+)
+
+// Title collision strategies (Config.TitleCollision), selecting what is appended to the
+// file name when a different recording already exists under the same name.
+const (
+	CollisionDateSubtitle = "date_subtitle" // "<title> (<date> <subtitle>).aac" (default)
+	CollisionDate         = "date"          // "<title> (<date>).aac"
+	CollisionSubtitle     = "subtitle"      // "<title> (<subtitle>).aac", falling back to the date
+)
+
+// FileNameConfig controls how program titles are turned into file names.
+type FileNameConfig struct {
+	// Transliterate replaces characters or words of titles before they are used in file names,
+	// e.g. {"〜": "~", "♪": ""} for file systems or players that handle them badly.
+	Transliterate map[string]string `json:"transliterate,omitempty"`
+	// FoldWidth turns full-width letters, digits and punctuation (ＡＢＣ１２３！) into their ASCII
+	// forms and half-width katakana into full-width ones.
+	FoldWidth bool `json:"fold_width,omitempty"`
+	// MaxBytes caps the length of file names in bytes. Defaults to DefaultFileNameMaxBytes.
+	MaxBytes int `json:"max_bytes,omitempty"`
+}
+
+// DefaultFileNameMaxBytes is the file name limit of most file systems (ext4, APFS, NTFS in UTF-8).
+const DefaultFileNameMaxBytes = 255
+
+// fileNameReserve is kept free below the limit for what is appended to the name of a recording
+// while it is written, rotated or numbered, e.g. ".part01" or ".part.loudnorm".
+const fileNameReserve = 24
+
+// minFileNameMaxBytes leaves room for the timestamp, station and extension of a name.
+const minFileNameMaxBytes = 64
+
+// fileNames is the file name configuration, see ConfigureFileNames.
+var fileNames = struct {
+	transliterate *strings.Replacer
+	foldWidth     bool
+	maxBytes      int
+}{maxBytes: DefaultFileNameMaxBytes}
+
+// ConfigureFileNames sets how titles are turned into file names. It is meant to be called once at startup.
+func ConfigureFileNames(cfg FileNameConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	var pairs []string
+	for from, to := range cfg.Transliterate {
+		pairs = append(pairs, from, to)
+	}
+	fileNames.transliterate = nil
+	if len(pairs) > 0 {
+		fileNames.transliterate = strings.NewReplacer(pairs...)
+	}
+	fileNames.foldWidth = cfg.FoldWidth
+	fileNames.maxBytes = cfg.MaxBytes
+	if fileNames.maxBytes == 0 {
+		fileNames.maxBytes = DefaultFileNameMaxBytes
+	}
+	return nil
+}
+
+// validate checks the file name configuration.
+func (c FileNameConfig) validate() error {
+	if c.MaxBytes != 0 && c.MaxBytes < minFileNameMaxBytes {
+		return fmt.Errorf("max_bytes must be at least %d", minFileNameMaxBytes)
+	}
+	for from := range c.Transliterate {
+		if from == "" {
+			return fmt.Errorf("transliterate has an empty key")
+		}
+	}
+	return nil
+}
+
+// fileNameBudget is the length in bytes a recording's file name may have.
+func fileNameBudget() int {
+	return fileNames.maxBytes - fileNameReserve
+}
+
+// normalizeTitle prepares a title from the guide for use in a file name: it is composed to NFC,
+// so the same title from macOS (NFD) and elsewhere gives the same name, then transliterated
+// and width-folded as configured.
+func normalizeTitle(title string) string {
+	title = norm.NFC.String(title)
+	if fileNames.transliterate != nil {
+		title = fileNames.transliterate.Replace(title)
+	}
+	if fileNames.foldWidth {
+		title = norm.NFC.String(width.Fold.String(title))
+	}
+	return title
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a character, dropping trailing spaces.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	n = max(n, 0)
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return strings.TrimRight(s[:n], " ")
+}
+
+// outputFileName returns the file name of a recording in the given layout, with characters that
+// are not allowed in file names replaced (see sanitizeFileName). The title is normalized (see
+// normalizeTitle) and shortened as needed to keep the name within the configured length.
+func outputFileName(layout string, pastTime time.Time, stationID, title string, rerun bool) string {
+	if layout == LayoutSeries {
+		return seriesRecordingPath(title, pastTime, rerun)
+	}
+	overhead := len(sanitizeFileName(legacyOutputFileName(layout, pastTime, stationID, "", rerun)))
+	title = truncateUTF8(replaceFileNameChars(normalizeTitle(title)), fileNameBudget()-overhead)
+	return sanitizeFileName(legacyOutputFileName(layout, pastTime, stationID, title, rerun))
+}
+
+// seriesRecordingPath returns the path of a recording in the series layout, relative to the
+// output directory: a folder per show and a season per year, with the episode named after its
+// broadcast date the way Jellyfin, Plex and Kodi match date-based episodes.
+func seriesRecordingPath(show string, pastTime time.Time, rerun bool) string {
+	date := pastTime.In(JST).Format("2006-01-02")
+	suffix := ".m4a"
+	if rerun {
+		suffix = "-rerun" + suffix
+	}
+	show = truncateUTF8(replaceFileNameChars(normalizeTitle(show)), fileNameBudget()-len(" - "+date+suffix))
+	return filepath.Join(sanitizeFileName(show), "Season "+date[:4], sanitizeFileName(show+" - "+date+suffix))
+}
+
+// legacyOutputFileName returns the file name of a recording as it was before names were
+// sanitized, so recordings made back then are still recognized.
+func legacyOutputFileName(layout string, pastTime time.Time, stationID, title string, rerun bool) string {
+	base := fmt.Sprintf("%s-%s-%s", pastTime.Format("20060102150405"), stationID, title)
+	if layout == LayoutTitle {
+		base = title
+	}
+	if rerun {
+		base += "-rerun"
+	}
+	return base + ".aac"
+}
+
+// fileNameReplacer maps the characters Windows (NTFS, SMB shares) does not allow in file names,
+// and the path separators, to their full-width forms, which read the same in Japanese titles.
+var fileNameReplacer = strings.NewReplacer(
+	`\`, "＼", "/", "／", ":", "：", "*", "＊", "?", "？", `"`, "＂", "<", "＜", ">", "＞", "|", "｜",
+)
+
+// windowsReservedNames are device names Windows refuses as file names, with any extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFileName makes name safe to use as a file name on every platform: reserved
+// characters are replaced by their full-width forms, control characters are dropped and
+// Windows device names get an underscore appended.
+func sanitizeFileName(name string) string {
+	name = replaceFileNameChars(name)
+
+	stem, rest, _ := strings.Cut(name, ".")
+	if windowsReservedNames[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		name = stem + "_"
+		if rest != "" {
+			name += "." + rest
+		}
+	}
+	return name
+}
+
+// replaceFileNameChars replaces the reserved characters of name by their full-width forms and drops control characters.
+func replaceFileNameChars(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, fileNameReplacer.Replace(name))
+}
+
+// disambiguatedName appends the broadcast date and/or subtitle to fileName according to strategy.
+func disambiguatedName(fileName, strategy string, pastTime time.Time, subtitle string) string {
+	date := pastTime.Format("20060102")
+	subtitle = strings.TrimSpace(subtitle)
+
+	var tag string
+	switch {
+	case strategy == CollisionDate || subtitle == "":
+		tag = date
+	case strategy == CollisionSubtitle:
+		tag = subtitle
+	default:
+		tag = date + " " + subtitle
+	}
+	// A long subtitle gets up to half of the name, and the title is shortened to make room for the tag.
+	ext := filepath.Ext(fileName)
+	room := fileNameBudget() - len(ext) - len(" ()")
+	tag = truncateUTF8(sanitizeFileName(normalizeTitle(tag)), max(room/2, len(date)))
+	stem := truncateUTF8(strings.TrimSuffix(fileName, ext), room-len(tag))
+	return fmt.Sprintf("%s (%s)%s", stem, tag, ext)
+}
+
+// partialExt is appended to the name of a recording while it is being written. The file is
+// renamed into place once complete, so media servers and sync tools never pick up a truncated
+// recording, and a leftover partial file marks an interrupted run.
+const partialExt = ".part"
+
+// partialPath returns the path a recording meant for path is written to until it is complete.
+func partialPath(path string) string {
+	return path + partialExt
+}
+
+// writeFileAtomic writes data to path through a partial file, so path never holds a
+// truncated file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	partial := partialPath(path)
+	f, err := os.OpenFile(partial, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(partial, path)
+	}
+	if err != nil {
+		os.Remove(partial)
+	}
+	return err
+}
+
+// placeRecording moves the finished recording at tmpPath to target.
+// If a different recording already exists at target, the file is saved under a
+// disambiguated name instead (numbered if that is taken as well). If an identical
+// recording already exists, tmpPath is removed and existing is true.
+// It returns the path the recording ends up at.
+func placeRecording(tmpPath, target, strategy string, pastTime time.Time, subtitle string) (path string, existing bool, err error) {
+	for i := 0; ; i++ {
+		candidate := recordingCandidate(target, strategy, pastTime, subtitle, i)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			if err := os.Rename(tmpPath, candidate); err != nil {
+				return "", false, fmt.Errorf("failed to move recording to '%s': %w", candidate, err)
+			}
+			return candidate, false, nil
+		}
+
+		same, err := sameContents(tmpPath, candidate)
+		if err != nil {
+			return "", false, err
+		}
+		if same {
+			if err := os.Remove(tmpPath); err != nil {
+				return "", false, fmt.Errorf("failed to remove duplicate recording: %w", err)
+			}
+			return candidate, true, nil
+		}
+	}
+}
+
+// recordingCandidate returns the i-th name tried for a recording meant to be saved as target:
+// target itself, then its disambiguated name, then that name numbered.
+func recordingCandidate(target, strategy string, pastTime time.Time, subtitle string, i int) string {
+	dir, name := filepath.Split(target)
+	switch i {
+	case 0:
+		return target
+	case 1:
+		return filepath.Join(dir, disambiguatedName(name, strategy, pastTime, subtitle))
+	}
+	alternate := disambiguatedName(name, strategy, pastTime, subtitle)
+	ext := filepath.Ext(alternate)
+	return filepath.Join(dir, fmt.Sprintf("%s %d%s", strings.TrimSuffix(alternate, ext), i, ext))
+}
+
+// sameContents reports whether the files at a and b have the same SHA-256 hash.
+func sameContents(a, b string) (bool, error) {
+	hashA, err := fileSHA256(a)
+	if err != nil {
+		return false, err
+	}
+	hashB, err := fileSHA256(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(hashA, hashB), nil
+}
+
+func fileSHA256(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, fmt.Errorf("failed to hash '%s': %w", path, err)
+	}
+	return h.Sum(nil), nil
+}
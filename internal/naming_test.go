@@ -0,0 +1,359 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestDisambiguatedName(t *testing.T) {
+	at := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	tests := []struct {
+		strategy string
+		subtitle string
+		expected string
+	}{
+		{CollisionDateSubtitle, "第12回", "Show (20260112 第12回).aac"},
+		{"", "第12回", "Show (20260112 第12回).aac"},
+		{CollisionDate, "第12回", "Show (20260112).aac"},
+		{CollisionSubtitle, "第12回", "Show (第12回).aac"},
+		{CollisionSubtitle, "", "Show (20260112).aac"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy+"/"+tt.subtitle, func(t *testing.T) {
+			if got := disambiguatedName("Show.aac", tt.strategy, at, tt.subtitle); got != tt.expected {
+				t.Errorf("disambiguatedName = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPlaceRecording(t *testing.T) {
+	at := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	dir := t.TempDir()
+	target := filepath.Join(dir, "Show.aac")
+
+	place := func(content string) (string, bool) {
+		t.Helper()
+		tmp := filepath.Join(dir, "Show.aac.part")
+		if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write recording: %v", err)
+		}
+		path, existing, err := placeRecording(tmp, target, CollisionDate, at, "")
+		if err != nil {
+			t.Fatalf("placeRecording failed: %v", err)
+		}
+		if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+			t.Errorf("partial file left behind: %v", err)
+		}
+		return filepath.Base(path), existing
+	}
+
+	steps := []struct {
+		content  string
+		expected string
+		existing bool
+	}{
+		{"episode 1", "Show.aac", false},
+		{"episode 1", "Show.aac", true},
+		{"episode 2", "Show (20260112).aac", false},
+		{"episode 2", "Show (20260112).aac", true},
+		{"episode 3", "Show (20260112) 2.aac", false},
+	}
+	for i, step := range steps {
+		path, existing := place(step.content)
+		if path != step.expected || existing != step.existing {
+			t.Errorf("step %d: placeRecording = (%s, %v), want (%s, %v)", i, path, existing, step.expected, step.existing)
+		}
+	}
+}
+
+func TestExecuteJobTitleLayout(t *testing.T) {
+	outputDir := t.TempDir()
+	history := OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	entry := ScheduleEntry{ProgramName: "Show", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		Logger:     log.New(io.Discard, "", 0),
+		History:    history,
+		Layout:     LayoutTitle,
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+		DisableProgressBar: true,
+	}
+
+	// A different episode already holds the title.
+	if err := os.WriteFile(filepath.Join(outputDir, "Show.aac"), []byte("older episode"), 0644); err != nil {
+		t.Fatalf("Failed to write existing recording: %v", err)
+	}
+
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	requests := 0
+	client := &MockRadikoClient{
+		ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+			requests++
+			return "http://mock.m3u8/playlist.m3u8", nil
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := ExecuteJob(context.Background(), client, entry, pastTime, opts); err != nil {
+			t.Fatalf("ExecuteJob failed: %v", err)
+		}
+	}
+
+	expected := filepath.Join(outputDir, "Show (20260112).aac")
+	if _, err := os.Stat(expected); err != nil {
+		t.Errorf("expected disambiguated recording %s: %v", expected, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(outputDir, "Show.aac")); string(data) != "older episode" {
+		t.Errorf("existing recording was overwritten: %q", data)
+	}
+	if requests != 1 {
+		t.Errorf("expected the second run to skip the recorded broadcast, got %d downloads", requests)
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"Show.aac", "Show.aac"},
+		{"Re:Zero? <特別編>.aac", "Re：Zero？ ＜特別編＞.aac"},
+		{`AC/DC "Live" | *1*\2.aac`, `AC／DC ＂Live＂ ｜ ＊1＊＼2.aac`},
+		{"Tab\there\n.aac", "Tabhere.aac"},
+		{"CON.aac", "CON_.aac"},
+		{"com1", "com1_"},
+		{"CONCERT.aac", "CONCERT.aac"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFileName(tt.name); got != tt.expected {
+				t.Errorf("sanitizeFileName(%q) = %q, want %q", tt.name, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOutputFileNameNormalization(t *testing.T) {
+	at := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	longTitle := strings.Repeat("オールナイトニッポン", 20) // 600 bytes
+	tests := []struct {
+		name     string
+		cfg      FileNameConfig
+		layout   string
+		title    string
+		rerun    bool
+		expected string
+	}{
+		{"NFD is composed", FileNameConfig{}, LayoutTitle, "ハ\u309aン", false, "パン.aac"},
+		{"full width kept by default", FileNameConfig{}, LayoutTitle, "ＡＢＣ！ラジオ", false, "ＡＢＣ！ラジオ.aac"},
+		{"width folded", FileNameConfig{FoldWidth: true}, LayoutTitle, "ＡＢＣ！ｱﾆﾒ／ラジオ", false, "ABC!アニメ／ラジオ.aac"},
+		{"transliterated", FileNameConfig{Transliterate: map[string]string{"〜": "~", "♪": ""}}, LayoutTitle, "♪ミュージック〜", false, "ミュージック~.aac"},
+		{"long title truncated", FileNameConfig{}, LayoutTimestamp, longTitle, false,
+			"20260112100000-LFR-" + strings.Repeat("オールナイトニッポン", 6) + "オールナイトニッポ.aac"},
+		{"rerun suffix kept", FileNameConfig{MaxBytes: 100}, LayoutTitle, longTitle, true,
+			"オールナイトニッポンオールナイトニッポンオー-rerun.aac"},
+		{"series folders", FileNameConfig{}, LayoutSeries, "Re:Zero から始める", false,
+			filepath.Join("Re：Zero から始める", "Season 2026", "Re：Zero から始める - 2026-01-12.m4a")},
+		{"series rerun", FileNameConfig{}, LayoutSeries, "Show", true, filepath.Join("Show", "Season 2026", "Show - 2026-01-12-rerun.m4a")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ConfigureFileNames(tt.cfg); err != nil {
+				t.Fatalf("ConfigureFileNames failed: %v", err)
+			}
+			t.Cleanup(func() { ConfigureFileNames(FileNameConfig{}) })
+			got := outputFileName(tt.layout, at, "LFR", tt.title, tt.rerun)
+			if got != tt.expected {
+				t.Errorf("outputFileName = %q, want %q", got, tt.expected)
+			}
+			if !utf8.ValidString(got) || len(got) > fileNameBudget() {
+				t.Errorf("outputFileName = %q (%d bytes), want valid UTF-8 of at most %d bytes", got, len(got), fileNameBudget())
+			}
+		})
+	}
+}
+
+func TestDisambiguatedNameLong(t *testing.T) {
+	at := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	title := strings.Repeat("あ", 77) + ".aac" // 231 bytes, at the budget
+	subtitle := strings.Repeat("い", 100)
+	got := disambiguatedName(title, CollisionDateSubtitle, at, subtitle)
+	if len(got) > fileNameBudget() || !utf8.ValidString(got) {
+		t.Fatalf("disambiguatedName = %q (%d bytes), want valid UTF-8 of at most %d bytes", got, len(got), fileNameBudget())
+	}
+	if !strings.Contains(got, " (20260112 い") || !strings.HasSuffix(got, ").aac") {
+		t.Errorf("disambiguatedName = %q, want the date and subtitle kept", got)
+	}
+}
+
+func TestConfigureFileNamesInvalid(t *testing.T) {
+	for _, cfg := range []FileNameConfig{
+		{MaxBytes: 10},
+		{Transliterate: map[string]string{"": "x"}},
+	} {
+		if err := ConfigureFileNames(cfg); err == nil {
+			t.Errorf("ConfigureFileNames(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
+
+func TestExecuteJobLegacyFileName(t *testing.T) {
+	outputDir := t.TempDir()
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	entry := ScheduleEntry{ProgramName: "Re:Zero", StationID: "ST1"}
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		Logger:     log.New(io.Discard, "", 0),
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+		DisableProgressBar: true,
+	}
+
+	// Recorded before file names were sanitized.
+	legacy := filepath.Join(outputDir, "20260112100000-ST1-Re:Zero.aac")
+	if err := os.WriteFile(legacy, []byte("recorded"), 0644); err != nil {
+		t.Fatalf("Failed to write existing recording: %v", err)
+	}
+
+	client := &MockRadikoClient{
+		ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+			t.Error("expected the legacy recording to be recognized")
+			return "http://mock.m3u8/playlist.m3u8", nil
+		},
+	}
+	if _, err := ExecuteJob(context.Background(), client, entry, pastTime, opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+
+	// New recordings use the sanitized name.
+	os.Remove(legacy)
+	client.ResolvePlaylistFn = nil
+	if _, err := ExecuteJob(context.Background(), client, entry, pastTime, opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "20260112100000-ST1-Re：Zero.aac")); err != nil {
+		t.Errorf("expected a sanitized file name: %v", err)
+	}
+}
+
+func TestExecuteJobPartialRecording(t *testing.T) {
+	outputDir := t.TempDir()
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	var logs bytes.Buffer
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		Logger:     log.New(&logs, "", 0),
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+		DisableProgressBar: true,
+	}
+
+	// Left by a run interrupted while writing the recording.
+	target := filepath.Join(outputDir, "20260112100000-ST1-Show.aac")
+	if err := os.WriteFile(partialPath(target), []byte("trunc"), 0644); err != nil {
+		t.Fatalf("Failed to write partial recording: %v", err)
+	}
+
+	entry := ScheduleEntry{ProgramName: "Show", StationID: "ST1"}
+	if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+	if !strings.Contains(logs.String(), "interrupted run") {
+		t.Errorf("expected a warning about the partial recording, got:\n%s", logs.String())
+	}
+	if data, err := os.ReadFile(target); err != nil || string(data) == "trunc" {
+		t.Errorf("expected a complete recording at %s (%v)", target, err)
+	}
+	if _, err := os.Stat(partialPath(target)); !os.IsNotExist(err) {
+		t.Errorf("expected the partial file to be gone: %v", err)
+	}
+}
+
+func TestExecuteJobOverwrite(t *testing.T) {
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	entry := ScheduleEntry{ProgramName: "Show", StationID: "ST1"}
+	tests := []struct {
+		name     string
+		layout   string
+		existing map[string]string // Files of the earlier recording(s).
+		expected string            // The new recording.
+		removed  []string
+		kept     []string
+	}{
+		{
+			name:     "timestamp",
+			existing: map[string]string{"20260112100000-ST1-Show.aac": "old"},
+			expected: "20260112100000-ST1-Show.aac",
+		},
+		{
+			name: "rotated",
+			existing: map[string]string{
+				"20260112100000-ST1-Show.m3u":        "#EXTM3U\n20260112100000-ST1-Show.part01.aac\n20260112100000-ST1-Show.part02.aac\n",
+				"20260112100000-ST1-Show.part01.aac": "old",
+				"20260112100000-ST1-Show.part02.aac": "old",
+			},
+			expected: "20260112100000-ST1-Show.aac",
+			removed:  []string{"20260112100000-ST1-Show.m3u", "20260112100000-ST1-Show.part01.aac", "20260112100000-ST1-Show.part02.aac"},
+		},
+		{
+			name:   "title",
+			layout: LayoutTitle,
+			existing: map[string]string{
+				"Show.aac":            "other episode",
+				"Show (20260112).aac": "old",
+			},
+			expected: "Show (20260112).aac",
+			kept:     []string{"Show.aac"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			for name, data := range tt.existing {
+				if err := os.WriteFile(filepath.Join(outputDir, name), []byte(data), 0644); err != nil {
+					t.Fatalf("Failed to write existing recording: %v", err)
+				}
+			}
+			opts := JobOptions{
+				OutputDir:  outputDir,
+				Logger:     log.New(io.Discard, "", 0),
+				Layout:     tt.layout,
+				Overwrite:  true,
+				FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+				DisableProgressBar: true,
+			}
+			if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err != nil {
+				t.Fatalf("ExecuteJob failed: %v", err)
+			}
+
+			if data, err := os.ReadFile(filepath.Join(outputDir, tt.expected)); err != nil || !strings.Contains(string(data), dummyAACChunk) {
+				t.Errorf("expected a new recording at %s, got %q (%v)", tt.expected, data, err)
+			}
+			for _, name := range tt.removed {
+				if _, err := os.Stat(filepath.Join(outputDir, name)); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed: %v", name, err)
+				}
+			}
+			for _, name := range tt.kept {
+				if data, _ := os.ReadFile(filepath.Join(outputDir, name)); string(data) != tt.existing[name] {
+					t.Errorf("expected %s to be kept, got %q", name, data)
+				}
+			}
+			// The new recording and its manifest, next to the kept files.
+			if entries, _ := os.ReadDir(outputDir); len(entries) != 2+len(tt.kept) {
+				t.Errorf("unexpected files left: %v", entries)
+			}
+		})
+	}
+}
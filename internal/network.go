@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// NetworkConfig configures the outgoing HTTP requests (auth, playlists, chunks, program guides, ...).
+type NetworkConfig struct {
+	// Proxy is an http://, https:// or socks5:// proxy URL. Defaults to the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string `json:"proxy"`
+	// UserAgent replaces the User-Agent header of every request.
+	UserAgent string `json:"user_agent"`
+	// RequestTimeoutSeconds limits each provider call and chunk download. Defaults to DefaultRequestTimeout.
+	RequestTimeoutSeconds int `json:"request_timeout_seconds,omitempty"`
+
+	// CAFile is a PEM file of CA certificates that servers' certificates must chain to instead of
+	// the system's roots, e.g. the CA of a TLS-intercepting proxy on a corporate network.
+	CAFile string `json:"ca_file,omitempty"`
+	// InsecureSkipVerify accepts any server certificate. It is meant for debugging; prefer CAFile.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+
+	// Headers adds headers to the playlist and chunk requests of each provider, by provider name,
+	// for CDNs that require e.g. a Referer.
+	Headers map[string]map[string]string `json:"headers,omitempty"`
+}
+
+// defaultTransport is http.DefaultTransport as it was before ConfigureNetwork replaced it.
+var defaultTransport = http.DefaultTransport.(*http.Transport)
+
+// NewTransport returns a RoundTripper that sends requests through the proxy and with the User-Agent of cfg,
+// verifying server certificates as cfg sets.
+func NewTransport(cfg NetworkConfig) (http.RoundTripper, error) {
+	transport := defaultTransport.Clone()
+	if err := configureTLS(transport, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy '%s': %w", cfg.Proxy, err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("invalid proxy '%s': scheme must be http, https or socks5", cfg.Proxy)
+		}
+		if proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy '%s': missing host", cfg.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if cfg.UserAgent == "" {
+		return transport, nil
+	}
+	return &userAgentTransport{base: transport, userAgent: cfg.UserAgent}, nil
+}
+
+// configureTLS sets how transport verifies server certificates: against the CAs of cfg.CAFile,
+// not at all with cfg.InsecureSkipVerify, or else against the system's roots.
+func configureTLS(transport *http.Transport, cfg NetworkConfig) error {
+	if cfg.CAFile == "" && !cfg.InsecureSkipVerify {
+		return nil
+	}
+	if cfg.CAFile != "" && cfg.InsecureSkipVerify {
+		return fmt.Errorf("ca_file and insecure_skip_verify cannot be used together")
+	}
+	tlsConfig := &tls.Config{}
+	if transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	}
+	if cfg.InsecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true
+	} else {
+		data, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no PEM certificates in CA file '%s'", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+	return nil
+}
+
+// ConfigureNetwork makes every HTTP request of the process, including those made by go-radiko
+// (which only uses the default transport), go through the proxy, User-Agent and certificate
+// verification of cfg. The Headers of cfg go to Options.Headers instead, see ParseProviderHeaders.
+func ConfigureNetwork(cfg NetworkConfig) error {
+	if cfg.Proxy != "" || cfg.UserAgent != "" || cfg.CAFile != "" || cfg.InsecureSkipVerify {
+		transport, err := NewTransport(cfg)
+		if err != nil {
+			return err
+		}
+		http.DefaultTransport = transport
+	}
+	return nil
+}
+
+// ParseProviderHeaders checks the header names and values of NetworkConfig.Headers and returns
+// them by provider, for Options.Headers.
+func ParseProviderHeaders(headers map[string]map[string]string) (map[string]http.Header, error) {
+	parsed := make(map[string]http.Header, len(headers))
+	for provider, fields := range headers {
+		header := make(http.Header, len(fields))
+		for name, value := range fields {
+			if !httpguts.ValidHeaderFieldName(name) {
+				return nil, fmt.Errorf("invalid header name '%s' for provider '%s'", name, provider)
+			}
+			if !httpguts.ValidHeaderFieldValue(value) {
+				return nil, fmt.Errorf("invalid value of header '%s' for provider '%s'", name, provider)
+			}
+			header.Set(name, value)
+		}
+		parsed[provider] = header
+	}
+	return parsed, nil
+}
+
+// withHeaders returns a client sending the requests of client with header and the configured
+// extra headers, which take precedence.
+func withHeaders(client httpDoer, header, extra http.Header) httpDoer {
+	header = header.Clone()
+	for name, values := range extra {
+		if header == nil {
+			header = make(http.Header)
+		}
+		header[name] = values
+	}
+	if len(header) == 0 {
+		return client
+	}
+	return headerDoer{base: client, header: header}
+}
+
+// headerDoer sets headers on every request it sends.
+type headerDoer struct {
+	base   httpDoer
+	header http.Header
+}
+
+func (d headerDoer) Do(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context()) // Like a RoundTripper, leave the caller's request alone.
+	for name, values := range d.header {
+		req.Header[name] = values
+	}
+	return d.base.Do(req)
+}
+
+// userAgentTransport sets the User-Agent header of every request, overriding the one set by the caller.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context()) // A RoundTripper must not modify the caller's request.
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
+
+// httpsDoer sends plain HTTP requests over HTTPS instead, for servers that all speak TLS, so a
+// playlist listing http:// URLs cannot make the auth token travel in the clear.
+type httpsDoer struct {
+	base httpDoer
+}
+
+func (d httpsDoer) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = "https"
+	}
+	return d.base.Do(req)
+}
@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewTransport(t *testing.T) {
+	var gotHost, gotAgent string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost, gotAgent = r.URL.Host, r.UserAgent()
+		io.WriteString(w, "proxied")
+	}))
+	defer proxy.Close()
+
+	transport, err := NewTransport(NetworkConfig{Proxy: proxy.URL, UserAgent: "radikoRecScheduler-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: transport}
+	req, _ := http.NewRequest(http.MethodGet, "http://radiko.example/v3/program/station/weekly/TBS.xml", nil)
+	req.Header.Set("User-Agent", "go-radiko")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "proxied" {
+		t.Errorf("request did not go through the proxy: %q", body)
+	}
+	if gotHost != "radiko.example" || gotAgent != "radikoRecScheduler-test" {
+		t.Errorf("proxy saw host %q and User-Agent %q", gotHost, gotAgent)
+	}
+	if req.Header.Get("User-Agent") != "go-radiko" {
+		t.Error("the caller's request was modified")
+	}
+
+	for _, bad := range []string{"ftp://proxy:21", "socks5://", "://"} {
+		if _, err := NewTransport(NetworkConfig{Proxy: bad}); err == nil || !strings.Contains(err.Error(), "invalid proxy") {
+			t.Errorf("expected an invalid proxy error for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestProviderHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		headers map[string]map[string]string
+		want    map[string]string // Headers of a radiko chunk request; "" means absent.
+	}{
+		{name: "auth token", want: map[string]string{"X-Radiko-AuthToken": "token", "Referer": ""}},
+		{
+			name:    "configured",
+			headers: map[string]map[string]string{ProviderRadiko: {"referer": "https://radiko.jp/"}},
+			want:    map[string]string{"X-Radiko-AuthToken": "token", "Referer": "https://radiko.jp/"},
+		},
+		{
+			name:    "configured token",
+			headers: map[string]map[string]string{ProviderRadiko: {"X-Radiko-AuthToken": "configured"}},
+			want:    map[string]string{"X-Radiko-AuthToken": "configured"},
+		},
+		{
+			name:    "other provider",
+			headers: map[string]map[string]string{ProviderRadiru: {"Referer": "https://www.nhk.or.jp/"}},
+			want:    map[string]string{"X-Radiko-AuthToken": "token", "Referer": ""},
+		},
+	}
+	for _, tt := range tests {
+		headers, err := ParseProviderHeaders(tt.headers)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		// As goradikoClient.streamClient, which cannot be created without reaching radiko.
+		client := withHeaders(server.Client(), http.Header{"X-Radiko-Authtoken": {"token"}}, headers[ProviderRadiko])
+		body, err := httpGet(context.Background(), client, server.URL+"/chunk.aac")
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tt.name, err)
+		}
+		body.Close()
+		for name, want := range tt.want {
+			if got.Get(name) != want {
+				t.Errorf("%s: header %s = %q, want %q", tt.name, name, got.Get(name), want)
+			}
+		}
+	}
+
+	for _, bad := range []map[string]string{{"Bad Name": "x"}, {"Referer": "a\nb"}} {
+		if _, err := ParseProviderHeaders(map[string]map[string]string{ProviderRadiko: bad}); err == nil {
+			t.Errorf("expected an error for headers %q", bad)
+		}
+	}
+}
+
+func TestNewClientHeaders(t *testing.T) {
+	var mu sync.Mutex
+	referers := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		referers[r.URL.Path] = r.Header.Get("Referer")
+	}))
+	defer server.Close()
+
+	// The options of two profiles give the same provider different headers.
+	radiru := func(ctx context.Context) (Provider, error) { return newRadiruClient(server.Client(), server.URL), nil }
+	first := Options{Providers: map[string]ProviderFactory{ProviderRadiru: radiru}, Headers: map[string]http.Header{ProviderRadiru: {"Referer": {"https://first.example/"}}}}
+	second := Options{Providers: map[string]ProviderFactory{ProviderRadiru: radiru}, Headers: map[string]http.Header{ProviderRadiko: {"Referer": {"https://radiko.jp/"}}}}
+	for path, opts := range map[string]Options{"/first.aac": first, "/second.aac": second} {
+		client, err := opts.newClient(context.Background(), ScheduleEntry{Provider: ProviderRadiru})
+		if err != nil {
+			t.Fatalf("newClient failed: %v", err)
+		}
+		body, err := client.Download(context.Background(), server.URL+path)
+		if err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+		body.Close()
+	}
+	if want := map[string]string{"/first.aac": "https://first.example/", "/second.aac": ""}; !reflect.DeepEqual(referers, want) {
+		t.Errorf("got Referers %v, want %v", referers, want)
+	}
+}
+
+func TestNewTransportTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	junkFile := filepath.Join(t.TempDir(), "junk.pem")
+	if err := os.WriteFile(junkFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     NetworkConfig
+		wantErr string // Error of NewTransport.
+		reaches bool   // Whether the test server is accepted.
+	}{
+		{name: "system roots", cfg: NetworkConfig{}},
+		{name: "ca file", cfg: NetworkConfig{CAFile: caFile}, reaches: true},
+		{name: "insecure", cfg: NetworkConfig{InsecureSkipVerify: true}, reaches: true},
+		{name: "both", cfg: NetworkConfig{CAFile: caFile, InsecureSkipVerify: true}, wantErr: "cannot be used together"},
+		{name: "missing ca file", cfg: NetworkConfig{CAFile: filepath.Join(t.TempDir(), "none.pem")}, wantErr: "failed to read CA file"},
+		{name: "junk ca file", cfg: NetworkConfig{CAFile: junkFile}, wantErr: "no PEM certificates"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, err := NewTransport(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewTransport error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if reached := err == nil; reached != tt.reaches {
+				t.Errorf("request error = %v, want reaching the server %v", err, tt.reaches)
+			}
+		})
+	}
+}
+
+func TestHTTPSDoer(t *testing.T) {
+	var schemes []string
+	doer := httpsDoer{base: doerFunc(func(req *http.Request) (*http.Response, error) {
+		schemes = append(schemes, req.URL.Scheme)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})}
+	for _, uri := range []string{"http://radiko.jp/chunk.aac", "https://radiko.jp/chunk.aac"} {
+		req, _ := http.NewRequest(http.MethodGet, uri, nil)
+		if _, err := doer.Do(req); err != nil {
+			t.Fatal(err)
+		}
+		if req.URL.Scheme != strings.Split(uri, ":")[0] {
+			t.Error("the caller's request was modified")
+		}
+	}
+	if strings.Join(schemes, ",") != "https,https" {
+		t.Errorf("sent schemes %v, want https only", schemes)
+	}
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// nfoExt is the extension of the episode metadata written next to recordings in LayoutSeries.
+const nfoExt = ".nfo"
+
+// tvShowNFO is the name of the show metadata in the show's folder.
+const tvShowNFO = "tvshow.nfo"
+
+// episodeNFO is the Kodi episodedetails document read by Jellyfin, Emby and Kodi, and by
+// Plex with an NFO agent.
+type episodeNFO struct {
+	XMLName   xml.Name `xml:"episodedetails"`
+	Title     string   `xml:"title"`
+	ShowTitle string   `xml:"showtitle"`
+	Season    int      `xml:"season"`
+	Aired     string   `xml:"aired"`
+	Plot      string   `xml:"plot,omitempty"`
+	Runtime   int      `xml:"runtime,omitempty"` // Minutes.
+	Studio    string   `xml:"studio"`
+	Credits   string   `xml:"credits,omitempty"`
+}
+
+// showNFO is the Kodi tvshow document of a show's folder.
+type showNFO struct {
+	XMLName xml.Name `xml:"tvshow"`
+	Title   string   `xml:"title"`
+	Studio  string   `xml:"studio"`
+}
+
+// formatEpisodeNFO renders the metadata of the broadcast of show on stationID at start, titled
+// after the guide entry prog when there is one.
+func formatEpisodeNFO(show string, prog *Prog, stationID string, start time.Time) ([]byte, error) {
+	start = start.In(JST)
+	nfo := episodeNFO{
+		Title:     show,
+		ShowTitle: show,
+		Season:    start.Year(),
+		Aired:     start.Format("2006-01-02"),
+		Studio:    stationID,
+	}
+	if prog != nil {
+		nfo.Title = prog.Title
+		if prog.SubTitle != "" {
+			nfo.Title += " " + prog.SubTitle
+		}
+		nfo.Plot = guideText(prog.Desc)
+		if info := guideText(prog.Info); info != "" {
+			nfo.Plot = strings.TrimSpace(nfo.Plot + "\n\n" + info)
+		}
+		nfo.Runtime = int(guideDuration(prog).Minutes())
+		nfo.Credits = prog.Pfm
+	}
+	return marshalNFO(nfo)
+}
+
+func marshalNFO(v any) ([]byte, error) {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// writeSeriesNFO writes the episode metadata next to the recording at path, and the show
+// metadata two folders up unless it exists, e.g. edited by hand. It returns the episode
+// metadata's path.
+func writeSeriesNFO(path, show string, prog *Prog, stationID string, start time.Time) (string, error) {
+	episode, err := formatEpisodeNFO(show, prog, stationID, start)
+	if err != nil {
+		return "", err
+	}
+	nfoPath := strings.TrimSuffix(path, filepath.Ext(path)) + nfoExt
+	if err := writeFileAtomic(nfoPath, episode, 0644); err != nil {
+		return "", fmt.Errorf("failed to write NFO '%s': %w", nfoPath, err)
+	}
+
+	showPath := filepath.Join(filepath.Dir(filepath.Dir(path)), tvShowNFO)
+	if _, err := os.Stat(showPath); os.IsNotExist(err) {
+		data, err := marshalNFO(showNFO{Title: show, Studio: stationID})
+		if err != nil {
+			return "", err
+		}
+		if err := writeFileAtomic(showPath, data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write NFO '%s': %w", showPath, err)
+		}
+	}
+	return nfoPath, nil
+}
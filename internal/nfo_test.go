@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatEpisodeNFO(t *testing.T) {
+	start := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	tests := []struct {
+		name     string
+		prog     *Prog
+		contains []string
+	}{
+		{
+			name: "From the guide",
+			prog: &Prog{Ft: "20260112100000", To: "20260112113000", Title: "Morning", SubTitle: "#12", Pfm: "DJ <Taro>", Desc: "<p>News &amp; music</p>"},
+			contains: []string{
+				"<title>Morning #12</title>", "<showtitle>Show</showtitle>", "<season>2026</season>", "<aired>2026-01-12</aired>",
+				"<plot>News &amp; music</plot>", "<runtime>90</runtime>", "<studio>TBS</studio>", "<credits>DJ &lt;Taro&gt;</credits>",
+			},
+		},
+		{
+			name:     "Without the guide",
+			contains: []string{"<title>Show</title>", "<aired>2026-01-12</aired>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := formatEpisodeNFO("Show", tt.prog, "TBS", start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(data), "<?xml") || !strings.Contains(string(data), "<episodedetails>") {
+				t.Errorf("not an episodedetails document:\n%s", data)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("expected %s in\n%s", want, data)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteJobSeriesLayout(t *testing.T) {
+	ffmpeg, argsFile := fakeFFmpeg(t, false)
+	outputDir := t.TempDir()
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		Layout:     LayoutSeries,
+		Logger:     log.New(io.Discard, "", 0),
+		FFmpeg:     ffmpeg,
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+		DisableProgressBar: true,
+	}
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	entry := ScheduleEntry{ProgramName: "Show", StationID: "ST1"}
+
+	result, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts)
+	if err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+	want := filepath.Join(outputDir, "Show", "Season 2026", "Show - 2026-01-12.m4a")
+	if result.OutputPath != want {
+		t.Errorf("saved to %s, want %s", result.OutputPath, want)
+	}
+	if data, _ := os.ReadFile(want); string(data) != "normalized" {
+		t.Errorf("expected the remuxed recording, got %q", data)
+	}
+	if args, _ := os.ReadFile(argsFile); !strings.Contains(string(args), "-c:a copy -bsf:a aac_adtstoasc") {
+		t.Errorf("expected the audio to be copied, got ffmpeg arguments %s", args)
+	}
+	if data, err := os.ReadFile(strings.TrimSuffix(want, ".m4a") + nfoExt); err != nil || !strings.Contains(string(data), "<aired>2026-01-12</aired>") {
+		t.Errorf("expected the episode NFO, got %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(outputDir, "Show", tvShowNFO)); err != nil || !strings.Contains(string(data), "<title>Show</title>") {
+		t.Errorf("expected the show NFO, got %q, %v", data, err)
+	}
+
+	if result, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err != nil || !result.Skipped {
+		t.Errorf("expected the second run to skip the recording, got (%+v, %v)", result, err)
+	}
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DefaultFFmpeg is the ffmpeg executable used when no path is configured (Config.FFmpegPath).
+const DefaultFFmpeg = "ffmpeg"
+
+// loudnormFilter is the ffmpeg filter normalizing to the EBU R128 target of -23 LUFS,
+// with true peaks below -1 dBTP.
+const loudnormFilter = "loudnorm=I=-23:TP=-1:LRA=11"
+
+// Output format of normalized recordings. loudnorm upsamples internally, so the sample
+// rate of radiko's streams is restored; the bitrate leaves headroom for the re-encode.
+const (
+	normalizeSampleRate = "48000"
+	normalizeBitrate    = "128k"
+)
+
+// normalizeLoudness replaces the AAC file at path with a copy normalized by ffmpeg's
+// loudnorm filter. On failure path is left as it was.
+func normalizeLoudness(ctx context.Context, ffmpeg, path string) error {
+	if ffmpeg == "" {
+		ffmpeg = DefaultFFmpeg
+	}
+	tmp := path + ".loudnorm"
+	cmd := exec.CommandContext(ctx, ffmpeg,
+		"-hide_banner", "-loglevel", "error", "-nostdin", "-y",
+		"-i", path,
+		"-af", loudnormFilter, "-ar", normalizeSampleRate,
+		"-c:a", "aac", "-b:a", normalizeBitrate,
+		"-f", "adts", tmp)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmp)
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("ffmpeg failed: %w: %s", err, msg)
+		}
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace '%s' with the normalized recording: %w", path, err)
+	}
+	return nil
+}
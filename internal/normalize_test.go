@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeFFmpeg writes a stand-in for ffmpeg that records its arguments next to itself and
+// writes "normalized" to its output (the last argument), or fails if fail is set.
+func fakeFFmpeg(t *testing.T, fail bool) (path, argsFile string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a POSIX sh script")
+	}
+	dir := t.TempDir()
+	path = filepath.Join(dir, "ffmpeg")
+	argsFile = filepath.Join(dir, "args")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %q\nfor out; do :; done\nprintf normalized > \"$out\"\n", argsFile)
+	if fail {
+		script = "#!/bin/sh\necho 'Invalid data found when processing input' >&2\nexit 1\n"
+	}
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake ffmpeg: %v", err)
+	}
+	return path, argsFile
+}
+
+func TestNormalizeLoudness(t *testing.T) {
+	tests := []struct {
+		name     string
+		fail     bool
+		expected string
+	}{
+		{"success", false, "normalized"},
+		{"failure", true, "original"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ffmpeg, argsFile := fakeFFmpeg(t, tt.fail)
+			path := filepath.Join(t.TempDir(), "Show.aac")
+			if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := normalizeLoudness(context.Background(), ffmpeg, path)
+			if tt.fail {
+				if err == nil || !strings.Contains(err.Error(), "Invalid data") {
+					t.Errorf("expected the ffmpeg error, got %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("normalizeLoudness failed: %v", err)
+				}
+				if args, _ := os.ReadFile(argsFile); !strings.Contains(string(args), loudnormFilter) {
+					t.Errorf("expected the loudnorm filter, got %s", args)
+				}
+			}
+			if data, _ := os.ReadFile(path); string(data) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, data)
+			}
+			if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+				t.Errorf("expected no temporary files, got %v", entries)
+			}
+		})
+	}
+}
+
+func TestExecuteJobNormalize(t *testing.T) {
+	ffmpeg, _ := fakeFFmpeg(t, false)
+	outputDir := t.TempDir()
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		Logger:     log.New(io.Discard, "", 0),
+		FFmpeg:     ffmpeg,
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+		DisableProgressBar: true,
+	}
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+
+	for _, entry := range []ScheduleEntry{
+		{ProgramName: "Loud", StationID: "ST1", Normalize: true},
+		{ProgramName: "Plain", StationID: "ST1"},
+	} {
+		if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err != nil {
+			t.Fatalf("ExecuteJob failed: %v", err)
+		}
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(outputDir, "20260112100000-ST1-Loud.aac")); string(data) != "normalized" {
+		t.Errorf("expected a normalized recording, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(outputDir, "20260112100000-ST1-Plain.aac")); !strings.Contains(string(data), dummyAACChunk) {
+		t.Errorf("expected the recording as downloaded, got %q", data)
+	}
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Notifier delivers a message to the user.
+type Notifier interface {
+	Notify(ctx context.Context, subject, body string) error
+}
+
+// NotificationConfig configures where notifications are sent.
+type NotificationConfig struct {
+	WebhookURL    string              `json:"webhook_url"` // Slack/Discord compatible incoming webhook
+	Email         EmailConfig         `json:"email"`
+	WeeklyPreview WeeklyPreviewConfig `json:"weekly_preview"`
+	RunReport     bool                `json:"run_report"` // Send a summary after each run that recorded or failed something.
+}
+
+// NewNotifier creates the notifier described by cfg, or nil when none is configured.
+// With both a webhook and email configured, notifications go to both.
+func NewNotifier(cfg NotificationConfig) Notifier {
+	var notifiers multiNotifier
+	if cfg.WebhookURL != "" {
+		notifiers = append(notifiers, &WebhookNotifier{URL: cfg.WebhookURL, Client: http.DefaultClient})
+	}
+	if cfg.Email.Host != "" {
+		notifiers = append(notifiers, &EmailNotifier{Config: cfg.Email})
+	}
+	switch len(notifiers) {
+	case 0:
+		return nil
+	case 1:
+		return notifiers[0]
+	}
+	return notifiers
+}
+
+// multiNotifier delivers each notification to all of its notifiers.
+type multiNotifier []Notifier
+
+func (m multiNotifier) Notify(ctx context.Context, subject, body string) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, subject, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WebhookNotifier POSTs notifications as JSON. The payload carries the message in both
+// "text" (Slack) and "content" (Discord) so either kind of incoming webhook accepts it.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, subject, body string) error {
+	message := subject + "\n\n" + body
+	payload, err := json.Marshal(map[string]string{
+		"subject": subject,
+		"text":    message,
+		"content": message,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", redactURLError(err)) // The webhook URL is a secret.
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to send notification: HTTP status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
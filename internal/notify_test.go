@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid JSON payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := &WebhookNotifier{URL: server.URL, Client: server.Client()}
+	if err := notifier.Notify(context.Background(), "Subject", "Body"); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	if payload["subject"] != "Subject" || payload["text"] != "Subject\n\nBody" || payload["content"] != payload["text"] {
+		t.Errorf("unexpected payload: %v", payload)
+	}
+}
+
+func TestWebhookNotifierHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	notifier := &WebhookNotifier{URL: server.URL, Client: server.Client()}
+	err := notifier.Notify(context.Background(), "Subject", "Body")
+	if err == nil || !strings.Contains(err.Error(), "HTTP status 403") {
+		t.Errorf("expected HTTP 403 error, got %v", err)
+	}
+}
+
+func TestWebhookNotifierRedactsURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close() // Connection refused.
+
+	notifier := &WebhookNotifier{URL: server.URL + "/services/T000/B000/SECRET", Client: http.DefaultClient}
+	err := notifier.Notify(context.Background(), "Subject", "Body")
+	if err == nil || strings.Contains(err.Error(), "SECRET") {
+		t.Errorf("expected an error without the webhook token, got %v", err)
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	if n := NewNotifier(NotificationConfig{}); n != nil {
+		t.Errorf("expected no notifier without webhook_url, got %v", n)
+	}
+	if n, ok := NewNotifier(NotificationConfig{WebhookURL: "http://example.com"}).(*WebhookNotifier); !ok || n == nil {
+		t.Error("expected a webhook notifier")
+	}
+	email := EmailConfig{Host: "smtp.example.com", From: "rec@example.com", To: []string{"me@example.com"}}
+	if _, ok := NewNotifier(NotificationConfig{Email: email}).(*EmailNotifier); !ok {
+		t.Error("expected an email notifier")
+	}
+	if n, ok := NewNotifier(NotificationConfig{WebhookURL: "http://example.com", Email: email}).(multiNotifier); !ok || len(n) != 2 {
+		t.Errorf("expected notifications to go to both, got %#v", n)
+	}
+}
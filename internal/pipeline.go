@@ -0,0 +1,745 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock abstracts the current time and waiting so the pipeline can be driven by a fake clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the Clock backed by the real wall clock.
+var SystemClock Clock = systemClock{}
+
+// Options configures RunOnce and RunDaemon. Only Schedule is required.
+type Options struct {
+	Schedule    []ScheduleEntry
+	OutputDir   string                                      // Defaults to "output".
+	Logger      *log.Logger                                 // Defaults to the standard logger.
+	Clock       Clock                                       // Defaults to SystemClock.
+	Location    *time.Location                              // Time zone the schedule is read in. Defaults to JST.
+	NewProvider func(ctx context.Context) (Provider, error) // Overrides the registered radiko provider.
+	PostStore   PostStore                                   // Optional storage for finished recordings.
+	DeleteLocal bool                                        // Remove local files after a PostStore upload.
+	Interval    time.Duration                               // RunDaemon polling interval. Defaults to one hour.
+	PostCommand string                                      // Global post_command run after each recording.
+	History     *History                                    // Optional recording history.
+	Quiet       bool                                        // One summary line per job instead of detailed logs.
+
+	DisableProgressBar bool   // Log progress periodically instead of drawing a progress bar.
+	Output             Output // Language of messages and errors, and characters of progress bars.
+
+	// Providers override registered providers (see RegisterProvider) by name.
+	Providers map[string]ProviderFactory
+
+	Notifier      Notifier                                                    // Optional; receives the weekly preview and run reports.
+	RunReport     bool                                                        // Send a report to Notifier after each run that recorded or failed something.
+	WeeklyPreview WeeklyPreviewConfig                                         // When RunDaemon sends the weekly preview.
+	FetchGuide    func(ctx context.Context, stationID string) ([]byte, error) // Defaults to GetProgramGuide, through Caches.Guide when set.
+	Concurrency   int                                                         // Jobs recorded in parallel. Defaults to 1.
+	ChunkRate     float64                                                     // Chunk requests per second across all jobs; 0 is unlimited.
+	JobChunkRate  float64                                                     // Chunk requests per second of each job, see JobOptions.ChunkRate.
+
+	// StationConcurrency limits the jobs of one station recorded at the same time, so that the
+	// Concurrency slots go to broadcasts of different stations first; 0 is no limit.
+	StationConcurrency int
+	Caches             *Caches     // Optional guide and chunk caches.
+	Tokens             *TokenCache // Auth tokens shared by jobs. Defaults to an in-memory cache.
+
+	// PanicWindow exempts jobs whose broadcast leaves the timeshift window within this time from
+	// ChunkRate, JobChunkRate and StationConcurrency; 0 exempts none.
+	PanicWindow time.Duration
+
+	Subscriptions []Subscription                              // Keyword subscriptions recorded on every pass.
+	ListStations  func(ctx context.Context) ([]string, error) // Stations scanned by subscriptions without stations. Defaults to GetAllStationIDs.
+	Audit         AuditConfig                                 // Periodic library audit in RunDaemon; requires History.
+
+	// Queue, when set, persists the jobs of each pass: pending and failed jobs are recorded again
+	// on later passes, including after a restart, while the broadcast is in the timeshift window.
+	Queue *JobQueue
+	// Retry paces the retries of failed jobs in Queue; RunDaemon wakes for them between passes.
+	Retry RetryConfig
+
+	Layout         string // File naming layout, see JobOptions.Layout.
+	TitleCollision string // Disambiguation of file name collisions, see JobOptions.TitleCollision.
+	ScheduleTitles bool   // Names after program_name instead of the guide's title, see JobOptions.ScheduleTitles.
+	Overwrite      bool   // Record broadcasts recorded before again, see JobOptions.Overwrite.
+
+	// Quality and Headers configure the stream requests of the built-in providers, see StreamConfig.
+	Quality string                 // Variant recorded from master playlists offering several.
+	Headers map[string]http.Header // Extra headers by provider name.
+
+	RequestTimeout time.Duration       // Limit of each provider call and chunk download, see JobOptions.RequestTimeout.
+	JobTimeout     time.Duration       // Limit of each job; 0 is unlimited.
+	Rotation       RotationConfig      // Splitting of long recordings into parts, see JobOptions.Rotation.
+	DurationCheck  DurationCheckConfig // Length check of recordings, see JobOptions.DurationCheck.
+	Chapters       bool                // Chapter files from the program guide, see JobOptions.Chapters.
+	ShowNotes      bool                // Show notes from the program guide, see JobOptions.ShowNotes.
+	FFmpeg         string              // ffmpeg executable for loudness normalization, see JobOptions.FFmpeg.
+	TempDir        string              // Where chunks are downloaded, see JobOptions.TempDir.
+	ChunkBufferMB  int                 // Keep chunks in memory instead, see JobOptions.ChunkBufferMB.
+	Transcriber    *Transcriber        // Optional; transcribes recordings, see JobOptions.Transcriber.
+
+	// KeepChunks keeps the temporary directory of each job, see JobOptions.KeepChunks.
+	KeepChunks bool
+	// ChunkNamePattern names the chunk files, see JobOptions.ChunkNamePattern.
+	ChunkNamePattern string
+
+	// TolerateMissingChunks is the percentage of chunks that may be left out of a recording,
+	// see JobOptions.TolerateMissingChunks.
+	TolerateMissingChunks float64
+
+	// ScheduleUpdates delivers reloaded schedules to RunDaemon (see WatchSchedule).
+	ScheduleUpdates <-chan []ScheduleEntry
+
+	// Summary, when set, receives the result of every entry handled by RunOnce.
+	Summary *RunSummary
+
+	// State, when set, is kept up to date by RunDaemon (e.g. to serve it with ServeDaemonAPI).
+	State *DaemonState
+
+	jobs    *jobTracker  // Set by RunDaemon so reloads can cancel jobs of removed entries.
+	limiter *RateLimiter // Built from ChunkRate once, so RunDaemon's passes and audits share it.
+}
+
+func (o Options) withDefaults() Options {
+	if o.OutputDir == "" {
+		o.OutputDir = DefaultConfig().OutputDir
+	}
+	if o.Logger == nil {
+		o.Logger = log.Default()
+	}
+	if o.Clock == nil {
+		o.Clock = SystemClock
+	}
+	if o.Interval <= 0 {
+		o.Interval = time.Hour
+	}
+	if o.FetchGuide == nil {
+		o.FetchGuide = GetProgramGuide
+		if o.Caches != nil {
+			o.FetchGuide = CachedGuideFetcher(o.Caches.Guide, o.Clock, GetProgramGuide)
+		}
+	}
+	if o.Concurrency < 1 {
+		o.Concurrency = 1
+	}
+	if o.ListStations == nil {
+		o.ListStations = GetAllStationIDs
+	}
+	if o.Tokens == nil {
+		o.Tokens = NewTokenCache("", o.Clock)
+	}
+	if o.limiter == nil {
+		o.limiter = NewRateLimiter(o.ChunkRate)
+	}
+	return o
+}
+
+// providerFactory returns the factory of the named provider ("" is radiko): an override
+// from Options, or else the registered one.
+func (o Options) providerFactory(name string) (ProviderFactory, bool) {
+	if name == "" {
+		name = ProviderRadiko
+	}
+	if name == ProviderRadiko && o.NewProvider != nil {
+		return o.NewProvider, true
+	}
+	if factory, ok := o.Providers[name]; ok {
+		return factory, true
+	}
+	return LookupProvider(name)
+}
+
+// newClient creates the provider for a job of entry, with the stream settings of o.
+func (o Options) newClient(ctx context.Context, entry ScheduleEntry) (Provider, error) {
+	name := cmp.Or(entry.Provider, ProviderRadiko)
+	factory, ok := o.providerFactory(name)
+	if !ok {
+		return nil, fmt.Errorf(o.Output.Msg("unknown provider '%s'"), entry.Provider)
+	}
+	if err := checkQuality(o.Quality); err != nil {
+		return nil, err
+	}
+	client, err := factory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := client.(streamConfigurer); ok {
+		c.configureStream(StreamConfig{Quality: o.Quality, Headers: o.Headers[name]})
+	}
+	return client, nil
+}
+
+func (o Options) jobOptions() JobOptions {
+	jobOpts := JobOptions{
+		OutputDir:   o.OutputDir,
+		PostStore:   o.PostStore,
+		DeleteLocal: o.DeleteLocal,
+		Logger:      o.Logger,
+		PostCommand: o.PostCommand,
+		History:     o.History,
+		Quiet:       o.Quiet,
+
+		DisableProgressBar: o.DisableProgressBar,
+		Output:             o.Output,
+		FetchGuide:         o.FetchGuide,
+		RateLimit:          o.limiter,
+		ChunkRate:          o.JobChunkRate,
+		PanicWindow:        o.PanicWindow,
+		Location:           o.Location,
+		Tokens:             o.Tokens,
+		Layout:             o.Layout,
+		TitleCollision:     o.TitleCollision,
+		ScheduleTitles:     o.ScheduleTitles,
+		Overwrite:          o.Overwrite,
+		RequestTimeout:     o.RequestTimeout,
+		JobTimeout:         o.JobTimeout,
+		Rotation:           o.Rotation,
+		DurationCheck:      o.DurationCheck,
+		Chapters:           o.Chapters,
+		ShowNotes:          o.ShowNotes,
+		FFmpeg:             o.FFmpeg,
+		TempDir:            o.TempDir,
+		ChunkBufferMB:      o.ChunkBufferMB,
+		Transcriber:        o.Transcriber,
+
+		TolerateMissingChunks: o.TolerateMissingChunks,
+		KeepChunks:            o.KeepChunks,
+		ChunkNamePattern:      o.ChunkNamePattern,
+	}
+	if o.Caches != nil {
+		jobOpts.ChunkCache = o.Caches.Chunk
+	}
+	return jobOpts
+}
+
+// jobSlots tracks the jobs of a run that are running, to keep them within Concurrency and
+// StationConcurrency. It is only used by the goroutine dispatching the jobs.
+type jobSlots struct {
+	limit, perStation int // perStation does not limit when it is not positive.
+	running           int
+	stations          map[string]int
+}
+
+func newJobSlots(limit, perStation int) *jobSlots {
+	return &jobSlots{limit: limit, perStation: perStation, stations: make(map[string]int)}
+}
+
+// free reports whether a job of stationID may start now. A panicking job (see Options.PanicWindow)
+// only needs one of the Concurrency slots.
+func (s *jobSlots) free(stationID string, panicking bool) bool {
+	return s.running < s.limit && (s.perStation <= 0 || panicking || s.stations[stationID] < s.perStation)
+}
+
+func (s *jobSlots) take(stationID string) {
+	s.running++
+	s.stations[stationID]++
+}
+
+func (s *jobSlots) release(stationID string) {
+	s.running--
+	s.stations[stationID]--
+}
+
+// RunOnce records the most recent past broadcast of every schedule entry,
+// followed by the past broadcasts matched by Subscriptions and the jobs left in Queue.
+// Up to Concurrency jobs run at the same time, at most StationConcurrency of them for one station;
+// the progress bar is disabled when more than one does.
+// Failures of individual entries do not stop the run; they are joined into the returned error.
+func RunOnce(ctx context.Context, opts Options) error {
+	opts = opts.withDefaults()
+	now := opts.Clock.Now().In(JST)
+
+	jobOpts := opts.jobOptions()
+	if opts.Concurrency > 1 {
+		jobOpts.DisableProgressBar = true
+	}
+
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	addErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+	// Results of this run, for Options.Summary and the run report.
+	run := &RunSummary{}
+	report := func(r JobResult) {
+		run.add(r)
+		opts.Summary.add(r)
+	}
+	// fail records the failure of entry's broadcast at start (zero if unknown).
+	fail := func(entry ScheduleEntry, start time.Time, err error) {
+		addErr(fmt.Errorf("%s: %w", entry.ProgramName, err))
+		report(JobResult{ProgramName: entry.ProgramName, StationID: entry.StationID, Start: start, Outcome: OutcomeFailed, Err: err})
+	}
+	slots := newJobSlots(opts.Concurrency, opts.StationConcurrency)
+	queueErr := func(err error) {
+		if err != nil {
+			opts.Logger.Printf("WARNING: %v", err)
+		}
+	}
+	// queueFail marks the queued job of entry's broadcast at start as failed, to be retried later.
+	queueFail := func(entry ScheduleEntry, start time.Time, err error) {
+		job, qerr := opts.Queue.fail(entry.StationID, start, err, opts.Clock.Now(), opts.Retry)
+		queueErr(qerr)
+		switch job.Status {
+		case QueueFailed:
+			opts.Logger.Printf("INFO: Retrying '%s' (%s) at %s.", entry.ProgramName, start.In(JST).Format("2006-01-02 15:04"), job.RetryAt.In(JST).Format("2006-01-02 15:04"))
+		case QueueGaveUp:
+			opts.Logger.Printf("WARNING: Giving up on '%s' (%s) after %d failed attempts.", entry.ProgramName, start.In(JST).Format("2006-01-02 15:04"), job.Attempts)
+		}
+	}
+
+	// Each provider is authenticated once, before its first job, and its client is shared by its jobs.
+	session := newSession(opts, jobOpts)
+	jobOpts.Authenticated = true
+
+	// finished receives the station of every job that ends, to free its slot.
+	var finished chan string
+
+	// dispatch starts a job of entry in a slot taken for it, reporting whether it started.
+	// Jobs not backed by a schedule entry (subscription matches) are not cancelled when the
+	// schedule is reloaded. Entries of a provider that failed to authenticate fail without
+	// starting a job. Jobs stay in Queue until they succeed; jobs interrupted by shutdown are
+	// left pending. Failed jobs are not dispatched again before their retry is due, nor after
+	// giving up.
+	dispatch := func(entry ScheduleEntry, pastTime time.Time, scheduled bool) bool {
+		if job, ok := opts.Queue.job(entry.StationID, pastTime); ok && job.held(now) {
+			return false
+		}
+		queueErr(opts.Queue.add(entry, pastTime, scheduled))
+		client, err := session.client(ctx, entry)
+		if err != nil {
+			queueFail(entry, pastTime, err)
+			fail(entry, pastTime, err)
+			return false
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { finished <- entry.StationID }()
+
+			ctx, progress, done := opts.jobs.start(ctx, entry, pastTime, !scheduled)
+			defer done()
+
+			jobOpts := jobOpts
+			jobOpts.OnProgress = progress
+			startedAt := opts.Clock.Now()
+			rec, err := watchJob(ctx, jobOpts.watchdogLimit(entry), func(ctx context.Context) (RecordingResult, error) {
+				return executeJobWithRerun(ctx, client, entry, pastTime, now, jobOpts)
+			})
+			if errors.Is(err, errJobTimeout) || errors.Is(err, errJobStuck) {
+				opts.reportJobTimeout(ctx, entry, pastTime, startedAt, err)
+			}
+			if err != nil {
+				if !opts.Quiet { // Quiet mode already reported the failure in the job summary line.
+					opts.Logger.Printf(opts.Output.Msg("Error executing job for '%s': %v"), entry.ProgramName, err)
+				}
+				if ctx.Err() == nil {
+					queueFail(entry, pastTime, err)
+				}
+				fail(entry, pastTime, err)
+				return
+			}
+			queueErr(opts.Queue.done(entry.StationID, pastTime))
+			result := JobResult{ProgramName: entry.ProgramName, StationID: entry.StationID, Start: pastTime, Outcome: OutcomeSucceeded, OutputPath: rec.OutputPath, Size: rec.Size}
+			if rec.Skipped {
+				result.Outcome = OutcomeSkipped
+			}
+			report(result)
+		}()
+		return true
+	}
+
+	// Jobs are collected first and dispatched by priority, so that when many broadcasts are due
+	// at once, e.g. after the daemon was down, the important ones take the first slots.
+	type pendingJob struct {
+		entry     ScheduleEntry
+		start     time.Time
+		scheduled bool
+	}
+	var pending []pendingJob
+	scheduled := make(map[string]bool)
+	stopped := false
+	for _, entry := range opts.Schedule {
+		if err := ctx.Err(); err != nil {
+			addErr(err)
+			stopped = true
+			break
+		}
+		if !entry.IsEnabled() {
+			continue
+		}
+
+		if _, ok := opts.providerFactory(entry.Provider); !ok {
+			opts.Logger.Printf("Unknown provider '%s' for '%s'", entry.Provider, entry.ProgramName)
+			fail(entry, time.Time{}, fmt.Errorf(opts.Output.Msg("unknown provider '%s'"), entry.Provider))
+			continue
+		}
+
+		recentPastTime, err := CalculateRecentPastRunTime(entry, now, opts.Location)
+		if errors.Is(err, ErrNotAired) {
+			continue // A one-shot entry, or the first broadcast of one every few weeks, on a later day.
+		}
+		if err != nil {
+			opts.Logger.Printf("Error calculating recent past run time for '%s': %v", entry.ProgramName, err)
+			fail(entry, time.Time{}, err)
+			continue
+		}
+		if entry.OneShot() && opts.oneShotDone(entry, recentPastTime, now) {
+			continue
+		}
+		if reason, skip := skipReason(entry, recentPastTime, opts.Location); skip {
+			opts.Logger.Printf("INFO: Skipping the broadcast of '%s' at %s: %s.", entry.ProgramName, recentPastTime.Format("2006-01-02 15:04"), reason)
+			continue
+		}
+
+		key := broadcastKey(entry.StationID, recentPastTime)
+		if scheduled[key] {
+			// Entries that overlap only on some weeks, e.g. a weekly one and one on the 1st week of the month.
+			opts.Logger.Printf("INFO: Skipping the broadcast of '%s' at %s: another entry records it.", entry.ProgramName, recentPastTime.Format("2006-01-02 15:04"))
+			continue
+		}
+		scheduled[key] = true
+		pending = append(pending, pendingJob{entry, recentPastTime, true})
+	}
+
+	if !stopped {
+		for _, job := range opts.subscriptionJobs(ctx, now, scheduled) {
+			scheduled[broadcastKey(job.entry.StationID, job.start)] = true
+			pending = append(pending, pendingJob{job.entry, job.start, false})
+		}
+	}
+
+	if !stopped {
+		queued, err := opts.Queue.prune(now, opts.Schedule)
+		queueErr(err)
+		for _, job := range queued {
+			if scheduled[broadcastKey(job.Entry.StationID, job.Start)] || job.held(now) {
+				continue
+			}
+			opts.Logger.Printf("INFO: Retrying queued job for '%s' (%s, %s).", job.Entry.ProgramName, job.Start.In(JST).Format("2006-01-02 15:04"), job.Status)
+			pending = append(pending, pendingJob{job.Entry, job.Start, job.Scheduled})
+		}
+
+		// Whenever a slot is free, the job of the highest priority that may take it starts: a
+		// job waiting for its station does not hold up jobs of other stations behind it.
+		slices.SortStableFunc(pending, func(a, b pendingJob) int { return cmp.Compare(b.entry.Priority, a.entry.Priority) })
+		finished = make(chan string, len(pending))
+	dispatching:
+		for len(pending) > 0 {
+			if err := ctx.Err(); err != nil {
+				addErr(err)
+				break
+			}
+			i := slices.IndexFunc(pending, func(job pendingJob) bool {
+				return slots.free(job.entry.StationID, panicking(opts.PanicWindow, job.start, now))
+			})
+			if i < 0 {
+				select {
+				case station := <-finished:
+					slots.release(station)
+				case <-ctx.Done():
+					addErr(ctx.Err())
+					break dispatching
+				}
+				continue
+			}
+			job := pending[i]
+			pending = slices.Delete(pending, i, i+1)
+			if dispatch(job.entry, job.start, job.scheduled) {
+				slots.take(job.entry.StationID)
+			}
+		}
+	}
+	wg.Wait()
+
+	if opts.RunReport && opts.Notifier != nil {
+		if subject, body, ok := FormatRunReport(run.Results()); ok {
+			if err := opts.Notifier.Notify(ctx, subject, body); err != nil {
+				opts.Logger.Printf("WARNING: Failed to send run report: %v", err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// oneShotDone reports whether the broadcast at start of a one-shot entry needs no further
+// attempts: a successful recording of it in the history, from its station or a fallback,
+// marks the entry done, and a broadcast that has left the timeshift window can no longer be
+// recorded.
+func (o Options) oneShotDone(entry ScheduleEntry, start, now time.Time) bool {
+	if o.History != nil {
+		for _, station := range append([]string{entry.StationID}, entry.FallbackStations...) {
+			if _, ok, err := o.History.FindRecording(station, start, false); err != nil {
+				o.Logger.Printf("WARNING: Failed to read the history: %v", err)
+				break
+			} else if ok {
+				o.Logger.Printf("INFO: The one-shot entry '%s' on %s is done; it can be removed from the schedule.", entry.ProgramName, entry.Date)
+				return true
+			}
+		}
+	}
+	if _, err := checkTimeshift(start, now); err != nil {
+		o.Logger.Printf("WARNING: The one-shot entry '%s' was not recorded: %v", entry.ProgramName, err)
+		return true
+	}
+	return false
+}
+
+// subscriptionJobs returns the broadcasts to record for Subscriptions, leaving out duplicates
+// and broadcasts already recorded by a schedule entry (keyed by broadcastKey in scheduled).
+// Guides that cannot be read are logged and skipped.
+func (o Options) subscriptionJobs(ctx context.Context, now time.Time, scheduled map[string]bool) []subscriptionJob {
+	var (
+		jobs        []subscriptionJob
+		allStations []string
+	)
+	seen := make(map[string]bool, len(scheduled))
+	for key := range scheduled {
+		seen[key] = true
+	}
+	for _, sub := range o.Subscriptions {
+		stations := sub.Stations
+		if len(stations) == 0 {
+			if allStations == nil {
+				var err error
+				if allStations, err = o.ListStations(ctx); err != nil {
+					o.Logger.Printf("WARNING: Skipping subscription '%s': %v", sub, err)
+					continue
+				}
+			}
+			stations = allStations
+		}
+
+		found, errs := findSubscriptionJobs(ctx, sub, now, stations, o.FetchGuide)
+		for _, err := range errs {
+			o.Logger.Printf("WARNING: Subscription '%s': %v", sub, err)
+		}
+		for _, job := range found {
+			key := broadcastKey(job.entry.StationID, job.start)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			jobs = append(jobs, job)
+		}
+	}
+	if len(jobs) > 0 {
+		o.Logger.Printf("INFO: Subscriptions matched %d broadcast(s).", len(jobs))
+	}
+	return jobs
+}
+
+// broadcastKey identifies the broadcast starting at start on stationID.
+func broadcastKey(stationID string, start time.Time) string {
+	return stationID + "|" + start.In(JST).Format("20060102150405")
+}
+
+// RunDaemon calls RunOnce every Interval until ctx is cancelled.
+// Already recorded broadcasts are skipped by ExecuteJob, so each pass only picks up new ones.
+// When a Notifier and WeeklyPreview are configured, the weekly preview is sent on the first pass after its slot.
+// With a Queue, when it was last sent is kept next to the queue file (see JobQueue.PreviewStatePath),
+// so a daemon restarted later in the week does not send it again.
+// A schedule received on ScheduleUpdates replaces the current one: running jobs of removed entries
+// are cancelled and a new pass starts right away (after the running one, if any), as it does when
+// a client calls State.RequestPass.
+// When Audit is configured, the library audit runs alongside the passes whenever it is due (see AuditLibrary).
+// With a Queue, jobs left pending by an earlier run are recorded on the first pass, and failed
+// ones once their retry is due; a pass starts early for them (see Options.Retry).
+// Job errors are logged and do not stop the daemon; it returns ctx.Err() on shutdown.
+func RunDaemon(ctx context.Context, opts Options) error {
+	opts = opts.withDefaults()
+	state := opts.State
+	if state == nil {
+		state = NewDaemonState()
+	}
+	opts.jobs = state.jobs
+	state.setSchedule(opts.Schedule)
+
+	previewPath := opts.Queue.PreviewStatePath()
+	lastPreview, err := loadPreviewSent(previewPath)
+	if err != nil {
+		opts.Logger.Printf("WARNING: %v", err)
+	}
+	var (
+		auditDone chan struct{}    // Non-nil while a library audit is running.
+		passDone  chan error       // Non-nil while a pass is running.
+		wait      <-chan time.Time // Non-nil while waiting for the next pass.
+		pending   bool             // The schedule changed during the running pass.
+	)
+	startPass := func() {
+		if opts.Notifier != nil {
+			now := opts.Clock.Now().In(JST)
+			due, err := weeklyPreviewDue(opts.WeeklyPreview, now, lastPreview, opts.Location)
+			if err != nil {
+				opts.Logger.Printf("WARNING: %v", err)
+			} else if due {
+				if err := SendWeeklyPreview(ctx, opts); err != nil {
+					opts.Logger.Printf("WARNING: Failed to send weekly preview: %v", err)
+				} else {
+					opts.Logger.Println("INFO: Sent weekly schedule preview.")
+				}
+				lastPreview = now
+				if err := savePreviewSent(previewPath, now); err != nil {
+					opts.Logger.Printf("WARNING: %v", err)
+				}
+			}
+		}
+
+		if auditDone == nil {
+			if due, err := auditDue(opts.Audit, opts.History, opts.Clock.Now()); err != nil {
+				opts.Logger.Printf("WARNING: %v", err)
+			} else if due {
+				auditDone = make(chan struct{})
+				go func(done chan struct{}) {
+					defer close(done)
+					runScheduledAudit(ctx, opts)
+				}(auditDone)
+			}
+		}
+
+		state.setPass(true, time.Time{})
+		passDone = make(chan error, 1)
+		passOpts := opts
+		go func() { passDone <- RunOnce(ctx, passOpts) }()
+	}
+
+	if n := len(opts.Queue.Jobs()); n > 0 {
+		opts.Logger.Printf("INFO: Resuming %d queued job(s) from the previous run.", n)
+	}
+	startPass()
+	for {
+		select {
+		case <-ctx.Done():
+			if passDone != nil {
+				<-passDone
+			}
+			if auditDone != nil {
+				<-auditDone
+			}
+			return ctx.Err()
+
+		case <-auditDone:
+			auditDone = nil
+
+		case err := <-passDone:
+			passDone = nil
+			if err != nil && ctx.Err() == nil {
+				opts.Logger.Printf("WARNING: Some jobs failed in this pass: %v", err)
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if pending {
+				pending = false
+				startPass()
+				continue
+			}
+			now := opts.Clock.Now()
+			next := now.Add(opts.Interval)
+			if retry := opts.Queue.nextRetry(); !retry.IsZero() && retry.Before(next) {
+				next = retry
+				if next.Before(now) {
+					next = now
+				}
+			}
+			state.setPass(false, next)
+			opts.Logger.Printf("INFO: Next pass at %s", next.Format("2006-01-02 15:04:05"))
+			wait = opts.Clock.After(next.Sub(now))
+
+		case entries, ok := <-opts.ScheduleUpdates:
+			if !ok {
+				opts.ScheduleUpdates = nil // The watcher stopped; keep the current schedule.
+				continue
+			}
+			opts.Schedule = entries
+			state.setSchedule(entries)
+			if n := opts.jobs.cancelRemoved(entries); n > 0 {
+				opts.Logger.Printf("INFO: Cancelled %d running job(s) of removed schedule entries.", n)
+			}
+			opts.Logger.Printf("INFO: Schedule reloaded: %d entries.", len(entries))
+			if passDone != nil {
+				pending = true
+			} else {
+				wait = nil
+				startPass()
+			}
+
+		case <-state.passes:
+			opts.Logger.Println("INFO: A pass was requested through the daemon API.")
+			if passDone != nil {
+				pending = true
+			} else {
+				wait = nil
+				startPass()
+			}
+
+		case <-wait:
+			wait = nil
+			startPass()
+		}
+	}
+}
+
+// runScheduledAudit runs (or resumes) the library audit, reports any issues to the Notifier
+// and, with AutoRepair, re-records the damaged recordings that can still be recorded.
+func runScheduledAudit(ctx context.Context, opts Options) {
+	opts.Logger.Println("INFO: Starting the library audit.")
+	state, err := AuditLibrary(ctx, AuditOptions{History: opts.History, Logger: opts.Logger, Clock: opts.Clock})
+	if err != nil {
+		if ctx.Err() == nil {
+			opts.Logger.Printf("WARNING: Library audit failed: %v", err)
+		}
+		return
+	}
+	if len(state.Issues) == 0 {
+		return
+	}
+	if opts.Audit.AutoRepair {
+		if _, err := RepairRecordings(ctx, opts); err != nil && ctx.Err() == nil {
+			opts.Logger.Printf("WARNING: Some repairs failed: %v", err)
+		}
+		if repaired, err := LoadAuditState(opts.History.AuditStatePath()); err == nil && repaired != nil {
+			state = repaired
+		}
+	}
+	if opts.Notifier != nil {
+		subject, body := FormatAuditReport(state)
+		if err := opts.Notifier.Notify(ctx, subject, body); err != nil {
+			opts.Logger.Printf("WARNING: Failed to send the audit report: %v", err)
+		}
+	}
+}
+
+// SendWeeklyPreview resolves the coming week's recordings against the guide and sends them to opts.Notifier.
+func SendWeeklyPreview(ctx context.Context, opts Options) error {
+	opts = opts.withDefaults()
+	if opts.Notifier == nil {
+		return errors.New(opts.Output.Msg("no notifier configured"))
+	}
+	now := opts.Clock.Now().In(JST)
+	subject, body := FormatWeeklyPreview(BuildWeeklyPreview(ctx, opts.Schedule, now, opts.Location, opts.FetchGuide), now)
+	return opts.Notifier.Notify(ctx, subject, body)
+}
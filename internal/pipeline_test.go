@@ -0,0 +1,680 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose Now is fixed and whose After fires immediately.
+type fakeClock struct {
+	now    time.Time
+	afters int
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.afters++
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestRunOnce(t *testing.T) {
+	outputDir := t.TempDir()
+	var logBuf bytes.Buffer
+	clock := &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)} // Tuesday
+
+	providers := 0
+	summary := &RunSummary{}
+	paused := false
+	opts := Options{
+		Schedule: []ScheduleEntry{
+			{ProgramName: "Good Program", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"},
+			{ProgramName: "Bad Program", DayOfWeek: "X", StartTime: "100000", StationID: "ST1"},
+			{ProgramName: "Paused Program", DayOfWeek: "月", StartTime: "110000", StationID: "ST1", Enabled: &paused},
+		},
+		OutputDir: outputDir,
+		Logger:    log.New(&logBuf, "", 0),
+		Clock:     clock,
+		NewProvider: func(ctx context.Context) (Provider, error) {
+			providers++
+			return &MockRadikoClient{}, nil
+		},
+		Summary: summary,
+	}
+
+	err := RunOnce(context.Background(), opts)
+	if err == nil || !strings.Contains(err.Error(), "Bad Program") {
+		t.Fatalf("expected error mentioning the bad entry, got %v", err)
+	}
+	if providers != 1 {
+		t.Errorf("expected 1 provider to be created, got %d", providers)
+	}
+
+	expected := filepath.Join(outputDir, "20260112100000-ST1-Good Program.aac")
+	if _, err := os.Stat(expected); err != nil {
+		t.Errorf("expected output file %s: %v", expected, err)
+	}
+	if !strings.Contains(logBuf.String(), "Starting recording for: Good Program") {
+		t.Errorf("expected injected logger to receive job logs, got:\n%s", logBuf.String())
+	}
+	if strings.Contains(logBuf.String(), "Paused Program") {
+		t.Errorf("expected the disabled entry to be ignored, got:\n%s", logBuf.String())
+	}
+	if succeeded, failed, skipped := summary.Counts(); succeeded != 1 || failed != 1 || skipped != 0 {
+		t.Errorf("expected 1 succeeded and 1 failed job, got %d/%d/%d", succeeded, failed, skipped)
+	}
+	for _, r := range summary.Results() {
+		switch r.ProgramName {
+		case "Good Program":
+			if r.Outcome != OutcomeSucceeded || r.OutputPath != expected || !r.Start.Equal(time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)) {
+				t.Errorf("unexpected result: %+v", r)
+			}
+		case "Bad Program":
+			if r.Outcome != OutcomeFailed || r.Err == nil || !r.Start.IsZero() {
+				t.Errorf("unexpected result: %+v", r)
+			}
+		}
+	}
+
+	opts.Summary = &RunSummary{}
+	if err := RunOnce(context.Background(), opts); err == nil {
+		t.Fatal("expected the bad entry to fail again")
+	}
+	if _, _, skipped := opts.Summary.Counts(); skipped != 1 {
+		t.Errorf("expected the recorded program to be skipped, got %+v", opts.Summary.Results())
+	}
+}
+
+func TestRunOnceOneShot(t *testing.T) {
+	var logBuf bytes.Buffer
+	opts := Options{
+		Schedule: []ScheduleEntry{
+			{ProgramName: "Special", Date: "2026-01-12", StartTime: "100000", StationID: "ST1"},
+			{ProgramName: "Coming Special", Date: "2026-01-20", StartTime: "100000", StationID: "ST1"},
+			{ProgramName: "Missed Special", Date: "2025-12-01", StartTime: "100000", StationID: "ST1"},
+		},
+		OutputDir:   t.TempDir(),
+		Logger:      log.New(&logBuf, "", 0),
+		Clock:       &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)}, // Tuesday
+		NewProvider: func(ctx context.Context) (Provider, error) { return &MockRadikoClient{}, nil },
+		History:     OpenHistory(filepath.Join(t.TempDir(), "history.jsonl")),
+		Summary:     &RunSummary{},
+	}
+
+	if err := RunOnce(context.Background(), opts); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	results := opts.Summary.Results()
+	if len(results) != 1 || results[0].ProgramName != "Special" || results[0].Outcome != OutcomeSucceeded {
+		t.Errorf("expected only the aired one-shot to be recorded, got %+v", results)
+	}
+	if !strings.Contains(logBuf.String(), "WARNING: The one-shot entry 'Missed Special' was not recorded") {
+		t.Errorf("expected a warning for the one-shot outside the timeshift window, got:\n%s", logBuf.String())
+	}
+
+	logBuf.Reset()
+	opts.Summary = &RunSummary{}
+	if err := RunOnce(context.Background(), opts); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if results := opts.Summary.Results(); len(results) != 0 {
+		t.Errorf("expected the recorded one-shot to be left alone, got %+v", results)
+	}
+	if !strings.Contains(logBuf.String(), "INFO: The one-shot entry 'Special' on 2026-01-12 is done") {
+		t.Errorf("expected the one-shot to be reported done, got:\n%s", logBuf.String())
+	}
+}
+
+func TestRunOnceSkipsHolidays(t *testing.T) {
+	var logBuf bytes.Buffer
+	opts := Options{
+		Schedule:    []ScheduleEntry{{ProgramName: "Weekday Show", DayOfWeek: "月", StartTime: "100000", StationID: "ST1", SkipHolidays: true}},
+		OutputDir:   t.TempDir(),
+		Logger:      log.New(&logBuf, "", 0),
+		Clock:       &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)}, // The day after 成人の日
+		NewProvider: func(ctx context.Context) (Provider, error) { return &MockRadikoClient{}, nil },
+		Summary:     &RunSummary{},
+	}
+	if err := RunOnce(context.Background(), opts); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if results := opts.Summary.Results(); len(results) != 0 {
+		t.Errorf("expected the holiday broadcast not to be recorded, got %+v", results)
+	}
+	if !strings.Contains(logBuf.String(), "INFO: Skipping the broadcast of 'Weekday Show' at 2026-01-12 10:00: 2026-01-12 is a public holiday (成人の日).") {
+		t.Errorf("expected the skip to be logged, got:\n%s", logBuf.String())
+	}
+}
+
+func TestRunOnceOverlappingEntries(t *testing.T) {
+	var logBuf bytes.Buffer
+	opts := Options{
+		Schedule: []ScheduleEntry{
+			{ProgramName: "Weekly", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"},
+			{ProgramName: "Monthly", DayOfWeek: "月", StartTime: "100000", StationID: "ST1", WeeksOfMonth: []int{1}},
+		},
+		OutputDir:   t.TempDir(),
+		Logger:      log.New(&logBuf, "", 0),
+		Clock:       &fakeClock{now: time.Date(2026, time.January, 6, 12, 0, 0, 0, JST)}, // The day after the 1st Monday
+		NewProvider: func(ctx context.Context) (Provider, error) { return &MockRadikoClient{}, nil },
+		Summary:     &RunSummary{},
+	}
+	if err := RunOnce(context.Background(), opts); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if results := opts.Summary.Results(); len(results) != 1 || results[0].ProgramName != "Weekly" {
+		t.Errorf("expected the broadcast to be recorded once, got %+v", results)
+	}
+	if !strings.Contains(logBuf.String(), "INFO: Skipping the broadcast of 'Monthly' at 2026-01-05 10:00: another entry records it.") {
+		t.Errorf("expected the skip to be logged, got:\n%s", logBuf.String())
+	}
+}
+
+func TestRunOnceProviderError(t *testing.T) {
+	opts := Options{
+		Schedule:  []ScheduleEntry{{ProgramName: "P", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}},
+		OutputDir: t.TempDir(),
+		Logger:    log.New(&bytes.Buffer{}, "", 0),
+		NewProvider: func(ctx context.Context) (Provider, error) {
+			return nil, fmt.Errorf("no provider")
+		},
+	}
+
+	if err := RunOnce(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "no provider") {
+		t.Errorf("expected provider error, got %v", err)
+	}
+}
+
+func TestRunOnceAuthenticatesOnce(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, time.January, 13, 12, 0, 0, 0, JST)} // Tuesday
+	schedule := []ScheduleEntry{
+		{ProgramName: "Program A", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"},
+		{ProgramName: "Program B", DayOfWeek: "月", StartTime: "110000", StationID: "ST1"},
+	}
+
+	tests := []struct {
+		name    string
+		authErr error
+		wantErr string
+	}{
+		{name: "success"},
+		{name: "failure", authErr: fmt.Errorf("auth1 status 403"), wantErr: "authentication with radiko failed (check the network connection"},
+		{name: "premium login", authErr: fmt.Errorf("%w: status code 401", errPremiumLogin), wantErr: "check radiko.mail and radiko.password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers, authentications := 0, 0
+			opts := Options{
+				Schedule:   schedule,
+				OutputDir:  t.TempDir(),
+				Logger:     log.New(io.Discard, "", 0),
+				Clock:      clock,
+				FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+				NewProvider: func(ctx context.Context) (Provider, error) {
+					providers++
+					return &MockRadikoClient{AuthenticateFn: func(ctx context.Context) error {
+						authentications++
+						return tt.authErr
+					}}, nil
+				},
+			}
+
+			err := RunOnce(context.Background(), opts)
+			if providers != 1 || authentications != 1 {
+				t.Errorf("expected one client authenticated once, got %d clients and %d authentications", providers, authentications)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("RunOnce failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			for _, entry := range schedule {
+				if !strings.Contains(err.Error(), entry.ProgramName+": authentication") {
+					t.Errorf("expected %s to fail with the authentication error, got %v", entry.ProgramName, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRunOnceConcurrency(t *testing.T) {
+	outputDir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)} // Tuesday
+
+	// Each download waits until both jobs are in flight, so the test only finishes when they run in parallel.
+	var inFlight sync.WaitGroup
+	inFlight.Add(2)
+	var once sync.Map
+	opts := Options{
+		Schedule: []ScheduleEntry{
+			{ProgramName: "Program A", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"},
+			{ProgramName: "Program B", DayOfWeek: "月", StartTime: "110000", StationID: "ST1"},
+		},
+		OutputDir:   outputDir,
+		Logger:      log.New(&bytes.Buffer{}, "", 0),
+		Clock:       clock,
+		Concurrency: 2,
+		// The client is shared by both jobs, so each job's chunks live under its own directory.
+		NewProvider: func(ctx context.Context) (Provider, error) {
+			client := &MockRadikoClient{}
+			client.ResolvePlaylistFn = func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+				return "http://mock.m3u8/" + pastTime.Format("1504") + ".m3u8", nil
+			}
+			client.ListChunksFn = func(ctx context.Context, uri string) ([]string, error) {
+				return []string{"http://mock.chunk/" + strings.TrimSuffix(path.Base(uri), ".m3u8") + "/chunk1.aac"}, nil
+			}
+			client.DoFn = func(req *http.Request) (*http.Response, error) {
+				if _, loaded := once.LoadOrStore(path.Dir(req.URL.Path), true); !loaded {
+					inFlight.Done()
+				}
+				inFlight.Wait()
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(dummyAACChunk))}, nil
+			}
+			return client, nil
+		},
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- RunOnce(context.Background(), opts) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("RunOnce failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("jobs did not run concurrently")
+	}
+
+	for _, name := range []string{"20260112100000-ST1-Program A.aac", "20260112110000-ST1-Program B.aac"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("expected output file %s: %v", name, err)
+		}
+	}
+}
+
+func TestRunOncePriority(t *testing.T) {
+	tests := []struct {
+		name               string
+		stationConcurrency int
+	}{
+		{name: "no station limit"},
+		{name: "one job per station", stationConcurrency: 1},
+		{name: "two jobs per station", stationConcurrency: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)} // Tuesday
+			var mu sync.Mutex
+			var order []string
+			opts := Options{
+				Schedule: []ScheduleEntry{
+					{ProgramName: "Program A", DayOfWeek: "月", StartTime: "090000", StationID: "ST1"},
+					{ProgramName: "Program B", DayOfWeek: "月", StartTime: "100000", StationID: "ST1", Priority: 10},
+					{ProgramName: "Program C", DayOfWeek: "月", StartTime: "110000", StationID: "ST2", Priority: -1},
+					{ProgramName: "Program D", DayOfWeek: "月", StartTime: "120000", StationID: "ST1", Priority: 10},
+				},
+				OutputDir:          t.TempDir(),
+				Logger:             log.New(&bytes.Buffer{}, "", 0),
+				Clock:              clock,
+				Concurrency:        1,
+				StationConcurrency: tt.stationConcurrency,
+				NewProvider: func(ctx context.Context) (Provider, error) {
+					client := &MockRadikoClient{}
+					client.ResolvePlaylistFn = func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+						mu.Lock()
+						order = append(order, pastTime.Format("1504"))
+						mu.Unlock()
+						return "http://mock.m3u8/playlist.m3u8", nil
+					}
+					return client, nil
+				},
+			}
+			if err := RunOnce(context.Background(), opts); err != nil {
+				t.Fatalf("RunOnce failed: %v", err)
+			}
+			if want := []string{"1000", "1200", "0900", "1100"}; !slices.Equal(order, want) {
+				t.Errorf("recorded in order %v, want %v", order, want)
+			}
+		})
+	}
+}
+
+func TestRunOnceStationConcurrency(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)} // Tuesday
+
+	// Downloads wait until both stations are in flight, which only happens when the second
+	// job of ST1 does not take the slot ahead of the job of ST2.
+	var inFlight sync.WaitGroup
+	inFlight.Add(2)
+	var (
+		mu      sync.Mutex
+		started = make(map[string]bool)
+		running = make(map[string]int)
+		peak    = make(map[string]int)
+	)
+	opts := Options{
+		Schedule: []ScheduleEntry{
+			{ProgramName: "Program A", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"},
+			{ProgramName: "Program B", DayOfWeek: "月", StartTime: "110000", StationID: "ST1"},
+			{ProgramName: "Program C", DayOfWeek: "月", StartTime: "120000", StationID: "ST2"},
+		},
+		OutputDir:          t.TempDir(),
+		Logger:             log.New(&bytes.Buffer{}, "", 0),
+		Clock:              clock,
+		Concurrency:        2,
+		StationConcurrency: 1,
+		NewProvider: func(ctx context.Context) (Provider, error) {
+			client := &MockRadikoClient{}
+			client.ResolvePlaylistFn = func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+				return "http://mock.m3u8/" + stationID + "/" + pastTime.Format("1504") + ".m3u8", nil
+			}
+			client.ListChunksFn = func(ctx context.Context, uri string) ([]string, error) {
+				station := path.Base(path.Dir(uri))
+				return []string{"http://mock.chunk/" + station + "/" + strings.TrimSuffix(path.Base(uri), ".m3u8") + "/chunk1.aac"}, nil
+			}
+			client.DoFn = func(req *http.Request) (*http.Response, error) {
+				station := strings.Split(strings.TrimPrefix(req.URL.Path, "/"), "/")[0]
+				mu.Lock()
+				if !started[station] {
+					started[station] = true
+					inFlight.Done()
+				}
+				running[station]++
+				peak[station] = max(peak[station], running[station])
+				mu.Unlock()
+				defer func() {
+					mu.Lock()
+					running[station]--
+					mu.Unlock()
+				}()
+				inFlight.Wait()
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(dummyAACChunk))}, nil
+			}
+			return client, nil
+		},
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- RunOnce(context.Background(), opts) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("RunOnce failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("jobs of different stations did not run concurrently")
+	}
+	if peak["ST1"] != 1 {
+		t.Errorf("expected one job of ST1 at a time, got %d", peak["ST1"])
+	}
+}
+
+func TestRunDaemonStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	clock := &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)}
+
+	passes := 0
+	opts := Options{
+		Schedule:  []ScheduleEntry{{ProgramName: "P", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}},
+		OutputDir: t.TempDir(),
+		Logger:    log.New(&bytes.Buffer{}, "", 0),
+		Clock:     clock,
+		Interval:  time.Hour,
+		NewProvider: func(ctx context.Context) (Provider, error) {
+			passes++
+			if passes == 3 {
+				cancel()
+			}
+			return &MockRadikoClient{}, nil
+		},
+	}
+
+	err := RunDaemon(ctx, opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if passes != 3 {
+		t.Errorf("expected 3 passes, got %d", passes)
+	}
+	if clock.afters != 2 {
+		t.Errorf("expected daemon to wait twice, got %d", clock.afters)
+	}
+}
+
+func TestRunDaemonReloadsSchedule(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)}
+
+	removed := ScheduleEntry{ProgramName: "Removed", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}
+	added := ScheduleEntry{ProgramName: "Added", DayOfWeek: "月", StartTime: "110000", StationID: "ST1"}
+
+	started := make(chan struct{})
+	jobErr := make(chan error, 1)
+	var logBuf bytes.Buffer
+	updates := make(chan []ScheduleEntry)
+	opts := Options{
+		Schedule:        []ScheduleEntry{removed},
+		ScheduleUpdates: updates,
+		OutputDir:       t.TempDir(),
+		Logger:          log.New(&logBuf, "", 0),
+		Clock:           clock,
+		FetchGuide:      func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+		NewProvider: func(ctx context.Context) (Provider, error) {
+			return &MockRadikoClient{
+				ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+					if pastTime.Hour() == 11 { // The added entry: stop the daemon.
+						cancel()
+						return "", ctx.Err()
+					}
+					close(started)
+					<-ctx.Done() // The removed entry blocks until its job is cancelled.
+					jobErr <- ctx.Err()
+					return "", ctx.Err()
+				},
+			}, nil
+		},
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- RunDaemon(ctx, opts) }()
+
+	<-started
+	updates <- []ScheduleEntry{added}
+
+	select {
+	case err := <-jobErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected job of removed entry to be cancelled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("job of removed entry was not cancelled")
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not run a pass with the reloaded schedule")
+	}
+	if clock.afters != 0 {
+		t.Errorf("expected the reload to start a pass without waiting, got %d waits", clock.afters)
+	}
+	if !strings.Contains(logBuf.String(), "Schedule reloaded: 1 entries") {
+		t.Errorf("expected reload to be logged, got:\n%s", logBuf.String())
+	}
+}
+
+func TestRunDaemonWeeklyPreviewSurvivesRestart(t *testing.T) {
+	queue, err := OpenJobQueue(filepath.Join(t.TempDir(), "queue.json"))
+	if err != nil {
+		t.Fatalf("OpenJobQueue failed: %v", err)
+	}
+	var previews []string
+	sunday := time.Date(2026, time.January, 11, 18, 30, 0, 0, JST) // Just after the default slot.
+
+	run := func(now time.Time) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := Options{
+			Schedule:      []ScheduleEntry{{ProgramName: "P", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}},
+			OutputDir:     t.TempDir(),
+			Logger:        log.New(io.Discard, "", 0),
+			Clock:         &fakeClock{now: now},
+			Queue:         queue,
+			WeeklyPreview: WeeklyPreviewConfig{Enabled: true},
+			Notifier: notifierFunc(func(ctx context.Context, subject, body string) error {
+				previews = append(previews, subject)
+				return nil
+			}),
+			FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+			NewProvider: func(ctx context.Context) (Provider, error) {
+				cancel() // Stop after the first pass.
+				return nil, ctx.Err()
+			},
+		}
+		if err := RunDaemon(ctx, opts); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	}
+
+	run(sunday)
+	if len(previews) != 1 {
+		t.Fatalf("expected the preview to be sent, got %v", previews)
+	}
+	// A restart in the same week does not send it again.
+	run(sunday.Add(2 * time.Hour))
+	if len(previews) != 1 {
+		t.Errorf("expected no preview after the restart, got %v", previews)
+	}
+	if _, err := os.Stat(queue.PreviewStatePath()); err != nil {
+		t.Errorf("expected the preview state next to the queue: %v", err)
+	}
+	// The next week's slot sends it again.
+	run(sunday.AddDate(0, 0, 7))
+	if len(previews) != 2 {
+		t.Errorf("expected the next week's preview, got %v", previews)
+	}
+}
+
+func TestRunOncePanicWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)} // Tuesday
+	fresh := time.Date(2026, time.January, 12, 12, 0, 0, 0, JST)
+	expiring := time.Date(2026, time.January, 6, 11, 0, 0, 0, JST) // Leaves the window in an hour.
+
+	// The fresh broadcast holds the station's only slot until the expiring one has started,
+	// and the expiring one downloads more chunks than the rate limit allows in the test's time.
+	expiringStarted := make(chan struct{})
+	var logBuf bytes.Buffer
+	opts := Options{
+		Schedule: []ScheduleEntry{
+			{ProgramName: "Fresh", DayOfWeek: "月", StartTime: "120000", StationID: "ST1", Priority: 10},
+			{ProgramName: "Expiring", DayOfWeek: "火", StartTime: "110000", StationID: "ST1"},
+		},
+		OutputDir:          t.TempDir(),
+		Logger:             log.New(&logBuf, "", 0),
+		Clock:              clock,
+		FetchGuide:         func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+		Concurrency:        2,
+		StationConcurrency: 1,
+		ChunkRate:          0.01,
+		JobChunkRate:       0.01,
+		PanicWindow:        2 * time.Hour,
+		NewProvider: func(ctx context.Context) (Provider, error) {
+			return &MockRadikoClient{
+				ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+					switch {
+					case pastTime.Equal(expiring):
+						close(expiringStarted)
+					case pastTime.Equal(fresh):
+						select {
+						case <-expiringStarted:
+						case <-ctx.Done():
+							return "", ctx.Err()
+						}
+					}
+					return "http://mock.m3u8/" + pastTime.Format("200601021504") + ".m3u8", nil
+				},
+				ListChunksFn: func(ctx context.Context, uri string) ([]string, error) {
+					if strings.Contains(uri, fresh.Format("200601021504")) {
+						return []string{"http://mock.chunk/fresh.aac"}, nil
+					}
+					return []string{"http://mock.chunk/1.aac", "http://mock.chunk/2.aac", "http://mock.chunk/3.aac"}, nil
+				},
+			}, nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	summary := &RunSummary{}
+	opts.Summary = summary
+	if err := RunOnce(ctx, opts); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if succeeded, _, _ := summary.Counts(); succeeded != 2 {
+		t.Errorf("expected both broadcasts to be recorded, got %+v", summary.Results())
+	}
+	if !strings.Contains(logBuf.String(), "downloading it without the chunk rate limits") {
+		t.Errorf("expected panic mode to be logged, got:\n%s", logBuf.String())
+	}
+}
+
+func TestRunOnceLocation(t *testing.T) {
+	newYork, err := LoadScheduleLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Runs with different time zones read the same entry as different slots.
+	tests := []struct {
+		loc  *time.Location
+		want time.Time
+	}{
+		{loc: nil, want: time.Date(2026, time.January, 12, 20, 0, 0, 0, JST)},
+		{loc: newYork, want: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)},
+	}
+	for _, tt := range tests {
+		var resolved time.Time
+		opts := Options{
+			Schedule:   []ScheduleEntry{{ProgramName: "Evening", DayOfWeek: "月", StartTime: "200000", StationID: "ST1"}},
+			OutputDir:  t.TempDir(),
+			Logger:     log.New(io.Discard, "", 0),
+			Clock:      &fakeClock{now: time.Date(2026, time.January, 13, 11, 0, 0, 0, JST)},
+			Location:   tt.loc,
+			FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+			NewProvider: func(ctx context.Context) (Provider, error) {
+				return &MockRadikoClient{ResolvePlaylistFn: func(ctx context.Context, stationID string, start time.Time) (string, error) {
+					resolved = start
+					return "http://mock.m3u8/playlist.m3u8", nil
+				}}, nil
+			},
+		}
+		if err := RunOnce(context.Background(), opts); err != nil {
+			t.Fatalf("RunOnce(%v) failed: %v", tt.loc, err)
+		}
+		if !resolved.Equal(tt.want) {
+			t.Errorf("location %v recorded the broadcast at %s, want %s", tt.loc, resolved, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// PostCommandInfo describes the finished recording passed to a post_command.
+type PostCommandInfo struct {
+	FilePath  string // The recording, or the .m3u playlist of its parts when it was rotated
+	Title     string // Title resolved from the program guide (or the schedule entry as fallback)
+	StationID string
+	StartTime time.Time
+	Entry     ScheduleEntry
+	Chapters  string // The chapter file written next to the recording, if any (see JobOptions.Chapters)
+	Manifest  string // The manifest written next to the recording (see Manifest), if any
+	ShowNotes string // The show notes written next to the recording (see JobOptions.ShowNotes), if any
+
+	// Transcript is the transcript written next to the recording (see TranscriptionConfig), if any.
+	// Rotated recordings have one per part, listed in the manifest.
+	Transcript string
+}
+
+// env returns the RADIKO_* environment variables exposed to the command.
+func (i PostCommandInfo) env() []string {
+	return []string{
+		"RADIKO_FILE=" + i.FilePath,
+		"RADIKO_TITLE=" + i.Title,
+		"RADIKO_STATION=" + i.StationID,
+		"RADIKO_START=" + i.StartTime.Format("20060102150405"),
+		"RADIKO_PROGRAM_NAME=" + i.Entry.ProgramName,
+		"RADIKO_CHAPTERS=" + i.Chapters,
+		"RADIKO_MANIFEST=" + i.Manifest,
+		"RADIKO_SHOW_NOTES=" + i.ShowNotes,
+		"RADIKO_TRANSCRIPT=" + i.Transcript,
+	}
+}
+
+// resolvePostCommand returns the entry's post_command, falling back to the global one.
+func resolvePostCommand(entry ScheduleEntry, global string) string {
+	if entry.PostCommand != "" {
+		return entry.PostCommand
+	}
+	return global
+}
+
+// runPostCommand runs command through the system shell with the recording details in its environment.
+// The command's combined output is written to logger.
+func runPostCommand(ctx context.Context, logger *log.Logger, command string, info PostCommandInfo) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), info.env()...)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	logger.Printf("INFO: Running post_command: %s", command)
+	err := cmd.Run()
+	for _, line := range strings.Split(strings.TrimRight(output.String(), "\n"), "\n") {
+		if line != "" {
+			logger.Printf("INFO: [post_command] %s", line)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("post_command '%s' failed: %w", command, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunPostCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX sh syntax")
+	}
+
+	outFile := filepath.Join(t.TempDir(), "env.txt")
+	info := PostCommandInfo{
+		FilePath:  "/srv/radio/show.aac",
+		Title:     "オードリーのオールナイトニッポン",
+		StationID: "LFR",
+		StartTime: time.Date(2026, time.January, 10, 1, 0, 0, 0, JST),
+		Entry:     ScheduleEntry{ProgramName: "ANN"},
+	}
+
+	var logBuf bytes.Buffer
+	command := `printf '%s|%s|%s|%s' "$RADIKO_FILE" "$RADIKO_TITLE" "$RADIKO_STATION" "$RADIKO_START" > ` + outFile + `; echo done`
+	if err := runPostCommand(context.Background(), log.New(&logBuf, "", 0), command, info); err != nil {
+		t.Fatalf("runPostCommand failed: %v", err)
+	}
+
+	got, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("Failed to read command output: %v", err)
+	}
+	want := "/srv/radio/show.aac|オードリーのオールナイトニッポン|LFR|20260110010000"
+	if string(got) != want {
+		t.Errorf("command saw %q, want %q", string(got), want)
+	}
+	if !strings.Contains(logBuf.String(), "[post_command] done") {
+		t.Errorf("expected command output to be logged, got:\n%s", logBuf.String())
+	}
+}
+
+func TestRunPostCommandFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX sh syntax")
+	}
+
+	err := runPostCommand(context.Background(), log.New(&bytes.Buffer{}, "", 0), "exit 3", PostCommandInfo{})
+	if err == nil || !strings.Contains(err.Error(), "post_command 'exit 3' failed") {
+		t.Errorf("expected post_command failure, got %v", err)
+	}
+}
+
+func TestExecuteJobPostCommandFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX sh syntax")
+	}
+	var logBuf bytes.Buffer
+	store := &recordingPostStore{}
+	opts := JobOptions{
+		OutputDir:   t.TempDir(),
+		Logger:      log.New(&logBuf, "", 0),
+		PostStore:   store,
+		PostCommand: "exit 3",
+		FetchGuide:  func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+		DisableProgressBar: true,
+	}
+	entry := ScheduleEntry{ProgramName: "Test Program", StationID: "ST1"}
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+
+	result, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts)
+	if err != nil {
+		t.Fatalf("expected the job to succeed despite the post_command, got %v", err)
+	}
+	if !strings.Contains(logBuf.String(), "WARNING: post_command 'exit 3' failed") {
+		t.Errorf("expected the failure to be logged, got:\n%s", logBuf.String())
+	}
+	if len(store.uploaded) == 0 || store.uploaded[0] != result.OutputPath {
+		t.Errorf("expected the recording to be uploaded, got %v", store.uploaded)
+	}
+}
+
+func TestResolvePostCommand(t *testing.T) {
+	if got := resolvePostCommand(ScheduleEntry{}, "global"); got != "global" {
+		t.Errorf("expected global command, got %q", got)
+	}
+	if got := resolvePostCommand(ScheduleEntry{PostCommand: "entry"}, "global"); got != "entry" {
+		t.Errorf("expected entry command to take precedence, got %q", got)
+	}
+}
@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PostStore uploads finished recordings to a location off the recording box.
+type PostStore interface {
+	// Upload stores the file at localPath and returns the remote location it was written to.
+	Upload(ctx context.Context, localPath string) (string, error)
+}
+
+// PostStoreConfig selects and configures the post-store backend.
+type PostStoreConfig struct {
+	Type        string   `json:"type"`         // Backend name, e.g. "s3". Empty disables uploads.
+	DeleteLocal bool     `json:"delete_local"` // Remove the local file after a successful upload.
+	S3          S3Config `json:"s3"`
+}
+
+// S3Config holds the settings for an S3-compatible bucket.
+type S3Config struct {
+	Endpoint        string `json:"endpoint"` // e.g. "https://s3.ap-northeast-1.amazonaws.com" or a MinIO URL
+	Region          string `json:"region"`
+	Bucket          string `json:"bucket"`
+	Prefix          string `json:"prefix"` // Key prefix ("path") inside the bucket
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+}
+
+// postStoreFactories maps backend names to their constructors.
+var postStoreFactories = map[string]func(cfg PostStoreConfig) (PostStore, error){
+	"s3": func(cfg PostStoreConfig) (PostStore, error) { return NewS3Store(cfg.S3, nil) },
+}
+
+// NewPostStore creates the backend selected by cfg.Type.
+// It returns nil without error when no backend is configured.
+func NewPostStore(cfg PostStoreConfig) (PostStore, error) {
+	if cfg.Type == "" {
+		return nil, nil
+	}
+	factory, ok := postStoreFactories[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown post_store type: %s", cfg.Type)
+	}
+	return factory(cfg)
+}
+
+// S3Store uploads files to an S3-compatible bucket using path-style requests signed with AWS Signature V4.
+type S3Store struct {
+	cfg    S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3Store validates cfg and returns an S3Store that sends requests through client.
+// A nil client means http.DefaultClient.
+func NewS3Store(cfg S3Config, client *http.Client) (*S3Store, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 post_store requires endpoint and bucket")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("s3 post_store requires access_key_id and secret_access_key")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3Store{cfg: cfg, client: client, now: time.Now}, nil
+}
+
+// Upload PUTs the file into the bucket under the configured prefix.
+func (s *S3Store) Upload(ctx context.Context, localPath string) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open '%s' for upload: %w", localPath, err)
+	}
+	defer file.Close()
+
+	// The payload hash is part of the signature, so the file is read twice: once to hash, once to send.
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash '%s': %w", localPath, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind '%s': %w", localPath, err)
+	}
+	payloadHash := hex.EncodeToString(hasher.Sum(nil))
+
+	key := path.Join(s.cfg.Prefix, filepath.Base(localPath))
+	canonicalURI := "/" + s.cfg.Bucket + "/" + s3EscapePath(key)
+	objectURL := strings.TrimRight(s.cfg.Endpoint, "/") + canonicalURI
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", uploadContentType(localPath))
+	s.sign(req, canonicalURI, payloadHash)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload '%s': %w", localPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("failed to upload '%s': HTTP status %d: %s", localPath, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return fmt.Sprintf("s3://%s/%s", s.cfg.Bucket, key), nil
+}
+
+// uploadContentType returns the media type of the recording at path, by its extension.
+func uploadContentType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".opus":
+		return "audio/ogg"
+	case ".flac":
+		return "audio/flac"
+	case ".m4a":
+		return "audio/mp4"
+	}
+	return "audio/aac"
+}
+
+// sign adds the AWS Signature V4 headers to req.
+func (s *S3Store) sign(req *http.Request, canonicalURI, payloadHash string) {
+	t := s.now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	dateStamp := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders, canonicalHeaders := canonicalS3Headers(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		req.URL.Query().Encode(),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", dateStamp, s.cfg.Region)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), dateStamp)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalS3Headers returns the signed header list and canonical header block for req.
+// Host, Range and all x-amz-* headers are signed.
+func canonicalS3Headers(req *http.Request) (string, string) {
+	values := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			values[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(values[name])
+		b.WriteString("\n")
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath URI-encodes each segment of an object key as required by Signature V4:
+// every byte except the unreserved characters is percent-encoded.
+func s3EscapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WeeklyPreviewConfig controls the weekly "what will be recorded" notification sent in daemon mode.
+type WeeklyPreviewConfig struct {
+	Enabled   bool   `json:"enabled"`
+	DayOfWeek string `json:"day_of_week"` // Japanese weekday as in schedule.json. Defaults to "日".
+	Time      string `json:"time"`        // HHMMSS. Defaults to "180000".
+}
+
+// slot returns the most recent past time the preview was due, its day and time read in loc.
+func (c WeeklyPreviewConfig) slot(now time.Time, loc *time.Location) (time.Time, error) {
+	day, at := c.DayOfWeek, c.Time
+	if day == "" {
+		day = "日"
+	}
+	if at == "" {
+		at = "180000"
+	}
+	return CalculateRecentPastRunTime(ScheduleEntry{DayOfWeek: day, StartTime: at}, now, loc)
+}
+
+// weeklyPreviewDue reports whether a preview should be sent now given when the last one was sent.
+// A slot that passed more than a day ago (e.g. the daemon was down) is not sent late.
+func weeklyPreviewDue(cfg WeeklyPreviewConfig, now, lastSent time.Time, loc *time.Location) (bool, error) {
+	if !cfg.Enabled {
+		return false, nil
+	}
+	slot, err := cfg.slot(now, loc)
+	if err != nil {
+		return false, fmt.Errorf("invalid weekly_preview setting: %w", err)
+	}
+	return lastSent.Before(slot) && now.Sub(slot) < 24*time.Hour, nil
+}
+
+// previewState remembers when RunDaemon last sent the weekly preview, so a restarted daemon
+// does not send the same week's preview again.
+type previewState struct {
+	SentAt time.Time `json:"sent_at"`
+}
+
+// PreviewStatePath returns where RunDaemon keeps the weekly preview's state, next to the queue
+// file. It is empty for a nil queue, whose daemon keeps the state in memory only.
+func (q *JobQueue) PreviewStatePath() string {
+	if q == nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(q.path), "preview.json")
+}
+
+// loadPreviewSent returns when the preview was last sent according to the state at path, or
+// the zero time if path is empty or there is no state yet.
+func loadPreviewSent(path string) (time.Time, error) {
+	if path == "" {
+		return time.Time{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to read weekly preview state '%s': %w", path, err)
+	}
+	var state previewState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return time.Time{}, fmt.Errorf("error parsing weekly preview state '%s': %w", path, err)
+	}
+	return state.SentAt, nil
+}
+
+// savePreviewSent records at path that the preview was sent at sentAt. An empty path keeps nothing.
+func savePreviewSent(path string, sentAt time.Time) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(previewState{SentAt: sentAt}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode weekly preview state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create weekly preview state directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write weekly preview state '%s': %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write weekly preview state '%s': %w", path, err)
+	}
+	return nil
+}
+
+// PreviewItem is one upcoming recording in the weekly preview.
+type PreviewItem struct {
+	Entry ScheduleEntry
+	At    time.Time
+	End   time.Time // End of the broadcast from the guide; zero when the slot was not found
+	Title string    // Title from the guide; empty when the slot was not found
+	Err   error     // Why the entry could not be resolved, if it could not
+}
+
+// previewHorizon is how far ahead the weekly preview looks, which is also how far the weekly
+// guide reaches.
+const previewHorizon = 7 * 24 * time.Hour
+
+// BuildWeeklyPreview resolves the next broadcast of every enabled entry within the coming week against
+// the guide, reading the entries in loc. fetchGuide is called at most once per station.
+func BuildWeeklyPreview(ctx context.Context, entries []ScheduleEntry, now time.Time, loc *time.Location, fetchGuide func(ctx context.Context, stationID string) ([]byte, error)) []PreviewItem {
+	var items []PreviewItem
+	for _, item := range BuildUpcoming(ctx, entries, now, loc, fetchGuide) {
+		if item.At.Sub(now) <= previewHorizon {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// BuildUpcoming is BuildWeeklyPreview without the one-week limit: one-shot entries (ScheduleEntry.Date)
+// are included however far ahead they air, unresolved when that is beyond the guide. One-shots
+// that have aired are left out.
+func BuildUpcoming(ctx context.Context, entries []ScheduleEntry, now time.Time, loc *time.Location, fetchGuide func(ctx context.Context, stationID string) ([]byte, error)) []PreviewItem {
+	guides := map[string][]byte{}
+	guideErrs := map[string]error{}
+
+	items := make([]PreviewItem, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsEnabled() {
+			continue
+		}
+		if entry.OneShot() {
+			if start, err := datedTime(entry, loc); err == nil && !start.After(now) {
+				continue
+			}
+		}
+		item := PreviewItem{Entry: entry}
+		item.At, item.Err = CalculateNextRunTime(entry, now, loc)
+		if item.Err == nil && item.At.Sub(now) <= previewHorizon {
+			if _, fetched := guides[entry.StationID]; !fetched && guideErrs[entry.StationID] == nil {
+				guides[entry.StationID], guideErrs[entry.StationID] = fetchGuide(ctx, entry.StationID)
+			}
+			if err := guideErrs[entry.StationID]; err != nil {
+				item.Err = err
+			} else if prog, err := FindProgram(guides[entry.StationID], item.At); err != nil {
+				item.Err = err
+			} else {
+				item.Title = prog.Title
+				item.End, _ = time.ParseInLocation("20060102150405", prog.To, JST)
+			}
+		}
+		items = append(items, item)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].At.Before(items[j].At) })
+	return items
+}
+
+// FormatWeeklyPreview renders the preview as a notification subject and body.
+// Entries missing from the guide or whose guide title differs from program_name are flagged.
+func FormatWeeklyPreview(items []PreviewItem, now time.Time) (string, string) {
+	subject := fmt.Sprintf("radikoRecScheduler: %d recordings planned for the week of %s", len(items), now.Format("2006-01-02"))
+
+	var b strings.Builder
+	for _, item := range items {
+		if item.At.IsZero() {
+			fmt.Fprintf(&b, "⚠ %s: %v\n", item.Entry.ProgramName, item.Err)
+			continue
+		}
+		when := fmt.Sprintf("%s(%s) %s", item.At.Format("01/02"), japaneseDayOfWeek(item.At.Weekday()), item.At.Format("15:04"))
+		switch {
+		case item.Err != nil:
+			fmt.Fprintf(&b, "⚠ %s %s %s: not found in guide (%v)\n", when, item.Entry.StationID, item.Entry.ProgramName, item.Err)
+		case item.Title != item.Entry.ProgramName:
+			fmt.Fprintf(&b, "⚠ %s %s %s (schedule: %s)\n", when, item.Entry.StationID, item.Title, item.Entry.ProgramName)
+		default:
+			fmt.Fprintf(&b, "  %s %s %s\n", when, item.Entry.StationID, item.Title)
+		}
+	}
+	return subject, b.String()
+}
@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const previewGuideXML = `<?xml version="1.0" encoding="UTF-8"?>
+<radiko>
+  <stations>
+    <station id="LFR">
+      <name>ニッポン放送</name>
+      <progs>
+        <prog ft="20260117010000" to="20260117030000" ftl="0100" tol="0300" dur="7200">
+          <title>オードリーのオールナイトニッポン</title>
+        </prog>
+        <prog ft="20260111230000" to="20260111233000" ftl="2300" tol="2330" dur="1800">
+          <title>別の番組</title>
+        </prog>
+      </progs>
+    </station>
+  </stations>
+</radiko>`
+
+func TestBuildWeeklyPreview(t *testing.T) {
+	now := time.Date(2026, time.January, 11, 18, 0, 0, 0, JST) // Sunday 18:00
+	entries := []ScheduleEntry{
+		{ProgramName: "櫻坂46 こちら有楽町星空放送局", DayOfWeek: "日", StartTime: "230000", StationID: "LFR"},
+		{ProgramName: "オードリーのオールナイトニッポン", DayOfWeek: "土", StartTime: "010000", StationID: "LFR"},
+		{ProgramName: "Gone", DayOfWeek: "水", StartTime: "120000", StationID: "TBS"},
+	}
+
+	fetches := 0
+	items := BuildWeeklyPreview(context.Background(), entries, now, nil, func(ctx context.Context, stationID string) ([]byte, error) {
+		fetches++
+		if stationID == "TBS" {
+			return nil, fmt.Errorf("guide unavailable")
+		}
+		return []byte(previewGuideXML), nil
+	})
+
+	if fetches != 2 {
+		t.Errorf("expected one guide fetch per station, got %d", fetches)
+	}
+	if len(items) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(items))
+	}
+	// Sorted by time: Sun 11th (later today), Wed 14th, Sat 17th
+	if items[0].Title != "別の番組" {
+		t.Errorf("expected drifted title to be resolved from the guide, got %+v", items[0])
+	}
+	if items[1].Entry.ProgramName != "Gone" || items[1].Err == nil {
+		t.Errorf("expected unresolved TBS entry second, got %+v", items[1])
+	}
+	if items[2].Title != "オードリーのオールナイトニッポン" || !items[2].At.Equal(time.Date(2026, time.January, 17, 1, 0, 0, 0, JST)) ||
+		!items[2].End.Equal(time.Date(2026, time.January, 17, 3, 0, 0, 0, JST)) {
+		t.Errorf("unexpected third item: %+v", items[2])
+	}
+
+	_, body := FormatWeeklyPreview(items, now)
+	for _, want := range []string{
+		"⚠ 01/14(水) 12:00 TBS Gone: not found in guide",
+		"  01/17(土) 01:00 LFR オードリーのオールナイトニッポン",
+		"⚠ 01/11(日) 23:00 LFR 別の番組 (schedule: 櫻坂46 こちら有楽町星空放送局)",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("preview body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestBuildUpcomingOneShots(t *testing.T) {
+	now := time.Date(2026, time.January, 11, 18, 0, 0, 0, JST) // Sunday 18:00
+	entries := []ScheduleEntry{
+		{ProgramName: "Aired Special", Date: "2026-01-10", StartTime: "230000", StationID: "LFR"},
+		{ProgramName: "Spring Special", Date: "2026-03-20", StartTime: "130000", StationID: "TBS"},
+		{ProgramName: "オードリーのオールナイトニッポン", Date: "2026-01-17", StartTime: "010000", StationID: "LFR"},
+	}
+	fetched := map[string]bool{}
+	fetch := func(ctx context.Context, stationID string) ([]byte, error) {
+		fetched[stationID] = true
+		return []byte(previewGuideXML), nil
+	}
+
+	items := BuildUpcoming(context.Background(), entries, now, nil, fetch)
+	if len(items) != 2 || items[0].Title != "オードリーのオールナイトニッポン" || items[1].Entry.ProgramName != "Spring Special" {
+		t.Fatalf("expected the two coming one-shots, got %+v", items)
+	}
+	if !items[1].At.Equal(time.Date(2026, time.March, 20, 13, 0, 0, 0, JST)) || fetched["TBS"] {
+		t.Errorf("expected the far one-shot unresolved at its date without a guide fetch, got %+v", items[1])
+	}
+	if weekly := BuildWeeklyPreview(context.Background(), entries, now, nil, fetch); len(weekly) != 1 {
+		t.Errorf("expected only this week's one-shot in the weekly preview, got %+v", weekly)
+	}
+
+	data := string(FormatScheduleICS(items, now))
+	if strings.Contains(data, "RRULE") || !strings.Contains(data, "DTSTART;TZID=Asia/Tokyo:20260320T130000\r\n") {
+		t.Errorf("expected single events for one-shots:\n%s", data)
+	}
+}
+
+func TestWeeklyPreviewDue(t *testing.T) {
+	cfg := WeeklyPreviewConfig{Enabled: true} // Sunday 18:00 by default
+	sunday := time.Date(2026, time.January, 11, 18, 30, 0, 0, JST)
+
+	tests := []struct {
+		name     string
+		cfg      WeeklyPreviewConfig
+		now      time.Time
+		lastSent time.Time
+		want     bool
+	}{
+		{name: "Just after slot, never sent", cfg: cfg, now: sunday, want: true},
+		{name: "Already sent this week", cfg: cfg, now: sunday, lastSent: sunday.Add(-10 * time.Minute), want: false},
+		{name: "Slot missed by more than a day", cfg: cfg, now: sunday.AddDate(0, 0, 2), want: false},
+		{name: "Disabled", cfg: WeeklyPreviewConfig{}, now: sunday, want: false},
+		{name: "Custom slot not reached", cfg: WeeklyPreviewConfig{Enabled: true, DayOfWeek: "日", Time: "200000"}, now: sunday, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := weeklyPreviewDue(tt.cfg, tt.now, tt.lastSent, nil)
+			if err != nil {
+				t.Fatalf("weeklyPreviewDue failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("weeklyPreviewDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
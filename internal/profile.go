@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+)
+
+// ProfileEnv selects a profile like the -profile flag.
+const ProfileEnv = EnvPrefix + "PROFILE"
+
+// profilesDir is the subdirectory of the config, data and cache directories holding the profiles.
+const profilesDir = "profiles"
+
+// Profile is a named set of settings, such as one per family member sharing a NAS: its
+// config.json, schedule, history and caches live in a profiles/<name> subdirectory of the usual
+// directories, and recordings go to output/<name> unless its config.json sets output_dir.
+// The zero value is the default profile, which uses the directories themselves. Several profiles
+// can be used at once, since each resolves its own directories.
+type Profile string
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ParseProfile returns the profile called name, or the default profile if name is "".
+func ParseProfile(name string) (Profile, error) {
+	if name != "" && !profileNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid profile name '%s': use letters, digits, '.', '_' and '-'", name)
+	}
+	return Profile(name), nil
+}
+
+// ListProfiles returns the profiles that have a directory below the config directory.
+func ListProfiles() ([]Profile, error) {
+	base, err := configDirSpec.resolve(runtime.GOOS, os.Getenv, os.UserHomeDir)
+	if err != nil {
+		return nil, err
+	}
+	dirEntries, err := os.ReadDir(filepath.Join(base, profilesDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+	var profiles []Profile
+	for _, entry := range dirEntries {
+		if entry.IsDir() && profileNamePattern.MatchString(entry.Name()) {
+			profiles = append(profiles, Profile(entry.Name()))
+		}
+	}
+	slices.Sort(profiles)
+	return profiles, nil
+}
@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProfile(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", base)
+
+	for _, name := range []string{"all,bob", "../alice", ".hidden", "a b"} {
+		if _, err := ParseProfile(name); err == nil {
+			t.Errorf("ParseProfile(%q) succeeded, want an error", name)
+		}
+	}
+
+	for _, name := range []string{"bob", "alice"} {
+		profile, err := ParseProfile(name)
+		if err != nil {
+			t.Fatalf("ParseProfile(%q) failed: %v", name, err)
+		}
+		dir, err := profile.configDir()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(base, appName, profilesDir, name); dir != want {
+			t.Errorf("config dir of %s = %s, want %s", name, dir, want)
+		}
+		if got, want := profile.DefaultConfig().OutputDir, filepath.Join("output", name); got != want {
+			t.Errorf("default output dir of %s = %s, want %s", name, got, want)
+		}
+	}
+
+	profiles, err := ListProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Profile{"alice", "bob"}; !reflect.DeepEqual(profiles, want) {
+		t.Errorf("ListProfiles() = %v, want %v", profiles, want)
+	}
+
+	if dir, _ := Profile("").configDir(); dir != filepath.Join(base, appName) {
+		t.Errorf("config dir of the default profile = %s", dir)
+	}
+	if got := DefaultConfig().OutputDir; got != "output" {
+		t.Errorf("default output dir of the default profile = %s", got)
+	}
+}
+
+func TestProfilesRunTogether(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+
+	// The profiles read the same entry in their own time zones, record to their own output
+	// directories and log in their own languages, all in one process at the same time. Recordings
+	// are named in the time zone of the schedule.
+	tests := []struct {
+		profile   Profile
+		config    string
+		wantStart time.Time
+		want      string
+		wantLog   string
+	}{
+		{
+			profile:   "alice",
+			config:    `{}`,
+			wantStart: time.Date(2026, time.January, 12, 20, 0, 0, 0, JST),
+			want:      filepath.Join("output", "alice", "20260112200000-ST1-Evening.aac"),
+			wantLog:   "INFO: Starting recording for: Evening",
+		},
+		{
+			profile:   "bob",
+			config:    `{"timezone": "America/New_York", "lang": "ja"}`,
+			wantStart: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST),
+			want:      filepath.Join("output", "bob", "20260112200000-ST1-Evening.aac"),
+			wantLog:   "INFO: 録音を開始します: Evening",
+		},
+	}
+	logs := make([]strings.Builder, len(tests))
+	starts := make([]time.Time, len(tests))
+	var wg sync.WaitGroup
+	errs := make([]error, len(tests))
+	for i, tt := range tests {
+		configPath, err := tt.profile.ConfigPath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(configPath, []byte(tt.config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := tt.profile.LoadEffectiveConfig(configPath, nil)
+		if err != nil {
+			t.Fatalf("loading the config of %s: %v", tt.profile, err)
+		}
+		opts := Options{
+			Schedule:   []ScheduleEntry{{ProgramName: "Evening", DayOfWeek: "月", StartTime: "200000", StationID: "ST1"}},
+			OutputDir:  cfg.OutputDir,
+			Logger:     log.New(&logs[i], "", 0),
+			Clock:      &fakeClock{now: time.Date(2026, time.January, 13, 11, 0, 0, 0, JST)},
+			Location:   cfg.ScheduleLocation(),
+			Output:     cfg.Output(),
+			FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+			NewProvider: func(ctx context.Context) (Provider, error) {
+				return &MockRadikoClient{ResolvePlaylistFn: func(ctx context.Context, stationID string, start time.Time) (string, error) {
+					starts[i] = start
+					return "http://mock.m3u8/playlist.m3u8", nil
+				}}, nil
+			},
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = RunOnce(context.Background(), opts)
+		}()
+	}
+	wg.Wait()
+
+	for i, tt := range tests {
+		if errs[i] != nil {
+			t.Errorf("RunOnce of %s failed: %v", tt.profile, errs[i])
+		}
+		if !starts[i].Equal(tt.wantStart) {
+			t.Errorf("%s recorded the broadcast at %s, want %s", tt.profile, starts[i], tt.wantStart)
+		}
+		if _, err := os.Stat(tt.want); err != nil {
+			t.Errorf("expected %s to record %s: %v", tt.profile, tt.want, err)
+		}
+		if !strings.Contains(logs[i].String(), tt.wantLog) {
+			t.Errorf("expected the log of %s to contain %q, got:\n%s", tt.profile, tt.wantLog, logs[i].String())
+		}
+	}
+}
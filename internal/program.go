@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -37,52 +38,150 @@ type Progs struct {
 }
 
 // Prog represents a single program.
+// The JSON tags are used when a program is stored as a guide snapshot in the history.
 type Prog struct {
-	XMLName  xml.Name `xml:"prog"`
-	Ft       string   `xml:"ft,attr"`
-	To       string   `xml:"to,attr"`
-	Ftl      string   `xml:"ftl,attr"`
-	Tol      string   `xml:"tol,attr"`
-	Dur      string   `xml:"dur,attr"`
-	Title    string   `xml:"title"`
-	SubTitle string   `xml:"sub_title"`
-	Pfm      string   `xml:"pfm"`
-	Desc     string   `xml:"desc"`
-	Info     string   `xml:"info"`
-	URL      string   `xml:"url"`
+	XMLName  xml.Name `xml:"prog" json:"-"`
+	Ft       string   `xml:"ft,attr" json:"ft"`
+	To       string   `xml:"to,attr" json:"to"`
+	Ftl      string   `xml:"ftl,attr" json:"ftl"`
+	Tol      string   `xml:"tol,attr" json:"tol"`
+	Dur      string   `xml:"dur,attr" json:"dur"`
+	Title    string   `xml:"title" json:"title"`
+	SubTitle string   `xml:"sub_title" json:"sub_title,omitempty"`
+	Pfm      string   `xml:"pfm" json:"pfm,omitempty"`
+	Desc     string   `xml:"desc" json:"desc,omitempty"`
+	Info     string   `xml:"info" json:"info,omitempty"`
+	URL      string   `xml:"url" json:"url,omitempty"`
 }
 
-// GetProgramGuide fetches the program guide for a given station.
-func GetProgramGuide(stationID string) ([]byte, error) {
-	url := fmt.Sprintf("http://radiko.jp/v3/program/station/weekly/%s.xml", stationID)
-	resp, err := http.Get(url)
+// guideTimeout limits a request for a program guide or the station list, so a stalled
+// connection cannot hold up a job that only wanted the program's title.
+const guideTimeout = 30 * time.Second
+
+// GetProgramGuide fetches the program guide for a given station with http.DefaultClient.
+func GetProgramGuide(ctx context.Context, stationID string) ([]byte, error) {
+	return FetchProgramGuide(ctx, http.DefaultClient, stationID)
+}
+
+// FetchProgramGuide fetches the program guide for a given station with client, e.g. one
+// with its own proxy or a test server's transport.
+func FetchProgramGuide(ctx context.Context, client *http.Client, stationID string) ([]byte, error) {
+	body, err := fetchGuideXML(ctx, client, fmt.Sprintf("https://radiko.jp/v3/program/station/weekly/%s.xml", stationID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get program guide: %w", err)
 	}
-	defer resp.Body.Close()
+	return body, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get program guide: status code %d", resp.StatusCode)
+// fetchGuideXML fetches url with client and returns the body, giving up after guideTimeout.
+func fetchGuideXML(ctx context.Context, client httpDoer, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, guideTimeout)
+	defer cancel()
+	body, err := httpGet(ctx, client, url)
+	if err != nil {
+		return nil, err
 	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
 
-	body, err := io.ReadAll(resp.Body)
+// FindProgramTitle finds a program title by start time and day of week from the program guide XML.
+func FindProgramTitle(programData []byte, targetTime, targetDayOfWeek string) (string, error) {
+	prog, err := findProgramByStart(programData, targetTime, targetDayOfWeek)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read program guide: %w", err)
+		return "", err
 	}
+	return prog.Title, nil
+}
 
-	return body, nil
+// FindProgram returns the program on air at at in the program guide XML, the one with
+// ft <= at < to, so a schedule's start time a few minutes off the official slot still finds it.
+// A program starting exactly at at wins over one still running, should the guide overlap.
+func FindProgram(programData []byte, at time.Time) (Prog, error) {
+	var radiko Radiko
+	if err := xml.Unmarshal(programData, &radiko); err != nil {
+		return Prog{}, fmt.Errorf("failed to unmarshal program guide: %w", err)
+	}
+
+	var (
+		found Prog
+		ok    bool
+	)
+	for _, station := range radiko.Stations.Station {
+		for _, prog := range station.Progs.Prog {
+			start, err := time.ParseInLocation("20060102150405", prog.Ft, JST)
+			if err != nil {
+				continue
+			}
+			end, err := time.ParseInLocation("20060102150405", prog.To, JST)
+			if err != nil || !end.After(start) {
+				end = start.Add(time.Second) // Without a usable end, only the exact start matches.
+			}
+			if start.After(at) || !end.After(at) {
+				continue
+			}
+			if start.Equal(at) {
+				return prog, nil
+			}
+			if !ok {
+				found, ok = prog, true
+			}
+		}
+	}
+	if !ok {
+		return Prog{}, fmt.Errorf("no program on air at %s", at.In(JST).Format("2006-01-02 15:04"))
+	}
+	return found, nil
 }
 
-// FindProgramTitle finds a program title by start time and day of week from the program guide XML.
-func FindProgramTitle(programData []byte, targetTime, targetDayOfWeek string) (string, error) {
+// findShiftedProgram finds the program titled like name that starts closest to slot, no more
+// than window before or after it, in the program guide XML. It returns the program and its start.
+// Titles match when either contains the other, ignoring case.
+func findShiftedProgram(programData []byte, name string, slot time.Time, window time.Duration) (Prog, time.Time, bool) {
+	var radiko Radiko
+	if window <= 0 || name == "" || xml.Unmarshal(programData, &radiko) != nil {
+		return Prog{}, time.Time{}, false
+	}
+	name = strings.ToLower(name)
+
+	var (
+		best      Prog
+		bestStart time.Time
+		bestDist  time.Duration = -1
+	)
+	for _, station := range radiko.Stations.Station {
+		for _, prog := range station.Progs.Prog {
+			start, err := time.ParseInLocation("20060102150405", prog.Ft, JST)
+			if err != nil {
+				continue
+			}
+			dist := start.Sub(slot)
+			if dist < 0 {
+				dist = -dist
+			}
+			if dist > window || (bestDist >= 0 && dist >= bestDist) {
+				continue
+			}
+			title := strings.ToLower(prog.Title)
+			if title == "" || !(strings.Contains(title, name) || strings.Contains(name, title)) {
+				continue
+			}
+			best, bestStart, bestDist = prog, start, dist
+		}
+	}
+	return best, bestStart, bestDist >= 0
+}
+
+// findProgramByStart finds the program starting at targetTime on targetDayOfWeek in the program guide XML.
+func findProgramByStart(programData []byte, targetTime, targetDayOfWeek string) (Prog, error) {
 	var radiko Radiko
 	if err := xml.Unmarshal(programData, &radiko); err != nil {
-		return "", fmt.Errorf("failed to unmarshal program guide: %w", err)
+		return Prog{}, fmt.Errorf("failed to unmarshal program guide: %w", err)
 	}
 
 	jst, err := time.LoadLocation("Asia/Tokyo")
 	if err != nil {
-		return "", fmt.Errorf("failed to load timezone: %w", err)
+		return Prog{}, fmt.Errorf("failed to load timezone: %w", err)
 	}
 
 	for _, station := range radiko.Stations.Station {
@@ -105,10 +204,10 @@ func FindProgramTitle(programData []byte, targetTime, targetDayOfWeek string) (s
 			}
 
 			if progStartTime == trimmedTargetTime && strings.EqualFold(progDayOfWeek, targetDayOfWeek) {
-				return prog.Title, nil
+				return prog, nil
 			}
 		}
 	}
 
-	return "", fmt.Errorf("program not found for time %s on %s", targetTime, targetDayOfWeek)
+	return Prog{}, fmt.Errorf("program not found for time %s on %s", targetTime, targetDayOfWeek)
 }
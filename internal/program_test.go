@@ -1,7 +1,18 @@
 package internal
 
 import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFindProgramTitle(t *testing.T) {
@@ -85,3 +96,151 @@ func TestFindProgramTitle(t *testing.T) {
 		})
 	}
 }
+
+// shiftedGuide has the Monday 22:00 show moved to 22:30 by a baseball extension.
+var shiftedGuide = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<radiko>
+  <stations>
+    <station id="LFR">
+      <progs>
+        <prog ft="20260112180000" to="20260112223000"><title>ショウアップナイター</title></prog>
+        <prog ft="20260112223000" to="20260113000000"><title>Night Show 第12回</title></prog>
+        <prog ft="20260113220000" to="20260113233000"><title>Night Show Late</title></prog>
+      </progs>
+    </station>
+  </stations>
+</radiko>`)
+
+func TestFindProgram(t *testing.T) {
+	overlapping := []byte(`<radiko><stations><station id="LFR"><progs>
+		<prog ft="20260112230000" to="20260113010000"><title>Long Special</title></prog>
+		<prog ft="20260113000000" to="20260113010000"><title>Midnight News</title></prog>
+		<prog ft="20260113010000" to="bad"><title>No End</title></prog>
+	</progs></station></stations></radiko>`)
+	tests := []struct {
+		name    string
+		guide   []byte
+		at      time.Time
+		want    string // Empty when nothing should be found.
+		wantErr bool
+	}{
+		{name: "exact start", guide: shiftedGuide, at: time.Date(2026, time.January, 12, 22, 30, 0, 0, JST), want: "Night Show 第12回"},
+		{name: "a few minutes late", guide: shiftedGuide, at: time.Date(2026, time.January, 12, 22, 33, 0, 0, JST), want: "Night Show 第12回"},
+		{name: "end is exclusive", guide: shiftedGuide, at: time.Date(2026, time.January, 13, 0, 0, 0, 0, JST), wantErr: true},
+		{name: "start wins over a running program", guide: overlapping, at: time.Date(2026, time.January, 13, 0, 0, 0, 0, JST), want: "Midnight News"},
+		{name: "running program", guide: overlapping, at: time.Date(2026, time.January, 13, 0, 30, 0, 0, JST), want: "Long Special"},
+		{name: "no end, exact start only", guide: overlapping, at: time.Date(2026, time.January, 13, 1, 0, 0, 0, JST), want: "No End"},
+		{name: "invalid guide", guide: []byte("<radiko"), at: time.Now(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := FindProgram(tt.guide, tt.at)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindProgram() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if prog.Title != tt.want {
+				t.Errorf("FindProgram() = %q, want %q", prog.Title, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindShiftedProgram(t *testing.T) {
+	slot := time.Date(2026, time.January, 12, 22, 0, 0, 0, JST)
+	tests := []struct {
+		name      string
+		title     string
+		window    time.Duration
+		wantStart string // Empty when nothing should be found.
+	}{
+		{name: "Moved within the window", title: "night show", window: time.Hour, wantStart: "20260112223000"},
+		{name: "Moved beyond the window", title: "Night Show", window: 20 * time.Minute},
+		{name: "Title does not match", title: "Other Show", window: time.Hour},
+		{name: "Disabled", title: "Night Show"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, start, ok := findShiftedProgram(shiftedGuide, tt.title, slot, tt.window)
+			if tt.wantStart == "" {
+				if ok {
+					t.Errorf("expected no match, got %s at %v", prog.Title, start)
+				}
+				return
+			}
+			if !ok || prog.Ft != tt.wantStart || start.Format("20060102150405") != tt.wantStart {
+				t.Errorf("expected the program at %s, got %v (%+v)", tt.wantStart, start, prog)
+			}
+		})
+	}
+}
+
+func TestExecuteJobFollowsShiftedProgram(t *testing.T) {
+	outputDir := t.TempDir()
+	entry := ScheduleEntry{ProgramName: "Night Show", DayOfWeek: "月", StartTime: "220000", StationID: "LFR", ShiftWindow: 60}
+	var resolved time.Time
+	client := &MockRadikoClient{
+		ResolvePlaylistFn: func(ctx context.Context, stationID string, start time.Time) (string, error) {
+			resolved = start
+			return "http://mock.m3u8/playlist.m3u8", nil
+		},
+	}
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		Logger:     log.New(io.Discard, "", 0),
+		FetchGuide: func(context.Context, string) ([]byte, error) { return shiftedGuide, nil },
+
+		DisableProgressBar: true,
+	}
+
+	slot := time.Date(2026, time.January, 12, 22, 0, 0, 0, JST)
+	if _, err := ExecuteJob(context.Background(), client, entry, slot, opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+	if want := slot.Add(30 * time.Minute); !resolved.Equal(want) {
+		t.Errorf("expected the broadcast at %v to be recorded, got %v", want, resolved)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "20260112223000-LFR-Night Show 第12回.aac")); err != nil {
+		t.Errorf("expected the recording to be named after the shifted broadcast: %v", err)
+	}
+}
+
+// rewriteTransport sends every request to target, keeping the path.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestFetchProgramGuide(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/program/station/weekly/TBS.xml":
+			io.WriteString(w, "<radiko/>")
+		case "/v3/program/station/weekly/SLOW.xml":
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: rewriteTransport{target: target}}
+
+	data, err := FetchProgramGuide(context.Background(), client, "TBS")
+	if err != nil || string(data) != "<radiko/>" {
+		t.Errorf("FetchProgramGuide = (%q, %v), want <radiko/>", data, err)
+	}
+	if _, err := FetchProgramGuide(context.Background(), client, "NONE"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected status error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := FetchProgramGuide(ctx, client, "SLOW"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the request to end with the context, got %v", err)
+	}
+}
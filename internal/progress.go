@@ -0,0 +1,193 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Progress receives download progress updates from bulkDownload.
+type Progress interface {
+	// Update is called after each chunk with the cumulative totals.
+	Update(u ProgressUpdate)
+	// Done is called once when downloading stops, successfully or not.
+	Done()
+}
+
+// ProgressUpdate holds the cumulative totals of a download.
+// AudioDone and AudioTotal are in broadcast time, from the playlist's EXTINF durations;
+// they are zero when the provider does not report durations.
+type ProgressUpdate struct {
+	ChunksDone, ChunksTotal int
+	BytesDone               int64
+	AudioDone, AudioTotal   time.Duration
+}
+
+// fraction returns how much of the download is done, by broadcast time when known and by chunks otherwise.
+func (u ProgressUpdate) fraction() float64 {
+	if u.AudioTotal > 0 {
+		return float64(u.AudioDone) / float64(u.AudioTotal)
+	}
+	if u.ChunksTotal > 0 {
+		return float64(u.ChunksDone) / float64(u.ChunksTotal)
+	}
+	return 0
+}
+
+// position formats how far the download got, e.g. "0:30:00/2:00:00 (120/480 chunks)" or "120/480 chunks".
+func (u ProgressUpdate) position(out Output) string {
+	chunks := fmt.Sprintf(out.Msg("%d/%d chunks"), u.ChunksDone, u.ChunksTotal)
+	if u.AudioTotal <= 0 {
+		return chunks
+	}
+	return fmt.Sprintf("%s/%s (%s)", formatClock(u.AudioDone), formatClock(u.AudioTotal), chunks)
+}
+
+// observedProgress passes every update to observe as well as to the Progress it wraps.
+type observedProgress struct {
+	Progress
+	observe func(ProgressUpdate)
+}
+
+func (p observedProgress) Update(u ProgressUpdate) {
+	p.Progress.Update(u)
+	p.observe(u)
+}
+
+// NewProgress returns a progress bar when w is a terminal, or a Progress that
+// writes periodic log lines to logger otherwise (cron, systemd, redirected output).
+func NewProgress(w io.Writer, logger *log.Logger, out Output) Progress {
+	if f, ok := w.(*os.File); ok && isTerminal(f) {
+		return newBarProgress(w, out)
+	}
+	return newLogProgress(logger, 30*time.Second, out)
+}
+
+// isTerminal reports whether f is attached to a character device such as a TTY.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressStats derives throughput and ETA from the cumulative totals.
+type progressStats struct {
+	started time.Time
+	now     func() time.Time
+}
+
+// rates returns the throughput in bytes per second and the estimated time remaining.
+func (s progressStats) rates(u ProgressUpdate) (float64, time.Duration) {
+	elapsed := s.now().Sub(s.started)
+	done := u.fraction()
+	if elapsed <= 0 || done <= 0 {
+		return 0, 0
+	}
+	throughput := float64(u.BytesDone) / elapsed.Seconds()
+	eta := time.Duration(float64(elapsed) / done * (1 - done))
+	return throughput, eta.Round(time.Second)
+}
+
+// Charsets of the progress bar (Config.ProgressCharset).
+const (
+	CharsetASCII   = "ascii"   // [#########.....] (default)
+	CharsetUnicode = "unicode" // [█████████░░░░░]
+)
+
+// barCharsets holds the filled and empty cell of the progress bar in each charset.
+var barCharsets = map[string][2]string{
+	CharsetASCII:   {"#", "."},
+	CharsetUnicode: {"█", "░"},
+}
+
+// checkProgressCharset validates the progress_charset setting.
+func checkProgressCharset(charset string) error {
+	if _, ok := barCharsets[charset]; !ok && charset != "" {
+		return fmt.Errorf("unknown progress charset '%s': must be %s or %s", charset, CharsetASCII, CharsetUnicode)
+	}
+	return nil
+}
+
+// barProgress redraws a single-line progress bar in place using carriage returns.
+type barProgress struct {
+	w     io.Writer
+	out   Output
+	stats progressStats
+	width int
+	cells [2]string // Filled and empty cell.
+}
+
+func newBarProgress(w io.Writer, out Output) *barProgress {
+	cells, ok := barCharsets[out.Charset]
+	if !ok {
+		cells = barCharsets[CharsetASCII]
+	}
+	return &barProgress{w: w, out: out, stats: progressStats{started: time.Now(), now: time.Now}, width: 30, cells: cells}
+}
+
+func (p *barProgress) Update(u ProgressUpdate) {
+	fmt.Fprintf(p.w, "\r%s", p.render(u))
+}
+
+func (p *barProgress) Done() {
+	fmt.Fprintln(p.w)
+}
+
+// render formats e.g. "[#########.....] 0:30:00/1:30:00 (360/1080 chunks)  14.2 MB  512.0 KB/s  ETA 2m30s".
+func (p *barProgress) render(u ProgressUpdate) string {
+	filled := min(int(float64(p.width)*u.fraction()), p.width)
+	bar := strings.Repeat(p.cells[0], filled) + strings.Repeat(p.cells[1], p.width-filled)
+	throughput, eta := p.stats.rates(u)
+	return fmt.Sprintf(p.out.Msg("[%s] %s  %s  %s/s  ETA %s"), bar, u.position(p.out), formatBytes(u.BytesDone), formatBytes(int64(throughput)), eta)
+}
+
+// logProgress writes a log line at most once per interval, plus one for the final chunk.
+type logProgress struct {
+	logger   *log.Logger
+	out      Output
+	interval time.Duration
+	stats    progressStats
+	lastLog  time.Time
+}
+
+func newLogProgress(logger *log.Logger, interval time.Duration, out Output) *logProgress {
+	now := time.Now()
+	return &logProgress{logger: logger, out: out, interval: interval, stats: progressStats{started: now, now: time.Now}, lastLog: now}
+}
+
+func (p *logProgress) Update(u ProgressUpdate) {
+	now := p.stats.now()
+	if u.ChunksDone < u.ChunksTotal && now.Sub(p.lastLog) < p.interval {
+		return
+	}
+	p.lastLog = now
+	throughput, eta := p.stats.rates(u)
+	p.logger.Printf(p.out.Msg("INFO: Downloaded %s (%s, %s/s, ETA %s)"), u.position(p.out), formatBytes(u.BytesDone), formatBytes(int64(throughput)), eta)
+}
+
+func (p *logProgress) Done() {}
+
+// formatClock formats d as h:mm:ss, e.g. "1:05:30".
+func formatClock(d time.Duration) string {
+	s := int64(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// formatBytes formats n using binary units, e.g. "1.5 MB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
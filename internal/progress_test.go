@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBarProgressRender(t *testing.T) {
+	start := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)
+	p := &barProgress{
+		width: 10,
+		stats: progressStats{started: start, now: func() time.Time { return start.Add(10 * time.Second) }},
+		cells: barCharsets[CharsetASCII],
+	}
+
+	tests := []struct {
+		name   string
+		update ProgressUpdate
+		want   string
+	}{
+		{
+			name:   "Chunk count",
+			update: ProgressUpdate{ChunksDone: 25, ChunksTotal: 100, BytesDone: 5 * 1024 * 1024},
+			want:   "[##........] 25/100 chunks  5.0 MB  512.0 KB/s  ETA 30s",
+		},
+		{
+			// Long first chunks: half the broadcast is done after a fifth of the chunks.
+			name:   "Broadcast time",
+			update: ProgressUpdate{ChunksDone: 20, ChunksTotal: 100, BytesDone: 5 * 1024 * 1024, AudioDone: 30 * time.Minute, AudioTotal: time.Hour},
+			want:   "[#####.....] 0:30:00/1:00:00 (20/100 chunks)  5.0 MB  512.0 KB/s  ETA 10s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.render(tt.update); got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBarProgressCharsetAndLanguage(t *testing.T) {
+	start := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)
+	p := newBarProgress(&bytes.Buffer{}, Output{Lang: LangJapanese, Charset: CharsetUnicode})
+	p.width = 10
+	p.stats = progressStats{started: start, now: func() time.Time { return start.Add(10 * time.Second) }}
+	got := p.render(ProgressUpdate{ChunksDone: 25, ChunksTotal: 100, BytesDone: 5 * 1024 * 1024})
+	want := "[██░░░░░░░░] 25/100 チャンク  5.0 MB  512.0 KB/秒  残り 30s"
+	if got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+
+	// Another output in the same process keeps its own charset and language.
+	plain := newBarProgress(&bytes.Buffer{}, Output{})
+	plain.width = 10
+	plain.stats = p.stats
+	if got, want := plain.render(ProgressUpdate{ChunksDone: 25, ChunksTotal: 100, BytesDone: 5 * 1024 * 1024}), "[##........] 25/100 chunks  5.0 MB  512.0 KB/s  ETA 30s"; got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+
+	if err := checkProgressCharset("emoji"); err == nil {
+		t.Error("checkProgressCharset(\"emoji\") succeeded, want an error")
+	}
+	if err := checkLanguage("fr"); err == nil {
+		t.Error("checkLanguage(\"fr\") succeeded, want an error")
+	}
+}
+
+func TestLogProgressThrottles(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)
+	p := &logProgress{
+		logger:   log.New(&buf, "", 0),
+		interval: 30 * time.Second,
+		stats:    progressStats{started: now, now: func() time.Time { return now }},
+		lastLog:  now,
+	}
+
+	p.Update(ProgressUpdate{ChunksDone: 1, ChunksTotal: 4, BytesDone: 100}) // Within the interval: suppressed
+	now = now.Add(31 * time.Second)
+	p.Update(ProgressUpdate{ChunksDone: 2, ChunksTotal: 4, BytesDone: 200}) // Interval elapsed: logged
+	p.Update(ProgressUpdate{ChunksDone: 3, ChunksTotal: 4, BytesDone: 300}) // Suppressed again
+	p.Update(ProgressUpdate{ChunksDone: 4, ChunksTotal: 4, BytesDone: 400}) // Final chunk: always logged
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %d:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], "Downloaded 2/4 chunks") || !strings.Contains(lines[1], "Downloaded 4/4 chunks") {
+		t.Errorf("unexpected log lines:\n%s", buf.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:                      "0 B",
+		1023:                   "1023 B",
+		1536:                   "1.5 KB",
+		300 * 1024 * 1024:      "300.0 MB",
+		3 * 1024 * 1024 * 1024: "3.0 GB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Provider is a streaming service recordings are made from, such as radiko or NHK らじる★らじる.
+// ExecuteJob drives a recording through these four steps only, so a new service is added
+// by implementing Provider and registering it with RegisterProvider.
+type Provider interface {
+	// Authenticate prepares the provider for the other calls, e.g. by fetching an auth token.
+	Authenticate(ctx context.Context) error
+	// ResolvePlaylist returns the playlist URI of the broadcast starting at start on stationID.
+	ResolvePlaylist(ctx context.Context, stationID string, start time.Time) (string, error)
+	// ListChunks returns the URLs of the audio chunks listed by the playlist at uri, in order.
+	ListChunks(ctx context.Context, uri string) ([]string, error)
+	// Download returns the contents of one chunk as ADTS audio.
+	Download(ctx context.Context, chunkURL string) (io.ReadCloser, error)
+}
+
+// RadikoClient is the former name of Provider.
+//
+// Deprecated: Use Provider.
+type RadikoClient = Provider
+
+// Chunk is one audio chunk of a playlist. Duration is the chunk's EXTINF duration,
+// or zero when it is unknown.
+type Chunk struct {
+	URL      string
+	Duration time.Duration
+}
+
+// TimedChunkLister is implemented by providers that can report the duration of each chunk,
+// so progress is measured in broadcast time rather than in chunks.
+type TimedChunkLister interface {
+	ListTimedChunks(ctx context.Context, uri string) ([]Chunk, error)
+}
+
+// RangeDownloader is implemented by providers whose chunks can be downloaded from an offset,
+// so a chunk download interrupted mid-transfer resumes instead of starting over. Providers that
+// transform the chunks they download, e.g. by decrypting them, must not implement it.
+type RangeDownloader interface {
+	// DownloadFrom returns the bytes of chunkURL from offset on. resumed is false when the
+	// server ignored the range and the body is the whole chunk.
+	DownloadFrom(ctx context.Context, chunkURL string, offset int64) (body io.ReadCloser, resumed bool, err error)
+}
+
+// listChunks lists the chunks of the playlist at uri, with durations when the provider reports them.
+func listChunks(ctx context.Context, p Provider, uri string) ([]Chunk, error) {
+	if lister, ok := p.(TimedChunkLister); ok {
+		return lister.ListTimedChunks(ctx, uri)
+	}
+	urls, err := p.ListChunks(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]Chunk, len(urls))
+	for i, u := range urls {
+		chunks[i] = Chunk{URL: u}
+	}
+	return chunks, nil
+}
+
+// chunksDuration returns the total duration of chunks, or zero if any duration is unknown.
+func chunksDuration(chunks []Chunk) time.Duration {
+	var total time.Duration
+	for _, c := range chunks {
+		if c.Duration <= 0 {
+			return 0
+		}
+		total += c.Duration
+	}
+	return total
+}
+
+// chunkURLs returns the URLs of chunks.
+func chunkURLs(chunks []Chunk) []string {
+	urls := make([]string, len(chunks))
+	for i, c := range chunks {
+		urls[i] = c.URL
+	}
+	return urls
+}
+
+// RangeResolver is implemented by providers that can resolve the playlist of an
+// arbitrary time window, rather than only of the program starting at a given time.
+type RangeResolver interface {
+	ResolveRangePlaylist(ctx context.Context, stationID string, from, to time.Time) (string, error)
+}
+
+// GuideAware is implemented by providers whose stations may not be listed in the radiko program guide.
+// When UsesRadikoGuide returns false, recordings are named after the schedule entry instead.
+type GuideAware interface {
+	UsesRadikoGuide() bool
+}
+
+// usesRadikoGuide reports whether the titles of p's programs are looked up in the radiko guide.
+func usesRadikoGuide(p Provider) bool {
+	if g, ok := p.(GuideAware); ok {
+		return g.UsesRadikoGuide()
+	}
+	return true
+}
+
+// resolveRangePlaylist resolves the playlist for the window from-to if the provider supports it.
+func resolveRangePlaylist(ctx context.Context, p Provider, stationID string, from, to time.Time) (string, error) {
+	ranger, ok := p.(RangeResolver)
+	if !ok {
+		return "", fmt.Errorf("the provider does not support recording arbitrary time ranges")
+	}
+	return ranger.ResolveRangePlaylist(ctx, stationID, from, to)
+}
+
+// StreamConfig holds the settings of the built-in providers' stream requests, which the
+// Options creating a provider pass on to it.
+type StreamConfig struct {
+	// Quality selects the variant recorded from master playlists offering several:
+	// QualityHighest, QualityLowest, a bitrate in kbps such as "48" for the variant closest
+	// to it, or "" for the first one listed.
+	Quality string
+	// Headers are added to the playlist and chunk requests of the provider.
+	Headers http.Header
+}
+
+// streamConfigurer is implemented by the built-in providers, which take the StreamConfig of
+// the Options that created them before their first request.
+type streamConfigurer interface {
+	configureStream(cfg StreamConfig)
+}
+
+// ProviderFactory creates the Provider used for one job.
+type ProviderFactory func(ctx context.Context) (Provider, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]ProviderFactory)
+)
+
+// RegisterProvider makes a provider available under name, the value of a schedule entry's
+// provider field. Registering a name again replaces the earlier factory.
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = factory
+}
+
+// LookupProvider returns the factory registered under name.
+func LookupProvider(name string) (ProviderFactory, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	factory, ok := providers[name]
+	return factory, ok
+}
+
+// ProviderNames returns the names of the registered providers, sorted.
+func ProviderNames() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// httpDoer is the part of *http.Client used to download chunks.
+type httpDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// httpGetFrom fetches url from offset on with a Range request. resumed reports a 206 Partial
+// Content response; a server that does not support ranges answers 200 with the whole body.
+func httpGetFrom(ctx context.Context, client httpDoer, url string, offset int64) (body io.ReadCloser, resumed bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, true, nil
+	case http.StatusOK:
+		return resp.Body, false, nil
+	}
+	resp.Body.Close()
+	return nil, false, fmt.Errorf("HTTP status %d", resp.StatusCode)
+}
+
+// httpGet fetches url with client and returns the body of a 200 response.
+func httpGet(ctx context.Context, client httpDoer, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
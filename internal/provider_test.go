@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProviderRegistry(t *testing.T) {
+	for _, name := range []string{ProviderRadiko, ProviderRadiru} {
+		if _, ok := LookupProvider(name); !ok {
+			t.Errorf("expected %s to be registered", name)
+		}
+	}
+
+	custom := &MockRadikoClient{}
+	RegisterProvider("community-test", func(ctx context.Context) (Provider, error) { return custom, nil })
+	defer func() {
+		providersMu.Lock()
+		delete(providers, "community-test")
+		providersMu.Unlock()
+	}()
+
+	names := ProviderNames()
+	if want := []string{"community-test", ProviderRadiko, ProviderRadiru}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected providers %v, got %v", want, names)
+	}
+
+	override := &MockRadikoClient{}
+	tests := []struct {
+		name     string
+		opts     Options
+		provider string
+		want     Provider
+		err      string
+	}{
+		{name: "Registered provider", provider: "community-test", want: custom},
+		{
+			name:     "Options override the registry",
+			opts:     Options{Providers: map[string]ProviderFactory{"community-test": func(ctx context.Context) (Provider, error) { return override, nil }}},
+			provider: "community-test",
+			want:     override,
+		},
+		{
+			name: "NewProvider overrides radiko",
+			opts: Options{NewProvider: func(ctx context.Context) (Provider, error) { return override, nil }},
+			want: override,
+		},
+		{name: "Unknown provider", provider: "radikoo", err: "unknown provider 'radikoo'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.newClient(context.Background(), ScheduleEntry{Provider: tt.provider})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %p, got %p", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statuses of jobs in the JobQueue.
+const (
+	QueuePending = "pending" // Dispatched but not finished, e.g. when the daemon was stopped mid-pass.
+	QueueFailed  = "failed"  // Retried from RetryAt on until it succeeds or leaves the timeshift window.
+	QueueGaveUp  = "gave_up" // Failed RetryConfig.MaxAttempts times; kept, but not retried, until it leaves the window.
+)
+
+// Defaults of RetryConfig.
+const (
+	DefaultRetryDelay    = 15 * time.Minute
+	DefaultMaxRetryDelay = 4 * time.Hour
+)
+
+// RetryConfig paces the retries of failed jobs by the daemon (config "retry"). The wait after a
+// failure starts at DelayMinutes and doubles with each further failure, up to MaxDelayMinutes.
+type RetryConfig struct {
+	DelayMinutes    int `json:"delay_minutes"`     // 0 means DefaultRetryDelay.
+	MaxDelayMinutes int `json:"max_delay_minutes"` // 0 means DefaultMaxRetryDelay.
+	MaxAttempts     int `json:"max_attempts"`      // Give up after this many failures; 0 retries until the broadcast leaves the timeshift window.
+}
+
+// delay returns the wait before retrying a job that failed attempts times.
+func (c RetryConfig) delay(attempts int) time.Duration {
+	delay, limit := DefaultRetryDelay, DefaultMaxRetryDelay
+	if c.DelayMinutes > 0 {
+		delay = time.Duration(c.DelayMinutes) * time.Minute
+	}
+	if c.MaxDelayMinutes > 0 {
+		limit = time.Duration(c.MaxDelayMinutes) * time.Minute
+	}
+	for ; attempts > 1 && delay < limit; attempts-- {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// QueuedJob is a recording the daemon has dispatched and not yet finished successfully.
+type QueuedJob struct {
+	Entry     ScheduleEntry `json:"entry"`
+	Start     time.Time     `json:"start"`               // Broadcast start time
+	Scheduled bool          `json:"scheduled,omitempty"` // Backed by a schedule entry rather than a subscription
+	Status    string        `json:"status"`
+	Attempts  int           `json:"attempts,omitempty"` // Failed attempts so far
+	Error     string        `json:"error,omitempty"`    // Error of the last failed attempt
+	RetryAt   time.Time     `json:"retry_at,omitzero"`  // When a failed job is due to be retried
+}
+
+// held reports whether the job must not be dispatched at now: it gave up, or its retry is not due yet.
+func (j QueuedJob) held(now time.Time) bool {
+	return j.Status == QueueGaveUp || (j.Status == QueueFailed && now.Before(j.RetryAt))
+}
+
+// JobQueue persists the jobs of RunDaemon's passes, so jobs that were pending when the daemon
+// stopped, or that failed, are recorded after a restart as long as the broadcast is still in the
+// timeshift window. The queue is rewritten on every change. A nil *JobQueue keeps nothing.
+type JobQueue struct {
+	path string
+	mu   sync.Mutex
+	jobs map[string]*QueuedJob // By broadcastKey.
+}
+
+// JobQueuePath returns the default location of the job queue of the profile in the XDG data directory.
+func (p Profile) JobQueuePath() (string, error) {
+	dataDir, err := p.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "queue.json"), nil
+}
+
+// OpenJobQueue reads the job queue stored at path. A missing file yields an empty queue.
+func OpenJobQueue(path string) (*JobQueue, error) {
+	q := &JobQueue{path: path, jobs: make(map[string]*QueuedJob)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return q, nil
+		}
+		return nil, fmt.Errorf("failed to read job queue '%s': %w", path, err)
+	}
+	var jobs []*QueuedJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("error parsing job queue '%s': %w", path, err)
+	}
+	for _, job := range jobs {
+		q.jobs[broadcastKey(job.Entry.StationID, job.Start)] = job
+	}
+	return q, nil
+}
+
+// Jobs returns the queued jobs, oldest broadcast first.
+func (q *JobQueue) Jobs() []QueuedJob {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.sorted()
+}
+
+func (q *JobQueue) sorted() []QueuedJob {
+	jobs := make([]QueuedJob, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].Start.Equal(jobs[j].Start) {
+			return jobs[i].Start.Before(jobs[j].Start)
+		}
+		return jobs[i].Entry.StationID < jobs[j].Entry.StationID
+	})
+	return jobs
+}
+
+// prune drops the jobs whose broadcast has left the timeshift window at now, and the scheduled
+// jobs whose entry is no longer in schedule or is disabled. It returns the remaining jobs.
+func (q *JobQueue) prune(now time.Time, schedule []ScheduleEntry) ([]QueuedJob, error) {
+	if q == nil {
+		return nil, nil
+	}
+	keep := make(map[string]bool, len(schedule))
+	for _, entry := range schedule {
+		if entry.IsEnabled() {
+			keep[scheduleKey(entry)] = true
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	changed := false
+	for key, job := range q.jobs {
+		if now.Sub(job.Start) >= TimeshiftWindow || (job.Scheduled && !keep[scheduleKey(job.Entry)]) {
+			delete(q.jobs, key)
+			changed = true
+		}
+	}
+	if changed {
+		if err := q.save(); err != nil {
+			return q.sorted(), err
+		}
+	}
+	return q.sorted(), nil
+}
+
+// job returns the queued job of the broadcast on stationID at start.
+func (q *JobQueue) job(stationID string, start time.Time) (QueuedJob, bool) {
+	if q == nil {
+		return QueuedJob{}, false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[broadcastKey(stationID, start)]
+	if !ok {
+		return QueuedJob{}, false
+	}
+	return *job, true
+}
+
+// nextRetry returns the earliest RetryAt of the failed jobs, or the zero time if there is none.
+func (q *JobQueue) nextRetry() time.Time {
+	if q == nil {
+		return time.Time{}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var next time.Time
+	for _, job := range q.jobs {
+		if job.Status == QueueFailed && (next.IsZero() || job.RetryAt.Before(next)) {
+			next = job.RetryAt
+		}
+	}
+	return next
+}
+
+// add queues the job of entry's broadcast at start as pending, keeping the failures of earlier attempts.
+func (q *JobQueue) add(entry ScheduleEntry, start time.Time, scheduled bool) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := broadcastKey(entry.StationID, start)
+	job, ok := q.jobs[key]
+	if !ok {
+		job = &QueuedJob{Start: start}
+		q.jobs[key] = job
+	}
+	job.Entry = entry
+	job.Scheduled = scheduled
+	job.Status = QueuePending
+	return q.save()
+}
+
+// fail marks the job of the broadcast on stationID at start as failed with err at now, to be
+// retried as retry says. A job failing with ErrStationNotFound is given up right away, since
+// retrying does not bring the station into the area. It returns the job.
+func (q *JobQueue) fail(stationID string, start time.Time, err error, now time.Time, retry RetryConfig) (QueuedJob, error) {
+	if q == nil {
+		return QueuedJob{}, nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[broadcastKey(stationID, start)]
+	if !ok {
+		return QueuedJob{}, nil
+	}
+	job.Attempts++
+	job.Error = err.Error()
+	if errors.Is(err, ErrStationNotFound) || (retry.MaxAttempts > 0 && job.Attempts >= retry.MaxAttempts) {
+		job.Status, job.RetryAt = QueueGaveUp, time.Time{}
+	} else {
+		job.Status, job.RetryAt = QueueFailed, now.Add(retry.delay(job.Attempts))
+	}
+	return *job, q.save()
+}
+
+// done removes the job of the broadcast on stationID at start.
+func (q *JobQueue) done(stationID string, start time.Time) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := broadcastKey(stationID, start)
+	if _, ok := q.jobs[key]; !ok {
+		return nil
+	}
+	delete(q.jobs, key)
+	return q.save()
+}
+
+// save writes the queue to its file atomically. The caller holds q.mu.
+func (q *JobQueue) save() error {
+	data, err := json.MarshalIndent(q.sorted(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode job queue: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		return fmt.Errorf("failed to create job queue directory: %w", err)
+	}
+	if err := writeFileAtomic(q.path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write job queue '%s': %w", q.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "queue.json")
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)
+	kept := ScheduleEntry{ProgramName: "Kept", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}
+	removed := ScheduleEntry{ProgramName: "Removed", DayOfWeek: "月", StartTime: "110000", StationID: "ST1"}
+	special := ScheduleEntry{ProgramName: "Special", StationID: "ST2"}
+
+	q, err := OpenJobQueue(path)
+	if err != nil {
+		t.Fatalf("OpenJobQueue failed: %v", err)
+	}
+	for _, job := range []struct {
+		entry     ScheduleEntry
+		start     time.Time
+		scheduled bool
+	}{
+		{kept, now.Add(-25 * time.Hour), true},
+		{removed, now.Add(-24 * time.Hour), true},
+		{special, now.Add(-8 * 24 * time.Hour), false}, // Left the timeshift window.
+		{special, now.Add(-48 * time.Hour), false},
+	} {
+		if err := q.add(job.entry, job.start, job.scheduled); err != nil {
+			t.Fatalf("add failed: %v", err)
+		}
+	}
+	if _, err := q.fail("ST1", now.Add(-25*time.Hour), fmt.Errorf("timeout"), now, RetryConfig{}); err != nil {
+		t.Fatalf("fail failed: %v", err)
+	}
+
+	// A restarted daemon finds the jobs again.
+	q, err = OpenJobQueue(path)
+	if err != nil {
+		t.Fatalf("OpenJobQueue failed: %v", err)
+	}
+	if n := len(q.Jobs()); n != 4 {
+		t.Fatalf("expected 4 queued jobs, got %d", n)
+	}
+	jobs, err := q.prune(now, []ScheduleEntry{kept})
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs after pruning, got %+v", jobs)
+	}
+	if job := jobs[0]; job.Entry.ProgramName != "Special" || job.Status != QueuePending {
+		t.Errorf("unexpected first job: %+v", job)
+	}
+	if job := jobs[1]; job.Entry.ProgramName != "Kept" || job.Status != QueueFailed || job.Attempts != 1 || job.Error != "timeout" || !job.RetryAt.Equal(now.Add(DefaultRetryDelay)) {
+		t.Errorf("unexpected second job: %+v", job)
+	}
+
+	if err := q.done("ST2", now.Add(-48*time.Hour)); err != nil {
+		t.Fatalf("done failed: %v", err)
+	}
+	if q, err = OpenJobQueue(path); err != nil || len(q.Jobs()) != 1 {
+		t.Errorf("expected 1 job left in the file, got %v (%v)", q.Jobs(), err)
+	}
+
+	// A station radiko does not serve is not retried.
+	if err := q.add(special, now.Add(-24*time.Hour), false); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	job, err := q.fail("ST2", now.Add(-24*time.Hour), fmt.Errorf("resolve: %w", ErrStationNotFound), now, RetryConfig{})
+	if err != nil || job.Status != QueueGaveUp {
+		t.Errorf("expected the job to be given up, got %+v (%v)", job, err)
+	}
+
+	var nilQueue *JobQueue
+	if err := nilQueue.add(kept, now, true); err != nil || nilQueue.Jobs() != nil {
+		t.Errorf("expected a nil queue to keep nothing, got %v", err)
+	}
+}
+
+func TestRunOnceQueue(t *testing.T) {
+	outputDir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)} // Tuesday
+	q, err := OpenJobQueue(filepath.Join(t.TempDir(), "queue.json"))
+	if err != nil {
+		t.Fatalf("OpenJobQueue failed: %v", err)
+	}
+	// A subscription match the daemon was stopped before recording.
+	missed := time.Date(2026, time.January, 10, 22, 0, 0, 0, JST)
+	if err := q.add(ScheduleEntry{ProgramName: "Special", StationID: "ST2"}, missed, false); err != nil {
+		t.Fatal(err)
+	}
+
+	fail := true
+	opts := Options{
+		Schedule:  []ScheduleEntry{{ProgramName: "Weekly", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}},
+		OutputDir: outputDir,
+		Logger:    log.New(&bytes.Buffer{}, "", 0),
+		Clock:     clock,
+		Queue:     q,
+		NewProvider: func(ctx context.Context) (Provider, error) {
+			if fail {
+				return nil, fmt.Errorf("offline")
+			}
+			return &MockRadikoClient{}, nil
+		},
+	}
+
+	if err := RunOnce(context.Background(), opts); err == nil {
+		t.Fatal("expected the offline pass to fail")
+	}
+	jobs := q.Jobs()
+	if len(jobs) != 2 {
+		t.Fatalf("expected both jobs to stay queued, got %+v", jobs)
+	}
+	for _, job := range jobs {
+		if job.Status != QueueFailed || !strings.Contains(job.Error, "offline") {
+			t.Errorf("expected a failed job, got %+v", job)
+		}
+	}
+
+	// The next pass records both, although the subscription no longer matches the special.
+	clock.now = clock.now.Add(24 * time.Hour)
+	fail = false
+	if err := RunOnce(context.Background(), opts); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	for _, name := range []string{"20260112100000-ST1-Weekly.aac", "20260110220000-ST2-Special.aac"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("expected %s to be recorded: %v", name, err)
+		}
+	}
+	if jobs := q.Jobs(); len(jobs) != 0 {
+		t.Errorf("expected an empty queue, got %+v", jobs)
+	}
+}
+
+func TestRetryConfigDelay(t *testing.T) {
+	tests := []struct {
+		config   RetryConfig
+		attempts int
+		want     time.Duration
+	}{
+		{config: RetryConfig{}, attempts: 1, want: DefaultRetryDelay},
+		{config: RetryConfig{}, attempts: 3, want: 4 * DefaultRetryDelay},
+		{config: RetryConfig{}, attempts: 20, want: DefaultMaxRetryDelay},
+		{config: RetryConfig{DelayMinutes: 5, MaxDelayMinutes: 30}, attempts: 2, want: 10 * time.Minute},
+		{config: RetryConfig{DelayMinutes: 5, MaxDelayMinutes: 30}, attempts: 4, want: 30 * time.Minute},
+		{config: RetryConfig{DelayMinutes: 60, MaxDelayMinutes: 30}, attempts: 1, want: 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := tt.config.delay(tt.attempts); got != tt.want {
+			t.Errorf("%+v.delay(%d) = %s, want %s", tt.config, tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRunOnceRetry(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)} // Tuesday
+	q, err := OpenJobQueue(filepath.Join(t.TempDir(), "queue.json"))
+	if err != nil {
+		t.Fatalf("OpenJobQueue failed: %v", err)
+	}
+	attempts := 0
+	opts := Options{
+		Schedule:  []ScheduleEntry{{ProgramName: "Weekly", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}},
+		OutputDir: t.TempDir(),
+		Logger:    log.New(&bytes.Buffer{}, "", 0),
+		Clock:     clock,
+		Queue:     q,
+		Retry:     RetryConfig{DelayMinutes: 30, MaxAttempts: 2},
+		NewProvider: func(ctx context.Context) (Provider, error) {
+			attempts++
+			return nil, fmt.Errorf("offline")
+		},
+	}
+
+	passes := []struct {
+		after        time.Duration
+		wantAttempts int
+		wantStatus   string
+	}{
+		{after: 0, wantAttempts: 1, wantStatus: QueueFailed},
+		{after: 10 * time.Minute, wantAttempts: 1, wantStatus: QueueFailed}, // Not due before 10:30.
+		{after: 25 * time.Minute, wantAttempts: 2, wantStatus: QueueGaveUp},
+		{after: 2 * time.Hour, wantAttempts: 2, wantStatus: QueueGaveUp},
+	}
+	for i, pass := range passes {
+		clock.now = clock.now.Add(pass.after)
+		RunOnce(context.Background(), opts)
+		jobs := q.Jobs()
+		if attempts != pass.wantAttempts || len(jobs) != 1 || jobs[0].Status != pass.wantStatus {
+			t.Fatalf("pass %d: %d attempts, queue %+v; want %d attempts and a job %s", i+1, attempts, jobs, pass.wantAttempts, pass.wantStatus)
+		}
+		if i == 0 {
+			if want := clock.now.Add(30 * time.Minute); !q.nextRetry().Equal(want) {
+				t.Errorf("nextRetry = %s, want %s", q.nextRetry(), want)
+			}
+		}
+	}
+	if next := q.nextRetry(); !next.IsZero() {
+		t.Errorf("expected no retry after giving up, got %s", next)
+	}
+}
@@ -0,0 +1,370 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+
+	goradiko "github.com/yyoshiki41/go-radiko" // Alias to avoid conflict with our internal package name
+)
+
+func init() {
+	RegisterProvider(ProviderRadiko, func(ctx context.Context) (Provider, error) { return NewGoradikoClient("") })
+}
+
+// radikoTokenTTL is how long a radiko auth token is reused by later jobs after it was issued.
+// It is kept short of the token's actual lifetime, so a job never starts with a token about to lapse.
+const radikoTokenTTL = 50 * time.Minute
+
+// goradikoClient is the radiko Provider, backed by go-radiko. It is safe for concurrent use,
+// so one authenticated client can be shared by the jobs of a run.
+type goradikoClient struct {
+	account    string          // Premium account the client is logged in with, if any.
+	smartPhone *smartPhoneAuth // Authorizes area-free tokens for the account; nil uses go-radiko's flow.
+	stream     StreamConfig    // Set by configureStream before the client is shared.
+
+	mu      sync.RWMutex
+	client  *goradiko.Client // Replaced, never modified, once shared.
+	expires time.Time        // When the token from Authenticate stops being reused.
+}
+
+// NewGoradikoClient returns the radiko provider. An empty token is fetched by Authenticate.
+func NewGoradikoClient(token string) (Provider, error) {
+	client, err := goradiko.New(token)
+	if err != nil {
+		return nil, err
+	}
+	return &goradikoClient{client: client}, nil
+}
+
+// NewLoggedInGoradikoClient creates a go-radiko client and logs in with a radiko premium account,
+// so that timeshift playlists of stations outside the current area can be fetched. When
+// creds.AppKeyFile is set, tokens are authorized like the smartphone app does, which is what
+// makes radiko serve out-of-area playlists instead of 403s.
+func NewLoggedInGoradikoClient(ctx context.Context, creds RadikoCredentials) (Provider, error) {
+	g := &goradikoClient{account: creds.Mail}
+	if creds.AppKeyFile != "" {
+		key, err := loadSmartPhoneKey(creds.AppKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		g.smartPhone = &smartPhoneAuth{key: key, baseURL: radikoBaseURL}
+	}
+	client, err := goradiko.New("")
+	if err != nil {
+		return nil, err
+	}
+	status, err := client.Login(ctx, creds.Mail, creds.Password)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errPremiumLogin, err)
+	}
+	if status.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("%w: status code %d", errPremiumLogin, status.StatusCode())
+	}
+	g.client = client
+	return g, nil
+}
+
+// current returns the go-radiko client in use.
+func (g *goradikoClient) current() *goradiko.Client {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.client
+}
+
+// Authenticate authorizes a copy of the go-radiko client, so jobs sharing g keep
+// using the previous token until the new one is in place.
+func (g *goradikoClient) Authenticate(ctx context.Context) error {
+	if g.smartPhone != nil {
+		return g.authenticateSmartPhone(ctx)
+	}
+	client := *g.current()
+	if _, err := client.AuthorizeToken(ctx); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.client, g.expires = &client, time.Now().Add(radikoTokenTTL)
+	return nil
+}
+
+// authenticateSmartPhone authorizes a token with the premium session through the smartphone
+// app's flow and switches to a client sending it.
+func (g *goradikoClient) authenticateSmartPhone(ctx context.Context) error {
+	current := g.current()
+	token, area, err := g.smartPhone.authorize(ctx, current, radikoSession(current.Jar(), g.smartPhone.baseURL))
+	if err != nil {
+		return err
+	}
+	client, err := g.withToken(token)
+	if err != nil {
+		return err
+	}
+	client.SetAreaID(area)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.client, g.expires = client, time.Now().Add(radikoTokenTTL)
+	return nil
+}
+
+// TokenKey separates anonymous tokens from those of each premium account,
+// which allow area-free recording.
+func (g *goradikoClient) TokenKey() string {
+	switch {
+	case g.smartPhone != nil:
+		return ProviderRadiko + ":" + g.account + ":" + radikoSmartPhoneApp
+	case g.account != "":
+		return ProviderRadiko + ":" + g.account
+	}
+	return ProviderRadiko
+}
+
+func (g *goradikoClient) AuthToken() (string, time.Time) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.client.AuthToken(), g.expires
+}
+
+// UseAuthToken replaces the go-radiko client with one sending token.
+func (g *goradikoClient) UseAuthToken(ctx context.Context, token string) error {
+	client, err := g.withToken(token)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.client = client
+	return nil
+}
+
+// withToken returns a go-radiko client sending token, which go-radiko only accepts at
+// construction. The cookies of a premium login are carried over.
+func (g *goradikoClient) withToken(token string) (*goradiko.Client, error) {
+	jar := g.current().Jar()
+	client, err := goradiko.New(token)
+	if err != nil {
+		return nil, err
+	}
+	if jar, ok := jar.(*cookiejar.Jar); ok {
+		client.SetJar(jar)
+	}
+	return client, nil
+}
+
+// configureStream applies the stream settings of the Options creating g, see streamConfigurer.
+func (g *goradikoClient) configureStream(cfg StreamConfig) {
+	g.stream = cfg
+}
+
+// ResolvePlaylist resolves the playlist of the program starting at start on stationID, see
+// resolveProgramPlaylist.
+func (g *goradikoClient) ResolvePlaylist(ctx context.Context, stationID string, start time.Time) (string, error) {
+	return resolveProgramPlaylist(ctx, g.current(), stationID, start, g.stream.Quality)
+}
+
+// radikoAPI is the part of the go-radiko client resolving playlists takes.
+type radikoAPI interface {
+	httpDoer
+	AuthToken() string
+	GetStations(ctx context.Context, date time.Time) (goradiko.Stations, error)
+}
+
+// resolveProgramPlaylist looks the program starting at start on stationID up in the guide and
+// returns the media playlist of the variant quality selects. go-radiko's own
+// TimeshiftPlaylistM3U8 is not used, as it only accepts master playlists with a single variant.
+// When the timeshift API fails, the alternate endpoints in timeshiftPlaylistEndpoints are tried.
+func resolveProgramPlaylist(ctx context.Context, client radikoAPI, stationID string, start time.Time, quality string) (string, error) {
+	stations, err := client.GetStations(ctx, start)
+	if err != nil {
+		return "", err
+	}
+	prog, served := findRadikoProgram(stations, stationID, start)
+	if !served {
+		return "", fmt.Errorf("%w: radiko has no station '%s' in the area of this session", ErrStationNotFound, stationID)
+	}
+	if prog == nil {
+		return "", goradiko.ErrProgramNotFound
+	}
+	from, errFrom := time.ParseInLocation("20060102150405", prog.Ft, JST)
+	to, errTo := time.ParseInLocation("20060102150405", prog.To, JST)
+	if errFrom != nil || errTo != nil {
+		return "", fmt.Errorf("invalid times in the guide entry of '%s': %s-%s", prog.Title, prog.Ft, prog.To)
+	}
+
+	uri, err := requestTimeshiftPlaylist(ctx, client, client.AuthToken(), timeshiftPlaylistEndpoints[0], stationID, from, to, quality)
+	if err == nil || ctx.Err() != nil {
+		return uri, err
+	}
+	uri, fallbackErr := resolveTimeshiftPlaylist(ctx, client, client.AuthToken(), timeshiftPlaylistEndpoints[1:], stationID, from, to, quality)
+	if fallbackErr != nil {
+		return "", fmt.Errorf("%w; alternate playlists failed too: %w", err, fallbackErr)
+	}
+	jobLogger(ctx).Printf("INFO: Resolved the playlist of %s at %s through an alternate endpoint after: %v", stationID, start.In(JST).Format("2006-01-02 15:04"), err)
+	return uri, nil
+}
+
+// findRadikoProgram returns the program starting at start on stationID in stations, and
+// whether stationID is among them at all.
+func findRadikoProgram(stations goradiko.Stations, stationID string, start time.Time) (prog *goradiko.Prog, served bool) {
+	ft := start.In(JST).Format("20060102150405")
+	for _, station := range stations {
+		if station.ID != stationID {
+			continue
+		}
+		for i, p := range station.Progs.Progs {
+			if p.Ft == ft {
+				return &station.Progs.Progs[i], true
+			}
+		}
+		served = true
+	}
+	return nil, served
+}
+
+func (g *goradikoClient) ListChunks(ctx context.Context, uri string) ([]string, error) {
+	chunks, err := g.ListTimedChunks(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	return chunkURLs(chunks), nil
+}
+
+// ListTimedChunks returns the chunks of the media playlist at uri with their EXTINF durations.
+func (g *goradikoClient) ListTimedChunks(ctx context.Context, uri string) ([]Chunk, error) {
+	media, base, err := fetchMediaPlaylist(ctx, g.streamClient(), uri, g.stream.Quality)
+	if err != nil {
+		return nil, err
+	}
+	return mediaChunks(media, base)
+}
+
+func (g *goradikoClient) Download(ctx context.Context, chunkURL string) (io.ReadCloser, error) {
+	return httpGet(ctx, g.streamClient(), chunkURL)
+}
+
+// DownloadFrom resumes the download of a chunk at offset, see RangeDownloader.
+func (g *goradikoClient) DownloadFrom(ctx context.Context, chunkURL string, offset int64) (io.ReadCloser, bool, error) {
+	return httpGetFrom(ctx, g.streamClient(), chunkURL, offset)
+}
+
+// streamClient returns the client of playlist and chunk requests. They carry the auth token,
+// which some chunk CDNs require, and the headers configured for radiko, and always go over HTTPS.
+func (g *goradikoClient) streamClient() httpDoer {
+	client := g.current()
+	header := make(http.Header)
+	if token := client.AuthToken(); token != "" {
+		header.Set("X-Radiko-AuthToken", token)
+	}
+	return httpsDoer{base: withHeaders(client, header, g.stream.Headers)}
+}
+
+// radikoBaseURL is the origin of the radiko API.
+const radikoBaseURL = "https://radiko.jp"
+
+// timeshiftPlaylistURL is the radiko API endpoint returning the master playlist of a timeshift window.
+const timeshiftPlaylistURL = radikoBaseURL + "/v2/api/ts/playlist.m3u8"
+
+// playlistEndpoint is a radiko endpoint creating timeshift playlists, with the chunk length
+// parameter l it is asked for.
+type playlistEndpoint struct {
+	URL string
+	L   string
+	// Stream marks the playlist_create_url endpoints of the stream servers, which take the
+	// window again as start_at/end_at and a listener session ID.
+	Stream bool
+}
+
+// timeshiftPlaylistEndpoints are tried in order until one returns a playlist. The first is the
+// API go-radiko uses; the others are the playlist_create_url variants radiko's own players fall
+// back on, which still answer for some stations when the API errs.
+var timeshiftPlaylistEndpoints = []playlistEndpoint{
+	{URL: timeshiftPlaylistURL, L: "15"},
+	{URL: timeshiftPlaylistURL, L: "300"},
+	{URL: "https://tf-f-rpaa-radiko.smartstream.ne.jp/tf/playlist.m3u8", L: "15", Stream: true},
+	{URL: "https://tf-c-rpaa-radiko.smartstream.ne.jp/tf/playlist.m3u8", L: "15", Stream: true},
+}
+
+// ResolveRangePlaylist returns the media playlist URI of the window from-to on stationID.
+// go-radiko only resolves whole programs, so the request is built here with the same parameters.
+func (g *goradikoClient) ResolveRangePlaylist(ctx context.Context, stationID string, from, to time.Time) (string, error) {
+	client := g.current()
+	return resolveTimeshiftPlaylist(ctx, client, client.AuthToken(), timeshiftPlaylistEndpoints, stationID, from, to, g.stream.Quality)
+}
+
+// resolveTimeshiftPlaylist requests the playlist of the window from-to on stationID from each
+// endpoint in turn, returning the first that succeeds or the errors of all of them.
+func resolveTimeshiftPlaylist(ctx context.Context, client httpDoer, token string, endpoints []playlistEndpoint, stationID string, from, to time.Time, quality string) (string, error) {
+	var errs []error
+	for _, endpoint := range endpoints {
+		uri, err := requestTimeshiftPlaylist(ctx, client, token, endpoint, stationID, from, to, quality)
+		if err == nil {
+			return uri, nil
+		}
+		if ctx.Err() != nil {
+			return "", err
+		}
+		errs = append(errs, fmt.Errorf("%s (l=%s): %w", endpoint.URL, endpoint.L, err))
+	}
+	return "", errors.Join(errs...)
+}
+
+func requestTimeshiftPlaylist(ctx context.Context, client httpDoer, token string, endpoint playlistEndpoint, stationID string, from, to time.Time, quality string) (string, error) {
+	ft, tt := from.In(JST).Format("20060102150405"), to.In(JST).Format("20060102150405")
+	query := url.Values{
+		"station_id": {stationID},
+		"ft":         {ft},
+		"to":         {tt},
+		"l":          {endpoint.L},
+	}
+	method := http.MethodPost
+	if endpoint.Stream {
+		method = http.MethodGet
+		query.Set("start_at", ft)
+		query.Set("end_at", tt)
+		query.Set("lsid", listenerSessionID())
+		query.Set("type", "b")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.URL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Radiko-AuthToken", token)
+	req.Header.Set("pragma", "no-cache")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get timeshift playlist: status code %d", resp.StatusCode)
+	}
+	return masterPlaylistURI(resp.Body, quality)
+}
+
+// listenerSessionID returns a random session ID in the format of radiko's players.
+func listenerSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// masterPlaylistURI returns the URI of the variant of an M3U8 master playlist that quality selects.
+func masterPlaylistURI(r io.Reader, quality string) (string, error) {
+	playlist, err := ParsePlaylist(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse playlist: %w", err)
+	}
+	if !playlist.Master() {
+		return "", fmt.Errorf("invalid m3u8 format")
+	}
+	return selectVariant(playlist, quality).URI, nil
+}
@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Identity of the radiko smartphone app (aSmartPhone7a). Unlike the browser player used by
+// go-radiko, its auth2 carries the premium session, so the token it yields plays the
+// timeshift of stations outside the area the request comes from.
+const (
+	radikoSmartPhoneApp        = "aSmartPhone7a"
+	radikoSmartPhoneAppVersion = "7.5.0"
+	radikoSmartPhoneDevice     = "31.Pixel_5"
+	// radikoSmartPhoneLocation is the GPS position sent to auth2. A premium session is area-free,
+	// so any position in Japan does; this is central Tokyo.
+	radikoSmartPhoneLocation = "35.689488,139.691706,gps"
+)
+
+// radikoSessionCookie is the cookie holding the session of a premium login.
+const radikoSessionCookie = "radiko_session"
+
+// smartPhoneAuth authorizes radiko tokens the way the smartphone app does.
+type smartPhoneAuth struct {
+	key     []byte // The app's auth key, from which auth1 picks the partial key.
+	baseURL string // e.g. "https://radiko.jp"
+}
+
+// loadSmartPhoneKey reads the aSmartPhone7a auth key from path, either as extracted from
+// the app or base64 encoded.
+func loadSmartPhoneKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read radiko app key: %w", err)
+	}
+	if key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data))); err == nil && len(key) > 0 {
+		return key, nil
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("radiko app key '%s' is empty", path)
+	}
+	return data, nil
+}
+
+// authorize runs auth1 and auth2 and returns the enabled token and the area it was issued for.
+// session is the radiko_session of a premium login.
+func (a *smartPhoneAuth) authorize(ctx context.Context, client httpDoer, session string) (token, area string, err error) {
+	user := make([]byte, 16)
+	if _, err := rand.Read(user); err != nil {
+		return "", "", err
+	}
+	header := http.Header{
+		"X-Radiko-App":         {radikoSmartPhoneApp},
+		"X-Radiko-App-Version": {radikoSmartPhoneAppVersion},
+		"X-Radiko-Device":      {radikoSmartPhoneDevice},
+		"X-Radiko-User":        {hex.EncodeToString(user)},
+	}
+
+	resp, err := a.get(ctx, client, "/v2/api/auth1", nil, header)
+	if err != nil {
+		return "", "", fmt.Errorf("auth1 failed: %w", err)
+	}
+	resp.Body.Close()
+	token = resp.Header.Get("X-Radiko-AuthToken")
+	offset, errOffset := strconv.Atoi(resp.Header.Get("X-Radiko-KeyOffset"))
+	length, errLength := strconv.Atoi(resp.Header.Get("X-Radiko-KeyLength"))
+	if token == "" || errOffset != nil || errLength != nil {
+		return "", "", fmt.Errorf("auth1 failed: missing token or key range")
+	}
+	if offset < 0 || length <= 0 || offset+length > len(a.key) {
+		return "", "", fmt.Errorf("auth1 asked for bytes %d-%d of a %d byte app key; check radiko.app_key_file", offset, offset+length, len(a.key))
+	}
+
+	header.Set("X-Radiko-AuthToken", token)
+	header.Set("X-Radiko-Partialkey", base64.StdEncoding.EncodeToString(a.key[offset:offset+length]))
+	header.Set("X-Radiko-Location", radikoSmartPhoneLocation)
+	header.Set("X-Radiko-Connection", "wifi")
+	var query url.Values
+	if session != "" {
+		query = url.Values{radikoSessionCookie: {session}}
+	}
+	resp, err = a.get(ctx, client, "/v2/api/auth2", query, header)
+	if err != nil {
+		return "", "", fmt.Errorf("auth2 failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", "", fmt.Errorf("auth2 failed: %w", err)
+	}
+	area, _, _ = strings.Cut(strings.TrimSpace(string(body)), ",")
+	if !strings.HasPrefix(area, "JP") {
+		return "", "", fmt.Errorf("auth2 failed: unexpected response %q", body)
+	}
+	return token, area, nil
+}
+
+// get sends a GET request for path with header and returns the 200 response.
+func (a *smartPhoneAuth) get(ctx context.Context, client httpDoer, path string, query url.Values, header http.Header) (*http.Response, error) {
+	u := a.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = header.Clone()
+	req.Header.Set("pragma", "no-cache")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// radikoSession returns the radiko_session cookie of a premium login kept in jar, if any.
+func radikoSession(jar http.CookieJar, baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if jar == nil || err != nil {
+		return ""
+	}
+	for _, c := range jar.Cookies(u) {
+		if c.Name == radikoSessionCookie {
+			return c.Value
+		}
+	}
+	return ""
+}
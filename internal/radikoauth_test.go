@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSmartPhoneAuthorize(t *testing.T) {
+	key := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	tests := []struct {
+		name    string
+		offset  string
+		auth2   string
+		session string
+		want    string // Area, or the expected error.
+		wantErr bool
+	}{
+		{"premium", "4", "JP27,大阪府,osaka Japan", "s3ss10n", "JP27", false},
+		{"key range outside the key", "30", "JP27", "", "app key", true},
+		{"rejected", "4", "OUT", "", "unexpected response", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/v2/api/auth1", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Radiko-App") != radikoSmartPhoneApp || len(r.Header.Get("X-Radiko-User")) != 32 {
+					t.Errorf("unexpected auth1 headers %v", r.Header)
+				}
+				w.Header().Set("X-Radiko-AuthToken", "tok")
+				w.Header().Set("X-Radiko-KeyOffset", tt.offset)
+				w.Header().Set("X-Radiko-KeyLength", "8")
+			})
+			mux.HandleFunc("/v2/api/auth2", func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("X-Radiko-Partialkey"); got != base64.StdEncoding.EncodeToString(key[4:12]) {
+					t.Errorf("unexpected partial key %q", got)
+				}
+				if r.Header.Get("X-Radiko-AuthToken") != "tok" || r.Header.Get("X-Radiko-Location") == "" {
+					t.Errorf("unexpected auth2 headers %v", r.Header)
+				}
+				if got := r.URL.Query().Get(radikoSessionCookie); got != tt.session {
+					t.Errorf("radiko_session = %q, want %q", got, tt.session)
+				}
+				fmt.Fprint(w, tt.auth2)
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			auth := &smartPhoneAuth{key: key, baseURL: srv.URL}
+			token, area, err := auth.authorize(context.Background(), srv.Client(), tt.session)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Fatalf("expected an error containing %q, got %v", tt.want, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authorize failed: %v", err)
+			}
+			if token != "tok" || area != tt.want {
+				t.Errorf("got token %q for %q, want tok for %q", token, area, tt.want)
+			}
+		})
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	auth := &smartPhoneAuth{key: key, baseURL: srv.URL}
+	if _, _, err := auth.authorize(context.Background(), srv.Client(), ""); err == nil || !strings.Contains(err.Error(), "auth1 failed: status code 403") {
+		t.Errorf("expected auth1 to fail with 403, got %v", err)
+	}
+}
+
+func TestLoadSmartPhoneKey(t *testing.T) {
+	dir := t.TempDir()
+	raw := []byte{0x00, 0xff, 0x10, 0x80, '\n'}
+	files := map[string][]byte{
+		"raw.bin": raw,
+		"key.b64": []byte(base64.StdEncoding.EncodeToString(raw) + "\n"),
+		"empty":   nil,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"raw.bin", "key.b64"} {
+		if key, err := loadSmartPhoneKey(filepath.Join(dir, name)); err != nil || string(key) != string(raw) {
+			t.Errorf("%s: got %x (%v), want %x", name, key, err, raw)
+		}
+	}
+	for _, name := range []string{"empty", "missing"} {
+		if _, err := loadSmartPhoneKey(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRadikoSession(t *testing.T) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(radikoBaseURL)
+	if got := radikoSession(jar, radikoBaseURL); got != "" {
+		t.Errorf("expected no session before logging in, got %q", got)
+	}
+	jar.SetCookies(u, []*http.Cookie{{Name: "other", Value: "x"}, {Name: radikoSessionCookie, Value: "s3ss10n"}})
+	if got := radikoSession(jar, radikoBaseURL); got != "s3ss10n" {
+		t.Errorf("radikoSession = %q", got)
+	}
+}
@@ -0,0 +1,377 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Providers selectable with the provider field of a schedule entry.
+const (
+	ProviderRadiko = "radiko" // Default.
+	ProviderRadiru = "radiru" // NHK らじる★らじる 聴き逃し (on-demand).
+)
+
+// radiruAPIURL is the base of NHK's らじる★らじる on-demand API.
+const radiruAPIURL = "https://www.nhk.or.jp/radio-api/app/v1/web/ondemand"
+
+func init() {
+	RegisterProvider(ProviderRadiru, func(ctx context.Context) (Provider, error) { return NewRadiruClient(), nil })
+}
+
+// radiruClient is the Provider for NHK らじる★らじる on-demand episodes.
+// Station IDs are NHK's channel names: "r1", "r2" and "fm". Episodes are found by their broadcast
+// start time, so schedule entries work as for radiko. On-demand streams are HLS; encrypted segments
+// are decrypted and MPEG-TS segments are demuxed to ADTS in Download, so chunks look like radiko's.
+type radiruClient struct {
+	http    *http.Client
+	baseURL string
+	stream  StreamConfig // Set by configureStream before the client is used.
+
+	mu       sync.Mutex
+	segments map[string]radiruSegment // Segment URL to its encryption, from ListChunks.
+	keys     map[string][]byte        // AES-128 keys by URI.
+}
+
+// radiruSegment describes how to decrypt one segment. keyURI is empty for clear segments.
+type radiruSegment struct {
+	keyURI string
+	iv     []byte
+}
+
+// NewRadiruClient returns the provider for NHK らじる★らじる on-demand streams.
+func NewRadiruClient() Provider {
+	return newRadiruClient(http.DefaultClient, radiruAPIURL)
+}
+
+func newRadiruClient(client *http.Client, baseURL string) *radiruClient {
+	return &radiruClient{
+		http:     client,
+		baseURL:  baseURL,
+		segments: make(map[string]radiruSegment),
+		keys:     make(map[string][]byte),
+	}
+}
+
+// configureStream applies the stream settings of the Options creating c, see streamConfigurer.
+func (c *radiruClient) configureStream(cfg StreamConfig) {
+	c.stream = cfg
+}
+
+// Authenticate is a no-op: on-demand streams need no authentication.
+func (c *radiruClient) Authenticate(ctx context.Context) error {
+	return nil
+}
+
+// UsesRadikoGuide returns false: NHK's on-demand channels are not in the radiko guide.
+func (c *radiruClient) UsesRadikoGuide() bool {
+	return false
+}
+
+// radiruCorner is a series ("corner") listed by the on-demand API.
+type radiruCorner struct {
+	Title          string `json:"title"`
+	RadioBroadcast string `json:"radio_broadcast"` // Channels it airs on, e.g. "R1" or "R1,FM".
+	SeriesSiteID   string `json:"series_site_id"`
+	CornerSiteID   string `json:"corner_site_id"`
+}
+
+// radiruEpisode is one on-demand episode of a series.
+type radiruEpisode struct {
+	ProgramTitle string `json:"program_title"`
+	OnairDate    string `json:"onair_date"`     // e.g. "2026年1月12日(月)午後10:00放送"
+	AAContentsID string `json:"aa_contents_id"` // Carries the broadcast start as an RFC 3339 time.
+	StreamURL    string `json:"stream_url"`
+}
+
+var (
+	rfc3339JSTPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\+09:00`)
+	onairDatePattern  = regexp.MustCompile(`(\d{4})年(\d{1,2})月(\d{1,2})日.*?(午前|午後)(\d{1,2}):(\d{2})`)
+)
+
+// start returns the broadcast start time of the episode, from aa_contents_id or else onair_date.
+func (e radiruEpisode) start() (time.Time, bool) {
+	if s := rfc3339JSTPattern.FindString(e.AAContentsID); s != "" {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t.In(JST), true
+		}
+	}
+	m := onairDatePattern.FindStringSubmatch(e.OnairDate)
+	if m == nil {
+		return time.Time{}, false
+	}
+	n := make([]int, 0, 5)
+	for _, s := range []string{m[1], m[2], m[3], m[5], m[6]} {
+		v, _ := strconv.Atoi(s)
+		n = append(n, v)
+	}
+	hour := n[3] % 12
+	if m[4] == "午後" {
+		hour += 12
+	}
+	return time.Date(n[0], time.Month(n[1]), n[2], hour, n[4], 0, 0, JST), true
+}
+
+// getJSON fetches path from the on-demand API and decodes it into v.
+func (c *radiruClient) getJSON(ctx context.Context, path string, v any) error {
+	body, err := httpGet(ctx, c.http, c.baseURL+path)
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", path, err)
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// ResolvePlaylist returns the stream URL of the on-demand episode that aired on stationID at start.
+func (c *radiruClient) ResolvePlaylist(ctx context.Context, stationID string, start time.Time) (string, error) {
+	var list struct {
+		Corners []radiruCorner `json:"corners"`
+	}
+	if err := c.getJSON(ctx, "/corners/new_arrivals", &list); err != nil {
+		return "", err
+	}
+
+	for _, corner := range list.Corners {
+		if !radiruAirsOn(corner.RadioBroadcast, stationID) {
+			continue
+		}
+		var series struct {
+			Episodes []radiruEpisode `json:"episodes"`
+		}
+		query := url.Values{"site_id": {corner.SeriesSiteID}, "corner_site_id": {corner.CornerSiteID}}
+		if err := c.getJSON(ctx, "/series?"+query.Encode(), &series); err != nil {
+			return "", err
+		}
+		for _, episode := range series.Episodes {
+			if aired, ok := episode.start(); ok && aired.Equal(start) && episode.StreamURL != "" {
+				return episode.StreamURL, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no on-demand episode on %s at %s", stationID, start.In(JST).Format("2006-01-02 15:04"))
+}
+
+// radiruAirsOn reports whether the comma-separated channel list includes stationID.
+func radiruAirsOn(channels, stationID string) bool {
+	for _, channel := range strings.Split(channels, ",") {
+		if strings.EqualFold(strings.TrimSpace(channel), stationID) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListChunks returns the segment URLs of the stream at uri (see ListTimedChunks).
+func (c *radiruClient) ListChunks(ctx context.Context, uri string) ([]string, error) {
+	chunks, err := c.ListTimedChunks(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	return chunkURLs(chunks), nil
+}
+
+// ListTimedChunks returns the segments of the stream at uri, following a master playlist
+// to the variant selected by the stream quality, and remembers how each segment is encrypted for Download.
+func (c *radiruClient) ListTimedChunks(ctx context.Context, uri string) ([]Chunk, error) {
+	media, base, err := fetchMediaPlaylist(ctx, c.streamClient(), uri, c.stream.Quality)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := mediaChunks(media, base)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, chunk := range chunks {
+		key := media.Segments[i].Key
+		if key == nil {
+			continue
+		}
+		if !strings.EqualFold(key.Method, "AES-128") {
+			return nil, fmt.Errorf("unsupported stream encryption %s", key.Method)
+		}
+		keyURL, err := base.Parse(key.URI)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key URI: %w", err)
+		}
+		iv, err := segmentIV(key.IV, media.MediaSequence+uint64(i))
+		if err != nil {
+			return nil, err
+		}
+		c.segments[chunk.URL] = radiruSegment{keyURI: keyURL.String(), iv: iv}
+	}
+	return chunks, nil
+}
+
+// segmentIV returns the AES IV of a segment: the explicit "0x..." IV of the key tag,
+// or else the segment's media sequence number, as HLS specifies.
+func segmentIV(explicit string, seqNo uint64) ([]byte, error) {
+	if explicit != "" {
+		iv, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(explicit, "0x"), "0X"))
+		if err != nil || len(iv) != aes.BlockSize {
+			return nil, fmt.Errorf("invalid IV %s", explicit)
+		}
+		return iv, nil
+	}
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], seqNo)
+	return iv, nil
+}
+
+// Download fetches a segment listed by ListChunks, decrypting it and, when it is MPEG-TS,
+// demuxing it to the ADTS audio stream.
+func (c *radiruClient) Download(ctx context.Context, chunkURL string) (io.ReadCloser, error) {
+	body, err := httpGet(ctx, c.streamClient(), chunkURL)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read segment: %w", err)
+	}
+
+	c.mu.Lock()
+	segment := c.segments[chunkURL]
+	c.mu.Unlock()
+	if segment.keyURI != "" {
+		key, err := c.key(ctx, segment.keyURI)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = decryptAES128(data, key, segment.iv); err != nil {
+			return nil, err
+		}
+	}
+	if len(data) > 0 && data[0] == tsSyncByte {
+		if data, err = demuxTSAudio(data); err != nil {
+			return nil, err
+		}
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// streamClient returns the client of playlist, key and segment requests, with the headers
+// configured for radiru.
+func (c *radiruClient) streamClient() httpDoer {
+	return withHeaders(c.http, nil, c.stream.Headers)
+}
+
+// key returns the AES-128 key at uri, fetching it once.
+func (c *radiruClient) key(ctx context.Context, uri string) ([]byte, error) {
+	c.mu.Lock()
+	key, ok := c.keys[uri]
+	c.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	body, err := httpGet(ctx, c.streamClient(), uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream key: %w", err)
+	}
+	defer body.Close()
+	key, err = io.ReadAll(body)
+	if err != nil || len(key) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid stream key from %s", uri)
+	}
+
+	c.mu.Lock()
+	c.keys[uri] = key
+	c.mu.Unlock()
+	return key, nil
+}
+
+// decryptAES128 decrypts an HLS AES-128 (CBC, PKCS#7 padded) segment.
+func decryptAES128(data, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stream key: %w", err)
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted segment size %d is not a multiple of the block size", len(data))
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(out) {
+		return nil, fmt.Errorf("invalid padding in decrypted segment")
+	}
+	return out[:len(out)-pad], nil
+}
+
+const (
+	tsSyncByte   = 0x47
+	tsPacketSize = 188
+)
+
+// demuxTSAudio extracts the audio elementary stream from an MPEG-TS segment: the payload of the
+// first PID carrying an audio PES stream (stream_id 0xC0-0xDF), with the PES headers removed.
+func demuxTSAudio(data []byte) ([]byte, error) {
+	var out []byte
+	audioPID := -1
+	for off := 0; off+tsPacketSize <= len(data); off += tsPacketSize {
+		packet := data[off : off+tsPacketSize]
+		if packet[0] != tsSyncByte {
+			return nil, fmt.Errorf("lost MPEG-TS sync at offset %d", off)
+		}
+		unitStart := packet[1]&0x40 != 0
+		pid := int(packet[1]&0x1f)<<8 | int(packet[2])
+		control := packet[3] >> 4 & 0x3
+
+		payload := packet[4:]
+		if control&0x2 != 0 { // Adaptation field
+			skip := 1 + int(payload[0])
+			if skip > len(payload) {
+				continue
+			}
+			payload = payload[skip:]
+		}
+		if control&0x1 == 0 || (audioPID != -1 && pid != audioPID) {
+			continue
+		}
+
+		if unitStart {
+			if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+				continue // PSI tables (PAT, PMT) rather than a PES packet.
+			}
+			if streamID := payload[3]; audioPID == -1 {
+				if streamID < 0xC0 || streamID > 0xDF {
+					continue
+				}
+				audioPID = pid
+			}
+			headerLen := 9 + int(payload[8])
+			if headerLen > len(payload) {
+				return nil, fmt.Errorf("truncated PES header at offset %d", off)
+			}
+			payload = payload[headerLen:]
+		} else if audioPID == -1 {
+			continue
+		}
+		out = append(out, payload...)
+	}
+	if audioPID == -1 {
+		return nil, fmt.Errorf("no audio stream in MPEG-TS segment")
+	}
+	return out, nil
+}
@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRadiruEpisodeStart(t *testing.T) {
+	want := time.Date(2026, time.January, 12, 22, 0, 0, 0, JST)
+	tests := []struct {
+		name    string
+		episode radiruEpisode
+		ok      bool
+	}{
+		{"aa_contents_id", radiruEpisode{AAContentsID: "[ja]audio;r1;2026-01-12T22:00:00+09:00_2026-01-12T22:50:00+09:00"}, true},
+		{"onair_date PM", radiruEpisode{OnairDate: "2026年1月12日(月)午後10:00放送"}, true},
+		{"unparsable", radiruEpisode{OnairDate: "1月12日放送"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.episode.start()
+			if ok != tt.ok || (ok && !got.Equal(want)) {
+				t.Errorf("start() = %v, %v; want %v, %v", got, ok, want, tt.ok)
+			}
+		})
+	}
+
+	if got, _ := (radiruEpisode{OnairDate: "2026年1月12日(月)午前0:30放送"}).start(); got.Hour() != 0 || got.Minute() != 30 {
+		t.Errorf("expected 午前0:30 to be 00:30, got %v", got)
+	}
+}
+
+// tsSegment wraps payload into an MPEG-TS segment: a PAT packet followed by one audio PES on pid.
+func tsSegment(pid int, payload []byte) []byte {
+	var out []byte
+	pat := make([]byte, tsPacketSize)
+	copy(pat, []byte{tsSyncByte, 0x40, 0x00, 0x10, 0x00, 0x00, 0xB0, 0x0D})
+	out = append(out, pat...)
+
+	pes := append([]byte{0, 0, 1, 0xC0, 0, 0, 0x80, 0x80, 0x05, 0x21, 0, 1, 0, 1}, payload...)
+	for first := true; len(pes) > 0; first = false {
+		header := []byte{tsSyncByte, byte(pid >> 8 & 0x1f), byte(pid), 0x10}
+		if first {
+			header[1] |= 0x40
+		}
+		n := min(len(pes), tsPacketSize-4)
+		packet := header
+		if n < tsPacketSize-4 { // Stuff the last packet with an adaptation field.
+			packet[3] = 0x30
+			stuffing := tsPacketSize - 4 - n - 1
+			packet = append(packet, byte(stuffing))
+			if stuffing > 0 {
+				packet = append(packet, 0x00)
+				packet = append(packet, bytes.Repeat([]byte{0xFF}, stuffing-1)...)
+			}
+		}
+		packet = append(packet, pes[:n]...)
+		pes = pes[n:]
+		out = append(out, packet...)
+	}
+	return out
+}
+
+func TestDemuxTSAudio(t *testing.T) {
+	audio := []byte(strings.Repeat(dummyAACChunk, 20)) // Spans several packets.
+	got, err := demuxTSAudio(tsSegment(0x101, audio))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, audio) {
+		t.Errorf("demuxed %d bytes, want the %d audio bytes", len(got), len(audio))
+	}
+
+	if _, err := demuxTSAudio([]byte{0x47, 0x00}); err == nil {
+		t.Error("expected an error for a segment without audio")
+	}
+}
+
+func TestRadiruClient(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	segment := tsSegment(0x101, []byte(dummyAACChunk))
+	encrypted := encryptAES128(t, segment, key, make([]byte, aes.BlockSize)) // IV from media sequence 0.
+
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/api/corners/new_arrivals", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"corners": [
+			{"title": "FM Show", "radio_broadcast": "FM", "series_site_id": "FMS", "corner_site_id": "01"},
+			{"title": "R1 Show", "radio_broadcast": "R1,FM", "series_site_id": "R1S", "corner_site_id": "01"}
+		]}`)
+	})
+	mux.HandleFunc("/api/series", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("site_id") != "R1S" {
+			t.Errorf("unexpected series request %s", r.URL)
+		}
+		fmt.Fprintf(w, `{"episodes": [
+			{"program_title": "Last week", "onair_date": "2026年1月5日(月)午後10:00放送", "stream_url": "%[1]s/old.m3u8"},
+			{"program_title": "This week", "onair_date": "2026年1月12日(月)午後10:00放送", "stream_url": "%[1]s/hls/master.m3u8"}
+		]}`, srv.URL)
+	})
+	mux.HandleFunc("/hls/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=64000\nmedia.m3u8\n")
+	})
+	mux.HandleFunc("/hls/media.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n#EXTINF:10,\nseg0.ts\n#EXT-X-ENDLIST\n")
+	})
+	mux.HandleFunc("/hls/key.bin", func(w http.ResponseWriter, r *http.Request) { w.Write(key) })
+	mux.HandleFunc("/hls/seg0.ts", func(w http.ResponseWriter, r *http.Request) { w.Write(encrypted) })
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := newRadiruClient(srv.Client(), srv.URL+"/api")
+	uri, err := client.ResolvePlaylist(context.Background(), "r1", time.Date(2026, time.January, 12, 22, 0, 0, 0, JST))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri != srv.URL+"/hls/master.m3u8" {
+		t.Errorf("unexpected stream URL %s", uri)
+	}
+
+	chunks, err := client.ListChunks(context.Background(), uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 1 || chunks[0] != srv.URL+"/hls/seg0.ts" {
+		t.Fatalf("unexpected chunks %v", chunks)
+	}
+
+	rc, err := client.Download(context.Background(), chunks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	body, _ := io.ReadAll(rc)
+	if string(body) != dummyAACChunk {
+		t.Errorf("expected the decrypted ADTS audio, got % x", body)
+	}
+
+	if _, err := client.ResolvePlaylist(context.Background(), "r2", time.Date(2026, time.January, 12, 22, 0, 0, 0, JST)); err == nil {
+		t.Error("expected an error for a channel without episodes")
+	}
+}
+
+func encryptAES128(t *testing.T, data, key, iv []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	padded := append(append([]byte{}, data...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	out := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, padded)
+	return out
+}
+
+// guidelessMockClient is a MockRadikoClient for stations missing from the radiko guide.
+type guidelessMockClient struct {
+	MockRadikoClient
+}
+
+func (*guidelessMockClient) UsesRadikoGuide() bool { return false }
+
+func TestRunOnceRadiruProvider(t *testing.T) {
+	var radiko, radiru int
+	opts := Options{
+		Schedule: []ScheduleEntry{
+			{ProgramName: "NHK Show", DayOfWeek: "月", StartTime: "220000", StationID: "r1", Provider: ProviderRadiru},
+			{ProgramName: "Typo", DayOfWeek: "月", StartTime: "100000", StationID: "ST1", Provider: "radikoo"},
+		},
+		OutputDir: t.TempDir(),
+		Clock:     &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)},
+		Logger:    log.New(io.Discard, "", 0),
+		NewProvider: func(ctx context.Context) (Provider, error) {
+			radiko++
+			return &MockRadikoClient{}, nil
+		},
+		Providers: map[string]ProviderFactory{
+			ProviderRadiru: func(ctx context.Context) (Provider, error) {
+				radiru++
+				return &guidelessMockClient{}, nil
+			},
+		},
+		FetchGuide: func(ctx context.Context, stationID string) ([]byte, error) {
+			t.Errorf("the radiko guide was fetched for %s", stationID)
+			return nil, fmt.Errorf("unexpected")
+		},
+	}
+	err := RunOnce(context.Background(), opts)
+	if err == nil || !strings.Contains(err.Error(), "unknown provider") {
+		t.Errorf("expected an error for the unknown provider, got %v", err)
+	}
+	if radiko != 0 || radiru != 1 {
+		t.Errorf("expected one radiru client and no radiko client, got %d and %d", radiru, radiko)
+	}
+}
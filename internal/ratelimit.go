@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces requests evenly so that no more than a fixed number start per second.
+// One RateLimiter is shared by all jobs of a run, so the limit holds however many record in parallel.
+// A nil *RateLimiter does not limit.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time    // Earliest start of the next request.
+	parent   *RateLimiter // Also waited for, so a job's own limit stays within the shared one.
+}
+
+// NewRateLimiter returns a limiter allowing perSecond requests per second,
+// or nil (no limit) when perSecond is not positive.
+func NewRateLimiter(perSecond float64) *RateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &RateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// newJobLimiter returns a limiter allowing one job perSecond requests per second, on top of
+// the limit of shared. It returns shared when perSecond is not positive.
+func newJobLimiter(perSecond float64, shared *RateLimiter) *RateLimiter {
+	l := NewRateLimiter(perSecond)
+	if l == nil {
+		return shared
+	}
+	l.parent = shared
+	return l
+}
+
+// Wait blocks until the next request may start, or until ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay := slot.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return l.parent.Wait(ctx)
+}
+
+// panicking reports whether the broadcast starting at start leaves the timeshift window within
+// window of now. Its job then ignores the rate limits and StationConcurrency ("panic mode"), so
+// the episode is not lost to politeness settings. A window that is not positive disables it.
+func panicking(window time.Duration, start, now time.Time) bool {
+	return window > 0 && start.Add(TimeshiftWindow).Sub(now) <= window
+}
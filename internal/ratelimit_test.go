@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	if NewRateLimiter(0) != nil {
+		t.Error("expected no limiter for a zero rate")
+	}
+	var unlimited *RateLimiter
+	if err := unlimited.Wait(context.Background()); err != nil {
+		t.Errorf("nil limiter returned %v", err)
+	}
+
+	// Ten requests from several goroutines at 50/s take at least 9 intervals of 20ms.
+	limiter := NewRateLimiter(50)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 2; j++ {
+				if err := limiter.Wait(context.Background()); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("10 requests at 50/s finished in %v", elapsed)
+	}
+
+	slow := NewRateLimiter(0.1)
+	_ = slow.Wait(context.Background()) // The first request starts right away.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := slow.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to be cancelled, got %v", err)
+	}
+}
+
+func TestJobLimiter(t *testing.T) {
+	shared := NewRateLimiter(50)
+	if newJobLimiter(0, shared) != shared {
+		t.Error("expected the shared limiter without a job rate")
+	}
+
+	// A generous job limit still waits for the shared one: 4 requests at 50/s take 3 intervals.
+	job := newJobLimiter(1000, shared)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := job.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 55*time.Millisecond {
+		t.Errorf("4 requests within a shared 50/s finished in %v", elapsed)
+	}
+
+	// A strict job limit holds without a shared one.
+	job = newJobLimiter(50, nil)
+	start = time.Now()
+	for i := 0; i < 4; i++ {
+		if err := job.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 55*time.Millisecond {
+		t.Errorf("4 requests at 50/s per job finished in %v", elapsed)
+	}
+}
+
+func TestPanicking(t *testing.T) {
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)
+	tests := []struct {
+		name   string
+		window time.Duration
+		start  time.Time
+		want   bool
+	}{
+		{name: "disabled", start: now.Add(-TimeshiftWindow + time.Minute)},
+		{name: "leaving the window", window: 2 * time.Hour, start: now.Add(-TimeshiftWindow + time.Hour), want: true},
+		{name: "plenty of time", window: 2 * time.Hour, start: now.Add(-24 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := panicking(tt.window, tt.start, now); got != tt.want {
+				t.Errorf("panicking() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
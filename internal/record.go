@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RecordRange records the fixed window from-to on stationID, without a schedule entry.
+// The recording is named after the program starting at from if the guide has one,
+// otherwise after title, or "<from>-<to>" when title is empty.
+// Everything else (output layout, post_command, post-store, history) follows opts.
+func RecordRange(ctx context.Context, opts Options, stationID string, from, to time.Time, title string) (RecordingResult, error) {
+	opts = opts.withDefaults()
+	from, to = from.In(JST), to.In(JST)
+	if !to.After(from) {
+		return RecordingResult{}, fmt.Errorf("end time %s is not after start time %s", to.Format("2006-01-02 15:04"), from.Format("2006-01-02 15:04"))
+	}
+	if title == "" {
+		title = fmt.Sprintf("%s-%s", from.Format("200601021504"), to.Format("200601021504"))
+	}
+
+	entry := ScheduleEntry{
+		ProgramName: title,
+		DayOfWeek:   japaneseDayOfWeek(from.Weekday()),
+		StartTime:   from.Format("150405"),
+		StationID:   stationID,
+	}
+
+	client, err := opts.newClient(ctx, entry)
+	if err != nil {
+		return RecordingResult{}, fmt.Errorf("failed to create client: %w", err)
+	}
+	jobOpts := opts.jobOptions()
+	jobOpts.End = to
+	return ExecuteJob(ctx, client, entry, from, jobOpts)
+}
+
+// RecordBroadcast records the broadcast of entry starting at start once, as a run records the most
+// recent one, with the entry's rerun and fallback_stations. The broadcast must still be in the
+// timeshift window. A broadcast recorded before is skipped unless opts.Overwrite is set.
+func RecordBroadcast(ctx context.Context, opts Options, entry ScheduleEntry, start time.Time) (RecordingResult, error) {
+	opts = opts.withDefaults()
+	now := opts.Clock.Now().In(JST)
+	start = start.In(JST)
+	if _, err := checkTimeshift(start, now); err != nil {
+		return RecordingResult{}, fmt.Errorf("%s at %s: %w", entry.ProgramName, start.Format("2006-01-02 15:04"), err)
+	}
+	jobOpts := opts.jobOptions()
+	client, err := newSession(opts, jobOpts).client(ctx, entry)
+	if err != nil {
+		return RecordingResult{}, err
+	}
+	jobOpts.Authenticated = true
+	return executeJobWithRerun(ctx, client, entry, start, now, jobOpts)
+}
+
+// japaneseDayOfWeek returns the schedule notation (DayOfWeekMap key) of a weekday.
+func japaneseDayOfWeek(weekday time.Weekday) string {
+	for name, day := range DayOfWeekMap {
+		if day == weekday {
+			return name
+		}
+	}
+	return ""
+}
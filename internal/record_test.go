@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	goradiko "github.com/yyoshiki41/go-radiko"
+)
+
+// rangeMockClient is a MockRadikoClient that also supports arbitrary time ranges.
+type rangeMockClient struct {
+	MockRadikoClient
+	from, to time.Time
+}
+
+func (m *rangeMockClient) ResolveRangePlaylist(ctx context.Context, stationID string, from, to time.Time) (string, error) {
+	m.from, m.to = from, to
+	return "http://mock.m3u8/range.m3u8", nil
+}
+
+func TestRecordRange(t *testing.T) {
+	from := time.Date(2026, time.January, 13, 18, 0, 0, 0, JST)
+	to := time.Date(2026, time.January, 13, 21, 0, 0, 0, JST)
+	outputDir := t.TempDir()
+
+	client := &rangeMockClient{}
+	opts := Options{
+		OutputDir:   outputDir,
+		Logger:      log.New(&bytes.Buffer{}, "", 0),
+		FetchGuide:  func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+		NewProvider: func(ctx context.Context) (Provider, error) { return client, nil },
+
+		DisableProgressBar: true,
+	}
+
+	if _, err := RecordRange(context.Background(), opts, "TBS", from, to, ""); err != nil {
+		t.Fatalf("RecordRange failed: %v", err)
+	}
+	if !client.from.Equal(from) || !client.to.Equal(to) {
+		t.Errorf("requested window %v-%v, want %v-%v", client.from, client.to, from, to)
+	}
+	expected := filepath.Join(outputDir, "20260113180000-TBS-202601131800-202601132100.aac")
+	if _, err := os.Stat(expected); err != nil {
+		t.Errorf("expected output file %s: %v", expected, err)
+	}
+
+	if _, err := RecordRange(context.Background(), opts, "TBS", to, from, ""); err == nil {
+		t.Error("expected error for a window ending before it starts")
+	}
+
+	opts.NewProvider = func(ctx context.Context) (Provider, error) { return &MockRadikoClient{}, nil }
+	_, err := RecordRange(context.Background(), opts, "TBS", from, to.Add(time.Hour), "Special")
+	if err == nil || !strings.Contains(err.Error(), "does not support") {
+		t.Errorf("expected unsupported client error, got %v", err)
+	}
+}
+
+func TestRecordBroadcast(t *testing.T) {
+	start := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	outputDir := t.TempDir()
+	opts := Options{
+		OutputDir:   outputDir,
+		Logger:      log.New(&bytes.Buffer{}, "", 0),
+		Clock:       &fakeClock{now: start.Add(3 * 24 * time.Hour)},
+		FetchGuide:  func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+		NewProvider: func(ctx context.Context) (Provider, error) { return &MockRadikoClient{}, nil },
+
+		DisableProgressBar: true,
+	}
+	entry := ScheduleEntry{ProgramName: "Show", DayOfWeek: "月", StartTime: "100000", StationID: "TBS"}
+
+	result, err := RecordBroadcast(context.Background(), opts, entry, start)
+	if err != nil {
+		t.Fatalf("RecordBroadcast failed: %v", err)
+	}
+	if filepath.Dir(result.OutputPath) != outputDir || result.Skipped {
+		t.Errorf("result = %+v, want a new recording in %s", result, outputDir)
+	}
+	if result, err = RecordBroadcast(context.Background(), opts, entry, start); err != nil || !result.Skipped {
+		t.Errorf("second RecordBroadcast = %+v, %v, want skipped", result, err)
+	}
+
+	opts.Clock = &fakeClock{now: start.Add(8 * 24 * time.Hour)}
+	if _, err := RecordBroadcast(context.Background(), opts, entry, start); err == nil {
+		t.Error("expected error for a broadcast outside the timeshift window")
+	}
+}
+
+func TestMasterPlaylistURI(t *testing.T) {
+	master := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=52973,CODECS=\"mp4a.40.5\"\nhttps://radiko.jp/v2/api/ts/chunklist/abc.m3u8\n"
+	uri, err := masterPlaylistURI(strings.NewReader(master), "")
+	if err != nil {
+		t.Fatalf("masterPlaylistURI failed: %v", err)
+	}
+	if uri != "https://radiko.jp/v2/api/ts/chunklist/abc.m3u8" {
+		t.Errorf("uri = %s", uri)
+	}
+
+	if _, err := masterPlaylistURI(strings.NewReader("<html>error</html>"), ""); err == nil {
+		t.Error("expected error for a non-playlist response")
+	}
+}
+
+func TestResolveTimeshiftPlaylist(t *testing.T) {
+	master := "#EXTM3U\n#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=52973\nhttps://radiko.jp/v2/api/ts/chunklist/abc.m3u8\n"
+	from := time.Date(2026, time.March, 2, 10, 0, 0, 0, JST)
+	endpoints := []playlistEndpoint{
+		{URL: "https://api.example/playlist.m3u8", L: "15"},
+		{URL: "https://api.example/playlist.m3u8", L: "300"},
+		{URL: "https://stream.example/tf/playlist.m3u8", L: "15", Stream: true},
+	}
+
+	tests := []struct {
+		name      string
+		working   string // Query of the only request that succeeds.
+		expectErr bool
+		requests  int
+	}{
+		{name: "Primary endpoint", working: "l=15&station_id=TBS", requests: 1},
+		{name: "Other chunk length", working: "l=300", requests: 2},
+		{name: "Stream server", working: "type=b", requests: 3},
+		{name: "All endpoints fail", working: "none", expectErr: true, requests: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []*http.Request
+			client := doerFunc(func(req *http.Request) (*http.Response, error) {
+				requests = append(requests, req)
+				if strings.Contains(req.URL.RawQuery, tt.working) {
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(master))}, nil
+				}
+				return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil
+			})
+			uri, err := resolveTimeshiftPlaylist(context.Background(), client, "token", endpoints, "TBS", from, from.Add(time.Hour), "")
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+			if !tt.expectErr && uri != "https://radiko.jp/v2/api/ts/chunklist/abc.m3u8" {
+				t.Errorf("uri = %s", uri)
+			}
+			if len(requests) != tt.requests {
+				t.Errorf("made %d requests, want %d", len(requests), tt.requests)
+			}
+			last := requests[len(requests)-1]
+			if last.Header.Get("X-Radiko-AuthToken") != "token" || last.URL.Query().Get("to") != "20260302110000" {
+				t.Errorf("unexpected request %s %v", last.URL, last.Header)
+			}
+			if last.URL.Host == "stream.example" && (last.Method != http.MethodGet || last.URL.Query().Get("start_at") != "20260302100000") {
+				t.Errorf("unexpected stream server request %s %s", last.Method, last.URL)
+			}
+		})
+	}
+}
+
+// fakeRadikoAPI serves a guide and timeshift playlists to resolveProgramPlaylist.
+type fakeRadikoAPI struct {
+	doerFunc
+	stations goradiko.Stations
+}
+
+func (f fakeRadikoAPI) AuthToken() string { return "token" }
+
+func (f fakeRadikoAPI) GetStations(ctx context.Context, date time.Time) (goradiko.Stations, error) {
+	return f.stations, nil
+}
+
+func TestResolveProgramPlaylist(t *testing.T) {
+	master := "#EXTM3U\n" +
+		"#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=52973\nhttps://radiko.jp/chunklist/48k.m3u8\n" +
+		"#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=100000\nhttps://radiko.jp/chunklist/96k.m3u8\n"
+	start := time.Date(2026, time.March, 2, 10, 0, 0, 0, JST)
+	stations := goradiko.Stations{{ID: "TBS", Progs: goradiko.Progs{Progs: []goradiko.Prog{{Ft: "20260302100000", To: "20260302110000", Title: "Morning"}}}}}
+
+	tests := []struct {
+		name      string
+		quality   string
+		stationID string
+		start     time.Time
+		failing   int // Playlist requests answered with an error first.
+		expected  string
+		expectErr error
+	}{
+		{name: "First variant by default", stationID: "TBS", start: start, expected: "https://radiko.jp/chunklist/48k.m3u8"},
+		{name: "Highest quality", quality: QualityHighest, stationID: "TBS", start: start, expected: "https://radiko.jp/chunklist/96k.m3u8"},
+		{name: "Alternate endpoint", quality: QualityHighest, stationID: "TBS", start: start, failing: 1, expected: "https://radiko.jp/chunklist/96k.m3u8"},
+		{name: "Unknown station", stationID: "QRR", start: start, expectErr: ErrStationNotFound},
+		{name: "No program at the time", stationID: "TBS", start: start.Add(time.Hour), expectErr: goradiko.ErrProgramNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			api := fakeRadikoAPI{stations: stations, doerFunc: func(req *http.Request) (*http.Response, error) {
+				if requests++; requests <= tt.failing {
+					return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(master))}, nil
+			}}
+
+			var logged strings.Builder
+			ctx := withJobLogger(context.Background(), log.New(&logged, "", 0))
+			uri, err := resolveProgramPlaylist(ctx, api, tt.stationID, tt.start, tt.quality)
+			if tt.expectErr != nil {
+				if !errors.Is(err, tt.expectErr) {
+					t.Errorf("expected %v, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil || uri != tt.expected {
+				t.Errorf("resolveProgramPlaylist = %q, %v, want %q", uri, err, tt.expected)
+			}
+			if fellBack := strings.Contains(logged.String(), "through an alternate endpoint"); fellBack != (tt.failing > 0) {
+				t.Errorf("job log %q, want the fallback logged %v", logged.String(), tt.failing > 0)
+			}
+		})
+	}
+}
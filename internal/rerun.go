@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// rerunEntry returns the schedule entry for the rerun slot of entry.
+func rerunEntry(entry ScheduleEntry) ScheduleEntry {
+	rerun := entry
+	rerun.Rerun, rerun.FallbackStations = nil, nil
+	// The rerun of a one-shot or of a program on some weeks only is the first rerun slot after it,
+	// like for weekly entries.
+	rerun.Date, rerun.WeeksOfMonth, rerun.EveryWeeks = "", nil, 0
+	rerun.DayOfWeek = entry.Rerun.DayOfWeek
+	rerun.StartTime = entry.Rerun.StartTime
+	if entry.Rerun.StationID != "" {
+		rerun.StationID = entry.Rerun.StationID
+	}
+	return rerun
+}
+
+// rerunTime returns the start of the rerun of the broadcast at primaryTime.
+// It is the first rerun slot, read in loc, at or after the primary broadcast; an error is
+// returned if that rerun has not started yet at now.
+func rerunTime(entry ScheduleEntry, primaryTime, now time.Time, loc *time.Location) (time.Time, error) {
+	t, err := CalculateRecentPastRunTime(entry, now, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if t.Before(primaryTime) {
+		return time.Time{}, fmt.Errorf("rerun at %s has not aired yet", t.AddDate(0, 0, 7).Format("2006-01-02 15:04"))
+	}
+	return t, nil
+}
+
+// executeJobWithRerun records entry at pastTime, on its fallback stations if need be, and, if
+// that fails and the entry declares a rerun slot, records the rerun of the same broadcast instead.
+// The error of the primary attempt is returned only when the rerun could not be recorded either.
+// Broadcasts that have left the timeshift window at now fail without a download attempt.
+func executeJobWithRerun(ctx context.Context, client RadikoClient, entry ScheduleEntry, pastTime, now time.Time, opts JobOptions) (RecordingResult, error) {
+	logger := opts.logger()
+	result := RecordingResult{Title: entry.ProgramName, Start: pastTime}
+	left, err := checkTimeshift(pastTime, now)
+	if err == nil {
+		if left < timeshiftMargin {
+			logger.Printf("WARNING: The broadcast of '%s' at %s leaves radiko's timeshift window in %s; the download may not finish in time.", entry.ProgramName, pastTime.Format("2006-01-02 15:04"), left.Round(time.Minute))
+		}
+		if panicking(opts.PanicWindow, pastTime, now) && (opts.RateLimit != nil || opts.ChunkRate > 0) {
+			logger.Printf("INFO: The broadcast of '%s' at %s leaves radiko's timeshift window in %s; downloading it without the chunk rate limits.", entry.ProgramName, pastTime.Format("2006-01-02 15:04"), left.Round(time.Minute))
+			opts.RateLimit, opts.ChunkRate = nil, 0
+		}
+		result, err = executeJobWithFallbacks(ctx, client, entry, pastTime, opts)
+	}
+	if err == nil || entry.Rerun == nil || ctx.Err() != nil {
+		return result, err
+	}
+
+	rerun := rerunEntry(entry)
+	at, rerunErr := rerunTime(rerun, pastTime, now, opts.Location)
+	if rerunErr != nil {
+		logger.Printf("WARNING: Primary recording of '%s' failed; %v", entry.ProgramName, rerunErr)
+		return result, err
+	}
+
+	logger.Printf("INFO: Primary recording of '%s' failed, recording the rerun at %s instead.", entry.ProgramName, at.Format("2006-01-02 15:04"))
+	opts.Rerun = true
+	rerunResult, rerunErr := ExecuteJob(ctx, client, rerun, at, opts)
+	if rerunErr != nil {
+		return result, fmt.Errorf("%w (rerun also failed: %v)", err, rerunErr)
+	}
+	return rerunResult, nil
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRerunTime(t *testing.T) {
+	primary := time.Date(2026, time.January, 10, 1, 0, 0, 0, JST) // Saturday 01:00
+	rerun := ScheduleEntry{DayOfWeek: "水", StartTime: "150000"}
+
+	tests := []struct {
+		name        string
+		now         time.Time
+		expected    time.Time
+		expectError bool
+	}{
+		{
+			name:     "Rerun already aired",
+			now:      time.Date(2026, time.January, 15, 10, 0, 0, 0, JST), // Thursday
+			expected: time.Date(2026, time.January, 14, 15, 0, 0, 0, JST),
+		},
+		{
+			name:        "Rerun not aired yet",
+			now:         time.Date(2026, time.January, 13, 10, 0, 0, 0, JST), // Tuesday
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rerunTime(rerun, primary, tt.now, nil)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("rerunTime failed: %v", err)
+			}
+			if !got.Equal(tt.expected) {
+				t.Errorf("rerunTime = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExecuteJobWithRerun(t *testing.T) {
+	outputDir := t.TempDir()
+	primary := time.Date(2026, time.January, 10, 1, 0, 0, 0, JST)
+	now := time.Date(2026, time.January, 15, 10, 0, 0, 0, JST)
+	entry := ScheduleEntry{
+		ProgramName: "Test Program",
+		DayOfWeek:   "土",
+		StartTime:   "010000",
+		StationID:   "ST1",
+		Rerun:       &RerunSlot{DayOfWeek: "水", StartTime: "150000", StationID: "ST2"},
+	}
+
+	var requested []string
+	client := &MockRadikoClient{
+		ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+			requested = append(requested, fmt.Sprintf("%s@%s", stationID, pastTime.Format("20060102150405")))
+			if pastTime.Equal(primary) {
+				return "", fmt.Errorf("timeshift expired")
+			}
+			return "http://mock.m3u8/playlist.m3u8", nil
+		},
+	}
+	var logBuf bytes.Buffer
+	history := OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		Logger:     log.New(&logBuf, "", 0),
+		History:    history,
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+		DisableProgressBar: true,
+	}
+
+	if _, err := executeJobWithRerun(context.Background(), client, entry, primary, now, opts); err != nil {
+		t.Fatalf("executeJobWithRerun failed: %v", err)
+	}
+
+	want := []string{"ST1@20260110010000", "ST2@20260114150000"}
+	if strings.Join(requested, ",") != strings.Join(want, ",") {
+		t.Errorf("requested playlists %v, want %v", requested, want)
+	}
+	expected := filepath.Join(outputDir, "20260114150000-ST2-Test Program-rerun.aac")
+	if _, err := os.Stat(expected); err != nil {
+		t.Errorf("expected rerun output %s: %v", expected, err)
+	}
+
+	records, err := history.Records()
+	if err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if len(records) != 2 || records[0].Status != StatusFailed || records[0].Rerun || records[1].Status != StatusSuccess || !records[1].Rerun {
+		t.Errorf("unexpected history records: %+v", records)
+	}
+}
+
+func TestExecuteJobWithRerunTimeshift(t *testing.T) {
+	primary := time.Date(2026, time.January, 10, 1, 0, 0, 0, JST) // Saturday 01:00
+	rerun := &RerunSlot{DayOfWeek: "水", StartTime: "150000"}
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		rerun     *RerunSlot
+		requested string
+		warning   bool
+		expectErr bool
+	}{
+		{
+			name:      "expired",
+			now:       primary.Add(TimeshiftWindow),
+			expectErr: true,
+		},
+		{
+			name:      "expired with rerun",
+			now:       primary.Add(TimeshiftWindow + time.Minute),
+			rerun:     rerun,
+			requested: "ST1@20260114150000",
+		},
+		{
+			name:      "about to expire",
+			now:       primary.Add(TimeshiftWindow - 10*time.Minute),
+			requested: "ST1@20260110010000",
+			warning:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []string
+			client := &MockRadikoClient{
+				ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+					requested = append(requested, fmt.Sprintf("%s@%s", stationID, pastTime.Format("20060102150405")))
+					return "http://mock.m3u8/playlist.m3u8", nil
+				},
+			}
+			var logBuf bytes.Buffer
+			opts := JobOptions{
+				OutputDir:  t.TempDir(),
+				Logger:     log.New(&logBuf, "", 0),
+				FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+				DisableProgressBar: true,
+			}
+			entry := ScheduleEntry{ProgramName: "Test Program", DayOfWeek: "土", StartTime: "010000", StationID: "ST1", Rerun: tt.rerun}
+
+			_, err := executeJobWithRerun(context.Background(), client, entry, primary, tt.now, opts)
+			if tt.expectErr {
+				if !errors.Is(err, ErrOutsideTimeshift) {
+					t.Errorf("expected ErrOutsideTimeshift, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("executeJobWithRerun failed: %v", err)
+			}
+			if got := strings.Join(requested, ","); got != tt.requested {
+				t.Errorf("requested playlists %q, want %q", got, tt.requested)
+			}
+			if warned := strings.Contains(logBuf.String(), "leaves radiko's timeshift window in 10m0s"); warned != tt.warning {
+				t.Errorf("expected warning %v, got log:\n%s", tt.warning, logBuf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RotationConfig splits long recordings into parts (config "rotation").
+// A new part is started before a chunk would push the current one past either limit;
+// 0 disables that limit. The parts are listed in an M3U playlist saved under the
+// recording's name, which stands for the recording in the history and post_command.
+type RotationConfig struct {
+	MaxMB      int `json:"max_mb"`
+	MaxMinutes int `json:"max_minutes"`
+}
+
+// playlistExt is the extension of the playlist written for a rotated recording.
+const playlistExt = ".m3u"
+
+// enabled reports whether any rotation limit is set.
+func (c RotationConfig) enabled() bool {
+	return c.MaxMB > 0 || c.MaxMinutes > 0
+}
+
+// recordingPart is one output file of a recording, while it is being written.
+type recordingPart struct {
+	Path     string        // Partial file the part is written to.
+	Chunks   int           // Number of chunks appended.
+	Size     int64         // Bytes appended.
+	Duration time.Duration // 0 when any chunk duration is unknown.
+	known    bool          // Whether every chunk so far had a duration.
+}
+
+// recordingWriter appends chunks to a recording as they are downloaded, so the chunks are
+// never kept on disk next to the joined recording. A new part is started before a chunk
+// would push the current one past the limits of rotation; a single chunk exceeding a
+// limit still forms its own part.
+type recordingWriter struct {
+	path     string // Where a recording of a single part goes.
+	base     string // Where the parts of a rotated recording go, see saveRotatedRecording.
+	rotation RotationConfig
+	parts    []recordingPart
+	file     *os.File // Partial file of the last part.
+}
+
+// newRecordingWriter returns a writer for a recording meant for path, whose parts are
+// numbered after base if it is rotated.
+func newRecordingWriter(path, base string, rotation RotationConfig) *recordingWriter {
+	return &recordingWriter{path: path, base: base, rotation: rotation}
+}
+
+// add appends c to the recording and removes its file, if any.
+func (w *recordingWriter) add(c downloadedChunk) error {
+	size := c.size()
+	if w.file == nil || w.exceeds(size, c.Duration) {
+		if err := w.startPart(); err != nil {
+			return err
+		}
+	}
+
+	if c.Path == "" {
+		if _, err := w.file.Write(c.Data); err != nil {
+			return fmt.Errorf("failed to append chunk: %w", err)
+		}
+	} else {
+		if err := appendFile(w.file, c.Path, appendChunk); err != nil {
+			return err
+		}
+	}
+
+	part := &w.parts[len(w.parts)-1]
+	part.Chunks++
+	part.Size += size
+	part.Duration += c.Duration
+	part.known = part.known && c.Duration > 0
+	c.discard()
+	return nil
+}
+
+// exceeds reports whether a chunk of size bytes and duration d would push the current part
+// past a rotation limit.
+func (w *recordingWriter) exceeds(size int64, d time.Duration) bool {
+	part := w.parts[len(w.parts)-1]
+	if part.Chunks == 0 {
+		return false
+	}
+	maxBytes := int64(w.rotation.MaxMB) << 20
+	maxDuration := time.Duration(w.rotation.MaxMinutes) * time.Minute
+	return (maxBytes > 0 && part.Size+size > maxBytes) || (maxDuration > 0 && d > 0 && part.Duration+d > maxDuration)
+}
+
+// startPart flushes the current part and opens the next. The first part is written to the
+// partial file of path; once a second one starts, it moves to its numbered name.
+func (w *recordingWriter) startPart() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if len(w.parts) == 1 {
+		first := partialPath(partName(w.base, 1))
+		if err := os.Rename(w.parts[0].Path, first); err != nil {
+			return fmt.Errorf("failed to move part 1 to '%s': %w", first, err)
+		}
+		w.parts[0].Path = first
+	}
+
+	path := partialPath(w.path)
+	if len(w.parts) > 0 {
+		path = partialPath(partName(w.base, len(w.parts)+1))
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file '%s': %w", path, err)
+	}
+	w.file = file
+	w.parts = append(w.parts, recordingPart{Path: path, known: true})
+	return nil
+}
+
+// flush writes the current part to disk and closes it, so it can be renamed into place safely.
+func (w *recordingWriter) flush() error {
+	if w.file == nil {
+		return nil
+	}
+	file := w.file
+	w.file = nil
+	err := file.Sync()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to flush output file '%s': %w", file.Name(), err)
+	}
+	return nil
+}
+
+// close finishes the recording and returns its parts, at least one even without chunks.
+// Durations of parts with a chunk of unknown duration are 0.
+func (w *recordingWriter) close() ([]recordingPart, error) {
+	if len(w.parts) == 0 {
+		if err := w.startPart(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.flush(); err != nil {
+		return nil, err
+	}
+	for i := range w.parts {
+		if !w.parts[i].known {
+			w.parts[i].Duration = 0
+		}
+	}
+	return w.parts, nil
+}
+
+// remove deletes the partial files left of the recording, e.g. after a failed download.
+func (w *recordingWriter) remove() {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	for _, part := range w.parts {
+		os.Remove(part.Path)
+	}
+}
+
+// partName returns the file name of part n (1-based) of a recording named name.
+func partName(name string, n int) string {
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s.part%02d%s", strings.TrimSuffix(name, ext), n, ext)
+}
+
+// playlistPath returns the path of the playlist of a rotated recording saved as path.
+func playlistPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + playlistExt
+}
+
+// isPlaylist reports whether path is the playlist of a rotated recording.
+func isPlaylist(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), playlistExt)
+}
+
+// recordingExists returns where the recording saved as path is, as a single file or
+// as the playlist of a rotated recording.
+func recordingExists(path string) (string, bool) {
+	if _, err := os.Stat(path); err == nil {
+		return path, true
+	}
+	if playlist := playlistPath(path); playlist != path {
+		if _, err := os.Stat(playlist); err == nil {
+			return playlist, true
+		}
+	}
+	return "", false
+}
+
+// freeRecordingName returns the first name for a recording meant to be saved as target
+// that is not taken, as a single file or a playlist. Like placeRecording, a name already
+// taken is disambiguated.
+func freeRecordingName(target, strategy string, pastTime time.Time, subtitle string) string {
+	for i := 0; ; i++ {
+		candidate := recordingCandidate(target, strategy, pastTime, subtitle, i)
+		if _, ok := recordingExists(candidate); !ok {
+			return candidate
+		}
+	}
+}
+
+// saveRotatedRecording moves the parts written by a recordingWriter into place next to base
+// and writes the playlist listing them, replacing any files of the same names. Each part is
+// passed to prepare, if set, before it is moved into place. It returns the path of the playlist.
+func saveRotatedRecording(parts []recordingPart, base, title string, prepare func(path string) error) (string, error) {
+	dir, name := filepath.Split(base)
+
+	var playlist strings.Builder
+	playlist.WriteString("#EXTM3U\n")
+	for i, part := range parts {
+		partPath := filepath.Join(dir, partName(name, i+1))
+		if prepare != nil {
+			if err := prepare(part.Path); err != nil {
+				return "", fmt.Errorf("failed to prepare part %d: %w", i+1, err)
+			}
+		}
+		if err := os.Rename(part.Path, partPath); err != nil {
+			return "", fmt.Errorf("failed to move part %d to '%s': %w", i+1, partPath, err)
+		}
+		seconds := -1 // Unknown, per the extended M3U format.
+		if part.Duration > 0 {
+			seconds = int(part.Duration.Round(time.Second) / time.Second)
+		}
+		fmt.Fprintf(&playlist, "#EXTINF:%d,%s (%d/%d)\n%s\n", seconds, title, i+1, len(parts), filepath.Base(partPath))
+	}
+
+	path := playlistPath(base)
+	if err := writeFileAtomic(path, []byte(playlist.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write playlist '%s': %w", path, err)
+	}
+	return path, nil
+}
+
+// recordingFiles returns the audio files of the recording at path: the parts listed
+// by its playlist for a rotated recording, or path itself.
+func recordingFiles(path string) ([]string, error) {
+	if !isPlaylist(path) {
+		return []string{path}, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var files []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(path), line)
+		}
+		files = append(files, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read playlist '%s': %w", path, err)
+	}
+	return files, nil
+}
+
+// recordingPaths returns every file of the recording at path: its audio files and,
+// for a rotated recording, the playlist.
+func recordingPaths(path string) ([]string, error) {
+	files, err := recordingFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	if isPlaylist(path) {
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// removeReplacedFiles removes the files of an overwritten recording (see recordingPaths)
+// that are not part of the recording that replaced it at current. It returns the files removed.
+func removeReplacedFiles(replaced []string, current string) ([]string, error) {
+	kept := make(map[string]bool)
+	files, err := recordingPaths(current)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		kept[filepath.Clean(file)] = true
+	}
+	var removed []string
+	var errs []error
+	for _, file := range replaced {
+		if kept[filepath.Clean(file)] {
+			continue
+		}
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		} else if err == nil {
+			removed = append(removed, file)
+		}
+	}
+	return removed, errors.Join(errs...)
+}
+
+// recordingDigest returns the total size and SHA-256 hash of the audio of the recording
+// at path; the parts of a rotated recording are hashed as if concatenated.
+func recordingDigest(ctx context.Context, path string) (int64, []byte, error) {
+	files, err := recordingFiles(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	sum, n, err := hashFileContext(ctx, files...)
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, sum, nil
+}
@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordingWriter(t *testing.T) {
+	sizes := []int{400 << 10, 400 << 10, 400 << 10, 2 << 20}
+	timed := func(minutes ...int) []Chunk {
+		var chunks []Chunk
+		for _, m := range minutes {
+			chunks = append(chunks, Chunk{Duration: time.Duration(m) * time.Minute})
+		}
+		return chunks
+	}
+
+	tests := []struct {
+		name      string
+		chunks    []Chunk
+		cfg       RotationConfig
+		wantSizes []int
+		wantDur   []time.Duration
+	}{
+		{"by size", timed(5, 5, 5, 5), RotationConfig{MaxMB: 1}, []int{2, 1, 1}, []time.Duration{10 * time.Minute, 5 * time.Minute, 5 * time.Minute}},
+		{"by duration", timed(5, 5, 5, 5), RotationConfig{MaxMinutes: 10}, []int{2, 2}, []time.Duration{10 * time.Minute, 10 * time.Minute}},
+		{"first limit reached", timed(1, 1, 1, 1), RotationConfig{MaxMB: 2, MaxMinutes: 2}, []int{2, 1, 1}, []time.Duration{2 * time.Minute, time.Minute, time.Minute}},
+		{"unknown durations", []Chunk{{}, {}, {}, {}}, RotationConfig{MaxMinutes: 1}, []int{4}, []time.Duration{0}},
+		{"no rotation", timed(5, 5, 5, 5), RotationConfig{}, []int{4}, []time.Duration{20 * time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "Show.aac")
+			w := newRecordingWriter(path, path, tt.cfg)
+			for i, size := range sizes {
+				chunk := filepath.Join(dir, fmt.Sprintf("chunk%d.aac", i))
+				if err := os.WriteFile(chunk, make([]byte, size), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.add(downloadedChunk{Chunk: tt.chunks[i], Path: chunk}); err != nil {
+					t.Fatalf("add failed: %v", err)
+				}
+				if _, err := os.Stat(chunk); !os.IsNotExist(err) {
+					t.Errorf("expected chunk %d to be removed once appended", i)
+				}
+			}
+			parts, err := w.close()
+			if err != nil {
+				t.Fatalf("close failed: %v", err)
+			}
+
+			var counts []int
+			var durations []time.Duration
+			var total int64
+			for i, part := range parts {
+				counts = append(counts, part.Chunks)
+				durations = append(durations, part.Duration)
+				want := partialPath(path)
+				if len(parts) > 1 {
+					want = partialPath(filepath.Join(dir, partName("Show.aac", i+1)))
+				}
+				info, err := os.Stat(part.Path)
+				if part.Path != want || err != nil || info.Size() != part.Size {
+					t.Errorf("part %d at %s (%v), want %s of %d bytes", i+1, part.Path, err, want, part.Size)
+				}
+				total += part.Size
+			}
+			if !reflect.DeepEqual(counts, tt.wantSizes) || !reflect.DeepEqual(durations, tt.wantDur) {
+				t.Errorf("got parts of %v chunks (%v), want %v (%v)", counts, durations, tt.wantSizes, tt.wantDur)
+			}
+			if want := int64(3*(400<<10) + 2<<20); total != want {
+				t.Errorf("parts hold %d bytes, want %d", total, want)
+			}
+
+			w.remove()
+			if left, _ := os.ReadDir(dir); len(left) != 0 {
+				t.Errorf("expected remove to delete the parts, found %d files", len(left))
+			}
+		})
+	}
+}
+
+// timedMockClient reports a duration for each of its chunks.
+type timedMockClient struct {
+	MockRadikoClient
+	chunks []Chunk
+}
+
+func (m *timedMockClient) ListTimedChunks(ctx context.Context, uri string) ([]Chunk, error) {
+	return m.chunks, nil
+}
+
+func TestExecuteJobRotation(t *testing.T) {
+	client := &timedMockClient{}
+	for i := 0; i < 5; i++ {
+		client.chunks = append(client.chunks, Chunk{URL: fmt.Sprintf("http://mock.chunk/chunk%d.aac", i), Duration: 5 * time.Minute})
+	}
+	outputDir := t.TempDir()
+	history := OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		History:    history,
+		Quiet:      true,
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+		Rotation:   RotationConfig{MaxMinutes: 10},
+	}
+	entry := ScheduleEntry{ProgramName: "Long Program", StationID: "ST1"}
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+
+	if _, err := ExecuteJob(context.Background(), client, entry, pastTime, opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+
+	playlist := filepath.Join(outputDir, "20260112100000-ST1-Long Program.m3u")
+	data, err := os.ReadFile(playlist)
+	if err != nil {
+		t.Fatalf("expected a playlist: %v", err)
+	}
+	want := "#EXTM3U\n" +
+		"#EXTINF:600,Long Program (1/3)\n20260112100000-ST1-Long Program.part01.aac\n" +
+		"#EXTINF:600,Long Program (2/3)\n20260112100000-ST1-Long Program.part02.aac\n" +
+		"#EXTINF:300,Long Program (3/3)\n20260112100000-ST1-Long Program.part03.aac\n"
+	if string(data) != want {
+		t.Errorf("unexpected playlist:\n%s", data)
+	}
+	files, err := recordingFiles(playlist)
+	if err != nil || len(files) != 3 {
+		t.Fatalf("expected 3 parts, got %v (%v)", files, err)
+	}
+	if info, err := os.Stat(files[2]); err != nil || info.Size() != int64(len(dummyAACChunk)) {
+		t.Errorf("unexpected last part: %v", err)
+	}
+
+	records, err := history.Records()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expected one history record, got %d (%v)", len(records), err)
+	}
+	rec := records[0]
+	wantSum := sha256.Sum256([]byte(strings.Repeat(dummyAACChunk, 5)))
+	if rec.OutputPath != playlist || rec.Size != int64(5*len(dummyAACChunk)) || rec.SHA256 != hex.EncodeToString(wantSum[:]) {
+		t.Errorf("unexpected history record: %+v", rec)
+	}
+	if issue, _, err := verifyRecording(context.Background(), rec); err != nil || issue != nil {
+		t.Errorf("expected the rotated recording to verify, got %+v (%v)", issue, err)
+	}
+
+	// The playlist counts as the existing recording on the next run.
+	if _, err := ExecuteJob(context.Background(), client, entry, pastTime, opts); err != nil {
+		t.Fatalf("ExecuteJob (second run) failed: %v", err)
+	}
+	if records, _ := history.Records(); len(records) != 1 {
+		t.Errorf("expected the second run to be skipped, got %d records", len(records))
+	}
+
+	if err := os.Remove(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	issue, _, err := verifyRecording(context.Background(), rec)
+	if err != nil || issue == nil || issue.Kind != AuditMissing || !strings.Contains(issue.Detail, "part02") {
+		t.Errorf("expected a missing part to be reported, got %+v (%v)", issue, err)
+	}
+}
+
+func TestExecuteJobSplitMinutes(t *testing.T) {
+	client := &timedMockClient{}
+	for i := 0; i < 6; i++ {
+		client.chunks = append(client.chunks, Chunk{URL: fmt.Sprintf("http://mock.chunk/chunk%d.aac", i), Duration: 10 * time.Minute})
+	}
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	tests := []struct {
+		name     string
+		rotation RotationConfig
+		split    int
+		parts    int // 0 for a single file.
+	}{
+		{"entry only", RotationConfig{}, 30, 2},
+		{"overrides global", RotationConfig{MaxMinutes: 20}, 60, 0},
+		{"global", RotationConfig{MaxMinutes: 20}, 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			opts := JobOptions{
+				OutputDir:  outputDir,
+				Quiet:      true,
+				FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+				Rotation:   tt.rotation,
+			}
+			entry := ScheduleEntry{ProgramName: "Block", StationID: "ST1", SplitMinutes: tt.split}
+			if _, err := ExecuteJob(context.Background(), client, entry, pastTime, opts); err != nil {
+				t.Fatalf("ExecuteJob failed: %v", err)
+			}
+
+			if tt.parts == 0 {
+				if _, err := os.Stat(filepath.Join(outputDir, "20260112100000-ST1-Block.aac")); err != nil {
+					t.Errorf("expected a single file: %v", err)
+				}
+				return
+			}
+			files, err := recordingFiles(filepath.Join(outputDir, "20260112100000-ST1-Block.m3u"))
+			if err != nil || len(files) != tt.parts {
+				t.Errorf("expected %d parts, got %v (%v)", tt.parts, files, err)
+			}
+		})
+	}
+}
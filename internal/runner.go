@@ -1,239 +1,1138 @@
 package internal
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
-
-	"github.com/briandowns/spinner"            // Import spinner
-	goradiko "github.com/yyoshiki41/go-radiko" // Alias to avoid conflict with our internal package name
 )
 
-type RadikoClient interface {
-	AuthorizeToken(ctx context.Context) (string, error)
-	TimeshiftPlaylistM3U8(ctx context.Context, stationID string, pastTime time.Time) (string, error)
-	GetChunklistFromM3U8(uri string) ([]string, error)
-	Do(req *http.Request) (*http.Response, error) // For bulkDownload
+// JobOptions controls where a recording is written and what happens to it afterwards.
+type JobOptions struct {
+	OutputDir   string
+	PostStore   PostStore   // Optional; uploads the finished file when set.
+	DeleteLocal bool        // Remove the local file after a successful PostStore upload.
+	Logger      *log.Logger // Optional; defaults to the standard logger.
+	PostCommand string      // Global post_command; ScheduleEntry.PostCommand takes precedence.
+	History     *History    // Optional; every attempted recording is appended to it.
+
+	// Quiet suppresses progress and detailed log output; a single summary line is
+	// written to Logger per job instead. Detailed lines still go to the job log.
+	Quiet bool
+	// DisableProgressBar replaces the terminal progress bar with periodic log lines.
+	DisableProgressBar bool
+
+	FetchGuide func(ctx context.Context, stationID string) ([]byte, error) // Optional; defaults to GetProgramGuide.
+	ChunkCache *Cache                                                      // Optional; verified chunks are reused from and stored here.
+	RateLimit  *RateLimiter                                                // Optional; paces chunk requests, shared by concurrent jobs.
+	ChunkRate  float64                                                     // Chunk requests per second of this job alone, within RateLimit; 0 is unlimited.
+	Tokens     *TokenCache                                                 // Optional; auth tokens are reused from and stored here.
+
+	// PanicWindow exempts the job of a schedule entry's broadcast from RateLimit and ChunkRate
+	// when the broadcast leaves the timeshift window within this time; 0 never exempts it.
+	PanicWindow time.Duration
+	// Output sets the language of the job's messages and errors and its progress bar.
+	Output Output
+	// Location is the time zone the rerun slot of a schedule entry is read in; nil is Japan time.
+	Location *time.Location
+
+	// Rerun marks the job as a rerun fallback (see ScheduleEntry.Rerun);
+	// the output file name and the history record are tagged accordingly.
+	Rerun bool
+
+	// Authenticated marks the provider as already authenticated by the caller, e.g. once for
+	// all jobs of a run; the job then starts with the playlist.
+	Authenticated bool
+
+	// End, when set, records the fixed window from pastTime to End instead of the program starting at pastTime.
+	End time.Time
+
+	Layout         string // File naming layout (LayoutTimestamp, LayoutTitle or LayoutSeries). Defaults to LayoutTimestamp.
+	TitleCollision string // How a name already used by a different recording is disambiguated. Defaults to CollisionDateSubtitle.
+	ScheduleTitles bool   // Name and tag recordings after ScheduleEntry.ProgramName even when the guide's title differs.
+
+	// TempDir is where chunks are downloaded before they are appended to the recording. Defaults to os.TempDir.
+	TempDir string
+	// ChunkBufferMB, when positive, keeps each chunk in memory instead, refusing chunks larger
+	// than that many MB, so nothing but the recording is written to disk.
+	ChunkBufferMB int
+	// KeepChunks leaves the temporary directory with every chunk and an index of them
+	// (chunkIndexFile) in place after the job, for diagnosing download and concatenation
+	// problems. It takes precedence over ChunkBufferMB.
+	KeepChunks bool
+	// ChunkNamePattern names the chunk files in the temporary directory, see chunkName.
+	// Defaults to DefaultChunkNamePattern.
+	ChunkNamePattern string
+
+	// FFmpeg is the ffmpeg executable used for entries with Normalize or Archive set. Defaults to DefaultFFmpeg.
+	FFmpeg string
+
+	// ShowNotes writes the description, performers and web page of the broadcast from the guide
+	// next to the recording as Markdown (see showNotesExt).
+	ShowNotes bool
+
+	// Transcriber, when set, writes a transcript next to the recording (see TranscriptionConfig).
+	Transcriber *Transcriber
+
+	// Chapters writes the programs and corners of the guide airing during the recording
+	// as chapters next to it (see chaptersExt).
+	Chapters bool
+
+	// Overwrite records broadcasts that were recorded before again, replacing the earlier recording,
+	// instead of skipping them.
+	Overwrite bool
+
+	RequestTimeout time.Duration // Limit of each provider call and chunk download. Defaults to DefaultRequestTimeout.
+	JobTimeout     time.Duration // Limit of the whole job; 0 is unlimited.
+
+	Rotation RotationConfig // Optional; splits long recordings into parts listed in a playlist.
+
+	// DurationCheck compares the length of the recording with the broadcast in the guide.
+	DurationCheck DurationCheckConfig
+
+	// TolerateMissingChunks is the percentage of chunks that may be left out of the recording when
+	// they cannot be downloaded; the recording then succeeds with a warning in its manifest.
+	// 0 fails the job on any such chunk.
+	TolerateMissingChunks float64
+
+	// OnProgress, when set, receives every progress update of the download, alongside the
+	// progress bar or log.
+	OnProgress func(ProgressUpdate)
 }
 
-// Concrete goradiko client wrapper
-type goradikoClient struct {
-	client *goradiko.Client
+// RecordingResult describes the recording made by ExecuteJob.
+type RecordingResult struct {
+	OutputPath string        // The recording, or the playlist of a rotated one; the earlier recording when skipped.
+	Title      string        // Program title from the guide, or the schedule's name.
+	Start      time.Time     // Start of the broadcast, after any move found in the guide.
+	Size       int64         // Bytes of audio written, across all parts.
+	Chunks     int           // Chunks downloaded.
+	Duration   time.Duration // Length of the audio according to the playlist; 0 if unknown.
+	Skipped    bool          // The broadcast was recorded before; nothing was downloaded.
 }
 
-func NewGoradikoClient(token string) (RadikoClient, error) {
-	client, err := goradiko.New(token)
-	if err != nil {
-		return nil, err
+// DefaultRequestTimeout bounds a single provider call or chunk download when no RequestTimeout is set.
+const DefaultRequestTimeout = 2 * time.Minute
+
+// errJobTimeout is the cause of the job context's cancellation when JobTimeout expires.
+var errJobTimeout = errors.New("job timed out")
+
+// maxMissingChunks returns how many of n chunks may be left out of a recording, see TolerateMissingChunks.
+func (o JobOptions) maxMissingChunks(n int) int {
+	return int(float64(n) * o.TolerateMissingChunks / 100)
+}
+
+// requestTimeout returns the configured request timeout or DefaultRequestTimeout.
+func (o JobOptions) requestTimeout() time.Duration {
+	if o.RequestTimeout > 0 {
+		return o.RequestTimeout
+	}
+	return DefaultRequestTimeout
+}
+
+// request runs a single provider call with ctx bounded by the request timeout,
+// so a stalled connection fails the call instead of hanging the job.
+func (o JobOptions) request(ctx context.Context, call func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, o.requestTimeout())
+	defer cancel()
+	return call(ctx)
+}
+
+// logger returns the configured logger or the standard logger.
+func (o JobOptions) logger() *log.Logger {
+	if o.Logger != nil {
+		return o.Logger
 	}
-	return &goradikoClient{client: client}, nil
+	return log.Default()
 }
 
-func (g *goradikoClient) AuthorizeToken(ctx context.Context) (string, error) {
-	return g.client.AuthorizeToken(ctx)
+// jobLoggerKey is the context key of the logger of a job, see withJobLogger.
+type jobLoggerKey struct{}
+
+// withJobLogger returns ctx carrying logger, so providers can log what they do on behalf of the
+// job to its console and job log.
+func withJobLogger(ctx context.Context, logger *log.Logger) context.Context {
+	return context.WithValue(ctx, jobLoggerKey{}, logger)
 }
 
-func (g *goradikoClient) TimeshiftPlaylistM3U8(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
-	return g.client.TimeshiftPlaylistM3U8(ctx, stationID, pastTime)
+// jobLogger returns the logger of the job ctx belongs to, or log.Default() outside of a job.
+func jobLogger(ctx context.Context) *log.Logger {
+	if logger, ok := ctx.Value(jobLoggerKey{}).(*log.Logger); ok {
+		return logger
+	}
+	return log.Default()
 }
 
-func (g *goradikoClient) GetChunklistFromM3U8(uri string) ([]string, error) {
-	return goradiko.GetChunklistFromM3U8(uri)
+// shiftedProgram looks up the broadcast of entry near pastTime when the entry has a ShiftWindow,
+// so programs moved by overruns are recorded in full at their actual time. Fixed-window
+// recordings (End set) are never moved.
+func (o JobOptions) shiftedProgram(programData []byte, entry ScheduleEntry, pastTime time.Time) (Prog, time.Time, bool) {
+	if entry.ShiftWindow <= 0 || !o.End.IsZero() {
+		return Prog{}, time.Time{}, false
+	}
+	return findShiftedProgram(programData, entry.ProgramName, pastTime, time.Duration(entry.ShiftWindow)*time.Minute)
 }
 
-func (g *goradikoClient) Do(req *http.Request) (*http.Response, error) {
-	return g.client.Do(req)
+// fetchGuide returns the configured guide fetcher or GetProgramGuide.
+func (o JobOptions) fetchGuide() func(ctx context.Context, stationID string) ([]byte, error) {
+	if o.FetchGuide != nil {
+		return o.FetchGuide
+	}
+	return GetProgramGuide
 }
 
-// ExecuteJob runs the recording process for a given schedule entry and time.
-// It now accepts a RadikoClient interface for dependency injection.
-func ExecuteJob(radikoClient RadikoClient, entry ScheduleEntry, pastTime time.Time, outputDir string) error {
-	log.Printf("INFO: Starting recording for: %s (%s) for past broadcast at %s", entry.ProgramName, entry.StationID, pastTime.Format("2006-01-02 15:04:05"))
+// normalize normalizes the loudness of the recording at path (see ScheduleEntry.Normalize).
+// A failure only costs the normalization: the recording is kept as downloaded.
+func (o JobOptions) normalize(ctx context.Context, logger *log.Logger, path string) {
+	logger.Println("INFO: Normalizing loudness...")
+	if err := normalizeLoudness(ctx, o.FFmpeg, path); err != nil {
+		logger.Printf("WARNING: Loudness normalization failed, keeping the recording as downloaded: %v", err)
+	}
+}
 
-	// Get program name from radiko API to check for existing files first.
-	programData, err := GetProgramGuide(entry.StationID)
-	var programName string
+// existingRecording returns the path of an earlier recording of this broadcast, if there is one.
+// A successful recording in the history that was uploaded to the post-store and deleted
+// locally (see HistoryRecord.LocalRemoved) counts in every layout, so it is not downloaded and
+// uploaded again. In the timestamp layout the file name identifies the broadcast (also under
+// its name from before file names were sanitized), as do the show and date in the series
+// layout. In the title layout episodes may share a name, so the history (and the date-tagged
+// name) is consulted instead.
+func (o JobOptions) existingRecording(logger *log.Logger, entry ScheduleEntry, pastTime time.Time, title, outputFilePath string) (string, bool) {
+	if o.History != nil {
+		rec, ok, err := o.History.FindRecording(entry.StationID, pastTime, o.Rerun)
+		if err != nil {
+			logger.Printf("WARNING: Failed to read history: %v", err)
+		} else if ok && rec.LocalRemoved {
+			logger.Printf("INFO: The broadcast was recorded and uploaded to the post-store on %s.", rec.FinishedAt.In(JST).Format("2006-01-02 15:04"))
+			return rec.OutputPath, true
+		} else if ok && o.Layout == LayoutTitle {
+			if _, err := os.Stat(rec.OutputPath); err == nil {
+				return rec.OutputPath, true
+			}
+		}
+	}
+
+	if o.Layout != LayoutTitle {
+		if path, ok := recordingExists(outputFilePath); ok || o.Layout == LayoutSeries {
+			return path, ok
+		}
+		legacy := filepath.Join(filepath.Dir(outputFilePath), legacyOutputFileName(o.Layout, pastTime, entry.StationID, title, o.Rerun))
+		if legacy == outputFilePath {
+			return "", false
+		}
+		return recordingExists(legacy)
+	}
+	dated := filepath.Join(filepath.Dir(outputFilePath), disambiguatedName(filepath.Base(outputFilePath), CollisionDate, pastTime, ""))
+	return recordingExists(dated)
+}
+
+// pendingUpload returns the earlier attempt at this broadcast whose recording was saved but
+// not completely uploaded to the post-store (see HistoryRecord.PendingUploads). Such attempts
+// are ignored without a post-store, when overwriting, and when the recording itself is gone.
+func (o JobOptions) pendingUpload(logger *log.Logger, entry ScheduleEntry, pastTime time.Time) (HistoryRecord, bool) {
+	if o.History == nil || o.PostStore == nil || o.Overwrite {
+		return HistoryRecord{}, false
+	}
+	rec, ok, err := o.History.FindPendingUpload(entry.StationID, pastTime, o.Rerun)
 	if err != nil {
-		log.Printf("WARNING: Failed to get program guide for station %s, falling back to schedule.json: %v", entry.StationID, err)
+		logger.Printf("WARNING: Failed to read history: %v", err)
+		return HistoryRecord{}, false
+	}
+	if !ok {
+		return HistoryRecord{}, false
+	}
+	if slices.Contains(rec.PendingUploads, rec.OutputPath) {
+		if _, err := os.Stat(rec.OutputPath); err != nil {
+			logger.Printf("WARNING: The recording left to upload by an earlier attempt is gone, recording again: %v", err)
+			return HistoryRecord{}, false
+		}
+	}
+	return rec, true
+}
+
+// ExecuteJob runs the recording process for a given schedule entry and time, using provider
+// to authenticate, resolve the playlist and download the chunks.
+// The result describes the recording; on failure only its Title and Start are set.
+func ExecuteJob(ctx context.Context, provider Provider, entry ScheduleEntry, pastTime time.Time, opts JobOptions) (result RecordingResult, err error) {
+	timeout := opts.jobTimeout(entry)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, errJobTimeout)
+		defer cancel()
+	}
+	outputDir := opts.OutputDir
+	summaryLogger := opts.logger()
+	console := summaryLogger.Writer()
+	if opts.Quiet {
+		console = io.Discard
+	}
+	logger := log.New(console, summaryLogger.Prefix(), summaryLogger.Flags())
+	ctx = withJobLogger(ctx, logger)
+
+	// With a history, every line logged by this job is also captured into a per-job log file.
+	// Lines are buffered until we know the job is not skipped, then flushed to the file.
+	var jobLog bytes.Buffer
+	if opts.History != nil {
+		logger.SetOutput(io.MultiWriter(console, &jobLog))
+	}
+
+	programName := entry.ProgramName
+	var (
+		outputFilePath string
+		outputSize     int64
+		outputSHA256   string
+		localRemoved   bool
+		pendingUploads []string // Files left to upload when the post-store upload failed.
+		chunklist      []Chunk
+	)
+	skipped := false
+	defer func() {
+		result = RecordingResult{Title: programName, Start: pastTime}
+		if err == nil {
+			result.OutputPath, result.Skipped = outputFilePath, skipped
+			result.Size, result.Chunks, result.Duration = outputSize, len(chunklist), chunksDuration(chunklist)
+		}
+	}()
+	if opts.Quiet {
+		defer func() {
+			summaryLogger.Print(jobSummary(opts.Output, entry, programName, pastTime, outputFilePath, outputSize, skipped, err))
+		}()
+	}
+
+	logger.Printf(opts.Output.Msg("INFO: Starting recording for: %s (%s) for past broadcast at %s"), entry.ProgramName, entry.StationID, pastTime.Format("2006-01-02 15:04:05"))
+
+	// Get program name from radiko API to check for existing files first.
+	// Stations outside the radiko guide (see GuideAware) keep the schedule's name.
+	var guideProg *Prog
+	var programData []byte
+	if usesRadikoGuide(provider) {
+		var guideErr error
+		if programData, guideErr = opts.fetchGuide()(ctx, entry.StationID); guideErr != nil {
+			logger.Printf("WARNING: Failed to get program guide for station %s, falling back to schedule.json: %v", entry.StationID, guideErr)
+			programData = nil
+		}
+	}
+	if programData == nil {
+		programName = entry.ProgramName
+	} else if prog, start, ok := opts.shiftedProgram(programData, entry, pastTime); ok {
+		if !start.Equal(pastTime) {
+			logger.Printf("INFO: The guide moved '%s' from %s to %s; recording it at the new time.", prog.Title, pastTime.Format("2006-01-02 15:04"), start.Format("2006-01-02 15:04"))
+			pastTime = start
+		}
+		programName = prog.Title
+		guideProg = &prog
+		logger.Printf(opts.Output.Msg("INFO: Successfully found program name: %s"), programName)
+	} else if prog, err := FindProgram(programData, pastTime); err != nil {
+		logger.Printf("WARNING: Failed to find program name for %s at %s on %s, falling back to schedule.json: %v", entry.StationID, entry.StartTime, entry.DayOfWeek, err)
 		programName = entry.ProgramName
 	} else {
-		dayOfWeek, err := toEnglishDayOfWeek(entry.DayOfWeek)
-		if err != nil {
-			log.Printf("WARNING: %v, falling back to schedule.json", err)
+		programName = prog.Title
+		guideProg = &prog
+		logger.Printf(opts.Output.Msg("INFO: Successfully found program name: %s"), programName)
+	}
+	if guideProg != nil && programName != entry.ProgramName {
+		if opts.ScheduleTitles {
+			logger.Printf("INFO: Naming the recording after program_name '%s' instead of the guide's title '%s'.", entry.ProgramName, programName)
 			programName = entry.ProgramName
 		} else {
-			name, err := FindProgramTitle(programData, entry.StartTime, dayOfWeek)
-			if err != nil {
-				log.Printf("WARNING: Failed to find program name for %s at %s on %s, falling back to schedule.json: %v", entry.StationID, entry.StartTime, entry.DayOfWeek, err)
-				programName = entry.ProgramName
-			} else {
-				programName = name
-				log.Printf("INFO: Successfully found program name: %s", programName)
-			}
+			logger.Printf("INFO: Naming the recording after the guide's title '%s' instead of program_name '%s'.", programName, entry.ProgramName)
 		}
 	}
 
-	outputFileName := fmt.Sprintf("%s-%s-%s.aac", pastTime.Format("20060102150405"), entry.StationID, programName)
-	outputFilePath := filepath.Join(outputDir, outputFileName)
+	fileName := programName
+	if opts.Layout == LayoutSeries {
+		fileName = entry.ProgramName // The show's folder must not change with each episode's title.
+	}
+	outputFilePath = filepath.Join(outputDir, outputFileName(opts.Layout, pastTime, entry.StationID, fileName, opts.Rerun))
+	format, convert, err := recordingFormat(entry, opts.Layout)
+	if err != nil {
+		return result, err
+	}
+	if convert {
+		outputFilePath = strings.TrimSuffix(outputFilePath, filepath.Ext(outputFilePath)) + format.ext
+	}
+
+	// An earlier attempt that saved the recording but failed to upload it is finished by
+	// uploading the files it left, instead of skipping the broadcast as already recorded.
+	var resumed *HistoryRecord
+	if rec, ok := opts.pendingUpload(logger, entry, pastTime); ok {
+		logger.Printf("INFO: The recording was saved by an earlier attempt on %s but not uploaded; uploading it now: %s", rec.FinishedAt.In(JST).Format("2006-01-02 15:04"), rec.OutputPath)
+		resumed = &rec
+		outputFilePath = rec.OutputPath
+	}
 
 	// Check if the file already exists before proceeding to download.
-	if _, err := os.Stat(outputFilePath); err == nil {
-		log.Printf("INFO: File already exists, skipping: %s", outputFilePath)
-		return nil
+	var replaced []string // Files of the earlier recording, when overwriting it.
+	if resumed != nil {
+		// The recording is in place; only its upload is left.
+	} else if existing, ok := opts.existingRecording(logger, entry, pastTime, programName, outputFilePath); ok {
+		if !opts.Overwrite {
+			logger.Printf(opts.Output.Msg("INFO: File already exists, skipping: %s"), existing)
+			outputFilePath = existing
+			skipped = true
+			return result, nil
+		}
+		logger.Printf("INFO: File already exists, recording again to overwrite it: %s", existing)
+		if replaced, err = recordingPaths(existing); err != nil {
+			return result, fmt.Errorf(opts.Output.Msg("failed to read the recording to overwrite: %w"), err)
+		}
+		if opts.Layout == LayoutTitle {
+			// Episodes share the title; replace this broadcast's recording, wherever it was saved.
+			outputFilePath = strings.TrimSuffix(existing, filepath.Ext(existing)) + filepath.Ext(outputFilePath)
+		}
+	}
+	// A partial file under this name was left by an interrupted run. It is overwritten below.
+	if _, err := os.Stat(partialPath(outputFilePath)); err == nil {
+		logger.Printf("WARNING: Found a partial recording left by an interrupted run, recording again: %s", partialPath(outputFilePath))
 	}
 
-	ctx := context.Background()
+	// From here on the attempt is recorded in the history, together with the guide snapshot,
+	// and its steps in the action log.
+	logAction := func(ActionRecord) {}
+	if opts.History != nil {
+		id := newJobID()
+		logPath := opts.History.JobLogPath(id)
+		if logFile, err := createJobLog(logPath, jobLog.Bytes()); err != nil {
+			logger.Printf("WARNING: Failed to create job log: %v", err)
+			logPath = ""
+		} else {
+			defer logFile.Close()
+			logger.SetOutput(io.MultiWriter(console, logFile))
+		}
 
-	// 1. Authenticate to get the auth token
-	log.Println("INFO: Authorizing Radiko token...")
-	_, err = radikoClient.AuthorizeToken(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to authorize Radiko token: %w", err)
+		record := HistoryRecord{
+			ID:          id,
+			LogPath:     logPath,
+			ProgramName: entry.ProgramName,
+			Title:       programName,
+			StationID:   entry.StationID,
+			Provider:    entry.Provider,
+			StartTime:   pastTime,
+			StartedAt:   time.Now(),
+			OutputPath:  outputFilePath,
+			Rerun:       opts.Rerun,
+			Guide:       guideProg,
+		}
+		defer func() {
+			record.OutputPath = outputFilePath // Disambiguation may have changed the name.
+			record.Size, record.SHA256, record.LocalRemoved = outputSize, outputSHA256, localRemoved
+			record.PendingUploads = pendingUploads
+			record.FinishedAt = time.Now()
+			record.Status = StatusSuccess
+			if err != nil {
+				record.Status = StatusFailed
+				record.Error = err.Error()
+				logger.Printf("ERROR: %v", err)
+			}
+			if histErr := opts.History.Append(record); histErr != nil {
+				logger.Printf("WARNING: Failed to write history: %v", histErr)
+			}
+			logAction(ActionRecord{Action: ActionJobFinished, Path: record.OutputPath, Detail: record.Status, Error: record.Error})
+		}()
+		logAction = func(action ActionRecord) {
+			action.JobID, action.ProgramName, action.StationID, action.Start = id, entry.ProgramName, entry.StationID, pastTime
+			if err := opts.History.LogAction(action); err != nil {
+				logger.Printf("WARNING: Failed to write the action log: %v", err)
+			}
+		}
+		logAction(ActionRecord{Action: ActionJobStarted, Path: outputFilePath})
+	}
+	// Deferred after the history record, so that the record already carries the reason.
+	defer func() {
+		if err != nil && errors.Is(context.Cause(ctx), errJobTimeout) {
+			err = fmt.Errorf("%w after %s: %w", errJobTimeout, timeout, err)
+		}
+	}()
+
+	if resumed != nil {
+		outputSize, outputSHA256 = resumed.Size, resumed.SHA256
+		if pendingUploads, err = uploadFiles(ctx, logger, opts.PostStore, resumed.PendingUploads, opts.DeleteLocal); err != nil {
+			return result, fmt.Errorf(opts.Output.Msg("failed to upload recording for %s: %w"), entry.ProgramName, err)
+		}
+		localRemoved = opts.DeleteLocal
+		if localRemoved {
+			logAction(ActionRecord{Action: ActionFileDeleted, Path: outputFilePath, Detail: "uploaded to the post-store"})
+		}
+		return result, nil
+	}
+
+	// 1. Authenticate with the provider, unless the caller already has. A token obtained
+	// earlier may have lapsed since, so it is renewed if the playlist request fails.
+	cachedToken := opts.Authenticated
+	if !opts.Authenticated {
+		logger.Println("INFO: Authenticating...")
+		if cachedToken, err = opts.authenticate(ctx, logger, provider); err != nil {
+			return result, fmt.Errorf(opts.Output.Msg("failed to authenticate: %w"), classify(ErrAuthFailed, err))
+		}
+		if cachedToken {
+			logger.Println("INFO: Authenticated with the cached token.")
+		} else {
+			logger.Println("INFO: Authenticated successfully.")
+		}
 	}
-	log.Println("INFO: Radiko token authorized successfully.")
 
 	// 2. Get M3U8 Playlist URI
-	log.Println("INFO: Getting M3U8 playlist URI...")
-	uri, err := radikoClient.TimeshiftPlaylistM3U8(ctx, entry.StationID, pastTime)
+	logger.Println("INFO: Getting M3U8 playlist URI...")
+	var uri string
+	resolve := func() error {
+		return opts.request(ctx, func(ctx context.Context) (err error) {
+			if opts.End.IsZero() {
+				uri, err = provider.ResolvePlaylist(ctx, entry.StationID, pastTime)
+			} else {
+				uri, err = resolveRangePlaylist(ctx, provider, entry.StationID, pastTime, opts.End)
+			}
+			return err
+		})
+	}
+	err = resolve()
+	if err != nil && cachedToken && ctx.Err() == nil {
+		// The earlier token may have expired, been revoked or been issued for another area.
+		logger.Printf("WARNING: Playlist request with the earlier token failed (%v); authenticating again.", err)
+		if err = opts.reauthenticate(ctx, logger, provider); err != nil {
+			return result, fmt.Errorf(opts.Output.Msg("failed to authenticate: %w"), classify(ErrAuthFailed, err))
+		}
+		err = resolve()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get timeshift M3U8 playlist URI for %s: %w", entry.ProgramName, err)
+		return result, fmt.Errorf(opts.Output.Msg("failed to get timeshift M3U8 playlist URI for %s: %w"), entry.ProgramName, err)
 	}
-	log.Printf("INFO: Got M3U8 URI: %s", uri)
+	logger.Printf("INFO: Got M3U8 URI: %s", uri)
 
 	// 3. Get Chunklist from M3U8
-	log.Println("INFO: Getting chunklist from M3U8...")
-	chunklist, err := radikoClient.GetChunklistFromM3U8(uri)
+	logger.Println("INFO: Getting chunklist from M3U8...")
+	err = opts.request(ctx, func(ctx context.Context) (err error) {
+		chunklist, err = listChunks(ctx, provider, uri)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get chunklist from M3U8 for %s: %w", entry.ProgramName, err)
+		return result, fmt.Errorf(opts.Output.Msg("failed to get chunklist from M3U8 for %s: %w"), entry.ProgramName, err)
 	}
-	log.Printf("INFO: Found %d audio chunks.", len(chunklist))
+	logDownloadEstimate(logger, entry.ProgramName, chunklist, expectedDuration(guideProg, pastTime, opts.End))
 
-	// 4. Create a temporary directory for downloading AAC chunks
-	tempDir, err := os.MkdirTemp("", "radikoRecScheduler-chunks-")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary directory: %w", err)
+	// 4. Create a temporary directory for downloading AAC chunks, unless they are kept in memory
+	spool := chunkSpool{MaxBytes: int64(opts.ChunkBufferMB) << 20, Pattern: opts.ChunkNamePattern, Keep: opts.KeepChunks, Logger: logger}
+	if spool.Keep {
+		spool.MaxBytes = 0
 	}
-	defer func() {
-		log.Printf("INFO: Cleaning up temporary directory: %s", tempDir)
-		if err := os.RemoveAll(tempDir); err != nil {
-			log.Printf("WARNING: Failed to remove temporary directory '%s': %v", tempDir, err)
+	if spool.MaxBytes > 0 {
+		logger.Printf("INFO: Keeping chunks in memory, up to %s each.", formatBytes(spool.MaxBytes))
+	} else {
+		if opts.TempDir != "" {
+			if err := os.MkdirAll(opts.TempDir, 0755); err != nil {
+				return result, fmt.Errorf(opts.Output.Msg("failed to create temporary directory: %w"), err)
+			}
 		}
-	}()
-	log.Printf("INFO: Created temporary directory: %s", tempDir)
+		tempDir, err := os.MkdirTemp(opts.TempDir, "radikoRecScheduler-chunks-")
+		if err != nil {
+			return result, fmt.Errorf(opts.Output.Msg("failed to create temporary directory: %w"), err)
+		}
+		defer func() {
+			if spool.Keep {
+				if err := spool.writeChunkIndex(chunklist); err != nil {
+					logger.Printf("WARNING: Failed to write the chunk index: %v", err)
+				}
+				logger.Printf("INFO: Keeping the chunks in: %s", tempDir)
+				return
+			}
+			logger.Printf("INFO: Cleaning up temporary directory: %s", tempDir)
+			if err := os.RemoveAll(tempDir); err != nil {
+				logger.Printf("WARNING: Failed to remove temporary directory '%s': %v", tempDir, err)
+			}
+		}()
+		logger.Printf("INFO: Created temporary directory: %s", tempDir)
+		spool.Dir = tempDir
+	}
 
-	// 5. Bulk download AAC files
-	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
-	s.Suffix = fmt.Sprintf(" Downloading %d chunks...", len(chunklist))
-	s.Start()
+	// 5. Download the AAC chunks, appending each to the recording as soon as it is verified,
+	// so a single chunk at a time is kept in the temporary directory (or memory). The recording is
+	// written to partial files first, so a failed run never leaves a truncated recording
+	// under the final name, and so it can be compared with an existing file.
+	if _, err := os.Stat(filepath.Dir(outputFilePath)); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(outputFilePath), 0755); err != nil {
+			return result, fmt.Errorf(opts.Output.Msg("failed to create output directory '%s': %w"), filepath.Dir(outputFilePath), err)
+		}
+	}
+	if err := checkFreeSpace(outputDir, recordingSpaceNeeded(chunklist)); err != nil {
+		return result, err
+	}
+
+	var subtitle string
+	if guideProg != nil {
+		subtitle = guideProg.SubTitle
+	}
+	rotation := opts.Rotation
+	if entry.SplitMinutes > 0 {
+		rotation.MaxMinutes = entry.SplitMinutes
+	}
+	base := outputFilePath
+	if rotation.enabled() && replaced == nil {
+		base = freeRecordingName(outputFilePath, opts.TitleCollision, pastTime, subtitle)
+	}
+	writer := newRecordingWriter(outputFilePath, base, rotation)
 
-	downloadedFiles, err := bulkDownload(ctx, radikoClient, chunklist, tempDir, s)
+	var progress Progress
+	if opts.Quiet || opts.DisableProgressBar {
+		progress = newLogProgress(logger, 30*time.Second, opts.Output)
+	} else {
+		progress = NewProgress(os.Stdout, logger, opts.Output)
+	}
+	if opts.OnProgress != nil {
+		progress = observedProgress{Progress: progress, observe: opts.OnProgress}
+	}
+	missing, err := streamDownload(ctx, provider, chunklist, spool, opts.ChunkCache, newJobLimiter(opts.ChunkRate, opts.RateLimit), opts.requestTimeout(), opts.maxMissingChunks(len(chunklist)), progress, writer.add)
+	progress.Done()
+	var parts []recordingPart
+	if err == nil {
+		parts, err = writer.close()
+	}
 	if err != nil {
-		s.Stop()
-		return fmt.Errorf("failed to bulk download AAC chunks for %s: %w", entry.ProgramName, err)
+		writer.remove()
+		return result, fmt.Errorf(opts.Output.Msg("failed to bulk download AAC chunks for %s: %w"), entry.ProgramName, err)
+	}
+	logger.Printf(opts.Output.Msg("INFO: Successfully downloaded %d AAC chunks."), len(chunklist)-len(missing))
+	var downloaded int64
+	for _, part := range parts {
+		downloaded += part.Size
+	}
+	logAction(ActionRecord{Action: ActionChunksDownloaded, Chunks: len(chunklist) - len(missing), Missing: len(missing), Bytes: downloaded})
+	var warnings []string
+	if len(missing) > 0 {
+		warnings = append(warnings, missingChunksWarning(chunklist, missing))
+		logger.Printf("WARNING: %s", warnings[0])
+	}
+	if err := opts.DurationCheck.verify(logger, parts, expectedDuration(guideProg, pastTime, opts.End)-missingDuration(chunklist, missing)); err != nil {
+		writer.remove()
+		return result, fmt.Errorf(opts.Output.Msg("incomplete recording of %s: %w"), entry.ProgramName, err)
 	}
-	s.Stop()
-	log.Printf("INFO: Successfully downloaded %d AAC chunks.", len(downloadedFiles))
 
-	// 6. Concatenate AAC files
-	log.Println("INFO: Concatenating AAC files...")
-	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			return fmt.Errorf("failed to create output directory '%s': %w", outputDir, err)
+	// 6. Move the recording into place
+	if len(parts) > 1 {
+		var prepare func(string) error
+		if entry.Normalize || convert {
+			prepare = func(path string) error {
+				if entry.Normalize {
+					opts.normalize(ctx, logger, path)
+				}
+				if convert {
+					return opts.convert(ctx, logger, path, entry, format, programName, pastTime)
+				}
+				return nil
+			}
+		}
+		playlist, err := saveRotatedRecording(parts, base, programName, prepare)
+		if err != nil {
+			writer.remove()
+			return result, fmt.Errorf(opts.Output.Msg("failed to save recording for %s: %w"), entry.ProgramName, err)
+		}
+		logger.Printf("INFO: Split the recording into %d parts.", len(parts))
+		outputFilePath = playlist
+	} else {
+		partial := parts[0].Path
+		if entry.Normalize {
+			opts.normalize(ctx, logger, partial)
+		}
+		if convert {
+			if err := opts.convert(ctx, logger, partial, entry, format, programName, pastTime); err != nil {
+				os.Remove(partial)
+				return result, fmt.Errorf(opts.Output.Msg("failed to transcode recording for %s: %w"), entry.ProgramName, err)
+			}
 		}
-	}
 
-	if err := concatAACFiles(downloadedFiles, outputFilePath); err != nil {
-		return fmt.Errorf("failed to concatenate AAC files for %s: %w", entry.ProgramName, err)
+		finalPath, identical := outputFilePath, false
+		if replaced != nil {
+			err = os.Rename(partial, outputFilePath)
+		} else {
+			finalPath, identical, err = placeRecording(partial, outputFilePath, opts.TitleCollision, pastTime, subtitle)
+		}
+		if err != nil {
+			os.Remove(partial)
+			return result, fmt.Errorf(opts.Output.Msg("failed to save recording for %s: %w"), entry.ProgramName, err)
+		}
+		if identical {
+			logger.Printf("INFO: An identical recording already exists, skipping: %s", finalPath)
+			outputFilePath = finalPath
+			skipped = true
+			return result, nil
+		}
+		if finalPath != outputFilePath {
+			logger.Printf("INFO: A different recording named %s already exists; saved as %s", filepath.Base(outputFilePath), filepath.Base(finalPath))
+			outputFilePath = finalPath
+		}
+	}
+	if replaced != nil {
+		removed, err := removeReplacedFiles(replaced, outputFilePath)
+		if err != nil {
+			logger.Printf("WARNING: Failed to remove files of the overwritten recording: %v", err)
+		}
+		for _, file := range removed {
+			logAction(ActionRecord{Action: ActionFileDeleted, Path: file, Detail: "overwritten"})
+		}
 	}
-	log.Printf("INFO: Successfully recorded and saved to: %s", outputFilePath)
+	logger.Printf(opts.Output.Msg("INFO: Successfully recorded and saved to: %s"), outputFilePath)
+	if size, sum, err := recordingDigest(ctx, outputFilePath); err != nil {
+		logger.Printf("WARNING: Failed to hash the recording: %v", err)
+	} else {
+		outputSize, outputSHA256 = size, hex.EncodeToString(sum)
+	}
+	logAction(ActionRecord{Action: ActionFileWritten, Path: outputFilePath, Bytes: outputSize})
 
-	return nil
-}
+	end := opts.End
+	if end.IsZero() && guideProg != nil {
+		end, _ = time.ParseInLocation("20060102150405", guideProg.To, JST)
+	}
+	var chaptersPath string
+	if opts.Chapters && programData != nil {
+		if !end.IsZero() {
+			var chaptersErr error
+			if chaptersPath, chaptersErr = writeChapters(programData, outputFilePath, entry.StationID, programName, pastTime, end); chaptersErr != nil {
+				logger.Printf("WARNING: Failed to write chapters: %v", chaptersErr)
+			} else if chaptersPath != "" {
+				logger.Printf("INFO: Wrote chapters to: %s", chaptersPath)
+			}
+		}
+	}
 
-// bulkDownload downloads a list of URLs to a specified directory.
-// It returns the list of paths to the downloaded files.
-func bulkDownload(ctx context.Context, client RadikoClient, urls []string, destDir string, s *spinner.Spinner) ([]string, error) {
-	downloadedFiles := make([]string, 0, len(urls))
-	for i, url := range urls {
-		s.Suffix = fmt.Sprintf(" Downloading chunk %d/%d...", i+1, len(urls)) // Update spinner suffix
-		fileName := fmt.Sprintf("chunk_%04d.aac", i)
-		filePath := filepath.Join(destDir, fileName)
+	var showNotesPath string
+	if opts.ShowNotes && guideProg != nil && hasShowNotes(*guideProg) {
+		var notesErr error
+		if showNotesPath, notesErr = writeShowNotes(outputFilePath, *guideProg, entry.StationID, pastTime, end); notesErr != nil {
+			logger.Printf("WARNING: Failed to write show notes: %v", notesErr)
+		} else {
+			logger.Printf("INFO: Wrote show notes to: %s", showNotesPath)
+		}
+	}
 
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request for chunk %d (%s): %w", i, url, err)
+	var nfoPath string
+	if opts.Layout == LayoutSeries {
+		var nfoErr error
+		if nfoPath, nfoErr = writeSeriesNFO(outputFilePath, entry.ProgramName, guideProg, entry.StationID, pastTime); nfoErr != nil {
+			logger.Printf("WARNING: Failed to write the NFO: %v", nfoErr)
+		} else {
+			logger.Printf("INFO: Wrote the NFO to: %s", nfoPath)
 		}
+	}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to download chunk %d (%s): %w", i, url, err)
+	transcripts := opts.Transcriber.transcribeRecording(ctx, logger, outputFilePath)
+
+	var manifestFile string
+	if outputSHA256 != "" {
+		audio := chunksDuration(chunklist)
+		if audio > 0 {
+			audio -= missingDuration(chunklist, missing)
+		}
+		manifest := Manifest{
+			Title:       programName,
+			ProgramName: entry.ProgramName,
+			StationID:   entry.StationID,
+			Provider:    entry.Provider,
+			Start:       pastTime,
+			End:         end,
+			Rerun:       opts.Rerun,
+			RecordedAt:  time.Now(),
+			Size:        outputSize,
+			SHA256:      outputSHA256,
+			Chunks:      len(chunklist),
+			Duration:    audio.Seconds(),
+			Warnings:    warnings,
+			Missing:     manifestGaps(chunklist, missing),
+			Chapters:    chaptersPath,
+			ShowNotes:   showNotesPath,
+			Guide:       guideProg,
+		}
+		var manifestErr error
+		if manifestFile, manifestErr = writeManifest(ctx, outputFilePath, manifest, transcripts); manifestErr != nil {
+			logger.Printf("WARNING: Failed to write the manifest: %v", manifestErr)
 		}
-		defer resp.Body.Close()
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("failed to download chunk %d (%s): HTTP status %d", i, url, resp.StatusCode)
+	// 7. Run the post_command, if configured. This happens before the upload so the
+	// command can still see (and transform) the local file. The recording is complete by
+	// now, so a failing command is only logged: failing the job would have its retry skip
+	// the recorded broadcast without ever running the command or the upload again.
+	if command := resolvePostCommand(entry, opts.PostCommand); command != "" {
+		info := PostCommandInfo{
+			FilePath:   outputFilePath,
+			Title:      programName,
+			StationID:  entry.StationID,
+			StartTime:  pastTime,
+			Entry:      entry,
+			Chapters:   chaptersPath,
+			Manifest:   manifestFile,
+			ShowNotes:  showNotesPath,
+			Transcript: transcripts[outputFilePath],
+		}
+		if err := runPostCommand(ctx, logger, command, info); err != nil {
+			logger.Printf("WARNING: %v; the recording is kept.", err)
 		}
+	}
 
-		file, err := os.Create(filePath)
+	// 8. Upload to the post-store, if configured. The files that could not be uploaded are
+	// recorded in the history, so the next attempt uploads them instead of skipping the broadcast.
+	if opts.PostStore != nil {
+		files, err := recordingUploads(outputFilePath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create file for chunk %d: %w", i, err)
+			return result, fmt.Errorf(opts.Output.Msg("failed to upload recording for %s: %w"), entry.ProgramName, err)
+		}
+		for _, file := range []string{chaptersPath, showNotesPath, nfoPath} {
+			if file != "" {
+				files = append(files, file)
+			}
 		}
-		defer file.Close()
+		files = append(files, slices.Sorted(maps.Values(transcripts))...)
+		if manifestFile != "" {
+			files = append(files, manifestFile)
+		}
+		if pendingUploads, err = uploadFiles(ctx, logger, opts.PostStore, files, opts.DeleteLocal); err != nil {
+			return result, fmt.Errorf(opts.Output.Msg("failed to upload recording for %s: %w"), entry.ProgramName, err)
+		}
+		localRemoved = opts.DeleteLocal
+		if localRemoved {
+			logAction(ActionRecord{Action: ActionFileDeleted, Path: outputFilePath, Detail: "uploaded to the post-store"})
+		}
+	}
 
-		if _, err := io.Copy(file, resp.Body); err != nil {
-			return nil, fmt.Errorf("failed to save chunk %d to file: %s: %w", i, url, err)
+	return result, nil
+}
+
+// jobSummary formats the one-line result of a job used in quiet mode.
+func jobSummary(out Output, entry ScheduleEntry, title string, pastTime time.Time, outputFilePath string, size int64, skipped bool, err error) string {
+	what := fmt.Sprintf("%s (%s %s)", title, entry.StationID, pastTime.Format("2006-01-02 15:04"))
+	switch {
+	case err != nil:
+		return fmt.Sprintf(out.Msg("FAIL %s: %v"), what, err)
+	case skipped:
+		return fmt.Sprintf(out.Msg("SKIP %s: already recorded"), what)
+	}
+	if size <= 0 {
+		return fmt.Sprintf(out.Msg("OK   %s -> %s"), what, outputFilePath)
+	}
+	return fmt.Sprintf(out.Msg("OK   %s -> %s (%s)"), what, outputFilePath, formatBytes(size))
+}
+
+// createJobLog creates the per-job log file and writes the lines captured so far.
+func createJobLog(logPath string, captured []byte) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(logPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(captured); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// uploadRecording sends a finished recording to the post-store and optionally removes the local copy.
+// A rotated recording is sent part by part, followed by its playlist.
+// Local files are kept whenever their upload fails.
+func uploadRecording(ctx context.Context, logger *log.Logger, store PostStore, filePath string, deleteLocal bool) error {
+	files, err := recordingUploads(filePath)
+	if err != nil {
+		return err
+	}
+	_, err = uploadFiles(ctx, logger, store, files, deleteLocal)
+	return err
+}
+
+// recordingUploads returns the files of the recording at path in upload order: the parts of a
+// rotated recording come before its playlist.
+func recordingUploads(path string) ([]string, error) {
+	if !isPlaylist(path) {
+		return []string{path}, nil
+	}
+	parts, err := recordingFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	return append(parts, path), nil
+}
+
+// uploadFiles sends files to the post-store in order, stopping at the first failed upload.
+// It returns the files left to upload, starting with the failed one; they are kept locally.
+func uploadFiles(ctx context.Context, logger *log.Logger, store PostStore, files []string, deleteLocal bool) ([]string, error) {
+	for i, file := range files {
+		if err := uploadFile(ctx, logger, store, file, deleteLocal); err != nil {
+			return files[i:], err
 		}
-		downloadedFiles = append(downloadedFiles, filePath)
 	}
-	return downloadedFiles, nil
+	return nil, nil
 }
 
-// concatAACFiles concatenates multiple AAC files into a single output file.
-func concatAACFiles(inputFiles []string, outputFile string) error {
-	outFile, err := os.Create(outputFile)
+// uploadFile sends a single file to the post-store and optionally removes the local copy.
+func uploadFile(ctx context.Context, logger *log.Logger, store PostStore, filePath string, deleteLocal bool) error {
+	logger.Printf("INFO: Uploading %s to post-store...", filePath)
+	location, err := store.Upload(ctx, filePath)
 	if err != nil {
-		return fmt.Errorf("failed to create output file '%s': %w", outputFile, err)
+		return err
 	}
-	defer outFile.Close()
+	logger.Printf("INFO: Uploaded to: %s", location)
 
-	for _, inFile := range inputFiles {
-		srcFile, err := os.Open(inFile)
-		if err != nil {
-			return fmt.Errorf("failed to open input file '%s': %w", inFile, err)
+	if deleteLocal {
+		if err := os.Remove(filePath); err != nil {
+			return fmt.Errorf("uploaded but failed to delete local file '%s': %w", filePath, err)
+		}
+		logger.Printf("INFO: Deleted local copy: %s", filePath)
+	}
+	return nil
+}
+
+// bulkDownload downloads a list of URLs to a specified directory and keeps the files.
+// It returns the list of paths to the downloaded files; see streamDownload.
+func bulkDownload(ctx context.Context, provider Provider, chunks []Chunk, destDir string, cache *Cache, limiter *RateLimiter, timeout time.Duration, progress Progress) ([]string, error) {
+	downloadedFiles := make([]string, 0, len(chunks))
+	_, err := streamDownload(ctx, provider, chunks, chunkSpool{Dir: destDir}, cache, limiter, timeout, 0, progress, func(c downloadedChunk) error {
+		downloadedFiles = append(downloadedFiles, c.Path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return downloadedFiles, nil
+}
+
+// streamDownload downloads chunks in order into spool and passes each verified chunk to
+// deliver, which may consume and remove it.
+// Each chunk is verified after download and re-downloaded up to maxChunkAttempts times if it is corrupt
+// or its download takes longer than timeout.
+// Chunks found in cache are used without downloading, and newly verified chunks are added to it.
+// Up to maxMissing chunks that cannot be recovered, whether corrupt or failing to download, are
+// left out and returned. Once more cannot be recovered, the remaining chunks are still checked
+// but no longer delivered, and a *ChunkIntegrityError listing every such chunk is returned.
+func streamDownload(ctx context.Context, provider Provider, chunks []Chunk, spool chunkSpool, cache *Cache, limiter *RateLimiter, timeout time.Duration, maxMissing int, progress Progress, deliver func(c downloadedChunk) error) ([]ChunkFailure, error) {
+	var failures []ChunkFailure
+	update := ProgressUpdate{ChunksTotal: len(chunks), AudioTotal: chunksDuration(chunks)}
+	advance := func(i int, size int64) {
+		update.ChunksDone = i + 1
+		update.BytesDone += size
+		update.AudioDone += chunks[i].Duration
+		progress.Update(update)
+	}
+	accept := func(i int, c downloadedChunk) error {
+		if len(failures) > maxMissing {
+			c.discard() // The recording is lost; only the remaining failures matter.
+			return nil
+		}
+		if err := deliver(c); err != nil {
+			return fmt.Errorf("failed to write chunk %d to the recording: %w", i, err)
+		}
+		return nil
+	}
+	for i, chunk := range chunks {
+		url := chunk.URL
+
+		if data, ok := cache.Get(url); ok {
+			c, err := spool.store(i, chunk, data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to write cached chunk %d: %w", i, err)
+			}
+			if c.verify() == nil {
+				advance(i, int64(len(data)))
+				if err := accept(i, c); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		var c downloadedChunk
+		var verifyErr error
+		var size int64
+		for attempt := 1; attempt <= maxChunkAttempts; attempt++ {
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+			var err error
+			c, err = spool.download(ctx, provider, i, chunk, timeout)
+			if err != nil {
+				// A stalled download is tried again with a fresh connection, and so is any other
+				// failure when chunks may be missing, before the chunk is given up.
+				if ctx.Err() == nil && attempt < maxChunkAttempts && (errors.Is(err, context.DeadlineExceeded) || maxMissing > 0) {
+					continue
+				}
+				if ctx.Err() != nil {
+					return nil, fmt.Errorf("failed to download chunk %d (%s): %w", i, url, err)
+				}
+				if maxMissing == 0 {
+					return nil, &ChunkDownloadError{Index: i, URL: url, Err: err}
+				}
+				verifyErr, size = err, 0
+				break
+			}
+			size = c.size()
+			if verifyErr = c.verify(); verifyErr == nil {
+				break
+			}
+		}
+		advance(i, size)
+		if verifyErr != nil {
+			c.discard()
+			failures = append(failures, ChunkFailure{Index: i, URL: url, Err: verifyErr})
+			continue
+		}
+		if cache.enabled() {
+			if data, err := c.bytes(); err == nil {
+				_ = cache.Put(url, data) // A failed cache write only costs a later re-download.
+			}
 		}
-		defer srcFile.Close() // Defer inside loop, but be careful with many files
+		if err := accept(i, c); err != nil {
+			return nil, err
+		}
+	}
+	if len(failures) > maxMissing {
+		return nil, &ChunkIntegrityError{Failures: failures}
+	}
+	return failures, nil
+}
+
+// downloadChunk fetches a single chunk into filePath, replacing any previous attempt,
+// giving up when it takes longer than timeout. It returns the number of bytes written.
+func downloadChunk(ctx context.Context, logger *log.Logger, provider Provider, url, filePath string, timeout time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	body, err := provider.Download(ctx, url)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		body.Close()
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	n, err := copyChunk(ctx, logger, provider, url, body, fileSink{file})
+	if err != nil {
+		return n, fmt.Errorf("failed to save chunk to file: %w", err)
+	}
+	return n, file.Close()
+}
+
+// downloadChunkData fetches a single chunk into memory, failing when it is larger than
+// maxBytes or takes longer than timeout.
+func downloadChunkData(ctx context.Context, logger *log.Logger, provider Provider, url string, maxBytes int64, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	body, err := provider.Download(ctx, url)
+	if err != nil {
+		return nil, err
+	}
 
-		if _, err := io.Copy(outFile, srcFile); err != nil {
-			return fmt.Errorf("failed to concatenate file '%s': %w", inFile, err)
+	sink := &memorySink{max: maxBytes}
+	if _, err := copyChunk(ctx, logger, provider, url, body, sink); err != nil {
+		if errors.Is(err, errChunkTooLarge) {
+			return nil, fmt.Errorf("chunk exceeds the in-memory limit of %s", formatBytes(maxBytes))
 		}
+		return nil, fmt.Errorf("failed to read chunk: %w", err)
+	}
+	return sink.buf.Bytes(), nil
+}
+
+// maxChunkResumes is how many times one download of a chunk is resumed after the connection
+// broke off, before the download fails and the chunk is retried from the start.
+const maxChunkResumes = 3
+
+// chunkSink receives the bytes of a chunk being downloaded. reset discards them when the
+// download starts over.
+type chunkSink interface {
+	io.Writer
+	reset() error
+}
+
+// fileSink writes a chunk to a file.
+type fileSink struct{ file *os.File }
+
+func (s fileSink) Write(p []byte) (int, error) { return s.file.Write(p) }
+
+func (s fileSink) reset() error {
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := s.file.Seek(0, io.SeekStart)
+	return err
+}
+
+var errChunkTooLarge = errors.New("chunk too large")
+
+// memorySink buffers a chunk of at most max bytes.
+type memorySink struct {
+	buf bytes.Buffer
+	max int64
+}
+
+func (s *memorySink) Write(p []byte) (int, error) {
+	if int64(s.buf.Len()+len(p)) > s.max {
+		return 0, errChunkTooLarge
 	}
-	log.Printf("INFO: Finished concatenating %d files.", len(inputFiles))
+	return s.buf.Write(p)
+}
+
+func (s *memorySink) reset() error {
+	s.buf.Reset()
 	return nil
 }
 
-// toEnglishDayOfWeek converts a Japanese day of the week to its English three-letter abbreviation.
-func toEnglishDayOfWeek(dayOfWeek string) (string, error) {
-	switch dayOfWeek {
-	case "日":
-		return "Sun", nil
-	case "月":
-		return "Mon", nil
-	case "火":
-		return "Tue", nil
-	case "水":
-		return "Wed", nil
-	case "木":
-		return "Thu", nil
-	case "金":
-		return "Fri", nil
-	case "土":
-		return "Sat", nil
-	default:
-		return "", fmt.Errorf("invalid day of week: %s", dayOfWeek)
+// readErrReader records the error of the reader it wraps, telling a broken download apart from
+// a failed write.
+type readErrReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *readErrReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// copyChunk copies body to sink and closes it. When reading body fails midway and provider is a
+// RangeDownloader, the download resumes at the first missing byte, or starts over when the
+// server ignores the range.
+func copyChunk(ctx context.Context, logger *log.Logger, provider Provider, url string, body io.ReadCloser, sink chunkSink) (int64, error) {
+	var written int64
+	for resumes := 0; ; resumes++ {
+		src := &readErrReader{r: body}
+		n, err := io.Copy(sink, src)
+		body.Close()
+		written += n
+		if err == nil {
+			return written, nil
+		}
+		ranger, ok := provider.(RangeDownloader)
+		if !ok || src.err == nil || ctx.Err() != nil || resumes == maxChunkResumes {
+			return written, err
+		}
+		logger.Printf("INFO: Resuming chunk %s at byte %d: %v", url, written, err)
+		var resumed bool
+		body, resumed, err = ranger.DownloadFrom(ctx, url, written)
+		if err != nil {
+			return written, err
+		}
+		if !resumed {
+			if err := sink.reset(); err != nil {
+				body.Close()
+				return 0, err
+			}
+			written = 0
+		}
 	}
 }
+
+// concatAACFiles concatenates multiple AAC files into a single output file.
+func concatAACFiles(inputFiles []string, outputFile string) error {
+	return concatFiles(inputFiles, outputFile, appendChunk)
+}
@@ -1,9 +1,12 @@
 package internal
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -11,50 +14,61 @@ import (
 	"strings"
 	"testing"
 	"time"
-
-	"github.com/briandowns/spinner"
 )
 
-// MockRadikoClient is a mock implementation of the RadikoClient interface for testing.
+// dummyAACChunk is a minimal chunk body: one ADTS header followed by filler payload.
+var dummyAACChunk = string([]byte{0xFF, 0xF1, 0x50, 0x80, 0x02, 0xFF, 0xFC}) + "DUMMY AAC CHUNK CONTENT"
+
+// MockRadikoClient is a mock implementation of the Provider interface for testing.
 type MockRadikoClient struct {
-	AuthTokenFn             func(ctx context.Context) (string, error)
-	TimeshiftPlaylistM3U8Fn func(ctx context.Context, stationID string, pastTime time.Time) (string, error)
-	GetChunklistFromM3U8Fn  func(uri string) ([]string, error)
-	DoFn                    func(req *http.Request) (*http.Response, error)
+	AuthenticateFn    func(ctx context.Context) error
+	ResolvePlaylistFn func(ctx context.Context, stationID string, pastTime time.Time) (string, error)
+	ListChunksFn      func(ctx context.Context, uri string) ([]string, error)
+	DoFn              func(req *http.Request) (*http.Response, error)
 }
 
-func (m *MockRadikoClient) AuthorizeToken(ctx context.Context) (string, error) {
-	if m.AuthTokenFn != nil {
-		return m.AuthTokenFn(ctx)
+func (m *MockRadikoClient) Authenticate(ctx context.Context) error {
+	if m.AuthenticateFn != nil {
+		return m.AuthenticateFn(ctx)
 	}
-	return "mock_auth_token", nil // Default success
+	return nil // Default success
 }
 
-func (m *MockRadikoClient) TimeshiftPlaylistM3U8(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
-	if m.TimeshiftPlaylistM3U8Fn != nil {
-		return m.TimeshiftPlaylistM3U8Fn(ctx, stationID, pastTime)
+func (m *MockRadikoClient) ResolvePlaylist(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+	if m.ResolvePlaylistFn != nil {
+		return m.ResolvePlaylistFn(ctx, stationID, pastTime)
 	}
 	return "http://mock.m3u8/playlist.m3u8", nil // Default success
 }
 
-func (m *MockRadikoClient) GetChunklistFromM3U8(uri string) ([]string, error) {
-	if m.GetChunklistFromM3U8Fn != nil {
-		return m.GetChunklistFromM3U8Fn(uri)
+func (m *MockRadikoClient) ListChunks(ctx context.Context, uri string) ([]string, error) {
+	if m.ListChunksFn != nil {
+		return m.ListChunksFn(ctx, uri)
 	}
 	return []string{"http://mock.chunk/chunk1.aac", "http://mock.chunk/chunk2.aac"}, nil // Default success
 }
 
-func (m *MockRadikoClient) Do(req *http.Request) (*http.Response, error) {
+// Download serves chunkURL through DoFn, or a dummy AAC chunk by default.
+func (m *MockRadikoClient) Download(ctx context.Context, chunkURL string) (io.ReadCloser, error) {
+	return httpGet(ctx, doerFunc(m.do), chunkURL)
+}
+
+func (m *MockRadikoClient) do(req *http.Request) (*http.Response, error) {
 	if m.DoFn != nil {
 		return m.DoFn(req)
 	}
 	// Default mock HTTP response for successful download
 	return &http.Response{
 		StatusCode: http.StatusOK,
-		Body:       io.NopCloser(strings.NewReader("DUMMY AAC CHUNK CONTENT")),
+		Body:       io.NopCloser(strings.NewReader(dummyAACChunk)),
 	}, nil
 }
 
+// doerFunc adapts a function to httpDoer.
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
 func TestExecuteJob(t *testing.T) {
 	mockNow := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST) // Tuesday
 
@@ -66,15 +80,16 @@ func TestExecuteJob(t *testing.T) {
 		outputDir     string
 		expectError   bool
 		expectedError string
+		class         error // Failure class of the error, see errors.go.
 	}{
 		{
 			name: "Successful execution",
 			mockClient: &MockRadikoClient{
-				AuthTokenFn: func(ctx context.Context) (string, error) { return "mock_auth_token", nil },
-				TimeshiftPlaylistM3U8Fn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+				AuthenticateFn: func(ctx context.Context) error { return nil },
+				ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
 					return "http://mock.m3u8/playlist.m3u8", nil
 				},
-				GetChunklistFromM3U8Fn: func(uri string) ([]string, error) {
+				ListChunksFn: func(ctx context.Context, uri string) ([]string, error) {
 					return []string{
 						"http://mock.chunk/chunk1.aac",
 						"http://mock.chunk/chunk2.aac",
@@ -83,7 +98,7 @@ func TestExecuteJob(t *testing.T) {
 				DoFn: func(req *http.Request) (*http.Response, error) {
 					return &http.Response{
 						StatusCode: http.StatusOK,
-						Body:       io.NopCloser(strings.NewReader("DUMMY AAC CHUNK CONTENT")),
+						Body:       io.NopCloser(strings.NewReader(dummyAACChunk)),
 					}, nil
 				},
 			},
@@ -98,7 +113,7 @@ func TestExecuteJob(t *testing.T) {
 		{
 			name: "Authentication failure",
 			mockClient: &MockRadikoClient{
-				AuthTokenFn: func(ctx context.Context) (string, error) { return "", fmt.Errorf("auth failed") },
+				AuthenticateFn: func(ctx context.Context) error { return fmt.Errorf("auth failed") },
 			},
 			entry: ScheduleEntry{
 				ProgramName: "Test Program",
@@ -107,13 +122,14 @@ func TestExecuteJob(t *testing.T) {
 			pastTime:      mockNow,
 			outputDir:     "output",
 			expectError:   true,
-			expectedError: "failed to authorize Radiko token: auth failed",
+			expectedError: "failed to authenticate: auth failed",
+			class:         ErrAuthFailed,
 		},
 		{
-			name: "TimeshiftPlaylistM3U8 failure",
+			name: "ResolvePlaylist failure",
 			mockClient: &MockRadikoClient{
-				AuthTokenFn: func(ctx context.Context) (string, error) { return "mock_auth_token", nil },
-				TimeshiftPlaylistM3U8Fn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+				AuthenticateFn: func(ctx context.Context) error { return nil },
+				ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
 					return "", fmt.Errorf("m3u8 failed")
 				},
 			},
@@ -127,13 +143,31 @@ func TestExecuteJob(t *testing.T) {
 			expectedError: "failed to get timeshift M3U8 playlist URI for Test Program: m3u8 failed",
 		},
 		{
-			name: "GetChunklistFromM3U8 failure",
+			name: "Unknown station",
+			mockClient: &MockRadikoClient{
+				AuthenticateFn: func(ctx context.Context) error { return nil },
+				ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+					return "", fmt.Errorf("%w: %s", ErrStationNotFound, stationID)
+				},
+			},
+			entry: ScheduleEntry{
+				ProgramName: "Test Program",
+				StationID:   "NONE",
+			},
+			pastTime:      mockNow,
+			outputDir:     "output",
+			expectError:   true,
+			expectedError: "station not found: NONE",
+			class:         ErrStationNotFound,
+		},
+		{
+			name: "ListChunks failure",
 			mockClient: &MockRadikoClient{
-				AuthTokenFn: func(ctx context.Context) (string, error) { return "mock_auth_token", nil },
-				TimeshiftPlaylistM3U8Fn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+				AuthenticateFn: func(ctx context.Context) error { return nil },
+				ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
 					return "http://mock.m3u8/playlist.m3u8", nil
 				},
-				GetChunklistFromM3U8Fn: func(uri string) ([]string, error) {
+				ListChunksFn: func(ctx context.Context, uri string) ([]string, error) {
 					return nil, fmt.Errorf("chunklist failed")
 				},
 			},
@@ -149,11 +183,11 @@ func TestExecuteJob(t *testing.T) {
 		{
 			name: "Bulk download failure (HTTP error)",
 			mockClient: &MockRadikoClient{
-				AuthTokenFn: func(ctx context.Context) (string, error) { return "mock_auth_token", nil },
-				TimeshiftPlaylistM3U8Fn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+				AuthenticateFn: func(ctx context.Context) error { return nil },
+				ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
 					return "http://mock.m3u8/playlist.m3u8", nil
 				},
-				GetChunklistFromM3U8Fn: func(uri string) ([]string, error) {
+				ListChunksFn: func(ctx context.Context, uri string) ([]string, error) {
 					return []string{"http://mock.chunk/chunk1.aac"}, nil
 				},
 				DoFn: func(req *http.Request) (*http.Response, error) {
@@ -171,15 +205,16 @@ func TestExecuteJob(t *testing.T) {
 			outputDir:     "output",
 			expectError:   true,
 			expectedError: "failed to bulk download AAC chunks for Test Program: failed to download chunk 0 (http://mock.chunk/chunk1.aac): HTTP status 500",
+			class:         ErrChunkDownload,
 		},
 		{
 			name: "Bulk download failure (network error)",
 			mockClient: &MockRadikoClient{
-				AuthTokenFn: func(ctx context.Context) (string, error) { return "mock_auth_token", nil },
-				TimeshiftPlaylistM3U8Fn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+				AuthenticateFn: func(ctx context.Context) error { return nil },
+				ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
 					return "http://mock.m3u8/playlist.m3u8", nil
 				},
-				GetChunklistFromM3U8Fn: func(uri string) ([]string, error) {
+				ListChunksFn: func(ctx context.Context, uri string) ([]string, error) {
 					return []string{"http://mock.chunk/chunk1.aac"}, nil
 				},
 				DoFn: func(req *http.Request) (*http.Response, error) {
@@ -194,6 +229,7 @@ func TestExecuteJob(t *testing.T) {
 			outputDir:     "output",
 			expectError:   true,
 			expectedError: "failed to bulk download AAC chunks for Test Program: failed to download chunk 0 (http://mock.chunk/chunk1.aac): network error",
+			class:         ErrChunkDownload,
 		},
 	}
 
@@ -206,7 +242,7 @@ func TestExecuteJob(t *testing.T) {
 			}
 			defer os.RemoveAll(tempOutputDir)
 
-			err = ExecuteJob(tt.mockClient, tt.entry, tt.pastTime, tempOutputDir)
+			_, err = ExecuteJob(context.Background(), tt.mockClient, tt.entry, tt.pastTime, JobOptions{OutputDir: tempOutputDir})
 
 			if tt.expectError {
 				if err == nil {
@@ -214,6 +250,13 @@ func TestExecuteJob(t *testing.T) {
 				} else if !strings.Contains(err.Error(), tt.expectedError) {
 					t.Errorf("for %s, expected error containing '%s', but got '%v'", tt.name, tt.expectedError, err)
 				}
+				if tt.class != nil && !errors.Is(err, tt.class) {
+					t.Errorf("for %s, expected an error of class %v, got %v", tt.name, tt.class, err)
+				}
+				var chunkErr *ChunkDownloadError
+				if errors.As(err, &chunkErr) != (tt.class == ErrChunkDownload) {
+					t.Errorf("for %s, errors.As(*ChunkDownloadError) = %v", tt.name, chunkErr)
+				}
 			} else {
 				if err != nil {
 					t.Errorf("did not expect an error for %s, but got: %v", tt.name, err)
@@ -244,7 +287,7 @@ func TestBulkDownload(t *testing.T) {
 		if strings.HasSuffix(r.URL.Path, ".aac") {
 			w.WriteHeader(http.StatusOK)
 			// Serve a small dummy AAC content
-			_, _ = w.Write([]byte("DUMMY AAC CHUNK CONTENT"))
+			_, _ = w.Write([]byte(dummyAACChunk))
 		} else {
 			w.WriteHeader(http.StatusNotFound)
 		}
@@ -259,18 +302,16 @@ func TestBulkDownload(t *testing.T) {
 	}
 
 	// Prepare a chunklist with URLs from the mock server
-	chunklist := []string{
-		fmt.Sprintf("%s/chunk1.aac", mockServer.URL),
-		fmt.Sprintf("%s/chunk2.aac", mockServer.URL),
-		fmt.Sprintf("%s/chunk3.aac", mockServer.URL),
+	chunklist := []Chunk{
+		{URL: fmt.Sprintf("%s/chunk1.aac", mockServer.URL)},
+		{URL: fmt.Sprintf("%s/chunk2.aac", mockServer.URL)},
+		{URL: fmt.Sprintf("%s/chunk3.aac", mockServer.URL)},
 	}
 
 	ctx := context.Background()
-	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond) // Mock spinner
-	s.Start()
-	defer s.Stop()
+	progress := newLogProgress(log.New(io.Discard, "", 0), time.Minute, Output{})
 
-	downloadedFiles, err := bulkDownload(ctx, mockClient, chunklist, tempDir, s)
+	downloadedFiles, err := bulkDownload(ctx, mockClient, chunklist, tempDir, nil, nil, time.Minute, progress)
 	if err != nil {
 		t.Fatalf("bulkDownload failed: %v", err)
 	}
@@ -288,7 +329,7 @@ func TestBulkDownload(t *testing.T) {
 		if err != nil {
 			t.Errorf("Failed to read downloaded file %s: %v", file, err)
 		}
-		if string(content) != "DUMMY AAC CHUNK CONTENT" {
+		if string(content) != dummyAACChunk {
 			t.Errorf("Downloaded file %s has wrong content: %s", file, string(content))
 		}
 	}
@@ -361,3 +402,225 @@ func TestConcatAACFiles(t *testing.T) {
 		t.Errorf("concatAACFiles returned wrong error type for output creation failure: %v", err)
 	}
 }
+
+func TestExecuteJobQuiet(t *testing.T) {
+	var logBuf bytes.Buffer
+	outputDir := t.TempDir()
+	entry := ScheduleEntry{ProgramName: "Test Program", StationID: "ST1"}
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	opts := JobOptions{OutputDir: outputDir, Logger: log.New(&logBuf, "", 0), Quiet: true}
+
+	if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+	if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err != nil {
+		t.Fatalf("ExecuteJob (second run) failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logBuf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one summary line per job, got:\n%s", logBuf.String())
+	}
+	if !strings.HasPrefix(lines[0], "OK   Test Program (ST1 2026-01-12 10:00) -> ") {
+		t.Errorf("unexpected success summary: %s", lines[0])
+	}
+	if lines[1] != "SKIP Test Program (ST1 2026-01-12 10:00): already recorded" {
+		t.Errorf("unexpected skip summary: %s", lines[1])
+	}
+}
+
+func TestExecuteJobTimeout(t *testing.T) {
+	client := &MockRadikoClient{
+		ResolvePlaylistFn: func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+			<-ctx.Done() // The playlist never arrives.
+			return "", ctx.Err()
+		},
+	}
+	opts := JobOptions{
+		OutputDir:  t.TempDir(),
+		Logger:     log.New(io.Discard, "", 0),
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+		JobTimeout: 20 * time.Millisecond,
+	}
+	entry := ScheduleEntry{ProgramName: "Test Program", StationID: "ST1", DayOfWeek: "月", StartTime: "100000"}
+
+	_, err := ExecuteJob(context.Background(), client, entry, time.Date(2026, time.January, 12, 10, 0, 0, 0, JST), opts)
+	if !errors.Is(err, errJobTimeout) || !strings.Contains(err.Error(), "job timed out after 20ms") {
+		t.Errorf("expected a job timeout error, got %v", err)
+	}
+}
+
+func TestExecuteJobStreamsChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		broken string // Chunk answered with an error page.
+	}{
+		{"complete", ""},
+		{"corrupt chunk", "chunk3.aac"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir, outputDir := t.TempDir(), t.TempDir()
+			kept := 0 // Most chunk files found in the temporary directory during a download.
+			client := &MockRadikoClient{
+				ListChunksFn: func(ctx context.Context, uri string) ([]string, error) {
+					return []string{"http://mock.chunk/chunk1.aac", "http://mock.chunk/chunk2.aac", "http://mock.chunk/chunk3.aac", "http://mock.chunk/chunk4.aac"}, nil
+				},
+				DoFn: func(req *http.Request) (*http.Response, error) {
+					files, _ := filepath.Glob(filepath.Join(tempDir, "*", "chunk_*.aac"))
+					kept = max(kept, len(files))
+					body := dummyAACChunk
+					if tt.broken != "" && strings.HasSuffix(req.URL.Path, tt.broken) {
+						body = "<html>error</html>"
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+				},
+			}
+			opts := JobOptions{
+				OutputDir:  outputDir,
+				TempDir:    tempDir,
+				Logger:     log.New(io.Discard, "", 0),
+				FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+				DisableProgressBar: true,
+			}
+			entry := ScheduleEntry{ProgramName: "Test Program", StationID: "ST1"}
+
+			_, err := ExecuteJob(context.Background(), client, entry, time.Date(2026, time.January, 12, 10, 0, 0, 0, JST), opts)
+			if kept > 1 { // The chunk being retried stays until it is replaced.
+				t.Errorf("expected chunks to be removed once appended, found %d in the temporary directory", kept)
+			}
+			if tt.broken != "" {
+				var integrityErr *ChunkIntegrityError
+				if !errors.As(err, &integrityErr) {
+					t.Fatalf("expected *ChunkIntegrityError, got %v", err)
+				}
+				if left, _ := os.ReadDir(outputDir); len(left) != 0 {
+					t.Errorf("expected no partial recording after a failed download, found %v", left)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExecuteJob failed: %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(outputDir, "20260112100000-ST1-Test Program.aac"))
+			if err != nil || string(data) != strings.Repeat(dummyAACChunk, 4) {
+				t.Errorf("unexpected recording %q (%v)", data, err)
+			}
+		})
+	}
+}
+
+func TestExecuteJobInMemory(t *testing.T) {
+	tempDir := filepath.Join(t.TempDir(), "unused")
+	outputDir := t.TempDir()
+	opts := JobOptions{
+		OutputDir:     outputDir,
+		TempDir:       tempDir,
+		ChunkBufferMB: 1,
+		Logger:        log.New(io.Discard, "", 0),
+		FetchGuide:    func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+		DisableProgressBar: true,
+	}
+	entry := ScheduleEntry{ProgramName: "Test Program", StationID: "ST1"}
+	if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, time.Date(2026, time.January, 12, 10, 0, 0, 0, JST), opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+
+	if _, err := os.Stat(tempDir); !os.IsNotExist(err) {
+		t.Errorf("expected no temporary directory, got %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(outputDir, "20260112100000-ST1-Test Program.aac")); err != nil || !strings.Contains(string(data), dummyAACChunk) {
+		t.Errorf("unexpected recording %q (%v)", data, err)
+	}
+}
+
+func TestExecuteJobManyChunks(t *testing.T) {
+	const count = 3000 // A little over four hours of radiko's 5-second chunks.
+	urls := make([]string, count)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("http://mock.chunk/chunk%d.aac", i)
+	}
+	before := openFiles(t)
+	peak := 0
+	client := &MockRadikoClient{
+		ListChunksFn: func(ctx context.Context, uri string) ([]string, error) { return urls, nil },
+		DoFn: func(req *http.Request) (*http.Response, error) {
+			peak = max(peak, openFiles(t))
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(dummyAACChunk))}, nil
+		},
+	}
+	outputDir := t.TempDir()
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		Logger:     log.New(io.Discard, "", 0),
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+		DisableProgressBar: true,
+	}
+	entry := ScheduleEntry{ProgramName: "Test Program", StationID: "ST1"}
+	if _, err := ExecuteJob(context.Background(), client, entry, time.Date(2026, time.January, 12, 10, 0, 0, 0, JST), opts); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+
+	// The partial recording, the job log and the descriptor reading /proc/self/fd.
+	if peak-before > 4 {
+		t.Errorf("expected a steady number of open files, %d more were open during the downloads", peak-before)
+	}
+	if after := openFiles(t); after != before {
+		t.Errorf("expected %d open files after the job, got %d", before, after)
+	}
+	info, err := os.Stat(filepath.Join(outputDir, "20260112100000-ST1-Test Program.aac"))
+	if err != nil || info.Size() != int64(count*len(dummyAACChunk)) {
+		t.Errorf("unexpected recording: %v (%v)", info, err)
+	}
+}
+
+func TestExecuteJobResult(t *testing.T) {
+	client := &timedMockClient{chunks: []Chunk{
+		{URL: "http://mock.chunk/chunk1.aac", Duration: 5 * time.Second},
+		{URL: "http://mock.chunk/chunk2.aac", Duration: 5 * time.Second},
+	}}
+	outputDir := t.TempDir()
+	opts := JobOptions{
+		OutputDir:  outputDir,
+		Logger:     log.New(io.Discard, "", 0),
+		History:    OpenHistory(filepath.Join(t.TempDir(), "history.jsonl")),
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+		DisableProgressBar: true,
+	}
+	entry := ScheduleEntry{ProgramName: "Test Program", StationID: "ST1"}
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+
+	result, err := ExecuteJob(context.Background(), client, entry, pastTime, opts)
+	if err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+	want := RecordingResult{
+		OutputPath: filepath.Join(outputDir, "20260112100000-ST1-Test Program.aac"),
+		Title:      "Test Program",
+		Start:      pastTime,
+		Size:       int64(2 * len(dummyAACChunk)),
+		Chunks:     2,
+		Duration:   10 * time.Second,
+	}
+	if result != want {
+		t.Errorf("ExecuteJob = %+v, want %+v", result, want)
+	}
+
+	result, err = ExecuteJob(context.Background(), client, entry, pastTime, opts)
+	if err != nil || !result.Skipped || result.OutputPath != want.OutputPath || result.Chunks != 0 {
+		t.Errorf("expected the second run to skip %s, got %+v (%v)", want.OutputPath, result, err)
+	}
+
+	opts.Overwrite = true
+	client.chunks = nil
+	client.MockRadikoClient.ResolvePlaylistFn = func(ctx context.Context, stationID string, pastTime time.Time) (string, error) {
+		return "", errors.New("HTTP status 404")
+	}
+	if result, err = ExecuteJob(context.Background(), client, entry, pastTime, opts); err == nil || result.OutputPath != "" || result.Title != "Test Program" {
+		t.Errorf("expected a failure with only the title, got %+v (%v)", result, err)
+	}
+}
@@ -1,20 +1,235 @@
 package internal
 
 import (
+	"bytes"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
 )
 
-// ScheduleEntry corresponds to an entry in the schedule.json file.
+// ScheduleEntry corresponds to an entry in the schedule file.
 type ScheduleEntry struct {
-	ProgramName string `json:"program_name"`
-	DayOfWeek   string `json:"day_of_week"`
-	StartTime   string `json:"start_time"`
-	StationID   string `json:"station_id"`
+	ProgramName string `json:"program_name" yaml:"program_name" toml:"program_name"`
+	DayOfWeek   string `json:"day_of_week,omitempty" yaml:"day_of_week,omitempty" toml:"day_of_week,omitempty"`
+	StartTime   string `json:"start_time" yaml:"start_time" toml:"start_time"`
+	StationID   string `json:"station_id" yaml:"station_id" toml:"station_id"`
+	PostCommand string `json:"post_command,omitempty" yaml:"post_command,omitempty" toml:"post_command,omitempty"` // Overrides the global post_command for this entry.
+	Provider    string `json:"provider,omitempty" yaml:"provider,omitempty" toml:"provider,omitempty"`             // ProviderRadiko (default) or ProviderRadiru.
+
+	// Rerun is an optional official rerun slot, recorded instead when the primary broadcast cannot be.
+	Rerun *RerunSlot `json:"rerun,omitempty" yaml:"rerun,omitempty" toml:"rerun,omitempty"`
+
+	// FallbackStations are stations that air the same program in the same slot, e.g. network
+	// affiliates, tried in order when recording it from StationID fails.
+	FallbackStations []string `json:"fallback_stations,omitempty" yaml:"fallback_stations,omitempty" toml:"fallback_stations,omitempty"`
+
+	// ShiftWindow is how many minutes before or after the slot the guide is searched for the
+	// program when it was moved, e.g. by a baseball extension. 0 only looks at the slot itself.
+	ShiftWindow int `json:"shift_window,omitempty" yaml:"shift_window,omitempty" toml:"shift_window,omitempty"`
+
+	// Enabled set to false pauses the entry, e.g. a seasonal show off the air, without removing it.
+	// Entries are enabled when it is omitted.
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty" toml:"enabled,omitempty"`
+	// Note is a free-form comment on the entry, shown by the schedule command.
+	Note string `json:"note,omitempty" yaml:"note,omitempty" toml:"note,omitempty"`
+	// Priority orders the jobs of a run: when more broadcasts are due than can be recorded at
+	// once, e.g. catching up after downtime, entries with a higher priority are downloaded first.
+	// Entries of the same priority keep their order; the default is 0.
+	Priority int `json:"priority,omitempty" yaml:"priority,omitempty" toml:"priority,omitempty"`
+
+	// SplitMinutes saves recordings longer than this many minutes as parts of at most this length,
+	// listed in a playlist, overriding rotation.max_minutes of config.json. 0 keeps the global setting.
+	SplitMinutes int `json:"split_minutes,omitempty" yaml:"split_minutes,omitempty" toml:"split_minutes,omitempty"`
+	// TimeoutMinutes limits each recording of the entry, overriding job_timeout_minutes of
+	// config.json, e.g. for a long live event. 0 keeps the global setting.
+	TimeoutMinutes int `json:"timeout_minutes,omitempty" yaml:"timeout_minutes,omitempty" toml:"timeout_minutes,omitempty"`
+
+	// Date makes the entry a one-shot for a special: the broadcast on this day (YYYY-MM-DD, JST)
+	// is recorded once instead of every week. DayOfWeek may then be omitted.
+	Date string `json:"date,omitempty" yaml:"date,omitempty" toml:"date,omitempty"`
+
+	// WeeksOfMonth limits the entry to the nth DayOfWeek of each month (1-5, -1 for the last),
+	// e.g. [1, 3] for a program on the 1st and 3rd Tuesday.
+	WeeksOfMonth []int `json:"weeks_of_month,omitempty" yaml:"weeks_of_month,omitempty" toml:"weeks_of_month,omitempty"`
+	// EveryWeeks records the entry every this many weeks, e.g. 2 for a biweekly program,
+	// counted from its broadcast on Date, which then is the first one rather than a one-shot.
+	EveryWeeks int `json:"every_weeks,omitempty" yaml:"every_weeks,omitempty" toml:"every_weeks,omitempty"`
+
+	// SkipDates are days (YYYY-MM-DD, JST) whose broadcast is not recorded, e.g. when a special
+	// replaces the program. SkipHolidays skips Japanese public holidays the same way.
+	SkipDates    []string `json:"skip_dates,omitempty" yaml:"skip_dates,omitempty" toml:"skip_dates,omitempty"`
+	SkipHolidays bool     `json:"skip_holidays,omitempty" yaml:"skip_holidays,omitempty" toml:"skip_holidays,omitempty"`
+
+	// Normalize runs an EBU R128 loudness normalization (ffmpeg's loudnorm) on the recording,
+	// evening out the levels of different stations.
+	Normalize bool `json:"normalize,omitempty" yaml:"normalize,omitempty" toml:"normalize,omitempty"`
+	// Archive transcodes the finished recording with ffmpeg for long-term storage: ArchiveOpus
+	// for small files, or ArchiveFLAC. Empty keeps the AAC as downloaded.
+	Archive string `json:"archive,omitempty" yaml:"archive,omitempty" toml:"archive,omitempty"`
+}
+
+// IsEnabled reports whether the entry is recorded, see Enabled.
+func (e ScheduleEntry) IsEnabled() bool {
+	return e.Enabled == nil || *e.Enabled
+}
+
+// OneShot reports whether the entry records a single broadcast on Date.
+func (e ScheduleEntry) OneShot() bool {
+	return e.Date != "" && e.EveryWeeks == 0
+}
+
+// slotKey identifies the broadcasts entry records: its provider, station, day and time, and
+// how often it repeats. Entries with the same key would download the same broadcasts to the
+// same file.
+func slotKey(entry ScheduleEntry) string {
+	provider := entry.Provider
+	if provider == "" {
+		provider = ProviderRadiko
+	}
+	day := entry.DayOfWeek
+	if entry.Date != "" {
+		day = entry.Date // The date decides the day of the week.
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%d|%v", provider, strings.TrimSpace(entry.StationID), day, entry.StartTime, entry.EveryWeeks, entry.WeeksOfMonth)
+}
+
+// ScheduleFilter selects entries of a schedule, e.g. to record a single program from the command line.
+// Empty fields select every entry.
+type ScheduleFilter struct {
+	ProgramName string         // program_name of the entries, ignoring case.
+	Stations    []string       // station_id of the entries.
+	Weekdays    []time.Weekday // Days the entries air on, from day_of_week or date.
+}
+
+// Match reports whether f selects entry.
+func (f ScheduleFilter) Match(entry ScheduleEntry) bool {
+	if f.ProgramName != "" && !strings.EqualFold(f.ProgramName, entry.ProgramName) {
+		return false
+	}
+	if len(f.Stations) > 0 && !slices.Contains(f.Stations, entry.StationID) {
+		return false
+	}
+	if len(f.Weekdays) > 0 {
+		day, ok := entryWeekday(entry)
+		return ok && slices.Contains(f.Weekdays, day)
+	}
+	return true
+}
+
+// entryWeekday returns the day of the week entry airs on. The date of a one-shot decides it.
+func entryWeekday(entry ScheduleEntry) (time.Weekday, bool) {
+	if entry.Date != "" {
+		date, err := time.ParseInLocation("2006-01-02", entry.Date, JST)
+		return date.Weekday(), err == nil
+	}
+	day, ok := DayOfWeekMap[entry.DayOfWeek]
+	return day, ok
+}
+
+// FilterSchedule returns the entries of schedule that f selects.
+func FilterSchedule(schedule []ScheduleEntry, f ScheduleFilter) []ScheduleEntry {
+	var selected []ScheduleEntry
+	for _, entry := range schedule {
+		if f.Match(entry) {
+			selected = append(selected, entry)
+		}
+	}
+	return selected
+}
+
+// MergeDuplicates returns entries without the enabled entries that record the same slot as an
+// earlier one, along with a warning for each entry left out. Identical copies are merged
+// into the first; an entry that differs in other settings, e.g. its program name or
+// post_command, is still left out, since both would download the broadcast to the same file.
+// Disabled entries are kept as they are.
+func MergeDuplicates(entries []ScheduleEntry) ([]ScheduleEntry, []string) {
+	var (
+		merged   []ScheduleEntry
+		warnings []string
+	)
+	first := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		if !entry.IsEnabled() {
+			merged = append(merged, entry)
+			continue
+		}
+		key := slotKey(entry)
+		i, ok := first[key]
+		if !ok {
+			first[key] = len(merged)
+			merged = append(merged, entry)
+			continue
+		}
+		slot := entry.StationID + " " + entry.DayOfWeek + " " + entry.StartTime
+		if entry.Date != "" {
+			slot = entry.StationID + " " + entry.Date + " " + entry.StartTime
+		}
+		if reflect.DeepEqual(entry, merged[i]) {
+			warnings = append(warnings, fmt.Sprintf("'%s' (%s) is in the schedule twice; recording it once.", entry.ProgramName, slot))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("'%s' records the same broadcasts as '%s' (%s); ignoring it.", entry.ProgramName, merged[i].ProgramName, slot))
+		}
+	}
+	return merged, warnings
+}
+
+// RerunSlot is the weekly slot of a program's rerun (再放送).
+type RerunSlot struct {
+	DayOfWeek string `json:"day_of_week" yaml:"day_of_week" toml:"day_of_week"`
+	StartTime string `json:"start_time" yaml:"start_time" toml:"start_time"`
+	StationID string `json:"station_id,omitempty" yaml:"station_id,omitempty" toml:"station_id,omitempty"` // Defaults to the entry's station.
+}
+
+// ScheduleFileNames lists the schedule file names looked up in a directory, in order of preference.
+var ScheduleFileNames = []string{"schedule.json", "schedule.yaml", "schedule.yml", "schedule.toml"}
+
+// FindScheduleFile returns the first schedule file (see ScheduleFileNames) that exists in dir.
+// If none exists, the path of schedule.json is returned.
+func FindScheduleFile(dir string) string {
+	for _, name := range ScheduleFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, ScheduleFileNames[0])
+}
+
+// scheduleSchema is the JSON Schema of schedule.json, printed by the schedule schema command.
+//
+//go:embed schedule.schema.json
+var scheduleSchema []byte
+
+// ScheduleSchema returns the JSON Schema describing schedule.json, for editors to validate
+// and complete the file.
+func ScheduleSchema() []byte {
+	return bytes.Clone(scheduleSchema)
+}
+
+// tomlSchedule is the layout of schedule.toml. TOML documents must be tables,
+// so entries are written as [[schedule]] array-of-tables.
+type tomlSchedule struct {
+	Schedule []ScheduleEntry `toml:"schedule"`
 }
 
 // LoadSchedule reads and parses the schedule file from the given path.
+// The format is chosen by extension: .yaml/.yml for YAML, .toml for TOML, and JSON otherwise.
+// Keys that are not fields of ScheduleEntry are rejected, so a misspelled key is reported
+// instead of being left out silently.
 func LoadSchedule(filePath string) ([]ScheduleEntry, error) {
 	file, err := os.ReadFile(filePath)
 	if err != nil {
@@ -22,9 +237,71 @@ func LoadSchedule(filePath string) ([]ScheduleEntry, error) {
 	}
 
 	var scheduleEntries []ScheduleEntry
-	if err := json.Unmarshal(file, &scheduleEntries); err != nil {
-		return nil, fmt.Errorf("error parsing JSON from '%s': %w", filePath, err)
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(file))
+		dec.KnownFields(true)
+		if err := dec.Decode(&scheduleEntries); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("error parsing YAML from '%s': %w", filePath, err)
+		}
+	case ".toml":
+		var doc tomlSchedule
+		md, err := toml.Decode(string(file), &doc)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing TOML from '%s': %w", filePath, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("error parsing TOML from '%s': unknown key %q", filePath, undecoded[0].String())
+		}
+		scheduleEntries = doc.Schedule
+	default:
+		if err := decodeStrictJSON(file, &scheduleEntries, "schedule"); err != nil {
+			return nil, fmt.Errorf("error parsing JSON from '%s': %w", filePath, err)
+		}
 	}
 
 	return scheduleEntries, nil
 }
+
+// decodeStrictJSON decodes data, the JSON of what (e.g. "schedule"), into v, rejecting unknown
+// object keys and trailing data. Errors are prefixed with the line and column they occurred at.
+func decodeStrictJSON(data []byte, v any, what string) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		if _, tokenErr := dec.Token(); !errors.Is(tokenErr, io.EOF) {
+			return fmt.Errorf("%s: unexpected data after the %s", jsonPosition(data, dec.InputOffset()), what)
+		}
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("%s: %w", jsonPosition(data, syntaxErr.Offset-1), err)
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("%s: %w", jsonPosition(data, typeErr.Offset-1), err)
+	}
+	// encoding/json reports no offset for unknown fields, so the key is looked up instead.
+	if quoted, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if key, unquoteErr := strconv.Unquote(quoted); unquoteErr == nil {
+			if loc := regexp.MustCompile(regexp.QuoteMeta(quoted) + `\s*:`).FindIndex(data); loc != nil {
+				return fmt.Errorf("%s: unknown field %q", jsonPosition(data, int64(loc[0])), key)
+			}
+			return fmt.Errorf("unknown field %q", key)
+		}
+	}
+	return err
+}
+
+// jsonPosition renders a byte offset into data as a 1-based line and column, counting
+// columns in characters.
+func jsonPosition(data []byte, offset int64) string {
+	offset = max(0, min(offset, int64(len(data))))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:]) + 1
+	return fmt.Sprintf("line %d, column %d", line, column)
+}
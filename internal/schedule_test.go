@@ -1,11 +1,15 @@
 package internal
 
 import (
+	"encoding/json"
 	"errors" // Added import
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadSchedule_ValidFile(t *testing.T) {
@@ -122,3 +126,299 @@ func TestLoadSchedule_InvalidJson(t *testing.T) {
 		t.Errorf("LoadSchedule returned wrong error for invalid JSON: %v", err)
 	}
 }
+
+func TestLoadSchedule_Formats(t *testing.T) {
+	disabled := false
+	expected := []ScheduleEntry{
+		{ProgramName: "Test Program 1", DayOfWeek: "月", StartTime: "010000", StationID: "ST1"},
+		{ProgramName: "Test Program 2", DayOfWeek: "火", StartTime: "110000", StationID: "ST2", PostCommand: "echo done", Enabled: &disabled, Note: "Back in April"},
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "YAML",
+			file: "schedule.yaml",
+			content: `# Added for the spring season
+- program_name: Test Program 1
+  day_of_week: 月
+  start_time: "010000"
+  station_id: ST1
+- program_name: Test Program 2
+  day_of_week: 火
+  start_time: "110000"
+  station_id: ST2
+  post_command: echo done
+  enabled: false
+  note: Back in April
+`,
+		},
+		{
+			name: "YML with unquoted time",
+			file: "schedule.yml",
+			content: `- {program_name: Test Program 1, day_of_week: 月, start_time: 010000, station_id: ST1}
+- {program_name: Test Program 2, day_of_week: 火, start_time: 110000, station_id: ST2, post_command: echo done, enabled: false, note: Back in April}
+`,
+		},
+		{
+			name: "TOML",
+			file: "schedule.toml",
+			content: `# Added for the spring season
+[[schedule]]
+program_name = "Test Program 1"
+day_of_week = "月"
+start_time = "010000"
+station_id = "ST1"
+
+[[schedule]]
+program_name = "Test Program 2"
+day_of_week = "火"
+start_time = "110000"
+station_id = "ST2"
+post_command = "echo done"
+enabled = false
+note = "Back in April"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write schedule: %v", err)
+			}
+			entries, err := LoadSchedule(path)
+			if err != nil {
+				t.Fatalf("LoadSchedule failed: %v", err)
+			}
+			if !reflect.DeepEqual(entries, expected) {
+				t.Errorf("LoadSchedule = %+v, want %+v", entries, expected)
+			}
+		})
+	}
+}
+
+func TestLoadSchedule_Strict(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected string
+	}{
+		{
+			name: "misspelled JSON key",
+			file: "schedule.json",
+			content: `[
+  {
+    "programname": "番組",
+    "day_of_week": "月",
+    "start_time": "010000",
+    "station_id": "ST1"
+  }
+]`,
+			expected: `line 3, column 5: unknown field "programname"`,
+		},
+		{
+			name:     "JSON type error",
+			file:     "schedule.json",
+			content:  "[\n  {\"program_name\": \"番組\", \"shift_window\": \"10\"}\n]",
+			expected: "line 2, column 45: json: cannot unmarshal string",
+		},
+		{
+			name:     "JSON syntax error",
+			file:     "schedule.json",
+			content:  "[\n  {\"program_name\": \"番組\",}\n]",
+			expected: "line 2, column 25: invalid character '}'",
+		},
+		{
+			name:     "JSON trailing data",
+			file:     "schedule.json",
+			content:  "[]\n[]",
+			expected: "line 2, column 2: unexpected data after the schedule",
+		},
+		{
+			name:     "misspelled YAML key",
+			file:     "schedule.yaml",
+			content:  "- program_name: 番組\n  stationid: ST1\n",
+			expected: "line 2: field stationid not found",
+		},
+		{
+			name:     "misspelled TOML key",
+			file:     "schedule.toml",
+			content:  "[[schedule]]\nprogram_name = \"番組\"\nstationid = \"ST1\"\n",
+			expected: `unknown key "schedule.stationid"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write schedule: %v", err)
+			}
+			entries, err := LoadSchedule(path)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("LoadSchedule error = %v, want %q", err, tt.expected)
+			}
+			if entries != nil {
+				t.Errorf("LoadSchedule returned entries for an invalid schedule: %+v", entries)
+			}
+		})
+	}
+}
+
+func TestScheduleSchema(t *testing.T) {
+	var schema struct {
+		Defs struct {
+			Entry struct {
+				Properties map[string]struct {
+					Properties map[string]any `json:"properties"`
+				} `json:"properties"`
+			} `json:"entry"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(ScheduleSchema(), &schema); err != nil {
+		t.Fatalf("ScheduleSchema is not valid JSON: %v", err)
+	}
+
+	// The schema must describe every field, or editors would flag valid schedules.
+	jsonKeys := func(v any) []string {
+		var keys []string
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			keys = append(keys, name)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	sortedKeys := func(m map[string]any) []string {
+		var keys []string
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	entryProperties := make(map[string]any)
+	for k := range schema.Defs.Entry.Properties {
+		entryProperties[k] = nil
+	}
+	if got, want := sortedKeys(entryProperties), jsonKeys(ScheduleEntry{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema properties = %v, want %v", got, want)
+	}
+	if got, want := sortedKeys(schema.Defs.Entry.Properties["rerun"].Properties), jsonKeys(RerunSlot{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema rerun properties = %v, want %v", got, want)
+	}
+}
+
+func TestFindScheduleFile(t *testing.T) {
+	dir := t.TempDir()
+	if got := FindScheduleFile(dir); got != filepath.Join(dir, "schedule.json") {
+		t.Errorf("FindScheduleFile on empty dir = %s, want schedule.json", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "schedule.toml"), nil, 0644); err != nil {
+		t.Fatalf("Failed to write schedule: %v", err)
+	}
+	if got := FindScheduleFile(dir); got != filepath.Join(dir, "schedule.toml") {
+		t.Errorf("FindScheduleFile = %s, want schedule.toml", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "schedule.json"), nil, 0644); err != nil {
+		t.Fatalf("Failed to write schedule: %v", err)
+	}
+	if got := FindScheduleFile(dir); got != filepath.Join(dir, "schedule.json") {
+		t.Errorf("FindScheduleFile = %s, want schedule.json to take precedence", got)
+	}
+}
+
+func TestFilterSchedule(t *testing.T) {
+	schedule := []ScheduleEntry{
+		{ProgramName: "Morning Show", StationID: "TBS", DayOfWeek: "月"},
+		{ProgramName: "Morning Show", StationID: "QRR", DayOfWeek: "火"},
+		{ProgramName: "Night Show", StationID: "TBS", DayOfWeek: "金"},
+		{ProgramName: "Special", StationID: "TBS", Date: "2024-05-03"},
+	}
+	tests := []struct {
+		name     string
+		filter   ScheduleFilter
+		expected []string
+	}{
+		{"Everything", ScheduleFilter{}, []string{"Morning Show/TBS", "Morning Show/QRR", "Night Show/TBS", "Special/TBS"}},
+		{"Program ignoring case", ScheduleFilter{ProgramName: "morning show"}, []string{"Morning Show/TBS", "Morning Show/QRR"}},
+		{"Stations", ScheduleFilter{Stations: []string{"QRR", "LFR"}}, []string{"Morning Show/QRR"}},
+		{"Both", ScheduleFilter{ProgramName: "Night Show", Stations: []string{"QRR"}}, nil},
+		{"Weekdays", ScheduleFilter{Weekdays: []time.Weekday{time.Monday, time.Friday}}, []string{"Morning Show/TBS", "Night Show/TBS", "Special/TBS"}},
+		{"Station and weekday", ScheduleFilter{Stations: []string{"TBS"}, Weekdays: []time.Weekday{time.Tuesday}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, entry := range FilterSchedule(schedule, tt.filter) {
+				got = append(got, entry.ProgramName+"/"+entry.StationID)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("FilterSchedule = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	disabled := false
+	entry := ScheduleEntry{ProgramName: "ANN", DayOfWeek: "土", StartTime: "010000", StationID: "LFR"}
+	renamed := entry
+	renamed.ProgramName, renamed.Provider = "ANN (copy)", ProviderRadiko
+	paused := entry
+	paused.Enabled = &disabled
+	monthly := entry
+	monthly.WeeksOfMonth = []int{1}
+	special := ScheduleEntry{ProgramName: "Special", DayOfWeek: "土", StartTime: "010000", StationID: "LFR", Date: "2026-01-03"}
+	specialNoDay := special
+	specialNoDay.ProgramName, specialNoDay.DayOfWeek = "New Year Special", ""
+
+	tests := []struct {
+		name         string
+		entries      []ScheduleEntry
+		want         []ScheduleEntry
+		wantWarnings []string
+	}{
+		{name: "no duplicates", entries: []ScheduleEntry{entry, monthly, special}, want: []ScheduleEntry{entry, monthly, special}},
+		{
+			name:         "identical copy",
+			entries:      []ScheduleEntry{entry, entry},
+			want:         []ScheduleEntry{entry},
+			wantWarnings: []string{"'ANN' (LFR 土 010000) is in the schedule twice; recording it once."},
+		},
+		{
+			name:         "different settings",
+			entries:      []ScheduleEntry{entry, renamed},
+			want:         []ScheduleEntry{entry},
+			wantWarnings: []string{"'ANN (copy)' records the same broadcasts as 'ANN' (LFR 土 010000); ignoring it."},
+		},
+		{
+			name:         "date decides the day",
+			entries:      []ScheduleEntry{special, specialNoDay},
+			want:         []ScheduleEntry{special},
+			wantWarnings: []string{"'New Year Special' records the same broadcasts as 'Special' (LFR 2026-01-03 010000); ignoring it."},
+		},
+		{name: "disabled entries are kept", entries: []ScheduleEntry{paused, entry, paused}, want: []ScheduleEntry{paused, entry, paused}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings := MergeDuplicates(tt.entries)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(warnings, tt.wantWarnings) {
+				t.Errorf("got warnings %q, want %q", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
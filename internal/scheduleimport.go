@@ -0,0 +1,363 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportScheduleFile reads the schedule entries of a CSV or iCalendar file, chosen by extension.
+// The slots of iCalendar events are converted to loc, the time zone of the schedule.
+func ImportScheduleFile(path string, loc *time.Location) ([]ScheduleEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	var entries []ScheduleEntry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		entries, err = ParseScheduleCSV(file)
+	case ".ics", ".ical":
+		entries, err = ParseScheduleICS(file, loc)
+	default:
+		return nil, fmt.Errorf("'%s' is neither a .csv nor an .ics file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to import '%s': %w", path, err)
+	}
+	return entries, nil
+}
+
+// csvColumns names the columns of an imported CSV, in their order without a header row.
+var csvColumns = []string{"station", "weekday", "time", "title"}
+
+// csvColumnAliases maps other accepted header names to those of csvColumns.
+var csvColumnAliases = map[string]string{
+	"station_id":   "station",
+	"day_of_week":  "weekday",
+	"day":          "weekday",
+	"start_time":   "time",
+	"start":        "time",
+	"program_name": "title",
+	"program":      "title",
+}
+
+// ParseScheduleCSV parses schedule entries from CSV with the columns station, weekday, time
+// and title. A header row naming them (or the schedule.json keys) may reorder them. Weekdays
+// are Japanese ("月") or English ("Mon", "Monday"); times are "HH:MM", "HH:MM:SS", "HHMM" or
+// "HHMMSS", where 24:00-28:59 is the early morning of the next day, as radio guides write it.
+func ParseScheduleCSV(r io.Reader) ([]ScheduleEntry, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := map[string]int{}
+	for i, name := range csvColumns {
+		columns[name] = i
+	}
+	var entries []ScheduleEntry
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if first {
+			record[0] = strings.TrimPrefix(record[0], "\ufeff") // Spreadsheets often save a BOM.
+			if header, ok := csvHeader(record); ok {
+				columns = header
+				continue
+			}
+		}
+		if csvBlank(record) {
+			continue
+		}
+
+		field := func(name string) string {
+			if i := columns[name]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		entry, err := scheduleSlot(field("station"), field("weekday"), field("time"), field("title"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// csvHeader returns the column of each field if record is a header row naming all of them.
+func csvHeader(record []string) (map[string]int, bool) {
+	columns := map[string]int{}
+	for i, cell := range record {
+		name := strings.ToLower(strings.TrimSpace(cell))
+		if alias, ok := csvColumnAliases[name]; ok {
+			name = alias
+		}
+		columns[name] = i
+	}
+	for _, name := range csvColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, false
+		}
+	}
+	return columns, true
+}
+
+// csvBlank reports whether every cell of record is empty.
+func csvBlank(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// scheduleSlot returns the entry recording title on station at the weekday and time as written
+// in an imported file.
+func scheduleSlot(station, weekday, at, title string) (ScheduleEntry, error) {
+	if station == "" {
+		return ScheduleEntry{}, fmt.Errorf("missing station")
+	}
+	if title == "" {
+		return ScheduleEntry{}, fmt.Errorf("missing title")
+	}
+	day, ok := ParseWeekday(weekday)
+	if !ok {
+		return ScheduleEntry{}, fmt.Errorf("invalid weekday %q", weekday)
+	}
+	hour, minute, second, ok := parseClock(at)
+	if !ok {
+		return ScheduleEntry{}, fmt.Errorf("invalid time %q", at)
+	}
+	if hour >= 24 {
+		day, hour = (day+1)%7, hour-24
+	}
+	return ScheduleEntry{
+		ProgramName: title,
+		DayOfWeek:   japaneseDayOfWeek(day),
+		StartTime:   fmt.Sprintf("%02d%02d%02d", hour, minute, second),
+		StationID:   station,
+	}, nil
+}
+
+// ParseWeekday parses a Japanese weekday ("月", "月曜", "月曜日") or an English one ("Mon", "monday").
+func ParseWeekday(s string) (time.Weekday, bool) {
+	s = strings.TrimSpace(s)
+	if day, ok := DayOfWeekMap[strings.TrimSuffix(strings.TrimSuffix(s, "曜日"), "曜")]; ok {
+		return day, true
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := day.String()
+		if strings.EqualFold(s, name) || strings.EqualFold(s, name[:3]) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// parseClock parses "HH:MM", "HH:MM:SS", "HHMM" or "HHMMSS", allowing hours up to 28.
+func parseClock(s string) (hour, minute, second int, ok bool) {
+	s = strings.TrimSpace(s)
+	var parts []string
+	switch {
+	case strings.Contains(s, ":"):
+		parts = strings.Split(s, ":")
+	case len(s) == 4:
+		parts = []string{s[:2], s[2:]}
+	case len(s) == 6:
+		parts = []string{s[:2], s[2:4], s[4:]}
+	}
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, 0, 0, false
+	}
+	values := make([]int, 3)
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 0 || part == "" {
+			return 0, 0, 0, false
+		}
+		values[i] = v
+	}
+	if values[0] > 28 || values[1] > 59 || values[2] > 59 {
+		return 0, 0, 0, false
+	}
+	return values[0], values[1], values[2], true
+}
+
+// ParseScheduleICS parses schedule entries from the events of an iCalendar file, such as one
+// written by FormatScheduleICS: SUMMARY is the title, LOCATION the station ID and DTSTART the
+// slot, converted to loc, the schedule's time zone (nil is Japan time). An RRULE with BYDAY adds an entry for each listed day; ordinals
+// such as 1TU become weeks of the month, and a weekly INTERVAL repeats every few weeks.
+// EXDATEs become skip dates.
+func ParseScheduleICS(r io.Reader, loc *time.Location) ([]ScheduleEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		entries []ScheduleEntry
+		event   map[string]string // Properties of the VEVENT being read, by name.
+		params  map[string]string // Parameters of DTSTART and EXDATE, as "DTSTART;TZID".
+		nested  int               // Depth of components inside the VEVENT, such as VALARM.
+	)
+	for _, line := range icsUnfold(string(data)) {
+		nameParams, value, _ := strings.Cut(line, ":")
+		name, paramList, _ := strings.Cut(nameParams, ";")
+		name = strings.ToUpper(name)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			event, params = map[string]string{}, map[string]string{}
+		case name == "END" && strings.EqualFold(value, "VEVENT") && event != nil:
+			found, err := icsEventEntries(event, params, scheduleLocation(loc))
+			if err != nil {
+				return nil, fmt.Errorf("event %q: %w", icsUnescape(event["SUMMARY"]), err)
+			}
+			entries = append(entries, found...)
+			event = nil
+		case event != nil && name == "BEGIN":
+			nested++
+		case event != nil && name == "END" && nested > 0:
+			nested--
+		case event != nil && nested == 0:
+			if name == "EXDATE" && event[name] != "" {
+				value = event[name] + "," + value // EXDATE may be repeated.
+			}
+			event[name] = value
+			if (name == "DTSTART" || name == "EXDATE") && paramList != "" {
+				for _, param := range strings.Split(paramList, ";") {
+					key, v, _ := strings.Cut(param, "=")
+					params[name+";"+strings.ToUpper(key)] = strings.Trim(v, `"`)
+				}
+			}
+		}
+	}
+	return entries, nil
+}
+
+// icsEventEntries returns the entries of one VEVENT, with their slots in loc.
+func icsEventEntries(event, params map[string]string, loc *time.Location) ([]ScheduleEntry, error) {
+	start, err := icsTime(event["DTSTART"], params["DTSTART;TZID"])
+	if err != nil {
+		return nil, err
+	}
+	start = start.In(loc)
+	days := []time.Weekday{start.Weekday()}
+	weeks := map[time.Weekday][]int{} // Weeks of the month by day, for BYDAY=1TU,3TU.
+	interval := 1
+	for _, rule := range strings.Split(event["RRULE"], ";") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch strings.ToUpper(key) {
+		case "INTERVAL":
+			if interval, err = strconv.Atoi(value); err != nil || interval < 1 {
+				return nil, fmt.Errorf("unsupported INTERVAL %q", value)
+			}
+		case "BYDAY":
+			days = days[:0]
+			seen := map[time.Weekday]bool{}
+			for _, byDay := range strings.Split(value, ",") {
+				byDay = strings.ToUpper(strings.TrimSpace(byDay))
+				code := strings.TrimLeft(byDay, "+-0123456789")
+				day, ok := icsWeekdays[code]
+				if !ok {
+					return nil, fmt.Errorf("unsupported BYDAY %q", byDay)
+				}
+				if !seen[day] {
+					days = append(days, day)
+					seen[day] = true
+				}
+				if ordinal := strings.TrimPrefix(strings.TrimSuffix(byDay, code), "+"); ordinal != "" {
+					week, err := strconv.Atoi(ordinal)
+					if err != nil || week == 0 || week < -1 || week > 5 {
+						return nil, fmt.Errorf("unsupported BYDAY %q", byDay)
+					}
+					weeks[day] = append(weeks[day], week)
+				}
+			}
+		}
+	}
+
+	var exdates []string
+	for _, value := range strings.Split(event["EXDATE"], ",") {
+		if exdate, err := icsTime(value, params["EXDATE;TZID"]); err == nil {
+			exdates = append(exdates, exdate.In(loc).Format("2006-01-02"))
+		}
+	}
+
+	var entries []ScheduleEntry
+	for _, day := range days {
+		entry, err := scheduleSlot(icsUnescape(event["LOCATION"]), japaneseDayOfWeek(day), start.Format("150405"), icsUnescape(event["SUMMARY"]))
+		if err != nil {
+			return nil, err
+		}
+		entry.WeeksOfMonth = weeks[day]
+		entry.SkipDates = exdates
+		if len(entry.WeeksOfMonth) == 0 && interval > 1 {
+			// Count the weeks from the first broadcast on this day.
+			entry.EveryWeeks = interval
+			entry.Date = start.AddDate(0, 0, (int(day)-int(start.Weekday())+7)%7).Format("2006-01-02")
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// icsWeekdays maps the BYDAY weekdays of an RRULE.
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// icsTime parses a DATE-TIME value: UTC with a trailing Z, in the zone tzid, or floating
+// (read in Japan time) without either.
+func icsTime(value, tzid string) (time.Time, error) {
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse("20060102T150405Z", value)
+	}
+	loc := JST
+	if tzid != "" && tzid != "Asia/Tokyo" {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid DTSTART %q", value)
+	}
+	return t, nil
+}
+
+// icsUnfold undoes the line folding of an iCalendar file and returns its content lines.
+func icsUnfold(data string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// icsUnescape reverses icsText.
+func icsUnescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScheduleCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []ScheduleEntry
+		wantErr string
+	}{
+		{
+			name:  "positional",
+			input: "LFR,土,01:00,オードリーのオールナイトニッポン\n\nTBS, Monday ,2200,\"Show, with comma\"\n",
+			want: []ScheduleEntry{
+				{ProgramName: "オードリーのオールナイトニッポン", DayOfWeek: "土", StartTime: "010000", StationID: "LFR"},
+				{ProgramName: "Show, with comma", DayOfWeek: "月", StartTime: "220000", StationID: "TBS"},
+			},
+		},
+		{
+			name:  "header with BOM and schedule keys",
+			input: "\ufeffprogram_name,start_time,day_of_week,station_id,note\nJUNK,25:30,金曜日,TBS,late night\n",
+			want:  []ScheduleEntry{{ProgramName: "JUNK", DayOfWeek: "土", StartTime: "013000", StationID: "TBS"}},
+		},
+		{
+			name:  "after midnight on Saturday",
+			input: "LFR,sat,24:00:30,Midnight\n",
+			want:  []ScheduleEntry{{ProgramName: "Midnight", DayOfWeek: "日", StartTime: "000030", StationID: "LFR"}},
+		},
+		{name: "bad weekday", input: "LFR,Funday,01:00,Show\n", wantErr: `line 1: invalid weekday "Funday"`},
+		{name: "bad time", input: "station,weekday,time,title\nLFR,月,29:00,Show\n", wantErr: `line 2: invalid time "29:00"`},
+		{name: "missing title", input: "LFR,月,01:00\n", wantErr: "line 1: missing title"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScheduleCSV(strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseScheduleCSV failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScheduleICS(t *testing.T) {
+	// A calendar written by FormatScheduleICS reads back as the slots it was made of.
+	entry := ScheduleEntry{ProgramName: "櫻坂46 こちら有楽町星空放送局, 特別編", DayOfWeek: "日", StartTime: "230000", StationID: "LFR", SkipDates: []string{"2026-01-18", "2026-02-01"}}
+	at := time.Date(2026, time.January, 11, 23, 0, 0, 0, JST)
+	exported := FormatScheduleICS([]PreviewItem{{Entry: entry, At: at, Title: entry.ProgramName}}, at)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []ScheduleEntry
+		wantErr string
+	}{
+		{name: "round trip", input: string(exported), want: []ScheduleEntry{entry}},
+		{
+			name: "UTC, BYDAY and an alarm",
+			input: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Morning\nLOCATION:TBS\nDTSTART:20260112T210000Z\n" +
+				"RRULE:FREQ=WEEKLY;BYDAY=TU,WE\nBEGIN:VALARM\nDESCRIPTION:Reminder\nLOCATION:Elsewhere\nEND:VALARM\nEND:VEVENT\nEND:VCALENDAR\n",
+			want: []ScheduleEntry{
+				{ProgramName: "Morning", DayOfWeek: "火", StartTime: "060000", StationID: "TBS"},
+				{ProgramName: "Morning", DayOfWeek: "水", StartTime: "060000", StationID: "TBS"},
+			},
+		},
+		{
+			name: "monthly and biweekly",
+			input: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Monthly\nLOCATION:TBS\nDTSTART:20260106T030000\nRRULE:FREQ=MONTHLY;BYDAY=1TU,-1TU\nEND:VEVENT\n" +
+				"BEGIN:VEVENT\nSUMMARY:Biweekly\nLOCATION:LFR\nDTSTART:20260106T030000\nRRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH\nEND:VEVENT\nEND:VCALENDAR\n",
+			want: []ScheduleEntry{
+				{ProgramName: "Monthly", DayOfWeek: "火", StartTime: "030000", StationID: "TBS", WeeksOfMonth: []int{1, -1}},
+				{ProgramName: "Biweekly", DayOfWeek: "火", StartTime: "030000", StationID: "LFR", Date: "2026-01-06", EveryWeeks: 2},
+				{ProgramName: "Biweekly", DayOfWeek: "木", StartTime: "030000", StationID: "LFR", Date: "2026-01-08", EveryWeeks: 2},
+			},
+		},
+		{
+			name:    "no station",
+			input:   "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Lunch\nDTSTART:20260112T120000\nEND:VEVENT\nEND:VCALENDAR\n",
+			wantErr: `event "Lunch": missing station`,
+		},
+		{
+			name:    "all-day event",
+			input:   "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Holiday\nLOCATION:TBS\nDTSTART;VALUE=DATE:20260112\nEND:VEVENT\nEND:VCALENDAR\n",
+			wantErr: "invalid DTSTART",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScheduleICS(strings.NewReader(tt.input), nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseScheduleICS failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportScheduleFile(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "Schedule.CSV")
+	if err := os.WriteFile(csvPath, []byte("LFR,土,01:00,ANN\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := ImportScheduleFile(csvPath, nil); err != nil || len(entries) != 1 {
+		t.Errorf("expected one entry from the CSV, got %+v (%v)", entries, err)
+	}
+	if _, err := ImportScheduleFile(filepath.Join(dir, "schedule.xlsx"), nil); err == nil {
+		t.Error("expected an error for a file that is neither CSV nor ICS")
+	}
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"errors"
+	"net/url"
+	"os"
+)
+
+// redactedValue replaces secrets in output meant for humans.
+const redactedValue = "********"
+
+// configFileMode is the permission config.json is written with, as it may hold credentials.
+const configFileMode = 0600
+
+// HasSecrets reports whether the config holds credentials or secret URLs.
+func (c *Config) HasSecrets() bool {
+	return c.Radiko.Password != "" || c.PostStore.S3.SecretAccessKey != "" ||
+		c.Notifications.WebhookURL != "" || c.Notifications.Email.Password != "" || proxyHasPassword(c.Network.Proxy) ||
+		c.Transcription.APIKey != "" || c.GRPC.Token != ""
+}
+
+// Redacted returns a copy of the config with passwords, secret keys and secret URLs masked,
+// for display and logging.
+func (c *Config) Redacted() *Config {
+	r := *c
+	if r.Radiko.Password != "" {
+		r.Radiko.Password = redactedValue
+	}
+	if r.PostStore.S3.SecretAccessKey != "" {
+		r.PostStore.S3.SecretAccessKey = redactedValue
+	}
+	r.Notifications.WebhookURL = redactURL(r.Notifications.WebhookURL)
+	if r.Notifications.Email.Password != "" {
+		r.Notifications.Email.Password = redactedValue
+	}
+	if r.Transcription.APIKey != "" {
+		r.Transcription.APIKey = redactedValue
+	}
+	if r.GRPC.Token != "" {
+		r.GRPC.Token = redactedValue
+	}
+	if proxyHasPassword(r.Network.Proxy) {
+		if u, err := url.Parse(r.Network.Proxy); err == nil {
+			r.Network.Proxy = u.Redacted()
+		}
+	}
+	return &r
+}
+
+// redactURL masks everything after the host of rawURL, where webhook URLs carry their token.
+func redactURL(rawURL string) string {
+	if rawURL == "" {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return redactedValue
+	}
+	return u.Scheme + "://" + u.Host + "/" + redactedValue
+}
+
+// redactURLError masks the URL in err when it is a *url.Error, as returned by http.Client.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return &url.Error{Op: urlErr.Op, URL: redactURL(urlErr.URL), Err: urlErr.Err}
+	}
+	return err
+}
+
+func proxyHasPassword(proxy string) bool {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return false
+	}
+	_, ok := u.User.Password()
+	return ok
+}
+
+// LooseConfigPermissions reports whether the file at path can be read or written by users other
+// than its owner, returning its permission bits. A missing file is not loose.
+func LooseConfigPermissions(path string) (os.FileMode, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	perm := info.Mode().Perm()
+	return perm, perm&0077 != 0, nil
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// errPremiumLogin is returned when logging in with the radiko premium account fails.
+var errPremiumLogin = errors.New("failed to log in to radiko premium")
+
+// sessionClient is the outcome of creating and authenticating the client of one provider.
+type sessionClient struct {
+	client Provider
+	err    error
+}
+
+// session holds the authenticated client of each provider used in one run. Every provider
+// is authenticated once, before its first job, and the client is shared by all of its jobs.
+// A provider that fails keeps its error, so its remaining entries fail right away.
+// It is only used by the goroutine dispatching the jobs.
+type session struct {
+	opts    Options
+	jobOpts JobOptions
+	clients map[string]sessionClient
+}
+
+func newSession(opts Options, jobOpts JobOptions) *session {
+	return &session{opts: opts, jobOpts: jobOpts, clients: make(map[string]sessionClient)}
+}
+
+// client returns the authenticated client for entry's provider, authenticating on first use.
+// Failures caused by ctx being cancelled are not remembered.
+func (s *session) client(ctx context.Context, entry ScheduleEntry) (Provider, error) {
+	name := entry.Provider
+	if name == "" {
+		name = ProviderRadiko
+	}
+	if c, ok := s.clients[name]; ok {
+		return c.client, c.err
+	}
+
+	s.opts.Logger.Printf("INFO: Authenticating with %s...", name)
+	client, err := s.opts.newClient(ctx, entry)
+	if err == nil {
+		var cached bool
+		if cached, err = s.jobOpts.authenticate(ctx, s.opts.Logger, client); err == nil {
+			if cached {
+				s.opts.Logger.Printf("INFO: Authenticated with %s using the cached token.", name)
+			} else {
+				s.opts.Logger.Printf("INFO: Authenticated with %s.", name)
+			}
+		}
+	}
+	if err != nil {
+		err = explainAuthError(name, err)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		s.opts.Logger.Printf("ERROR: %v", err)
+		client = nil
+	}
+	s.clients[name] = sessionClient{client: client, err: err}
+	return client, err
+}
+
+// explainAuthError wraps an authentication failure of the named provider with what
+// the user can do about it.
+func explainAuthError(name string, err error) error {
+	var hint string
+	switch {
+	case errors.Is(err, errPremiumLogin):
+		hint = "check radiko.mail and radiko.password in config.json"
+	case name == ProviderRadiko:
+		hint = "check the network connection; radiko only serves clients in Japan, so elsewhere set network.proxy or a premium account in config.json"
+	}
+	if hint == "" {
+		return classify(ErrAuthFailed, fmt.Errorf("authentication with %s failed: %w", name, err))
+	}
+	return classify(ErrAuthFailed, fmt.Errorf("authentication with %s failed (%s): %w", name, hint, err))
+}
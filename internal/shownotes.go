@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// showNotesExt is the extension of the show notes written next to a recording (JobOptions.ShowNotes).
+const showNotesExt = ".md"
+
+var (
+	// htmlLinkPattern matches the links in guide descriptions, capturing the target and the text.
+	htmlLinkPattern = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*["']([^"']+)["'][^>]*>(.*?)</a>`)
+	// blankLinesPattern matches runs of blank lines left by the removed markup.
+	blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// guideText converts the HTML of a guide description into plain text with Markdown links.
+func guideText(s string) string {
+	s = htmlLinkPattern.ReplaceAllStringFunc(s, func(link string) string {
+		m := htmlLinkPattern.FindStringSubmatch(link)
+		text := strings.TrimSpace(htmlTagPattern.ReplaceAllString(m[2], ""))
+		if text == "" || text == m[1] {
+			return m[1] // Angle brackets would be taken for a tag below; renderers link bare URLs.
+		}
+		return "[" + text + "](" + m[1] + ")"
+	})
+	s = htmlTagPattern.ReplaceAllStringFunc(s, func(tag string) string {
+		if strings.HasPrefix(strings.ToLower(tag), "<br") || strings.EqualFold(tag, "</p>") {
+			return "\n"
+		}
+		return ""
+	})
+	lines := strings.Split(html.UnescapeString(s), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// hasShowNotes reports whether the guide entry has anything beyond its title to show.
+func hasShowNotes(prog Prog) bool {
+	return strings.TrimSpace(prog.Desc) != "" || strings.TrimSpace(prog.Info) != "" || prog.URL != ""
+}
+
+// formatShowNotes renders the show notes of the broadcast of prog on stationID from start to end
+// (zero if unknown) as Markdown.
+func formatShowNotes(prog Prog, stationID string, start, end time.Time) []byte {
+	var b strings.Builder
+	title := prog.Title
+	if prog.SubTitle != "" {
+		title += " " + prog.SubTitle
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+
+	aired := start.In(JST).Format("2006-01-02 15:04")
+	if !end.IsZero() {
+		aired += "–" + end.In(JST).Format("15:04")
+	}
+	fmt.Fprintf(&b, "- Station: %s\n- Aired: %s\n", stationID, aired)
+	if pfm := strings.TrimSpace(prog.Pfm); pfm != "" {
+		fmt.Fprintf(&b, "- Performers: %s\n", pfm)
+	}
+	if prog.URL != "" {
+		fmt.Fprintf(&b, "- Web: <%s>\n", prog.URL)
+	}
+	for _, section := range []string{prog.Info, prog.Desc} {
+		if text := guideText(section); text != "" {
+			fmt.Fprintf(&b, "\n%s\n", text)
+		}
+	}
+	return []byte(b.String())
+}
+
+// writeShowNotes writes the show notes of prog next to the recording at path and returns their path.
+func writeShowNotes(path string, prog Prog, stationID string, start, end time.Time) (string, error) {
+	notesPath := strings.TrimSuffix(path, filepath.Ext(path)) + showNotesExt
+	if err := writeFileAtomic(notesPath, formatShowNotes(prog, stationID, start, end), 0644); err != nil {
+		return "", fmt.Errorf("failed to write show notes '%s': %w", notesPath, err)
+	}
+	return notesPath, nil
+}
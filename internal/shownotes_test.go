@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFormatShowNotes(t *testing.T) {
+	prog := Prog{
+		Title:    "アフター６ジャンクション",
+		SubTitle: "第1部",
+		Pfm:      "宇多丸、日比麻音子",
+		URL:      "https://www.tbsradio.jp/a6j/",
+		Info:     `<p>今夜の特集&amp;お知らせ<br /><br><br>・<a href="https://example.com/mail" target="_blank">メールはこちら</a></p><p><img src="x.png"></p>`,
+		Desc:     `<a href="https://example.com/">https://example.com/</a>`,
+	}
+	start := time.Date(2026, time.January, 12, 18, 0, 0, 0, JST)
+
+	got := string(formatShowNotes(prog, "TBS", start, start.Add(3*time.Hour)))
+	want := "# アフター６ジャンクション 第1部\n\n" +
+		"- Station: TBS\n- Aired: 2026-01-12 18:00–21:00\n- Performers: 宇多丸、日比麻音子\n- Web: <https://www.tbsradio.jp/a6j/>\n" +
+		"\n今夜の特集&お知らせ\n\n・[メールはこちら](https://example.com/mail)\n" +
+		"\nhttps://example.com/\n"
+	if got != want {
+		t.Errorf("formatShowNotes =\n%s\nwant\n%s", got, want)
+	}
+
+	if hasShowNotes(Prog{Title: "ニュース", Info: " "}) {
+		t.Error("expected a title alone to have no show notes")
+	}
+}
+
+func TestExecuteJobShowNotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    time.Time
+		file     string
+		expected bool
+	}{
+		{"description", time.Date(2026, time.January, 12, 18, 0, 0, 0, JST), "20260112180000-TBS-アフター６ジャンクション", true},
+		{"title only", time.Date(2026, time.January, 12, 21, 0, 0, 0, JST), "20260112210000-TBS-ニュース", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			opts := JobOptions{
+				OutputDir:  outputDir,
+				Logger:     log.New(io.Discard, "", 0),
+				ShowNotes:  true,
+				FetchGuide: func(context.Context, string) ([]byte, error) { return []byte(chaptersGuide), nil },
+
+				DisableProgressBar: true,
+			}
+			entry := ScheduleEntry{ProgramName: "TBS", DayOfWeek: "月", StartTime: tt.start.Format("150405"), StationID: "TBS"}
+			if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, tt.start, opts); err != nil {
+				t.Fatalf("ExecuteJob failed: %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(outputDir, tt.file+showNotesExt))
+			if !tt.expected {
+				if !os.IsNotExist(err) {
+					t.Errorf("expected no show notes, got %q (%v)", data, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected show notes: %v", err)
+			}
+			if want := fmt.Sprintf("# アフター６ジャンクション\n\n- Station: TBS\n- Aired: 2026-01-12 18:00–21:00\n\n%s\n\n22:00 never aired\n",
+				"18:00〜 オープニング\n・19:30頃 特集\n20:45～ リスナーのお便り"); string(data) != want {
+				t.Errorf("unexpected show notes:\n%s", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+// ProgramStats summarizes the recording history of one program, see ComputeStats.
+type ProgramStats struct {
+	ProgramName string
+	Episodes    int           // Broadcasts recorded successfully, each counted once.
+	Duration    time.Duration // Broadcast time of the episodes whose guide entry is in the history.
+	DiskUsage   int64         // Bytes of the episodes' files still in the output directory.
+	LastSuccess time.Time     // Broadcast start of the most recent episode.
+	Failures    int           // Failed jobs.
+	// FailureStreak counts the jobs that failed since the last success, or since the first job.
+	FailureStreak int
+}
+
+// ComputeStats returns the statistics of every program in records, which are in the order they
+// were written, sorted by program name. A broadcast recorded again, e.g. with --overwrite, counts
+// as one episode. Disk usage is read from the files themselves, so recordings that were deleted
+// or moved away after an upload do not count.
+func ComputeStats(records []HistoryRecord) []ProgramStats {
+	byProgram := make(map[string]*ProgramStats)
+	episodes := make(map[string]bool)
+	files := make(map[string]bool)
+	for _, rec := range records {
+		stats, ok := byProgram[rec.ProgramName]
+		if !ok {
+			stats = &ProgramStats{ProgramName: rec.ProgramName}
+			byProgram[rec.ProgramName] = stats
+		}
+		if rec.Status != StatusSuccess {
+			stats.Failures++
+			stats.FailureStreak++
+			continue
+		}
+		stats.FailureStreak = 0
+		if rec.StartTime.After(stats.LastSuccess) {
+			stats.LastSuccess = rec.StartTime
+		}
+		if key := rec.ProgramName + "|" + broadcastKey(rec.StationID, rec.StartTime); !episodes[key] {
+			episodes[key] = true
+			stats.Episodes++
+			stats.Duration += guideDuration(rec.Guide)
+		}
+		if rec.OutputPath != "" && !rec.LocalRemoved && !files[rec.OutputPath] {
+			files[rec.OutputPath] = true
+			if info, err := os.Stat(rec.OutputPath); err == nil {
+				// A rotated recording's path is its playlist; Size covers the parts.
+				size := rec.Size
+				if size == 0 {
+					size = info.Size()
+				}
+				stats.DiskUsage += size
+			}
+		}
+	}
+
+	all := make([]ProgramStats, 0, len(byProgram))
+	for _, stats := range byProgram {
+		all = append(all, *stats)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ProgramName < all[j].ProgramName })
+	return all
+}
+
+// guideDuration returns the length of the broadcast in a guide snapshot, or 0 without one.
+func guideDuration(prog *Prog) time.Duration {
+	if prog == nil {
+		return 0
+	}
+	from, errFrom := time.ParseInLocation("20060102150405", prog.Ft, JST)
+	to, errTo := time.ParseInLocation("20060102150405", prog.To, JST)
+	if errFrom != nil || errTo != nil || !to.After(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
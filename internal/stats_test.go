@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComputeStats(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "kept.aac")
+	if err := os.WriteFile(kept, make([]byte, 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	monday := time.Date(2026, time.March, 2, 10, 0, 0, 0, JST)
+	guide := &Prog{Ft: "20260302100000", To: "20260302113000"}
+
+	records := []HistoryRecord{
+		{ProgramName: "Morning", StationID: "TBS", StartTime: monday, Status: StatusSuccess, OutputPath: kept, Guide: guide},
+		// Recorded again with --overwrite: one episode, one file.
+		{ProgramName: "Morning", StationID: "TBS", StartTime: monday, Status: StatusSuccess, OutputPath: kept, Guide: guide},
+		{ProgramName: "Morning", StationID: "TBS", StartTime: monday.AddDate(0, 0, 7), Status: StatusFailed},
+		// Deleted since, and recorded without the guide.
+		{ProgramName: "Morning", StationID: "TBS", StartTime: monday.AddDate(0, 0, 14), Status: StatusSuccess, OutputPath: filepath.Join(dir, "gone.aac")},
+		{ProgramName: "Night", StationID: "QRR", StartTime: monday, Status: StatusFailed},
+		{ProgramName: "Night", StationID: "QRR", StartTime: monday.AddDate(0, 0, 7), Status: StatusFailed},
+	}
+
+	expected := []ProgramStats{
+		{ProgramName: "Morning", Episodes: 2, Duration: 90 * time.Minute, DiskUsage: 100, LastSuccess: monday.AddDate(0, 0, 14), Failures: 1},
+		{ProgramName: "Night", Failures: 2, FailureStreak: 2},
+	}
+	if got := ComputeStats(records); !reflect.DeepEqual(got, expected) {
+		t.Errorf("ComputeStats =\n%+v\nwant\n%+v", got, expected)
+	}
+	if got := ComputeStats(nil); len(got) != 0 {
+		t.Errorf("ComputeStats(nil) = %+v, want none", got)
+	}
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// TimeshiftWindow is how far back radiko keeps broadcasts available for timeshift playback.
+const TimeshiftWindow = 7 * 24 * time.Hour
+
+// Subscription records every broadcast whose title or performer matches Keyword, like a
+// season pass: each pass scans the weekly guides of Stations and records the matches that
+// have already aired and are still within the timeshift window.
+type Subscription struct {
+	Name        string   `json:"name,omitempty"` // Shown in logs. Defaults to the keyword.
+	Keyword     string   `json:"keyword"`
+	Regex       bool     `json:"regex,omitempty"`    // Keyword is a regular expression instead of a plain substring.
+	Stations    []string `json:"stations,omitempty"` // Stations whose guides are scanned. Defaults to all stations.
+	PostCommand string   `json:"post_command,omitempty"`
+}
+
+// String returns the subscription's name, or its keyword if it has none.
+func (s Subscription) String() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Keyword
+}
+
+// matcher returns a function reporting whether a program matches the subscription.
+// Matching is case-insensitive for both plain keywords and regular expressions.
+func (s Subscription) matcher() (func(Prog) bool, error) {
+	if s.Keyword == "" {
+		return nil, fmt.Errorf("subscription '%s' has no keyword", s.Name)
+	}
+	if s.Regex {
+		re, err := regexp.Compile("(?i)" + s.Keyword)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex in subscription '%s': %w", s, err)
+		}
+		return func(p Prog) bool { return re.MatchString(p.Title) || re.MatchString(p.Pfm) }, nil
+	}
+	keyword := strings.ToLower(s.Keyword)
+	return func(p Prog) bool {
+		return strings.Contains(strings.ToLower(p.Title), keyword) || strings.Contains(strings.ToLower(p.Pfm), keyword)
+	}, nil
+}
+
+// subscriptionJob is a past broadcast matched by a subscription.
+type subscriptionJob struct {
+	entry ScheduleEntry
+	start time.Time
+}
+
+// findSubscriptionJobs returns the broadcasts matching sub that ended by now and started within
+// the timeshift window, in guide order. Stations whose guide cannot be read are reported in errs.
+func findSubscriptionJobs(ctx context.Context, sub Subscription, now time.Time, stations []string, fetch func(ctx context.Context, stationID string) ([]byte, error)) (jobs []subscriptionJob, errs []error) {
+	match, err := sub.matcher()
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	for _, stationID := range stations {
+		data, err := fetch(ctx, stationID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", stationID, err))
+			continue
+		}
+		var radiko Radiko
+		if err := xml.Unmarshal(data, &radiko); err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to unmarshal program guide: %w", stationID, err))
+			continue
+		}
+		for _, station := range radiko.Stations.Station {
+			for _, prog := range station.Progs.Prog {
+				if !match(prog) {
+					continue
+				}
+				start, err := time.ParseInLocation("20060102150405", prog.Ft, JST)
+				if err != nil {
+					continue
+				}
+				end, err := time.ParseInLocation("20060102150405", prog.To, JST)
+				if err != nil || end.After(now) || start.Before(now.Add(-TimeshiftWindow)) {
+					continue
+				}
+				jobs = append(jobs, subscriptionJob{
+					entry: ScheduleEntry{
+						ProgramName: prog.Title,
+						DayOfWeek:   japaneseDayOfWeek(start.Weekday()),
+						StartTime:   start.Format("150405"),
+						StationID:   station.ID,
+						PostCommand: sub.PostCommand,
+					},
+					start: start,
+				})
+			}
+		}
+	}
+	return jobs, errs
+}
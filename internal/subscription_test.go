@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const subscriptionGuideXML = `<?xml version="1.0" encoding="UTF-8"?>
+<radiko>
+  <stations>
+    <station id="LFR">
+      <progs>
+        <prog ft="20260105010000" to="20260105030000"><title>Old Special</title><pfm>Audrey</pfm></prog>
+        <prog ft="20260110010000" to="20260110030000"><title>オードリーのオールナイトニッポン</title><pfm>Audrey</pfm></prog>
+        <prog ft="20260112220000" to="20260112230000"><title>Music Hour</title><pfm>Guest: AUDREY</pfm></prog>
+        <prog ft="20260113093000" to="20260113103000"><title>Morning Show</title><pfm>Audrey</pfm></prog>
+        <prog ft="20260117010000" to="20260117030000"><title>オードリーのオールナイトニッポン</title><pfm>Audrey</pfm></prog>
+      </progs>
+    </station>
+  </stations>
+</radiko>`
+
+func TestSubscriptionMatcher(t *testing.T) {
+	prog := Prog{Title: "オードリーのオールナイトニッポン", Pfm: "Audrey", Desc: "special guest"}
+	tests := []struct {
+		name    string
+		sub     Subscription
+		want    bool
+		wantErr bool
+	}{
+		{"title", Subscription{Keyword: "オールナイト"}, true, false},
+		{"performer, case-insensitive", Subscription{Keyword: "AUDREY"}, true, false},
+		{"description is not matched", Subscription{Keyword: "guest"}, false, false},
+		{"regex", Subscription{Keyword: "^オードリー.*ニッポン$", Regex: true}, true, false},
+		{"regex no match", Subscription{Keyword: "^ニッポン", Regex: true}, false, false},
+		{"invalid regex", Subscription{Keyword: "(", Regex: true}, false, true},
+		{"no keyword", Subscription{Name: "empty"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := tt.sub.matcher()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && match(prog) != tt.want {
+				t.Errorf("expected match=%v", tt.want)
+			}
+		})
+	}
+}
+
+func TestFindSubscriptionJobs(t *testing.T) {
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST) // Tuesday
+	fetch := func(ctx context.Context, stationID string) ([]byte, error) { return []byte(subscriptionGuideXML), nil }
+
+	jobs, errs := findSubscriptionJobs(context.Background(), Subscription{Keyword: "audrey"}, now, []string{"LFR"}, fetch)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	// Old Special is outside the timeshift window, Morning Show has not ended,
+	// and next Saturday's broadcast has not aired.
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %+v", jobs)
+	}
+	want := ScheduleEntry{ProgramName: "オードリーのオールナイトニッポン", DayOfWeek: "土", StartTime: "010000", StationID: "LFR"}
+	if !reflect.DeepEqual(jobs[0].entry, want) || !jobs[0].start.Equal(time.Date(2026, time.January, 10, 1, 0, 0, 0, JST)) {
+		t.Errorf("unexpected first job: %+v", jobs[0])
+	}
+	if jobs[1].entry.ProgramName != "Music Hour" {
+		t.Errorf("unexpected second job: %+v", jobs[1])
+	}
+}
+
+func TestRunOnceSubscriptions(t *testing.T) {
+	outputDir := t.TempDir()
+	var logBuf bytes.Buffer
+	opts := Options{
+		Schedule: []ScheduleEntry{
+			{ProgramName: "Music Hour", DayOfWeek: "月", StartTime: "220000", StationID: "LFR"},
+		},
+		Subscriptions: []Subscription{
+			{Keyword: "オードリー"},
+			{Name: "Audrey", Keyword: "audrey"}, // Overlaps the first subscription and the schedule entry.
+		},
+		OutputDir: outputDir,
+		Logger:    log.New(&logBuf, "", 0),
+		Clock:     &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)},
+		NewProvider: func(ctx context.Context) (Provider, error) {
+			return &MockRadikoClient{}, nil
+		},
+		FetchGuide: func(ctx context.Context, stationID string) ([]byte, error) { return []byte(subscriptionGuideXML), nil },
+		ListStations: func(context.Context) ([]string, error) {
+			return []string{"LFR"}, nil
+		},
+	}
+
+	if err := RunOnce(context.Background(), opts); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	files, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		if filepath.Ext(f.Name()) == ".aac" {
+			names = append(names, f.Name())
+		}
+	}
+	want := []string{"20260110010000-LFR-オードリーのオールナイトニッポン.aac", "20260112220000-LFR-Music Hour.aac"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("expected recordings %v, got %v", want, names)
+	}
+	if n := strings.Count(logBuf.String(), "Starting recording for"); n != 2 {
+		t.Errorf("expected each broadcast to be recorded once, got %d jobs:\n%s", n, logBuf.String())
+	}
+}
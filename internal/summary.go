@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of a job in a RunSummary.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomeSkipped   = "skipped" // Already recorded.
+)
+
+// JobResult is the outcome of one entry of a run.
+type JobResult struct {
+	ProgramName string
+	StationID   string
+	Start       time.Time // Zero if the broadcast time could not be determined.
+	Outcome     string
+	OutputPath  string // The recording, for succeeded and skipped jobs.
+	Size        int64  // Bytes of audio recorded, for succeeded jobs.
+	Err         error  // Why the job failed.
+}
+
+// RunSummary collects the result of every entry handled by RunOnce (see Options.Summary),
+// in the order the jobs finish. It is safe for concurrent use.
+type RunSummary struct {
+	mu      sync.Mutex
+	results []JobResult
+}
+
+// add appends r to the summary; a nil summary ignores it.
+func (s *RunSummary) add(r JobResult) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, r)
+}
+
+// Results returns a copy of the results collected so far.
+func (s *RunSummary) Results() []JobResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]JobResult(nil), s.results...)
+}
+
+// Counts returns the number of succeeded, failed and skipped jobs.
+func (s *RunSummary) Counts() (succeeded, failed, skipped int) {
+	for _, r := range s.Results() {
+		switch r.Outcome {
+		case OutcomeSucceeded:
+			succeeded++
+		case OutcomeFailed:
+			failed++
+		case OutcomeSkipped:
+			skipped++
+		}
+	}
+	return succeeded, failed, skipped
+}
+
+// FormatRunReport renders the results of a run as a notification subject and body: the programs
+// recorded with their file sizes, and the failures with their errors. It returns false when
+// nothing was recorded or failed, as most daemon passes only skip recorded broadcasts.
+func FormatRunReport(results []JobResult) (string, string, bool) {
+	var recorded, failed []JobResult
+	skipped := 0
+	for _, r := range results {
+		switch r.Outcome {
+		case OutcomeSucceeded:
+			recorded = append(recorded, r)
+		case OutcomeFailed:
+			failed = append(failed, r)
+		case OutcomeSkipped:
+			skipped++
+		}
+	}
+	if len(recorded) == 0 && len(failed) == 0 {
+		return "", "", false
+	}
+
+	subject := fmt.Sprintf("Recording run: %d recorded, %d failed", len(recorded), len(failed))
+	var b strings.Builder
+	if len(recorded) > 0 {
+		b.WriteString("Recorded:\n")
+		for _, r := range recorded {
+			fmt.Fprintf(&b, "- %s: %s", r.describe(), r.OutputPath)
+			if r.Size > 0 {
+				fmt.Fprintf(&b, " (%s)", formatBytes(r.Size))
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(failed) > 0 {
+		b.WriteString("Failed:\n")
+		for _, r := range failed {
+			fmt.Fprintf(&b, "- %s: %v\n", r.describe(), r.Err)
+		}
+	}
+	if skipped > 0 {
+		fmt.Fprintf(&b, "%d already recorded broadcast(s) skipped.\n", skipped)
+	}
+	return subject, b.String(), true
+}
+
+// describe names the program and broadcast of r for reports.
+func (r JobResult) describe() string {
+	if r.Start.IsZero() {
+		return fmt.Sprintf("%s (%s)", r.ProgramName, r.StationID)
+	}
+	return fmt.Sprintf("%s (%s %s)", r.ProgramName, r.StationID, r.Start.In(JST).Format("2006-01-02 15:04"))
+}
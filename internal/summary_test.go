@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// notifierFunc adapts a function to Notifier.
+type notifierFunc func(ctx context.Context, subject, body string) error
+
+func (f notifierFunc) Notify(ctx context.Context, subject, body string) error {
+	return f(ctx, subject, body)
+}
+
+func TestFormatRunReport(t *testing.T) {
+	recording := filepath.Join("output", "show.aac") // Sizes come from the job, not the file.
+	start := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+
+	if _, _, ok := FormatRunReport([]JobResult{{ProgramName: "Old", Outcome: OutcomeSkipped}}); ok {
+		t.Error("expected no report for a run that only skipped broadcasts")
+	}
+
+	subject, body, ok := FormatRunReport([]JobResult{
+		{ProgramName: "Show", StationID: "TBS", Start: start, Outcome: OutcomeSucceeded, OutputPath: recording, Size: 2048},
+		{ProgramName: "Broken", StationID: "QRR", Start: start, Outcome: OutcomeFailed, Err: errors.New("HTTP status 404")},
+		{ProgramName: "Invalid", StationID: "LFR", Outcome: OutcomeFailed, Err: errors.New("invalid day")},
+		{ProgramName: "Old", Outcome: OutcomeSkipped},
+	})
+	if !ok || subject != "Recording run: 1 recorded, 2 failed" {
+		t.Errorf("unexpected subject %q (%v)", subject, ok)
+	}
+	want := "Recorded:\n" +
+		"- Show (TBS 2026-01-12 10:00): " + recording + " (2.0 KB)\n" +
+		"Failed:\n" +
+		"- Broken (QRR 2026-01-12 10:00): HTTP status 404\n" +
+		"- Invalid (LFR): invalid day\n" +
+		"1 already recorded broadcast(s) skipped.\n"
+	if body != want {
+		t.Errorf("unexpected body:\n%s\nwant:\n%s", body, want)
+	}
+}
+
+func TestRunOnceSendsRunReport(t *testing.T) {
+	var subjects, bodies []string
+	opts := Options{
+		Schedule: []ScheduleEntry{
+			{ProgramName: "Good Program", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"},
+			{ProgramName: "Bad Program", DayOfWeek: "X", StartTime: "100000", StationID: "ST1"},
+		},
+		OutputDir:   t.TempDir(),
+		Logger:      log.New(io.Discard, "", 0),
+		Clock:       &fakeClock{now: time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)},
+		FetchGuide:  func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+		NewProvider: func(ctx context.Context) (Provider, error) { return &MockRadikoClient{}, nil },
+		RunReport:   true,
+		Notifier: notifierFunc(func(ctx context.Context, subject, body string) error {
+			subjects, bodies = append(subjects, subject), append(bodies, body)
+			return nil
+		}),
+	}
+
+	_ = RunOnce(context.Background(), opts)
+	if len(subjects) != 1 || subjects[0] != "Recording run: 1 recorded, 1 failed" ||
+		!strings.Contains(bodies[0], "Good Program (ST1 2026-01-12 10:00)") || !strings.Contains(bodies[0], "Bad Program (ST1): ") {
+		t.Fatalf("unexpected run report: %q %q", subjects, bodies)
+	}
+
+	// The second run only skips the recorded program; the failure alone is still reported.
+	_ = RunOnce(context.Background(), opts)
+	if len(subjects) != 2 || subjects[1] != "Recording run: 0 recorded, 1 failed" {
+		t.Errorf("unexpected second run report: %q", subjects)
+	}
+}
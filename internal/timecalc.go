@@ -1,19 +1,45 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"time"
+	_ "time/tzdata" // Asia/Tokyo loads without a zoneinfo database, e.g. in scratch containers.
 )
 
-var JST *time.Location
+// JST is the time of radiko and its program guides.
+var JST = loadJST()
 
-func init() {
-	var err error
-	JST, err = time.LoadLocation("Asia/Tokyo")
+// loadJST returns the Asia/Tokyo location, or a fixed UTC+9 zone, the same since Japan has no
+// daylight saving time, should it fail to load.
+func loadJST() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Tokyo"); err == nil {
+		return loc
+	}
+	return time.FixedZone("JST", 9*60*60)
+}
+
+// LoadScheduleLocation returns the time zone named by the timezone setting, an IANA name such
+// as "Europe/London", in which the days, start times and dates of schedule entries are read.
+// "" is Japan time, the default.
+func LoadScheduleLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return JST, nil
+	}
+	loc, err := time.LoadLocation(name)
 	if err != nil {
-		// This is a fatal error for the application, so panic.
-		panic(fmt.Sprintf("Error loading location 'Asia/Tokyo': %v", err))
+		return nil, fmt.Errorf("unknown time zone '%s': %w", name, err)
+	}
+	return loc, nil
+}
+
+// scheduleLocation returns loc, the time zone of a schedule, or JST when it is nil.
+func scheduleLocation(loc *time.Location) *time.Location {
+	if loc == nil {
+		return JST
 	}
+	return loc
 }
 
 // DayOfWeekMap maps Japanese day of the week to time.Weekday
@@ -27,31 +53,174 @@ var DayOfWeekMap = map[string]time.Weekday{
 	"土": time.Saturday,
 }
 
-// CalculateRecentPastRunTime calculates the most recent past run time for a schedule entry.
-func CalculateRecentPastRunTime(entry ScheduleEntry, now time.Time) (time.Time, error) {
+// ErrNotAired is returned for one-shot entries (ScheduleEntry.Date) whose broadcast is still to come,
+// and for entries repeating every few weeks (ScheduleEntry.EveryWeeks) before their first broadcast.
+var ErrNotAired = errors.New("has not aired yet")
+
+// maxRecurrenceWeeks bounds the search for a week matching ScheduleEntry.WeeksOfMonth. A fifth
+// weekday, the rarest, occurs at least once a quarter.
+const maxRecurrenceWeeks = 26
+
+// datedTime returns the broadcast start on the Date of entry in loc. A day_of_week given
+// alongside the date has to agree with it.
+func datedTime(entry ScheduleEntry, loc *time.Location) (time.Time, error) {
+	loc = scheduleLocation(loc)
+	day, err := time.ParseInLocation("2006-01-02", entry.Date, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date '%s': %w", entry.Date, err)
+	}
+	if entry.DayOfWeek != "" && japaneseDayOfWeek(day.Weekday()) != entry.DayOfWeek {
+		return time.Time{}, fmt.Errorf("date %s is a %s, not a %s", entry.Date, japaneseDayOfWeek(day.Weekday()), entry.DayOfWeek)
+	}
+	startTime, err := time.Parse("150405", entry.StartTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start time format '%s': %w", entry.StartTime, err)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), startTime.Hour(), startTime.Minute(), startTime.Second(), 0, loc), nil
+}
+
+// checkRecurrence validates the WeeksOfMonth and EveryWeeks of entry.
+func checkRecurrence(entry ScheduleEntry) error {
+	for _, week := range entry.WeeksOfMonth {
+		if week == 0 || week < -1 || week > 5 {
+			return fmt.Errorf("invalid week of month %d: use 1-5, or -1 for the last", week)
+		}
+	}
+	switch {
+	case entry.EveryWeeks < 0:
+		return fmt.Errorf("invalid every_weeks %d", entry.EveryWeeks)
+	case entry.EveryWeeks > 0 && entry.Date == "":
+		return fmt.Errorf("every_weeks needs the date of a broadcast to count from")
+	case len(entry.WeeksOfMonth) > 0 && entry.Date != "":
+		return fmt.Errorf("weeks_of_month cannot be combined with date")
+	}
+	return nil
+}
+
+// inWeeksOfMonth reports whether t falls on one of the weeks of its month, as in ScheduleEntry.WeeksOfMonth.
+func inWeeksOfMonth(t time.Time, weeks []int) bool {
+	nth := (t.Day()-1)/7 + 1
+	last := t.AddDate(0, 0, 7).Month() != t.Month()
+	for _, week := range weeks {
+		if week == nth || (week == -1 && last) {
+			return true
+		}
+	}
+	return false
+}
+
+// CalculateRecentPastRunTime calculates the most recent past run time for a schedule entry whose
+// days, times and dates are in loc (nil is Japan time). For a one-shot entry it is its broadcast,
+// or an error wrapping ErrNotAired if that is still to come.
+func CalculateRecentPastRunTime(entry ScheduleEntry, now time.Time, loc *time.Location) (time.Time, error) {
+	loc = scheduleLocation(loc)
+	now = now.In(loc)
+	if err := checkRecurrence(entry); err != nil {
+		return time.Time{}, err
+	}
+	if entry.Date != "" {
+		first, err := datedTime(entry, loc)
+		if err == nil && first.After(now) {
+			err = fmt.Errorf("the broadcast at %s %w", first.Format("2006-01-02 15:04"), ErrNotAired)
+		}
+		if err != nil || entry.OneShot() {
+			return first, err
+		}
+		// Every few weeks from the first broadcast: step back from this week's slot to one of them.
+		recent := weeklyRunTime(first, now)
+		weeks := int(math.Round(recent.Sub(first).Hours()/24)) / 7 // Rounded over daylight saving changes.
+		return recent.AddDate(0, 0, -7*(weeks%entry.EveryWeeks)), nil
+	}
+
 	targetWeekday, ok := DayOfWeekMap[entry.DayOfWeek]
 	if !ok {
 		return time.Time{}, fmt.Errorf("invalid day of week: %s", entry.DayOfWeek)
 	}
 
-	startTime, err := time.ParseInLocation("150405", entry.StartTime, JST)
+	startTime, err := time.Parse("150405", entry.StartTime)
 	if err != nil {
 		return time.Time{}, fmt.Errorf("invalid start time format '%s': %w", entry.StartTime, err)
 	}
 
-	// Calculate the difference in days from today to the target weekday
-	daysOffset := int(targetWeekday) - int(now.Weekday())
-
-	// Create a candidate time for this week at the target start time.
-	candidate := time.Date(now.Year(), now.Month(), now.Day(), startTime.Hour(), startTime.Minute(), startTime.Second(), 0, JST)
-	candidate = candidate.AddDate(0, 0, daysOffset)
+	// The slot this week; its most recent occurrence is the run time of a weekly entry.
+	slot := time.Date(now.Year(), now.Month(), now.Day()+int(targetWeekday)-int(now.Weekday()), startTime.Hour(), startTime.Minute(), startTime.Second(), 0, loc)
+	candidate := weeklyRunTime(slot, now)
+	if len(entry.WeeksOfMonth) == 0 {
+		return candidate, nil
+	}
+	// Only some weeks of the month: step back to the latest of them.
+	for i := 0; i < maxRecurrenceWeeks; i++ {
+		if inWeeksOfMonth(candidate, entry.WeeksOfMonth) {
+			return candidate, nil
+		}
+		candidate = candidate.AddDate(0, 0, -7)
+	}
+	return time.Time{}, fmt.Errorf("no broadcast on weeks %v of the month in the last %d weeks", entry.WeeksOfMonth, maxRecurrenceWeeks)
+}
 
-	// Now check if this candidate is in the past or future relative to 'now'.
+// weeklyRunTime returns the most recent time at or before now that falls on the weekday and
+// time of day of slot.
+func weeklyRunTime(slot, now time.Time) time.Time {
+	candidate := time.Date(now.Year(), now.Month(), now.Day(), slot.Hour(), slot.Minute(), slot.Second(), 0, slot.Location())
+	candidate = candidate.AddDate(0, 0, int(slot.Weekday())-int(now.Weekday()))
 	if candidate.After(now) {
 		// If the candidate is in the future, then the most recent past occurrence must be last week.
-		return candidate.AddDate(0, 0, -7), nil
-	} else {
-		// If the candidate is in the past or exactly 'now', then this is the most recent past.
-		return candidate, nil
+		return candidate.AddDate(0, 0, -7)
+	}
+	return candidate
+}
+
+// ErrOutsideTimeshift is returned for broadcasts that have left radiko's timeshift window
+// (TimeshiftWindow), so their playlist can no longer be fetched.
+var ErrOutsideTimeshift = errors.New("outside radiko's 7-day timeshift window")
+
+// timeshiftMargin is how close to the end of the timeshift window a broadcast has to be
+// for a warning that the download may not finish in time.
+const timeshiftMargin = time.Hour
+
+// checkTimeshift returns an error wrapping ErrOutsideTimeshift if the broadcast starting at start
+// can no longer be played back at now, and how long it can still be played back otherwise.
+func checkTimeshift(start, now time.Time) (time.Duration, error) {
+	left := start.Add(TimeshiftWindow).Sub(now)
+	if left <= 0 {
+		return 0, fmt.Errorf("the broadcast at %s is %w", start.In(JST).Format("2006-01-02 15:04"), ErrOutsideTimeshift)
+	}
+	return left, nil
+}
+
+// CalculateNextRunTime calculates the next future run time for a schedule entry, passing over
+// broadcasts it skips (SkipDates, SkipHolidays). One-shot entries that have aired have none.
+// The entry is read in loc, as for CalculateRecentPastRunTime.
+func CalculateNextRunTime(entry ScheduleEntry, now time.Time, loc *time.Location) (time.Time, error) {
+	next, err := nextRunTime(entry, now, loc)
+	for i := 0; err == nil && i < maxRecurrenceWeeks; i++ {
+		if _, skip := skipReason(entry, next, loc); !skip {
+			return next, nil
+		}
+		next, err = nextRunTime(entry, next, loc)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("the next %d broadcasts are all skipped", maxRecurrenceWeeks)
+}
+
+// nextRunTime returns the first broadcast of entry, read in loc, after now.
+func nextRunTime(entry ScheduleEntry, now time.Time, loc *time.Location) (time.Time, error) {
+	recent, err := CalculateRecentPastRunTime(entry, now, loc)
+	switch {
+	case errors.Is(err, ErrNotAired):
+		return datedTime(entry, loc)
+	case err != nil:
+		return time.Time{}, err
+	case entry.OneShot():
+		return time.Time{}, fmt.Errorf("the one-shot broadcast at %s has aired", recent.Format("2006-01-02 15:04"))
+	case entry.EveryWeeks > 0:
+		return recent.AddDate(0, 0, 7*entry.EveryWeeks), nil
+	}
+	next := recent.AddDate(0, 0, 7)
+	for len(entry.WeeksOfMonth) > 0 && !inWeeksOfMonth(next, entry.WeeksOfMonth) {
+		next = next.AddDate(0, 0, 7)
 	}
+	return next, nil
 }
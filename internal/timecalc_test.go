@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -98,7 +99,7 @@ func TestCalculateRecentPastRunTime(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := CalculateRecentPastRunTime(tt.entry, tt.now)
+			result, err := CalculateRecentPastRunTime(tt.entry, tt.now, nil)
 
 			if tt.expectError {
 				if err == nil {
@@ -115,3 +116,195 @@ func TestCalculateRecentPastRunTime(t *testing.T) {
 		})
 	}
 }
+
+func TestOneShotRunTimes(t *testing.T) {
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST) // Tuesday
+	tests := []struct {
+		name     string
+		entry    ScheduleEntry
+		wantPast time.Time
+		wantNext time.Time
+		notAired bool
+		invalid  bool
+	}{
+		{
+			name:     "aired",
+			entry:    ScheduleEntry{Date: "2026-01-12", StartTime: "010000"},
+			wantPast: time.Date(2026, time.January, 12, 1, 0, 0, 0, JST),
+		},
+		{
+			name:     "later today",
+			entry:    ScheduleEntry{Date: "2026-01-13", StartTime: "150000"},
+			wantNext: time.Date(2026, time.January, 13, 15, 0, 0, 0, JST),
+			notAired: true,
+		},
+		{
+			name:     "months ahead with a matching weekday",
+			entry:    ScheduleEntry{Date: "2026-05-03", DayOfWeek: "日", StartTime: "200000"},
+			wantNext: time.Date(2026, time.May, 3, 20, 0, 0, 0, JST),
+			notAired: true,
+		},
+		{name: "weekday disagrees with the date", entry: ScheduleEntry{Date: "2026-01-12", DayOfWeek: "火", StartTime: "010000"}, invalid: true},
+		{name: "invalid date", entry: ScheduleEntry{Date: "2026/01/12", StartTime: "010000"}, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			past, pastErr := CalculateRecentPastRunTime(tt.entry, now, nil)
+			next, nextErr := CalculateNextRunTime(tt.entry, now, nil)
+			switch {
+			case tt.invalid:
+				if pastErr == nil || nextErr == nil || errors.Is(pastErr, ErrNotAired) {
+					t.Errorf("expected the entry to be invalid, got %v and %v", pastErr, nextErr)
+				}
+			case tt.notAired:
+				if !errors.Is(pastErr, ErrNotAired) {
+					t.Errorf("expected ErrNotAired, got %s (%v)", past, pastErr)
+				}
+				if nextErr != nil || !next.Equal(tt.wantNext) {
+					t.Errorf("expected the next run at %s, got %s (%v)", tt.wantNext, next, nextErr)
+				}
+			default:
+				if pastErr != nil || !past.Equal(tt.wantPast) {
+					t.Errorf("expected the past run at %s, got %s (%v)", tt.wantPast, past, pastErr)
+				}
+				if nextErr == nil {
+					t.Errorf("expected no next run for an aired one-shot, got %s", next)
+				}
+			}
+		})
+	}
+}
+
+func TestRecurrenceRunTimes(t *testing.T) {
+	tuesday := time.Date(2026, time.January, 13, 10, 0, 0, 0, JST) // The 2nd Tuesday of January
+	at := func(month time.Month, day, hour int) time.Time {
+		year := 2026
+		if month == time.December {
+			year = 2025
+		}
+		return time.Date(year, month, day, hour, 0, 0, 0, JST)
+	}
+	tests := []struct {
+		name     string
+		entry    ScheduleEntry
+		now      time.Time
+		wantPast time.Time
+		wantNext time.Time
+		notAired bool
+		invalid  bool
+	}{
+		{
+			name:     "1st and 3rd Tuesday, now the 2nd",
+			entry:    ScheduleEntry{DayOfWeek: "火", StartTime: "030000", WeeksOfMonth: []int{1, 3}},
+			now:      tuesday,
+			wantPast: at(time.January, 6, 3),
+			wantNext: at(time.January, 20, 3),
+		},
+		{
+			name:     "1st and 3rd Tuesday, now the 3rd before the slot",
+			entry:    ScheduleEntry{DayOfWeek: "火", StartTime: "150000", WeeksOfMonth: []int{1, 3}},
+			now:      at(time.January, 20, 10),
+			wantPast: at(time.January, 6, 15),
+			wantNext: at(time.January, 20, 15),
+		},
+		{
+			name:     "monthly across the month boundary",
+			entry:    ScheduleEntry{DayOfWeek: "火", StartTime: "030000", WeeksOfMonth: []int{1}},
+			now:      at(time.January, 28, 10),
+			wantPast: at(time.January, 6, 3),
+			wantNext: at(time.February, 3, 3),
+		},
+		{
+			name:     "last Tuesday",
+			entry:    ScheduleEntry{DayOfWeek: "火", StartTime: "030000", WeeksOfMonth: []int{-1}},
+			now:      at(time.February, 1, 10),
+			wantPast: at(time.January, 27, 3),
+			wantNext: at(time.February, 24, 3),
+		},
+		{
+			name:     "5th Tuesday, none in February",
+			entry:    ScheduleEntry{DayOfWeek: "火", StartTime: "030000", WeeksOfMonth: []int{5}},
+			now:      tuesday,
+			wantPast: at(time.December, 30, 3),
+			wantNext: at(time.March, 31, 3),
+		},
+		{
+			name:     "biweekly, aired last week",
+			entry:    ScheduleEntry{Date: "2026-01-06", StartTime: "030000", EveryWeeks: 2},
+			now:      tuesday,
+			wantPast: at(time.January, 6, 3),
+			wantNext: at(time.January, 20, 3),
+		},
+		{
+			name:     "biweekly across the year boundary",
+			entry:    ScheduleEntry{Date: "2025-12-30", DayOfWeek: "火", StartTime: "030000", EveryWeeks: 2},
+			now:      tuesday,
+			wantPast: at(time.January, 13, 3),
+			wantNext: at(time.January, 27, 3),
+		},
+		{
+			name:     "biweekly before the first broadcast",
+			entry:    ScheduleEntry{Date: "2026-01-20", StartTime: "030000", EveryWeeks: 2},
+			now:      tuesday,
+			wantNext: at(time.January, 20, 3),
+			notAired: true,
+		},
+		{name: "invalid week", entry: ScheduleEntry{DayOfWeek: "火", StartTime: "030000", WeeksOfMonth: []int{0}}, now: tuesday, invalid: true},
+		{name: "every_weeks without a date", entry: ScheduleEntry{DayOfWeek: "火", StartTime: "030000", EveryWeeks: 2}, now: tuesday, invalid: true},
+		{name: "weeks_of_month with a date", entry: ScheduleEntry{Date: "2026-01-06", StartTime: "030000", WeeksOfMonth: []int{1}}, now: tuesday, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			past, pastErr := CalculateRecentPastRunTime(tt.entry, tt.now, nil)
+			next, nextErr := CalculateNextRunTime(tt.entry, tt.now, nil)
+			if tt.invalid {
+				if pastErr == nil || nextErr == nil {
+					t.Errorf("expected the entry to be invalid, got %s and %s", past, next)
+				}
+				return
+			}
+			if tt.notAired {
+				if !errors.Is(pastErr, ErrNotAired) {
+					t.Errorf("expected ErrNotAired, got %s (%v)", past, pastErr)
+				}
+			} else if pastErr != nil || !past.Equal(tt.wantPast) {
+				t.Errorf("expected the past run at %s, got %s (%v)", tt.wantPast, past, pastErr)
+			}
+			if nextErr != nil || !next.Equal(tt.wantNext) {
+				t.Errorf("expected the next run at %s, got %s (%v)", tt.wantNext, next, nextErr)
+			}
+		})
+	}
+}
+
+func TestScheduleLocation(t *testing.T) {
+	newYork, err := LoadScheduleLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadScheduleLocation failed: %v", err)
+	}
+
+	// Monday 20:00 in New York is Tuesday 10:00 in Japan; the same entry read in Japan time
+	// is unaffected by schedules in other time zones.
+	weekly := ScheduleEntry{DayOfWeek: "月", StartTime: "200000"}
+	now := time.Date(2026, time.January, 13, 11, 0, 0, 0, JST)
+	if got, err := CalculateRecentPastRunTime(weekly, now, newYork); err != nil || !got.Equal(time.Date(2026, time.January, 13, 10, 0, 0, 0, JST)) {
+		t.Errorf("expected the slot in New York time, got %s (%v)", got, err)
+	}
+	if got, err := CalculateRecentPastRunTime(weekly, now, nil); err != nil || !got.Equal(time.Date(2026, time.January, 12, 20, 0, 0, 0, JST)) {
+		t.Errorf("expected the slot in Japan time, got %s (%v)", got, err)
+	}
+
+	// Counting weeks across the start of daylight saving time on March 8th.
+	biweekly := ScheduleEntry{Date: "2026-03-02", StartTime: "200000", EveryWeeks: 2}
+	now = time.Date(2026, time.March, 16, 23, 0, 0, 0, newYork)
+	if got, err := CalculateRecentPastRunTime(biweekly, now, newYork); err != nil || !got.Equal(time.Date(2026, time.March, 16, 20, 0, 0, 0, newYork)) {
+		t.Errorf("expected the broadcast two weeks after the first, got %s (%v)", got, err)
+	}
+
+	if _, err := LoadScheduleLocation("Asia/Nowhere"); err == nil {
+		t.Error("expected an unknown time zone to be rejected")
+	}
+	if loc, err := LoadScheduleLocation(""); err != nil || loc != JST {
+		t.Errorf("expected Japan time by default, got %v (%v)", loc, err)
+	}
+}
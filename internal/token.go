@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TokenAuthenticator is implemented by providers whose authentication yields a token that
+// stays valid for a while, so it can be reused by later jobs instead of authenticating again.
+type TokenAuthenticator interface {
+	// TokenKey identifies what the token is valid for (e.g. the provider and account);
+	// an empty key disables caching.
+	TokenKey() string
+	// AuthToken returns the token obtained by the last Authenticate and when it expires.
+	AuthToken() (token string, expires time.Time)
+	// UseAuthToken prepares the provider with a cached token instead of calling Authenticate.
+	UseAuthToken(ctx context.Context, token string) error
+}
+
+// cachedToken is an auth token kept in a TokenCache.
+type cachedToken struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// TokenCache keeps auth tokens between jobs, in memory and optionally in a file,
+// so recording many programs back-to-back authenticates once. A nil *TokenCache caches nothing.
+type TokenCache struct {
+	path  string // Empty keeps tokens in memory only.
+	clock Clock
+
+	mu     sync.Mutex
+	tokens map[string]cachedToken
+}
+
+// NewTokenCache returns a cache persisted to path (empty for memory only), loading the
+// tokens saved there by an earlier run. Expiry is judged by clock (SystemClock if nil).
+func NewTokenCache(path string, clock Clock) *TokenCache {
+	if clock == nil {
+		clock = SystemClock
+	}
+	c := &TokenCache{path: path, clock: clock, tokens: make(map[string]cachedToken)}
+	if path != "" {
+		// An unreadable or corrupt file only means authenticating once more.
+		if data, err := os.ReadFile(path); err == nil {
+			_ = json.Unmarshal(data, &c.tokens)
+		}
+	}
+	return c
+}
+
+// Get returns the unexpired token cached under key.
+func (c *TokenCache) Get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tokens[key]
+	if !ok || !c.clock.Now().Before(t.Expires) {
+		return "", false
+	}
+	return t.Token, true
+}
+
+// Put caches token under key until expires.
+func (c *TokenCache) Put(key, token string, expires time.Time) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[key] = cachedToken{Token: token, Expires: expires}
+	return c.save()
+}
+
+// Invalidate drops the token cached under key, e.g. after the service rejected it.
+func (c *TokenCache) Invalidate(key string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.tokens[key]; !ok {
+		return nil
+	}
+	delete(c.tokens, key)
+	return c.save()
+}
+
+// save writes the unexpired tokens to the cache file. The caller holds c.mu.
+func (c *TokenCache) save() error {
+	if c.path == "" {
+		return nil
+	}
+	now := c.clock.Now()
+	for key, t := range c.tokens {
+		if !now.Before(t.Expires) {
+			delete(c.tokens, key)
+		}
+	}
+	data, err := json.MarshalIndent(c.tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode token cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create token cache directory: %w", err)
+	}
+	// Tokens grant access to the account, so the file is private like config.json.
+	if err := os.WriteFile(c.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write token cache '%s': %w", c.path, err)
+	}
+	return nil
+}
+
+// tokenKey returns the key provider's token is cached under, or "" when it is not cached.
+func (o JobOptions) tokenKey(provider Provider) string {
+	if t, ok := provider.(TokenAuthenticator); ok && o.Tokens != nil {
+		return t.TokenKey()
+	}
+	return ""
+}
+
+// authenticate prepares provider with a token cached by an earlier job, falling back to
+// Authenticate. It reports whether a cached token was used.
+func (o JobOptions) authenticate(ctx context.Context, logger *log.Logger, provider Provider) (cached bool, err error) {
+	if key := o.tokenKey(provider); key != "" {
+		if token, ok := o.Tokens.Get(key); ok {
+			err := o.request(ctx, func(ctx context.Context) error {
+				return provider.(TokenAuthenticator).UseAuthToken(ctx, token)
+			})
+			if err == nil {
+				return true, nil
+			}
+			logger.Printf("WARNING: Failed to use the cached auth token: %v", err)
+		}
+	}
+	return false, o.reauthenticate(ctx, logger, provider)
+}
+
+// reauthenticate calls provider.Authenticate, replacing any cached token with the new one.
+func (o JobOptions) reauthenticate(ctx context.Context, logger *log.Logger, provider Provider) error {
+	key := o.tokenKey(provider)
+	if err := o.Tokens.Invalidate(key); err != nil {
+		logger.Printf("WARNING: %v", err)
+	}
+	if err := o.request(ctx, provider.Authenticate); err != nil {
+		return err
+	}
+	if key == "" {
+		return nil
+	}
+	if token, expires := provider.(TokenAuthenticator).AuthToken(); token != "" {
+		if err := o.Tokens.Put(key, token, expires); err != nil {
+			logger.Printf("WARNING: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// tokenMockClient issues numbered tokens and only resolves playlists with one it accepts.
+type tokenMockClient struct {
+	MockRadikoClient
+	issued   int
+	token    string
+	rejected string // Token refused by ResolvePlaylist.
+}
+
+func (m *tokenMockClient) Authenticate(ctx context.Context) error {
+	m.issued++
+	m.token = fmt.Sprintf("token%d", m.issued)
+	return nil
+}
+
+func (m *tokenMockClient) ResolvePlaylist(ctx context.Context, stationID string, start time.Time) (string, error) {
+	if m.token == "" || m.token == m.rejected {
+		return "", fmt.Errorf("unauthorized")
+	}
+	return m.MockRadikoClient.ResolvePlaylist(ctx, stationID, start)
+}
+
+func (m *tokenMockClient) TokenKey() string { return "mock" }
+
+func (m *tokenMockClient) AuthToken() (string, time.Time) {
+	return m.token, time.Date(2026, time.January, 12, 11, 0, 0, 0, JST)
+}
+
+func (m *tokenMockClient) UseAuthToken(ctx context.Context, token string) error {
+	m.token = token
+	return nil
+}
+
+func TestExecuteJobReusesToken(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, time.January, 12, 10, 30, 0, 0, JST)}
+	tokens := NewTokenCache("", clock)
+	opts := JobOptions{
+		Logger:     log.New(io.Discard, "", 0),
+		FetchGuide: func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+		Tokens:     tokens,
+	}
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	record := func(client *tokenMockClient, name string) {
+		t.Helper()
+		opts.OutputDir = t.TempDir()
+		if _, err := ExecuteJob(context.Background(), client, ScheduleEntry{ProgramName: name, StationID: "ST1"}, pastTime, opts); err != nil {
+			t.Fatalf("ExecuteJob failed: %v", err)
+		}
+	}
+
+	first, second := &tokenMockClient{}, &tokenMockClient{}
+	record(first, "First")
+	record(second, "Second")
+	if first.issued != 1 || second.issued != 0 || second.token != "token1" {
+		t.Errorf("expected the second job to reuse the first token, got %d/%d authentications (%q)", first.issued, second.issued, second.token)
+	}
+
+	// A rejected token is dropped and replaced by a fresh one.
+	third := &tokenMockClient{issued: 1, rejected: "token1"}
+	record(third, "Third")
+	if third.issued != 2 {
+		t.Errorf("expected a rejected token to trigger authentication, got %d", third.issued)
+	}
+	if token, ok := tokens.Get("mock"); !ok || token != "token2" {
+		t.Errorf("expected the fresh token to be cached, got %q %v", token, ok)
+	}
+
+	// Expired tokens are not reused.
+	clock.now = clock.now.Add(time.Hour)
+	fourth := &tokenMockClient{}
+	record(fourth, "Fourth")
+	if fourth.issued != 1 {
+		t.Errorf("expected an expired token to trigger authentication, got %d", fourth.issued)
+	}
+}
+
+func TestTokenCachePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", TokenCacheFile)
+	clock := &fakeClock{now: time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)}
+
+	cache := NewTokenCache(path, clock)
+	if err := cache.Put("radiko", "abc", clock.now.Add(time.Hour)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := cache.Put("old", "xyz", clock.now.Add(-time.Minute)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected a token file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected mode 0600, got %04o", perm)
+	}
+
+	reloaded := NewTokenCache(path, clock)
+	if token, ok := reloaded.Get("radiko"); !ok || token != "abc" {
+		t.Errorf("expected the token to survive a restart, got %q %v", token, ok)
+	}
+	if _, ok := reloaded.Get("old"); ok {
+		t.Error("expected the expired token to be dropped")
+	}
+	if err := reloaded.Invalidate("radiko"); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	if _, ok := NewTokenCache(path, clock).Get("radiko"); ok {
+		t.Error("expected the invalidated token to be gone from the file")
+	}
+
+	var disabled *TokenCache
+	if err := disabled.Put("radiko", "abc", clock.now.Add(time.Hour)); err != nil {
+		t.Errorf("nil cache Put failed: %v", err)
+	}
+	if _, ok := disabled.Get("radiko"); ok {
+		t.Error("expected a nil cache to cache nothing")
+	}
+}
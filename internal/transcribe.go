@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Transcript formats, as accepted by the response_format of OpenAI-compatible endpoints.
+const (
+	TranscriptVTT  = "vtt"
+	TranscriptText = "text"
+)
+
+// TranscriptionConfig enables transcribing recordings through a whisper.cpp server or another
+// OpenAI-compatible transcription endpoint. Transcription is off when URL is empty.
+type TranscriptionConfig struct {
+	URL            string `json:"url"`                       // e.g. http://127.0.0.1:8080/v1/audio/transcriptions
+	APIKey         string `json:"api_key,omitempty"`         // Sent as a bearer token, for hosted endpoints.
+	Model          string `json:"model,omitempty"`           // Defaults to "whisper-1".
+	Language       string `json:"language,omitempty"`        // Defaults to "ja".
+	Format         string `json:"format,omitempty"`          // TranscriptVTT (default) or TranscriptText.
+	TimeoutMinutes int    `json:"timeout_minutes,omitempty"` // Limit of each request. Defaults to 60.
+}
+
+// Transcriber sends recordings to the transcription endpoint of a TranscriptionConfig.
+type Transcriber struct {
+	cfg    TranscriptionConfig
+	client *http.Client
+}
+
+// NewTranscriber returns the Transcriber of cfg, or nil when transcription is off.
+// A nil client means http.DefaultClient.
+func NewTranscriber(cfg TranscriptionConfig, client *http.Client) (*Transcriber, error) {
+	if cfg.URL == "" {
+		return nil, nil
+	}
+	if cfg.Model == "" {
+		cfg.Model = "whisper-1"
+	}
+	if cfg.Language == "" {
+		cfg.Language = "ja"
+	}
+	switch cfg.Format {
+	case "":
+		cfg.Format = TranscriptVTT
+	case TranscriptVTT, TranscriptText:
+	default:
+		return nil, fmt.Errorf("unknown transcription format: %s", cfg.Format)
+	}
+	if cfg.TimeoutMinutes <= 0 {
+		cfg.TimeoutMinutes = 60
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Transcriber{cfg: cfg, client: client}, nil
+}
+
+// transcriptPath returns where the transcript of the audio file at path is written.
+func (t *Transcriber) transcriptPath(path string) string {
+	ext := ".txt"
+	if t.cfg.Format == TranscriptVTT {
+		ext = ".vtt"
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ext
+}
+
+// Transcribe uploads the audio file at path and returns its transcript.
+func (t *Transcriber) Transcribe(ctx context.Context, path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// The audio is streamed between the form fields and the closing boundary, so that
+	// multi-hour recordings are not held in memory and the length is still known.
+	var head bytes.Buffer
+	form := multipart.NewWriter(&head)
+	for _, field := range [][2]string{{"model", t.cfg.Model}, {"language", t.cfg.Language}, {"response_format", t.cfg.Format}} {
+		if err := form.WriteField(field[0], field[1]); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := form.CreateFormFile("file", filepath.Base(path)); err != nil {
+		return nil, err
+	}
+	tail := "\r\n--" + form.Boundary() + "--\r\n"
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.cfg.TimeoutMinutes)*time.Minute)
+	defer cancel()
+	body := io.MultiReader(bytes.NewReader(head.Bytes()), file, strings.NewReader(tail))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.URL, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcription request: %w", err)
+	}
+	req.ContentLength = int64(head.Len()) + info.Size() + int64(len(tail))
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if t.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.cfg.APIKey)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("transcription request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("transcription request failed: HTTP status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	transcript, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+	return transcript, nil
+}
+
+// transcribeRecording writes a transcript next to each audio file of the recording at path
+// (one per part of a rotated recording) and returns their paths by audio file. Failures are
+// logged; the recording is kept either way. A nil *Transcriber transcribes nothing.
+func (t *Transcriber) transcribeRecording(ctx context.Context, logger *log.Logger, path string) map[string]string {
+	if t == nil {
+		return nil
+	}
+	files, err := recordingFiles(path)
+	if err != nil {
+		logger.Printf("WARNING: Failed to transcribe the recording: %v", err)
+		return nil
+	}
+	transcripts := make(map[string]string, len(files))
+	for _, file := range files {
+		logger.Printf("INFO: Transcribing %s...", filepath.Base(file))
+		transcript, err := t.Transcribe(ctx, file)
+		if err != nil {
+			logger.Printf("WARNING: Failed to transcribe '%s': %v", file, err)
+			continue
+		}
+		out := t.transcriptPath(file)
+		if err := writeFileAtomic(out, transcript, 0644); err != nil {
+			logger.Printf("WARNING: Failed to write transcript '%s': %v", out, err)
+			continue
+		}
+		logger.Printf("INFO: Wrote transcript to: %s", out)
+		transcripts[file] = out
+	}
+	return transcripts
+}
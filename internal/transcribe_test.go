@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTranscript = "WEBVTT\n\n00:00:00.000 --> 00:00:05.000\nこんばんは\n"
+
+// transcriptionServer is a stand-in for an OpenAI-compatible endpoint. It checks the request
+// and answers with testTranscript, or fails with status when it is not 0.
+func transcriptionServer(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != 0 {
+			http.Error(w, "model not loaded", status)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer sk-test" {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+		if r.ContentLength <= 0 {
+			t.Errorf("expected a Content-Length, got %d", r.ContentLength)
+		}
+		for field, want := range map[string]string{"model": "large-v3", "language": "ja", "response_format": "vtt"} {
+			if got := r.FormValue(field); got != want {
+				t.Errorf("form field %s = %q, want %q", field, got, want)
+			}
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("expected an audio file: %v", err)
+			return
+		}
+		defer file.Close()
+		if audio, _ := io.ReadAll(file); !strings.Contains(string(audio), dummyAACChunk) || !strings.HasSuffix(header.Filename, ".aac") {
+			t.Errorf("unexpected audio %s: %q", header.Filename, audio)
+		}
+		io.WriteString(w, testTranscript)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewTranscriber(t *testing.T) {
+	if tr, err := NewTranscriber(TranscriptionConfig{}, nil); tr != nil || err != nil {
+		t.Errorf("expected transcription to be off without a URL, got %v, %v", tr, err)
+	}
+	if _, err := NewTranscriber(TranscriptionConfig{URL: "http://localhost", Format: "srt"}, nil); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	tr, err := NewTranscriber(TranscriptionConfig{URL: "http://localhost", Format: TranscriptText}, nil)
+	if err != nil {
+		t.Fatalf("NewTranscriber failed: %v", err)
+	}
+	if got := tr.transcriptPath(filepath.Join("out", "Show.part01.aac")); got != filepath.Join("out", "Show.part01.txt") {
+		t.Errorf("transcriptPath = %s", got)
+	}
+}
+
+func TestExecuteJobTranscription(t *testing.T) {
+	pastTime := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+	entry := ScheduleEntry{ProgramName: "Show", StationID: "ST1"}
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"success", 0},
+		{"endpoint failure", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := transcriptionServer(t, tt.status)
+			tr, err := NewTranscriber(TranscriptionConfig{URL: server.URL + "/v1/audio/transcriptions", APIKey: "sk-test", Model: "large-v3"}, server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputDir := t.TempDir()
+			var logs bytes.Buffer
+			opts := JobOptions{
+				OutputDir:   outputDir,
+				Logger:      log.New(&logs, "", 0),
+				Transcriber: tr,
+				FetchGuide:  func(context.Context, string) ([]byte, error) { return nil, fmt.Errorf("offline") },
+
+				DisableProgressBar: true,
+			}
+			if _, err := ExecuteJob(context.Background(), &MockRadikoClient{}, entry, pastTime, opts); err != nil {
+				t.Fatalf("ExecuteJob failed: %v", err)
+			}
+
+			transcript, err := os.ReadFile(filepath.Join(outputDir, "20260112100000-ST1-Show.vtt"))
+			var m Manifest
+			data, _ := os.ReadFile(filepath.Join(outputDir, "20260112100000-ST1-Show"+manifestExt))
+			if jsonErr := json.Unmarshal(data, &m); jsonErr != nil {
+				t.Fatalf("invalid manifest: %v", jsonErr)
+			}
+			if tt.status != 0 {
+				if !os.IsNotExist(err) || m.Transcript != "" {
+					t.Errorf("expected no transcript, got %q (%v)", transcript, err)
+				}
+				if !strings.Contains(logs.String(), "WARNING: Failed to transcribe") || !strings.Contains(logs.String(), "model not loaded") {
+					t.Errorf("expected a warning with the endpoint's error, got:\n%s", logs.String())
+				}
+				return
+			}
+			if string(transcript) != testTranscript {
+				t.Errorf("unexpected transcript %q (%v)", transcript, err)
+			}
+			if m.Transcript != "20260112100000-ST1-Show.vtt" {
+				t.Errorf("expected the transcript in the manifest, got %q", m.Transcript)
+			}
+		})
+	}
+}
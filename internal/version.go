@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// goRadikoModule is the module implementing radiko's auth, guide and playlist API.
+const goRadikoModule = "github.com/yyoshiki41/go-radiko"
+
+// BuildInfo describes how the running binary was built.
+type BuildInfo struct {
+	Version   string // Module version, or "(devel)" for a build from a checkout.
+	GoVersion string
+	Revision  string // VCS commit, with "-dirty" appended for a build with uncommitted changes.
+	Time      string // Time of the VCS commit.
+	GoRadiko  string // Version of go-radiko the binary was built with.
+}
+
+// ReadBuildInfo returns the build information embedded in the binary. Fields the binary
+// does not carry, e.g. the revision of a build outside a checkout, are empty.
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Version = bi.Main.Version
+	for _, dep := range bi.Deps {
+		if dep.Path == goRadikoModule {
+			info.GoRadiko = dep.Version
+		}
+	}
+	var modified bool
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.Time = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if modified && info.Revision != "" {
+		info.Revision += "-dirty"
+	}
+	return info
+}
+
+// String formats the build information one field per line, leaving out empty ones.
+func (b BuildInfo) String() string {
+	var sb strings.Builder
+	for _, field := range []struct{ name, value string }{
+		{"version", b.Version},
+		{"revision", b.Revision},
+		{"built from commit at", b.Time},
+		{"go", b.GoVersion},
+		{"go-radiko", b.GoRadiko},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(&sb, "%-21s %s\n", field.name+":", field.value)
+		}
+	}
+	return sb.String()
+}
+
+// CompatibilityCheck is the result of one step of CheckCompatibility.
+type CompatibilityCheck struct {
+	Name   string // "auth", "playlist" or "chunks".
+	Detail string // What was found, when the step succeeded.
+	Err    error
+}
+
+// compatibilityWindow is the length of the timeshift window CheckCompatibility resolves,
+// ending compatibilityDelay before now so radiko has it in the timeshift archive.
+const (
+	compatibilityWindow = 5 * time.Minute
+	compatibilityDelay  = 10 * time.Minute
+)
+
+// CheckCompatibility probes whether radiko still works the way this binary expects: it
+// authenticates (auth1 and auth2), resolves the timeshift playlist of a few minutes that aired
+// shortly before now on stationID and lists its chunks. The steps run in that order and stop
+// at the first failure, whose check carries the error. The auth token cache is bypassed.
+func CheckCompatibility(ctx context.Context, opts Options, stationID string) []CompatibilityCheck {
+	opts = opts.withDefaults()
+	var client Provider
+	var uri string
+	steps := []struct {
+		name string
+		run  func() (string, error)
+	}{
+		{"auth", func() (string, error) {
+			var err error
+			if client, err = opts.newClient(ctx, ScheduleEntry{StationID: stationID}); err != nil {
+				return "", err
+			}
+			if err := client.Authenticate(ctx); err != nil {
+				return "", explainAuthError(ProviderRadiko, err)
+			}
+			return "token issued", nil
+		}},
+		{"playlist", func() (string, error) {
+			to := opts.Clock.Now().In(JST).Add(-compatibilityDelay).Truncate(time.Minute)
+			from := to.Add(-compatibilityWindow)
+			var err error
+			if uri, err = resolveRangePlaylist(ctx, client, stationID, from, to); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s %s-%s", stationID, from.Format("15:04"), to.Format("15:04")), nil
+		}},
+		{"chunks", func() (string, error) {
+			chunks, err := client.ListChunks(ctx, uri)
+			if err != nil {
+				return "", err
+			}
+			if len(chunks) == 0 {
+				return "", fmt.Errorf("the playlist lists no chunks")
+			}
+			return fmt.Sprintf("%d chunks", len(chunks)), nil
+		}},
+	}
+
+	var checks []CompatibilityCheck
+	for _, step := range steps {
+		detail, err := step.run()
+		checks = append(checks, CompatibilityCheck{Name: step.name, Detail: detail, Err: err})
+		if err != nil {
+			break
+		}
+	}
+	return checks
+}
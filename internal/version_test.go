@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadBuildInfo(t *testing.T) {
+	info := ReadBuildInfo()
+	if info.GoVersion == "" {
+		t.Error("GoVersion is empty")
+	}
+	if !strings.Contains(info.String(), "go: ") {
+		t.Errorf("String() = %q, want the Go version", info.String())
+	}
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	now := time.Date(2026, time.January, 13, 18, 0, 0, 0, JST)
+	tests := []struct {
+		name     string
+		client   *rangeMockClient
+		expected []string // Name and outcome of each check.
+	}{
+		{"Compatible", &rangeMockClient{}, []string{"auth ok", "playlist ok", "chunks ok"}},
+		{"Auth fails", &rangeMockClient{MockRadikoClient: MockRadikoClient{
+			AuthenticateFn: func(ctx context.Context) error { return errors.New("auth1: status code 400") },
+		}}, []string{"auth FAIL"}},
+		{"Empty playlist", &rangeMockClient{MockRadikoClient: MockRadikoClient{
+			ListChunksFn: func(ctx context.Context, uri string) ([]string, error) { return nil, nil },
+		}}, []string{"auth ok", "playlist ok", "chunks FAIL"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{
+				Logger:      log.New(&bytes.Buffer{}, "", 0),
+				Clock:       &fakeClock{now: now},
+				NewProvider: func(ctx context.Context) (Provider, error) { return tt.client, nil },
+			}
+			var got []string
+			for _, c := range CheckCompatibility(context.Background(), opts, "TBS") {
+				outcome := "ok"
+				if c.Err != nil {
+					outcome = "FAIL"
+				}
+				got = append(got, c.Name+" "+outcome)
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.expected, ", ") {
+				t.Errorf("CheckCompatibility = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	client := &rangeMockClient{}
+	CheckCompatibility(context.Background(), Options{Clock: &fakeClock{now: now}, NewProvider: func(ctx context.Context) (Provider, error) { return client, nil }}, "TBS")
+	if want := time.Date(2026, time.January, 13, 17, 45, 0, 0, JST); !client.from.Equal(want) || !client.to.Equal(want.Add(compatibilityWindow)) {
+		t.Errorf("resolved %s-%s, want the five minutes from %s", client.from, client.to, want)
+	}
+}
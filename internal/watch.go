@@ -0,0 +1,253 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// scheduleReloadDelay debounces bursts of file events, since editors often write a file in several steps.
+const scheduleReloadDelay = 500 * time.Millisecond
+
+// WatchSchedule watches the schedule file at path and sends the result of load on
+// the returned channel whenever it changes. A file that fails to load is logged and
+// ignored, so the daemon keeps the last good schedule. Watching stops when ctx is cancelled.
+func WatchSchedule(ctx context.Context, path string, load func() ([]ScheduleEntry, error), logger *log.Logger) (<-chan []ScheduleEntry, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	// Watch the directory rather than the file: editors and config tools often
+	// replace the file by renaming a new one over it, which ends a watch on the file itself.
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch '%s': %w", filepath.Dir(path), err)
+	}
+
+	updates := make(chan []ScheduleEntry)
+	go func() {
+		defer watcher.Close()
+		defer close(updates)
+
+		var reload <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == filepath.Clean(path) && event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					reload = time.After(scheduleReloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Printf("WARNING: Schedule watcher error: %v", err)
+			case <-reload:
+				reload = nil
+				entries, err := load()
+				if err != nil {
+					logger.Printf("WARNING: Failed to reload schedule, keeping the current one: %v", err)
+					continue
+				}
+				select {
+				case updates <- entries:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return updates, nil
+}
+
+// scheduleKey identifies a schedule entry across reloads.
+func scheduleKey(entry ScheduleEntry) string {
+	key := entry.ProgramName + "|" + entry.StationID + "|" + entry.DayOfWeek + "|" + entry.StartTime
+	if entry.OneShot() {
+		key += "|" + entry.Date
+	}
+	return key
+}
+
+// jobTracker keeps the cancel functions and progress of running jobs, so jobs of entries removed
+// from the schedule can be stopped and clients of the daemon can follow and cancel them.
+// A nil *jobTracker tracks nothing.
+type jobTracker struct {
+	mu       sync.Mutex
+	running  map[*trackedJob]struct{}
+	watchers map[chan JobEvent]struct{}
+}
+
+type trackedJob struct {
+	entry    ScheduleEntry
+	start    time.Time // Start of the broadcast.
+	cancel   context.CancelFunc
+	pinned   bool // Not backed by a schedule entry, so never cancelled by cancelRemoved.
+	progress ProgressUpdate
+}
+
+// RunningJob is a recording in progress in the daemon.
+type RunningJob struct {
+	ID          string // Identifies the job while it runs: its station and broadcast start.
+	ProgramName string
+	StationID   string
+	Start       time.Time // Start of the broadcast.
+	Progress    ProgressUpdate
+}
+
+// JobEvent reports that a job started, its progress, or that it finished.
+type JobEvent struct {
+	Job      RunningJob
+	Started  bool // The job just started; nothing is downloaded yet.
+	Finished bool // The job stopped, successfully or not.
+}
+
+// jobEventBuffer is how many events a watcher may fall behind before further ones are dropped.
+const jobEventBuffer = 64
+
+func newJobTracker() *jobTracker {
+	return &jobTracker{running: make(map[*trackedJob]struct{}), watchers: make(map[chan JobEvent]struct{})}
+}
+
+// start returns the context for a job of entry's broadcast at start, a function to report its
+// download progress, and a function to call when the job finishes.
+// Pinned jobs (e.g. subscription matches) are not cancelled by cancelRemoved.
+func (t *jobTracker) start(ctx context.Context, entry ScheduleEntry, start time.Time, pinned bool) (context.Context, func(ProgressUpdate), func()) {
+	if t == nil {
+		return ctx, func(ProgressUpdate) {}, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	job := &trackedJob{entry: entry, start: start, cancel: cancel, pinned: pinned}
+
+	t.mu.Lock()
+	t.running[job] = struct{}{}
+	t.publish(JobEvent{Job: job.snapshot(), Started: true})
+	t.mu.Unlock()
+
+	progress := func(u ProgressUpdate) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		job.progress = u
+		t.publish(JobEvent{Job: job.snapshot()})
+	}
+	return ctx, progress, func() {
+		t.mu.Lock()
+		delete(t.running, job)
+		t.publish(JobEvent{Job: job.snapshot(), Finished: true})
+		t.mu.Unlock()
+		cancel()
+	}
+}
+
+// snapshot returns the job as a RunningJob. The caller holds the tracker's mu.
+func (j *trackedJob) snapshot() RunningJob {
+	return RunningJob{
+		ID:          broadcastKey(j.entry.StationID, j.start),
+		ProgramName: j.entry.ProgramName,
+		StationID:   j.entry.StationID,
+		Start:       j.start,
+		Progress:    j.progress,
+	}
+}
+
+// publish sends ev to every watcher that has room for it. The caller holds t.mu.
+func (t *jobTracker) publish(ev JobEvent) {
+	for ch := range t.watchers {
+		select {
+		case ch <- ev:
+		default: // A slow watcher misses the event; the next one brings it up to date.
+		}
+	}
+}
+
+// watch returns a channel of the events of running jobs, starting with one for each job
+// running now, and a function to stop watching.
+func (t *jobTracker) watch() (<-chan JobEvent, func()) {
+	ch := make(chan JobEvent, jobEventBuffer)
+	if t == nil {
+		return ch, func() {}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for job := range t.running {
+		select {
+		case ch <- JobEvent{Job: job.snapshot()}:
+		default:
+		}
+	}
+	t.watchers[ch] = struct{}{}
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.watchers, ch)
+	}
+}
+
+// list returns the running jobs, in order of broadcast start.
+func (t *jobTracker) list() []RunningJob {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	jobs := make([]RunningJob, 0, len(t.running))
+	for job := range t.running {
+		jobs = append(jobs, job.snapshot())
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		if !jobs[i].Start.Equal(jobs[k].Start) {
+			return jobs[i].Start.Before(jobs[k].Start)
+		}
+		return jobs[i].ID < jobs[k].ID
+	})
+	return jobs
+}
+
+// cancelJob cancels the running job with the given ID and reports whether there was one.
+func (t *jobTracker) cancelJob(id string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	found := false
+	for job := range t.running {
+		if job.snapshot().ID == id {
+			job.cancel()
+			found = true
+		}
+	}
+	return found
+}
+
+// cancelRemoved cancels the running jobs whose entries are not in entries and returns how many were cancelled.
+func (t *jobTracker) cancelRemoved(entries []ScheduleEntry) int {
+	if t == nil {
+		return 0
+	}
+	keep := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		keep[scheduleKey(entry)] = true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cancelled := 0
+	for job := range t.running {
+		if !job.pinned && !keep[scheduleKey(job.entry)] {
+			job.cancel()
+			cancelled++
+		}
+	}
+	return cancelled
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchSchedule(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	if err := os.WriteFile(path, []byte(`[]`), 0644); err != nil {
+		t.Fatalf("Failed to write schedule: %v", err)
+	}
+	var logBuf bytes.Buffer
+	updates, err := WatchSchedule(ctx, path, func() ([]ScheduleEntry, error) { return LoadSchedule(path) }, log.New(&logBuf, "", 0))
+	if err != nil {
+		t.Fatalf("WatchSchedule failed: %v", err)
+	}
+
+	// A broken file is reported and skipped; the next valid version is delivered.
+	if err := os.WriteFile(path, []byte(`[{`), 0644); err != nil {
+		t.Fatalf("Failed to write schedule: %v", err)
+	}
+	time.Sleep(2 * scheduleReloadDelay)
+	valid := `[{"program_name": "P", "day_of_week": "月", "start_time": "100000", "station_id": "ST1"}]`
+	if err := os.WriteFile(path, []byte(valid), 0644); err != nil {
+		t.Fatalf("Failed to write schedule: %v", err)
+	}
+
+	select {
+	case entries := <-updates:
+		if len(entries) != 1 || entries[0].ProgramName != "P" {
+			t.Errorf("unexpected reloaded schedule: %+v", entries)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no schedule update received")
+	}
+	if !strings.Contains(logBuf.String(), "Failed to reload schedule") {
+		t.Errorf("expected broken schedule to be logged, got:\n%s", logBuf.String())
+	}
+
+	cancel()
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Error("expected updates to be closed after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("updates not closed after cancel")
+	}
+}
+
+func TestJobTrackerCancelRemoved(t *testing.T) {
+	a := ScheduleEntry{ProgramName: "A", DayOfWeek: "月", StartTime: "100000", StationID: "ST1"}
+	b := ScheduleEntry{ProgramName: "B", DayOfWeek: "火", StartTime: "100000", StationID: "ST1"}
+
+	tracker := newJobTracker()
+	ctxA, _, doneA := tracker.start(context.Background(), a, time.Time{}, false)
+	defer doneA()
+	ctxB, _, doneB := tracker.start(context.Background(), b, time.Time{}, false)
+	defer doneB()
+	ctxC, _, doneC := tracker.start(context.Background(), ScheduleEntry{ProgramName: "Matched", StationID: "ST2"}, time.Time{}, true)
+	defer doneC()
+
+	if n := tracker.cancelRemoved([]ScheduleEntry{b}); n != 1 {
+		t.Errorf("cancelRemoved = %d, want 1", n)
+	}
+	if ctxA.Err() == nil {
+		t.Error("expected job of removed entry to be cancelled")
+	}
+	if ctxB.Err() != nil {
+		t.Error("job of kept entry was cancelled")
+	}
+	if ctxC.Err() != nil {
+		t.Error("pinned job was cancelled")
+	}
+}
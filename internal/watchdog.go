@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// watchdogGrace is how long a job may keep running past its time limit before the watchdog
+// gives up on it.
+const watchdogGrace = 2 * time.Minute
+
+// errJobStuck is returned for a job abandoned by the watchdog.
+var errJobStuck = errors.New("job did not stop after its time limit")
+
+// jobTimeout returns the time limit of each recording of entry: its TimeoutMinutes, or JobTimeout.
+func (o JobOptions) jobTimeout(entry ScheduleEntry) time.Duration {
+	if entry.TimeoutMinutes > 0 {
+		return time.Duration(entry.TimeoutMinutes) * time.Minute
+	}
+	return o.JobTimeout
+}
+
+// watchdogLimit returns how long a job of entry may run in all before the watchdog abandons it:
+// the time limit of each recording it may make (from its station, each fallback station and
+// the rerun) plus watchdogGrace. 0 means the job is not watched, as it has no time limit.
+func (o JobOptions) watchdogLimit(entry ScheduleEntry) time.Duration {
+	timeout := o.jobTimeout(entry)
+	if timeout <= 0 {
+		return 0
+	}
+	attempts := 1 + len(entry.FallbackStations)
+	if entry.Rerun != nil {
+		attempts++
+	}
+	return timeout*time.Duration(attempts) + watchdogGrace
+}
+
+// watchJob runs job and returns its result, unless it is still running after limit. A job past
+// its time limit has its context cancelled and normally fails right away; one that ignores the
+// cancellation, e.g. blocked on a connection that never times out, is abandoned with errJobStuck
+// and left to finish in the background, so it no longer holds up the jobs waiting for its slot.
+// A limit of 0 runs job without a watchdog.
+func watchJob(ctx context.Context, limit time.Duration, job func(context.Context) (RecordingResult, error)) (RecordingResult, error) {
+	if limit <= 0 {
+		return job(ctx)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type outcome struct {
+		rec RecordingResult
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		rec, err := job(ctx)
+		done <- outcome{rec, err}
+	}()
+
+	timer := time.NewTimer(limit)
+	defer timer.Stop()
+	select {
+	case o := <-done:
+		return o.rec, o.err
+	case <-timer.C:
+		return RecordingResult{}, fmt.Errorf("%w (%s)", errJobStuck, limit)
+	}
+}
+
+// reportJobTimeout records a job of entry that ran out of time: an abandoned job, which wrote no
+// history record of its own, gets a failed one. The Notifier, if any, is told right away, as a
+// job that hangs usually needs a look at the network or the provider.
+// startedAt is when the job was dispatched.
+func (o Options) reportJobTimeout(ctx context.Context, entry ScheduleEntry, start, startedAt time.Time, err error) {
+	if errors.Is(err, errJobStuck) {
+		o.Logger.Printf("ERROR: Abandoned the job of '%s' at %s: %v.", entry.ProgramName, start.In(JST).Format("2006-01-02 15:04"), err)
+		if o.History != nil {
+			record := HistoryRecord{
+				ID:          newJobID(),
+				ProgramName: entry.ProgramName,
+				Title:       entry.ProgramName,
+				StationID:   entry.StationID,
+				Provider:    entry.Provider,
+				StartTime:   start,
+				StartedAt:   startedAt,
+				FinishedAt:  o.Clock.Now(),
+				Status:      StatusFailed,
+				Error:       err.Error(),
+			}
+			if histErr := o.History.Append(record); histErr != nil {
+				o.Logger.Printf("WARNING: Failed to write history: %v", histErr)
+			}
+		}
+	}
+	if o.Notifier == nil {
+		return
+	}
+	subject := fmt.Sprintf("radikoRecScheduler: '%s' timed out", entry.ProgramName)
+	body := fmt.Sprintf("The recording of '%s' (%s, %s) was stopped: %v\n", entry.ProgramName, entry.StationID, start.In(JST).Format("2006-01-02 15:04"), err)
+	if notifyErr := o.Notifier.Notify(ctx, subject, body); notifyErr != nil {
+		o.Logger.Printf("WARNING: Failed to send the timeout notification: %v", notifyErr)
+	}
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdogLimit(t *testing.T) {
+	opts := JobOptions{JobTimeout: 30 * time.Minute}
+	tests := []struct {
+		name     string
+		opts     JobOptions
+		entry    ScheduleEntry
+		expected time.Duration
+	}{
+		{"No limit", JobOptions{}, ScheduleEntry{}, 0},
+		{"Global limit", opts, ScheduleEntry{}, 30*time.Minute + watchdogGrace},
+		{"Entry limit", opts, ScheduleEntry{TimeoutMinutes: 240}, 240*time.Minute + watchdogGrace},
+		{"Entry limit without a global one", JobOptions{}, ScheduleEntry{TimeoutMinutes: 60}, 60*time.Minute + watchdogGrace},
+		{"Fallbacks and rerun", opts, ScheduleEntry{FallbackStations: []string{"A", "B"}, Rerun: &RerunSlot{}}, 4*30*time.Minute + watchdogGrace},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.watchdogLimit(tt.entry); got != tt.expected {
+				t.Errorf("watchdogLimit = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWatchJob(t *testing.T) {
+	rec, err := watchJob(context.Background(), time.Minute, func(ctx context.Context) (RecordingResult, error) {
+		return RecordingResult{OutputPath: "show.aac"}, nil
+	})
+	if err != nil || rec.OutputPath != "show.aac" {
+		t.Errorf("watchJob = (%+v, %v), want the job's result", rec, err)
+	}
+
+	// A job ignoring its context is abandoned, and its context cancelled.
+	release := make(chan struct{})
+	defer close(release)
+	cancelled := make(chan struct{})
+	_, err = watchJob(context.Background(), 20*time.Millisecond, func(ctx context.Context) (RecordingResult, error) {
+		<-release
+		if ctx.Err() != nil {
+			close(cancelled)
+		}
+		return RecordingResult{}, nil
+	})
+	if !errors.Is(err, errJobStuck) {
+		t.Errorf("watchJob error = %v, want errJobStuck", err)
+	}
+	release <- struct{}{}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("the abandoned job's context was not cancelled")
+	}
+}
+
+func TestReportJobTimeout(t *testing.T) {
+	history := OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	var notified []string
+	now := time.Date(2026, time.January, 13, 18, 0, 0, 0, JST)
+	opts := Options{
+		Logger:  log.New(&bytes.Buffer{}, "", 0),
+		Clock:   &fakeClock{now: now},
+		History: history,
+		Notifier: notifierFunc(func(ctx context.Context, subject, body string) error {
+			notified = append(notified, subject)
+			return nil
+		}),
+	}
+	entry := ScheduleEntry{ProgramName: "Show", StationID: "TBS"}
+	start := time.Date(2026, time.January, 12, 10, 0, 0, 0, JST)
+
+	// A job that stopped at its limit wrote its own history record.
+	opts.reportJobTimeout(context.Background(), entry, start, now.Add(-time.Hour), fmt.Errorf("%w after 30m0s", errJobTimeout))
+	if records, _ := history.Records(); len(records) != 0 {
+		t.Errorf("expected no history record for a job that stopped, got %+v", records)
+	}
+
+	opts.reportJobTimeout(context.Background(), entry, start, now.Add(-time.Hour), fmt.Errorf("%w (32m0s)", errJobStuck))
+	records, err := history.Records()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expected one history record, got %+v (%v)", records, err)
+	}
+	if r := records[0]; r.Status != StatusFailed || !r.StartTime.Equal(start) || !strings.Contains(r.Error, "did not stop") {
+		t.Errorf("history record = %+v, want a failure of the broadcast", r)
+	}
+	if len(notified) != 2 || !strings.Contains(notified[0], "Show") {
+		t.Errorf("notifications = %q, want one per timeout", notified)
+	}
+}
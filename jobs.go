@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"radikoRecScheduler/internal"
+)
+
+// runJobsCommand implements the "jobs" subcommand for inspecting the recording history.
+func runJobsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s jobs <list|show> [options]", os.Args[0])
+	}
+
+	source, err := openJobSource()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("jobs list", flag.ExitOnError)
+		limit := fs.Int("n", 20, "Number of most recent jobs to show (0 for all).")
+		fs.Parse(args[1:])
+		return listJobs(os.Stdout, source, *limit)
+	case "show":
+		fs := flag.NewFlagSet("jobs show", flag.ExitOnError)
+		printLog := fs.Bool("log", false, "Print the captured job log after the details.")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: %s jobs show [-log] <id>", os.Args[0])
+		}
+		return showJob(os.Stdout, source, fs.Arg(0), *printLog)
+	default:
+		return fmt.Errorf("unknown jobs command: %s", args[0])
+	}
+}
+
+// jobSource is where job records are read from: the running daemon, or the history file directly.
+type jobSource interface {
+	Jobs(ctx context.Context, limit int) ([]internal.HistoryRecord, error)
+	Job(ctx context.Context, id string) (internal.HistoryRecord, error)
+}
+
+// historyFile reads job records directly from the history file.
+type historyFile struct {
+	*internal.History
+}
+
+func (h historyFile) Jobs(ctx context.Context, limit int) ([]internal.HistoryRecord, error) {
+	records, err := h.Records()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	return records, nil
+}
+
+func (h historyFile) Job(ctx context.Context, id string) (internal.HistoryRecord, error) {
+	return h.Find(id)
+}
+
+// openJobSource returns the running daemon if there is one, and the history file otherwise.
+func openJobSource() (jobSource, error) {
+	if daemon := discoverDaemon(); daemon != nil {
+		return daemon, nil
+	}
+	history, err := openHistory()
+	if err != nil {
+		return nil, err
+	}
+	return historyFile{history}, nil
+}
+
+func listJobs(w io.Writer, source jobSource, limit int) error {
+	records, err := source.Jobs(context.Background(), limit)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tBROADCAST\tSTATION\tTITLE")
+	for _, rec := range records {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", rec.ID, rec.Status, rec.StartTime.In(internal.JST).Format("2006-01-02 15:04"), rec.StationID, rec.Title)
+	}
+	return tw.Flush()
+}
+
+func showJob(w io.Writer, source jobSource, id string, printLog bool) error {
+	rec, err := source.Job(context.Background(), id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "ID:        %s\n", rec.ID)
+	fmt.Fprintf(w, "Status:    %s\n", rec.Status)
+	fmt.Fprintf(w, "Program:   %s\n", rec.ProgramName)
+	fmt.Fprintf(w, "Title:     %s\n", rec.Title)
+	fmt.Fprintf(w, "Station:   %s\n", rec.StationID)
+	fmt.Fprintf(w, "Broadcast: %s\n", rec.StartTime.In(internal.JST).Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "Started:   %s\n", rec.StartedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "Finished:  %s (%s)\n", rec.FinishedAt.Format("2006-01-02 15:04:05"), rec.FinishedAt.Sub(rec.StartedAt).Round(1e9))
+	fmt.Fprintf(w, "Output:    %s\n", rec.OutputPath)
+	if rec.Rerun {
+		fmt.Fprintf(w, "Rerun:     yes (recorded from the rerun slot)\n")
+	}
+	if rec.Error != "" {
+		fmt.Fprintf(w, "Error:     %s\n", rec.Error)
+	}
+	if rec.LogPath != "" {
+		fmt.Fprintf(w, "Log:       %s\n", rec.LogPath)
+	}
+
+	if printLog && rec.LogPath != "" {
+		data, err := os.ReadFile(rec.LogPath)
+		if err != nil {
+			return fmt.Errorf("failed to read job log: %w", err)
+		}
+		fmt.Fprintln(w)
+		w.Write(data)
+	}
+	return nil
+}
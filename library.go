@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"radikoRecScheduler/internal"
+)
+
+// runLibraryCommand implements the "library" subcommand for auditing and repairing recordings.
+func runLibraryCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s library <audit|repair|report> [options]", os.Args[0])
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "audit":
+		fs := flag.NewFlagSet("library audit", flag.ExitOnError)
+		restart := fs.Bool("restart", false, "Start over instead of resuming an interrupted audit.")
+		fs.Parse(args[1:])
+
+		history, err := openHistory()
+		if err != nil {
+			return err
+		}
+		state, err := internal.AuditLibrary(ctx, internal.AuditOptions{History: history, Restart: *restart})
+		if errors.Is(err, context.Canceled) {
+			log.Printf("INFO: Audit interrupted after %d/%d recordings; run it again to resume.", len(state.Checked), state.Total)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		printAuditReport(state)
+		return nil
+	case "repair":
+		config := loadConfig(currentProfile)
+		opts := newOptions(currentProfile, config, nil, false, false)
+		repaired, err := internal.RepairRecordings(ctx, opts)
+		fmt.Printf("Repaired %d recording(s).\n", repaired)
+		return err
+	case "report":
+		history, err := openHistory()
+		if err != nil {
+			return err
+		}
+		state, err := internal.LoadAuditState(history.AuditStatePath())
+		if err != nil {
+			return err
+		}
+		if state == nil {
+			fmt.Println("The library has not been audited yet.")
+			return nil
+		}
+		printAuditReport(state)
+		return nil
+	default:
+		return fmt.Errorf("unknown library command: %s", args[0])
+	}
+}
+
+// openHistory opens the history file in the XDG data directory.
+func openHistory() (*internal.History, error) {
+	historyPath, err := currentProfile.HistoryPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history path: %w", err)
+	}
+	return internal.OpenHistory(historyPath), nil
+}
+
+func printAuditReport(state *internal.AuditState) {
+	subject, body := internal.FormatAuditReport(state)
+	fmt.Println(subject)
+	fmt.Println(body)
+}
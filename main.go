@@ -1,68 +1,489 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt" // Added
+	"io"
 	"log"
 	"os" // Added
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"radikoRecScheduler/internal" // Assuming radikoRecScheduler is the module name
 )
 
 func main() {
+	// -config and -profile may precede a subcommand, which then uses them as well.
+	global, rest := takeGlobalFlags(os.Args[1:])
+	os.Args = append(os.Args[:1], rest...)
+	if global.config != "" {
+		internal.SetConfigPath(global.config)
+	}
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		useProfile(global.profile)
+	}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "jobs":
+			if err := runJobsCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "cache":
+			if err := runCacheCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "status":
+			if err := runStatusCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "schedule":
+			if err := runScheduleCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "tui":
+			if err := runTUICommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "guide":
+			if err := runGuideCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "library":
+			if err := runLibraryCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "record":
+			if err := runRecordCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "backfill":
+			if err := runBackfillCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "config":
+			if err := runConfigCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "preview":
+			if err := runPreviewCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "version":
+			if err := runVersionCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "stats":
+			if err := runStatsCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "history":
+			if err := runHistoryCommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 		flag.PrintDefaults()
-		fmt.Fprintln(os.Stderr, "\nSchedule is loaded from the path specified by -file flag, or from XDG config directory by default.")
+		fmt.Fprintln(os.Stderr, "\nSubcommands:")
+		fmt.Fprintln(os.Stderr, "  record -station ID -from YYYYMMDDhhmm -to YYYYMMDDhhmm")
+		fmt.Fprintln(os.Stderr, "                          Record an arbitrary timeshift window once.")
+		fmt.Fprintln(os.Stderr, "  record [-entry|-only NAME] [-station ID,...] [-weekday DAY,...]")
+		fmt.Fprintln(os.Stderr, "                          Record the most recent broadcast of the matching schedule entries.")
+		fmt.Fprintln(os.Stderr, "  backfill -entry NAME -from YYYY-MM-DD [-to YYYY-MM-DD]")
+		fmt.Fprintln(os.Stderr, "                          Record every broadcast of an entry in a past range still in the timeshift window.")
+		fmt.Fprintln(os.Stderr, "  guide search [-station ID,...] [-add N] <keyword>")
+		fmt.Fprintln(os.Stderr, "                          Search this week's program guide; -add schedules result N.")
+		fmt.Fprintln(os.Stderr, "  status                  Show the state of the running daemon.")
+		fmt.Fprintln(os.Stderr, "  schedule                List the schedule in use (the daemon's, if one is running).")
+		fmt.Fprintln(os.Stderr, "  schedule schema         Print the JSON Schema of schedule.json.")
+		fmt.Fprintln(os.Stderr, "  schedule import-favorites [-dry-run]")
+		fmt.Fprintln(os.Stderr, "                          Schedule the programs favorited in the radiko premium account.")
+		fmt.Fprintln(os.Stderr, "  schedule export-ics [-o FILE]")
+		fmt.Fprintln(os.Stderr, "                          Export the schedule as an iCalendar file of weekly events.")
+		fmt.Fprintln(os.Stderr, "  schedule import [-dry-run] FILE")
+		fmt.Fprintln(os.Stderr, "                          Add the entries of a CSV (station, weekday, time, title) or ICS file.")
+		fmt.Fprintln(os.Stderr, "  schedule calendar       Print the coming week as a grid of hours, marking overlapping entries.")
+		fmt.Fprintln(os.Stderr, "  tui                     Browse the schedule and search the guide interactively.")
+		fmt.Fprintln(os.Stderr, "  jobs list [-n N]        List recent recording jobs from the history.")
+		fmt.Fprintln(os.Stderr, "  jobs show [-log] <id>   Show details (and the captured log) of a job.")
+		fmt.Fprintln(os.Stderr, "  stats [-sort name|last] Show episodes, hours, disk usage and failures per program.")
+		fmt.Fprintln(os.Stderr, "  history export [-format csv|json] [-o FILE]")
+		fmt.Fprintln(os.Stderr, "                          Export the recording history for spreadsheets or dashboards.")
+		fmt.Fprintln(os.Stderr, "  library audit [-restart] Verify recordings against their stored hashes (resumable).")
+		fmt.Fprintln(os.Stderr, "  library repair          Re-record damaged recordings still in the timeshift window.")
+		fmt.Fprintln(os.Stderr, "  library report          Show the result of the last audit.")
+		fmt.Fprintln(os.Stderr, "  preview [-send]         Show (or send as a notification) next week's recordings.")
+		fmt.Fprintln(os.Stderr, "  cache stats             Show the size of the guide, chunk and artwork caches.")
+		fmt.Fprintln(os.Stderr, "  cache clear [name]      Empty all caches (and saved auth tokens), or only the named one.")
+		fmt.Fprintln(os.Stderr, "  config migrate          Move a separate schedule.json into config.json.")
+		fmt.Fprintln(os.Stderr, "  config show [-show-secrets]")
+		fmt.Fprintln(os.Stderr, "                          Print the effective config.json, with secrets masked.")
+		fmt.Fprintln(os.Stderr, "  version [-check] [-station ID]")
+		fmt.Fprintln(os.Stderr, "                          Print build info; -check tests radiko's auth and playlist endpoints.")
+		fmt.Fprintln(os.Stderr, "\nSchedule is loaded from the path specified by -file flag, or from config.json (or schedule.json) in the XDG config directory by default.")
+		fmt.Fprintln(os.Stderr, "-config and -profile may also precede a subcommand, e.g. -profile alice status.")
+		fmt.Fprintf(os.Stderr, "Settings of config.json can be overridden by %s* environment variables, e.g. %sOUTPUT_DIR.\n", internal.EnvPrefix, internal.EnvPrefix)
 		fmt.Fprintln(os.Stderr, "For detailed usage and configuration, refer to README.md.")
 	}
 
-	scheduleFilePath := flag.String("file", func() string {
-		path, err := internal.GetScheduleConfigPath()
+	configPath := flag.String("config", global.config, "Path to config.json. Defaults to $"+internal.ConfigPathEnv+" or the XDG config directory.")
+	profile := flag.String("profile", global.profile, "Profile to use, or with -daemon a comma-separated list of profiles (or 'all') to run together. Defaults to $"+internal.ProfileEnv+".")
+	scheduleFilePath := flag.String("file", "", "Path to the schedule file (JSON, YAML or TOML). Defaults to $"+internal.SchedulePathEnv+" or the XDG config directory.")
+	quiet := flag.Bool("quiet", false, "Suppress progress output and print one summary line per job (for cron).")
+	noSpinner := flag.Bool("no-spinner", false, "Disable the progress bar; log progress periodically instead.")
+	daemon := flag.Bool("daemon", false, "Keep running and record new broadcasts every hour until interrupted.")
+	overwrite := flag.Bool("overwrite", false, "Record broadcasts again even if they were recorded before, replacing the recordings.")
+	flag.Parse()
+	if *configPath != "" {
+		internal.SetConfigPath(*configPath)
+	}
+	if profiles := selectProfiles(*profile); len(profiles) > 1 {
+		if !*daemon {
+			log.Fatal("Several profiles can only be run together with -daemon")
+		}
+		if *overwrite {
+			log.Fatal("-overwrite cannot be used with -daemon")
+		}
+		if err := runProfileDaemons(profiles, *quiet, *noSpinner); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	useProfile(*profile)
+	if currentProfile != "" && *daemon {
+		log.SetPrefix("[" + string(currentProfile) + "] ")
+	}
+	if *scheduleFilePath == "" {
+		*scheduleFilePath = defaultSchedulePath(currentProfile)
+	}
+
+	config := loadConfig(currentProfile)
+	scheduleEntries, source := resolveSchedule(currentProfile, config, *scheduleFilePath, flagWasSet(flag.CommandLine, "file"))
+
+	opts := newOptions(currentProfile, config, scheduleEntries, *quiet, *noSpinner)
+	opts.Overwrite = *overwrite
+
+	if *daemon {
+		if *overwrite {
+			// Every pass would record the whole timeshift window again.
+			log.Fatal(opts.Output.Msg("-overwrite cannot be used with -daemon"))
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := runDaemon(ctx, currentProfile, config, opts, source); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Per-entry failures are logged by RunOnce; the batch always runs to completion,
+	// and the exit status tells cron and CI wrappers whether any job failed.
+	summary := &internal.RunSummary{}
+	opts.Summary = summary
+	err := internal.RunOnce(context.Background(), opts)
+	printRunSummary(os.Stdout, summary, *quiet, opts.Output)
+
+	if !*quiet {
+		log.Println("All scheduled past broadcasts processed. Exiting.")
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// runDaemon runs the daemon of profile with opts until ctx is done: it watches the schedule, serves
+// the daemon API (and gRPC if config asks for it) and keeps the job queue of profile. It returns an
+// error if the daemon cannot be started.
+func runDaemon(ctx context.Context, profile internal.Profile, config *internal.Config, opts internal.Options, source scheduleSource) error {
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	logger := opts.Logger
+	updates, err := internal.WatchSchedule(ctx, source.path, source.load, logger)
+	if err != nil {
+		logger.Printf("WARNING: Schedule changes will not be picked up until restart: %v", err)
+	}
+	opts.ScheduleUpdates = updates
+
+	dataDir, err := profile.DataDir()
+	if err != nil {
+		return fmt.Errorf(opts.Output.Msg("Failed to get data directory: %v"), err)
+	}
+	opts.State = internal.NewDaemonState()
+	stopAPI, err := internal.ServeDaemonAPI(ctx, dataDir, opts.State, opts.History, logger)
+	if err != nil {
+		return fmt.Errorf(opts.Output.Msg("Failed to start daemon: %v"), err)
+	}
+	defer stopAPI()
+	if config.GRPC.Listen != "" {
+		stopGRPC, err := internal.ServeGRPC(config.GRPC, opts.State, opts.History, logger)
 		if err != nil {
-			log.Fatalf("Failed to get default schedule config path: %v", err)
+			return fmt.Errorf(opts.Output.Msg("Failed to start daemon: %v"), err)
 		}
-		return path
-	}(), "Path to the schedule JSON file. Defaults to XDG config directory.")
-	flag.Parse()
+		defer stopGRPC()
+	}
+	queuePath, err := profile.JobQueuePath()
+	if err != nil {
+		return fmt.Errorf(opts.Output.Msg("Failed to get job queue path: %v"), err)
+	}
+	if opts.Queue, err = internal.OpenJobQueue(queuePath); err != nil {
+		return fmt.Errorf(opts.Output.Msg("Failed to start daemon: %v"), err)
+	}
+	logger.Println("Running in daemon mode. Press Ctrl+C to stop.")
+	_ = internal.RunDaemon(ctx, opts)
+	logger.Println("Daemon stopped.")
+	return nil
+}
+
+// printRunSummary prints the outcome of every job of a run as a table, followed by the totals.
+// In quiet mode each job already had its summary line, so only the totals are printed.
+func printRunSummary(w io.Writer, summary *internal.RunSummary, quiet bool, out internal.Output) {
+	results := summary.Results()
+	if len(results) == 0 {
+		return
+	}
+	if !quiet {
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, out.Msg("RESULT\tPROGRAM\tSTATION\tBROADCAST\tDETAIL"))
+		for _, r := range results {
+			broadcast := "-"
+			if !r.Start.IsZero() {
+				broadcast = r.Start.Format("2006-01-02 15:04")
+			}
+			detail := r.OutputPath
+			if r.Err != nil {
+				detail = r.Err.Error()
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", out.Msg(strings.ToUpper(r.Outcome)), r.ProgramName, r.StationID, broadcast, detail)
+		}
+		tw.Flush()
+	}
+	succeeded, failed, skipped := summary.Counts()
+	fmt.Fprintf(w, out.Msg("%d succeeded, %d failed, %d skipped.\n"), succeeded, failed, skipped)
+}
+
+// newOptions builds the pipeline options of profile from its config.json and the common output flags,
+// exiting on failure.
+func newOptions(profile internal.Profile, config *internal.Config, schedule []internal.ScheduleEntry, quiet, noSpinner bool) internal.Options {
+	postStore, err := internal.NewPostStore(config.PostStore)
+	if err != nil {
+		log.Fatalf(config.Output().Msg("Failed to set up post-store: %v"), err)
+	}
+	historyPath, err := profile.HistoryPath()
+	if err != nil {
+		log.Fatalf(config.Output().Msg("Failed to get history path: %v"), err)
+	}
+	caches, err := openCaches(profile, config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	transcriber, err := internal.NewTranscriber(config.Transcription, nil)
+	if err != nil {
+		log.Fatalf(config.Output().Msg("Failed to set up transcription: %v"), err)
+	}
+	headers, err := internal.ParseProviderHeaders(config.Network.Headers)
+	if err != nil {
+		log.Fatalf("Failed to configure network: %v", err)
+	}
+
+	return internal.Options{
+		Schedule:           schedule,
+		Concurrency:        config.Concurrency,
+		ChunkRate:          config.ChunkRate,
+		JobChunkRate:       config.JobChunkRate,
+		StationConcurrency: config.StationConcurrency,
+		PanicWindow:        time.Duration(config.PanicHours * float64(time.Hour)),
+
+		Output:         config.Output(),
+		Location:       config.ScheduleLocation(),
+		Quality:        config.Quality,
+		Headers:        headers,
+		RequestTimeout: time.Duration(config.Network.RequestTimeoutSeconds) * time.Second,
+		JobTimeout:     time.Duration(config.JobTimeout) * time.Minute,
+		Rotation:       config.Rotation,
+		DurationCheck:  config.DurationCheck,
+		Retry:          config.Retry,
+		Chapters:       config.Chapters,
+		ShowNotes:      config.ShowNotes,
+		FFmpeg:         config.FFmpegPath,
+		TempDir:        config.TempDir,
+		ChunkBufferMB:  config.ChunkBufferMB,
+		Transcriber:    transcriber,
+		NewProvider:    newProvider(config),
+		OutputDir:      config.OutputDir,
+		Layout:         config.OutputLayout,
+		PostStore:      postStore,
+		DeleteLocal:    config.PostStore.DeleteLocal,
+		PostCommand:    config.PostCommand,
+		History:        internal.OpenHistory(historyPath),
+		Quiet:          quiet,
+		Notifier:       internal.NewNotifier(config.Notifications),
+		Caches:         caches,
+		Tokens:         openTokenCache(profile, config),
+
+		Subscriptions: config.Subscriptions,
+		Audit:         config.Audit,
+
+		DisableProgressBar: noSpinner,
+		WeeklyPreview:      config.Notifications.WeeklyPreview,
+		RunReport:          config.Notifications.RunReport,
+		TitleCollision:     config.TitleCollision,
+		ScheduleTitles:     !config.GuideTitles(),
+
+		TolerateMissingChunks: config.TolerateMissingChunks,
+		KeepChunks:            config.KeepChunks,
+		ChunkNamePattern:      config.ChunkNamePattern,
+	}
+}
+
+// defaultSchedulePath returns the XDG schedule path of profile, exiting if it cannot be determined.
+func defaultSchedulePath(profile internal.Profile) string {
+	path, err := profile.ScheduleConfigPath()
+	if err != nil {
+		log.Fatalf("Failed to get default schedule config path: %v", err)
+	}
+	return path
+}
 
-	scheduleEntries, err := internal.LoadSchedule(*scheduleFilePath)
+// loadSchedule loads the schedule of profile from scheduleFilePath, exiting on failure.
+// It returns the entries and the path they were actually read from.
+func loadSchedule(profile internal.Profile, scheduleFilePath string, out internal.Output) ([]internal.ScheduleEntry, string) {
+	scheduleEntries, err := internal.LoadSchedule(scheduleFilePath)
 	if err != nil {
-		// If schedule.json does not exist in the XDG config path, try to load from the current directory for backward compatibility
-		if os.IsNotExist(err) && *scheduleFilePath == func() string {
-			path, _ := internal.GetScheduleConfigPath()
-			return path
-		}() {
-			log.Printf("Schedule file not found at default XDG config path. Trying current directory for 'schedule.json'.")
-			*scheduleFilePath = "schedule.json"
-			scheduleEntries, err = internal.LoadSchedule(*scheduleFilePath)
+		// If no schedule file exists in the XDG config path, try to load from the current directory for backward compatibility.
+		// Profiles are newer than that layout and never share the schedule of the current directory.
+		if errors.Is(err, os.ErrNotExist) && scheduleFilePath == defaultSchedulePath(profile) && profile == "" {
+			localPath := internal.FindScheduleFile(".")
+			log.Printf("Schedule file not found at default XDG config path. Trying current directory for '%s'.", localPath)
+			scheduleEntries, err = internal.LoadSchedule(localPath)
 			if err != nil {
-				log.Fatalf("Failed to load schedule from XDG path and current directory: %v", err)
+				log.Fatalf(out.Msg("Failed to load schedule from XDG path and current directory: %v"), err)
 			}
-		} else {
-			log.Fatalf("Failed to load schedule: %v", err)
+			return scheduleEntries, localPath
 		}
+		log.Fatalf(out.Msg("Failed to load schedule: %v"), err)
 	}
+	return scheduleEntries, scheduleFilePath
+}
 
-	now := time.Now().In(internal.JST)
-	for _, entry := range scheduleEntries {
-		recentPastTime, err := internal.CalculateRecentPastRunTime(entry, now)
-		if err != nil {
-			log.Printf("Error calculating recent past run time for '%s': %v", entry.ProgramName, err)
-			continue
-		}
+// scheduleSource is the file a schedule was read from and how to read it again.
+type scheduleSource struct {
+	path string
+	load func() ([]internal.ScheduleEntry, error)
+}
 
-		// Create a new goradiko client for each job. The ExecuteJob will handle token authorization.
-		radikoClient, err := internal.NewGoradikoClient("") // Token will be authorized inside ExecuteJob
+// resolveSchedule returns the schedule stored in config.json of profile. The separate schedule file is used
+// instead when it was given explicitly with -file or RADIREC_SCHEDULE, or when config.json has
+// neither a schedule nor subscriptions yet (legacy layout). Entries recording the same slot as
+// an earlier one are left out with a warning.
+func resolveSchedule(profile internal.Profile, config *internal.Config, scheduleFilePath string, explicit bool) ([]internal.ScheduleEntry, scheduleSource) {
+	if path := os.Getenv(internal.SchedulePathEnv); path != "" && !explicit {
+		scheduleFilePath, explicit = path, true
+	}
+	if !explicit && (len(config.Schedule) > 0 || len(config.Subscriptions) > 0) {
+		configPath, err := profile.ConfigPath()
 		if err != nil {
-			log.Fatalf("Failed to create Radiko client for job: %v", err)
+			log.Fatalf("Failed to get default config path: %v", err)
 		}
+		return mergeDuplicates(config.Schedule), scheduleSource{path: configPath, load: func() ([]internal.ScheduleEntry, error) {
+			cfg, err := profile.LoadConfig(configPath)
+			if err != nil {
+				return nil, err
+			}
+			if len(cfg.Schedule) == 0 && len(cfg.Subscriptions) == 0 {
+				return nil, fmt.Errorf("'%s' has no schedule", configPath)
+			}
+			return mergeDuplicates(cfg.Schedule), nil
+		}}
+	}
+	entries, path := loadSchedule(profile, scheduleFilePath, config.Output())
+	if !explicit {
+		log.Printf("Using a separate schedule file. Run '%s config migrate' to move it into config.json.", os.Args[0])
+	}
+	return mergeDuplicates(entries), scheduleSource{path: path, load: func() ([]internal.ScheduleEntry, error) {
+		entries, err := internal.LoadSchedule(path)
+		return mergeDuplicates(entries), err
+	}}
+}
+
+// mergeDuplicates leaves out the entries of a loaded schedule that record the same slot as
+// another, logging a warning for each.
+func mergeDuplicates(entries []internal.ScheduleEntry) []internal.ScheduleEntry {
+	entries, warnings := internal.MergeDuplicates(entries)
+	for _, warning := range warnings {
+		log.Printf("WARNING: %s", warning)
+	}
+	return entries
+}
 
-		if err := internal.ExecuteJob(radikoClient, entry, recentPastTime, "output"); err != nil {
-			log.Printf("Error executing job for '%s': %v", entry.ProgramName, err)
+// flagWasSet reports whether the named flag was given on the command line.
+func flagWasSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
 		}
+	})
+	return set
+}
+
+// newProvider returns the radiko provider factory for the configured account,
+// or nil (the registered anonymous provider) when no premium credentials are set.
+func newProvider(config *internal.Config) func(ctx context.Context) (internal.Provider, error) {
+	if config.Radiko.Mail == "" {
+		return nil
 	}
+	return func(ctx context.Context) (internal.Provider, error) {
+		return internal.NewLoggedInGoradikoClient(ctx, config.Radiko)
+	}
+}
 
-	log.Println("All scheduled past broadcasts processed. Exiting.")
+// loadConfig loads config.json of profile from the XDG config directory and applies its network and file name
+// settings, exiting on failure.
+func loadConfig(profile internal.Profile) *internal.Config {
+	configPath, err := profile.ConfigPath()
+	if err != nil {
+		log.Fatalf("Failed to get default config path: %v", err)
+	}
+	config, err := profile.LoadEffectiveConfig(configPath, os.LookupEnv)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if config.HasSecrets() {
+		if perm, loose, err := internal.LooseConfigPermissions(configPath); err == nil && loose {
+			log.Printf("WARNING: '%s' holds credentials but is accessible by other users (mode %04o). Run 'chmod 600 %s'.", configPath, perm, configPath)
+		}
+	}
+	if err := internal.ConfigureNetwork(config.Network); err != nil {
+		log.Fatalf("Failed to configure network: %v", err)
+	}
+	if config.Network.InsecureSkipVerify {
+		log.Println("WARNING: TLS certificate verification is disabled by network.insecure_skip_verify.")
+	}
+	if err := internal.ConfigureFileNames(config.FileNames); err != nil {
+		log.Fatalf("Failed to configure file names: %v", err)
+	}
+	return config
 }
@@ -0,0 +1,102 @@
+package radirec
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// Program is one program of a station's guide.
+type Program struct {
+	Start, End  time.Time
+	Title       string
+	SubTitle    string
+	Performers  string
+	Description string // HTML.
+	Info        string // HTML.
+	URL         string // The program's web page.
+}
+
+// program returns the public copy of the guide entry p.
+func program(p internal.Prog) Program {
+	start, _ := time.ParseInLocation("20060102150405", p.Ft, internal.JST)
+	end, _ := time.ParseInLocation("20060102150405", p.To, internal.JST)
+	return Program{
+		Start:       start,
+		End:         end,
+		Title:       p.Title,
+		SubTitle:    p.SubTitle,
+		Performers:  p.Pfm,
+		Description: p.Desc,
+		Info:        p.Info,
+		URL:         p.URL,
+	}
+}
+
+// GuideMatch is a program found by Client.Search.
+type GuideMatch struct {
+	StationID string
+	Program   Program
+}
+
+// Client reads radiko's station list and weekly program guides. Recording goes through
+// Recorder and Scheduler, which authenticate with a Provider of their own.
+type Client struct {
+	http  *http.Client
+	fetch func(ctx context.Context, stationID string) ([]byte, error)
+}
+
+// NewClient returns a Client downloading each station's guide at most once per day into
+// caches.Guide, or on every call if caches is nil. Guides are requested with httpClient, or
+// http.DefaultClient if it is nil. A nil clock means SystemClock.
+func NewClient(httpClient *http.Client, caches *Caches, clock Clock) *Client {
+	if clock == nil {
+		clock = SystemClock
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	fetch := func(ctx context.Context, stationID string) ([]byte, error) {
+		return internal.FetchProgramGuide(ctx, httpClient, stationID)
+	}
+	if caches != nil && caches.caches.Guide != nil {
+		fetch = internal.CachedGuideFetcher(caches.caches.Guide, clock, fetch)
+	}
+	return &Client{http: httpClient, fetch: fetch}
+}
+
+// Stations returns the IDs of every station radiko lists.
+func (c *Client) Stations(ctx context.Context) ([]string, error) {
+	return internal.FetchStationIDs(ctx, c.http)
+}
+
+// Guide returns the program guide XML of this week on stationID.
+func (c *Client) Guide(ctx context.Context, stationID string) ([]byte, error) {
+	return c.fetch(ctx, stationID)
+}
+
+// ProgramAt returns the program on air at at on stationID.
+func (c *Client) ProgramAt(ctx context.Context, stationID string, at time.Time) (Program, error) {
+	guide, err := c.fetch(ctx, stationID)
+	if err != nil {
+		return Program{}, err
+	}
+	prog, err := internal.FindProgram(guide, at)
+	if err != nil {
+		return Program{}, err
+	}
+	return program(prog), nil
+}
+
+// Search returns the programs of this week on stationIDs whose title, performer or description
+// contains keyword, ignoring case, along with an error for each station whose guide could not be read.
+func (c *Client) Search(ctx context.Context, stationIDs []string, keyword string) ([]GuideMatch, []error) {
+	found, errs := internal.SearchGuides(ctx, stationIDs, keyword, c.fetch)
+	var matches []GuideMatch
+	for _, m := range found {
+		matches = append(matches, GuideMatch{StationID: m.StationID, Program: program(m.Prog)})
+	}
+	return matches, errs
+}
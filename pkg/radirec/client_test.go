@@ -0,0 +1,100 @@
+package radirec_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"radikoRecScheduler/pkg/radirec"
+	"radikoRecScheduler/pkg/radirec/testutil"
+)
+
+var jst = time.FixedZone("Asia/Tokyo", 9*60*60)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// fakeRadiko serves the station list and the guides from memory, counting the requests.
+type fakeRadiko struct {
+	guides map[string][]byte
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func (f *fakeRadiko) client() *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		f.mu.Lock()
+		f.requests = append(f.requests, req.URL.Path)
+		f.mu.Unlock()
+		body, status := "", http.StatusNotFound
+		if req.URL.Path == "/v3/station/region/full.xml" {
+			body, status = `<region><stations><station><id>TBS</id></station><station><id>QRR</id></station></stations></region>`, http.StatusOK
+		} else if id, ok := strings.CutPrefix(req.URL.Path, "/v3/program/station/weekly/"); ok {
+			if guide, ok := f.guides[strings.TrimSuffix(id, ".xml")]; ok {
+				body, status = string(guide), http.StatusOK
+			}
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+	})}
+}
+
+func (f *fakeRadiko) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+func TestClient(t *testing.T) {
+	start := time.Date(2026, time.January, 12, 18, 0, 0, 0, jst)
+	radiko := &fakeRadiko{guides: map[string][]byte{
+		"TBS": testutil.GuideXML("TBS",
+			testutil.Program{Start: start, End: start.Add(3 * time.Hour), Title: "After 6", Performer: "Utamaru", Desc: "Guest talk"},
+			testutil.Program{Start: start.Add(3 * time.Hour), End: start.Add(4 * time.Hour), Title: "News"},
+		),
+	}}
+	client := radirec.NewClient(radiko.client(), nil, testutil.NewFakeClock(start))
+	ctx := context.Background()
+
+	stations, err := client.Stations(ctx)
+	if err != nil || len(stations) != 2 || stations[0] != "TBS" || stations[1] != "QRR" {
+		t.Errorf("Stations = %v, %v", stations, err)
+	}
+
+	prog, err := client.ProgramAt(ctx, "TBS", start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ProgramAt failed: %v", err)
+	}
+	if prog.Title != "After 6" || prog.Performers != "Utamaru" || !prog.Start.Equal(start) || !prog.End.Equal(start.Add(3*time.Hour)) {
+		t.Errorf("ProgramAt = %+v", prog)
+	}
+
+	matches, errs := client.Search(ctx, []string{"TBS", "QRR"}, "guest")
+	if len(matches) != 1 || matches[0].StationID != "TBS" || matches[0].Program.Title != "After 6" {
+		t.Errorf("Search = %+v", matches)
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected an error for the station without a guide, got %v", errs)
+	}
+}
+
+func TestClientCachesGuides(t *testing.T) {
+	now := time.Date(2026, time.January, 12, 18, 0, 0, 0, jst)
+	radiko := &fakeRadiko{guides: map[string][]byte{"TBS": testutil.GuideXML("TBS")}}
+	caches := radirec.OpenCaches(t.TempDir(), radirec.CacheConfig{GuideMB: 1})
+	client := radirec.NewClient(radiko.client(), caches, testutil.NewFakeClock(now))
+
+	for range 2 {
+		if _, err := client.Guide(context.Background(), "TBS"); err != nil {
+			t.Fatalf("Guide failed: %v", err)
+		}
+	}
+	if n := radiko.count(); n != 1 {
+		t.Errorf("expected the guide to be fetched once, got %d requests", n)
+	}
+}
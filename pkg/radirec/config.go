@@ -0,0 +1,73 @@
+package radirec
+
+import "radikoRecScheduler/internal"
+
+// NetworkConfig sets the proxy, User-Agent and certificates of outgoing requests, see
+// ConfigureNetwork. Options.RequestTimeout limits the requests and Options.Headers adds headers
+// to those of the providers.
+type NetworkConfig struct {
+	// Proxy is an http://, https:// or socks5:// proxy URL. Defaults to the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string `json:"proxy"`
+	// UserAgent replaces the User-Agent header of every request.
+	UserAgent string `json:"user_agent"`
+
+	// CAFile is a PEM file of CA certificates that servers' certificates must chain to instead
+	// of the system's roots, e.g. the CA of a TLS-intercepting proxy.
+	CAFile string `json:"ca_file,omitempty"`
+	// InsecureSkipVerify accepts any server certificate. It is meant for debugging; prefer CAFile.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// RotationConfig splits long recordings into parts listed in an M3U playlist; 0 is no limit.
+type RotationConfig struct {
+	MaxMB      int `json:"max_mb"`
+	MaxMinutes int `json:"max_minutes"`
+}
+
+// DurationCheckConfig compares the length of recordings with the broadcasts in the guide.
+type DurationCheckConfig struct {
+	ToleranceSeconds int  `json:"tolerance_seconds"` // 0 means the default tolerance; negative disables the check.
+	Fail             bool `json:"fail"`              // Fail the job, to be retried, instead of only logging a warning.
+}
+
+// RetryConfig paces the daemon's retries of failed jobs in Options.Queue.
+type RetryConfig struct {
+	DelayMinutes    int `json:"delay_minutes"`     // Delay of the first retry; 0 means the default.
+	MaxDelayMinutes int `json:"max_delay_minutes"` // Cap of the doubling delays; 0 means the default.
+	MaxAttempts     int `json:"max_attempts"`      // Give up after this many failures; 0 retries until the broadcast leaves the timeshift window.
+}
+
+// WeeklyPreviewConfig sets when RunDaemon sends the coming week's recordings to Options.Notifier.
+type WeeklyPreviewConfig struct {
+	Enabled   bool   `json:"enabled"`
+	DayOfWeek string `json:"day_of_week"` // Japanese weekday as in schedule.json. Defaults to "日".
+	Time      string `json:"time"`        // HHMMSS. Defaults to "180000".
+}
+
+// FileNameConfig controls how program titles are turned into file names, see ConfigureFileNames.
+type FileNameConfig struct {
+	// Transliterate replaces characters or words of titles before they are used in file names,
+	// e.g. {"〜": "~", "♪": ""}.
+	Transliterate map[string]string `json:"transliterate,omitempty"`
+	// FoldWidth turns full-width letters, digits and punctuation into their ASCII forms and
+	// half-width katakana into full-width ones.
+	FoldWidth bool `json:"fold_width,omitempty"`
+	// MaxBytes caps the length of file names in bytes. Defaults to 255.
+	MaxBytes int `json:"max_bytes,omitempty"`
+}
+
+// ConfigureNetwork routes every HTTP request of the process through the proxy and User-Agent of cfg.
+func ConfigureNetwork(cfg NetworkConfig) error {
+	return internal.ConfigureNetwork(internal.NetworkConfig{
+		Proxy:              cfg.Proxy,
+		UserAgent:          cfg.UserAgent,
+		CAFile:             cfg.CAFile,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	})
+}
+
+// ConfigureFileNames sets how program titles are transliterated, width-folded and shortened in file names.
+func ConfigureFileNames(cfg FileNameConfig) error {
+	return internal.ConfigureFileNames(internal.FileNameConfig(cfg))
+}
@@ -0,0 +1,489 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: daemon.proto
+
+// The gRPC control interface of the radikoRecScheduler daemon, served when "grpc.listen" is set
+// in config.json. When "grpc.token" is set, every call must carry it in the "authorization"
+// metadata as "Bearer <token>".
+
+package daemonpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ScheduleService_GetStatus_FullMethodName   = "/radirec.v1.ScheduleService/GetStatus"
+	ScheduleService_ListEntries_FullMethodName = "/radirec.v1.ScheduleService/ListEntries"
+	ScheduleService_RunPass_FullMethodName     = "/radirec.v1.ScheduleService/RunPass"
+)
+
+// ScheduleServiceClient is the client API for ScheduleService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ScheduleService reports the daemon's schedule and starts passes over it.
+type ScheduleServiceClient interface {
+	// GetStatus returns the state of the daemon.
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*DaemonStatus, error)
+	// ListEntries returns the schedule the daemon is using.
+	ListEntries(ctx context.Context, in *ListEntriesRequest, opts ...grpc.CallOption) (*ListEntriesResponse, error)
+	// RunPass starts a pass over the schedule right away, or right after the running one.
+	RunPass(ctx context.Context, in *RunPassRequest, opts ...grpc.CallOption) (*RunPassResponse, error)
+}
+
+type scheduleServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewScheduleServiceClient(cc grpc.ClientConnInterface) ScheduleServiceClient {
+	return &scheduleServiceClient{cc}
+}
+
+func (c *scheduleServiceClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*DaemonStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DaemonStatus)
+	err := c.cc.Invoke(ctx, ScheduleService_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *scheduleServiceClient) ListEntries(ctx context.Context, in *ListEntriesRequest, opts ...grpc.CallOption) (*ListEntriesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListEntriesResponse)
+	err := c.cc.Invoke(ctx, ScheduleService_ListEntries_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *scheduleServiceClient) RunPass(ctx context.Context, in *RunPassRequest, opts ...grpc.CallOption) (*RunPassResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RunPassResponse)
+	err := c.cc.Invoke(ctx, ScheduleService_RunPass_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ScheduleServiceServer is the server API for ScheduleService service.
+// All implementations must embed UnimplementedScheduleServiceServer
+// for forward compatibility.
+//
+// ScheduleService reports the daemon's schedule and starts passes over it.
+type ScheduleServiceServer interface {
+	// GetStatus returns the state of the daemon.
+	GetStatus(context.Context, *GetStatusRequest) (*DaemonStatus, error)
+	// ListEntries returns the schedule the daemon is using.
+	ListEntries(context.Context, *ListEntriesRequest) (*ListEntriesResponse, error)
+	// RunPass starts a pass over the schedule right away, or right after the running one.
+	RunPass(context.Context, *RunPassRequest) (*RunPassResponse, error)
+	mustEmbedUnimplementedScheduleServiceServer()
+}
+
+// UnimplementedScheduleServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedScheduleServiceServer struct{}
+
+func (UnimplementedScheduleServiceServer) GetStatus(context.Context, *GetStatusRequest) (*DaemonStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedScheduleServiceServer) ListEntries(context.Context, *ListEntriesRequest) (*ListEntriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListEntries not implemented")
+}
+func (UnimplementedScheduleServiceServer) RunPass(context.Context, *RunPassRequest) (*RunPassResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunPass not implemented")
+}
+func (UnimplementedScheduleServiceServer) mustEmbedUnimplementedScheduleServiceServer() {}
+func (UnimplementedScheduleServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeScheduleServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ScheduleServiceServer will
+// result in compilation errors.
+type UnsafeScheduleServiceServer interface {
+	mustEmbedUnimplementedScheduleServiceServer()
+}
+
+func RegisterScheduleServiceServer(s grpc.ServiceRegistrar, srv ScheduleServiceServer) {
+	// If the following call pancis, it indicates UnimplementedScheduleServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ScheduleService_ServiceDesc, srv)
+}
+
+func _ScheduleService_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScheduleServiceServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ScheduleService_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScheduleServiceServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ScheduleService_ListEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListEntriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScheduleServiceServer).ListEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ScheduleService_ListEntries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScheduleServiceServer).ListEntries(ctx, req.(*ListEntriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ScheduleService_RunPass_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunPassRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScheduleServiceServer).RunPass(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ScheduleService_RunPass_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScheduleServiceServer).RunPass(ctx, req.(*RunPassRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ScheduleService_ServiceDesc is the grpc.ServiceDesc for ScheduleService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ScheduleService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "radirec.v1.ScheduleService",
+	HandlerType: (*ScheduleServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetStatus",
+			Handler:    _ScheduleService_GetStatus_Handler,
+		},
+		{
+			MethodName: "ListEntries",
+			Handler:    _ScheduleService_ListEntries_Handler,
+		},
+		{
+			MethodName: "RunPass",
+			Handler:    _ScheduleService_RunPass_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "daemon.proto",
+}
+
+const (
+	RecordingService_ListRunning_FullMethodName     = "/radirec.v1.RecordingService/ListRunning"
+	RecordingService_WatchProgress_FullMethodName   = "/radirec.v1.RecordingService/WatchProgress"
+	RecordingService_CancelRecording_FullMethodName = "/radirec.v1.RecordingService/CancelRecording"
+	RecordingService_ListJobs_FullMethodName        = "/radirec.v1.RecordingService/ListJobs"
+	RecordingService_GetJob_FullMethodName          = "/radirec.v1.RecordingService/GetJob"
+)
+
+// RecordingServiceClient is the client API for RecordingService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RecordingService reports and controls recordings.
+type RecordingServiceClient interface {
+	// ListRunning returns the recordings in progress.
+	ListRunning(ctx context.Context, in *ListRunningRequest, opts ...grpc.CallOption) (*ListRunningResponse, error)
+	// WatchProgress streams the progress of recordings, starting with those in progress,
+	// until the client cancels the call.
+	WatchProgress(ctx context.Context, in *WatchProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProgressEvent], error)
+	// CancelRecording stops a recording in progress. It fails with NOT_FOUND if there is none
+	// with the ID.
+	CancelRecording(ctx context.Context, in *CancelRecordingRequest, opts ...grpc.CallOption) (*CancelRecordingResponse, error)
+	// ListJobs returns the most recent recording attempts from the history, oldest first.
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
+	// GetJob returns one recording attempt from the history. It fails with NOT_FOUND if there
+	// is none with the ID.
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+}
+
+type recordingServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRecordingServiceClient(cc grpc.ClientConnInterface) RecordingServiceClient {
+	return &recordingServiceClient{cc}
+}
+
+func (c *recordingServiceClient) ListRunning(ctx context.Context, in *ListRunningRequest, opts ...grpc.CallOption) (*ListRunningResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRunningResponse)
+	err := c.cc.Invoke(ctx, RecordingService_ListRunning_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recordingServiceClient) WatchProgress(ctx context.Context, in *WatchProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProgressEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RecordingService_ServiceDesc.Streams[0], RecordingService_WatchProgress_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchProgressRequest, ProgressEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RecordingService_WatchProgressClient = grpc.ServerStreamingClient[ProgressEvent]
+
+func (c *recordingServiceClient) CancelRecording(ctx context.Context, in *CancelRecordingRequest, opts ...grpc.CallOption) (*CancelRecordingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelRecordingResponse)
+	err := c.cc.Invoke(ctx, RecordingService_CancelRecording_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recordingServiceClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListJobsResponse)
+	err := c.cc.Invoke(ctx, RecordingService_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recordingServiceClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, RecordingService_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RecordingServiceServer is the server API for RecordingService service.
+// All implementations must embed UnimplementedRecordingServiceServer
+// for forward compatibility.
+//
+// RecordingService reports and controls recordings.
+type RecordingServiceServer interface {
+	// ListRunning returns the recordings in progress.
+	ListRunning(context.Context, *ListRunningRequest) (*ListRunningResponse, error)
+	// WatchProgress streams the progress of recordings, starting with those in progress,
+	// until the client cancels the call.
+	WatchProgress(*WatchProgressRequest, grpc.ServerStreamingServer[ProgressEvent]) error
+	// CancelRecording stops a recording in progress. It fails with NOT_FOUND if there is none
+	// with the ID.
+	CancelRecording(context.Context, *CancelRecordingRequest) (*CancelRecordingResponse, error)
+	// ListJobs returns the most recent recording attempts from the history, oldest first.
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
+	// GetJob returns one recording attempt from the history. It fails with NOT_FOUND if there
+	// is none with the ID.
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	mustEmbedUnimplementedRecordingServiceServer()
+}
+
+// UnimplementedRecordingServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRecordingServiceServer struct{}
+
+func (UnimplementedRecordingServiceServer) ListRunning(context.Context, *ListRunningRequest) (*ListRunningResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRunning not implemented")
+}
+func (UnimplementedRecordingServiceServer) WatchProgress(*WatchProgressRequest, grpc.ServerStreamingServer[ProgressEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchProgress not implemented")
+}
+func (UnimplementedRecordingServiceServer) CancelRecording(context.Context, *CancelRecordingRequest) (*CancelRecordingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelRecording not implemented")
+}
+func (UnimplementedRecordingServiceServer) ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJobs not implemented")
+}
+func (UnimplementedRecordingServiceServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedRecordingServiceServer) mustEmbedUnimplementedRecordingServiceServer() {}
+func (UnimplementedRecordingServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeRecordingServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RecordingServiceServer will
+// result in compilation errors.
+type UnsafeRecordingServiceServer interface {
+	mustEmbedUnimplementedRecordingServiceServer()
+}
+
+func RegisterRecordingServiceServer(s grpc.ServiceRegistrar, srv RecordingServiceServer) {
+	// If the following call pancis, it indicates UnimplementedRecordingServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RecordingService_ServiceDesc, srv)
+}
+
+func _RecordingService_ListRunning_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRunningRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecordingServiceServer).ListRunning(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecordingService_ListRunning_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecordingServiceServer).ListRunning(ctx, req.(*ListRunningRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecordingService_WatchProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchProgressRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RecordingServiceServer).WatchProgress(m, &grpc.GenericServerStream[WatchProgressRequest, ProgressEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RecordingService_WatchProgressServer = grpc.ServerStreamingServer[ProgressEvent]
+
+func _RecordingService_CancelRecording_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRecordingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecordingServiceServer).CancelRecording(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecordingService_CancelRecording_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecordingServiceServer).CancelRecording(ctx, req.(*CancelRecordingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecordingService_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecordingServiceServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecordingService_ListJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecordingServiceServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecordingService_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecordingServiceServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecordingService_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecordingServiceServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RecordingService_ServiceDesc is the grpc.ServiceDesc for RecordingService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RecordingService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "radirec.v1.RecordingService",
+	HandlerType: (*RecordingServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListRunning",
+			Handler:    _RecordingService_ListRunning_Handler,
+		},
+		{
+			MethodName: "CancelRecording",
+			Handler:    _RecordingService_CancelRecording_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _RecordingService_ListJobs_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _RecordingService_GetJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchProgress",
+			Handler:       _RecordingService_WatchProgress_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "daemon.proto",
+}
@@ -0,0 +1,6 @@
+// Package daemonpb holds the gRPC control interface of the daemon, ScheduleService and
+// RecordingService, generated from daemon.proto. Clients in other languages generate theirs
+// from the same file.
+package daemonpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative daemon.proto
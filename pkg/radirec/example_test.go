@@ -0,0 +1,89 @@
+package radirec_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"radikoRecScheduler/pkg/radirec"
+	"radikoRecScheduler/pkg/radirec/testutil"
+)
+
+func ExampleScheduler() {
+	outputDir, _ := os.MkdirTemp("", "radirec-example")
+	defer os.RemoveAll(outputDir)
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, jst)
+	start := time.Date(2026, time.January, 12, 10, 0, 0, 0, jst)
+
+	scheduler := radirec.NewScheduler(radirec.Options{
+		Schedule: []radirec.ScheduleEntry{
+			{ProgramName: "Morning Show", DayOfWeek: "月", StartTime: "100000", StationID: "TBS"},
+		},
+		OutputDir: outputDir,
+		Logger:    log.New(io.Discard, "", 0),
+		// Tests and demos run without radiko; drop these to record for real.
+		Clock:       testutil.NewFakeClock(now),
+		NewProvider: (&testutil.MockProvider{}).Factory(),
+		FetchGuide: testutil.GuideFetcher(map[string][]byte{
+			"TBS": testutil.GuideXML("TBS", testutil.Program{Start: start, End: start.Add(time.Hour), Title: "Morning Show #12"}),
+		}),
+		DisableProgressBar: true,
+	})
+	summary, err := scheduler.RunOnce(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, r := range summary.Results() {
+		fmt.Println(r.Outcome, filepath.Base(r.OutputPath))
+	}
+	// Output: succeeded 20260112100000-TBS-Morning Show #12.aac
+}
+
+func ExampleRecorder() {
+	outputDir, _ := os.MkdirTemp("", "radirec-example")
+	defer os.RemoveAll(outputDir)
+	clock := testutil.NewFakeClock(time.Date(2026, time.January, 13, 10, 0, 0, 0, jst))
+	opts := radirec.Options{
+		OutputDir:          outputDir,
+		Logger:             log.New(io.Discard, "", 0),
+		Clock:              clock,
+		NewProvider:        (&testutil.MockProvider{Chunks: 4}).Factory(),
+		FetchGuide:         testutil.GuideFetcher(nil),
+		DisableProgressBar: true,
+	}
+	entry := radirec.ScheduleEntry{ProgramName: "Night Talk", DayOfWeek: "火", StartTime: "010000", StationID: "TBS"}
+
+	start, err := radirec.NewScheduler(opts).Recent(entry)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	result, err := radirec.NewRecorder(opts).Record(context.Background(), entry, start)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(filepath.Base(result.OutputPath), result.Chunks, result.Duration)
+	// Output: 20260113010000-TBS-Night Talk.aac 4 20s
+}
+
+func ExampleClient_ProgramAt() {
+	start := time.Date(2026, time.January, 12, 18, 0, 0, 0, jst)
+	radiko := &fakeRadiko{guides: map[string][]byte{
+		"TBS": testutil.GuideXML("TBS", testutil.Program{Start: start, End: start.Add(3 * time.Hour), Title: "After 6"}),
+	}}
+	client := radirec.NewClient(radiko.client(), nil, nil)
+
+	prog, err := client.ProgramAt(context.Background(), "TBS", start.Add(90*time.Minute))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(prog.Title, prog.Start.Format("15:04"), prog.End.Format("15:04"))
+	// Output: After 6 18:00 21:00
+}
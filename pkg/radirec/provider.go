@@ -0,0 +1,208 @@
+package radirec
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// Clock provides the current time and timers, so runs can be driven by a fake clock in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock backed by the real wall clock.
+var SystemClock Clock = internal.SystemClock
+
+// Provider is a streaming service used to authenticate and fetch playlists and chunks.
+// Providers shared by the jobs of a run must be safe for concurrent use.
+type Provider interface {
+	// Authenticate prepares the provider for the other calls, e.g. by fetching an auth token.
+	Authenticate(ctx context.Context) error
+	// ResolvePlaylist returns the playlist URI of the broadcast starting at start on stationID.
+	ResolvePlaylist(ctx context.Context, stationID string, start time.Time) (string, error)
+	// ListChunks returns the URLs of the audio chunks listed by the playlist at uri, in order.
+	ListChunks(ctx context.Context, uri string) ([]string, error)
+	// Download returns the contents of one chunk as ADTS audio.
+	Download(ctx context.Context, chunkURL string) (io.ReadCloser, error)
+}
+
+// RadikoClient is the former name of Provider.
+//
+// Deprecated: Use Provider.
+type RadikoClient = Provider
+
+// Chunk is one audio chunk of a playlist. Duration is zero when it is unknown.
+type Chunk struct {
+	URL      string
+	Duration time.Duration
+}
+
+// TimedChunkLister is implemented by providers that report the duration of each chunk, so
+// progress is measured in broadcast time rather than in chunks.
+type TimedChunkLister interface {
+	ListTimedChunks(ctx context.Context, uri string) ([]Chunk, error)
+}
+
+// TokenAuthenticator is implemented by providers whose auth tokens can be reused by later
+// jobs, through Options.Tokens.
+type TokenAuthenticator interface {
+	// TokenKey identifies what the token is valid for (e.g. the provider and account);
+	// an empty key disables caching.
+	TokenKey() string
+	// AuthToken returns the token obtained by the last Authenticate and when it expires.
+	AuthToken() (token string, expires time.Time)
+	// UseAuthToken prepares the provider with a cached token instead of calling Authenticate.
+	UseAuthToken(ctx context.Context, token string) error
+}
+
+// RangeDownloader is implemented by providers whose chunks can be downloaded from an offset,
+// so an interrupted chunk download resumes instead of starting over. Providers that transform
+// the chunks they download, e.g. by decrypting them, must not implement it.
+type RangeDownloader interface {
+	// DownloadFrom returns the bytes of chunkURL from offset on. resumed is false when the
+	// server ignored the range and the body is the whole chunk.
+	DownloadFrom(ctx context.Context, chunkURL string, offset int64) (body io.ReadCloser, resumed bool, err error)
+}
+
+// ProviderFactory creates the Provider used for one job.
+type ProviderFactory func(ctx context.Context) (Provider, error)
+
+// internal returns the factory of the pipeline's providers for f.
+func (f ProviderFactory) internal() internal.ProviderFactory {
+	return func(ctx context.Context) (internal.Provider, error) {
+		p, err := f(ctx)
+		if err != nil || p == nil {
+			return nil, err
+		}
+		return providerToInternal(p), nil
+	}
+}
+
+// timedProvider lists the chunks of a TimedChunkLister in the pipeline's type.
+type timedProvider struct {
+	Provider
+	lister TimedChunkLister
+}
+
+func (p timedProvider) ListTimedChunks(ctx context.Context, uri string) ([]internal.Chunk, error) {
+	chunks, err := p.lister.ListTimedChunks(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]internal.Chunk, len(chunks))
+	for i, c := range chunks {
+		converted[i] = internal.Chunk(c)
+	}
+	return converted, nil
+}
+
+// providerToInternal returns p as a provider of the pipeline. Only TimedChunkLister differs
+// from the pipeline's interface, in its Chunk type; a provider implementing it is wrapped,
+// keeping the other optional interfaces it implements.
+func providerToInternal(p Provider) internal.Provider {
+	lister, ok := p.(TimedChunkLister)
+	if !ok {
+		return p
+	}
+	timed := timedProvider{Provider: p, lister: lister}
+	tokens, hasTokens := p.(TokenAuthenticator)
+	ranges, hasRanges := p.(RangeDownloader)
+	switch {
+	case hasTokens && hasRanges:
+		return struct {
+			timedProvider
+			TokenAuthenticator
+			RangeDownloader
+		}{timed, tokens, ranges}
+	case hasTokens:
+		return struct {
+			timedProvider
+			TokenAuthenticator
+		}{timed, tokens}
+	case hasRanges:
+		return struct {
+			timedProvider
+			RangeDownloader
+		}{timed, ranges}
+	}
+	return timed
+}
+
+// RegisterProvider makes a provider available under name, the value of a schedule entry's
+// provider field. Registering a name again replaces the earlier factory.
+func RegisterProvider(name string, factory ProviderFactory) {
+	internal.RegisterProvider(name, factory.internal())
+}
+
+// NewGoradikoClient returns the default provider backed by go-radiko.
+func NewGoradikoClient(token string) (Provider, error) {
+	p, err := internal.NewGoradikoClient(token)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Playlist is a parsed HLS master or media playlist, see ParsePlaylist.
+type Playlist struct {
+	Variants      []string          // URIs of the variant streams, in order.
+	Streams       []PlaylistVariant // The variant streams with their attributes, in the order of Variants.
+	Segments      []PlaylistSegment
+	MediaSequence uint64 // Sequence number of the first segment (#EXT-X-MEDIA-SEQUENCE).
+	Closed        bool   // No segments will be added (#EXT-X-ENDLIST), as for timeshift playlists.
+}
+
+// Master reports whether p is a master playlist.
+func (p *Playlist) Master() bool {
+	return len(p.Variants) > 0
+}
+
+// PlaylistVariant is a variant stream of a master Playlist.
+type PlaylistVariant struct {
+	URI       string
+	Bandwidth int    // Peak bits per second (BANDWIDTH); 0 when not given.
+	Codecs    string // e.g. "mp4a.40.5".
+}
+
+// PlaylistSegment is a segment of a media Playlist.
+type PlaylistSegment struct {
+	URI      string
+	Duration time.Duration // From #EXTINF.
+	Key      *PlaylistKey  // Encryption in effect for the segment; nil when it is clear.
+}
+
+// PlaylistKey is the #EXT-X-KEY that encrypts a PlaylistSegment.
+type PlaylistKey struct {
+	Method string // e.g. "AES-128".
+	URI    string
+	IV     string // "0x..." hex, or empty for the media sequence number.
+}
+
+// ParsePlaylist parses an HLS master or media playlist, e.g. for a Provider's ListTimedChunks.
+func ParsePlaylist(r io.Reader) (*Playlist, error) {
+	parsed, err := internal.ParsePlaylist(r)
+	if err != nil {
+		return nil, err
+	}
+	playlist := &Playlist{
+		Variants:      parsed.Variants,
+		MediaSequence: parsed.MediaSequence,
+		Closed:        parsed.Closed,
+	}
+	for _, v := range parsed.Streams {
+		playlist.Streams = append(playlist.Streams, PlaylistVariant(v))
+	}
+	for _, s := range parsed.Segments {
+		segment := PlaylistSegment{URI: s.URI, Duration: s.Duration}
+		if s.Key != nil {
+			key := PlaylistKey(*s.Key)
+			segment.Key = &key
+		}
+		playlist.Segments = append(playlist.Segments, segment)
+	}
+	return playlist, nil
+}
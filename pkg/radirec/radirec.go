@@ -0,0 +1,250 @@
+// Package radirec exposes the radikoRecScheduler recording pipeline for use from other Go programs.
+//
+// Embedders load or build a schedule, fill in Options and call RunOnce for a single batch,
+// or RunDaemon to keep recording new broadcasts until the context is cancelled:
+//
+//	entries, err := radirec.LoadSchedule("schedule.json")
+//	if err != nil {
+//		return err
+//	}
+//	err = radirec.RunOnce(ctx, radirec.Options{
+//		Schedule:  entries,
+//		OutputDir: "/srv/radio",
+//		Logger:    myLogger,
+//	})
+//
+// The same is available as types: a Scheduler runs a schedule (NewScheduler(opts).RunOnce or Run)
+// and tells when its entries air, a Recorder records single broadcasts and timeshift windows on
+// demand, and a Client reads radiko's station list and program guides.
+//
+// The logger, clock, providers and post-recording storage are all injectable through Options.
+// Further streaming services are added by implementing Provider and calling RegisterProvider.
+//
+// Every type of this package is its own: the pipeline behind it may change without changing
+// the fields and methods documented here.
+package radirec
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// Options configures RunOnce, RunDaemon, Scheduler and Recorder. The zero value records
+// into "output" with the registered radiko provider and the standard logger.
+type Options struct {
+	Schedule    []ScheduleEntry
+	OutputDir   string                                      // Defaults to "output".
+	Logger      *log.Logger                                 // Defaults to the standard logger.
+	Clock       Clock                                       // Defaults to SystemClock.
+	Location    *time.Location                              // Time zone the schedule's days, times and dates are in. Defaults to Japan time.
+	NewProvider func(ctx context.Context) (Provider, error) // Overrides the registered radiko provider.
+	PostStore   PostStore                                   // Optional storage for finished recordings.
+	DeleteLocal bool                                        // Remove local files after a PostStore upload.
+	Interval    time.Duration                               // RunDaemon polling interval. Defaults to one hour.
+	PostCommand string                                      // Global post_command run after each recording.
+	History     *History                                    // Optional recording history.
+	Quiet       bool                                        // One summary line per job instead of detailed logs.
+
+	DisableProgressBar bool // Log progress periodically instead of drawing a progress bar.
+
+	// Lang is the language of console messages and job errors: "en" (default) or "ja".
+	// ProgressCharset draws progress bars with "ascii" (default) or "unicode" block characters.
+	Lang            string
+	ProgressCharset string
+
+	// Providers override registered providers (see RegisterProvider) by name.
+	Providers map[string]ProviderFactory
+
+	Notifier      Notifier                                                    // Optional; receives the weekly preview and run reports.
+	RunReport     bool                                                        // Send a report to Notifier after each run that recorded or failed something.
+	WeeklyPreview WeeklyPreviewConfig                                         // When RunDaemon sends the weekly preview.
+	FetchGuide    func(ctx context.Context, stationID string) ([]byte, error) // Defaults to radiko's guide, through Caches when set.
+	Concurrency   int                                                         // Jobs recorded in parallel. Defaults to 1.
+	ChunkRate     float64                                                     // Chunk requests per second across all jobs; 0 is unlimited.
+	JobChunkRate  float64                                                     // Chunk requests per second of each job; 0 is unlimited.
+
+	// StationConcurrency limits the jobs of one station recorded at the same time, so that the
+	// Concurrency slots go to broadcasts of different stations first; 0 is no limit.
+	StationConcurrency int
+	Caches             *Caches     // Optional guide and chunk caches.
+	Tokens             *TokenCache // Auth tokens shared by jobs. Defaults to an in-memory cache.
+
+	// PanicWindow exempts jobs whose broadcast leaves the timeshift window within this time from
+	// ChunkRate, JobChunkRate and StationConcurrency; 0 exempts none.
+	PanicWindow time.Duration
+
+	Subscriptions []Subscription                              // Keyword subscriptions recorded on every pass.
+	ListStations  func(ctx context.Context) ([]string, error) // Stations scanned by subscriptions without stations. Defaults to radiko's station list.
+
+	// Queue, when set, persists the jobs of each pass: pending and failed jobs are recorded again
+	// on later passes, including after a restart, while the broadcast is in the timeshift window.
+	// RunDaemon also remembers next to it when the weekly preview was last sent.
+	Queue *JobQueue
+	// Retry paces the retries of failed jobs in Queue; RunDaemon wakes for them between passes.
+	Retry RetryConfig
+
+	Layout         string // File naming layout: "timestamp" (default), "title" or "series".
+	TitleCollision string // Suffix of a name already used by a different recording: "date_subtitle" (default), "date" or "subtitle".
+	ScheduleTitles bool   // Name recordings after ScheduleEntry.ProgramName even when the guide's title differs.
+	Overwrite      bool   // Record broadcasts recorded before again, replacing the recordings.
+
+	// Quality selects the variant recorded from master playlists offering several: "highest",
+	// "lowest" or a bitrate in kbps. Headers are added to the playlist and chunk requests of the
+	// built-in providers, by provider name.
+	Quality string
+	Headers map[string]http.Header
+
+	RequestTimeout time.Duration       // Limit of each provider call and chunk download.
+	JobTimeout     time.Duration       // Limit of each job; 0 is unlimited.
+	Rotation       RotationConfig      // Splitting of long recordings into parts.
+	DurationCheck  DurationCheckConfig // Length check of recordings against the guide.
+	Chapters       bool                // Write chapter files from the program guide next to recordings.
+	ShowNotes      bool                // Write the guide's description of each broadcast next to recordings.
+	FFmpeg         string              // ffmpeg for loudness normalization and transcoding. Defaults to "ffmpeg" in PATH.
+	TempDir        string              // Where chunks are downloaded. Defaults to the OS temporary directory.
+	ChunkBufferMB  int                 // Keep chunks in memory, up to this size each, instead of in TempDir.
+
+	// KeepChunks leaves each job's chunks and an index of them in TempDir, for debugging downloads.
+	KeepChunks bool
+	// ChunkNamePattern names the chunk files in TempDir, with {index} and {name} (the URL's file name).
+	ChunkNamePattern string
+
+	// TolerateMissingChunks is the percentage of chunks that may be left out of a recording
+	// when they cannot be downloaded, instead of failing it. 0 tolerates none.
+	TolerateMissingChunks float64
+
+	// Summary, when set, receives the result of every entry handled by RunOnce.
+	Summary *RunSummary
+}
+
+// internal returns the pipeline's options for o.
+func (o Options) internal() internal.Options {
+	opts := internal.Options{
+		Schedule:    scheduleToInternal(o.Schedule),
+		OutputDir:   o.OutputDir,
+		Logger:      o.Logger,
+		Location:    o.Location,
+		DeleteLocal: o.DeleteLocal,
+		Interval:    o.Interval,
+		PostCommand: o.PostCommand,
+		Quiet:       o.Quiet,
+
+		DisableProgressBar: o.DisableProgressBar,
+		Output:             internal.Output{Lang: o.Lang, Charset: o.ProgressCharset},
+
+		RunReport:          o.RunReport,
+		WeeklyPreview:      internal.WeeklyPreviewConfig(o.WeeklyPreview),
+		FetchGuide:         o.FetchGuide,
+		Concurrency:        o.Concurrency,
+		ChunkRate:          o.ChunkRate,
+		JobChunkRate:       o.JobChunkRate,
+		StationConcurrency: o.StationConcurrency,
+		PanicWindow:        o.PanicWindow,
+		ListStations:       o.ListStations,
+		Retry:              internal.RetryConfig(o.Retry),
+		Layout:             o.Layout,
+		TitleCollision:     o.TitleCollision,
+		ScheduleTitles:     o.ScheduleTitles,
+		Overwrite:          o.Overwrite,
+		Quality:            o.Quality,
+		Headers:            o.Headers,
+		RequestTimeout:     o.RequestTimeout,
+		JobTimeout:         o.JobTimeout,
+		Rotation:           internal.RotationConfig(o.Rotation),
+		DurationCheck:      internal.DurationCheckConfig(o.DurationCheck),
+		Chapters:           o.Chapters,
+		ShowNotes:          o.ShowNotes,
+		FFmpeg:             o.FFmpeg,
+		TempDir:            o.TempDir,
+		ChunkBufferMB:      o.ChunkBufferMB,
+
+		TolerateMissingChunks: o.TolerateMissingChunks,
+		KeepChunks:            o.KeepChunks,
+		ChunkNamePattern:      o.ChunkNamePattern,
+	}
+	// Nil interfaces stay nil rather than becoming interfaces holding a nil value.
+	if o.Clock != nil {
+		opts.Clock = o.Clock
+	}
+	if o.PostStore != nil {
+		opts.PostStore = o.PostStore
+	}
+	if o.Notifier != nil {
+		opts.Notifier = o.Notifier
+	}
+	if o.NewProvider != nil {
+		opts.NewProvider = ProviderFactory(o.NewProvider).internal()
+	}
+	if o.Providers != nil {
+		opts.Providers = make(map[string]internal.ProviderFactory, len(o.Providers))
+		for name, factory := range o.Providers {
+			opts.Providers[name] = factory.internal()
+		}
+	}
+	for _, s := range o.Subscriptions {
+		opts.Subscriptions = append(opts.Subscriptions, internal.Subscription(s))
+	}
+	if o.History != nil {
+		opts.History = o.History.history
+	}
+	if o.Caches != nil {
+		opts.Caches = o.Caches.caches
+	}
+	if o.Tokens != nil {
+		opts.Tokens = o.Tokens.cache
+	}
+	if o.Queue != nil {
+		opts.Queue = o.Queue.queue
+	}
+	if o.Summary != nil {
+		opts.Summary = &o.Summary.summary
+	}
+	return opts
+}
+
+// Outcomes of a job in a RunSummary.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomeSkipped   = "skipped"
+)
+
+// Failure classes of jobs, for errors.Is on the errors of RunOnce, Recorder and the other job
+// functions. ChunkDownloadError and ChunkIntegrityError name the chunks of an ErrChunkDownload.
+var (
+	ErrAuthFailed       = internal.ErrAuthFailed
+	ErrStationNotFound  = internal.ErrStationNotFound
+	ErrChunkDownload    = internal.ErrChunkDownload
+	ErrOutsideTimeshift = internal.ErrOutsideTimeshift
+	ErrNotAired         = internal.ErrNotAired
+)
+
+// RunOnce records the most recent past broadcast of every entry in opts.Schedule.
+func RunOnce(ctx context.Context, opts Options) error {
+	return publicError(internal.RunOnce(ctx, opts.internal()))
+}
+
+// RunDaemon runs RunOnce every opts.Interval until ctx is cancelled.
+func RunDaemon(ctx context.Context, opts Options) error {
+	return publicError(internal.RunDaemon(ctx, opts.internal()))
+}
+
+// FetchProgramGuide fetches the weekly program guide of stationID with client, e.g. for
+// Options.FetchGuide when guides have to go through a client of your own.
+func FetchProgramGuide(ctx context.Context, client *http.Client, stationID string) ([]byte, error) {
+	return internal.FetchProgramGuide(ctx, client, stationID)
+}
+
+// RecordRange records the fixed window from-to on stationID once, without a schedule entry.
+func RecordRange(ctx context.Context, opts Options, stationID string, from, to time.Time, title string) (RecordingResult, error) {
+	return NewRecorder(opts).RecordRange(ctx, stationID, from, to, title)
+}
+
+// Backfill records every broadcast of entry from from to to that is still in the timeshift window.
+func Backfill(ctx context.Context, opts Options, entry ScheduleEntry, from, to time.Time) error {
+	return NewRecorder(opts).Backfill(ctx, entry, from, to)
+}
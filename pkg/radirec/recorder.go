@@ -0,0 +1,38 @@
+package radirec
+
+import (
+	"context"
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// Recorder records single broadcasts and timeshift windows on demand, with the output layout,
+// post-processing and history of its Options. Schedule and Subscriptions are not used.
+type Recorder struct {
+	opts Options
+}
+
+// NewRecorder returns a Recorder recording with opts.
+func NewRecorder(opts Options) *Recorder {
+	return &Recorder{opts: opts}
+}
+
+// Record records the broadcast of entry starting at start, which must still be in the
+// timeshift window. A broadcast recorded before is skipped unless opts.Overwrite is set.
+func (r *Recorder) Record(ctx context.Context, entry ScheduleEntry, start time.Time) (RecordingResult, error) {
+	result, err := internal.RecordBroadcast(ctx, r.opts.internal(), entry.internal(), start)
+	return RecordingResult(result), publicError(err)
+}
+
+// RecordRange records the fixed window from-to on stationID, named after the program starting
+// at from in the guide, or after title.
+func (r *Recorder) RecordRange(ctx context.Context, stationID string, from, to time.Time, title string) (RecordingResult, error) {
+	result, err := internal.RecordRange(ctx, r.opts.internal(), stationID, from, to, title)
+	return RecordingResult(result), publicError(err)
+}
+
+// Backfill records every broadcast of entry from from to to that is still in the timeshift window.
+func (r *Recorder) Backfill(ctx context.Context, entry ScheduleEntry, from, to time.Time) error {
+	return publicError(internal.Backfill(ctx, r.opts.internal(), entry.internal(), from, to))
+}
@@ -0,0 +1,79 @@
+package radirec_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"radikoRecScheduler/pkg/radirec"
+	"radikoRecScheduler/pkg/radirec/testutil"
+)
+
+func TestRecorderRecord(t *testing.T) {
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, jst)
+	start := time.Date(2026, time.January, 12, 10, 0, 0, 0, jst)
+	outputDir := t.TempDir()
+	provider := &testutil.MockProvider{Chunks: 3}
+	recorder := radirec.NewRecorder(radirec.Options{
+		OutputDir:   outputDir,
+		Logger:      log.New(io.Discard, "", 0),
+		Clock:       testutil.NewFakeClock(now),
+		NewProvider: provider.Factory(),
+		FetchGuide:  testutil.GuideFetcher(nil),
+
+		DisableProgressBar: true,
+	})
+	entry := radirec.ScheduleEntry{ProgramName: "Show", DayOfWeek: "月", StartTime: "100000", StationID: "TBS"}
+
+	result, err := recorder.Record(context.Background(), entry, start)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if want := filepath.Join(outputDir, "20260112100000-TBS-Show.aac"); result.OutputPath != want || result.Title != "Show" || result.Skipped {
+		t.Errorf("unexpected result %+v", result)
+	}
+	// The durations of the mock's chunks reach the pipeline.
+	if result.Chunks != 3 || result.Duration != 15*time.Second || result.Size != int64(3*len(testutil.SyntheticAAC(5*time.Second))) {
+		t.Errorf("unexpected chunks, duration or size in %+v", result)
+	}
+
+	if result, err := recorder.Record(context.Background(), entry, start); err != nil || !result.Skipped {
+		t.Errorf("expected the broadcast to be skipped, got %+v, %v", result, err)
+	}
+
+	if _, err := recorder.Record(context.Background(), entry, now.AddDate(0, 0, -10)); !errors.Is(err, radirec.ErrOutsideTimeshift) {
+		t.Errorf("expected ErrOutsideTimeshift, got %v", err)
+	}
+}
+
+func TestRecorderChunkErrors(t *testing.T) {
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, jst)
+	provider := &testutil.MockProvider{
+		DownloadFn: func(ctx context.Context, chunkURL string) (io.ReadCloser, error) {
+			return nil, errors.New("network error")
+		},
+	}
+	recorder := radirec.NewRecorder(radirec.Options{
+		OutputDir:   t.TempDir(),
+		Logger:      log.New(io.Discard, "", 0),
+		Clock:       testutil.NewFakeClock(now),
+		NewProvider: provider.Factory(),
+		FetchGuide:  testutil.GuideFetcher(nil),
+
+		DisableProgressBar: true,
+	})
+	entry := radirec.ScheduleEntry{ProgramName: "Show", DayOfWeek: "月", StartTime: "100000", StationID: "TBS"}
+
+	_, err := recorder.Record(context.Background(), entry, time.Date(2026, time.January, 12, 10, 0, 0, 0, jst))
+	if !errors.Is(err, radirec.ErrChunkDownload) {
+		t.Fatalf("expected ErrChunkDownload, got %v", err)
+	}
+	var chunkErr *radirec.ChunkDownloadError
+	if !errors.As(err, &chunkErr) || chunkErr.Index != 0 || chunkErr.URL != "mock://TBS/20260112100000.m3u8/chunk0.aac" {
+		t.Errorf("errors.As(*ChunkDownloadError) = %+v in %v", chunkErr, err)
+	}
+}
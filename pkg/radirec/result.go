@@ -0,0 +1,143 @@
+package radirec
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// RecordingResult describes a finished recording.
+type RecordingResult struct {
+	OutputPath string        // The recording, or the playlist of a rotated one; the earlier recording when skipped.
+	Title      string        // Program title from the guide, or the schedule's name.
+	Start      time.Time     // Start of the broadcast, after any move found in the guide.
+	Size       int64         // Bytes of audio written, across all parts.
+	Chunks     int           // Chunks downloaded.
+	Duration   time.Duration // Length of the audio according to the playlist; 0 if unknown.
+	Skipped    bool          // The broadcast was recorded before; nothing was downloaded.
+}
+
+// JobResult is the outcome of one entry of a run.
+type JobResult struct {
+	ProgramName string
+	StationID   string
+	Start       time.Time // Zero if the broadcast time could not be determined.
+	Outcome     string    // OutcomeSucceeded, OutcomeFailed or OutcomeSkipped.
+	OutputPath  string    // The recording, for succeeded and skipped jobs.
+	Size        int64     // Bytes of audio recorded, for succeeded jobs.
+	Err         error     // Why the job failed.
+}
+
+// RunSummary collects the result of every entry handled by RunOnce (see Options.Summary),
+// in the order the jobs finish. The zero value is ready to use; it is safe for concurrent use
+// and must not be copied once used.
+type RunSummary struct {
+	summary internal.RunSummary
+}
+
+// Results returns a copy of the results collected so far.
+func (s *RunSummary) Results() []JobResult {
+	collected := s.summary.Results()
+	results := make([]JobResult, len(collected))
+	for i, r := range collected {
+		results[i] = JobResult{
+			ProgramName: r.ProgramName,
+			StationID:   r.StationID,
+			Start:       r.Start,
+			Outcome:     r.Outcome,
+			OutputPath:  r.OutputPath,
+			Size:        r.Size,
+			Err:         publicError(r.Err),
+		}
+	}
+	return results
+}
+
+// Counts returns the number of succeeded, failed and skipped jobs.
+func (s *RunSummary) Counts() (succeeded, failed, skipped int) {
+	return s.summary.Counts()
+}
+
+// ChunkFailure is one chunk of a ChunkIntegrityError.
+type ChunkFailure struct {
+	Index int
+	URL   string
+	Err   error
+}
+
+// ChunkDownloadError reports a chunk whose download failed after all attempts. It is an
+// ErrChunkDownload.
+type ChunkDownloadError struct {
+	Index int
+	URL   string
+	Err   error
+}
+
+func (e *ChunkDownloadError) Error() string {
+	return fmt.Sprintf("failed to download chunk %d (%s): %v", e.Index, e.URL, e.Err)
+}
+
+func (e *ChunkDownloadError) Unwrap() error { return e.Err }
+
+func (e *ChunkDownloadError) Is(target error) bool { return target == ErrChunkDownload }
+
+// ChunkIntegrityError reports every chunk that still failed verification after all attempts,
+// in the order of the playlist. It is an ErrChunkDownload.
+type ChunkIntegrityError struct {
+	Failures []ChunkFailure
+}
+
+func (e *ChunkIntegrityError) Error() string {
+	parts := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		parts[i] = fmt.Sprintf("chunk %d (%s): %v", f.Index, f.URL, f.Err)
+	}
+	return fmt.Sprintf("%d chunk(s) unrecoverable: %s", len(e.Failures), strings.Join(parts, "; "))
+}
+
+func (e *ChunkIntegrityError) Is(target error) bool { return target == ErrChunkDownload }
+
+// jobError is an error of the pipeline, whose chunk errors errors.As finds as
+// ChunkDownloadError and ChunkIntegrityError.
+type jobError struct {
+	err error
+}
+
+// publicError returns err for the callers of this package, or nil if err is nil.
+func publicError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &jobError{err: err}
+}
+
+func (e *jobError) Error() string { return e.err.Error() }
+
+func (e *jobError) Unwrap() error { return e.err }
+
+func (e *jobError) As(target any) bool {
+	switch target := target.(type) {
+	case **ChunkDownloadError:
+		var chunkErr *internal.ChunkDownloadError
+		if errors.As(e.err, &chunkErr) {
+			*target = &ChunkDownloadError{Index: chunkErr.Index, URL: chunkErr.URL, Err: chunkErr.Err}
+			return true
+		}
+	case **ChunkIntegrityError:
+		var integrityErr *internal.ChunkIntegrityError
+		if errors.As(e.err, &integrityErr) {
+			failures := make([]ChunkFailure, len(integrityErr.Failures))
+			for i, f := range integrityErr.Failures {
+				failures[i] = ChunkFailure(f)
+			}
+			sort.Slice(failures, func(i, j int) bool { return failures[i].Index < failures[j].Index })
+			*target = &ChunkIntegrityError{Failures: failures}
+			return true
+		}
+	}
+	return false
+}
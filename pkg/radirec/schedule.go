@@ -0,0 +1,180 @@
+package radirec
+
+import (
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// ScheduleEntry is a single weekly program to record, as written in schedule.json.
+type ScheduleEntry struct {
+	ProgramName string `json:"program_name" yaml:"program_name" toml:"program_name"`
+	DayOfWeek   string `json:"day_of_week,omitempty" yaml:"day_of_week,omitempty" toml:"day_of_week,omitempty"` // Japanese weekday, e.g. "月".
+	StartTime   string `json:"start_time" yaml:"start_time" toml:"start_time"`                                  // HHMMSS, 24:00 and later for late-night programs.
+	StationID   string `json:"station_id" yaml:"station_id" toml:"station_id"`
+	PostCommand string `json:"post_command,omitempty" yaml:"post_command,omitempty" toml:"post_command,omitempty"` // Overrides Options.PostCommand.
+	Provider    string `json:"provider,omitempty" yaml:"provider,omitempty" toml:"provider,omitempty"`             // "radiko" (default), "radiru" or a registered provider.
+
+	// Rerun is an official rerun slot, recorded instead when the primary broadcast cannot be.
+	Rerun *RerunSlot `json:"rerun,omitempty" yaml:"rerun,omitempty" toml:"rerun,omitempty"`
+	// FallbackStations air the same program in the same slot and are tried in order when
+	// recording it from StationID fails.
+	FallbackStations []string `json:"fallback_stations,omitempty" yaml:"fallback_stations,omitempty" toml:"fallback_stations,omitempty"`
+	// ShiftWindow is how many minutes before or after the slot the guide is searched for the
+	// program when it was moved. 0 only looks at the slot itself.
+	ShiftWindow int `json:"shift_window,omitempty" yaml:"shift_window,omitempty" toml:"shift_window,omitempty"`
+
+	// Enabled set to false pauses the entry; entries are enabled when it is nil.
+	Enabled *bool  `json:"enabled,omitempty" yaml:"enabled,omitempty" toml:"enabled,omitempty"`
+	Note    string `json:"note,omitempty" yaml:"note,omitempty" toml:"note,omitempty"`
+	// Priority orders the jobs of a run when more broadcasts are due than can be recorded at once.
+	Priority int `json:"priority,omitempty" yaml:"priority,omitempty" toml:"priority,omitempty"`
+
+	SplitMinutes   int `json:"split_minutes,omitempty" yaml:"split_minutes,omitempty" toml:"split_minutes,omitempty"`       // Overrides Options.Rotation.MaxMinutes.
+	TimeoutMinutes int `json:"timeout_minutes,omitempty" yaml:"timeout_minutes,omitempty" toml:"timeout_minutes,omitempty"` // Overrides Options.JobTimeout.
+
+	// Date (YYYY-MM-DD, JST) makes the entry a one-shot for a special, or with EveryWeeks the
+	// first of its broadcasts.
+	Date         string `json:"date,omitempty" yaml:"date,omitempty" toml:"date,omitempty"`
+	WeeksOfMonth []int  `json:"weeks_of_month,omitempty" yaml:"weeks_of_month,omitempty" toml:"weeks_of_month,omitempty"` // 1-5, -1 for the last.
+	EveryWeeks   int    `json:"every_weeks,omitempty" yaml:"every_weeks,omitempty" toml:"every_weeks,omitempty"`
+
+	SkipDates    []string `json:"skip_dates,omitempty" yaml:"skip_dates,omitempty" toml:"skip_dates,omitempty"` // YYYY-MM-DD, JST.
+	SkipHolidays bool     `json:"skip_holidays,omitempty" yaml:"skip_holidays,omitempty" toml:"skip_holidays,omitempty"`
+
+	Normalize bool   `json:"normalize,omitempty" yaml:"normalize,omitempty" toml:"normalize,omitempty"` // EBU R128 loudness normalization with ffmpeg.
+	Archive   string `json:"archive,omitempty" yaml:"archive,omitempty" toml:"archive,omitempty"`       // "opus" or "flac" to transcode the recording with ffmpeg.
+}
+
+// IsEnabled reports whether the entry is recorded, see Enabled.
+func (e ScheduleEntry) IsEnabled() bool {
+	return e.Enabled == nil || *e.Enabled
+}
+
+// RerunSlot is the weekly slot of a program's official rerun.
+type RerunSlot struct {
+	DayOfWeek string `json:"day_of_week" yaml:"day_of_week" toml:"day_of_week"`
+	StartTime string `json:"start_time" yaml:"start_time" toml:"start_time"`
+	StationID string `json:"station_id,omitempty" yaml:"station_id,omitempty" toml:"station_id,omitempty"` // Defaults to the entry's station.
+}
+
+// ScheduleFilter selects entries of a schedule, see FilterSchedule.
+type ScheduleFilter struct {
+	ProgramName string         // program_name of the entries, ignoring case.
+	Stations    []string       // station_id of the entries.
+	Weekdays    []time.Weekday // Days the entries air on, from day_of_week or date.
+}
+
+// Subscription records every broadcast whose guide entry matches a keyword.
+type Subscription struct {
+	Name        string   `json:"name,omitempty"` // Shown in logs. Defaults to the keyword.
+	Keyword     string   `json:"keyword"`
+	Regex       bool     `json:"regex,omitempty"`    // Keyword is a regular expression instead of a plain substring.
+	Stations    []string `json:"stations,omitempty"` // Stations whose guides are scanned. Defaults to all stations.
+	PostCommand string   `json:"post_command,omitempty"`
+}
+
+// internal returns the pipeline's copy of e.
+func (e ScheduleEntry) internal() internal.ScheduleEntry {
+	entry := internal.ScheduleEntry{
+		ProgramName:      e.ProgramName,
+		DayOfWeek:        e.DayOfWeek,
+		StartTime:        e.StartTime,
+		StationID:        e.StationID,
+		PostCommand:      e.PostCommand,
+		Provider:         e.Provider,
+		FallbackStations: e.FallbackStations,
+		ShiftWindow:      e.ShiftWindow,
+		Enabled:          e.Enabled,
+		Note:             e.Note,
+		Priority:         e.Priority,
+		SplitMinutes:     e.SplitMinutes,
+		TimeoutMinutes:   e.TimeoutMinutes,
+		Date:             e.Date,
+		WeeksOfMonth:     e.WeeksOfMonth,
+		EveryWeeks:       e.EveryWeeks,
+		SkipDates:        e.SkipDates,
+		SkipHolidays:     e.SkipHolidays,
+		Normalize:        e.Normalize,
+		Archive:          e.Archive,
+	}
+	if e.Rerun != nil {
+		rerun := internal.RerunSlot(*e.Rerun)
+		entry.Rerun = &rerun
+	}
+	return entry
+}
+
+// scheduleEntry returns the public copy of the pipeline's entry e.
+func scheduleEntry(e internal.ScheduleEntry) ScheduleEntry {
+	entry := ScheduleEntry{
+		ProgramName:      e.ProgramName,
+		DayOfWeek:        e.DayOfWeek,
+		StartTime:        e.StartTime,
+		StationID:        e.StationID,
+		PostCommand:      e.PostCommand,
+		Provider:         e.Provider,
+		FallbackStations: e.FallbackStations,
+		ShiftWindow:      e.ShiftWindow,
+		Enabled:          e.Enabled,
+		Note:             e.Note,
+		Priority:         e.Priority,
+		SplitMinutes:     e.SplitMinutes,
+		TimeoutMinutes:   e.TimeoutMinutes,
+		Date:             e.Date,
+		WeeksOfMonth:     e.WeeksOfMonth,
+		EveryWeeks:       e.EveryWeeks,
+		SkipDates:        e.SkipDates,
+		SkipHolidays:     e.SkipHolidays,
+		Normalize:        e.Normalize,
+		Archive:          e.Archive,
+	}
+	if e.Rerun != nil {
+		rerun := RerunSlot(*e.Rerun)
+		entry.Rerun = &rerun
+	}
+	return entry
+}
+
+func scheduleToInternal(entries []ScheduleEntry) []internal.ScheduleEntry {
+	if entries == nil {
+		return nil
+	}
+	converted := make([]internal.ScheduleEntry, len(entries))
+	for i, e := range entries {
+		converted[i] = e.internal()
+	}
+	return converted
+}
+
+func scheduleFromInternal(entries []internal.ScheduleEntry) []ScheduleEntry {
+	if entries == nil {
+		return nil
+	}
+	converted := make([]ScheduleEntry, len(entries))
+	for i, e := range entries {
+		converted[i] = scheduleEntry(e)
+	}
+	return converted
+}
+
+// LoadSchedule reads a schedule file: JSON, YAML or TOML by its extension.
+func LoadSchedule(filePath string) ([]ScheduleEntry, error) {
+	entries, err := internal.LoadSchedule(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return scheduleFromInternal(entries), nil
+}
+
+// MergeDuplicates leaves out the entries recording the same slot as an earlier one, returning
+// a warning for each.
+func MergeDuplicates(entries []ScheduleEntry) ([]ScheduleEntry, []string) {
+	merged, warnings := internal.MergeDuplicates(scheduleToInternal(entries))
+	return scheduleFromInternal(merged), warnings
+}
+
+// FilterSchedule returns the entries of schedule that f selects.
+func FilterSchedule(schedule []ScheduleEntry, f ScheduleFilter) []ScheduleEntry {
+	return scheduleFromInternal(internal.FilterSchedule(scheduleToInternal(schedule), internal.ScheduleFilter(f)))
+}
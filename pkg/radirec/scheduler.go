@@ -0,0 +1,54 @@
+package radirec
+
+import (
+	"context"
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// Scheduler records the broadcasts of a schedule: the most recent one of every entry with
+// RunOnce, or new ones as they become available with Run.
+type Scheduler struct {
+	opts Options
+}
+
+// NewScheduler returns a Scheduler recording opts.Schedule (and opts.Subscriptions) with opts.
+func NewScheduler(opts Options) *Scheduler {
+	if opts.Clock == nil {
+		opts.Clock = SystemClock
+	}
+	return &Scheduler{opts: opts}
+}
+
+// RunOnce records the most recent past broadcast of every entry and returns the outcome of each.
+// The error reports the jobs that failed; the summary is complete either way.
+func (s *Scheduler) RunOnce(ctx context.Context) (*RunSummary, error) {
+	opts := s.opts
+	if opts.Summary == nil {
+		opts.Summary = &RunSummary{}
+	}
+	err := RunOnce(ctx, opts)
+	return opts.Summary, err
+}
+
+// Run records new broadcasts every opts.Interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) error {
+	return RunDaemon(ctx, s.opts)
+}
+
+// Recent returns the start of the most recent broadcast of entry that has aired.
+func (s *Scheduler) Recent(entry ScheduleEntry) (time.Time, error) {
+	return internal.CalculateRecentPastRunTime(entry.internal(), s.opts.Clock.Now(), s.opts.Location)
+}
+
+// Next returns the start of the next broadcast of entry.
+func (s *Scheduler) Next(entry ScheduleEntry) (time.Time, error) {
+	return internal.CalculateNextRunTime(entry.internal(), s.opts.Clock.Now(), s.opts.Location)
+}
+
+// Between returns the broadcasts of entry starting at or after from and before to, oldest first,
+// leaving out those the entry skips.
+func (s *Scheduler) Between(entry ScheduleEntry, from, to time.Time) ([]time.Time, error) {
+	return internal.BroadcastsBetween(entry.internal(), from, to, s.opts.Location)
+}
@@ -0,0 +1,77 @@
+package radirec_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"radikoRecScheduler/pkg/radirec"
+	"radikoRecScheduler/pkg/radirec/testutil"
+)
+
+func TestSchedulerRunOnce(t *testing.T) {
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, jst) // Tuesday
+	start := time.Date(2026, time.January, 12, 10, 0, 0, 0, jst)
+	outputDir := t.TempDir()
+	history := radirec.OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+
+	provider := &testutil.MockProvider{Chunks: 3}
+	scheduler := radirec.NewScheduler(radirec.Options{
+		Schedule:    []radirec.ScheduleEntry{{ProgramName: "Schedule Name", DayOfWeek: "月", StartTime: "100000", StationID: "TBS"}},
+		OutputDir:   outputDir,
+		Logger:      log.New(io.Discard, "", 0),
+		Clock:       testutil.NewFakeClock(now),
+		NewProvider: provider.Factory(),
+		History:     history,
+		FetchGuide: testutil.GuideFetcher(map[string][]byte{
+			"TBS": testutil.GuideXML("TBS", testutil.Program{Start: start, End: start.Add(time.Hour), Title: "Guide Title"}),
+		}),
+		DisableProgressBar: true,
+	})
+
+	summary, err := scheduler.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	results := summary.Results()
+	want := filepath.Join(outputDir, "20260112100000-TBS-Guide Title.aac")
+	if len(results) != 1 || results[0].Outcome != radirec.OutcomeSucceeded || results[0].OutputPath != want || !results[0].Start.Equal(start) {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	records, err := history.Records()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expected one history record, got %v, %v", records, err)
+	}
+	if rec := records[0]; rec.Status != radirec.StatusSuccess || rec.Title != "Guide Title" || rec.Guide == nil || !rec.Guide.Start.Equal(start) {
+		t.Errorf("unexpected history record %+v", rec)
+	}
+
+	summary, err = scheduler.RunOnce(context.Background())
+	if succeeded, failed, skipped := summary.Counts(); err != nil || succeeded != 0 || failed != 0 || skipped != 1 {
+		t.Errorf("expected the second run to skip the recording, got %d/%d/%d, %v", succeeded, failed, skipped, err)
+	}
+}
+
+func TestSchedulerBroadcasts(t *testing.T) {
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, jst) // Tuesday
+	scheduler := radirec.NewScheduler(radirec.Options{Clock: testutil.NewFakeClock(now)})
+	// A late-night program is scheduled on the day it starts, at 1:00 on Tuesday.
+	entry := radirec.ScheduleEntry{ProgramName: "Show", DayOfWeek: "火", StartTime: "010000", StationID: "TBS"}
+
+	if recent, err := scheduler.Recent(entry); err != nil || !recent.Equal(time.Date(2026, time.January, 13, 1, 0, 0, 0, jst)) {
+		t.Errorf("Recent = %v, %v", recent, err)
+	}
+	if next, err := scheduler.Next(entry); err != nil || !next.Equal(time.Date(2026, time.January, 20, 1, 0, 0, 0, jst)) {
+		t.Errorf("Next = %v, %v", next, err)
+	}
+
+	entry.SkipDates = []string{"2026-01-06"}
+	got, err := scheduler.Between(entry, now.AddDate(0, 0, -14), now)
+	if err != nil || len(got) != 1 || !got[0].Equal(time.Date(2026, time.January, 13, 1, 0, 0, 0, jst)) {
+		t.Errorf("Between = %v, %v", got, err)
+	}
+}
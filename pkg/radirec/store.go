@@ -0,0 +1,193 @@
+package radirec
+
+import (
+	"context"
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// PostStore receives finished recordings.
+type PostStore interface {
+	// Upload stores the file at localPath and returns the remote location it was written to.
+	Upload(ctx context.Context, localPath string) (string, error)
+}
+
+// Notifier delivers the weekly preview and run reports, see Options.Notifier.
+type Notifier interface {
+	Notify(ctx context.Context, subject, body string) error
+}
+
+// S3Config configures the S3-compatible PostStore of NewS3Store.
+type S3Config struct {
+	Endpoint        string `json:"endpoint"` // e.g. "https://s3.ap-northeast-1.amazonaws.com" or a MinIO URL.
+	Region          string `json:"region"`
+	Bucket          string `json:"bucket"`
+	Prefix          string `json:"prefix"` // Key prefix ("path") inside the bucket.
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+}
+
+// NewS3Store returns a PostStore that uploads to an S3-compatible bucket.
+func NewS3Store(cfg S3Config) (PostStore, error) {
+	store, err := internal.NewS3Store(internal.S3Config(cfg), nil)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Statuses of a HistoryRecord.
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// History is the append-only recording history, a JSON Lines file written by the jobs of
+// Options.History.
+type History struct {
+	history *internal.History
+}
+
+// HistoryRecord is one job in the History.
+type HistoryRecord struct {
+	ID          string
+	ProgramName string // program_name of the schedule entry.
+	Title       string // Title the recording was saved under.
+	StationID   string
+	Provider    string    // Schedule entry's provider; empty for radiko.
+	StartTime   time.Time // Broadcast start time.
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	Status      string // StatusSuccess or StatusFailed.
+	OutputPath  string
+	Error       string
+	LogPath     string // Log lines emitted during the job.
+	Rerun       bool   // Recorded from the entry's rerun slot.
+
+	Size         int64  // Bytes of the saved recording.
+	SHA256       string // Hex SHA-256 of the saved recording.
+	LocalRemoved bool   // The local file was deleted after a post-store upload.
+	// PendingUploads lists the files of a saved recording whose upload to the post-store failed;
+	// the next attempt at the broadcast uploads only these.
+	PendingUploads []string
+
+	// Guide is the program guide entry the recording was resolved with, if any.
+	Guide *Program
+}
+
+// OpenHistory returns the History stored at path. The file is created by the first job.
+func OpenHistory(path string) *History {
+	return &History{history: internal.OpenHistory(path)}
+}
+
+// Path returns the path of the history file.
+func (h *History) Path() string {
+	return h.history.Path()
+}
+
+// Records returns every record, oldest first.
+func (h *History) Records() ([]HistoryRecord, error) {
+	records, err := h.history.Records()
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]HistoryRecord, len(records))
+	for i, r := range records {
+		converted[i] = historyRecord(r)
+	}
+	return converted, nil
+}
+
+// Find returns the record of the job with the given ID.
+func (h *History) Find(id string) (HistoryRecord, error) {
+	r, err := h.history.Find(id)
+	if err != nil {
+		return HistoryRecord{}, err
+	}
+	return historyRecord(r), nil
+}
+
+// FindRecording returns the most recent successful recording of the broadcast starting at
+// start on stationID, tagged as a rerun or not, or false if there is none.
+func (h *History) FindRecording(stationID string, start time.Time, rerun bool) (HistoryRecord, bool, error) {
+	r, ok, err := h.history.FindRecording(stationID, start, rerun)
+	if err != nil || !ok {
+		return HistoryRecord{}, ok, err
+	}
+	return historyRecord(r), true, nil
+}
+
+func historyRecord(r internal.HistoryRecord) HistoryRecord {
+	rec := HistoryRecord{
+		ID:           r.ID,
+		ProgramName:  r.ProgramName,
+		Title:        r.Title,
+		StationID:    r.StationID,
+		Provider:     r.Provider,
+		StartTime:    r.StartTime,
+		StartedAt:    r.StartedAt,
+		FinishedAt:   r.FinishedAt,
+		Status:       r.Status,
+		OutputPath:   r.OutputPath,
+		Error:        r.Error,
+		LogPath:      r.LogPath,
+		Rerun:        r.Rerun,
+		Size:         r.Size,
+		SHA256:       r.SHA256,
+		LocalRemoved: r.LocalRemoved,
+
+		PendingUploads: r.PendingUploads,
+	}
+	if r.Guide != nil {
+		prog := program(*r.Guide)
+		rec.Guide = &prog
+	}
+	return rec
+}
+
+// JobQueue persists pending and failed jobs across daemon restarts, see Options.Queue.
+type JobQueue struct {
+	queue *internal.JobQueue
+}
+
+// OpenJobQueue reads the JobQueue stored at path; a missing file yields an empty queue.
+func OpenJobQueue(path string) (*JobQueue, error) {
+	queue, err := internal.OpenJobQueue(path)
+	if err != nil {
+		return nil, err
+	}
+	return &JobQueue{queue: queue}, nil
+}
+
+// TokenCache keeps auth tokens between jobs, in memory and optionally in a file, see
+// Options.Tokens and TokenAuthenticator.
+type TokenCache struct {
+	cache *internal.TokenCache
+}
+
+// NewTokenCache returns an auth token cache persisted to path, or kept in memory if path is
+// empty. A nil clock means SystemClock.
+func NewTokenCache(path string, clock Clock) *TokenCache {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &TokenCache{cache: internal.NewTokenCache(path, clock)}
+}
+
+// Caches holds the size-capped guide, chunk and artwork caches, see Options.Caches and NewClient.
+type Caches struct {
+	caches *internal.Caches
+}
+
+// CacheConfig sets the size cap of each cache in megabytes; 0 disables that cache.
+type CacheConfig struct {
+	GuideMB   int `json:"guide_mb"`
+	ChunkMB   int `json:"chunk_mb"`
+	ArtworkMB int `json:"artwork_mb"`
+}
+
+// OpenCaches returns the caches stored under dir with the caps from cfg.
+func OpenCaches(dir string, cfg CacheConfig) *Caches {
+	return &Caches{caches: internal.OpenCaches(dir, internal.CacheConfig{GuideMB: cfg.GuideMB, ChunkMB: cfg.ChunkMB, ArtworkMB: cfg.ArtworkMB})}
+}
@@ -0,0 +1,44 @@
+package testutil
+
+import "time"
+
+const (
+	// AACSampleRate is the sample rate of the synthetic audio (that of radiko streams).
+	AACSampleRate = 48000
+	// AACFrameDuration is the length of audio held by one ADTS frame (1024 samples).
+	AACFrameDuration = time.Second * 1024 / AACSampleRate
+
+	adtsHeaderSize      = 7
+	adtsSampleRateIndex = 3 // 48000 Hz
+	adtsChannels        = 2
+)
+
+// ADTSFrame returns one AAC-LC ADTS frame (48 kHz stereo, no CRC) carrying payload.
+// The payload is not valid AAC audio, but the header is, so frame-level parsers accept it.
+func ADTSFrame(payload []byte) []byte {
+	length := adtsHeaderSize + len(payload)
+	frame := make([]byte, adtsHeaderSize, length)
+	frame[0] = 0xFF
+	frame[1] = 0xF1 // MPEG-4, layer 0, no CRC
+	frame[2] = 1<<6 | adtsSampleRateIndex<<2 | adtsChannels>>2
+	frame[3] = byte(adtsChannels&3)<<6 | byte(length>>11)
+	frame[4] = byte(length >> 3)
+	frame[5] = byte(length&7)<<5 | 0x1F
+	frame[6] = 0xFC
+	return append(frame, payload...)
+}
+
+// SyntheticAAC returns ADTS frames covering at least d of audio.
+func SyntheticAAC(d time.Duration) []byte {
+	frames := int((d + AACFrameDuration - 1) / AACFrameDuration)
+	if frames < 1 {
+		frames = 1
+	}
+	payload := make([]byte, 8)
+	out := make([]byte, 0, frames*(adtsHeaderSize+len(payload)))
+	for i := 0; i < frames; i++ {
+		payload[0] = byte(i) // Keep frames distinguishable when comparing output.
+		out = append(out, ADTSFrame(payload)...)
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MasterPlaylist returns an HLS master playlist with a single AAC variant at mediaURI,
+// in the form returned by radiko's timeshift playlist API.
+func MasterPlaylist(mediaURI string) string {
+	return "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=52973,CODECS=\"mp4a.40.5\"\n" + mediaURI + "\n"
+}
+
+// MediaPlaylist returns a complete HLS media playlist listing segmentURIs, each segmentDuration long.
+func MediaPlaylist(segmentURIs []string, segmentDuration time.Duration) string {
+	var b strings.Builder
+	seconds := int((segmentDuration + time.Second - 1) / time.Second)
+	fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:%d\n#EXT-X-MEDIA-SEQUENCE:0\n", seconds)
+	for _, uri := range segmentURIs {
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", segmentDuration.Seconds(), uri)
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String()
+}
+
+// Program is one broadcast in a program guide built by GuideXML.
+type Program struct {
+	Start, End time.Time
+	Title      string
+	Performer  string
+	Desc       string
+}
+
+const guideTimeLayout = "20060102150405"
+
+// GuideXML returns a radiko weekly program guide for stationID listing programs,
+// as served by the program guide API.
+func GuideXML(stationID string, programs ...Program) []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<radiko>\n  <stations>\n")
+	fmt.Fprintf(&b, "    <station id=\"%s\">\n      <progs>\n", escape(stationID))
+	for _, p := range programs {
+		start, end := p.Start.In(jst), p.End.In(jst)
+		fmt.Fprintf(&b, "        <prog ft=\"%s\" to=\"%s\" ftl=\"%s\" tol=\"%s\" dur=\"%d\">\n",
+			start.Format(guideTimeLayout), end.Format(guideTimeLayout),
+			start.Format("1504"), end.Format("1504"), int(end.Sub(start).Seconds()))
+		fmt.Fprintf(&b, "          <title>%s</title>\n          <pfm>%s</pfm>\n          <desc>%s</desc>\n",
+			escape(p.Title), escape(p.Performer), escape(p.Desc))
+		b.WriteString("        </prog>\n")
+	}
+	b.WriteString("      </progs>\n    </station>\n  </stations>\n</radiko>\n")
+	return b.Bytes()
+}
+
+// GuideFetcher returns a function for Options.FetchGuide that serves guides[stationID]
+// and fails for stations without a guide, as when the guide API is unreachable.
+func GuideFetcher(guides map[string][]byte) func(ctx context.Context, stationID string) ([]byte, error) {
+	return func(ctx context.Context, stationID string) ([]byte, error) {
+		guide, ok := guides[stationID]
+		if !ok {
+			return nil, fmt.Errorf("no program guide for station %s", stationID)
+		}
+		return guide, nil
+	}
+}
+
+var jst = time.FixedZone("Asia/Tokyo", 9*60*60)
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
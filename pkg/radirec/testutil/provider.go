@@ -0,0 +1,126 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"radikoRecScheduler/pkg/radirec"
+)
+
+// MockProvider is a radirec.Provider that serves synthetic recordings from memory.
+// Each Fn field, when set, replaces the corresponding default behaviour.
+type MockProvider struct {
+	AuthenticateFn    func(ctx context.Context) error
+	ResolvePlaylistFn func(ctx context.Context, stationID string, start time.Time) (string, error)
+	ListChunksFn      func(ctx context.Context, uri string) ([]string, error)
+	DownloadFn        func(ctx context.Context, chunkURL string) (io.ReadCloser, error)
+
+	// Chunks is the number of chunks listed by default. Defaults to 2.
+	Chunks int
+	// ChunkDuration is the length of the synthetic audio in each default chunk. Defaults to 5 seconds.
+	ChunkDuration time.Duration
+
+	mu        sync.Mutex
+	playlists []string
+	downloads []string
+}
+
+var (
+	_ radirec.Provider         = (*MockProvider)(nil)
+	_ radirec.TimedChunkLister = (*MockProvider)(nil)
+)
+
+// Factory returns a radirec.ProviderFactory that always hands out m,
+// for use as Options.NewProvider or in Options.Providers.
+func (m *MockProvider) Factory() radirec.ProviderFactory {
+	return func(ctx context.Context) (radirec.Provider, error) { return m, nil }
+}
+
+// Authenticate succeeds unless AuthenticateFn says otherwise.
+func (m *MockProvider) Authenticate(ctx context.Context) error {
+	if m.AuthenticateFn != nil {
+		return m.AuthenticateFn(ctx)
+	}
+	return nil
+}
+
+// ResolvePlaylist returns mock://<stationID>/<start>.m3u8 by default.
+func (m *MockProvider) ResolvePlaylist(ctx context.Context, stationID string, start time.Time) (string, error) {
+	m.mu.Lock()
+	m.playlists = append(m.playlists, stationID+"|"+start.Format("20060102150405"))
+	m.mu.Unlock()
+	if m.ResolvePlaylistFn != nil {
+		return m.ResolvePlaylistFn(ctx, stationID, start)
+	}
+	return fmt.Sprintf("mock://%s/%s.m3u8", stationID, start.Format("20060102150405")), nil
+}
+
+// ListChunks returns Chunks chunk URLs derived from uri by default.
+func (m *MockProvider) ListChunks(ctx context.Context, uri string) ([]string, error) {
+	if m.ListChunksFn != nil {
+		return m.ListChunksFn(ctx, uri)
+	}
+	return m.defaultChunks(uri), nil
+}
+
+// ListTimedChunks reports the chunks of ListChunks, each ChunkDuration long, so progress is
+// measured in broadcast time as with the real providers.
+func (m *MockProvider) ListTimedChunks(ctx context.Context, uri string) ([]radirec.Chunk, error) {
+	urls, err := m.ListChunks(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]radirec.Chunk, len(urls))
+	for i, u := range urls {
+		chunks[i] = radirec.Chunk{URL: u, Duration: m.chunkDuration()}
+	}
+	return chunks, nil
+}
+
+func (m *MockProvider) defaultChunks(uri string) []string {
+	n := m.Chunks
+	if n <= 0 {
+		n = 2
+	}
+	chunks := make([]string, n)
+	for i := range chunks {
+		chunks[i] = fmt.Sprintf("%s/chunk%d.aac", uri, i)
+	}
+	return chunks
+}
+
+func (m *MockProvider) chunkDuration() time.Duration {
+	if m.ChunkDuration <= 0 {
+		return 5 * time.Second
+	}
+	return m.ChunkDuration
+}
+
+// Download returns ChunkDuration of synthetic AAC audio by default.
+func (m *MockProvider) Download(ctx context.Context, chunkURL string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	m.downloads = append(m.downloads, chunkURL)
+	m.mu.Unlock()
+	if m.DownloadFn != nil {
+		return m.DownloadFn(ctx, chunkURL)
+	}
+	return io.NopCloser(bytes.NewReader(SyntheticAAC(m.chunkDuration()))), nil
+}
+
+// Playlists returns the broadcasts resolved so far, as "stationID|YYYYMMDDhhmmss".
+func (m *MockProvider) Playlists() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.playlists...)
+}
+
+// Downloads returns the chunk URLs downloaded so far, in request order.
+func (m *MockProvider) Downloads() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.downloads...)
+}
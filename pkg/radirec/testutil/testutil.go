@@ -0,0 +1,65 @@
+// Package testutil provides fakes and fixtures for testing programs that embed radirec:
+// a mock Provider, a fake Clock, M3U8 playlist and program guide builders, and synthetic AAC audio.
+//
+// A typical integration test records into a temporary directory without touching the network:
+//
+//	provider := &testutil.MockProvider{}
+//	err := radirec.RunOnce(ctx, radirec.Options{
+//		Schedule:    entries,
+//		OutputDir:   t.TempDir(),
+//		Clock:       testutil.NewFakeClock(now),
+//		NewProvider: provider.Factory(),
+//		FetchGuide:  testutil.GuideFetcher(nil),
+//	})
+package testutil
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a radirec.Clock whose time only moves when told to.
+// After advances the clock by the requested duration and fires immediately,
+// so daemon loops run through their waits without sleeping.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	afters int
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After advances the clock by d and returns a channel that already holds the new time.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.afters++
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Afters returns how many times After was called.
+func (c *FakeClock) Afters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.afters
+}
@@ -0,0 +1,106 @@
+package testutil_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"radikoRecScheduler/pkg/radirec"
+	"radikoRecScheduler/pkg/radirec/testutil"
+)
+
+var jst = time.FixedZone("Asia/Tokyo", 9*60*60)
+
+func TestRunOnceWithMockProvider(t *testing.T) {
+	outputDir := t.TempDir()
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, jst) // Tuesday
+	start := time.Date(2026, time.January, 12, 10, 0, 0, 0, jst)
+
+	provider := &testutil.MockProvider{Chunks: 3}
+	err := radirec.RunOnce(context.Background(), radirec.Options{
+		Schedule:    []radirec.ScheduleEntry{{ProgramName: "Schedule Name", DayOfWeek: "月", StartTime: "100000", StationID: "TBS"}},
+		OutputDir:   outputDir,
+		Logger:      log.New(io.Discard, "", 0),
+		Clock:       testutil.NewFakeClock(now),
+		NewProvider: provider.Factory(),
+		FetchGuide: testutil.GuideFetcher(map[string][]byte{
+			"TBS": testutil.GuideXML("TBS", testutil.Program{Start: start, End: start.Add(2 * time.Hour), Title: "Guide Title"}),
+		}),
+		DisableProgressBar: true,
+	})
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+
+	if got := provider.Playlists(); len(got) != 1 || got[0] != "TBS|20260112100000" {
+		t.Errorf("unexpected playlists %v", got)
+	}
+	if got := len(provider.Downloads()); got != 3 {
+		t.Errorf("expected 3 chunk downloads, got %d", got)
+	}
+	data, err := os.ReadFile(filepath.Join(outputDir, "20260112100000-TBS-Guide Title.aac"))
+	if err != nil {
+		t.Fatalf("recording not found: %v", err)
+	}
+	if want := bytes.Repeat(testutil.SyntheticAAC(5*time.Second), 3); !bytes.Equal(data, want) {
+		t.Errorf("expected the concatenated chunks (%d bytes), got %d bytes", len(want), len(data))
+	}
+}
+
+func TestSyntheticAAC(t *testing.T) {
+	data := testutil.SyntheticAAC(time.Second)
+	frames := 0
+	for len(data) > 0 {
+		if len(data) < 7 || data[0] != 0xFF || data[1]&0xF6 != 0xF0 {
+			t.Fatalf("frame %d: missing ADTS sync word", frames)
+		}
+		length := int(data[3]&3)<<11 | int(data[4])<<3 | int(data[5])>>5
+		if length < 7 || length > len(data) {
+			t.Fatalf("frame %d: bad frame length %d", frames, length)
+		}
+		data = data[length:]
+		frames++
+	}
+	if want := 47; frames != want { // ceil(1s / 21.33ms)
+		t.Errorf("expected %d frames, got %d", want, frames)
+	}
+}
+
+func TestPlaylists(t *testing.T) {
+	master, err := radirec.ParsePlaylist(strings.NewReader(testutil.MasterPlaylist("https://example.com/media.m3u8")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !master.Master() || len(master.Variants) != 1 || master.Variants[0] != "https://example.com/media.m3u8" {
+		t.Errorf("unexpected master playlist %+v", master)
+	}
+
+	media, err := radirec.ParsePlaylist(strings.NewReader(testutil.MediaPlaylist([]string{"a.aac", "b.aac"}, 5*time.Second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if media.Master() || len(media.Segments) != 2 || media.Segments[1].URI != "b.aac" || media.Segments[1].Duration != 5*time.Second || !media.Closed {
+		t.Errorf("unexpected media playlist %+v", media)
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	now := time.Date(2026, time.January, 13, 10, 0, 0, 0, jst)
+	clock := testutil.NewFakeClock(now)
+	if got := <-clock.After(time.Hour); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("After fired at %v", got)
+	}
+	clock.Advance(time.Minute)
+	if got := clock.Now(); !got.Equal(now.Add(time.Hour + time.Minute)) {
+		t.Errorf("Now returned %v", got)
+	}
+	if clock.Afters() != 1 {
+		t.Errorf("expected 1 After call, got %d", clock.Afters())
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// runPreviewCommand implements the "preview" subcommand, which lists the coming week's recordings.
+func runPreviewCommand(args []string) error {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	scheduleFilePath := fs.String("file", defaultSchedulePath(currentProfile), "Path to the schedule file (JSON, YAML or TOML).")
+	send := fs.Bool("send", false, "Send the preview through the configured notifier instead of printing it.")
+	fs.Parse(args)
+
+	config := loadConfig(currentProfile)
+	entries, _ := resolveSchedule(currentProfile, config, *scheduleFilePath, flagWasSet(fs, "file"))
+
+	if *send {
+		notifier := internal.NewNotifier(config.Notifications)
+		if notifier == nil {
+			return fmt.Errorf("no notifier configured in config.json (notifications.webhook_url)")
+		}
+		return internal.SendWeeklyPreview(context.Background(), internal.Options{Schedule: entries, Notifier: notifier, Location: config.ScheduleLocation(), Output: config.Output()})
+	}
+
+	now := time.Now().In(internal.JST)
+	subject, body := internal.FormatWeeklyPreview(internal.BuildWeeklyPreview(context.Background(), entries, now, config.ScheduleLocation(), internal.GetProgramGuide), now)
+	fmt.Fprintln(os.Stdout, subject)
+	fmt.Fprintln(os.Stdout)
+	fmt.Fprint(os.Stdout, body)
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+
+	"radikoRecScheduler/internal"
+)
+
+// globalFlags are the flags that may precede a subcommand.
+type globalFlags struct {
+	config  string
+	profile string
+}
+
+// takeGlobalFlags returns the -config and -profile flags (or --config, --profile) at the start of
+// args and the arguments after them.
+func takeGlobalFlags(args []string) (globalFlags, []string) {
+	var global globalFlags
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(args[0], "-"), "-"), "=")
+		var target *string
+		switch name {
+		case "config":
+			target = &global.config
+		case "profile":
+			target = &global.profile
+		default:
+			return global, args
+		}
+		if !hasValue {
+			if len(args) < 2 {
+				log.Fatalf("flag needs an argument: -%s", name)
+			}
+			value, args = args[1], args[1:]
+		}
+		*target, args = value, args[1:]
+	}
+	return global, args
+}
+
+// currentProfile is the profile selected for a command, see useProfile. The daemons of several
+// profiles are each given their own instead.
+var currentProfile internal.Profile
+
+// selectProfiles returns the profiles selected by the -profile flag, or RADIREC_PROFILE if it is
+// empty: a comma-separated list, or "all" for every profile.
+func selectProfiles(flagValue string) []internal.Profile {
+	if flagValue == "" {
+		flagValue = os.Getenv(internal.ProfileEnv)
+	}
+	if flagValue == "all" {
+		profiles, err := internal.ListProfiles()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(profiles) == 0 {
+			log.Fatal("There are no profiles; create one by running a command with -profile NAME")
+		}
+		return profiles
+	}
+	var profiles []internal.Profile
+	for _, name := range strings.Split(flagValue, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		profile, err := internal.ParseProfile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
+
+// useProfile selects the single profile given by the -profile flag or RADIREC_PROFILE as
+// currentProfile, exiting if several are given.
+func useProfile(flagValue string) {
+	profiles := selectProfiles(flagValue)
+	if len(profiles) > 1 {
+		log.Fatal("Only the daemon can run several profiles; select one with -profile NAME")
+	}
+	if len(profiles) == 1 {
+		currentProfile = profiles[0]
+	}
+}
+
+// sharedSettings returns the settings of config that apply to the whole process rather than to
+// the Options of its profile: the transport of ConfigureNetwork and ConfigureFileNames.
+func sharedSettings(config *internal.Config) any {
+	network := config.Network
+	network.RequestTimeoutSeconds, network.Headers = 0, nil
+	return []any{network, config.FileNames}
+}
+
+// runProfileDaemons runs the daemons of several profiles in this process, each with the Options
+// of its own config.json and schedule, until interrupted. The log lines of each are prefixed with
+// its profile name.
+func runProfileDaemons(profiles []internal.Profile, quiet, noSpinner bool) error {
+	if os.Getenv(internal.ConfigPathEnv) != "" || os.Getenv(internal.SchedulePathEnv) != "" || flag.Lookup("config").Value.String() != "" || flagWasSet(flag.CommandLine, "file") {
+		return errors.New("several profiles cannot share one -config or -file; each uses its own")
+	}
+	configs := make([]*internal.Config, len(profiles))
+	options := make([]internal.Options, len(profiles))
+	sources := make([]scheduleSource, len(profiles))
+	for i, profile := range profiles {
+		// Messages about loading the config and schedule name the profile as well.
+		log.SetPrefix("[" + string(profile) + "] ")
+		configs[i] = loadConfig(profile)
+		if !reflect.DeepEqual(sharedSettings(configs[i]), sharedSettings(configs[0])) {
+			log.SetPrefix("")
+			return fmt.Errorf("profiles %s and %s cannot run together: the proxy, user_agent, ca_file, insecure_skip_verify and file_names settings apply to the whole process and must be the same", profiles[0], profile)
+		}
+		var entries []internal.ScheduleEntry
+		entries, sources[i] = resolveSchedule(profile, configs[i], defaultSchedulePath(profile), false)
+		options[i] = newOptions(profile, configs[i], entries, quiet, noSpinner)
+		options[i].Logger = log.New(log.Writer(), log.Prefix(), log.Flags())
+	}
+	log.SetPrefix("")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("Running the daemons of profiles %s. Press Ctrl+C to stop.", joinProfiles(profiles))
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(profiles))
+	for i, profile := range profiles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := runDaemon(ctx, profile, configs[i], options[i], sources[i]); err != nil {
+				// A daemon that cannot start stops the others, as a single one would exit.
+				errs[i] = fmt.Errorf("profile %s: %w", profile, err)
+				stop()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// joinProfiles returns the names of profiles separated by commas.
+func joinProfiles(profiles []internal.Profile) string {
+	names := make([]string, len(profiles))
+	for i, profile := range profiles {
+		names[i] = string(profile)
+	}
+	return strings.Join(names, ", ")
+}
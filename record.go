@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// runRecordCommand implements the "record" subcommand, which records an arbitrary timeshift window
+// once, or without -from and -to the most recent broadcasts of the schedule entries selected by
+// -entry (or its alias -only), -station and -weekday.
+func runRecordCommand(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	station := fs.String("station", "", "Station ID, e.g. TBS. Without -from and -to, comma-separated station IDs of the schedule entries to record.")
+	entry := fs.String("entry", "", "Record the most recent broadcast of the schedule entries with this program_name (ignoring case).")
+	fs.StringVar(entry, "only", "", "Alias of -entry.")
+	weekday := fs.String("weekday", "", "Record the most recent broadcast of the schedule entries airing on these comma-separated weekdays, e.g. Mon,Fri or 月.")
+	scheduleFilePath := fs.String("file", defaultSchedulePath(currentProfile), "Path to the schedule file (JSON, YAML or TOML) for -entry, -station and -weekday.")
+	fromFlag := fs.String("from", "", "Start of the window in JST, as YYYYMMDDhhmm.")
+	toFlag := fs.String("to", "", "End of the window in JST, as YYYYMMDDhhmm.")
+	title := fs.String("title", "", "Title used for the file name when the guide has no program starting at -from.")
+	quiet := fs.Bool("quiet", false, "Suppress progress output and print one summary line.")
+	noSpinner := fs.Bool("no-spinner", false, "Disable the progress bar; log progress periodically instead.")
+	overwrite := fs.Bool("overwrite", false, "Record the window again even if it was recorded before, replacing the recording.")
+	fs.Parse(args)
+
+	if *fromFlag == "" && *toFlag == "" && (*entry != "" || *station != "" || *weekday != "") {
+		if *title != "" {
+			return fmt.Errorf("-title cannot be used without -from and -to")
+		}
+		filter := internal.ScheduleFilter{ProgramName: *entry}
+		if *station != "" {
+			filter.Stations = strings.Split(*station, ",")
+		}
+		if *weekday != "" {
+			for _, name := range strings.Split(*weekday, ",") {
+				day, ok := internal.ParseWeekday(name)
+				if !ok {
+					return fmt.Errorf("invalid -weekday '%s'", name)
+				}
+				filter.Weekdays = append(filter.Weekdays, day)
+			}
+		}
+		return recordEntries(filter, *scheduleFilePath, flagWasSet(fs, "file"), *quiet, *noSpinner, *overwrite)
+	}
+	if *entry != "" || *weekday != "" {
+		return fmt.Errorf("-entry and -weekday cannot be used with -from and -to")
+	}
+	if *station == "" || *fromFlag == "" || *toFlag == "" {
+		return fmt.Errorf("usage: %s record -station ID -from YYYYMMDDhhmm -to YYYYMMDDhhmm [-title TITLE], or %s record [-entry NAME] [-station ID,...] [-weekday DAY,...]", os.Args[0], os.Args[0])
+	}
+	from, err := time.ParseInLocation("200601021504", *fromFlag, internal.JST)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	to, err := time.ParseInLocation("200601021504", *toFlag, internal.JST)
+	if err != nil {
+		return fmt.Errorf("invalid -to: %w", err)
+	}
+	now := time.Now()
+	if to.After(now) {
+		return fmt.Errorf("the window ends at %s, which has not passed yet", to.Format("2006-01-02 15:04"))
+	}
+	if from.Before(now.Add(-internal.TimeshiftWindow)) {
+		return fmt.Errorf("the window starts at %s, which is outside radiko's 7-day timeshift window", from.Format("2006-01-02 15:04"))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := newOptions(currentProfile, loadConfig(currentProfile), nil, *quiet, *noSpinner)
+	opts.Overwrite = *overwrite
+	_, err = internal.RecordRange(ctx, opts, *station, from, to, *title)
+	return err
+}
+
+// recordEntries records the most recent broadcast of the schedule entries filter selects, like a
+// run of the whole schedule would, e.g. to retry a single program that failed.
+func recordEntries(filter internal.ScheduleFilter, scheduleFilePath string, explicit, quiet, noSpinner, overwrite bool) error {
+	config := loadConfig(currentProfile)
+	schedule, _ := resolveSchedule(currentProfile, config, scheduleFilePath, explicit)
+	entries := internal.FilterSchedule(schedule, filter)
+	if len(entries) == 0 {
+		return fmt.Errorf("no schedule entry matches -entry, -station and -weekday")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := newOptions(currentProfile, config, entries, quiet, noSpinner)
+	opts.Overwrite = overwrite
+	opts.Subscriptions = nil
+	summary := &internal.RunSummary{}
+	opts.Summary = summary
+	err := internal.RunOnce(ctx, opts)
+	printRunSummary(os.Stdout, summary, quiet, opts.Output)
+	return err
+}
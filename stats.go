@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"radikoRecScheduler/internal"
+)
+
+// runStatsCommand implements the "stats" subcommand, which prints per-program totals of the
+// recording history.
+func runStatsCommand(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	sortBy := fs.String("sort", "name", "Order of the programs: name, or last for the longest without a recording first.")
+	fs.Parse(args)
+	if *sortBy != "name" && *sortBy != "last" {
+		return fmt.Errorf("invalid -sort '%s': must be name or last", *sortBy)
+	}
+
+	source, err := openJobSource()
+	if err != nil {
+		return err
+	}
+	records, err := source.Jobs(context.Background(), 0)
+	if err != nil {
+		return err
+	}
+	stats := internal.ComputeStats(records)
+	if *sortBy == "last" {
+		sort.SliceStable(stats, func(i, j int) bool { return stats[i].LastSuccess.Before(stats[j].LastSuccess) })
+	}
+	return printStats(os.Stdout, stats)
+}
+
+func printStats(w io.Writer, stats []internal.ProgramStats) error {
+	if len(stats) == 0 {
+		fmt.Fprintln(w, "No recordings in the history.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROGRAM\tEPISODES\tHOURS\tDISK\tLAST SUCCESS\tFAILURES\tSTREAK")
+	for _, s := range stats {
+		last := "never"
+		if !s.LastSuccess.IsZero() {
+			last = s.LastSuccess.In(internal.JST).Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%s\t%s\t%d\t%d\n", s.ProgramName, s.Episodes, s.Duration.Hours(), formatMB(s.DiskUsage), last, s.Failures, s.FailureStreak)
+	}
+	return tw.Flush()
+}
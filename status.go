@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// discoverDaemon returns a client for the running daemon, or nil if none is running.
+func discoverDaemon() *internal.DaemonClient {
+	dataDir, err := currentProfile.DataDir()
+	if err != nil {
+		log.Printf("WARNING: Failed to get data directory: %v", err)
+		return nil
+	}
+	client, err := internal.DiscoverDaemon(context.Background(), dataDir)
+	if err != nil {
+		log.Printf("WARNING: %v", err)
+		return nil
+	}
+	return client
+}
+
+// runStatusCommand implements the "status" subcommand, which reports on the running daemon.
+func runStatusCommand(args []string) error {
+	daemon := discoverDaemon()
+	if daemon == nil {
+		fmt.Println("No daemon is running.")
+		return nil
+	}
+	status, err := daemon.Status(context.Background())
+	if err != nil {
+		return err
+	}
+	return printStatus(os.Stdout, daemon.Info, status)
+}
+
+func printStatus(w io.Writer, info internal.DaemonInfo, status internal.DaemonStatus) error {
+	fmt.Fprintf(w, "Daemon:    running (pid %d, %s %s)\n", status.PID, info.Network, info.Address)
+	fmt.Fprintf(w, "Started:   %s\n", status.StartedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "Schedule:  %d entries\n", len(status.Schedule))
+	switch {
+	case status.PassRunning:
+		fmt.Fprintf(w, "Pass:      running\n")
+	case !status.NextPass.IsZero():
+		fmt.Fprintf(w, "Next pass: %s\n", status.NextPass.Format("2006-01-02 15:04:05"))
+	}
+	for _, name := range status.RunningJobs {
+		fmt.Fprintf(w, "Recording: %s\n", name)
+	}
+	return nil
+}
+
+// runScheduleCommand implements the "schedule" subcommand, which lists the schedule in use:
+// the running daemon's (including reloads), or the one in the config directory.
+func runScheduleCommand(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "schema":
+			_, err := os.Stdout.Write(internal.ScheduleSchema())
+			return err
+		case "import-favorites":
+			return runImportFavorites(args[1:])
+		case "export-ics":
+			return runExportICS(args[1:])
+		case "import":
+			return runImportSchedule(args[1:])
+		case "calendar":
+			return runScheduleCalendar(args[1:])
+		}
+		return fmt.Errorf("usage: %s schedule [schema | import-favorites [-dry-run] | export-ics [-o FILE] | import [-dry-run] FILE | calendar]", os.Args[0])
+	}
+
+	config := loadConfig(currentProfile)
+	var entries []internal.ScheduleEntry
+	if daemon := discoverDaemon(); daemon != nil {
+		var err error
+		if entries, err = daemon.Schedule(context.Background()); err != nil {
+			return err
+		}
+	} else {
+		entries, _ = resolveSchedule(currentProfile, config, defaultSchedulePath(currentProfile), false)
+	}
+	return printSchedule(os.Stdout, entries, time.Now().In(internal.JST), config.ScheduleLocation())
+}
+
+func printSchedule(w io.Writer, entries []internal.ScheduleEntry, now time.Time, loc *time.Location) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROGRAM\tDAY\tTIME\tSTATION\tLAST BROADCAST\tNOTE")
+	for _, entry := range entries {
+		last := "invalid"
+		if !entry.IsEnabled() {
+			last = "disabled"
+		} else if t, err := internal.CalculateRecentPastRunTime(entry, now, loc); err == nil {
+			last = t.Format("2006-01-02 15:04")
+		} else if errors.Is(err, internal.ErrNotAired) {
+			last = "not aired yet"
+		}
+		day := entry.DayOfWeek
+		if entry.OneShot() || entry.DayOfWeek == "" {
+			day = entry.Date
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.ProgramName, day, entry.StartTime, entry.StationID, last, entry.Note)
+	}
+	return tw.Flush()
+}
+
+// runScheduleCalendar implements "schedule calendar": it prints the coming week as a grid of
+// days and hours, showing when the entries air and where they overlap.
+func runScheduleCalendar(args []string) error {
+	fs := flag.NewFlagSet("schedule calendar", flag.ExitOnError)
+	scheduleFilePath := fs.String("file", defaultSchedulePath(currentProfile), "Path to the schedule file (JSON, YAML or TOML).")
+	fs.Parse(args)
+
+	config := loadConfig(currentProfile)
+	entries, _ := resolveSchedule(currentProfile, config, *scheduleFilePath, flagWasSet(fs, "file"))
+
+	fetch := internal.GetProgramGuide
+	if caches, err := openCaches(currentProfile, config); err == nil {
+		fetch = internal.CachedGuideFetcher(caches.Guide, internal.SystemClock, internal.GetProgramGuide)
+	}
+	now := time.Now().In(internal.JST)
+	items := internal.BuildWeeklyPreview(context.Background(), entries, now, config.ScheduleLocation(), fetch)
+	for _, item := range items {
+		if item.Err != nil {
+			log.Printf("WARNING: %s: %v", item.Entry.ProgramName, item.Err)
+		}
+	}
+	return printCalendar(os.Stdout, internal.BuildScheduleCalendar(items, now))
+}
+
+func printCalendar(w io.Writer, cal internal.ScheduleCalendar) error {
+	fmt.Fprintf(w, "Week of %s (JST). # recording, 2-9 recordings in the hour, ! overlapping recordings\n\n", cal.Start.Format("2006-01-02"))
+	fmt.Fprint(w, "          ")
+	for hour := 0; hour < 24; hour++ {
+		fmt.Fprintf(w, "%3d", hour)
+	}
+	fmt.Fprintln(w)
+	for day := 0; day < 7; day++ {
+		fmt.Fprint(w, cal.Start.AddDate(0, 0, day).Format("Mon 01-02"), " ")
+		for hour := 0; hour < 24; hour++ {
+			count, overlap := cal.Cell(day, hour)
+			cell := "."
+			switch {
+			case overlap:
+				cell = "!"
+			case count == 1:
+				cell = "#"
+			case count > 9:
+				cell = "+"
+			case count > 1:
+				cell = fmt.Sprint(count)
+			}
+			fmt.Fprintf(w, "%3s", cell)
+		}
+		count, duration := cal.DayTotal(day)
+		fmt.Fprintf(w, "   %d recording(s), %.1fh\n", count, duration.Hours())
+	}
+
+	fmt.Fprintln(w)
+	if len(cal.Overlaps) == 0 {
+		fmt.Fprintln(w, "No overlapping recordings.")
+		return nil
+	}
+	fmt.Fprintln(w, "Overlapping recordings:")
+	for _, o := range cal.Overlaps {
+		a, b := cal.Slots[o.A], cal.Slots[o.B]
+		fmt.Fprintf(w, "  %s-%s  %s (%s) / %s (%s)\n", o.Start.Format("Mon 01-02 15:04"), o.End.Format("15:04"),
+			a.Title, a.Entry.StationID, b.Title, b.Entry.StationID)
+	}
+	return nil
+}
+
+// runImportFavorites implements "schedule import-favorites": it adds the weekly slots of the
+// programs favorited in the radiko premium account to the schedule.
+func runImportFavorites(args []string) error {
+	fs := flag.NewFlagSet("schedule import-favorites", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "List the entries that would be added without changing the schedule.")
+	fs.Parse(args)
+
+	config := loadConfig(currentProfile)
+	ctx := context.Background()
+	favorites, err := internal.FetchRadikoFavorites(ctx, config.Radiko)
+	if err != nil {
+		return err
+	}
+	if len(favorites) == 0 {
+		fmt.Println("No favorite programs found in the radiko account.")
+		return nil
+	}
+
+	fetch := internal.GetProgramGuide
+	if caches, err := openCaches(currentProfile, config); err == nil {
+		fetch = internal.CachedGuideFetcher(caches.Guide, internal.SystemClock, internal.GetProgramGuide)
+	}
+	entries, missing, errs := internal.FavoriteScheduleEntries(ctx, favorites, config.ScheduleLocation(), fetch)
+	for _, err := range errs {
+		log.Printf("WARNING: Failed to read guide: %v", err)
+	}
+	for _, fav := range missing {
+		log.Printf("WARNING: %s (%s) is not in this week's guide; add it by hand or import again later.", fav.Title, fav.StationID)
+	}
+
+	return addScheduleEntries(config, entries, *dryRun)
+}
+
+// addScheduleEntries adds entries to the schedule in use, printing the outcome of each,
+// or only lists them when dryRun is set.
+func addScheduleEntries(config *internal.Config, entries []internal.ScheduleEntry, dryRun bool) error {
+	for _, entry := range entries {
+		if dryRun {
+			fmt.Printf("Would add %s (%s %s, %s).\n", entry.ProgramName, entry.DayOfWeek, entry.StartTime, entry.StationID)
+			continue
+		}
+		message, err := addScheduleEntry(config, entry)
+		if err != nil {
+			return err
+		}
+		fmt.Println(message)
+	}
+	return nil
+}
+
+// runImportSchedule implements "schedule import", adding the entries of a CSV or iCalendar file.
+func runImportSchedule(args []string) error {
+	fs := flag.NewFlagSet("schedule import", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "List the entries that would be added without changing the schedule.")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: %s schedule import [-dry-run] FILE.csv|FILE.ics", os.Args[0])
+	}
+
+	config := loadConfig(currentProfile)
+	entries, err := internal.ImportScheduleFile(fs.Arg(0), config.ScheduleLocation())
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No entries found in %s.\n", fs.Arg(0))
+		return nil
+	}
+	return addScheduleEntries(config, entries, *dryRun)
+}
+
+// runExportICS implements "schedule export-ics", writing the schedule as an iCalendar file
+// with a weekly event per entry.
+func runExportICS(args []string) error {
+	fs := flag.NewFlagSet("schedule export-ics", flag.ExitOnError)
+	scheduleFilePath := fs.String("file", defaultSchedulePath(currentProfile), "Path to the schedule file (JSON, YAML or TOML).")
+	out := fs.String("o", "", "Write the calendar to this file instead of standard output.")
+	fs.Parse(args)
+
+	config := loadConfig(currentProfile)
+	entries, _ := resolveSchedule(currentProfile, config, *scheduleFilePath, flagWasSet(fs, "file"))
+
+	fetch := internal.GetProgramGuide
+	if caches, err := openCaches(currentProfile, config); err == nil {
+		fetch = internal.CachedGuideFetcher(caches.Guide, internal.SystemClock, internal.GetProgramGuide)
+	}
+	now := time.Now().In(internal.JST)
+	items := internal.BuildUpcoming(context.Background(), entries, now, config.ScheduleLocation(), fetch)
+	for _, item := range items {
+		if item.Err != nil {
+			log.Printf("WARNING: %s: %v", item.Entry.ProgramName, item.Err)
+		}
+	}
+	calendar := internal.FormatScheduleICS(items, now)
+	if *out == "" {
+		_, err := os.Stdout.Write(calendar)
+		return err
+	}
+	if err := os.WriteFile(*out, calendar, 0644); err != nil {
+		return fmt.Errorf("failed to write calendar '%s': %w", *out, err)
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"radikoRecScheduler/internal"
+)
+
+// runTUICommand implements the "tui" subcommand: an interactive view of the schedule, with the
+// next and last run of every entry, and a guide search to add programs, for use over SSH.
+func runTUICommand(args []string) error {
+	config := loadConfig(currentProfile)
+	_, source := resolveSchedule(currentProfile, config, defaultSchedulePath(currentProfile), false)
+	m := &tuiModel{now: time.Now, config: config, source: source}
+	if err := m.reload(); err != nil {
+		return err
+	}
+
+	// Log lines would tear the full-screen view; they are shown after it closes instead.
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		os.Stderr.Write(logs.Bytes())
+	}()
+	_, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
+
+// Views of the TUI.
+const (
+	viewSchedule = iota
+	viewSearch   // Typing a keyword.
+	viewResults  // Browsing guide search results.
+)
+
+var (
+	tuiTitleStyle    = lipgloss.NewStyle().Bold(true)
+	tuiHeaderStyle   = lipgloss.NewStyle().Faint(true)
+	tuiSelectedStyle = lipgloss.NewStyle().Reverse(true)
+	tuiDisabledStyle = lipgloss.NewStyle().Faint(true)
+	tuiFailedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	tuiStatusStyle   = lipgloss.NewStyle().Italic(true)
+)
+
+// tuiRow is an entry of the schedule with its next and last run.
+type tuiRow struct {
+	entry internal.ScheduleEntry
+	next  string
+	last  *internal.HistoryRecord // Latest attempt in the history, if any.
+}
+
+type tuiModel struct {
+	now func() time.Time
+
+	config *internal.Config
+	source scheduleSource
+	rows   []tuiRow
+
+	view    int
+	cursor  int
+	keyword string
+	results []internal.GuideMatch
+	picked  int  // Selected result.
+	busy    bool // A search is running.
+	status  string
+	width   int
+	height  int
+}
+
+// searchDoneMsg delivers the results of a guide search.
+type searchDoneMsg struct {
+	matches []internal.GuideMatch
+	errs    []error
+}
+
+// reload reads the config, the schedule in use and the history again.
+func (m *tuiModel) reload() error {
+	configPath, err := currentProfile.ConfigPath()
+	if err != nil {
+		return fmt.Errorf("failed to get default config path: %w", err)
+	}
+	config, err := currentProfile.LoadEffectiveConfig(configPath, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	entries, err := m.source.load()
+	if err != nil {
+		return err
+	}
+	m.config = config
+
+	latest := make(map[string]internal.HistoryRecord)
+	if source, err := openJobSource(); err != nil {
+		m.status = fmt.Sprintf("Failed to open the history: %v", err)
+	} else if records, err := source.Jobs(context.Background(), 0); err != nil {
+		m.status = fmt.Sprintf("Failed to read the history: %v", err)
+	} else {
+		for _, rec := range records {
+			latest[rec.ProgramName+"|"+rec.StationID] = rec
+		}
+	}
+
+	now := m.now().In(internal.JST)
+	m.rows = m.rows[:0]
+	for _, entry := range entries {
+		row := tuiRow{entry: entry, next: "invalid"}
+		if !entry.IsEnabled() {
+			row.next = "disabled"
+		} else if t, err := internal.CalculateNextRunTime(entry, now, config.ScheduleLocation()); err == nil {
+			row.next = t.Format("01-02 (Mon) 15:04")
+		}
+		if rec, ok := latest[entry.ProgramName+"|"+entry.StationID]; ok {
+			row.last = &rec
+		}
+		m.rows = append(m.rows, row)
+	}
+	if m.cursor >= len(m.rows) {
+		m.cursor = max(len(m.rows)-1, 0)
+	}
+	return nil
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case searchDoneMsg:
+		m.busy = false
+		m.results, m.picked = msg.matches, 0
+		m.view = viewResults
+		m.status = fmt.Sprintf("%d broadcasts match %q.", len(msg.matches), m.keyword)
+		if len(msg.errs) > 0 {
+			m.status += fmt.Sprintf(" %d station(s) could not be searched: %v", len(msg.errs), msg.errs[0])
+		}
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		switch m.view {
+		case viewSearch:
+			return m.updateSearch(msg)
+		case viewResults:
+			return m.updateResults(msg)
+		default:
+			return m.updateSchedule(msg)
+		}
+	}
+	return m, nil
+}
+
+func (m *tuiModel) updateSchedule(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "esc":
+		return m, tea.Quit
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, max(len(m.rows)-1, 0))
+	case "r":
+		m.status = "Reloaded."
+		if err := m.reload(); err != nil {
+			m.status = fmt.Sprintf("Failed to reload: %v", err)
+		}
+	case "/":
+		m.view, m.keyword, m.status = viewSearch, "", ""
+	}
+	return m, nil
+}
+
+func (m *tuiModel) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.view = viewSchedule
+	case tea.KeyEnter:
+		if strings.TrimSpace(m.keyword) == "" || m.busy {
+			return m, nil
+		}
+		m.busy = true
+		m.status = fmt.Sprintf("Searching the guide for %q...", m.keyword)
+		return m, searchGuideCmd(m.config, strings.TrimSpace(m.keyword))
+	case tea.KeyBackspace:
+		if r := []rune(m.keyword); len(r) > 0 {
+			m.keyword = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.keyword += string(msg.Runes)
+	}
+	return m, nil
+}
+
+func (m *tuiModel) updateResults(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q":
+		m.view, m.status = viewSchedule, ""
+	case "/":
+		m.view = viewSearch
+	case "up", "k":
+		m.picked = max(m.picked-1, 0)
+	case "down", "j":
+		m.picked = min(m.picked+1, max(len(m.results)-1, 0))
+	case "a", "enter":
+		if len(m.results) == 0 {
+			return m, nil
+		}
+		message, err := scheduleGuideMatch(m.config, m.results[m.picked])
+		if err != nil {
+			m.status = fmt.Sprintf("Failed to add: %v", err)
+			return m, nil
+		}
+		m.status = message
+		if err := m.reload(); err != nil {
+			m.status = fmt.Sprintf("%s Failed to reload: %v", message, err)
+		}
+	}
+	return m, nil
+}
+
+// searchGuideCmd searches this week's guide of every station for keyword.
+func searchGuideCmd(config *internal.Config, keyword string) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		stationIDs, err := internal.GetAllStationIDs(ctx)
+		if err != nil {
+			return searchDoneMsg{errs: []error{err}}
+		}
+		fetch := internal.GetProgramGuide
+		if caches, err := openCaches(currentProfile, config); err == nil {
+			fetch = internal.CachedGuideFetcher(caches.Guide, internal.SystemClock, internal.GetProgramGuide)
+		}
+		matches, errs := internal.SearchGuides(ctx, stationIDs, keyword, fetch)
+		return searchDoneMsg{matches: matches, errs: errs}
+	}
+}
+
+func (m *tuiModel) View() string {
+	var b strings.Builder
+	switch m.view {
+	case viewSearch, viewResults:
+		b.WriteString(tuiTitleStyle.Render("Guide search") + "\n\n")
+		prompt := "Keyword: " + m.keyword
+		if m.view == viewSearch {
+			prompt += "█"
+		}
+		b.WriteString(prompt + "\n\n")
+		if m.view == viewResults {
+			m.viewResults(&b)
+		}
+	default:
+		b.WriteString(tuiTitleStyle.Render(fmt.Sprintf("Schedule (%d entries)", len(m.rows))) + "\n\n")
+		m.viewSchedule(&b)
+	}
+
+	if m.status != "" {
+		b.WriteString("\n" + tuiStatusStyle.Render(m.status) + "\n")
+	}
+	b.WriteString("\n" + tuiHeaderStyle.Render(m.help()) + "\n")
+	return b.String()
+}
+
+func (m *tuiModel) viewSchedule(b *strings.Builder) {
+	if len(m.rows) == 0 {
+		b.WriteString("The schedule is empty. Press / to search the guide.\n")
+		return
+	}
+	b.WriteString(tuiHeaderStyle.Render(fmt.Sprintf("  %s %-8s %-8s %-19s %s", pad("PROGRAM", 32), "STATION", "SLOT", "NEXT RUN", "LAST RUN")) + "\n")
+	for i, row := range visibleWindow(m.rows, m.cursor, m.listHeight()) {
+		e := row.entry
+		day := e.DayOfWeek
+		if e.OneShot() || e.DayOfWeek == "" {
+			day = e.Date
+		}
+		line := fmt.Sprintf("  %s %-8s %-8s %-19s %s", pad(e.ProgramName, 32), e.StationID, day+" "+formatSlot(e.StartTime), row.next, lastRun(row.last))
+		if e.Note != "" {
+			line += "  # " + e.Note
+		}
+		switch {
+		case i == m.cursor-windowStart(len(m.rows), m.cursor, m.listHeight()):
+			line = tuiSelectedStyle.Render(line)
+		case !e.IsEnabled():
+			line = tuiDisabledStyle.Render(line)
+		case row.last != nil && row.last.Status != internal.StatusSuccess:
+			line = tuiFailedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+}
+
+func (m *tuiModel) viewResults(b *strings.Builder) {
+	if len(m.results) == 0 {
+		b.WriteString("No matching broadcasts found.\n")
+		return
+	}
+	b.WriteString(tuiHeaderStyle.Render(fmt.Sprintf("  %-22s %-8s %s", "BROADCAST", "STATION", "TITLE")) + "\n")
+	for i, match := range visibleWindow(m.results, m.picked, m.listHeight()) {
+		when := match.Prog.Ft
+		if start, err := match.Start(); err == nil {
+			when = start.Format("2006-01-02 (Mon) 15:04")
+		}
+		line := fmt.Sprintf("  %-22s %-8s %s", when, match.StationID, match.Prog.Title)
+		if match.Prog.Pfm != "" {
+			line += " / " + match.Prog.Pfm
+		}
+		if i == m.picked-windowStart(len(m.results), m.picked, m.listHeight()) {
+			line = tuiSelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+}
+
+func (m *tuiModel) help() string {
+	switch m.view {
+	case viewSearch:
+		return "enter: search  esc: back"
+	case viewResults:
+		return "↑/↓: move  a/enter: add to schedule  /: new search  esc: back"
+	}
+	return "↑/↓: move  /: search the guide  r: reload  q: quit"
+}
+
+// listHeight is the number of list rows that fit on the screen, next to the title, header and help.
+func (m *tuiModel) listHeight() int {
+	if m.height == 0 {
+		return 20
+	}
+	return max(m.height-10, 3)
+}
+
+// windowStart returns the first of n items shown in a list of height rows, keeping cursor visible.
+func windowStart(n, cursor, height int) int {
+	if n <= height || cursor < height/2 {
+		return 0
+	}
+	return min(cursor-height/2, n-height)
+}
+
+// visibleWindow returns the items shown in a list of height rows, keeping cursor visible.
+func visibleWindow[T any](items []T, cursor, height int) []T {
+	start := windowStart(len(items), cursor, height)
+	return items[start:min(start+height, len(items))]
+}
+
+// lastRun describes the latest attempt of an entry.
+func lastRun(rec *internal.HistoryRecord) string {
+	if rec == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s %s", rec.Status, rec.StartTime.In(internal.JST).Format("01-02 15:04"))
+}
+
+// formatSlot renders a schedule start time (HHMMSS) as HH:MM.
+func formatSlot(startTime string) string {
+	if len(startTime) < 4 {
+		return startTime
+	}
+	return startTime[:2] + ":" + startTime[2:4]
+}
+
+// pad truncates or pads s to width terminal columns; Japanese characters take two.
+func pad(s string, width int) string {
+	if lipgloss.Width(s) > width {
+		r := []rune(s)
+		for len(r) > 0 && lipgloss.Width(string(r))+1 > width {
+			r = r[:len(r)-1]
+		}
+		s = string(r) + "…"
+	}
+	return s + strings.Repeat(" ", max(width-lipgloss.Width(s), 0))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"radikoRecScheduler/internal"
+)
+
+// versionCheckTimeout bounds the online compatibility check of "version -check".
+const versionCheckTimeout = time.Minute
+
+// runVersionCommand implements the "version" subcommand, which prints how the binary was built
+// and, with -check, probes whether radiko still works the way it expects.
+func runVersionCommand(args []string) error {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	check := fs.Bool("check", false, "Also check online that radiko's auth and playlist endpoints still work with this binary.")
+	station := fs.String("station", "", "Station whose playlist -check resolves. Defaults to the first radiko station in the schedule.")
+	fs.Parse(args)
+
+	fmt.Print(internal.ReadBuildInfo())
+	if !*check {
+		return nil
+	}
+
+	config := loadConfig(currentProfile)
+	stationID := *station
+	if stationID == "" {
+		schedule, _ := resolveSchedule(currentProfile, config, defaultSchedulePath(currentProfile), false)
+		for _, entry := range schedule {
+			if entry.IsEnabled() && (entry.Provider == "" || entry.Provider == internal.ProviderRadiko) {
+				stationID = entry.StationID
+				break
+			}
+		}
+	}
+	if stationID == "" {
+		return fmt.Errorf("no radiko station in the schedule; give one with -station")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, versionCheckTimeout)
+	defer cancel()
+
+	fmt.Println()
+	failed := false
+	for _, c := range internal.CheckCompatibility(ctx, newOptions(currentProfile, config, nil, true, true), stationID) {
+		if c.Err != nil {
+			failed = true
+			fmt.Printf("%-9s FAIL  %v\n", c.Name, c.Err)
+			continue
+		}
+		fmt.Printf("%-9s ok    %s\n", c.Name, c.Detail)
+	}
+	if failed {
+		return fmt.Errorf("radiko is not working as this version expects; check for a newer release")
+	}
+	fmt.Println("This version is compatible with radiko.")
+	return nil
+}